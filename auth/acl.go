@@ -0,0 +1,59 @@
+package auth
+
+import "strings"
+
+// Rule the permissions granted to the principal
+type Rule struct {
+	Principal   string       `yaml:"principal" json:"principal" validate:"nonzero"` // the name of principal, '*' for all principals
+	Permissions []Permission `yaml:"permissions" json:"permissions"`
+}
+
+// Permission the actions allowed on the resources, the resources are patterns, see Match
+type Permission struct {
+	Action    string   `yaml:"action" json:"action" validate:"nonzero"` // such as pub, sub, call and http methods, '*' for all actions
+	Resources []string `yaml:"resources" json:"resources"`
+}
+
+// ACL the access control list, the action is denied unless it is allowed by any rule
+type ACL struct {
+	rules []Rule
+}
+
+// NewACL creates a new acl
+func NewACL(rules []Rule) *ACL {
+	return &ACL{rules: rules}
+}
+
+// Authorize authorizes the action of principal on the resource
+func (a *ACL) Authorize(p *Principal, action, resource string) error {
+	if p == nil {
+		return ErrUnauthenticated
+	}
+	for _, r := range a.rules {
+		if r.Principal != "*" && r.Principal != p.Name {
+			continue
+		}
+		for _, perm := range r.Permissions {
+			if perm.Action != "*" && !strings.EqualFold(perm.Action, action) {
+				continue
+			}
+			for _, res := range perm.Resources {
+				if Match(res, resource) {
+					return nil
+				}
+			}
+		}
+	}
+	return ErrForbidden
+}
+
+// AllowAll the authorizer which allows all actions of principals authenticated
+type AllowAll struct{}
+
+// Authorize allows the action if the principal is authenticated
+func (AllowAll) Authorize(p *Principal, _, _ string) error {
+	if p == nil {
+		return ErrUnauthenticated
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+// Package auth provides the authentication and authorization shared by the mqtt server, the link server and
+// the http middleware. The credentials presented by clients are authenticated by the authenticators, such as
+// password file, client certificate rules and jwt, and the principal authenticated is authorized by the acl.
+package auth
+
+import (
+	"context"
+	"crypto/x509"
+	"strings"
+
+	"github.com/baetyl/baetyl-go/errors"
+)
+
+// all methods of authentication
+const (
+	MethodPassword = "password"
+	MethodCert     = "cert"
+	MethodJWT      = "jwt"
+)
+
+// all actions of the servers, the http middleware uses the request method as action
+const (
+	ActionPublish   = "pub"
+	ActionSubscribe = "sub"
+	ActionCall      = "call"
+)
+
+// all errors, which are coded so that they can be converted to grpc status and http status
+var (
+	ErrUnauthenticated = errors.Coded(errors.CodeUnauthenticated, "unauthenticated")
+	ErrForbidden       = errors.Coded(errors.CodePermissionDenied, "permission denied")
+)
+
+// Credentials the credentials presented by client, the certificates are verified by the tls handshake
+type Credentials struct {
+	Username     string
+	Password     string
+	Token        string
+	Certificates []*x509.Certificate // the leaf certificate first
+}
+
+// Principal the identity authenticated
+type Principal struct {
+	Name   string `json:"name"`
+	Method string `json:"method"`
+}
+
+// Authenticator authenticates the credentials and returns the principal
+type Authenticator interface {
+	Authenticate(c *Credentials) (*Principal, error)
+}
+
+// Authorizer authorizes the action of principal on the resource, such as the topic or the path
+type Authorizer interface {
+	Authorize(p *Principal, action, resource string) error
+}
+
+// AuthenticatorFunc the function to authenticate
+type AuthenticatorFunc func(c *Credentials) (*Principal, error)
+
+// Authenticate calls the function
+func (f AuthenticatorFunc) Authenticate(c *Credentials) (*Principal, error) {
+	return f(c)
+}
+
+// Chain the authenticators tried in order, the first principal authenticated is returned
+type Chain []Authenticator
+
+// Authenticate authenticates the credentials by the authenticators in order
+func (c Chain) Authenticate(cred *Credentials) (*Principal, error) {
+	for _, a := range c {
+		p, err := a.Authenticate(cred)
+		if err == nil {
+			return p, nil
+		}
+	}
+	return nil, ErrUnauthenticated
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of the context with the principal
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the principal set by the servers
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+// Match checks whether the resource matches the pattern, the resources are separated into levels by '/',
+// '+' matches one level and '#' matches all remaining levels as the topic filter of mqtt, '*' matches all
+func Match(pattern, resource string) bool {
+	if pattern == "*" || pattern == resource {
+		return true
+	}
+	ps := strings.Split(pattern, "/")
+	rs := strings.Split(resource, "/")
+	for i, p := range ps {
+		if p == "#" {
+			return i == len(ps)-1
+		}
+		if i >= len(rs) {
+			return false
+		}
+		if p != "+" && p != rs[i] {
+			return false
+		}
+	}
+	return len(ps) == len(rs)
+}
@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/baetyl/baetyl-go/errors"
+	jwt "github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	cases := []struct {
+		pattern, resource string
+		matched           bool
+	}{
+		{"*", "a/b", true},
+		{"a/b", "a/b", true},
+		{"a/+", "a/b", true},
+		{"a/+", "a/b/c", false},
+		{"a/#", "a", true},
+		{"a/#", "a/b/c", true},
+		{"#", "a/b", true},
+		{"/v1/#", "/v1/configs/c1", true},
+		{"/v1/+", "/v2/configs", false},
+		{"a/b", "a", false},
+		{"a/#/c", "a/b/c", false},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.matched, Match(c.pattern, c.resource), c.pattern+" "+c.resource)
+	}
+}
+
+func TestACL(t *testing.T) {
+	acl := NewACL([]Rule{
+		{Principal: "svc1", Permissions: []Permission{
+			{Action: ActionPublish, Resources: []string{"a/#"}},
+			{Action: "get", Resources: []string{"/v1/+"}},
+		}},
+		{Principal: "*", Permissions: []Permission{{Action: "*", Resources: []string{"public"}}}},
+	})
+	p := &Principal{Name: "svc1"}
+	assert.NoError(t, acl.Authorize(p, ActionPublish, "a/b"))
+	assert.NoError(t, acl.Authorize(p, "GET", "/v1/configs"))
+	assert.NoError(t, acl.Authorize(&Principal{Name: "svc2"}, ActionSubscribe, "public"))
+	assert.Equal(t, ErrForbidden, acl.Authorize(p, ActionSubscribe, "a/b"))
+	assert.Equal(t, ErrForbidden, acl.Authorize(&Principal{Name: "svc2"}, ActionPublish, "a/b"))
+	assert.Equal(t, ErrUnauthenticated, acl.Authorize(nil, ActionPublish, "public"))
+	assert.Equal(t, errors.CodePermissionDenied, errors.CodeOf(ErrForbidden))
+
+	assert.NoError(t, AllowAll{}.Authorize(p, ActionPublish, "x"))
+	assert.Equal(t, ErrUnauthenticated, AllowAll{}.Authorize(nil, ActionPublish, "x"))
+}
+
+func TestPasswords(t *testing.T) {
+	dir, err := ioutil.TempDir("", "auth")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	fn := filepath.Join(dir, "passwd")
+	// the sha256 of "secret"
+	data := "# users\n\nu1:p1\nu2:sha256:2BB80D537B1DA3E38BD30361AA855686BDE0EACD7162FEF6A25FE97BF527A25B\n"
+	assert.NoError(t, ioutil.WriteFile(fn, []byte(data), 0600))
+	a, err := LoadPasswordFile(fn)
+	assert.NoError(t, err)
+
+	p, err := a.Authenticate(&Credentials{Username: "u1", Password: "p1"})
+	assert.NoError(t, err)
+	assert.Equal(t, &Principal{Name: "u1", Method: MethodPassword}, p)
+	p, err = a.Authenticate(&Credentials{Username: "u2", Password: "secret"})
+	assert.NoError(t, err)
+	assert.Equal(t, "u2", p.Name)
+	_, err = a.Authenticate(&Credentials{Username: "u1", Password: "p2"})
+	assert.Equal(t, ErrUnauthenticated, err)
+	_, err = a.Authenticate(&Credentials{Username: "u3", Password: "p1"})
+	assert.Equal(t, ErrUnauthenticated, err)
+	_, err = a.Authenticate(&Credentials{})
+	assert.Equal(t, ErrUnauthenticated, err)
+
+	assert.NoError(t, ioutil.WriteFile(fn, []byte("u1:p1\nbad\n"), 0600))
+	_, err = LoadPasswordFile(fn)
+	assert.EqualError(t, err, "line 2 of password file is invalid")
+}
+
+func TestCerts(t *testing.T) {
+	crt := &x509.Certificate{Subject: pkix.Name{CommonName: "node1", OrganizationalUnit: []string{"edge", "gateway"}}}
+	c := &Credentials{Certificates: []*x509.Certificate{crt}}
+
+	_, err := NewCerts(nil).Authenticate(&Credentials{})
+	assert.Equal(t, ErrUnauthenticated, err)
+	p, err := NewCerts(nil).Authenticate(c)
+	assert.NoError(t, err)
+	assert.Equal(t, &Principal{Name: "node1", Method: MethodCert}, p)
+
+	a := NewCerts([]CertRule{
+		{CN: "node2"},
+		{OU: "gateway", Principal: "gateways"},
+	})
+	p, err = a.Authenticate(c)
+	assert.NoError(t, err)
+	assert.Equal(t, "gateways", p.Name)
+	_, err = NewCerts([]CertRule{{CN: "node1", OU: "cloud"}}).Authenticate(c)
+	assert.Equal(t, ErrUnauthenticated, err)
+}
+
+func TestJWT(t *testing.T) {
+	_, err := NewJWT(JWTConfig{})
+	assert.EqualError(t, err, "either secret or public key is required")
+
+	a, err := NewJWT(JWTConfig{Secret: "secret", Issuer: "baetyl"})
+	assert.NoError(t, err)
+	sign := func(claims jwt.StandardClaims, method jwt.SigningMethod, key interface{}) string {
+		s, err := jwt.NewWithClaims(method, claims).SignedString(key)
+		assert.NoError(t, err)
+		return s
+	}
+	exp := time.Now().Add(time.Hour).Unix()
+	p, err := a.Authenticate(&Credentials{Token: sign(jwt.StandardClaims{Subject: "svc1", Issuer: "baetyl", ExpiresAt: exp}, jwt.SigningMethodHS256, []byte("secret"))})
+	assert.NoError(t, err)
+	assert.Equal(t, &Principal{Name: "svc1", Method: MethodJWT}, p)
+	_, err = a.Authenticate(&Credentials{Token: sign(jwt.StandardClaims{Subject: "svc1", Issuer: "other"}, jwt.SigningMethodHS256, []byte("secret"))})
+	assert.Equal(t, ErrUnauthenticated, err)
+	_, err = a.Authenticate(&Credentials{Token: sign(jwt.StandardClaims{Subject: "svc1", Issuer: "baetyl"}, jwt.SigningMethodHS256, []byte("other"))})
+	assert.Equal(t, ErrUnauthenticated, err)
+	_, err = a.Authenticate(&Credentials{})
+	assert.Equal(t, ErrUnauthenticated, err)
+
+	dir, err := ioutil.TempDir("", "auth")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	assert.NoError(t, err)
+	fn := filepath.Join(dir, "pub.pem")
+	assert.NoError(t, ioutil.WriteFile(fn, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600))
+
+	a, err = NewJWT(JWTConfig{PublicKey: fn, Audience: "broker"})
+	assert.NoError(t, err)
+	p, err = a.Authenticate(&Credentials{Token: sign(jwt.StandardClaims{Subject: "svc2", Audience: "broker"}, jwt.SigningMethodES256, key)})
+	assert.NoError(t, err)
+	assert.Equal(t, "svc2", p.Name)
+	// the token signed by hmac with the public key as secret is rejected
+	_, err = a.Authenticate(&Credentials{Token: sign(jwt.StandardClaims{Subject: "svc2", Audience: "broker"}, jwt.SigningMethodHS256, der)})
+	assert.Equal(t, ErrUnauthenticated, err)
+}
+
+func TestConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "auth")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	fn := filepath.Join(dir, "passwd")
+	assert.NoError(t, ioutil.WriteFile(fn, []byte("u1:p1\n"), 0600))
+
+	a, err := NewAuthenticator(Config{PasswordFile: fn, CertsEnabled: true, JWT: JWTConfig{Secret: "secret"}})
+	assert.NoError(t, err)
+	assert.Len(t, a, 3)
+	p, err := a.Authenticate(&Credentials{Username: "u1", Password: "p1"})
+	assert.NoError(t, err)
+	assert.Equal(t, MethodPassword, p.Method)
+	p, err = a.Authenticate(&Credentials{Certificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "node1"}}}})
+	assert.NoError(t, err)
+	assert.Equal(t, MethodCert, p.Method)
+	_, err = a.Authenticate(&Credentials{Username: "u1", Password: "p2"})
+	assert.Equal(t, ErrUnauthenticated, err)
+
+	_, err = NewAuthenticator(Config{PasswordFile: filepath.Join(dir, "missing")})
+	assert.Error(t, err)
+
+	assert.Equal(t, AllowAll{}, NewAuthorizer(Config{}))
+	assert.IsType(t, &ACL{}, NewAuthorizer(Config{ACL: []Rule{{Principal: "*"}}}))
+}
@@ -0,0 +1,59 @@
+package auth
+
+// CertRule the rule to authenticate the client certificate by its common name and organizational units
+type CertRule struct {
+	CN        string `yaml:"cn" json:"cn"`               // the common name, '*' or empty for all
+	OU        string `yaml:"ou" json:"ou"`               // one of the organizational units, '*' or empty for all
+	Principal string `yaml:"principal" json:"principal"` // the principal name, the common name if empty
+}
+
+// Certs authenticates the client certificate verified by the tls handshake
+type Certs struct {
+	rules []CertRule
+}
+
+// NewCerts creates a new client certificate authenticator, any certificate is allowed if no rule
+func NewCerts(rules []CertRule) *Certs {
+	return &Certs{rules: rules}
+}
+
+// Authenticate authenticates the leaf certificate by the first rule matched
+func (a *Certs) Authenticate(c *Credentials) (*Principal, error) {
+	if len(c.Certificates) == 0 {
+		return nil, ErrUnauthenticated
+	}
+	subject := c.Certificates[0].Subject
+	if len(a.rules) == 0 {
+		return &Principal{Name: subject.CommonName, Method: MethodCert}, nil
+	}
+	for _, r := range a.rules {
+		if !matchAny(r.CN, subject.CommonName) {
+			continue
+		}
+		if !matchOU(r.OU, subject.OrganizationalUnit) {
+			continue
+		}
+		name := r.Principal
+		if name == "" {
+			name = subject.CommonName
+		}
+		return &Principal{Name: name, Method: MethodCert}, nil
+	}
+	return nil, ErrUnauthenticated
+}
+
+func matchAny(pattern, v string) bool {
+	return pattern == "" || pattern == "*" || pattern == v
+}
+
+func matchOU(pattern string, ous []string) bool {
+	if pattern == "" || pattern == "*" {
+		return true
+	}
+	for _, ou := range ous {
+		if ou == pattern {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package auth
+
+// Config the config of authentication and authorization, the authenticators configured are tried
+// in the order of password file, client certificate and jwt, all actions are allowed if the acl is empty
+type Config struct {
+	PasswordFile string     `yaml:"passwordFile" json:"passwordFile"`
+	Certs        []CertRule `yaml:"certs" json:"certs"`
+	CertsEnabled bool       `yaml:"certsEnabled" json:"certsEnabled"` // enables client certificate authentication even if no rule
+	JWT          JWTConfig  `yaml:"jwt" json:"jwt"`
+	ACL          []Rule     `yaml:"acl" json:"acl"`
+}
+
+// JWTConfig the config of jwt authentication, either the secret or the public key is required
+type JWTConfig struct {
+	Secret    string `yaml:"secret" json:"secret"`
+	PublicKey string `yaml:"publicKey" json:"publicKey"` // the pem file of rsa or ecdsa public key
+	Issuer    string `yaml:"issuer" json:"issuer"`
+	Audience  string `yaml:"audience" json:"audience"`
+}
+
+// NewAuthenticator creates the chain of authenticators configured
+func NewAuthenticator(cfg Config) (Authenticator, error) {
+	var c Chain
+	if cfg.PasswordFile != "" {
+		a, err := LoadPasswordFile(cfg.PasswordFile)
+		if err != nil {
+			return nil, err
+		}
+		c = append(c, a)
+	}
+	if cfg.CertsEnabled || len(cfg.Certs) > 0 {
+		c = append(c, NewCerts(cfg.Certs))
+	}
+	if cfg.JWT.Secret != "" || cfg.JWT.PublicKey != "" {
+		a, err := NewJWT(cfg.JWT)
+		if err != nil {
+			return nil, err
+		}
+		c = append(c, a)
+	}
+	return c, nil
+}
+
+// NewAuthorizer creates the authorizer configured
+func NewAuthorizer(cfg Config) Authorizer {
+	if len(cfg.ACL) == 0 {
+		return AllowAll{}
+	}
+	return NewACL(cfg.ACL)
+}
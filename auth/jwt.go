@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	jwt "github.com/golang-jwt/jwt"
+)
+
+// JWT authenticates the jwt token signed by the secret (HMAC) or the private key paired with the public key (RSA or ECDSA),
+// the subject claim is the principal
+type JWT struct {
+	cfg JWTConfig
+	key interface{}
+}
+
+// NewJWT creates a new jwt authenticator
+func NewJWT(cfg JWTConfig) (*JWT, error) {
+	a := &JWT{cfg: cfg}
+	switch {
+	case cfg.PublicKey != "":
+		data, err := ioutil.ReadFile(cfg.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		b, _ := pem.Decode(data)
+		if b == nil {
+			return nil, errors.New("failed to decode pem of public key")
+		}
+		pub, err := x509.ParsePKIXPublicKey(b.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		switch pub.(type) {
+		case *rsa.PublicKey, *ecdsa.PublicKey:
+		default:
+			return nil, fmt.Errorf("public key type (%T) not supported", pub)
+		}
+		a.key = pub
+	case cfg.Secret != "":
+		a.key = []byte(cfg.Secret)
+	default:
+		return nil, errors.New("either secret or public key is required")
+	}
+	return a, nil
+}
+
+// Authenticate authenticates the token
+func (a *JWT) Authenticate(c *Credentials) (*Principal, error) {
+	if c.Token == "" {
+		return nil, ErrUnauthenticated
+	}
+	claims := &jwt.StandardClaims{}
+	_, err := jwt.ParseWithClaims(c.Token, claims, a.keyFunc)
+	if err != nil {
+		return nil, ErrUnauthenticated
+	}
+	if a.cfg.Issuer != "" && !claims.VerifyIssuer(a.cfg.Issuer, true) {
+		return nil, ErrUnauthenticated
+	}
+	if a.cfg.Audience != "" && !claims.VerifyAudience(a.cfg.Audience, true) {
+		return nil, ErrUnauthenticated
+	}
+	if claims.Subject == "" {
+		return nil, ErrUnauthenticated
+	}
+	return &Principal{Name: claims.Subject, Method: MethodJWT}, nil
+}
+
+func (a *JWT) keyFunc(t *jwt.Token) (interface{}, error) {
+	var ok bool
+	switch a.key.(type) {
+	case []byte:
+		_, ok = t.Method.(*jwt.SigningMethodHMAC)
+	case *rsa.PublicKey:
+		_, ok = t.Method.(*jwt.SigningMethodRSA)
+	case *ecdsa.PublicKey:
+		_, ok = t.Method.(*jwt.SigningMethodECDSA)
+	}
+	if !ok {
+		return nil, fmt.Errorf("unexpected signing method (%s)", t.Header["alg"])
+	}
+	return a.key, nil
+}
@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// Passwords authenticates the username and password, the password is plain text or sha256:<hex>
+type Passwords struct {
+	users map[string]string
+}
+
+// NewPasswords creates a new password authenticator, the map is from username to password
+func NewPasswords(users map[string]string) *Passwords {
+	return &Passwords{users: users}
+}
+
+// LoadPasswordFile loads the password file, each line is username:password,
+// the empty lines and the lines starting with '#' are ignored
+func LoadPasswordFile(file string) (*Passwords, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	users := map[string]string{}
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("line %d of password file is invalid", n)
+		}
+		users[parts[0]] = parts[1]
+	}
+	if err = s.Err(); err != nil {
+		return nil, err
+	}
+	return NewPasswords(users), nil
+}
+
+// Authenticate authenticates the username and password, the username is the principal
+func (a *Passwords) Authenticate(c *Credentials) (*Principal, error) {
+	if c.Username == "" {
+		return nil, ErrUnauthenticated
+	}
+	expected, ok := a.users[c.Username]
+	if !ok || !matchPassword(expected, c.Password) {
+		return nil, ErrUnauthenticated
+	}
+	return &Principal{Name: c.Username, Method: MethodPassword}, nil
+}
+
+func matchPassword(expected, password string) bool {
+	if strings.HasPrefix(expected, "sha256:") {
+		sum := sha256.Sum256([]byte(password))
+		password = hex.EncodeToString(sum[:])
+		expected = strings.ToLower(strings.TrimPrefix(expected, "sha256:"))
+	}
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(password)) == 1
+}
@@ -0,0 +1,320 @@
+// Package bench generates the load of mqtt and link with the connections, the rate, the payload size and the qos mix
+// configured, and reports the throughput and the latencies, so that the capacity is measured on the target hardware.
+package bench
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/baetyl/baetyl-go/link"
+	"github.com/baetyl/baetyl-go/log"
+	"github.com/baetyl/baetyl-go/mqtt"
+)
+
+// the payload starts with the unix nano when sent and the sequence, so that the end-to-end latency is measured by receivers
+const headerSize = 16
+
+// conn the connection which sends the messages
+type conn interface {
+	send(qos uint32, id uint64, payload []byte) error
+	close() error
+}
+
+type runner struct {
+	cfg      Config
+	ack      recorder
+	e2e      recorder
+	sent     int64
+	sentQOS1 int64
+	acked    int64
+	received int64
+	errors   int64
+	log      *log.Logger
+}
+
+// worker the sender of a connection, which tracks the messages of qos 1 not acknowledged
+type worker struct {
+	r       *runner
+	index   int
+	conn    conn
+	pending map[uint64]time.Time
+	mu      sync.Mutex
+}
+
+// Run runs the load test until the duration or the count is reached, or the context is done,
+// then waits for the acks and the messages in flight for the drain duration at most
+func Run(ctx context.Context, cfg Config) (*Report, error) {
+	if cfg.PayloadSize < headerSize {
+		cfg.PayloadSize = headerSize
+	}
+	r := &runner{cfg: cfg, log: log.With(log.Any("bench", cfg.Protocol))}
+	workers := make([]*worker, cfg.Connections)
+	closeAll := func() {
+		for _, w := range workers {
+			if w != nil && w.conn != nil {
+				w.conn.close()
+			}
+		}
+	}
+	for i := range workers {
+		w := &worker{r: r, index: i, pending: map[uint64]time.Time{}}
+		var err error
+		switch cfg.Protocol {
+		case ProtocolMQTT, "":
+			w.conn, err = newMQTTConn(w)
+		case ProtocolLink:
+			w.conn, err = newLinkConn(w)
+		default:
+			err = fmt.Errorf("protocol (%s) not supported", cfg.Protocol)
+		}
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		workers[i] = w
+	}
+	defer closeAll()
+
+	sctx := ctx
+	if cfg.Duration > 0 {
+		var cancel context.CancelFunc
+		sctx, cancel = context.WithTimeout(ctx, cfg.Duration)
+		defer cancel()
+	}
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, w := range workers {
+		wg.Add(1)
+		go func(w *worker) {
+			defer wg.Done()
+			w.sending(sctx)
+		}(w)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	r.draining(ctx, workers)
+
+	rep := &Report{
+		Protocol:    cfg.Protocol,
+		Connections: cfg.Connections,
+		Elapsed:     elapsed,
+		Sent:        atomic.LoadInt64(&r.sent),
+		SentQOS1:    atomic.LoadInt64(&r.sentQOS1),
+		Acked:       atomic.LoadInt64(&r.acked),
+		Received:    atomic.LoadInt64(&r.received),
+		Errors:      atomic.LoadInt64(&r.errors),
+		Ack:         r.ack.latency(),
+		EndToEnd:    r.e2e.latency(),
+	}
+	if s := elapsed.Seconds(); s > 0 {
+		rep.Throughput = float64(rep.Sent) / s
+		rep.Bandwidth = float64(rep.Sent) * float64(cfg.PayloadSize) / s
+	}
+	return rep, nil
+}
+
+func (w *worker) sending(ctx context.Context) {
+	cfg := w.r.cfg
+	var interval time.Duration
+	if cfg.Rate > 0 {
+		interval = time.Duration(float64(time.Second) / cfg.Rate)
+	}
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano() + int64(w.index)))
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	next := time.Now()
+	var qid uint64
+	for seq := uint64(1); cfg.Count <= 0 || seq <= uint64(cfg.Count); seq++ {
+		if interval > 0 {
+			timer.Reset(time.Until(next))
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+			}
+			next = next.Add(interval)
+		} else if ctx.Err() != nil {
+			return
+		}
+
+		var qos uint32
+		var id uint64
+		now := time.Now()
+		if cfg.QOS1Ratio > 0 && rnd.Float64() < cfg.QOS1Ratio {
+			qos = 1
+			qid++
+			id = qid
+			if cfg.Protocol != ProtocolLink {
+				// the packet id of mqtt is 16 bits and non-zero
+				id = (qid-1)%65535 + 1
+			}
+			w.mu.Lock()
+			w.pending[id] = now
+			w.mu.Unlock()
+		}
+		payload := make([]byte, int(cfg.PayloadSize))
+		binary.BigEndian.PutUint64(payload, uint64(now.UnixNano()))
+		binary.BigEndian.PutUint64(payload[8:], seq)
+		if err := w.conn.send(qos, id, payload); err != nil {
+			atomic.AddInt64(&w.r.errors, 1)
+			w.r.log.Debug("failed to send message", log.Error(err))
+			continue
+		}
+		atomic.AddInt64(&w.r.sent, 1)
+		if qos == 1 {
+			atomic.AddInt64(&w.r.sentQOS1, 1)
+		}
+	}
+}
+
+func (w *worker) onAck(id uint64) {
+	w.mu.Lock()
+	sent, ok := w.pending[id]
+	delete(w.pending, id)
+	w.mu.Unlock()
+	if !ok {
+		return
+	}
+	w.r.ack.add(time.Since(sent))
+	atomic.AddInt64(&w.r.acked, 1)
+}
+
+func (w *worker) onReceive(payload []byte) {
+	if len(payload) < headerSize {
+		return
+	}
+	sent := time.Unix(0, int64(binary.BigEndian.Uint64(payload)))
+	w.r.e2e.add(time.Since(sent))
+	atomic.AddInt64(&w.r.received, 1)
+}
+
+func (w *worker) topic() string {
+	return w.r.cfg.Topic + "/" + strconv.Itoa(w.index)
+}
+
+// draining waits until all messages of qos 1 are acknowledged and all messages expected are received
+func (r *runner) draining(ctx context.Context, workers []*worker) {
+	if r.cfg.Drain <= 0 {
+		return
+	}
+	deadline := time.After(r.cfg.Drain)
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		pending := 0
+		for _, w := range workers {
+			w.mu.Lock()
+			pending += len(w.pending)
+			w.mu.Unlock()
+		}
+		// the messages are expected to come back if subscribed, or if the link server has sent any back
+		received := atomic.LoadInt64(&r.received)
+		expected := (r.cfg.Protocol != ProtocolLink && !r.cfg.DisableSubscribe) || received > 0
+		if pending == 0 && (!expected || received >= atomic.LoadInt64(&r.sent)) {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-deadline:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+type mqttConn struct {
+	cli   *mqtt.Client
+	topic string
+}
+
+// newMQTTConn creates a mqtt connection, the client id is suffixed by the index of connection
+func newMQTTConn(w *worker) (conn, error) {
+	cc := w.r.cfg.MQTT
+	if cc.ClientID == "" {
+		cc.ClientID = "bench"
+	}
+	cc.ClientID += "-" + strconv.Itoa(w.index)
+	obs := mqtt.NewObserverWrapper(func(pkt *mqtt.Publish) error {
+		w.onReceive(pkt.Message.Payload)
+		return nil
+	}, func(pkt *mqtt.Puback) error {
+		w.onAck(uint64(pkt.ID))
+		return nil
+	}, func(err error) {
+		atomic.AddInt64(&w.r.errors, 1)
+		w.r.log.Debug("error occurs", log.Error(err))
+	})
+	cli, err := mqtt.NewClient(cc, obs)
+	if err != nil {
+		return nil, err
+	}
+	c := &mqttConn{cli: cli, topic: w.topic()}
+	if !w.r.cfg.DisableSubscribe {
+		if err = cli.Subscribe([]mqtt.Subscription{{Topic: c.topic, QOS: 1}}); err != nil {
+			cli.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (c *mqttConn) send(qos uint32, id uint64, payload []byte) error {
+	return c.cli.Publish(mqtt.QOS(qos), c.topic, payload, mqtt.ID(id), false, false)
+}
+
+func (c *mqttConn) close() error {
+	return c.cli.Close()
+}
+
+type linkConn struct {
+	cli   *link.Client
+	topic string
+}
+
+// newLinkConn creates a link connection, the end-to-end latency is measured if the server sends the messages back
+func newLinkConn(w *worker) (conn, error) {
+	obs := &linkObserver{w: w}
+	cli, err := link.NewClient(w.r.cfg.Link, obs)
+	if err != nil {
+		return nil, err
+	}
+	return &linkConn{cli: cli, topic: w.topic()}, nil
+}
+
+func (c *linkConn) send(qos uint32, id uint64, payload []byte) error {
+	msg := &link.Message{Content: payload}
+	msg.Context.ID = id
+	msg.Context.QOS = qos
+	msg.Context.Topic = c.topic
+	return c.cli.Send(msg)
+}
+
+func (c *linkConn) close() error {
+	return c.cli.Close()
+}
+
+type linkObserver struct {
+	w *worker
+}
+
+func (o *linkObserver) OnMsg(msg *link.Message) error {
+	o.w.onReceive(msg.Content)
+	return nil
+}
+
+func (o *linkObserver) OnAck(msg *link.Message) error {
+	o.w.onAck(msg.Context.ID)
+	return nil
+}
+
+func (o *linkObserver) OnErr(err error) {
+	atomic.AddInt64(&o.w.r.errors, 1)
+	o.w.r.log.Debug("error occurs", log.Error(err))
+}
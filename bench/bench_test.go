@@ -0,0 +1,109 @@
+package bench
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/baetyl/baetyl-go/link"
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// echoServer acknowledges the messages of qos 1 and sends all messages back
+type echoServer struct{}
+
+func (s *echoServer) Call(_ context.Context, msg *link.Message) (*link.Message, error) {
+	return msg, nil
+}
+
+func (s *echoServer) Talk(stream link.Link_TalkServer) error {
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			return nil
+		}
+		if msg.Context.QOS == 1 {
+			ack := &link.Message{}
+			ack.Context.ID = msg.Context.ID
+			ack.Context.Type = link.Ack
+			if err = stream.Send(ack); err != nil {
+				return err
+			}
+		}
+		echo := &link.Message{Content: msg.Content}
+		echo.Context.Topic = msg.Context.Topic
+		if err = stream.Send(echo); err != nil {
+			return err
+		}
+	}
+}
+
+func TestLink(t *testing.T) {
+	var sc link.ServerConfig
+	assert.NoError(t, utils.SetDefaults(&sc))
+	svr, err := link.NewServer(sc, nil)
+	assert.NoError(t, err)
+	link.RegisterLinkServer(svr, &echoServer{})
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go svr.Serve(lis)
+	defer svr.Stop()
+
+	var cfg Config
+	assert.NoError(t, utils.SetDefaults(&cfg))
+	cfg.Protocol = ProtocolLink
+	cfg.Link.Address = lis.Addr().String()
+	cfg.Connections = 2
+	cfg.Count = 50
+	cfg.Rate = 1000
+	cfg.QOS1Ratio = 0.5
+	cfg.PayloadSize = 8 // enlarged to carry the header
+	cfg.Drain = 5 * time.Second
+
+	rep, err := Run(context.Background(), cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), rep.Sent)
+	assert.Equal(t, int64(0), rep.Errors)
+	assert.Equal(t, rep.SentQOS1, rep.Acked)
+	assert.Equal(t, rep.Ack.Count, rep.Acked)
+	assert.Equal(t, int64(100), rep.Received)
+	assert.Equal(t, int64(100), rep.EndToEnd.Count)
+	assert.True(t, rep.Throughput > 0)
+	assert.Equal(t, rep.Throughput*headerSize, rep.Bandwidth)
+	assert.Contains(t, rep.String(), "sent:        100")
+	t.Log("\n" + rep.String())
+
+	// stops after the duration
+	cfg.Count = 0
+	cfg.Rate = 100
+	cfg.Duration = 200 * time.Millisecond
+	rep, err = Run(context.Background(), cfg)
+	assert.NoError(t, err)
+	assert.True(t, rep.Sent > 0 && rep.Sent <= 50, "%d", rep.Sent)
+
+	cfg.Protocol = "amqp"
+	_, err = Run(context.Background(), cfg)
+	assert.EqualError(t, err, "protocol (amqp) not supported")
+}
+
+func TestLatency(t *testing.T) {
+	assert.Equal(t, "n/a", newLatency(nil).String())
+
+	var samples []time.Duration
+	for i := 100; i > 0; i-- {
+		samples = append(samples, time.Duration(i)*time.Millisecond)
+	}
+	l := newLatency(samples)
+	assert.Equal(t, Latency{
+		Count: 100,
+		Min:   time.Millisecond,
+		Max:   100 * time.Millisecond,
+		Mean:  50500 * time.Microsecond,
+		P50:   50 * time.Millisecond,
+		P90:   90 * time.Millisecond,
+		P99:   99 * time.Millisecond,
+	}, l)
+	assert.Equal(t, "count=100 min=1ms mean=50.5ms p50=50ms p90=90ms p99=99ms max=100ms", l.String())
+}
@@ -0,0 +1,31 @@
+package bench
+
+import (
+	"time"
+
+	"github.com/baetyl/baetyl-go/link"
+	"github.com/baetyl/baetyl-go/mqtt"
+	"github.com/baetyl/baetyl-go/utils"
+)
+
+// all protocols
+const (
+	ProtocolMQTT = "mqtt"
+	ProtocolLink = "link"
+)
+
+// Config the config of load test
+type Config struct {
+	Protocol         string            `yaml:"protocol" json:"protocol" default:"mqtt" validate:"regexp=^(mqtt|link)$"`
+	MQTT             mqtt.ClientConfig `yaml:"mqtt" json:"mqtt"`
+	Link             link.ClientConfig `yaml:"link" json:"link"`
+	Connections      int               `yaml:"connections" json:"connections" default:"1" validate:"min=1"`
+	Rate             float64           `yaml:"rate" json:"rate"`                                   // the messages per second of each connection, unlimited if zero
+	Count            int               `yaml:"count" json:"count"`                                 // the messages of each connection, unlimited if zero
+	Duration         time.Duration     `yaml:"duration" json:"duration" default:"10s"`             // the duration to send, unlimited if zero
+	Drain            time.Duration     `yaml:"drain" json:"drain" default:"2s"`                    // the duration to wait for the acks and the messages after sent
+	PayloadSize      utils.Size        `yaml:"payloadSize" json:"payloadSize" default:"256"`       // at least 16 bytes to carry the timestamp and the sequence
+	QOS1Ratio        float64           `yaml:"qos1Ratio" json:"qos1Ratio" validate:"min=0, max=1"` // the ratio of qos 1 messages, the others are qos 0
+	Topic            string            `yaml:"topic" json:"topic" default:"bench"`                 // the topic prefix, followed by the index of connection
+	DisableSubscribe bool              `yaml:"disableSubscribe" json:"disableSubscribe"`           // the mqtt connections subscribe their topics to measure the end-to-end latency unless disabled
+}
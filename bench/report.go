@@ -0,0 +1,105 @@
+package bench
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Latency the statistics of latency
+type Latency struct {
+	Count int64         `json:"count"`
+	Min   time.Duration `json:"min"`
+	Max   time.Duration `json:"max"`
+	Mean  time.Duration `json:"mean"`
+	P50   time.Duration `json:"p50"`
+	P90   time.Duration `json:"p90"`
+	P99   time.Duration `json:"p99"`
+}
+
+func (l Latency) String() string {
+	if l.Count == 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("count=%d min=%v mean=%v p50=%v p90=%v p99=%v max=%v", l.Count, l.Min, l.Mean, l.P50, l.P90, l.P99, l.Max)
+}
+
+// Report the report of load test
+type Report struct {
+	Protocol    string        `json:"protocol"`
+	Connections int           `json:"connections"`
+	Elapsed     time.Duration `json:"elapsed"` // the duration of sending
+	Sent        int64         `json:"sent"`
+	SentQOS1    int64         `json:"sentQos1"`
+	Acked       int64         `json:"acked"`
+	Received    int64         `json:"received"`
+	Errors      int64         `json:"errors"`
+	Throughput  float64       `json:"throughput"` // the messages sent per second
+	Bandwidth   float64       `json:"bandwidth"`  // the payload bytes sent per second
+	Ack         Latency       `json:"ack"`        // the latency from sent to acknowledged
+	EndToEnd    Latency       `json:"endToEnd"`   // the latency from sent to received
+}
+
+func (r *Report) String() string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "protocol:    %s\n", r.Protocol)
+	fmt.Fprintf(&b, "connections: %d\n", r.Connections)
+	fmt.Fprintf(&b, "elapsed:     %v\n", r.Elapsed)
+	fmt.Fprintf(&b, "sent:        %d (qos 1: %d, errors: %d)\n", r.Sent, r.SentQOS1, r.Errors)
+	fmt.Fprintf(&b, "acked:       %d\n", r.Acked)
+	fmt.Fprintf(&b, "received:    %d\n", r.Received)
+	fmt.Fprintf(&b, "throughput:  %.1f msg/s, %.1f KB/s\n", r.Throughput, r.Bandwidth/1024)
+	fmt.Fprintf(&b, "ack:         %s\n", r.Ack)
+	fmt.Fprintf(&b, "end-to-end:  %s\n", r.EndToEnd)
+	return b.String()
+}
+
+// recorder records the latencies
+type recorder struct {
+	samples []time.Duration
+	mu      sync.Mutex
+}
+
+func (r *recorder) add(d time.Duration) {
+	r.mu.Lock()
+	r.samples = append(r.samples, d)
+	r.mu.Unlock()
+}
+
+func (r *recorder) latency() Latency {
+	r.mu.Lock()
+	samples := append([]time.Duration{}, r.samples...)
+	r.mu.Unlock()
+	return newLatency(samples)
+}
+
+func newLatency(samples []time.Duration) Latency {
+	l := Latency{Count: int64(len(samples))}
+	if len(samples) == 0 {
+		return l
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	var sum time.Duration
+	for _, s := range samples {
+		sum += s
+	}
+	percentile := func(p float64) time.Duration {
+		i := int(p*float64(len(samples))+0.5) - 1
+		if i < 0 {
+			i = 0
+		}
+		if i >= len(samples) {
+			i = len(samples) - 1
+		}
+		return samples[i]
+	}
+	l.Min = samples[0]
+	l.Max = samples[len(samples)-1]
+	l.Mean = sum / time.Duration(len(samples))
+	l.P50 = percentile(0.5)
+	l.P90 = percentile(0.9)
+	l.P99 = percentile(0.99)
+	return l
+}
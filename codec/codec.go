@@ -0,0 +1,207 @@
+// Package codec provides the registry of serialization codecs keyed by content type,
+// so that mqtt payloads, link contents, http bodies and kv values are encoded consistently.
+package codec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/ugorji/go/codec"
+)
+
+// all content types supported by default
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+	ContentTypeCBOR     = "application/cbor"
+	ContentTypeMsgPack  = "application/x-msgpack"
+)
+
+// ErrNotProtoMessage the value is not a protobuf message
+var ErrNotProtoMessage = errors.New("value is not a protobuf message")
+
+// Codec encodes and decodes values in a content type
+type Codec interface {
+	ContentType() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// all codecs registered by default
+var (
+	JSON     Codec = jsonCodec{}
+	Protobuf Codec = protobufCodec{}
+	CBOR     Codec = &handleCodec{contentType: ContentTypeCBOR, handle: &codec.CborHandle{}}
+	MsgPack  Codec = &handleCodec{contentType: ContentTypeMsgPack, handle: &codec.MsgpackHandle{WriteExt: true}}
+)
+
+var (
+	codecs = map[string]Codec{}
+	mu     sync.RWMutex
+)
+
+func init() {
+	Register(JSON)
+	Register(Protobuf)
+	Register(CBOR)
+	Register(MsgPack)
+	alias("text/json", JSON)
+	alias("application/protobuf", Protobuf)
+	alias("application/msgpack", MsgPack)
+}
+
+// Register registers the codec by its content type, the codec registered before is replaced
+func Register(c Codec) {
+	alias(c.ContentType(), c)
+}
+
+func alias(contentType string, c Codec) {
+	mu.Lock()
+	codecs[strings.ToLower(contentType)] = c
+	mu.Unlock()
+}
+
+// Get returns the codec of the content type, the parameters such as charset are ignored
+func Get(contentType string) (Codec, error) {
+	t := mediaType(contentType)
+	mu.RLock()
+	c, ok := codecs[t]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("codec of content type (%s) not found", contentType)
+	}
+	return c, nil
+}
+
+// ContentTypes returns the content types of all codecs registered, sorted
+func ContentTypes() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	ts := make([]string, 0, len(codecs))
+	for t := range codecs {
+		ts = append(ts, t)
+	}
+	sort.Strings(ts)
+	return ts
+}
+
+// Negotiate returns the codec which is most preferred by the accept header (such as "application/cbor, application/json;q=0.5"),
+// returns the codec of json if the header is empty or nothing matched
+func Negotiate(accept string) Codec {
+	best, q := JSON, -1.0
+	for _, part := range strings.Split(accept, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		t, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		w := 1.0
+		if v, ok := params["q"]; ok {
+			w, err = strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+		}
+		if w <= q {
+			continue
+		}
+		if t == "*/*" {
+			best, q = JSON, w
+			continue
+		}
+		if c, err := Get(t); err == nil {
+			best, q = c, w
+		}
+	}
+	return best
+}
+
+// Marshal encodes the value by the codec of the content type
+func Marshal(contentType string, v interface{}) ([]byte, error) {
+	c, err := Get(contentType)
+	if err != nil {
+		return nil, err
+	}
+	return c.Marshal(v)
+}
+
+// Unmarshal decodes the data into the value by the codec of the content type
+func Unmarshal(contentType string, data []byte, v interface{}) error {
+	c, err := Get(contentType)
+	if err != nil {
+		return err
+	}
+	return c.Unmarshal(data, v)
+}
+
+func mediaType(contentType string) string {
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		contentType = contentType[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string {
+	return ContentTypeJSON
+}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+type protobufCodec struct{}
+
+func (protobufCodec) ContentType() string {
+	return ContentTypeProtobuf
+}
+
+func (protobufCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, ErrNotProtoMessage
+	}
+	return proto.Marshal(m)
+}
+
+func (protobufCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return ErrNotProtoMessage
+	}
+	return proto.Unmarshal(data, m)
+}
+
+type handleCodec struct {
+	contentType string
+	handle      codec.Handle
+}
+
+func (c *handleCodec) ContentType() string {
+	return c.contentType
+}
+
+func (c *handleCodec) Marshal(v interface{}) ([]byte, error) {
+	var data []byte
+	err := codec.NewEncoderBytes(&data, c.handle).Encode(v)
+	return data, err
+}
+
+func (c *handleCodec) Unmarshal(data []byte, v interface{}) error {
+	return codec.NewDecoderBytes(data, c.handle).Decode(v)
+}
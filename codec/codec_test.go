@@ -0,0 +1,77 @@
+package codec
+
+import (
+	"testing"
+
+	"github.com/gogo/protobuf/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type point struct {
+	Name  string   `json:"name"`
+	Value float64  `json:"value"`
+	Tags  []string `json:"tags,omitempty"`
+}
+
+func TestCodecs(t *testing.T) {
+	in := point{Name: "temperature", Value: 36.5, Tags: []string{"a", "b"}}
+	for _, c := range []Codec{JSON, CBOR, MsgPack} {
+		data, err := c.Marshal(in)
+		assert.NoError(t, err, c.ContentType())
+		var out point
+		assert.NoError(t, c.Unmarshal(data, &out), c.ContentType())
+		assert.Equal(t, in, out, c.ContentType())
+	}
+
+	data, err := JSON.Marshal(in)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"name":"temperature","value":36.5,"tags":["a","b"]}`, string(data))
+
+	pin := &types.StringValue{Value: "hi"}
+	data, err = Protobuf.Marshal(pin)
+	assert.NoError(t, err)
+	pout := &types.StringValue{}
+	assert.NoError(t, Protobuf.Unmarshal(data, pout))
+	assert.Equal(t, pin.Value, pout.Value)
+	_, err = Protobuf.Marshal(in)
+	assert.Equal(t, ErrNotProtoMessage, err)
+	assert.Equal(t, ErrNotProtoMessage, Protobuf.Unmarshal(data, &in))
+}
+
+func TestRegistry(t *testing.T) {
+	c, err := Get("application/json; charset=utf-8")
+	assert.NoError(t, err)
+	assert.Equal(t, JSON, c)
+	c, err = Get("Application/CBOR")
+	assert.NoError(t, err)
+	assert.Equal(t, CBOR, c)
+	c, err = Get("application/msgpack")
+	assert.NoError(t, err)
+	assert.Equal(t, MsgPack, c)
+	_, err = Get("application/xml")
+	assert.EqualError(t, err, "codec of content type (application/xml) not found")
+	assert.Contains(t, ContentTypes(), ContentTypeProtobuf)
+
+	data, err := Marshal(ContentTypeMsgPack, map[string]int{"a": 1})
+	assert.NoError(t, err)
+	var out map[string]int
+	assert.NoError(t, Unmarshal(ContentTypeMsgPack, data, &out))
+	assert.Equal(t, map[string]int{"a": 1}, out)
+	_, err = Marshal("text/plain", "hi")
+	assert.Error(t, err)
+	assert.Error(t, Unmarshal("text/plain", nil, &out))
+
+	Register(&handleCodec{contentType: "application/x-test", handle: CBOR.(*handleCodec).handle})
+	c, err = Get("application/x-test")
+	assert.NoError(t, err)
+	assert.Equal(t, "application/x-test", c.ContentType())
+}
+
+func TestNegotiate(t *testing.T) {
+	assert.Equal(t, JSON, Negotiate(""))
+	assert.Equal(t, JSON, Negotiate("text/html, */*"))
+	assert.Equal(t, CBOR, Negotiate("application/cbor"))
+	assert.Equal(t, MsgPack, Negotiate("application/json;q=0.5, application/x-msgpack"))
+	assert.Equal(t, JSON, Negotiate("application/json;q=0.9, application/cbor;q=0.8"))
+	assert.Equal(t, Protobuf, Negotiate("text/xml, application/x-protobuf;q=0.1"))
+}
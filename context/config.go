@@ -3,7 +3,9 @@ package context
 import (
 	"github.com/baetyl/baetyl-go/link"
 	"github.com/baetyl/baetyl-go/log"
+	"github.com/baetyl/baetyl-go/metrics"
 	"github.com/baetyl/baetyl-go/mqtt"
+	"github.com/baetyl/baetyl-go/trace"
 )
 
 // ServiceConfig base config of service
@@ -11,4 +13,7 @@ type ServiceConfig struct {
 	Mqtt   mqtt.ClientConfig `yaml:"mqtt" json:"mqtt"`
 	Link   link.ClientConfig `yaml:"link" json:"link"`
 	Logger log.Config        `yaml:"logger" json:"logger"`
+	Trace  trace.Config      `yaml:"trace" json:"trace"`
+	// the metrics are exposed by the http server if configured, which is not restarted once the config is reloaded
+	Metrics *metrics.ServerConfig `yaml:"metrics" json:"metrics"`
 }
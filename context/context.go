@@ -1,13 +1,20 @@
 package context
 
 import (
+	"errors"
+	"io/ioutil"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
+	"time"
 
+	"github.com/baetyl/baetyl-go/http"
 	"github.com/baetyl/baetyl-go/link"
 	"github.com/baetyl/baetyl-go/log"
+	"github.com/baetyl/baetyl-go/metrics"
 	"github.com/baetyl/baetyl-go/mqtt"
+	"github.com/baetyl/baetyl-go/trace"
 	"github.com/baetyl/baetyl-go/utils"
 )
 
@@ -16,6 +23,8 @@ const (
 	EnvKeyNodeName    = "BAETYL_NODE_NAME"
 	EnvKeyAppName     = "BAETYL_APP_NAME"
 	EnvKeyServiceName = "BAETYL_SERVICE_NAME"
+	// EnvConfigPrefix the prefix of envs overriding the service config, such as BAETYL_MQTT_ADDRESS of mqtt.address
+	EnvConfigPrefix = "BAETYL"
 )
 
 const (
@@ -29,71 +38,157 @@ const (
 	DefaultBrokerLinkAddress = "ssl://baetyl-broker:8886"
 )
 
+// ErrContextClosed the error of the clients got after the context is closed
+var ErrContextClosed = errors.New("context is closed")
+
 // Context of service
 type Context interface {
+	// returns the name of node, app and service from the envs
+	NodeName() string
+	AppName() string
+	ServiceName() string
+	// returns the config of service loaded from the default config file, which is reloaded once the file is changed,
+	// the file is checked every 5 seconds
+	Config() ServiceConfig
+	// registers the handler called once the config file is changed and the new config is valid,
+	// the logger is initialized again by the new config before the handlers are called
+	OnConfigChange(ConfigChangeHandler)
+	// loads the custom config of service from the config file, the placeholders such as ${NAME} are expanded
+	// and the fields are overridden by the envs of the prefix BAETYL, the same as the config of service
+	LoadConfig(interface{}) error
 	// creates a MQTT Client that connects to the broker through system configuration
 	NewMQTTClient(string, mqtt.Observer, []mqtt.QOSTopic) (*mqtt.Client, error)
 	// creates a Link Client that connects to the broker through system configuration
 	NewLinkClient(link.Observer) (*link.Client, error)
+	// returns the MQTT Client shared by the service, which is created on the first call and closed with the context
+	MQTTClient() (*mqtt.Client, error)
+	// returns the Link Client shared by the service, which is created on the first call and closed with the context
+	LinkClient() (*link.Client, error)
 	// returns logger interface
 	Log() *log.Logger
-	// waiting to exit, receiving SIGTERM and SIGINT signals
+	// waiting to exit, receiving SIGTERM and SIGINT signals, or the context is closed,
+	// the signals are handled by the context since the first call of Wait or WaitChan
 	Wait()
-	// returns wait channel
+	// returns wait channel, which receives the signal of SIGTERM or SIGINT, or is closed once the context is closed
 	WaitChan() <-chan os.Signal
+	// closes the context and the clients shared
+	Close() error
 }
 
 type ctx struct {
-	nn  string
-	an  string
-	sn  string
-	cfg ServiceConfig
-	log *log.Logger
+	nn      string
+	an      string
+	sn      string
+	path    string
+	data    []byte
+	cfg     ServiceConfig
+	log     *log.Logger
+	fields  []log.Field
+	hs      []ConfigChangeHandler
+	cfgMu   sync.RWMutex
+	watcher utils.Tomb
+	sig     chan os.Signal
+	recv    os.Signal // the signal received
+	exit    chan struct{}
+	notify  sync.Once
+	once    sync.Once
+	mqtt    *mqtt.Client
+	link    *link.Client
+	msvr    *http.Server // the server exposing metrics, nil if not configured
+	dead    bool
+	mu      sync.Mutex
+}
+
+func newContext(path string) *ctx {
+	return newWatchedContext(path, configWatchInterval)
 }
 
-func newContext() *ctx {
+// newWatchedContext creates the context which checks the config file by the interval
+func newWatchedContext(path string, interval time.Duration) *ctx {
 	nn := os.Getenv(EnvKeyNodeName)
 	an := os.Getenv(EnvKeyAppName)
 	sn := os.Getenv(EnvKeyServiceName)
 	fs := []log.Field{log.Any("node", nn), log.Any("app", an), log.Any("service", sn)}
 	l := log.With(fs...)
 
-	var err error
-	var cfg ServiceConfig
-	if utils.FileExists(DefaultConfFile) {
-		err = utils.LoadYAML(DefaultConfFile, &cfg)
-	} else {
-		err = utils.UnmarshalYAML(nil, &cfg)
-	}
+	data, _ := ioutil.ReadFile(path)
+	cfg, err := loadServiceConfig(path)
 	if err != nil {
 		l.Error("failed to load config", log.Error(err))
 	}
-	l, err = log.Init(cfg.Logger, fs...)
-	if err != nil {
+	// the default logger is kept if failed to init, such as the level address is in use
+	if nl, err := log.Init(cfg.Logger, fs...); err != nil {
 		l.Error("failed to init logger", log.Error(err))
+	} else {
+		l = nl
 	}
-	if cfg.Mqtt.Address == "" {
-		cfg.Mqtt.Address = DefaultBrokerMqttAddress
+	_, err = trace.Init(cfg.Trace, sn)
+	if err != nil {
+		l.Error("failed to init trace", log.Error(err))
 	}
-	if cfg.Link.Address == "" {
-		cfg.Link.Address = DefaultBrokerLinkAddress
+	var msvr *http.Server
+	if cfg.Metrics != nil {
+		msvr, err = metrics.NewServer(*cfg.Metrics)
+		if err != nil {
+			l.Error("failed to start metrics server", log.Error(err))
+		}
 	}
 	c := &ctx{
-		nn:  nn,
-		an:  an,
-		sn:  sn,
-		cfg: cfg,
-		log: l,
+		nn:     nn,
+		an:     an,
+		sn:     sn,
+		path:   path,
+		data:   data,
+		cfg:    cfg,
+		log:    l,
+		fields: fs,
+		msvr:   msvr,
+		sig:    make(chan os.Signal, 1),
+		exit:   make(chan struct{}),
 	}
+	c.watcher.Go(c.watching(interval))
 	l.Info("context is created", log.Any("config", cfg))
 	return c
 }
 
+// handles the signals on demand, so that the service not waiting is terminated by the signals as default
+func (c *ctx) handleSignals() {
+	c.notify.Do(func() {
+		signal.Notify(c.sig, syscall.SIGTERM, syscall.SIGINT)
+		signal.Ignore(syscall.SIGPIPE)
+		go c.waiting()
+	})
+}
+
+// waiting closes the exit channel once the signal is received
+func (c *ctx) waiting() {
+	select {
+	case s := <-c.sig:
+		c.Log().Info("signal is received, service is exiting", log.Any("signal", s.String()))
+		c.mu.Lock()
+		c.recv = s
+		c.mu.Unlock()
+		c.shutdown()
+	case <-c.exit:
+	}
+}
+
+func (c *ctx) shutdown() {
+	c.once.Do(func() {
+		signal.Stop(c.sig)
+		close(c.exit)
+	})
+}
+
 func (c *ctx) NewMQTTClient(cid string, obs mqtt.Observer, topics []mqtt.QOSTopic) (*mqtt.Client, error) {
-	cc := c.cfg.Mqtt
+	cfg := c.Config()
+	cc := cfg.Mqtt
 	if cid != "" {
 		cc.ClientID = cid
 	}
+	if cfg.Trace.Propagation {
+		cc = trace.MQTTClientConfig(cc)
+	}
 	cli, err := mqtt.NewClient(cc, obs)
 	if err != nil {
 		return nil, err
@@ -112,7 +207,11 @@ func (c *ctx) NewMQTTClient(cid string, obs mqtt.Observer, topics []mqtt.QOSTopi
 }
 
 func (c *ctx) NewLinkClient(obs link.Observer) (*link.Client, error) {
-	cc := c.cfg.Link
+	cfg := c.Config()
+	cc := cfg.Link
+	if cfg.Trace.Propagation {
+		cc = trace.LinkClientConfig(cc)
+	}
 	cli, err := link.NewClient(cc, obs)
 	if err != nil {
 		return nil, err
@@ -120,8 +219,40 @@ func (c *ctx) NewLinkClient(obs link.Observer) (*link.Client, error) {
 	return cli, nil
 }
 
+func (c *ctx) MQTTClient() (*mqtt.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.dead {
+		return nil, ErrContextClosed
+	}
+	if c.mqtt == nil {
+		cli, err := c.NewMQTTClient("", nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		c.mqtt = cli
+	}
+	return c.mqtt, nil
+}
+
+func (c *ctx) LinkClient() (*link.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.dead {
+		return nil, ErrContextClosed
+	}
+	if c.link == nil {
+		cli, err := c.NewLinkClient(nil)
+		if err != nil {
+			return nil, err
+		}
+		c.link = cli
+	}
+	return c.link, nil
+}
+
 func (c *ctx) LoadConfig(cfg interface{}) error {
-	return utils.LoadYAML(DefaultConfFile, cfg)
+	return utils.LoadYAMLWithEnv(c.path, EnvConfigPrefix, cfg)
 }
 
 func (c *ctx) NodeName() string {
@@ -137,10 +268,14 @@ func (c *ctx) ServiceName() string {
 }
 
 func (c *ctx) Config() ServiceConfig {
+	c.cfgMu.RLock()
+	defer c.cfgMu.RUnlock()
 	return c.cfg
 }
 
 func (c *ctx) Log() *log.Logger {
+	c.cfgMu.RLock()
+	defer c.cfgMu.RUnlock()
 	return c.log
 }
 
@@ -149,8 +284,44 @@ func (c *ctx) Wait() {
 }
 
 func (c *ctx) WaitChan() <-chan os.Signal {
-	sig := make(chan os.Signal, 1)
-	signal.Notify(sig, syscall.SIGTERM, syscall.SIGINT)
-	signal.Ignore(syscall.SIGPIPE)
-	return sig
+	c.handleSignals()
+	ch := make(chan os.Signal, 1)
+	go func() {
+		<-c.exit
+		c.mu.Lock()
+		s := c.recv
+		c.mu.Unlock()
+		if s != nil {
+			ch <- s
+		}
+		close(ch)
+	}()
+	return ch
+}
+
+func (c *ctx) Close() error {
+	c.shutdown()
+	c.watcher.Kill(nil)
+	c.watcher.Wait()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.dead {
+		return nil
+	}
+	c.dead = true
+	var err error
+	if c.mqtt != nil {
+		err = c.mqtt.Close()
+	}
+	if c.link != nil {
+		if e := c.link.Close(); err == nil {
+			err = e
+		}
+	}
+	if c.msvr != nil {
+		if e := c.msvr.Close(); err == nil {
+			err = e
+		}
+	}
+	return err
 }
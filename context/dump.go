@@ -0,0 +1,118 @@
+package context
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+
+	"github.com/baetyl/baetyl-go/utils"
+	"gopkg.in/yaml.v2"
+)
+
+// MaskedValue the value of secrets in the config dumped
+const MaskedValue = "******"
+
+// the suffixes of keys (in lower case) whose values are secrets, such as password, jwt.secret and apiKey
+var secretSuffixes = []string{"password", "secret", "token", "apikey"}
+
+// DumpEffectiveConfig returns the effective config of service in yaml, which is loaded from the default config file
+// as the context does, with the envs rendered and the defaults set, and the secrets are masked
+func DumpEffectiveConfig() ([]byte, error) {
+	return dumpConfig(DefaultConfFile)
+}
+
+// ValidateConfigFile validates the config file of service, returns the error of loading,
+// such as the env missing, or the errors of all fields invalid
+func ValidateConfigFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	data, err = utils.ParseEnv(data)
+	if err != nil {
+		return fmt.Errorf("failed to render envs: %s", err.Error())
+	}
+	var cfg ServiceConfig
+	err = utils.UnmarshalYAMLWithEnv(data, EnvConfigPrefix, &cfg)
+	if err != nil {
+		return fmt.Errorf("config is invalid: %s", err.Error())
+	}
+	return nil
+}
+
+// loadServiceConfig loads the config of service, the default config is used if the file does not exist,
+// the placeholders such as ${NAME} are expanded and the fields are overridden by the envs of the prefix BAETYL
+func loadServiceConfig(path string) (ServiceConfig, error) {
+	var err error
+	var cfg ServiceConfig
+	if utils.FileExists(path) {
+		err = utils.LoadYAMLWithEnv(path, EnvConfigPrefix, &cfg)
+	} else {
+		err = utils.UnmarshalYAMLWithEnv(nil, EnvConfigPrefix, &cfg)
+	}
+	if cfg.Mqtt.Address == "" {
+		cfg.Mqtt.Address = DefaultBrokerMqttAddress
+	}
+	if cfg.Link.Address == "" {
+		cfg.Link.Address = DefaultBrokerLinkAddress
+	}
+	return cfg, err
+}
+
+func dumpConfig(path string) ([]byte, error) {
+	cfg, err := loadServiceConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	// the map slice keeps the order of fields
+	var doc yaml.MapSlice
+	err = yaml.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(maskSecrets(doc))
+}
+
+// maskSecrets masks the values of secret keys, and the passwords in urls
+func maskSecrets(v interface{}) interface{} {
+	switch t := v.(type) {
+	case yaml.MapSlice:
+		for i, item := range t {
+			if s, ok := item.Value.(string); ok && s != "" && isSecret(fmt.Sprint(item.Key)) {
+				t[i].Value = MaskedValue
+				continue
+			}
+			t[i].Value = maskSecrets(item.Value)
+		}
+		return t
+	case []interface{}:
+		for i, item := range t {
+			t[i] = maskSecrets(item)
+		}
+		return t
+	case string:
+		if u, err := url.Parse(t); err == nil && u.User != nil {
+			if _, ok := u.User.Password(); ok {
+				return strings.Replace(t, u.User.String()+"@", url.User(u.User.Username()).String()+":"+MaskedValue+"@", 1)
+			}
+		}
+		return t
+	default:
+		return v
+	}
+}
+
+func isSecret(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range secretSuffixes {
+		if strings.HasSuffix(key, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -7,19 +7,21 @@ import (
 	"github.com/baetyl/baetyl-go/log"
 )
 
-// Run service
+// Run service, the context is closed after the handle returns, and the wait channel of the context
+// is closed on SIGTERM and SIGINT signals to shut down the service gracefully
 func Run(handle func(Context) error) {
-	c := newContext()
+	c := newContext(DefaultConfFile)
+	defer c.Close()
 	defer func() {
 		if r := recover(); r != nil {
-			c.log.Error("service is stopped with panic", log.Any("panic", debug.Stack()))
+			c.Log().Error("service is stopped with panic", log.Any("panic", debug.Stack()))
 		}
 	}()
-	c.log.Info("service starting", log.Any("args", os.Args))
+	c.Log().Info("service starting", log.Any("args", os.Args))
 	err := handle(c)
 	if err != nil {
-		c.log.Error("service has stopped with error", log.Error(err))
+		c.Log().Error("service has stopped with error", log.Error(err))
 	} else {
-		c.log.Info("service has stopped")
+		c.Log().Info("service has stopped")
 	}
 }
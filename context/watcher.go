@@ -0,0 +1,80 @@
+package context
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"time"
+
+	"github.com/baetyl/baetyl-go/log"
+)
+
+// the interval to check whether the config file is changed, the file is polled instead of notified by the file system,
+// since the config file mounted from configmap is replaced by a symlink which the notifications are not reliable for,
+// so the change is applied with the latency up to the interval
+const configWatchInterval = 5 * time.Second
+
+// ConfigChangeHandler handles the config of service changed, such as reconnecting to the new mqtt address
+type ConfigChangeHandler func(prev, cfg ServiceConfig)
+
+func (c *ctx) OnConfigChange(h ConfigChangeHandler) {
+	c.cfgMu.Lock()
+	c.hs = append(c.hs, h)
+	c.cfgMu.Unlock()
+}
+
+// watching checks the config file by the interval until the context is closed
+func (c *ctx) watching(interval time.Duration) func() error {
+	return func() error {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.reload()
+			case <-c.exit:
+				return nil
+			case <-c.watcher.Dying():
+				return nil
+			}
+		}
+	}
+}
+
+// reload loads the config file if changed, the config invalid is ignored and the current one is kept
+func (c *ctx) reload() {
+	data, err := ioutil.ReadFile(c.path)
+	if err != nil || bytes.Equal(data, c.data) {
+		return
+	}
+	c.data = data
+	cfg, err := loadServiceConfig(c.path)
+	if err != nil {
+		c.Log().Error("failed to reload config, the current one is kept", log.Error(err))
+		return
+	}
+
+	c.cfgMu.Lock()
+	prev := c.cfg
+	if reflect.DeepEqual(prev, cfg) {
+		c.cfgMu.Unlock()
+		return
+	}
+	c.cfg = cfg
+	if !reflect.DeepEqual(prev.Logger, cfg.Logger) {
+		l, err := log.Init(cfg.Logger, c.fields...)
+		if err != nil {
+			c.log.Error("failed to init logger by the config reloaded", log.Error(err))
+		} else {
+			c.log = l
+		}
+	}
+	l := c.log
+	hs := append([]ConfigChangeHandler{}, c.hs...)
+	c.cfgMu.Unlock()
+
+	l.Info("config is reloaded", log.Any("config", cfg))
+	for _, h := range hs {
+		h(prev, cfg)
+	}
+}
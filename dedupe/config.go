@@ -0,0 +1,9 @@
+package dedupe
+
+import "time"
+
+// Config the config of dedupe store
+type Config struct {
+	Prefix string        `yaml:"prefix" json:"prefix" default:"dedupe/"` // the key prefix in kv store
+	Window time.Duration `yaml:"window" json:"window" default:"10m"`     // how long the message handled is remembered
+}
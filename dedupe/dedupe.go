@@ -0,0 +1,116 @@
+// Package dedupe detects the messages redelivered, the message id and the hash of content handled are persisted
+// in the kv store within a time window, so that the messages are handled exactly once across restarts.
+package dedupe
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"net/url"
+	"sync"
+
+	"github.com/baetyl/baetyl-go/kv"
+)
+
+// Deduper the dedupe store backed by kv, the message is identified by the scope (such as the client id or the channel),
+// the id and the hash of its content, so the id reused with another content isn't taken as a duplicate
+type Deduper struct {
+	cfg   Config
+	kv    kv.Driver
+	locks map[string]*lock // the locks of the messages being handled, keyed by the key of message
+	mu    sync.Mutex
+}
+
+// lock the lock of a message, which is removed once no one holds or waits for it
+type lock struct {
+	sync.Mutex
+	refs int
+}
+
+// NewDeduper creates a new dedupe store, the kv store can be shared with others by distinct prefixes
+func NewDeduper(cfg Config, d kv.Driver) *Deduper {
+	return &Deduper{cfg: cfg, kv: d, locks: map[string]*lock{}}
+}
+
+// key escapes the scope, so that the scope containing '/' doesn't take the messages of another scope as its own
+func (d *Deduper) key(scope, id string) string {
+	return d.cfg.Prefix + url.PathEscape(scope) + "/" + id
+}
+
+func (d *Deduper) lock(key string) {
+	d.mu.Lock()
+	l, ok := d.locks[key]
+	if !ok {
+		l = &lock{}
+		d.locks[key] = l
+	}
+	l.refs++
+	d.mu.Unlock()
+	l.Lock()
+}
+
+func (d *Deduper) unlock(key string) {
+	d.mu.Lock()
+	l := d.locks[key]
+	l.refs--
+	if l.refs == 0 {
+		delete(d.locks, key)
+	}
+	d.mu.Unlock()
+	l.Unlock()
+}
+
+// Seen checks whether the message was handled within the window
+func (d *Deduper) Seen(scope, id string, content []byte) (bool, error) {
+	sum := sha256.Sum256(content)
+	v, err := d.kv.Get(d.key(scope, id))
+	if err == kv.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(v, sum[:]), nil
+}
+
+// Mark records the message as handled, which is remembered within the window
+func (d *Deduper) Mark(scope, id string, content []byte) error {
+	sum := sha256.Sum256(content)
+	return d.kv.SetWithTTL(d.key(scope, id), sum[:], d.cfg.Window)
+}
+
+// Handle calls the handler if the message was not handled within the window, and marks it once the handler succeeds,
+// so that the message is handled again if the handler fails. Returns true if the message is a duplicate.
+func (d *Deduper) Handle(scope, id string, content []byte, handler func() error) (bool, error) {
+	// the messages of the same scope and id are handled one by one, in case the duplicate arrives before the original
+	// is marked, while the messages of others are handled concurrently
+	key := d.key(scope, id)
+	d.lock(key)
+	defer d.unlock(key)
+	seen, err := d.Seen(scope, id, content)
+	if err != nil || seen {
+		return seen, err
+	}
+	if err = handler(); err != nil {
+		return false, err
+	}
+	return false, d.Mark(scope, id, content)
+}
+
+// Forget removes the message, such as the id released by the sender and about to be reused
+func (d *Deduper) Forget(scope, id string) error {
+	return d.kv.Del(d.key(scope, id))
+}
+
+// Clear removes all messages of the scope, such as the clean session of mqtt client
+func (d *Deduper) Clear(scope string) error {
+	kvs, err := d.kv.List(d.key(scope, ""))
+	if err != nil {
+		return err
+	}
+	for _, v := range kvs {
+		if err = d.kv.Del(v.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
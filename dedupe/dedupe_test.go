@@ -0,0 +1,153 @@
+package dedupe
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/baetyl/baetyl-go/kv"
+	"github.com/creasty/defaults"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeduper(t *testing.T) {
+	dir, err := ioutil.TempDir("", t.Name())
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	var kc kv.Config
+	assert.NoError(t, defaults.Set(&kc))
+	kc.Path = filepath.Join(dir, "kv.db")
+	var cfg Config
+	assert.NoError(t, defaults.Set(&cfg))
+	assert.Equal(t, "dedupe/", cfg.Prefix)
+	assert.Equal(t, 10*time.Minute, cfg.Window)
+
+	store, err := kv.New(kc)
+	assert.NoError(t, err)
+	d := NewDeduper(cfg, store)
+
+	count := 0
+	handler := func() error {
+		count++
+		return nil
+	}
+	dup, err := d.Handle("c1", "1", []byte("a"), handler)
+	assert.NoError(t, err)
+	assert.False(t, dup)
+	dup, err = d.Handle("c1", "1", []byte("a"), handler)
+	assert.NoError(t, err)
+	assert.True(t, dup)
+	assert.Equal(t, 1, count)
+
+	// the id reused with another content or in another scope is not a duplicate
+	dup, err = d.Handle("c1", "1", []byte("b"), handler)
+	assert.NoError(t, err)
+	assert.False(t, dup)
+	dup, err = d.Handle("c2", "1", []byte("b"), handler)
+	assert.NoError(t, err)
+	assert.False(t, dup)
+	assert.Equal(t, 3, count)
+
+	// the message is not marked if the handler fails
+	errHandle := errors.New("handle error")
+	dup, err = d.Handle("c1", "2", []byte("a"), func() error { return errHandle })
+	assert.Equal(t, errHandle, err)
+	assert.False(t, dup)
+	seen, err := d.Seen("c1", "2", []byte("a"))
+	assert.NoError(t, err)
+	assert.False(t, seen)
+
+	// the messages handled are remembered across restarts
+	assert.NoError(t, store.Close())
+	store, err = kv.New(kc)
+	assert.NoError(t, err)
+	defer store.Close()
+	d = NewDeduper(cfg, store)
+	seen, err = d.Seen("c1", "1", []byte("b"))
+	assert.NoError(t, err)
+	assert.True(t, seen)
+
+	assert.NoError(t, d.Forget("c1", "1"))
+	seen, err = d.Seen("c1", "1", []byte("b"))
+	assert.NoError(t, err)
+	assert.False(t, seen)
+
+	// the scope containing '/' is not cleared with its parent
+	assert.NoError(t, d.Mark("c1", "3", nil))
+	assert.NoError(t, d.Mark("c1/a", "3", nil))
+	assert.NoError(t, d.Clear("c1"))
+	seen, err = d.Seen("c1", "3", nil)
+	assert.NoError(t, err)
+	assert.False(t, seen)
+	seen, err = d.Seen("c1/a", "3", nil)
+	assert.NoError(t, err)
+	assert.True(t, seen)
+	seen, err = d.Seen("c2", "1", []byte("b"))
+	assert.NoError(t, err)
+	assert.True(t, seen)
+
+	// the message is forgotten after the window
+	d = NewDeduper(Config{Prefix: "x/", Window: 100 * time.Millisecond}, store)
+	assert.NoError(t, d.Mark("c1", "1", nil))
+	seen, err = d.Seen("c1", "1", nil)
+	assert.NoError(t, err)
+	assert.True(t, seen)
+	time.Sleep(200 * time.Millisecond)
+	seen, err = d.Seen("c1", "1", nil)
+	assert.NoError(t, err)
+	assert.False(t, seen)
+}
+
+func TestDeduperHandleConcurrently(t *testing.T) {
+	dir, err := ioutil.TempDir("", t.Name())
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	var kc kv.Config
+	assert.NoError(t, defaults.Set(&kc))
+	kc.Path = filepath.Join(dir, "kv.db")
+	var cfg Config
+	assert.NoError(t, defaults.Set(&cfg))
+	store, err := kv.New(kc)
+	assert.NoError(t, err)
+	defer store.Close()
+	d := NewDeduper(cfg, store)
+
+	// the message of another id is handled while the handler of c1/1 blocks
+	blocked := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		dup, err := d.Handle("c1", "1", nil, func() error {
+			close(blocked)
+			<-release
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.False(t, dup)
+	}()
+	<-blocked
+	dup, err := d.Handle("c1", "2", nil, func() error { return nil })
+	assert.NoError(t, err)
+	assert.False(t, dup)
+
+	// the duplicate waits for the original
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		dup, err := d.Handle("c1", "1", nil, func() error { return nil })
+		assert.NoError(t, err)
+		assert.True(t, dup)
+	}()
+	close(release)
+	<-done
+	wg.Wait()
+	d.mu.Lock()
+	assert.Len(t, d.locks, 0)
+	d.mu.Unlock()
+}
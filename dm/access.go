@@ -0,0 +1,230 @@
+package dm
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/baetyl/baetyl-go/spec/v1"
+	"github.com/baetyl/baetyl-go/utils"
+)
+
+// all modbus modes
+const (
+	ModbusTCP = "tcp"
+	ModbusRTU = "rtu"
+)
+
+// all modbus function codes to read
+const (
+	ModbusCoil            byte = 1
+	ModbusDiscreteInput   byte = 2
+	ModbusHoldingRegister byte = 3
+	ModbusInputRegister   byte = 4
+)
+
+// all opcua security policies
+const (
+	OpcuaPolicyNone           = "None"
+	OpcuaPolicyBasic128Rsa15  = "Basic128Rsa15"
+	OpcuaPolicyBasic256       = "Basic256"
+	OpcuaPolicyBasic256Sha256 = "Basic256Sha256"
+)
+
+// all opcua security modes
+const (
+	OpcuaModeNone           = "None"
+	OpcuaModeSign           = "Sign"
+	OpcuaModeSignAndEncrypt = "SignAndEncrypt"
+)
+
+// ModbusConfig the config of modbus access point
+type ModbusConfig struct {
+	Slaves []ModbusSlave `yaml:"slaves" json:"slaves"`
+}
+
+// ModbusSlave the config of modbus slave, the address is host:port in tcp mode or the serial port in rtu mode
+type ModbusSlave struct {
+	Device   string        `yaml:"device" json:"device"`
+	ID       byte          `yaml:"id" json:"id" default:"1"`
+	Mode     string        `yaml:"mode" json:"mode" default:"tcp"`
+	Address  string        `yaml:"address" json:"address"`
+	Timeout  time.Duration `yaml:"timeout" json:"timeout" default:"10s"`
+	Interval time.Duration `yaml:"interval" json:"interval" default:"5s"`
+	BaudRate int           `yaml:"baudRate" json:"baudRate" default:"19200"`
+	DataBits int           `yaml:"dataBits" json:"dataBits" default:"8"`
+	StopBits int           `yaml:"stopBits" json:"stopBits" default:"1"`
+	Parity   string        `yaml:"parity" json:"parity" default:"E"`
+	Points   []ModbusPoint `yaml:"points" json:"points"`
+}
+
+// ModbusPoint the config of modbus point which is mapped to a device property
+type ModbusPoint struct {
+	Name     string  `yaml:"name" json:"name"`
+	Function byte    `yaml:"function" json:"function" default:"3"`
+	Address  uint16  `yaml:"address" json:"address"`
+	Quantity uint16  `yaml:"quantity" json:"quantity" default:"1"`
+	Type     string  `yaml:"type" json:"type" default:"int"`
+	Scale    float64 `yaml:"scale" json:"scale" default:"1"`
+}
+
+// OpcuaConfig the config of opcua access point
+type OpcuaConfig struct {
+	Endpoints []OpcuaEndpoint `yaml:"endpoints" json:"endpoints"`
+}
+
+// OpcuaEndpoint the config of opcua endpoint, such as opc.tcp://127.0.0.1:4840
+type OpcuaEndpoint struct {
+	Device      string            `yaml:"device" json:"device"`
+	Endpoint    string            `yaml:"endpoint" json:"endpoint"`
+	Policy      string            `yaml:"policy" json:"policy" default:"None"`
+	Mode        string            `yaml:"mode" json:"mode" default:"None"`
+	Username    string            `yaml:"username" json:"username"`
+	Password    string            `yaml:"password" json:"password"`
+	Certificate utils.Certificate `yaml:",inline" json:",inline"`
+	Timeout     time.Duration     `yaml:"timeout" json:"timeout" default:"10s"`
+	Interval    time.Duration     `yaml:"interval" json:"interval" default:"5s"`
+	Nodes       []OpcuaNode       `yaml:"nodes" json:"nodes"`
+}
+
+// OpcuaNode the config of opcua node which is mapped to a device property, the id is like ns=2;s=Temperature
+type OpcuaNode struct {
+	Name string `yaml:"name" json:"name"`
+	ID   string `yaml:"id" json:"id"`
+	Type string `yaml:"type" json:"type" default:"float"`
+}
+
+// Validate validates the modbus config
+func (c *ModbusConfig) Validate() error {
+	var es v1.FieldErrors
+	for i := range c.Slaves {
+		c.Slaves[i].validate(&es, fmt.Sprintf("slaves[%d]", i))
+	}
+	return fieldErrors(es)
+}
+
+func (s *ModbusSlave) validate(es *v1.FieldErrors, f string) {
+	if s.Device == "" {
+		addFieldError(es, f+".device", "device is required")
+	}
+	switch s.Mode {
+	case ModbusTCP:
+		if _, _, err := net.SplitHostPort(s.Address); err != nil {
+			addFieldError(es, f+".address", "address (%s) must be host:port", s.Address)
+		}
+	case ModbusRTU:
+		if s.Address == "" {
+			addFieldError(es, f+".address", "address is required")
+		}
+		if s.BaudRate <= 0 {
+			addFieldError(es, f+".baudRate", "baud rate (%d) must be positive", s.BaudRate)
+		}
+		if s.DataBits < 5 || s.DataBits > 8 {
+			addFieldError(es, f+".dataBits", "data bits (%d) must be in range [5, 8]", s.DataBits)
+		}
+		if s.StopBits != 1 && s.StopBits != 2 {
+			addFieldError(es, f+".stopBits", "stop bits (%d) must be 1 or 2", s.StopBits)
+		}
+		switch s.Parity {
+		case "N", "E", "O":
+		default:
+			addFieldError(es, f+".parity", "parity (%s) must be N, E or O", s.Parity)
+		}
+	default:
+		addFieldError(es, f+".mode", "mode (%s) is not supported", s.Mode)
+	}
+	if s.ID < 1 || s.ID > 247 {
+		addFieldError(es, f+".id", "id (%d) must be in range [1, 247]", s.ID)
+	}
+	if s.Interval <= 0 {
+		addFieldError(es, f+".interval", "interval (%s) must be positive", s.Interval)
+	}
+	names := map[string]struct{}{}
+	for i, p := range s.Points {
+		pf := fmt.Sprintf("%s.points[%d]", f, i)
+		if p.Name == "" {
+			addFieldError(es, pf+".name", "name is required")
+		} else if _, ok := names[p.Name]; ok {
+			addFieldError(es, pf+".name", "name (%s) is duplicated", p.Name)
+		}
+		names[p.Name] = struct{}{}
+		if p.Function < ModbusCoil || p.Function > ModbusInputRegister {
+			addFieldError(es, pf+".function", "function (%d) must be in range [1, 4]", p.Function)
+		}
+		if p.Quantity == 0 {
+			addFieldError(es, pf+".quantity", "quantity must be positive")
+		}
+		validateType(es, pf+".type", p.Type)
+	}
+}
+
+// Validate validates the opcua config
+func (c *OpcuaConfig) Validate() error {
+	var es v1.FieldErrors
+	for i := range c.Endpoints {
+		c.Endpoints[i].validate(&es, fmt.Sprintf("endpoints[%d]", i))
+	}
+	return fieldErrors(es)
+}
+
+func (e *OpcuaEndpoint) validate(es *v1.FieldErrors, f string) {
+	if e.Device == "" {
+		addFieldError(es, f+".device", "device is required")
+	}
+	if u, err := url.Parse(e.Endpoint); err != nil || u.Scheme != "opc.tcp" || u.Host == "" {
+		addFieldError(es, f+".endpoint", "endpoint (%s) must be like opc.tcp://host:port", e.Endpoint)
+	}
+	switch e.Policy {
+	case OpcuaPolicyNone, OpcuaPolicyBasic128Rsa15, OpcuaPolicyBasic256, OpcuaPolicyBasic256Sha256:
+	default:
+		addFieldError(es, f+".policy", "policy (%s) is not supported", e.Policy)
+	}
+	switch e.Mode {
+	case OpcuaModeNone, OpcuaModeSign, OpcuaModeSignAndEncrypt:
+	default:
+		addFieldError(es, f+".mode", "mode (%s) is not supported", e.Mode)
+	}
+	if (e.Policy == OpcuaPolicyNone) != (e.Mode == OpcuaModeNone) {
+		addFieldError(es, f+".mode", "mode (%s) does not match policy (%s)", e.Mode, e.Policy)
+	}
+	if e.Policy != OpcuaPolicyNone && (e.Certificate.Cert == "" || e.Certificate.Key == "") {
+		addFieldError(es, f+".cert", "cert and key are required if policy is not None")
+	}
+	if e.Interval <= 0 {
+		addFieldError(es, f+".interval", "interval (%s) must be positive", e.Interval)
+	}
+	names := map[string]struct{}{}
+	for i, n := range e.Nodes {
+		nf := fmt.Sprintf("%s.nodes[%d]", f, i)
+		if n.Name == "" {
+			addFieldError(es, nf+".name", "name is required")
+		} else if _, ok := names[n.Name]; ok {
+			addFieldError(es, nf+".name", "name (%s) is duplicated", n.Name)
+		}
+		names[n.Name] = struct{}{}
+		if n.ID == "" {
+			addFieldError(es, nf+".id", "id is required")
+		}
+		validateType(es, nf+".type", n.Type)
+	}
+}
+
+func validateType(es *v1.FieldErrors, f, t string) {
+	switch t {
+	case TypeInt, TypeFloat, TypeBool, TypeString:
+	default:
+		addFieldError(es, f, "type (%s) is not supported", t)
+	}
+}
+
+func addFieldError(es *v1.FieldErrors, field, format string, args ...interface{}) {
+	*es = append(*es, &v1.FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func fieldErrors(es v1.FieldErrors) error {
+	if len(es) == 0 {
+		return nil
+	}
+	return es
+}
@@ -0,0 +1,112 @@
+package dm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModbusConfig(t *testing.T) {
+	in := `
+slaves:
+- device: d1
+  address: 127.0.0.1:502
+  points:
+  - name: temp
+    address: 40001
+    type: float
+    scale: 0.1
+  - name: switch
+    function: 1
+    type: bool
+- device: d2
+  id: 2
+  mode: rtu
+  address: /dev/ttyUSB0
+  baudRate: 9600
+  parity: N
+`
+	var cfg ModbusConfig
+	err := utils.UnmarshalYAML([]byte(in), &cfg)
+	assert.NoError(t, err)
+	assert.Len(t, cfg.Slaves, 2)
+	s := cfg.Slaves[0]
+	assert.Equal(t, byte(1), s.ID)
+	assert.Equal(t, ModbusTCP, s.Mode)
+	assert.Equal(t, 10*time.Second, s.Timeout)
+	assert.Equal(t, 5*time.Second, s.Interval)
+	assert.Equal(t, ModbusPoint{Name: "temp", Function: ModbusHoldingRegister, Address: 40001, Quantity: 1, Type: TypeFloat, Scale: 0.1}, s.Points[0])
+	assert.Equal(t, ModbusPoint{Name: "switch", Function: ModbusCoil, Quantity: 1, Type: TypeBool, Scale: 1}, s.Points[1])
+	s = cfg.Slaves[1]
+	assert.Equal(t, ModbusRTU, s.Mode)
+	assert.Equal(t, 9600, s.BaudRate)
+	assert.Equal(t, 8, s.DataBits)
+	assert.Equal(t, 1, s.StopBits)
+	assert.Equal(t, "N", s.Parity)
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Slaves[0].Address = "127.0.0.1"
+	cfg.Slaves[0].Points[1].Name = "temp"
+	cfg.Slaves[0].Points[1].Function = 5
+	cfg.Slaves[0].Points[1].Type = TypeObject
+	cfg.Slaves[1].ID = 0
+	cfg.Slaves[1].StopBits = 3
+	cfg.Slaves[1].Parity = "X"
+	err = cfg.Validate()
+	assert.EqualError(t, err, "slaves[0].address: address (127.0.0.1) must be host:port; "+
+		"slaves[0].points[1].name: name (temp) is duplicated; "+
+		"slaves[0].points[1].function: function (5) must be in range [1, 4]; "+
+		"slaves[0].points[1].type: type (object) is not supported; "+
+		"slaves[1].stopBits: stop bits (3) must be 1 or 2; "+
+		"slaves[1].parity: parity (X) must be N, E or O; "+
+		"slaves[1].id: id (0) must be in range [1, 247]")
+
+	cfg = ModbusConfig{Slaves: []ModbusSlave{{Mode: "udp"}}}
+	err = cfg.Validate()
+	assert.EqualError(t, err, "slaves[0].device: device is required; "+
+		"slaves[0].mode: mode (udp) is not supported; "+
+		"slaves[0].id: id (0) must be in range [1, 247]; "+
+		"slaves[0].interval: interval (0s) must be positive")
+}
+
+func TestOpcuaConfig(t *testing.T) {
+	in := `
+endpoints:
+- device: d1
+  endpoint: opc.tcp://127.0.0.1:4840
+  nodes:
+  - name: temp
+    id: ns=2;s=Temperature
+  - name: switch
+    id: ns=2;i=1001
+    type: bool
+`
+	var cfg OpcuaConfig
+	err := utils.UnmarshalYAML([]byte(in), &cfg)
+	assert.NoError(t, err)
+	assert.Len(t, cfg.Endpoints, 1)
+	e := cfg.Endpoints[0]
+	assert.Equal(t, OpcuaPolicyNone, e.Policy)
+	assert.Equal(t, OpcuaModeNone, e.Mode)
+	assert.Equal(t, 10*time.Second, e.Timeout)
+	assert.Equal(t, []OpcuaNode{{Name: "temp", ID: "ns=2;s=Temperature", Type: TypeFloat}, {Name: "switch", ID: "ns=2;i=1001", Type: TypeBool}}, e.Nodes)
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Endpoints[0].Endpoint = "http://127.0.0.1:4840"
+	cfg.Endpoints[0].Policy = OpcuaPolicyBasic256Sha256
+	cfg.Endpoints[0].Nodes[1].ID = ""
+	err = cfg.Validate()
+	assert.EqualError(t, err, "endpoints[0].endpoint: endpoint (http://127.0.0.1:4840) must be like opc.tcp://host:port; "+
+		"endpoints[0].mode: mode (None) does not match policy (Basic256Sha256); "+
+		"endpoints[0].cert: cert and key are required if policy is not None; "+
+		"endpoints[0].nodes[1].id: id is required")
+
+	cfg.Endpoints[0].Endpoint = "opc.tcp://127.0.0.1:4840"
+	cfg.Endpoints[0].Mode = OpcuaModeSignAndEncrypt
+	cfg.Endpoints[0].Certificate.Cert = "cert.pem"
+	cfg.Endpoints[0].Certificate.Key = "key.pem"
+	cfg.Endpoints[0].Nodes[1].ID = "ns=2;i=1001"
+	assert.NoError(t, cfg.Validate())
+}
@@ -0,0 +1,94 @@
+// Package dm provides the device management models (device, model, twin) and the helpers for southbound drivers
+package dm
+
+import "time"
+
+// all property types
+const (
+	TypeInt    = "int"
+	TypeFloat  = "float"
+	TypeBool   = "bool"
+	TypeString = "string"
+	TypeTime   = "time"
+	TypeArray  = "array"
+	TypeObject = "object"
+)
+
+// all property modes
+const (
+	ModeReadOnly  = "ro"
+	ModeReadWrite = "rw"
+)
+
+// Device the device managed by edge node
+type Device struct {
+	Name              string            `json:"name,omitempty" yaml:"name,omitempty"`
+	Namespace         string            `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Version           string            `json:"version,omitempty" yaml:"version,omitempty"`
+	Labels            map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Attributes        map[string]string `json:"attributes,omitempty" yaml:"attributes,omitempty"`
+	DeviceModel       string            `json:"deviceModel,omitempty" yaml:"deviceModel,omitempty"`
+	Description       string            `json:"description,omitempty" yaml:"description,omitempty"`
+	CreationTimestamp time.Time         `json:"createTime,omitempty" yaml:"createTime,omitempty"`
+	Twin              Twin              `json:"twin,omitempty" yaml:"twin,omitempty"`
+}
+
+// DeviceModel the model of devices, which defines the properties and events
+type DeviceModel struct {
+	Name        string           `json:"name,omitempty" yaml:"name,omitempty"`
+	Version     string           `json:"version,omitempty" yaml:"version,omitempty"`
+	Description string           `json:"description,omitempty" yaml:"description,omitempty"`
+	Properties  []DeviceProperty `json:"properties,omitempty" yaml:"properties,omitempty"`
+	Events      []DeviceEvent    `json:"events,omitempty" yaml:"events,omitempty"`
+}
+
+// DeviceProperty the property of device model
+type DeviceProperty struct {
+	Name        string `json:"name,omitempty" yaml:"name,omitempty"`
+	ID          string `json:"id,omitempty" yaml:"id,omitempty"`
+	Type        string `json:"type,omitempty" yaml:"type,omitempty"`
+	Mode        string `json:"mode,omitempty" yaml:"mode,omitempty" default:"ro"`
+	Unit        string `json:"unit,omitempty" yaml:"unit,omitempty"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// DeviceEvent the event of device model
+type DeviceEvent struct {
+	Name        string `json:"name,omitempty" yaml:"name,omitempty"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// Property returns the property by name
+func (m *DeviceModel) Property(name string) (*DeviceProperty, bool) {
+	for i := range m.Properties {
+		if m.Properties[i].Name == name {
+			return &m.Properties[i], true
+		}
+	}
+	return nil, false
+}
+
+// DeepCopy creates a deep copy of device
+func (in *Device) DeepCopy() *Device {
+	if in == nil {
+		return nil
+	}
+	out := new(Device)
+	*out = *in
+	out.Labels = copyStringMap(in.Labels)
+	out.Attributes = copyStringMap(in.Attributes)
+	out.Twin.Reported = in.Twin.Reported.DeepCopy()
+	out.Twin.Desired = in.Twin.Desired.DeepCopy()
+	return out
+}
+
+func copyStringMap(in map[string]string) map[string]string {
+	if in == nil {
+		return nil
+	}
+	out := make(map[string]string, len(in))
+	for k, v := range in {
+		out[k] = v
+	}
+	return out
+}
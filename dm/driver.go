@@ -0,0 +1,183 @@
+package dm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/baetyl/baetyl-go/log"
+	"github.com/baetyl/baetyl-go/mqtt"
+)
+
+// all device topics, %s is the device name
+const (
+	TopicReport      = "$baetyl/device/%s/report"
+	TopicDelta       = "$baetyl/device/%s/delta"
+	TopicEvent       = "$baetyl/device/%s/event"
+	TopicGet         = "$baetyl/device/%s/get"
+	TopicGetResponse = "$baetyl/device/%s/getResponse"
+)
+
+// all event types
+const (
+	EventPropertyGet = "propertyGet"
+)
+
+// Event the event sent to device
+type Event struct {
+	Type    string      `json:"type,omitempty"`
+	Payload interface{} `json:"payload,omitempty"`
+}
+
+// DeltaCallback the callback to handle the delta of desired properties
+type DeltaCallback func(device string, delta Props) error
+
+// EventCallback the callback to handle the event
+type EventCallback func(device string, event *Event) error
+
+// ResponseCallback the callback to handle the response of get
+type ResponseCallback func(device string, twin *Twin) error
+
+type callbacks struct {
+	delta    DeltaCallback
+	event    EventCallback
+	response ResponseCallback
+}
+
+// Driver the helper of device driver, which handles the device topics over mqtt
+type Driver struct {
+	cli *mqtt.Client
+	cbs map[string]*callbacks
+	mu  sync.RWMutex
+	log *log.Logger
+}
+
+// NewDriver creates a new driver, which connects to the broker and subscribes the device topics
+func NewDriver(cc mqtt.ClientConfig) (*Driver, error) {
+	d := &Driver{
+		cbs: map[string]*callbacks{},
+		log: log.With(log.Any("dm", "driver")),
+	}
+	cli, err := mqtt.NewClient(cc, mqtt.NewObserverWrapper(d.onPublish, nil, d.onError))
+	if err != nil {
+		return nil, err
+	}
+	err = cli.Subscribe([]mqtt.Subscription{
+		{Topic: fmt.Sprintf(TopicDelta, "+"), QOS: 1},
+		{Topic: fmt.Sprintf(TopicEvent, "+"), QOS: 1},
+		{Topic: fmt.Sprintf(TopicGetResponse, "+"), QOS: 1},
+	})
+	if err != nil {
+		cli.Close()
+		return nil, err
+	}
+	d.cli = cli
+	return d, nil
+}
+
+// RegisterDeltaCallback registers the callback to handle the delta of the device
+func (d *Driver) RegisterDeltaCallback(device string, cb DeltaCallback) {
+	d.update(device, func(c *callbacks) { c.delta = cb })
+}
+
+// RegisterEventCallback registers the callback to handle the events of the device
+func (d *Driver) RegisterEventCallback(device string, cb EventCallback) {
+	d.update(device, func(c *callbacks) { c.event = cb })
+}
+
+// RegisterResponseCallback registers the callback to handle the response of get of the device
+func (d *Driver) RegisterResponseCallback(device string, cb ResponseCallback) {
+	d.update(device, func(c *callbacks) { c.response = cb })
+}
+
+// ReportProperties reports the properties of the device
+func (d *Driver) ReportProperties(device string, props Props) error {
+	data, err := json.Marshal(props)
+	if err != nil {
+		return err
+	}
+	return d.cli.Publish(1, fmt.Sprintf(TopicReport, device), data, 0, false, false)
+}
+
+// GetProperties requests the twin of the device, the response is handled by the response callback
+func (d *Driver) GetProperties(device string) error {
+	return d.cli.Publish(1, fmt.Sprintf(TopicGet, device), []byte("{}"), 0, false, false)
+}
+
+// Close closes the driver
+func (d *Driver) Close() error {
+	return d.cli.Close()
+}
+
+func (d *Driver) update(device string, f func(*callbacks)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	c, ok := d.cbs[device]
+	if !ok {
+		c = &callbacks{}
+		d.cbs[device] = c
+	}
+	f(c)
+}
+
+func (d *Driver) onPublish(pkt *mqtt.Publish) error {
+	device, kind, ok := parseTopic(pkt.Message.Topic)
+	if !ok {
+		d.log.Warn("topic is not a device topic", log.Any("topic", pkt.Message.Topic))
+		return nil
+	}
+	d.mu.RLock()
+	c, ok := d.cbs[device]
+	var cb callbacks
+	if ok {
+		cb = *c
+	}
+	d.mu.RUnlock()
+
+	var err error
+	switch kind {
+	case "delta":
+		if cb.delta == nil {
+			break
+		}
+		var delta Props
+		if err = json.Unmarshal(pkt.Message.Payload, &delta); err == nil {
+			err = cb.delta(device, delta)
+		}
+	case "event":
+		if cb.event == nil {
+			break
+		}
+		var e Event
+		if err = json.Unmarshal(pkt.Message.Payload, &e); err == nil {
+			err = cb.event(device, &e)
+		}
+	case "getResponse":
+		if cb.response == nil {
+			break
+		}
+		var t Twin
+		if err = json.Unmarshal(pkt.Message.Payload, &t); err == nil {
+			err = cb.response(device, &t)
+		}
+	}
+	if err != nil {
+		d.log.Error("failed to handle message of device", log.Any("device", device), log.Any("topic", pkt.Message.Topic), log.Error(err))
+	}
+	// the error is not returned to avoid reconnecting
+	return nil
+}
+
+func (d *Driver) onError(err error) {
+	d.log.Error("error occurs in mqtt client", log.Error(err))
+}
+
+// parseTopic parses the device name and the kind from topic, such as $baetyl/device/d1/delta
+func parseTopic(topic string) (string, string, bool) {
+	parts := strings.Split(topic, "/")
+	if len(parts) != 4 || parts[0] != "$baetyl" || parts[1] != "device" || parts[2] == "" {
+		return "", "", false
+	}
+	return parts[2], parts[3], true
+}
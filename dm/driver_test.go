@@ -0,0 +1,65 @@
+package dm
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/baetyl/baetyl-go/log"
+	"github.com/baetyl/baetyl-go/mqtt"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestPublish(topic, payload string) *mqtt.Publish {
+	pkt := mqtt.NewPublish()
+	pkt.Message.Topic = topic
+	pkt.Message.Payload = []byte(payload)
+	return pkt
+}
+
+func TestDriverDispatch(t *testing.T) {
+	d := &Driver{cbs: map[string]*callbacks{}, log: log.With(log.Any("dm", "driver"))}
+
+	var deltas []Props
+	var events []*Event
+	var twins []*Twin
+	d.RegisterDeltaCallback("d1", func(device string, delta Props) error {
+		assert.Equal(t, "d1", device)
+		deltas = append(deltas, delta)
+		return nil
+	})
+	d.RegisterEventCallback("d1", func(device string, e *Event) error {
+		assert.Equal(t, "d1", device)
+		events = append(events, e)
+		return errors.New("ignored")
+	})
+	d.RegisterResponseCallback("d1", func(device string, twin *Twin) error {
+		assert.Equal(t, "d1", device)
+		twins = append(twins, twin)
+		return nil
+	})
+
+	assert.NoError(t, d.onPublish(newTestPublish(fmt.Sprintf(TopicDelta, "d1"), `{"switch":true}`)))
+	assert.NoError(t, d.onPublish(newTestPublish(fmt.Sprintf(TopicEvent, "d1"), `{"type":"propertyGet"}`)))
+	assert.NoError(t, d.onPublish(newTestPublish(fmt.Sprintf(TopicGetResponse, "d1"), `{"reported":{"temp":1},"desired":{"temp":2}}`)))
+	// unknown device, invalid payload and invalid topic are ignored
+	assert.NoError(t, d.onPublish(newTestPublish(fmt.Sprintf(TopicDelta, "d2"), `{"switch":true}`)))
+	assert.NoError(t, d.onPublish(newTestPublish(fmt.Sprintf(TopicDelta, "d1"), `{`)))
+	assert.NoError(t, d.onPublish(newTestPublish("a/b", `{}`)))
+
+	assert.Equal(t, []Props{{"switch": true}}, deltas)
+	assert.Equal(t, []*Event{{Type: EventPropertyGet}}, events)
+	assert.Equal(t, []*Twin{{Reported: Props{"temp": 1.0}, Desired: Props{"temp": 2.0}}}, twins)
+}
+
+func TestParseTopic(t *testing.T) {
+	device, kind, ok := parseTopic("$baetyl/device/d1/delta")
+	assert.True(t, ok)
+	assert.Equal(t, "d1", device)
+	assert.Equal(t, "delta", kind)
+
+	for _, topic := range []string{"", "$baetyl/device//delta", "$baetyl/device/d1", "$baetyl/node/d1/delta", "$baetyl/device/d1/delta/x"} {
+		_, _, ok = parseTopic(topic)
+		assert.False(t, ok, topic)
+	}
+}
@@ -0,0 +1,261 @@
+package dm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/baetyl/baetyl-go/spec/v1"
+)
+
+// all field encodings of binary payload
+const (
+	EncodingInt8    = "int8"
+	EncodingUint8   = "uint8"
+	EncodingInt16   = "int16"
+	EncodingUint16  = "uint16"
+	EncodingInt32   = "int32"
+	EncodingUint32  = "uint32"
+	EncodingInt64   = "int64"
+	EncodingUint64  = "uint64"
+	EncodingFloat32 = "float32"
+	EncodingFloat64 = "float64"
+	EncodingString  = "string"
+)
+
+// all byte orders
+const (
+	EndianBig    = "big"
+	EndianLittle = "little"
+)
+
+// ParserConfig the config of binary payload parser, which converts the raw payload of device into properties
+type ParserConfig struct {
+	Endian string        `yaml:"endian" json:"endian" default:"big"` // the default byte order of fields
+	Fields []ParserField `yaml:"fields" json:"fields"`
+}
+
+// ParserField the config of field in binary payload, the value is raw*scale+bias.
+// If bits is positive, the value is the bitfield [bit, bit+bits) of the raw integer, bit 0 is the least significant.
+// If word swap is true, the order of 16-bit words is reversed before decoding, such as CDAB float of modbus.
+type ParserField struct {
+	Name     string  `yaml:"name" json:"name"`
+	Offset   int     `yaml:"offset" json:"offset"`
+	Length   int     `yaml:"length" json:"length"` // the length of string
+	Encoding string  `yaml:"encoding" json:"encoding" default:"uint16"`
+	Endian   string  `yaml:"endian" json:"endian"` // use the default byte order if empty
+	WordSwap bool    `yaml:"wordSwap" json:"wordSwap"`
+	Bit      int     `yaml:"bit" json:"bit"`
+	Bits     int     `yaml:"bits" json:"bits"`
+	Scale    float64 `yaml:"scale" json:"scale" default:"1"`
+	Bias     float64 `yaml:"bias" json:"bias"`
+	Type     string  `yaml:"type" json:"type"` // the property type, derived from the encoding if empty
+}
+
+// Parser the parser of binary payload
+type Parser struct {
+	fields []ParserField
+}
+
+// NewParser creates a new parser, the config is validated
+func NewParser(cfg ParserConfig) (*Parser, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	p := &Parser{fields: make([]ParserField, len(cfg.Fields))}
+	for i, f := range cfg.Fields {
+		if f.Endian == "" {
+			f.Endian = cfg.Endian
+		}
+		if f.Type == "" {
+			f.Type = f.defaultType()
+		}
+		p.fields[i] = f
+	}
+	return p, nil
+}
+
+// Parse parses the payload into properties, returns error if the payload is too short
+func (p *Parser) Parse(data []byte) (Props, error) {
+	props := Props{}
+	for i := range p.fields {
+		f := &p.fields[i]
+		size := f.size()
+		if f.Offset+size > len(data) {
+			return nil, fmt.Errorf("field (%s) is out of payload: offset %d + size %d > length %d", f.Name, f.Offset, size, len(data))
+		}
+		props[f.Name] = f.parse(data[f.Offset : f.Offset+size])
+	}
+	return props, nil
+}
+
+func (f *ParserField) size() int {
+	switch f.Encoding {
+	case EncodingInt8, EncodingUint8:
+		return 1
+	case EncodingInt16, EncodingUint16:
+		return 2
+	case EncodingInt32, EncodingUint32, EncodingFloat32:
+		return 4
+	case EncodingInt64, EncodingUint64, EncodingFloat64:
+		return 8
+	case EncodingString:
+		return f.Length
+	default:
+		return 0
+	}
+}
+
+func (f *ParserField) defaultType() string {
+	switch {
+	case f.Encoding == EncodingString:
+		return TypeString
+	case f.Bits == 1:
+		return TypeBool
+	case f.Encoding == EncodingFloat32 || f.Encoding == EncodingFloat64 || f.Scale != 1 || f.Bias != 0:
+		return TypeFloat
+	default:
+		return TypeInt
+	}
+}
+
+func (f *ParserField) parse(b []byte) interface{} {
+	if f.Encoding == EncodingString {
+		return strings.TrimRight(string(b), "\x00 ")
+	}
+	if f.WordSwap && len(b) >= 4 {
+		s := make([]byte, len(b))
+		for i := 0; i < len(b); i += 2 {
+			copy(s[len(b)-i-2:], b[i:i+2])
+		}
+		b = s
+	}
+	var order binary.ByteOrder = binary.BigEndian
+	if f.Endian == EndianLittle {
+		order = binary.LittleEndian
+	}
+	var raw uint64
+	switch len(b) {
+	case 1:
+		raw = uint64(b[0])
+	case 2:
+		raw = uint64(order.Uint16(b))
+	case 4:
+		raw = uint64(order.Uint32(b))
+	case 8:
+		raw = order.Uint64(b)
+	}
+
+	var i int64
+	var v float64
+	isInt := true
+	switch {
+	case f.Bits > 0:
+		i = int64(raw >> uint(f.Bit) & (1<<uint(f.Bits) - 1))
+	case f.Encoding == EncodingInt8:
+		i = int64(int8(raw))
+	case f.Encoding == EncodingInt16:
+		i = int64(int16(raw))
+	case f.Encoding == EncodingInt32:
+		i = int64(int32(raw))
+	case f.Encoding == EncodingInt64, f.Encoding == EncodingUint64:
+		i = int64(raw)
+	case f.Encoding == EncodingFloat32:
+		v, isInt = float64(math.Float32frombits(uint32(raw))), false
+	case f.Encoding == EncodingFloat64:
+		v, isInt = math.Float64frombits(raw), false
+	default:
+		i = int64(raw)
+	}
+	if isInt {
+		if f.Encoding == EncodingUint64 && f.Bits == 0 {
+			v = float64(raw)
+		} else {
+			v = float64(i)
+		}
+		if f.Scale == 1 && f.Bias == 0 {
+			// keep the exact integer
+			switch f.Type {
+			case TypeInt:
+				return i
+			case TypeBool:
+				return i != 0
+			case TypeString:
+				if f.Encoding == EncodingUint64 && f.Bits == 0 {
+					return strconv.FormatUint(raw, 10)
+				}
+				return strconv.FormatInt(i, 10)
+			}
+		}
+	}
+	v = v*f.Scale + f.Bias
+	switch f.Type {
+	case TypeInt:
+		return int64(math.Round(v))
+	case TypeBool:
+		return v != 0
+	case TypeString:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return v
+	}
+}
+
+// Validate validates the parser config
+func (c *ParserConfig) Validate() error {
+	var es v1.FieldErrors
+	if !validEndian(c.Endian) {
+		addFieldError(&es, "endian", "endian (%s) must be big or little", c.Endian)
+	}
+	names := map[string]struct{}{}
+	for i := range c.Fields {
+		f := &c.Fields[i]
+		ff := fmt.Sprintf("fields[%d]", i)
+		if f.Name == "" {
+			addFieldError(&es, ff+".name", "name is required")
+		} else if _, ok := names[f.Name]; ok {
+			addFieldError(&es, ff+".name", "name (%s) is duplicated", f.Name)
+		}
+		names[f.Name] = struct{}{}
+		if f.Offset < 0 {
+			addFieldError(&es, ff+".offset", "offset (%d) must not be negative", f.Offset)
+		}
+		if f.Endian != "" && !validEndian(f.Endian) {
+			addFieldError(&es, ff+".endian", "endian (%s) must be big or little", f.Endian)
+		}
+		size := f.size()
+		switch f.Encoding {
+		case EncodingString:
+			if f.Length <= 0 {
+				addFieldError(&es, ff+".length", "length (%d) must be positive", f.Length)
+			}
+			if f.Type != "" && f.Type != TypeString {
+				addFieldError(&es, ff+".type", "type (%s) must be string", f.Type)
+			}
+		default:
+			if size == 0 {
+				addFieldError(&es, ff+".encoding", "encoding (%s) is not supported", f.Encoding)
+			}
+		}
+		switch {
+		case f.Bits == 0:
+		case f.Encoding == EncodingString, f.Encoding == EncodingFloat32, f.Encoding == EncodingFloat64:
+			addFieldError(&es, ff+".bits", "bits is not supported by encoding (%s)", f.Encoding)
+		case f.Bits < 0 || f.Bit < 0 || f.Bit+f.Bits > size*8 || f.Bits >= 64:
+			addFieldError(&es, ff+".bits", "bitfield [%d, %d) is out of encoding (%s)", f.Bit, f.Bit+f.Bits, f.Encoding)
+		}
+		if f.WordSwap && (size < 4 || f.Encoding == EncodingString) {
+			addFieldError(&es, ff+".wordSwap", "word swap is not supported by encoding (%s)", f.Encoding)
+		}
+		if f.Type != "" {
+			validateType(&es, ff+".type", f.Type)
+		}
+	}
+	return fieldErrors(es)
+}
+
+func validEndian(e string) bool {
+	return e == EndianBig || e == EndianLittle
+}
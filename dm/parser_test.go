@@ -0,0 +1,112 @@
+package dm
+
+import (
+	"testing"
+
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParser(t *testing.T) {
+	in := `
+fields:
+- name: temperature
+  offset: 0
+  encoding: int16
+  scale: 0.1
+- name: humidity
+  offset: 2
+  encoding: uint8
+- name: running
+  offset: 3
+  encoding: uint8
+  bit: 7
+  bits: 1
+- name: mode
+  offset: 3
+  encoding: uint8
+  bit: 0
+  bits: 3
+- name: pressure
+  offset: 4
+  encoding: float32
+  wordSwap: true
+- name: counter
+  offset: 8
+  encoding: uint32
+  endian: little
+- name: serial
+  offset: 12
+  encoding: string
+  length: 6
+- name: voltage
+  offset: 18
+  encoding: uint16
+  scale: 0.01
+  bias: -1
+  type: int
+- name: code
+  offset: 20
+  encoding: uint64
+  type: string
+`
+	var cfg ParserConfig
+	assert.NoError(t, utils.UnmarshalYAML([]byte(in), &cfg))
+	assert.Equal(t, EndianBig, cfg.Endian)
+	assert.Equal(t, 1.0, cfg.Fields[1].Scale)
+	p, err := NewParser(cfg)
+	assert.NoError(t, err)
+
+	data := []byte{
+		0xff, 0x38, // -200
+		55,
+		0x85,                   // running, mode 5
+		0x00, 0x00, 0x42, 0xf6, // 123.0 in CDAB
+		0x01, 0x02, 0x00, 0x00, // 513
+		'S', 'N', '0', '1', 0, 0,
+		0x03, 0xe8, // 1000
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+	}
+	props, err := p.Parse(data)
+	assert.NoError(t, err)
+	assert.Equal(t, Props{
+		"temperature": -20.0,
+		"humidity":    int64(55),
+		"running":     true,
+		"mode":        int64(5),
+		"pressure":    123.0,
+		"counter":     int64(513),
+		"serial":      "SN01",
+		"voltage":     int64(9),
+		"code":        "18446744073709551615",
+	}, props)
+
+	_, err = p.Parse(data[:20])
+	assert.EqualError(t, err, "field (code) is out of payload: offset 20 + size 8 > length 20")
+
+	cfg = ParserConfig{
+		Endian: "middle",
+		Fields: []ParserField{
+			{Name: "a", Encoding: "int24"},
+			{Name: "a", Offset: -1, Encoding: EncodingString, Type: TypeInt},
+			{Name: "s", Encoding: EncodingString, Length: 4, WordSwap: true},
+			{Name: "b", Encoding: EncodingFloat32, Bits: 1, Endian: "x"},
+			{Name: "c", Encoding: EncodingUint8, Bit: 4, Bits: 5, WordSwap: true, Type: TypeTime},
+		},
+	}
+	err = cfg.Validate()
+	assert.EqualError(t, err, "endian: endian (middle) must be big or little; "+
+		"fields[0].encoding: encoding (int24) is not supported; "+
+		"fields[1].name: name (a) is duplicated; "+
+		"fields[1].offset: offset (-1) must not be negative; "+
+		"fields[1].length: length (0) must be positive; "+
+		"fields[1].type: type (int) must be string; "+
+		"fields[2].wordSwap: word swap is not supported by encoding (string); "+
+		"fields[3].endian: endian (x) must be big or little; "+
+		"fields[3].bits: bits is not supported by encoding (float32); "+
+		"fields[4].bits: bitfield [4, 9) is out of encoding (uint8); "+
+		"fields[4].wordSwap: word swap is not supported by encoding (uint8); "+
+		"fields[4].type: type (time) is not supported")
+	_, err = NewParser(cfg)
+	assert.Error(t, err)
+}
@@ -0,0 +1,77 @@
+package dm
+
+import (
+	"github.com/baetyl/baetyl-go/patch"
+)
+
+// Props the properties of device, the value can be nested props
+type Props map[string]interface{}
+
+// Twin the twin of device, the desired properties are set by cloud, the reported properties are reported by device
+type Twin struct {
+	Reported Props `json:"reported,omitempty" yaml:"reported,omitempty"`
+	Desired  Props `json:"desired,omitempty" yaml:"desired,omitempty"`
+}
+
+// Delta returns the desired properties which are different from the reported
+func (t *Twin) Delta() Props {
+	return t.Desired.Diff(t.Reported)
+}
+
+// DeepCopy creates a deep copy of props
+func (p Props) DeepCopy() Props {
+	if p == nil {
+		return nil
+	}
+	return patch.DeepCopy(map[string]interface{}(p)).(map[string]interface{})
+}
+
+// Diff returns the properties which are different from the base recursively,
+// the properties only in base are ignored, the numbers are compared by value regardless of type
+func (p Props) Diff(base Props) Props {
+	delta := Props{}
+	for k, v := range p {
+		bv, ok := base[k]
+		if !ok {
+			delta[k] = patch.DeepCopy(v)
+			continue
+		}
+		vm, vok := toMap(v)
+		bm, bok := toMap(bv)
+		if vok && bok {
+			if d := Props(vm).Diff(Props(bm)); len(d) > 0 {
+				delta[k] = map[string]interface{}(d)
+			}
+			continue
+		}
+		if !patch.Equal(v, bv) {
+			delta[k] = patch.DeepCopy(v)
+		}
+	}
+	return delta
+}
+
+// Merge merges the delta into a copy of props recursively as json merge patch (RFC 7386) and returns it,
+// the property is removed if its value in delta is nil
+func (p Props) Merge(delta Props) Props {
+	return patch.MergePatch(map[string]interface{}(p), map[string]interface{}(delta)).(map[string]interface{})
+}
+
+// Merge3 merges the properties changed by cloud (desired) and the properties changed by device (reported)
+// since the base, the conflicts are resolved by the desired, see patch.Merge3
+func Merge3(base, reported, desired Props) (Props, []string) {
+	merged, conflicts := patch.Merge3(map[string]interface{}(base), map[string]interface{}(reported), map[string]interface{}(desired))
+	m, _ := toMap(merged)
+	return m, conflicts
+}
+
+func toMap(v interface{}) (map[string]interface{}, bool) {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m, true
+	case Props:
+		return m, true
+	default:
+		return nil, false
+	}
+}
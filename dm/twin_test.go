@@ -0,0 +1,91 @@
+package dm
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTwinDelta(t *testing.T) {
+	var twin Twin
+	err := json.Unmarshal([]byte(`{
+		"reported": {"temperature": 20, "switch": false, "config": {"interval": 5, "unit": "c"}, "tags": ["a"]},
+		"desired": {"temperature": 20, "switch": true, "config": {"interval": 10, "unit": "c"}, "tags": ["a"], "mode": "auto"}
+	}`), &twin)
+	assert.NoError(t, err)
+
+	delta := twin.Delta()
+	assert.Equal(t, Props{
+		"switch": true,
+		"config": map[string]interface{}{"interval": float64(10)},
+		"mode":   "auto",
+	}, delta)
+
+	// numbers are compared by value
+	assert.Len(t, Props{"a": 1, "b": int64(2), "c": float32(1.5)}.Diff(Props{"a": 1.0, "b": 2.0, "c": 1.5}), 0)
+	assert.Equal(t, Props{"a": 1}, Props{"a": 1}.Diff(Props{"a": "1"}))
+	assert.Equal(t, Props{"a": 1}, Props{"a": 1}.Diff(nil))
+
+	// the delta is a copy
+	delta["config"].(map[string]interface{})["interval"] = 20
+	assert.Equal(t, float64(10), twin.Desired["config"].(map[string]interface{})["interval"])
+}
+
+func TestPropsMerge(t *testing.T) {
+	reported := Props{
+		"temperature": 20,
+		"config":      map[string]interface{}{"interval": 5, "unit": "c"},
+		"old":         true,
+	}
+	merged := reported.Merge(Props{
+		"temperature": 21,
+		"config":      map[string]interface{}{"interval": 10, "unit": nil},
+		"old":         nil,
+		"new":         map[string]interface{}{"a": 1, "b": nil},
+	})
+	assert.Equal(t, Props{
+		"temperature": 21,
+		"config":      map[string]interface{}{"interval": 10},
+		"new":         map[string]interface{}{"a": 1},
+	}, merged)
+	assert.Equal(t, 20, reported["temperature"])
+	assert.Equal(t, map[string]interface{}{"interval": 5, "unit": "c"}, reported["config"])
+
+	var p Props
+	assert.Equal(t, Props{"a": 1}, p.Merge(Props{"a": 1}))
+	assert.Nil(t, p.DeepCopy())
+}
+
+func TestMerge3(t *testing.T) {
+	base := Props{"interval": 5, "unit": "c", "mode": "auto"}
+	reported := Props{"interval": 5, "unit": "f", "mode": "eco"}
+	desired := Props{"interval": 10, "unit": "c", "mode": "off"}
+	merged, conflicts := Merge3(base, reported, desired)
+	assert.Equal(t, Props{"interval": 10, "unit": "f", "mode": "off"}, merged)
+	assert.Equal(t, []string{"/mode"}, conflicts)
+}
+
+func TestDevice(t *testing.T) {
+	d := &Device{
+		Name:        "d1",
+		DeviceModel: "m1",
+		Labels:      map[string]string{"a": "b"},
+		Twin:        Twin{Reported: Props{"x": map[string]interface{}{"y": 1}}},
+	}
+	cp := d.DeepCopy()
+	assert.Equal(t, d, cp)
+	cp.Labels["a"] = "c"
+	cp.Twin.Reported["x"].(map[string]interface{})["y"] = 2
+	assert.Equal(t, "b", d.Labels["a"])
+	assert.Equal(t, 1, d.Twin.Reported["x"].(map[string]interface{})["y"])
+	var nd *Device
+	assert.Nil(t, nd.DeepCopy())
+
+	m := &DeviceModel{Properties: []DeviceProperty{{Name: "temperature", Type: TypeFloat}}}
+	p, ok := m.Property("temperature")
+	assert.True(t, ok)
+	assert.Equal(t, TypeFloat, p.Type)
+	_, ok = m.Property("x")
+	assert.False(t, ok)
+}
@@ -0,0 +1,356 @@
+// Package docker provides a thin client of docker engine api, and the mapping from the application model of spec.
+package docker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	gohttp "net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/baetyl/baetyl-go/http"
+	"github.com/baetyl/baetyl-go/log"
+	"github.com/baetyl/baetyl-go/utils"
+)
+
+// ErrNotFound the object (container, image, volume or network) is not found
+var ErrNotFound = errors.New("object not found")
+
+// Client the client of docker engine api
+type Client struct {
+	cfg  Config
+	cli  *gohttp.Client
+	base string
+	log  *log.Logger
+}
+
+// NewClient creates a new docker client
+func NewClient(cfg Config) (*Client, error) {
+	u, err := url.Parse(cfg.Host)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: cfg.Timeout}
+	transport := &gohttp.Transport{}
+	scheme := "http"
+	switch u.Scheme {
+	case "unix":
+		path := u.Path
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", path)
+		}
+		u.Host = "docker"
+	case "tcp", "http", "https":
+		transport.DialContext = dialer.DialContext
+		if cfg.Certificate.CA != "" || cfg.Certificate.Cert != "" || u.Scheme == "https" {
+			var tc *tls.Config
+			tc, err = utils.NewTLSConfigClient(cfg.Certificate)
+			if err != nil {
+				return nil, err
+			}
+			transport.TLSClientConfig = tc
+			scheme = "https"
+		}
+	default:
+		return nil, fmt.Errorf("host (%s) not supported", cfg.Host)
+	}
+	base := scheme + "://" + u.Host
+	if cfg.APIVersion != "" {
+		base += "/v" + strings.TrimPrefix(cfg.APIVersion, "v")
+	}
+	return &Client{
+		cfg:  cfg,
+		cli:  &gohttp.Client{Transport: transport},
+		base: base,
+		log:  log.With(log.Any("docker", "client")),
+	}, nil
+}
+
+// Ping checks whether the docker engine is available
+func (c *Client) Ping(ctx context.Context) error {
+	return c.call(ctx, gohttp.MethodGet, "/_ping", nil, nil, nil)
+}
+
+// PullImage pulls the image with the registry auth (optional), blocks until the image is pulled
+func (c *Client) PullImage(ctx context.Context, image string, auth *AuthConfig) error {
+	name, tag := parseImage(image)
+	q := url.Values{"fromImage": {name}, "tag": {tag}}
+	header := map[string]string{}
+	if auth != nil {
+		data, err := json.Marshal(auth)
+		if err != nil {
+			return err
+		}
+		header["X-Registry-Auth"] = base64.URLEncoding.EncodeToString(data)
+	}
+	res, err := c.send(ctx, gohttp.MethodPost, "/images/create?"+q.Encode(), nil, header)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	// the progress is streamed in json lines, the error is reported in the stream
+	s := bufio.NewScanner(res.Body)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	for s.Scan() {
+		var msg struct {
+			Status string `json:"status"`
+			Error  string `json:"error"`
+		}
+		if json.Unmarshal(s.Bytes(), &msg) != nil {
+			continue
+		}
+		if msg.Error != "" {
+			return fmt.Errorf("failed to pull image (%s): %s", image, msg.Error)
+		}
+	}
+	if err = s.Err(); err != nil {
+		return err
+	}
+	c.log.Info("image is pulled", log.Any("image", image))
+	return nil
+}
+
+// InspectImage checks whether the image exists, returns ErrNotFound if not
+func (c *Client) InspectImage(ctx context.Context, image string) error {
+	return c.call(ctx, gohttp.MethodGet, "/images/"+image+"/json", nil, nil, nil)
+}
+
+// CreateContainer creates a container with the name (optional), returns the container id
+func (c *Client) CreateContainer(ctx context.Context, name string, cfg *ContainerConfig) (string, error) {
+	path := "/containers/create"
+	if name != "" {
+		path += "?" + url.Values{"name": {name}}.Encode()
+	}
+	var out struct {
+		ID string `json:"Id"`
+	}
+	err := c.call(ctx, gohttp.MethodPost, path, cfg, nil, &out)
+	if err != nil {
+		return "", err
+	}
+	return out.ID, nil
+}
+
+// StartContainer starts the container
+func (c *Client) StartContainer(ctx context.Context, id string) error {
+	return c.call(ctx, gohttp.MethodPost, "/containers/"+id+"/start", nil, nil, nil)
+}
+
+// StopContainer stops the container, the container is killed if not stopped after the timeout
+func (c *Client) StopContainer(ctx context.Context, id string, timeout time.Duration) error {
+	q := url.Values{"t": {strconv.Itoa(int(timeout.Seconds()))}}
+	return c.call(ctx, gohttp.MethodPost, "/containers/"+id+"/stop?"+q.Encode(), nil, nil, nil)
+}
+
+// RestartContainer restarts the container
+func (c *Client) RestartContainer(ctx context.Context, id string, timeout time.Duration) error {
+	q := url.Values{"t": {strconv.Itoa(int(timeout.Seconds()))}}
+	return c.call(ctx, gohttp.MethodPost, "/containers/"+id+"/restart?"+q.Encode(), nil, nil, nil)
+}
+
+// RemoveContainer removes the container, the running one is killed if force
+func (c *Client) RemoveContainer(ctx context.Context, id string, force bool) error {
+	q := url.Values{"force": {strconv.FormatBool(force)}, "v": {"true"}}
+	return c.call(ctx, gohttp.MethodDelete, "/containers/"+id+"?"+q.Encode(), nil, nil, nil)
+}
+
+// InspectContainer returns the detail of the container
+func (c *Client) InspectContainer(ctx context.Context, id string) (*ContainerInfo, error) {
+	var info ContainerInfo
+	err := c.call(ctx, gohttp.MethodGet, "/containers/"+id+"/json", nil, nil, &info)
+	if err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// ListContainers returns all containers (including the stopped ones) which have all labels
+func (c *Client) ListContainers(ctx context.Context, labels map[string]string) ([]Container, error) {
+	q := url.Values{"all": {"true"}}
+	if len(labels) > 0 {
+		q.Set("filters", labelFilters(labels))
+	}
+	var cs []Container
+	err := c.call(ctx, gohttp.MethodGet, "/containers/json?"+q.Encode(), nil, nil, &cs)
+	return cs, err
+}
+
+// ContainerStats returns the current resource usage of the container
+func (c *Client) ContainerStats(ctx context.Context, id string) (*Stats, error) {
+	var raw rawStats
+	err := c.call(ctx, gohttp.MethodGet, "/containers/"+id+"/stats?stream=false", nil, nil, &raw)
+	if err != nil {
+		return nil, err
+	}
+	s := &Stats{
+		MemoryUsage: raw.MemoryStats.Usage,
+		MemoryLimit: raw.MemoryStats.Limit,
+	}
+	// the page cache is not counted as usage, same as docker cli
+	if v, ok := raw.MemoryStats.Stats["cache"]; ok && v < s.MemoryUsage {
+		s.MemoryUsage -= v
+	}
+	cpuDelta := float64(raw.CPUStats.CPUUsage.TotalUsage) - float64(raw.PreCPUStats.CPUUsage.TotalUsage)
+	sysDelta := float64(raw.CPUStats.SystemUsage) - float64(raw.PreCPUStats.SystemUsage)
+	cpus := float64(raw.CPUStats.OnlineCPUs)
+	if cpus == 0 {
+		cpus = float64(len(raw.CPUStats.CPUUsage.PercpuUsage))
+	}
+	if cpuDelta > 0 && sysDelta > 0 {
+		s.CPUPercent = cpuDelta / sysDelta * cpus * 100
+	}
+	return s, nil
+}
+
+// CreateVolume creates the volume if not exists
+func (c *Client) CreateVolume(ctx context.Context, v *Volume) (*Volume, error) {
+	var out Volume
+	err := c.call(ctx, gohttp.MethodPost, "/volumes/create", v, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RemoveVolume removes the volume
+func (c *Client) RemoveVolume(ctx context.Context, name string, force bool) error {
+	q := url.Values{"force": {strconv.FormatBool(force)}}
+	return c.call(ctx, gohttp.MethodDelete, "/volumes/"+name+"?"+q.Encode(), nil, nil, nil)
+}
+
+// CreateNetwork creates the network, returns the network id
+func (c *Client) CreateNetwork(ctx context.Context, n *Network) (string, error) {
+	req := struct {
+		*Network
+		CheckDuplicate bool `json:"CheckDuplicate"`
+	}{n, true}
+	var out struct {
+		ID string `json:"Id"`
+	}
+	err := c.call(ctx, gohttp.MethodPost, "/networks/create", req, nil, &out)
+	if err != nil {
+		return "", err
+	}
+	return out.ID, nil
+}
+
+// InspectNetwork returns the network by id or name
+func (c *Client) InspectNetwork(ctx context.Context, id string) (*Network, error) {
+	var out Network
+	err := c.call(ctx, gohttp.MethodGet, "/networks/"+id, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RemoveNetwork removes the network
+func (c *Client) RemoveNetwork(ctx context.Context, id string) error {
+	return c.call(ctx, gohttp.MethodDelete, "/networks/"+id, nil, nil, nil)
+}
+
+// ConnectNetwork connects the container to the network with the aliases
+func (c *Client) ConnectNetwork(ctx context.Context, network, container string, aliases ...string) error {
+	req := map[string]interface{}{
+		"Container":      container,
+		"EndpointConfig": map[string]interface{}{"Aliases": aliases},
+	}
+	return c.call(ctx, gohttp.MethodPost, "/networks/"+network+"/connect", req, nil, nil)
+}
+
+// Close closes idle connections
+func (c *Client) Close() error {
+	c.cli.CloseIdleConnections()
+	return nil
+}
+
+func (c *Client) call(ctx context.Context, method, path string, in interface{}, header map[string]string, out interface{}) error {
+	var body []byte
+	if in != nil {
+		var err error
+		body, err = json.Marshal(in)
+		if err != nil {
+			return err
+		}
+	}
+	res, err := c.send(ctx, method, path, body, header)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if out == nil {
+		io.Copy(ioutil.Discard, res.Body)
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+// send sends the request and returns the response if its status is 2xx, or ErrNotFound if 404,
+// otherwise returns http.StatusError with the message returned by docker
+func (c *Client) send(ctx context.Context, method, path string, body []byte, header map[string]string) (*gohttp.Response, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := gohttp.NewRequest(method, c.base+path, r)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if body != nil {
+		req.Header.Set(http.HeaderContentType, http.ContentTypeJSON)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	res, err := c.cli.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return res, nil
+	}
+	defer res.Body.Close()
+	if res.StatusCode == gohttp.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	data, _ := ioutil.ReadAll(res.Body)
+	var msg struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(data, &msg) != nil || msg.Message == "" {
+		msg.Message = strings.TrimSpace(string(data))
+	}
+	return nil, &http.StatusError{Code: res.StatusCode, Message: msg.Message}
+}
+
+// parseImage splits the image into name and tag (or digest), the tag is latest if not set
+func parseImage(image string) (string, string) {
+	if i := strings.Index(image, "@"); i >= 0 {
+		return image[:i], image[i+1:]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[:i], image[i+1:]
+	}
+	return image, "latest"
+}
+
+func labelFilters(labels map[string]string) string {
+	var ls []string
+	for k, v := range labels {
+		ls = append(ls, k+"="+v)
+	}
+	data, _ := json.Marshal(map[string][]string{"label": ls})
+	return string(data)
+}
@@ -0,0 +1,195 @@
+package docker
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	gohttp "net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/baetyl/baetyl-go/http"
+	v1 "github.com/baetyl/baetyl-go/spec/v1"
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeDocker struct {
+	t    *testing.T
+	reqs []string
+	auth string
+}
+
+func (f *fakeDocker) ServeHTTP(w gohttp.ResponseWriter, r *gohttp.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/v1.38")
+	f.reqs = append(f.reqs, r.Method+" "+path)
+	switch {
+	case path == "/_ping":
+		w.Write([]byte("OK"))
+	case path == "/images/create":
+		f.auth = r.Header.Get("X-Registry-Auth")
+		if r.URL.Query().Get("fromImage") == "bad" {
+			w.Write([]byte(`{"status":"Pulling"}` + "\n" + `{"error":"manifest unknown"}` + "\n"))
+			return
+		}
+		assert.Equal(f.t, "nginx", r.URL.Query().Get("fromImage"))
+		assert.Equal(f.t, "1.17", r.URL.Query().Get("tag"))
+		w.Write([]byte(`{"status":"Pulling"}` + "\n" + `{"status":"Downloaded"}` + "\n"))
+	case path == "/images/missing/json":
+		w.WriteHeader(gohttp.StatusNotFound)
+		w.Write([]byte(`{"message":"no such image"}`))
+	case path == "/containers/create":
+		var cfg ContainerConfig
+		assert.NoError(f.t, json.NewDecoder(r.Body).Decode(&cfg))
+		if cfg.Image == "conflict" {
+			w.WriteHeader(gohttp.StatusConflict)
+			w.Write([]byte(`{"message":"name is already in use"}`))
+			return
+		}
+		assert.Equal(f.t, "app-svc-0", r.URL.Query().Get("name"))
+		w.WriteHeader(gohttp.StatusCreated)
+		w.Write([]byte(`{"Id":"c1"}`))
+	case path == "/containers/c1/start", path == "/containers/c1/stop", path == "/containers/c1/restart":
+		w.WriteHeader(gohttp.StatusNoContent)
+	case path == "/containers/c1" && r.Method == gohttp.MethodDelete:
+		assert.Equal(f.t, "true", r.URL.Query().Get("force"))
+		w.WriteHeader(gohttp.StatusNoContent)
+	case path == "/containers/c1/json":
+		w.Write([]byte(`{"Id":"c1","Name":"/app-svc-0","State":{"Status":"running","Running":true,"Pid":10},"Config":{"Labels":{"baetyl-app-name":"app"}}}`))
+	case path == "/containers/json":
+		assert.Equal(f.t, `{"label":["baetyl-app-name=app"]}`, r.URL.Query().Get("filters"))
+		w.Write([]byte(`[{"Id":"c1","Names":["/app-svc-0"],"State":"running"}]`))
+	case path == "/containers/c1/stats":
+		w.Write([]byte(`{"cpu_stats":{"cpu_usage":{"total_usage":300},"system_cpu_usage":2000,"online_cpus":2},` +
+			`"precpu_stats":{"cpu_usage":{"total_usage":100},"system_cpu_usage":1000},` +
+			`"memory_stats":{"usage":1000,"limit":4000,"stats":{"cache":200}}}`))
+	case path == "/volumes/create":
+		w.WriteHeader(gohttp.StatusCreated)
+		w.Write([]byte(`{"Name":"v1","Driver":"local","Mountpoint":"/var/lib/docker/volumes/v1/_data"}`))
+	case path == "/volumes/v1":
+		w.WriteHeader(gohttp.StatusNoContent)
+	case path == "/networks/create":
+		w.WriteHeader(gohttp.StatusCreated)
+		w.Write([]byte(`{"Id":"n1"}`))
+	case path == "/networks/n1" && r.Method == gohttp.MethodGet:
+		w.Write([]byte(`{"Id":"n1","Name":"baetyl","Driver":"bridge"}`))
+	case path == "/networks/n1/connect":
+		data, _ := ioutil.ReadAll(r.Body)
+		assert.JSONEq(f.t, `{"Container":"c1","EndpointConfig":{"Aliases":["svc"]}}`, string(data))
+	case path == "/networks/n1":
+		w.WriteHeader(gohttp.StatusNoContent)
+	default:
+		w.WriteHeader(gohttp.StatusNotFound)
+	}
+}
+
+func newTestClient(t *testing.T, host string) *Client {
+	var cfg Config
+	assert.NoError(t, utils.SetDefaults(&cfg))
+	cfg.Host = host
+	cli, err := NewClient(cfg)
+	assert.NoError(t, err)
+	return cli
+}
+
+func TestClient(t *testing.T) {
+	f := &fakeDocker{t: t}
+	ts := httptest.NewServer(f)
+	defer ts.Close()
+	cli := newTestClient(t, strings.Replace(ts.URL, "http://", "tcp://", 1))
+	defer cli.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	assert.NoError(t, cli.Ping(ctx))
+
+	assert.NoError(t, cli.PullImage(ctx, "nginx:1.17", &AuthConfig{Username: "u", Password: "p"}))
+	data, err := base64.URLEncoding.DecodeString(f.auth)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"username":"u","password":"p"}`, string(data))
+	assert.EqualError(t, cli.PullImage(ctx, "bad", nil), "failed to pull image (bad): manifest unknown")
+	assert.Equal(t, ErrNotFound, cli.InspectImage(ctx, "missing"))
+
+	id, err := cli.CreateContainer(ctx, "app-svc-0", &ContainerConfig{Image: "nginx"})
+	assert.NoError(t, err)
+	assert.Equal(t, "c1", id)
+	_, err = cli.CreateContainer(ctx, "app-svc-0", &ContainerConfig{Image: "conflict"})
+	assert.Equal(t, &http.StatusError{Code: gohttp.StatusConflict, Message: "name is already in use"}, err)
+
+	assert.NoError(t, cli.StartContainer(ctx, id))
+	assert.NoError(t, cli.RestartContainer(ctx, id, time.Second))
+	assert.NoError(t, cli.StopContainer(ctx, id, 10*time.Second))
+	info, err := cli.InspectContainer(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, "/app-svc-0", info.Name)
+	assert.True(t, info.State.Running)
+	assert.Equal(t, "app", info.Config.Labels[v1.LabelAppName])
+	_, err = cli.InspectContainer(ctx, "c2")
+	assert.Equal(t, ErrNotFound, err)
+	cs, err := cli.ListContainers(ctx, map[string]string{v1.LabelAppName: "app"})
+	assert.NoError(t, err)
+	assert.Len(t, cs, 1)
+	assert.Equal(t, "running", cs[0].State)
+
+	stats, err := cli.ContainerStats(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, &Stats{CPUPercent: 40, MemoryUsage: 800, MemoryLimit: 4000}, stats)
+	assert.NoError(t, cli.RemoveContainer(ctx, id, true))
+
+	v, err := cli.CreateVolume(ctx, &Volume{Name: "v1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "local", v.Driver)
+	assert.NoError(t, cli.RemoveVolume(ctx, "v1", false))
+
+	nid, err := cli.CreateNetwork(ctx, &Network{Name: "baetyl", Driver: "bridge"})
+	assert.NoError(t, err)
+	assert.Equal(t, "n1", nid)
+	n, err := cli.InspectNetwork(ctx, nid)
+	assert.NoError(t, err)
+	assert.Equal(t, "baetyl", n.Name)
+	assert.NoError(t, cli.ConnectNetwork(ctx, nid, id, "svc"))
+	assert.NoError(t, cli.RemoveNetwork(ctx, nid))
+	assert.Contains(t, f.reqs, "DELETE /networks/n1")
+}
+
+func TestClientUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docker")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	sock := filepath.Join(dir, "docker.sock")
+	lis, err := net.Listen("unix", sock)
+	assert.NoError(t, err)
+	svr := &gohttp.Server{Handler: &fakeDocker{t: t}}
+	go svr.Serve(lis)
+	defer svr.Close()
+
+	cli := newTestClient(t, "unix://"+sock)
+	defer cli.Close()
+	assert.NoError(t, cli.Ping(context.Background()))
+
+	_, err = NewClient(Config{Host: "ftp://localhost"})
+	assert.EqualError(t, err, "host (ftp://localhost) not supported")
+}
+
+func TestParseImage(t *testing.T) {
+	cases := []struct {
+		image, name, tag string
+	}{
+		{"nginx", "nginx", "latest"},
+		{"nginx:1.17", "nginx", "1.17"},
+		{"localhost:5000/nginx", "localhost:5000/nginx", "latest"},
+		{"localhost:5000/baetyl/nginx:1.17", "localhost:5000/baetyl/nginx", "1.17"},
+		{"nginx@sha256:abc", "nginx", "sha256:abc"},
+	}
+	for _, c := range cases {
+		name, tag := parseImage(c.image)
+		assert.Equal(t, c.name, name, c.image)
+		assert.Equal(t, c.tag, tag, c.image)
+	}
+}
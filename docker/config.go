@@ -0,0 +1,15 @@
+package docker
+
+import (
+	"time"
+
+	"github.com/baetyl/baetyl-go/utils"
+)
+
+// Config the config of docker client
+type Config struct {
+	Host        string            `yaml:"host" json:"host" default:"unix:///var/run/docker.sock"` // unix://path or tcp://host:port
+	APIVersion  string            `yaml:"apiVersion" json:"apiVersion" default:"1.38"`
+	Certificate utils.Certificate `yaml:",inline" json:",inline"`               // used if the host is tcp
+	Timeout     time.Duration     `yaml:"timeout" json:"timeout" default:"30s"` // the timeout to connect
+}
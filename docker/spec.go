@@ -0,0 +1,128 @@
+package docker
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/baetyl/baetyl-go/context"
+	v1 "github.com/baetyl/baetyl-go/spec/v1"
+)
+
+// VolumeResolver returns the host path of the volume, such as the directory where the config or secret is stored
+type VolumeResolver func(v1.Volume) (string, error)
+
+// NewContainerConfig maps the service of application to the container config,
+// the host paths of config and secret volumes are returned by the resolver (optional)
+func NewContainerConfig(app *v1.Application, svc *v1.Service, resolver VolumeResolver) (*ContainerConfig, error) {
+	cfg := &ContainerConfig{
+		Image:      svc.Image,
+		Entrypoint: svc.Command,
+		Cmd:        svc.Args,
+		Labels: map[string]string{
+			v1.LabelAppName:     app.Name,
+			v1.LabelAppVersion:  app.Version,
+			v1.LabelServiceName: svc.Name,
+		},
+		HostConfig: HostConfig{
+			Runtime: svc.Runtime,
+		},
+	}
+	for k, v := range svc.Labels {
+		cfg.Labels[k] = v
+	}
+	cfg.Env = append(cfg.Env,
+		context.EnvKeyAppName+"="+app.Name,
+		context.EnvKeyServiceName+"="+svc.Name,
+	)
+	for _, e := range svc.Env {
+		cfg.Env = append(cfg.Env, e.Name+"="+e.Value)
+	}
+	for _, p := range svc.Ports {
+		proto := strings.ToLower(p.Protocol)
+		if proto == "" {
+			proto = "tcp"
+		}
+		port := strconv.Itoa(int(p.ContainerPort)) + "/" + proto
+		if cfg.ExposedPorts == nil {
+			cfg.ExposedPorts = map[string]struct{}{}
+			cfg.HostConfig.PortBindings = map[string][]PortBinding{}
+		}
+		cfg.ExposedPorts[port] = struct{}{}
+		if p.HostPort != 0 {
+			cfg.HostConfig.PortBindings[port] = append(cfg.HostConfig.PortBindings[port], PortBinding{
+				HostIP:   p.HostIP,
+				HostPort: strconv.Itoa(int(p.HostPort)),
+			})
+		}
+	}
+	vols := map[string]v1.Volume{}
+	for _, v := range app.Volumes {
+		vols[v.Name] = v
+	}
+	for _, m := range svc.VolumeMounts {
+		v, ok := vols[m.Name]
+		if !ok {
+			return nil, fmt.Errorf("volume (%s) not found", m.Name)
+		}
+		var src string
+		if v.HostPath != nil {
+			src = v.HostPath.Path
+		} else if resolver != nil {
+			var err error
+			src, err = resolver(v)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			return nil, fmt.Errorf("the host path of volume (%s) is not resolved", m.Name)
+		}
+		bind := src + ":" + m.MountPath
+		if m.ReadOnly {
+			bind += ":ro"
+		}
+		cfg.HostConfig.Binds = append(cfg.HostConfig.Binds, bind)
+	}
+	for _, d := range svc.Devices {
+		perm := d.Permissions
+		if perm == "" {
+			perm = "mrw"
+		}
+		cfg.HostConfig.Devices = append(cfg.HostConfig.Devices, DeviceMapping{
+			PathOnHost:        d.DevicePath,
+			PathInContainer:   d.DevicePath,
+			CgroupPermissions: perm,
+		})
+	}
+	if svc.Resources != nil {
+		if v, ok := svc.Resources.Limits["memory"]; ok {
+			n, err := v1.ParseQuantity(v)
+			if err != nil {
+				return nil, err
+			}
+			cfg.HostConfig.Memory = int64(n)
+		}
+		if v, ok := svc.Resources.Limits["cpu"]; ok {
+			n, err := v1.ParseQuantity(v)
+			if err != nil {
+				return nil, err
+			}
+			cfg.HostConfig.NanoCPUs = int64(n * 1e9)
+		}
+	}
+	cfg.HostConfig.RestartPolicy = RestartPolicy{Name: "always"}
+	if r := svc.Restart; r != nil {
+		switch r.Policy {
+		case "never":
+			cfg.HostConfig.RestartPolicy = RestartPolicy{Name: "no"}
+		case "on-failure":
+			cfg.HostConfig.RestartPolicy = RestartPolicy{Name: "on-failure", MaximumRetryCount: r.Retries}
+		}
+	}
+	return cfg, nil
+}
+
+// ContainerName returns the name of the container of the service instance
+func ContainerName(app, svc string, instance int) string {
+	return fmt.Sprintf("%s-%s-%d", app, svc, instance)
+}
@@ -0,0 +1,81 @@
+package docker
+
+import (
+	"errors"
+	"testing"
+
+	v1 "github.com/baetyl/baetyl-go/spec/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewContainerConfig(t *testing.T) {
+	app := &v1.Application{
+		Name:    "app",
+		Version: "3",
+		Volumes: []v1.Volume{
+			{Name: "data", VolumeSource: v1.VolumeSource{HostPath: &v1.HostPathVolumeSource{Path: "/var/data"}}},
+			{Name: "conf", VolumeSource: v1.VolumeSource{Config: &v1.ObjectReference{Name: "conf", Version: "1"}}},
+		},
+	}
+	svc := &v1.Service{
+		Name:    "svc",
+		Labels:  map[string]string{"a": "b"},
+		Image:   "nginx:1.17",
+		Command: []string{"nginx"},
+		Args:    []string{"-g", "daemon off;"},
+		Env:     []v1.Environment{{Name: "K", Value: "V"}},
+		Ports: []v1.ContainerPort{
+			{ContainerPort: 80, HostPort: 8080},
+			{ContainerPort: 53, Protocol: "UDP"},
+		},
+		VolumeMounts: []v1.VolumeMount{
+			{Name: "data", MountPath: "/data"},
+			{Name: "conf", MountPath: "/etc/nginx", ReadOnly: true},
+		},
+		Devices:   []v1.Device{{DevicePath: "/dev/ttyS0"}},
+		Resources: &v1.Resources{Limits: map[string]string{"cpu": "500m", "memory": "128Mi"}},
+		Restart:   &v1.RestartPolicy{Policy: "on-failure", Retries: 3},
+	}
+	resolver := func(v v1.Volume) (string, error) {
+		return "/var/lib/baetyl/configs/" + v.Config.Name + "/" + v.Config.Version, nil
+	}
+	cfg, err := NewContainerConfig(app, svc, resolver)
+	assert.NoError(t, err)
+	expected := &ContainerConfig{
+		Image:      "nginx:1.17",
+		Entrypoint: []string{"nginx"},
+		Cmd:        []string{"-g", "daemon off;"},
+		Env:        []string{"BAETYL_APP_NAME=app", "BAETYL_SERVICE_NAME=svc", "K=V"},
+		Labels: map[string]string{
+			v1.LabelAppName:     "app",
+			v1.LabelAppVersion:  "3",
+			v1.LabelServiceName: "svc",
+			"a":                 "b",
+		},
+		ExposedPorts: map[string]struct{}{"80/tcp": {}, "53/udp": {}},
+		HostConfig: HostConfig{
+			Binds:         []string{"/var/data:/data", "/var/lib/baetyl/configs/conf/1:/etc/nginx:ro"},
+			PortBindings:  map[string][]PortBinding{"80/tcp": {{HostPort: "8080"}}},
+			Devices:       []DeviceMapping{{PathOnHost: "/dev/ttyS0", PathInContainer: "/dev/ttyS0", CgroupPermissions: "mrw"}},
+			RestartPolicy: RestartPolicy{Name: "on-failure", MaximumRetryCount: 3},
+			Memory:        128 << 20,
+			NanoCPUs:      500000000,
+		},
+	}
+	assert.Equal(t, expected, cfg)
+
+	_, err = NewContainerConfig(app, svc, nil)
+	assert.EqualError(t, err, "the host path of volume (conf) is not resolved")
+	_, err = NewContainerConfig(app, svc, func(v1.Volume) (string, error) { return "", errors.New("not ready") })
+	assert.EqualError(t, err, "not ready")
+
+	svc.VolumeMounts = []v1.VolumeMount{{Name: "missing"}}
+	_, err = NewContainerConfig(app, svc, nil)
+	assert.EqualError(t, err, "volume (missing) not found")
+
+	svc = &v1.Service{Name: "svc", Image: "busybox", Restart: &v1.RestartPolicy{Policy: "never"}}
+	cfg, err = NewContainerConfig(app, svc, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, RestartPolicy{Name: "no"}, cfg.HostConfig.RestartPolicy)
+	assert.Equal(t, "app-svc-1", ContainerName("app", "svc", 1))
+}
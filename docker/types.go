@@ -0,0 +1,129 @@
+package docker
+
+import "time"
+
+// AuthConfig the auth of registry to pull images
+type AuthConfig struct {
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+	ServerAddress string `json:"serveraddress,omitempty"`
+}
+
+// ContainerConfig the config to create container, a subset of the docker api
+type ContainerConfig struct {
+	Image        string              `json:"Image"`
+	Entrypoint   []string            `json:"Entrypoint,omitempty"`
+	Cmd          []string            `json:"Cmd,omitempty"`
+	Env          []string            `json:"Env,omitempty"`
+	Labels       map[string]string   `json:"Labels,omitempty"`
+	WorkingDir   string              `json:"WorkingDir,omitempty"`
+	ExposedPorts map[string]struct{} `json:"ExposedPorts,omitempty"`
+	HostConfig   HostConfig          `json:"HostConfig"`
+}
+
+// HostConfig the host config of container
+type HostConfig struct {
+	Binds         []string                 `json:"Binds,omitempty"`
+	PortBindings  map[string][]PortBinding `json:"PortBindings,omitempty"`
+	Devices       []DeviceMapping          `json:"Devices,omitempty"`
+	RestartPolicy RestartPolicy            `json:"RestartPolicy"`
+	NetworkMode   string                   `json:"NetworkMode,omitempty"`
+	Runtime       string                   `json:"Runtime,omitempty"`
+	Memory        int64                    `json:"Memory,omitempty"`
+	NanoCPUs      int64                    `json:"NanoCpus,omitempty"`
+}
+
+// PortBinding the port of host bound
+type PortBinding struct {
+	HostIP   string `json:"HostIp,omitempty"`
+	HostPort string `json:"HostPort,omitempty"`
+}
+
+// DeviceMapping the device of host mapped
+type DeviceMapping struct {
+	PathOnHost        string `json:"PathOnHost"`
+	PathInContainer   string `json:"PathInContainer"`
+	CgroupPermissions string `json:"CgroupPermissions"`
+}
+
+// RestartPolicy the restart policy of container
+type RestartPolicy struct {
+	Name              string `json:"Name,omitempty"`
+	MaximumRetryCount int    `json:"MaximumRetryCount,omitempty"`
+}
+
+// Container the container listed
+type Container struct {
+	ID     string            `json:"Id"`
+	Names  []string          `json:"Names"`
+	Image  string            `json:"Image"`
+	State  string            `json:"State"`
+	Status string            `json:"Status"`
+	Labels map[string]string `json:"Labels"`
+}
+
+// ContainerInfo the container inspected
+type ContainerInfo struct {
+	ID     string         `json:"Id"`
+	Name   string         `json:"Name"`
+	Image  string         `json:"Image"`
+	State  ContainerState `json:"State"`
+	Config struct {
+		Image  string            `json:"Image"`
+		Labels map[string]string `json:"Labels"`
+	} `json:"Config"`
+	RestartCount int `json:"RestartCount"`
+}
+
+// ContainerState the state of container
+type ContainerState struct {
+	Status     string    `json:"Status"`
+	Running    bool      `json:"Running"`
+	Pid        int       `json:"Pid"`
+	ExitCode   int       `json:"ExitCode"`
+	Error      string    `json:"Error"`
+	StartedAt  time.Time `json:"StartedAt"`
+	FinishedAt time.Time `json:"FinishedAt"`
+}
+
+// Stats the resource usage of container
+type Stats struct {
+	CPUPercent  float64 `json:"cpuPercent"`
+	MemoryUsage uint64  `json:"memoryUsage"`
+	MemoryLimit uint64  `json:"memoryLimit"`
+}
+
+type rawStats struct {
+	CPUStats    rawCPUStats `json:"cpu_stats"`
+	PreCPUStats rawCPUStats `json:"precpu_stats"`
+	MemoryStats struct {
+		Usage uint64            `json:"usage"`
+		Limit uint64            `json:"limit"`
+		Stats map[string]uint64 `json:"stats"`
+	} `json:"memory_stats"`
+}
+
+type rawCPUStats struct {
+	CPUUsage struct {
+		TotalUsage  uint64   `json:"total_usage"`
+		PercpuUsage []uint64 `json:"percpu_usage"`
+	} `json:"cpu_usage"`
+	SystemUsage uint64 `json:"system_cpu_usage"`
+	OnlineCPUs  uint32 `json:"online_cpus"`
+}
+
+// Volume the volume of docker
+type Volume struct {
+	Name       string            `json:"Name"`
+	Driver     string            `json:"Driver,omitempty"`
+	Mountpoint string            `json:"Mountpoint,omitempty"`
+	Labels     map[string]string `json:"Labels,omitempty"`
+}
+
+// Network the network of docker
+type Network struct {
+	ID     string            `json:"Id,omitempty"`
+	Name   string            `json:"Name"`
+	Driver string            `json:"Driver,omitempty"`
+	Labels map[string]string `json:"Labels,omitempty"`
+}
@@ -0,0 +1,67 @@
+package errors
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"syscall"
+
+	"github.com/256dpi/gomqtt/client/future"
+)
+
+// IsTimeout checks whether the error is caused by timeout, such as deadline exceeded code,
+// context deadline, network timeout and mqtt future timeout
+func IsTimeout(err error) bool {
+	if err == nil {
+		return false
+	}
+	if CodeOf(err) == CodeDeadlineExceeded {
+		return true
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, future.ErrTimeout) || os.IsTimeout(err) {
+		return true
+	}
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+// IsAuth checks whether the error is caused by authentication or authorization, such as unauthenticated
+// and permission denied codes (including mqtt connack return codes), and tls certificate verification errors
+func IsAuth(err error) bool {
+	if err == nil {
+		return false
+	}
+	switch CodeOf(err) {
+	case CodeUnauthenticated, CodePermissionDenied:
+		return true
+	}
+	var uae x509.UnknownAuthorityError
+	var cie x509.CertificateInvalidError
+	var he x509.HostnameError
+	return errors.As(err, &uae) || errors.As(err, &cie) || errors.As(err, &he)
+}
+
+// IsTemporary checks whether the error is temporary and the operation can be retried later,
+// such as unavailable, resource exhausted and aborted codes, timeout, connection refused or reset and unexpected eof.
+// The auth errors are never temporary.
+func IsTemporary(err error) bool {
+	if err == nil || IsAuth(err) {
+		return false
+	}
+	switch CodeOf(err) {
+	case CodeUnavailable, CodeResourceExhausted, CodeAborted, CodeDeadlineExceeded:
+		return true
+	}
+	if IsTimeout(err) {
+		return true
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var te interface{ Temporary() bool }
+	return errors.As(err, &te) && te.Temporary()
+}
@@ -0,0 +1,56 @@
+package errors
+
+import (
+	"context"
+	"crypto/x509"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/256dpi/gomqtt/client/future"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		err       error
+		timeout   bool
+		auth      bool
+		temporary bool
+	}{
+		{err: nil},
+		{err: stderrors.New("x")},
+		{err: Coded(CodeDeadlineExceeded, "x"), timeout: true, temporary: true},
+		{err: context.DeadlineExceeded, timeout: true, temporary: true},
+		{err: fmt.Errorf("wait: %w", future.ErrTimeout), timeout: true, temporary: true},
+		{err: &net.OpError{Op: "dial", Err: timeoutError{}}, timeout: true, temporary: true},
+		{err: status.Error(codes.DeadlineExceeded, "x"), timeout: true, temporary: true},
+		{err: status.Error(codes.Unauthenticated, "x"), auth: true},
+		{err: Coded(CodePermissionDenied, "connection refused: not authorized"), auth: true},
+		{err: fmt.Errorf("handshake: %w", x509.UnknownAuthorityError{}), auth: true},
+		{err: x509.HostnameError{Certificate: &x509.Certificate{}, Host: "a"}, auth: true},
+		{err: Wrap(x509.CertificateInvalidError{}, CodeUnavailable, ""), auth: true},
+		{err: status.Error(codes.Unavailable, "x"), temporary: true},
+		{err: Coded(CodeResourceExhausted, "x"), temporary: true},
+		{err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, temporary: true},
+		{err: io.ErrUnexpectedEOF, temporary: true},
+		{err: Coded(CodeInvalidArgument, "x")},
+		{err: status.Error(codes.NotFound, "x")},
+	}
+	for i, c := range cases {
+		assert.Equal(t, c.timeout, IsTimeout(c.err), "case %d: %v", i, c.err)
+		assert.Equal(t, c.auth, IsAuth(c.err), "case %d: %v", i, c.err)
+		assert.Equal(t, c.temporary, IsTemporary(c.err), "case %d: %v", i, c.err)
+	}
+}
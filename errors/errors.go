@@ -0,0 +1,166 @@
+// Package errors provides the structured errors with code, message, cause and stack,
+// which can be converted to and from grpc status and http responses.
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+)
+
+// Code the code of error
+type Code string
+
+// all codes, which are the same as grpc codes
+const (
+	CodeOK                 Code = "OK"
+	CodeCanceled           Code = "Canceled"
+	CodeUnknown            Code = "Unknown"
+	CodeInvalidArgument    Code = "InvalidArgument"
+	CodeDeadlineExceeded   Code = "DeadlineExceeded"
+	CodeNotFound           Code = "NotFound"
+	CodeAlreadyExists      Code = "AlreadyExists"
+	CodePermissionDenied   Code = "PermissionDenied"
+	CodeResourceExhausted  Code = "ResourceExhausted"
+	CodeFailedPrecondition Code = "FailedPrecondition"
+	CodeAborted            Code = "Aborted"
+	CodeOutOfRange         Code = "OutOfRange"
+	CodeUnimplemented      Code = "Unimplemented"
+	CodeInternal           Code = "Internal"
+	CodeUnavailable        Code = "Unavailable"
+	CodeDataLoss           Code = "DataLoss"
+	CodeUnauthenticated    Code = "Unauthenticated"
+)
+
+// Error the error with code, message, cause and stack
+type Error struct {
+	Code    Code
+	Message string
+	cause   error
+	stack   []uintptr
+}
+
+// New creates a new error with unknown code, like the standard errors.New
+func New(text string) error {
+	return newError(CodeUnknown, text, nil)
+}
+
+// Errorf creates a new error with unknown code, like fmt.Errorf
+func Errorf(format string, args ...interface{}) error {
+	return newError(CodeUnknown, fmt.Sprintf(format, args...), nil)
+}
+
+// Coded creates a new error with the code
+func Coded(code Code, format string, args ...interface{}) error {
+	return newError(code, fmt.Sprintf(format, args...), nil)
+}
+
+// Wrap wraps the error as the cause with the code and message, returns nil if the error is nil.
+// The message of the cause is used if the message is empty.
+func Wrap(err error, code Code, message string) error {
+	if err == nil {
+		return nil
+	}
+	if message == "" {
+		message = err.Error()
+	}
+	return newError(code, message, err)
+}
+
+func newError(code Code, message string, cause error) *Error {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+	return &Error{
+		Code:    code,
+		Message: message,
+		cause:   cause,
+		stack:   pcs[:n],
+	}
+}
+
+// Error returns the message, the code is not included to keep the message unchanged
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Unwrap returns the cause
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Cause returns the cause
+func (e *Error) Cause() error {
+	return e.cause
+}
+
+// StackTrace returns the stack where the error is created
+func (e *Error) StackTrace() string {
+	var b strings.Builder
+	frames := runtime.CallersFrames(e.stack)
+	for {
+		f, more := frames.Next()
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", f.Function, f.File, f.Line)
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
+
+// Format formats the error, %+v prints the code, message, stack and cause
+func (e *Error) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			fmt.Fprintf(s, "[%s] %s\n%s", e.Code, e.Message, e.StackTrace())
+			if e.cause != nil {
+				fmt.Fprintf(s, "caused by: %+v", e.cause)
+			}
+			return
+		}
+		fallthrough
+	case 's':
+		io.WriteString(s, e.Message)
+	case 'q':
+		fmt.Fprintf(s, "%q", e.Message)
+	}
+}
+
+// CodeOf returns the code of the error, which is the first code found in the chain of causes,
+// the code of grpc status error is also recognized
+func CodeOf(err error) Code {
+	if err == nil {
+		return CodeOK
+	}
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if ce, ok := e.(*Error); ok {
+			return ce.Code
+		}
+		if se, ok := e.(interface{ GRPCStatus() *grpcStatus }); ok {
+			return fromGRPCCode(se.GRPCStatus().Code())
+		}
+	}
+	return CodeUnknown
+}
+
+// HasCode checks whether the code of error is the code
+func HasCode(err error, code Code) bool {
+	return CodeOf(err) == code
+}
+
+// Is reports whether any error in err's chain matches target, the same as the standard errors.Is
+func Is(err, target error) bool {
+	return errors.Is(err, target)
+}
+
+// As finds the first error in err's chain that matches target, the same as the standard errors.As
+func As(err error, target interface{}) bool {
+	return errors.As(err, target)
+}
+
+// Unwrap returns the result of calling the Unwrap method on err, the same as the standard errors.Unwrap
+func Unwrap(err error) error {
+	return errors.Unwrap(err)
+}
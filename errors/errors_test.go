@@ -0,0 +1,93 @@
+package errors
+
+import (
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestError(t *testing.T) {
+	err := New("boom")
+	assert.EqualError(t, err, "boom")
+	assert.Equal(t, CodeUnknown, CodeOf(err))
+	assert.Equal(t, CodeOK, CodeOf(nil))
+	assert.Equal(t, CodeUnknown, CodeOf(stderrors.New("std")))
+
+	err = Coded(CodeNotFound, "key (%s) not found", "a")
+	assert.EqualError(t, err, "key (a) not found")
+	assert.True(t, HasCode(err, CodeNotFound))
+	assert.Equal(t, "key (a) not found", fmt.Sprintf("%v", err))
+	assert.Equal(t, `"key (a) not found"`, fmt.Sprintf("%q", err))
+	detail := fmt.Sprintf("%+v", err)
+	assert.True(t, strings.HasPrefix(detail, "[NotFound] key (a) not found\n"), detail)
+	assert.Contains(t, detail, "errors.TestError")
+
+	cause := stderrors.New("connection refused")
+	err = Wrap(cause, CodeUnavailable, "")
+	assert.EqualError(t, err, "connection refused")
+	assert.Equal(t, cause, Unwrap(err))
+	assert.Equal(t, cause, err.(*Error).Cause())
+	assert.True(t, Is(err, cause))
+	assert.Contains(t, fmt.Sprintf("%+v", err), "caused by: connection refused")
+	assert.Nil(t, Wrap(nil, CodeInternal, "x"))
+
+	// the first code in chain
+	err = fmt.Errorf("failed to connect: %w", Wrap(Coded(CodeUnauthenticated, "bad password"), CodeUnavailable, "unavailable"))
+	assert.Equal(t, CodeUnavailable, CodeOf(err))
+	var e *Error
+	assert.True(t, As(err, &e))
+	assert.Equal(t, "unavailable", e.Message)
+	assert.Equal(t, CodeUnknown, CodeOf(Errorf("%d", 1)))
+}
+
+func TestGRPC(t *testing.T) {
+	err := Coded(CodeNotFound, "not found")
+	s, ok := status.FromError(err)
+	assert.True(t, ok)
+	assert.Equal(t, codes.NotFound, s.Code())
+	assert.Equal(t, "not found", s.Message())
+	assert.Equal(t, codes.NotFound, status.Code(ToGRPC(err)))
+	assert.Equal(t, codes.Unknown, status.Code(ToGRPC(stderrors.New("x"))))
+	assert.Nil(t, ToGRPC(nil))
+
+	serr := status.Error(codes.PermissionDenied, "denied")
+	assert.Equal(t, CodePermissionDenied, CodeOf(serr))
+	err = FromGRPC(serr)
+	assert.EqualError(t, err, "denied")
+	assert.Equal(t, CodePermissionDenied, CodeOf(err))
+	assert.Equal(t, serr, Unwrap(err))
+	assert.Equal(t, CodeUnknown, CodeOf(FromGRPC(stderrors.New("x"))))
+	assert.Nil(t, FromGRPC(nil))
+}
+
+func TestHTTP(t *testing.T) {
+	assert.Equal(t, http.StatusNotFound, HTTPStatus(Coded(CodeNotFound, "")))
+	assert.Equal(t, http.StatusInternalServerError, HTTPStatus(stderrors.New("x")))
+	assert.Equal(t, http.StatusUnauthorized, HTTPStatus(status.Error(codes.Unauthenticated, "")))
+
+	w := httptest.NewRecorder()
+	WriteHTTP(w, Coded(CodeAlreadyExists, "app (a) exists"))
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, `{"code":"AlreadyExists","message":"app (a) exists"}`, w.Body.String())
+
+	err := FromHTTP(w.Code, w.Body.Bytes())
+	assert.EqualError(t, err, "app (a) exists")
+	assert.Equal(t, CodeAlreadyExists, CodeOf(err))
+
+	err = FromHTTP(http.StatusServiceUnavailable, []byte("busy\n"))
+	assert.EqualError(t, err, "busy")
+	assert.Equal(t, CodeUnavailable, CodeOf(err))
+	err = FromHTTP(http.StatusHTTPVersionNotSupported, nil)
+	assert.EqualError(t, err, "HTTP Version Not Supported")
+	assert.Equal(t, CodeInternal, CodeOf(err))
+	assert.Equal(t, CodeUnknown, CodeOf(FromHTTP(http.StatusTeapot, nil)))
+	assert.Nil(t, FromHTTP(http.StatusNoContent, nil))
+}
@@ -0,0 +1,75 @@
+package errors
+
+import (
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type grpcStatus = status.Status
+
+var grpcCodes = map[Code]codes.Code{
+	CodeOK:                 codes.OK,
+	CodeCanceled:           codes.Canceled,
+	CodeUnknown:            codes.Unknown,
+	CodeInvalidArgument:    codes.InvalidArgument,
+	CodeDeadlineExceeded:   codes.DeadlineExceeded,
+	CodeNotFound:           codes.NotFound,
+	CodeAlreadyExists:      codes.AlreadyExists,
+	CodePermissionDenied:   codes.PermissionDenied,
+	CodeResourceExhausted:  codes.ResourceExhausted,
+	CodeFailedPrecondition: codes.FailedPrecondition,
+	CodeAborted:            codes.Aborted,
+	CodeOutOfRange:         codes.OutOfRange,
+	CodeUnimplemented:      codes.Unimplemented,
+	CodeInternal:           codes.Internal,
+	CodeUnavailable:        codes.Unavailable,
+	CodeDataLoss:           codes.DataLoss,
+	CodeUnauthenticated:    codes.Unauthenticated,
+}
+
+// GRPCStatus returns the grpc status of the error, so that the error can be returned by grpc services directly
+func (e *Error) GRPCStatus() *status.Status {
+	return status.New(toGRPCCode(e.Code), e.Message)
+}
+
+// ToGRPC converts the error to grpc status error, returns nil if the error is nil
+func ToGRPC(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	return status.Error(toGRPCCode(CodeOf(err)), err.Error())
+}
+
+// FromGRPC converts the grpc status error to error with code, returns nil if the error is nil
+func FromGRPC(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*Error); ok {
+		return err
+	}
+	s, ok := status.FromError(err)
+	if !ok {
+		return Wrap(err, CodeUnknown, "")
+	}
+	return newError(fromGRPCCode(s.Code()), s.Message(), err)
+}
+
+func toGRPCCode(code Code) codes.Code {
+	if c, ok := grpcCodes[code]; ok {
+		return c
+	}
+	return codes.Unknown
+}
+
+func fromGRPCCode(code codes.Code) Code {
+	for k, v := range grpcCodes {
+		if v == code {
+			return k
+		}
+	}
+	return CodeUnknown
+}
@@ -0,0 +1,91 @@
+package errors
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+var httpStatuses = map[Code]int{
+	CodeOK:                 http.StatusOK,
+	CodeCanceled:           499,
+	CodeUnknown:            http.StatusInternalServerError,
+	CodeInvalidArgument:    http.StatusBadRequest,
+	CodeDeadlineExceeded:   http.StatusGatewayTimeout,
+	CodeNotFound:           http.StatusNotFound,
+	CodeAlreadyExists:      http.StatusConflict,
+	CodePermissionDenied:   http.StatusForbidden,
+	CodeResourceExhausted:  http.StatusTooManyRequests,
+	CodeFailedPrecondition: http.StatusPreconditionFailed,
+	CodeAborted:            http.StatusConflict,
+	CodeOutOfRange:         http.StatusBadRequest,
+	CodeUnimplemented:      http.StatusNotImplemented,
+	CodeInternal:           http.StatusInternalServerError,
+	CodeUnavailable:        http.StatusServiceUnavailable,
+	CodeDataLoss:           http.StatusInternalServerError,
+	CodeUnauthenticated:    http.StatusUnauthorized,
+}
+
+// codes converted from http status, which are not one to one
+var httpCodes = map[int]Code{
+	http.StatusBadRequest:            CodeInvalidArgument,
+	http.StatusUnauthorized:          CodeUnauthenticated,
+	http.StatusForbidden:             CodePermissionDenied,
+	http.StatusNotFound:              CodeNotFound,
+	http.StatusConflict:              CodeAlreadyExists,
+	http.StatusPreconditionFailed:    CodeFailedPrecondition,
+	http.StatusRequestEntityTooLarge: CodeResourceExhausted,
+	http.StatusTooManyRequests:       CodeResourceExhausted,
+	499:                              CodeCanceled,
+	http.StatusNotImplemented:        CodeUnimplemented,
+	http.StatusBadGateway:            CodeUnavailable,
+	http.StatusServiceUnavailable:    CodeUnavailable,
+	http.StatusGatewayTimeout:        CodeDeadlineExceeded,
+}
+
+// HTTPBody the body of http response of error
+type HTTPBody struct {
+	Code    Code   `json:"code"`
+	Message string `json:"message"`
+}
+
+// HTTPStatus returns the http status of the error
+func HTTPStatus(err error) int {
+	if s, ok := httpStatuses[CodeOf(err)]; ok {
+		return s
+	}
+	return http.StatusInternalServerError
+}
+
+// WriteHTTP writes the error to http response in json
+func WriteHTTP(w http.ResponseWriter, err error) {
+	body := HTTPBody{Code: CodeOf(err), Message: err.Error()}
+	data, _ := json.Marshal(body)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(HTTPStatus(err))
+	w.Write(data)
+}
+
+// FromHTTP creates the error from the http status and the body of response,
+// the code and message in body are used if the body is written by WriteHTTP
+func FromHTTP(status int, body []byte) error {
+	if status >= 200 && status < 300 {
+		return nil
+	}
+	var hb HTTPBody
+	if json.Unmarshal(body, &hb) == nil && hb.Code != "" {
+		return newError(hb.Code, hb.Message, nil)
+	}
+	code, ok := httpCodes[status]
+	if !ok {
+		code = CodeUnknown
+		if status >= 500 {
+			code = CodeInternal
+		}
+	}
+	msg := strings.TrimSpace(string(body))
+	if msg == "" {
+		msg = http.StatusText(status)
+	}
+	return newError(code, msg, nil)
+}
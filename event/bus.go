@@ -0,0 +1,121 @@
+package event
+
+import (
+	"errors"
+
+	"github.com/baetyl/baetyl-go/log"
+	"github.com/baetyl/baetyl-go/mqtt"
+	"github.com/baetyl/baetyl-go/pubsub"
+	"github.com/baetyl/baetyl-go/utils"
+)
+
+// ErrUnexpectedMessage the message received from the pubsub topic of event is not an event
+var ErrUnexpectedMessage = errors.New("message is not an event")
+
+// Sender sends the packet, such as mqtt.Client
+type Sender interface {
+	Send(pkt mqtt.Packet) error
+}
+
+// Bus the typed event bus over the internal pubsub, the events of each type are published to the pubsub topic
+// named by the mqtt system topic of the type, the topics of events should not be durable
+type Bus struct {
+	ps   *pubsub.Pubsub
+	node string
+}
+
+// NewBus creates a new bus over the pubsub, the node name is filled in the events published
+func NewBus(ps *pubsub.Pubsub, node string) *Bus {
+	return &Bus{ps: ps, node: node}
+}
+
+// Publish publishes the typed payload as a new event
+func (b *Bus) Publish(payload interface{}) error {
+	e, err := NewEvent(b.node, payload)
+	if err != nil {
+		return err
+	}
+	return b.PublishEvent(e)
+}
+
+// PublishEvent publishes the event
+func (b *Bus) PublishEvent(e *Event) error {
+	return b.ps.Publish(Topic(e.Type), e)
+}
+
+// Subscribe subscribes the events of the type, the messages received from the subscriber are *Event
+func (b *Bus) Subscribe(t Type) (*pubsub.Subscriber, error) {
+	return b.ps.Subscribe(Topic(t))
+}
+
+// Forwarder forwards the events of the bus onto the mqtt system topics
+type Forwarder struct {
+	subs []*pubsub.Subscriber
+	snd  Sender
+	qos  mqtt.QOS
+	tomb utils.Tomb
+	log  *log.Logger
+}
+
+// Forward starts to forward the events of the types to the sender, all types if no type specified
+func (b *Bus) Forward(snd Sender, qos mqtt.QOS, types ...Type) (*Forwarder, error) {
+	if len(types) == 0 {
+		types = []Type{TypeAppDeployed, TypeServiceCrashed, TypeOTAProgress, TypeCertRotated}
+	}
+	f := &Forwarder{
+		snd: snd,
+		qos: qos,
+		log: log.With(log.Any("event", "forwarder")),
+	}
+	for _, t := range types {
+		s, err := b.Subscribe(t)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		f.subs = append(f.subs, s)
+	}
+	fs := make([]func() error, 0, len(f.subs))
+	for _, s := range f.subs {
+		s := s
+		fs = append(fs, func() error {
+			return f.forwarding(s)
+		})
+	}
+	f.tomb.Go(fs...)
+	return f, nil
+}
+
+func (f *Forwarder) forwarding(s *pubsub.Subscriber) error {
+	for {
+		select {
+		case msg, ok := <-s.Channel():
+			if !ok {
+				return nil
+			}
+			e, ok := msg.(*Event)
+			if !ok {
+				f.log.Warn("failed to forward event", log.Any("topic", s.Topic()), log.Error(ErrUnexpectedMessage))
+				continue
+			}
+			pkt, err := ToPublish(e, f.qos)
+			if err == nil {
+				err = f.snd.Send(pkt)
+			}
+			if err != nil {
+				f.log.Warn("failed to forward event", log.Any("type", e.Type), log.Error(err))
+			}
+		case <-f.tomb.Dying():
+			return nil
+		}
+	}
+}
+
+// Close stops forwarding and unsubscribes all topics
+func (f *Forwarder) Close() error {
+	for _, s := range f.subs {
+		s.Close()
+	}
+	f.tomb.Kill(nil)
+	return f.tomb.Wait()
+}
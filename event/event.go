@@ -0,0 +1,164 @@
+// Package event defines the typed system events, such as app deployed, service crashed, ota progress and cert rotated,
+// which are published and subscribed over the internal pubsub, and serialized onto the mqtt system topics.
+package event
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/baetyl/baetyl-go/codec"
+	"github.com/baetyl/baetyl-go/mqtt"
+	"github.com/baetyl/baetyl-go/ota"
+)
+
+// Type the type of event
+type Type string
+
+// all event types
+const (
+	TypeAppDeployed    Type = "appDeployed"
+	TypeServiceCrashed Type = "serviceCrashed"
+	TypeOTAProgress    Type = "otaProgress"
+	TypeCertRotated    Type = "certRotated"
+)
+
+// TopicPrefix the prefix of mqtt system topics of events, the topic of event is the prefix followed by its type,
+// such as $baetyl/event/appDeployed
+const TopicPrefix = "$baetyl/event/"
+
+// AppDeployed the payload of event sent after the application is deployed
+type AppDeployed struct {
+	App       string `json:"app"`
+	Version   string `json:"version,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// ServiceCrashed the payload of event sent after the instance of service exited unexpectedly
+type ServiceCrashed struct {
+	App      string `json:"app"`
+	Service  string `json:"service"`
+	Instance int    `json:"instance"`
+	ExitCode int    `json:"exitCode"`
+	Restarts int    `json:"restarts"`
+	Error    string `json:"error,omitempty"`
+}
+
+// OTAProgress the payload of event sent after the phase or the downloaded bytes of update changed
+type OTAProgress struct {
+	Task       string    `json:"task"`
+	Version    string    `json:"version,omitempty"`
+	Phase      ota.Phase `json:"phase"`
+	Downloaded int64     `json:"downloaded"`
+	Total      int64     `json:"total,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// CertRotated the payload of event sent after the certificate is renewed
+type CertRotated struct {
+	Name     string    `json:"name"`
+	Serial   string    `json:"serial,omitempty"`
+	NotAfter time.Time `json:"notAfter"`
+}
+
+// Event the envelope of event, the payload is one of the typed payloads above according to the type,
+// or json.RawMessage if the type is unknown when decoded
+type Event struct {
+	Type    Type        `json:"type"`
+	Node    string      `json:"node,omitempty"`
+	Time    time.Time   `json:"time"`
+	Payload interface{} `json:"payload,omitempty"`
+}
+
+// NewEvent creates a new event of the typed payload occurred now
+func NewEvent(node string, payload interface{}) (*Event, error) {
+	t, err := TypeOf(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &Event{Type: t, Node: node, Time: time.Now().UTC(), Payload: payload}, nil
+}
+
+// TypeOf returns the event type of the typed payload
+func TypeOf(payload interface{}) (Type, error) {
+	switch payload.(type) {
+	case *AppDeployed, AppDeployed:
+		return TypeAppDeployed, nil
+	case *ServiceCrashed, ServiceCrashed:
+		return TypeServiceCrashed, nil
+	case *OTAProgress, OTAProgress:
+		return TypeOTAProgress, nil
+	case *CertRotated, CertRotated:
+		return TypeCertRotated, nil
+	default:
+		return "", fmt.Errorf("payload type (%T) not supported", payload)
+	}
+}
+
+func newPayload(t Type) interface{} {
+	switch t {
+	case TypeAppDeployed:
+		return &AppDeployed{}
+	case TypeServiceCrashed:
+		return &ServiceCrashed{}
+	case TypeOTAProgress:
+		return &OTAProgress{}
+	case TypeCertRotated:
+		return &CertRotated{}
+	default:
+		return nil
+	}
+}
+
+// UnmarshalJSON decodes the event and its payload typed according to the type
+func (e *Event) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Type    Type            `json:"type"`
+		Node    string          `json:"node"`
+		Time    time.Time       `json:"time"`
+		Payload json.RawMessage `json:"payload"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	e.Type, e.Node, e.Time, e.Payload = raw.Type, raw.Node, raw.Time, nil
+	if len(raw.Payload) == 0 {
+		return nil
+	}
+	p := newPayload(raw.Type)
+	if p == nil {
+		e.Payload = raw.Payload
+		return nil
+	}
+	if err := json.Unmarshal(raw.Payload, p); err != nil {
+		return err
+	}
+	e.Payload = p
+	return nil
+}
+
+// Topic returns the mqtt system topic of the event type
+func Topic(t Type) string {
+	return TopicPrefix + string(t)
+}
+
+// ToPublish serializes the event onto its mqtt system topic
+func ToPublish(e *Event, qos mqtt.QOS) (*mqtt.Publish, error) {
+	return mqtt.NewPublishPayload(Topic(e.Type), qos, codec.JSON, e)
+}
+
+// FromPublish deserializes the event from the message of mqtt system topic
+func FromPublish(pkt *mqtt.Publish) (*Event, error) {
+	if !strings.HasPrefix(pkt.Message.Topic, TopicPrefix) {
+		return nil, fmt.Errorf("topic (%s) is not an event topic", pkt.Message.Topic)
+	}
+	var e Event
+	if err := mqtt.DecodePayload(pkt, codec.JSON, &e); err != nil {
+		return nil, err
+	}
+	if e.Type == "" {
+		e.Type = Type(strings.TrimPrefix(pkt.Message.Topic, TopicPrefix))
+	}
+	return &e, nil
+}
@@ -0,0 +1,116 @@
+package event
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/baetyl/baetyl-go/mqtt"
+	"github.com/baetyl/baetyl-go/ota"
+	"github.com/baetyl/baetyl-go/pubsub"
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestBus(t *testing.T) (*Bus, *pubsub.Pubsub) {
+	var cfg pubsub.Config
+	assert.NoError(t, utils.SetDefaults(&cfg))
+	ps, err := pubsub.NewPubsub(cfg)
+	assert.NoError(t, err)
+	return NewBus(ps, "node1"), ps
+}
+
+type fakeSender struct {
+	pkts []*mqtt.Publish
+	mu   sync.Mutex
+}
+
+func (s *fakeSender) Send(pkt mqtt.Packet) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pkts = append(s.pkts, pkt.(*mqtt.Publish))
+	return nil
+}
+
+func (s *fakeSender) published() []*mqtt.Publish {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*mqtt.Publish{}, s.pkts...)
+}
+
+func TestEventCodec(t *testing.T) {
+	_, err := NewEvent("node1", "x")
+	assert.EqualError(t, err, "payload type (string) not supported")
+
+	e, err := NewEvent("node1", &OTAProgress{Task: "t1", Phase: ota.PhaseDownloading, Downloaded: 10, Total: 100})
+	assert.NoError(t, err)
+	assert.Equal(t, TypeOTAProgress, e.Type)
+
+	pkt, err := ToPublish(e, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "$baetyl/event/otaProgress", pkt.Message.Topic)
+	assert.Equal(t, mqtt.QOS(1), pkt.Message.QOS)
+
+	got, err := FromPublish(pkt)
+	assert.NoError(t, err)
+	assert.Equal(t, "node1", got.Node)
+	assert.True(t, e.Time.Equal(got.Time))
+	assert.Equal(t, e.Payload, got.Payload)
+
+	pkt.Message.Topic = "a/b"
+	_, err = FromPublish(pkt)
+	assert.EqualError(t, err, "topic (a/b) is not an event topic")
+
+	var unknown Event
+	assert.NoError(t, json.Unmarshal([]byte(`{"type":"custom","payload":{"a":1}}`), &unknown))
+	assert.Equal(t, json.RawMessage(`{"a":1}`), unknown.Payload)
+	assert.Error(t, json.Unmarshal([]byte(`{"type":"certRotated","payload":{"name":1}}`), &unknown))
+}
+
+func TestBus(t *testing.T) {
+	b, ps := newTestBus(t)
+	defer ps.Close()
+
+	s, err := b.Subscribe(TypeServiceCrashed)
+	assert.NoError(t, err)
+	defer s.Close()
+	assert.NoError(t, b.Publish(&AppDeployed{App: "app", Version: "1"}))
+	assert.NoError(t, b.Publish(&ServiceCrashed{App: "app", Service: "svc", ExitCode: 1, Restarts: 2}))
+	assert.Error(t, b.Publish(1))
+
+	select {
+	case msg := <-s.Channel():
+		e := msg.(*Event)
+		assert.Equal(t, TypeServiceCrashed, e.Type)
+		assert.Equal(t, "node1", e.Node)
+		assert.Equal(t, &ServiceCrashed{App: "app", Service: "svc", ExitCode: 1, Restarts: 2}, e.Payload)
+	case <-time.After(time.Second):
+		t.Fatal("event not received")
+	}
+}
+
+func TestForwarder(t *testing.T) {
+	b, ps := newTestBus(t)
+	defer ps.Close()
+
+	snd := &fakeSender{}
+	f, err := b.Forward(snd, 0, TypeCertRotated, TypeAppDeployed)
+	assert.NoError(t, err)
+	assert.NoError(t, b.Publish(&CertRotated{Name: "server", Serial: "01"}))
+	assert.NoError(t, b.Publish(&OTAProgress{Task: "t1"}))
+	assert.NoError(t, b.Publish(&AppDeployed{App: "app"}))
+	assert.NoError(t, ps.Publish(Topic(TypeAppDeployed), "bad"))
+
+	assert.Eventually(t, func() bool { return len(snd.published()) == 2 }, time.Second, 10*time.Millisecond)
+	topics := map[string]bool{}
+	for _, pkt := range snd.published() {
+		topics[pkt.Message.Topic] = true
+	}
+	assert.Equal(t, map[string]bool{"$baetyl/event/certRotated": true, "$baetyl/event/appDeployed": true}, topics)
+	assert.NoError(t, f.Close())
+
+	assert.NoError(t, b.Publish(&AppDeployed{App: "app"}))
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, snd.published(), 2)
+}
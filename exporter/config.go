@@ -0,0 +1,42 @@
+package exporter
+
+import (
+	"github.com/baetyl/baetyl-go/http"
+	"github.com/baetyl/baetyl-go/mqtt"
+	"github.com/baetyl/baetyl-go/upload"
+)
+
+// all auth types of http endpoint
+const (
+	AuthNone   = "none"
+	AuthBasic  = "basic"
+	AuthBearer = "bearer"
+	AuthAPIKey = "apikey"
+)
+
+// Config the config of exporter, which forwards the mqtt messages to http endpoints
+type Config struct {
+	MQTT      mqtt.ClientConfig `yaml:"mqtt" json:"mqtt"`
+	Endpoints []EndpointConfig  `yaml:"endpoints" json:"endpoints"`
+}
+
+// EndpointConfig the config of http endpoint, the messages of topics are posted to the path in batches,
+// the batch is retried with backoff until succeeded
+type EndpointConfig struct {
+	Name    string            `yaml:"name" json:"name" validate:"nonzero"`
+	Topics  []mqtt.QOSTopic   `yaml:"topics" json:"topics"`
+	Path    string            `yaml:"path" json:"path" default:"/"`
+	Headers map[string]string `yaml:"headers" json:"headers"`
+	Auth    AuthConfig        `yaml:"auth" json:"auth"`
+	Client  http.ClientConfig `yaml:"client" json:"client"`
+	Batch   upload.Config     `yaml:"batch" json:"batch"`
+}
+
+// AuthConfig the config of authentication to http endpoint
+type AuthConfig struct {
+	Type     string `yaml:"type" json:"type" default:"none" validate:"regexp=^(none|basic|bearer|apikey)$"`
+	Username string `yaml:"username" json:"username"`
+	Password string `yaml:"password" json:"password"`
+	Token    string `yaml:"token" json:"token"`                       // the token of bearer or api key
+	Header   string `yaml:"header" json:"header" default:"X-Api-Key"` // the header of api key
+}
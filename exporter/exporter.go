@@ -0,0 +1,192 @@
+// Package exporter subscribes the mqtt topics and forwards the messages to http endpoints,
+// such as the ingestion api in cloud which only accepts rest, with batching, retry and authentication.
+package exporter
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	gohttp "net/http"
+	"strconv"
+	"time"
+
+	"github.com/baetyl/baetyl-go/http"
+	"github.com/baetyl/baetyl-go/log"
+	"github.com/baetyl/baetyl-go/mqtt"
+	"github.com/baetyl/baetyl-go/upload"
+)
+
+// Record the record of mqtt message in batch, the payload is embedded if it is json, otherwise it is encoded in base64 as data
+type Record struct {
+	Topic     string          `json:"topic"`
+	QOS       uint32          `json:"qos"`
+	Timestamp int64           `json:"timestamp"` // the time received in milliseconds
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	Data      []byte          `json:"data,omitempty"`
+}
+
+// NewRecord creates a new record of the message
+func NewRecord(msg *mqtt.Message) *Record {
+	r := &Record{
+		Topic:     msg.Topic,
+		QOS:       uint32(msg.QOS),
+		Timestamp: time.Now().UnixNano() / int64(time.Millisecond),
+	}
+	if len(msg.Payload) > 0 && json.Valid(msg.Payload) {
+		r.Payload = msg.Payload
+	} else {
+		r.Data = msg.Payload
+	}
+	return r
+}
+
+// NewHTTPSender creates a sender which posts the batch of records to the path with the headers and authentication
+func NewHTTPSender(cli *http.Client, path string, headers map[string]string, auth AuthConfig) upload.Sender {
+	header := map[string]string{}
+	for k, v := range headers {
+		header[k] = v
+	}
+	switch auth.Type {
+	case AuthBasic:
+		header[http.HeaderAuthorization] = "Basic " + base64.StdEncoding.EncodeToString([]byte(auth.Username+":"+auth.Password))
+	case AuthBearer:
+		header[http.HeaderAuthorization] = "Bearer " + auth.Token
+	case AuthAPIKey:
+		header[auth.Header] = auth.Token
+	}
+	header[http.HeaderContentType] = http.ContentTypeJSON
+	return upload.SenderFunc(func(ctx context.Context, b *upload.Batch) error {
+		h := make(map[string]string, len(header)+2)
+		for k, v := range header {
+			h[k] = v
+		}
+		h[upload.HeaderBatchCount] = strconv.Itoa(b.Count)
+		if b.Encoding != "" {
+			h[upload.HeaderContentEncoding] = b.Encoding
+		}
+		_, err := cli.CallContext(ctx, gohttp.MethodPost, path, b.Data, h)
+		return err
+	})
+}
+
+type endpoint struct {
+	cfg  EndpointConfig
+	cli  *http.Client
+	up   *upload.Uploader
+	trie *mqtt.Trie
+}
+
+// Exporter the exporter which subscribes the topics of all endpoints by one mqtt client
+type Exporter struct {
+	eps []*endpoint
+	cli *mqtt.Client
+	log *log.Logger
+}
+
+// NewExporter creates a new exporter, which connects to the broker and subscribes the topics of endpoints
+func NewExporter(cfg Config) (*Exporter, error) {
+	e := &Exporter{log: log.With(log.Any("exporter", "mqtt2http"))}
+	var subs []mqtt.Subscription
+	for _, ec := range cfg.Endpoints {
+		ep, err := newEndpoint(ec)
+		if err != nil {
+			e.close()
+			return nil, fmt.Errorf("endpoint (%s) is invalid: %s", ec.Name, err.Error())
+		}
+		e.eps = append(e.eps, ep)
+		for _, t := range ec.Topics {
+			subs = append(subs, mqtt.Subscription{Topic: t.Topic, QOS: mqtt.QOS(t.QOS)})
+		}
+	}
+	if len(subs) == 0 {
+		e.close()
+		return nil, fmt.Errorf("no topic to export")
+	}
+	cli, err := mqtt.NewClient(cfg.MQTT, mqtt.NewObserverWrapper(e.OnPublish, nil, e.onError))
+	if err != nil {
+		e.close()
+		return nil, err
+	}
+	if err = cli.Subscribe(subs); err != nil {
+		cli.Close()
+		e.close()
+		return nil, err
+	}
+	e.cli = cli
+	return e, nil
+}
+
+func newEndpoint(cfg EndpointConfig) (*endpoint, error) {
+	if cfg.Auth.Type == AuthAPIKey && cfg.Auth.Header == "" {
+		return nil, fmt.Errorf("header of api key is required")
+	}
+	ep := &endpoint{cfg: cfg, trie: mqtt.NewTrie()}
+	for _, t := range cfg.Topics {
+		if !mqtt.CheckTopic(t.Topic, true) {
+			return nil, fmt.Errorf("topic (%s) is invalid", t.Topic)
+		}
+		ep.trie.Add(t.Topic, ep)
+	}
+	cli, err := http.NewClient(cfg.Client)
+	if err != nil {
+		return nil, err
+	}
+	up, err := upload.NewUploader(cfg.Batch, NewHTTPSender(cli, cfg.Path, cfg.Headers, cfg.Auth))
+	if err != nil {
+		cli.Close()
+		return nil, err
+	}
+	ep.cli, ep.up = cli, up
+	return ep, nil
+}
+
+// OnPublish adds the message into the batches of endpoints whose topics match
+func (e *Exporter) OnPublish(pkt *mqtt.Publish) error {
+	var rec *Record
+	for _, ep := range e.eps {
+		if len(ep.trie.Match(pkt.Message.Topic)) == 0 {
+			continue
+		}
+		if rec == nil {
+			rec = NewRecord(&pkt.Message)
+		}
+		if err := ep.up.Add(rec); err != nil {
+			return fmt.Errorf("endpoint (%s): %w", ep.cfg.Name, err)
+		}
+	}
+	return nil
+}
+
+func (e *Exporter) onError(err error) {
+	e.log.Error("error occurs", log.Error(err))
+}
+
+// Flush closes the current batches of all endpoints immediately
+func (e *Exporter) Flush() error {
+	for _, ep := range e.eps {
+		if err := ep.up.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the mqtt client and the endpoints, the batches pending in memory are dropped
+func (e *Exporter) Close() error {
+	var err error
+	if e.cli != nil {
+		err = e.cli.Close()
+	}
+	e.close()
+	return err
+}
+
+func (e *Exporter) close() {
+	for _, ep := range e.eps {
+		if err := ep.up.Close(); err != nil {
+			e.log.Warn("failed to close uploader", log.Any("endpoint", ep.cfg.Name), log.Error(err))
+		}
+		ep.cli.Close()
+	}
+}
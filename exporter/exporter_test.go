@@ -0,0 +1,152 @@
+package exporter
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	gohttp "net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/baetyl/baetyl-go/log"
+	"github.com/baetyl/baetyl-go/mqtt"
+	"github.com/baetyl/baetyl-go/upload"
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestPublish(topic, payload string) *mqtt.Publish {
+	pkt := mqtt.NewPublish()
+	pkt.Message.Topic = topic
+	pkt.Message.Payload = []byte(payload)
+	return pkt
+}
+
+type request struct {
+	header gohttp.Header
+	batch  *upload.Batch
+}
+
+func newTestServer(t *testing.T, failures int32) (*httptest.Server, chan request) {
+	reqs := make(chan request, 10)
+	var n int32
+	svr := httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		if atomic.AddInt32(&n, 1) <= failures {
+			w.WriteHeader(gohttp.StatusServiceUnavailable)
+			return
+		}
+		data, err := ioutil.ReadAll(r.Body)
+		assert.NoError(t, err)
+		reqs <- request{header: r.Header, batch: &upload.Batch{Encoding: r.Header.Get(upload.HeaderContentEncoding), Data: data}}
+	}))
+	return svr, reqs
+}
+
+func TestExporter(t *testing.T) {
+	svr1, reqs1 := newTestServer(t, 1)
+	defer svr1.Close()
+	svr2, reqs2 := newTestServer(t, 0)
+	defer svr2.Close()
+
+	in := `
+endpoints:
+- name: telemetry
+  topics:
+  - topic: sensor/+/data
+    qos: 1
+  path: /ingest
+  headers:
+    X-Tenant: t1
+  auth:
+    type: bearer
+    token: secret
+  batch:
+    maxCount: 3
+    interval: 10s
+- name: events
+  topics:
+  - topic: sensor/+/event
+  - topic: sensor/d1/#
+  auth:
+    type: apikey
+    token: key
+  batch:
+    maxCount: 1
+    compression: none
+`
+	var cfg Config
+	assert.NoError(t, utils.UnmarshalYAML([]byte(in), &cfg))
+	assert.Equal(t, "/", cfg.Endpoints[1].Path)
+	assert.Equal(t, "X-Api-Key", cfg.Endpoints[1].Auth.Header)
+	cfg.Endpoints[0].Client.Address = svr1.URL
+	cfg.Endpoints[0].Client.MaxRetries = 0
+	cfg.Endpoints[1].Client.Address = svr2.URL
+
+	e := &Exporter{log: log.With(log.Any("exporter", "mqtt2http"))}
+	for _, ec := range cfg.Endpoints {
+		ep, err := newEndpoint(ec)
+		assert.NoError(t, err)
+		e.eps = append(e.eps, ep)
+	}
+	defer e.Close()
+
+	assert.NoError(t, e.OnPublish(newTestPublish("sensor/d1/data", `{"temp":25}`)))
+	assert.NoError(t, e.OnPublish(newTestPublish("sensor/d2/data", `binary`)))
+	assert.NoError(t, e.OnPublish(newTestPublish("sensor/d2/event", `{"alarm":true}`)))
+	assert.NoError(t, e.OnPublish(newTestPublish("other", `{}`)))
+
+	// exported once even if matched by two topics
+	req := <-reqs2
+	assert.Equal(t, "key", req.header.Get("X-Api-Key"))
+	assert.Equal(t, "1", req.header.Get(upload.HeaderBatchCount))
+	var recs []Record
+	assert.NoError(t, json.Unmarshal(req.batch.Data, &recs))
+	assert.Len(t, recs, 1)
+	assert.Equal(t, "sensor/d1/data", recs[0].Topic)
+	req = <-reqs2
+	assert.NoError(t, json.Unmarshal(req.batch.Data, &recs))
+	assert.Equal(t, "sensor/d2/event", recs[0].Topic)
+	assert.JSONEq(t, `{"alarm":true}`, string(recs[0].Payload))
+	assert.NotZero(t, recs[0].Timestamp)
+
+	// retried after the endpoint fails
+	assert.NoError(t, e.Flush())
+	select {
+	case req = <-reqs1:
+	case <-time.After(5 * time.Second):
+		t.Fatal("batch is not retried")
+	}
+	assert.Equal(t, "Bearer secret", req.header.Get("Authorization"))
+	assert.Equal(t, "t1", req.header.Get("X-Tenant"))
+	assert.Equal(t, "gzip", req.header.Get("Content-Encoding"))
+	msgs, err := req.batch.Decode()
+	assert.NoError(t, err)
+	assert.Len(t, msgs, 2)
+	var rec Record
+	assert.NoError(t, json.Unmarshal(msgs[0], &rec))
+	assert.Equal(t, "sensor/d1/data", rec.Topic)
+	assert.JSONEq(t, `{"temp":25}`, string(rec.Payload))
+	assert.NoError(t, json.Unmarshal(msgs[1], &rec))
+	assert.Equal(t, "binary", string(rec.Data))
+}
+
+func TestNewExporter(t *testing.T) {
+	var cfg Config
+	assert.NoError(t, utils.SetDefaults(&cfg))
+	_, err := NewExporter(cfg)
+	assert.EqualError(t, err, "no topic to export")
+
+	ec := EndpointConfig{Name: "a", Topics: []mqtt.QOSTopic{{Topic: "a/#/b"}}}
+	assert.NoError(t, utils.SetDefaults(&ec))
+	cfg.Endpoints = []EndpointConfig{ec}
+	_, err = NewExporter(cfg)
+	assert.EqualError(t, err, "endpoint (a) is invalid: topic (a/#/b) is invalid")
+
+	// the broker is connected in background
+	cfg.Endpoints[0].Topics[0].Topic = "a/#"
+	cfg.MQTT.Address = "tcp://127.0.0.1:1"
+	e, err := NewExporter(cfg)
+	assert.NoError(t, err)
+	assert.NoError(t, e.Close())
+}
@@ -0,0 +1,76 @@
+package faas
+
+import (
+	"context"
+	"io"
+
+	"github.com/baetyl/baetyl-go/link"
+	"google.golang.org/grpc"
+)
+
+// Client client of function server
+type Client struct {
+	cfg  ClientConfig
+	cli  FunctionClient
+	conn *grpc.ClientConn
+}
+
+// NewClient creates a new client of function server
+func NewClient(cc ClientConfig) (*Client, error) {
+	conn, err := link.NewClientConn(link.ClientConfig{
+		Address:        cc.Address,
+		Username:       cc.Username,
+		Password:       cc.Password,
+		Certificate:    cc.Certificate,
+		MaxMessageSize: cc.MaxMessageSize,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		cfg:  cc,
+		cli:  NewFunctionClient(conn),
+		conn: conn,
+	}, nil
+}
+
+// Invoke invokes the function synchronously, the timeout configured is applied
+func (c *Client) Invoke(msg *Message) (*Message, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.Timeout)
+	defer cancel()
+	return c.InvokeContext(ctx, msg)
+}
+
+// InvokeContext invokes the function with context synchronously
+func (c *Client) InvokeContext(ctx context.Context, msg *Message) (*Message, error) {
+	return c.cli.Invoke(ctx, msg, grpc.WaitForReady(true))
+}
+
+// InvokeStream invokes the function which responds a stream of messages,
+// the handler is called for each message until the stream ends or the handler returns error
+func (c *Client) InvokeStream(ctx context.Context, msg *Message, handler func(*Message) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := c.cli.InvokeStream(ctx, msg, grpc.WaitForReady(true))
+	if err != nil {
+		return err
+	}
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		err = handler(res)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Close closes the client
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
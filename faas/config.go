@@ -0,0 +1,33 @@
+package faas
+
+import (
+	"time"
+
+	"github.com/baetyl/baetyl-go/link"
+	"github.com/baetyl/baetyl-go/log"
+	"github.com/baetyl/baetyl-go/utils"
+)
+
+// ServerConfig function server config
+type ServerConfig struct {
+	link.ServerConfig `yaml:",inline" json:",inline"`
+}
+
+// ClientConfig function client config
+type ClientConfig struct {
+	Address        string            `yaml:"address" json:"address"`
+	Username       string            `yaml:"username" json:"username"`
+	Password       string            `yaml:"password" json:"password"`
+	Certificate    utils.Certificate `yaml:",inline" json:",inline"`
+	Timeout        time.Duration     `yaml:"timeout" json:"timeout" default:"30s"`
+	MaxMessageSize utils.Size        `yaml:"maxMessageSize" json:"maxMessageSize" default:"4194304"`
+}
+
+// RuntimeConfig function runtime config
+type RuntimeConfig struct {
+	Server         ServerConfig  `yaml:"server" json:"server"`
+	Timeout        time.Duration `yaml:"timeout" json:"timeout" default:"30s"`                         // the timeout of each invocation
+	Concurrency    int           `yaml:"concurrency" json:"concurrency" default:"10" validate:"min=1"` // the max concurrent invocations
+	ReportInterval time.Duration `yaml:"reportInterval" json:"reportInterval" default:"1m"`            // the interval to report stats in log, not report if 0
+	Logger         log.Config    `yaml:"logger" json:"logger"`
+}
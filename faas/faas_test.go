@@ -0,0 +1,119 @@
+package faas
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/baetyl/baetyl-go/link"
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/creasty/defaults"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type mockAuth map[string]string
+
+func (ma mockAuth) Authenticate(ctx context.Context) error {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return link.ErrUnauthenticated
+	}
+	u, p := md[link.KeyUsername], md[link.KeyPassword]
+	if len(u) != 1 || len(p) != 1 || ma[u[0]] != p[0] {
+		return link.ErrUnauthenticated
+	}
+	return nil
+}
+
+type mockFunction struct{}
+
+func (mockFunction) Invoke(ctx context.Context, msg *Message) (*Message, error) {
+	if msg.Name != "echo" {
+		return nil, status.Errorf(codes.NotFound, "function (%s) not found", msg.Name)
+	}
+	return msg, nil
+}
+
+func (mockFunction) InvokeStream(msg *Message, stream Function_InvokeStreamServer) error {
+	for i := 0; i < 3; i++ {
+		err := stream.Send(&Message{ID: msg.ID, Payload: []byte(fmt.Sprintf("%s-%d", msg.Payload, i))})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newTestServer(t *testing.T) (*grpc.Server, string) {
+	var sc ServerConfig
+	assert.NoError(t, defaults.Set(&sc))
+	sc.Certificate = utils.Certificate{
+		CA:   "../example/var/lib/baetyl/testcert/ca.pem",
+		Key:  "../example/var/lib/baetyl/testcert/server.key",
+		Cert: "../example/var/lib/baetyl/testcert/server.pem",
+	}
+	svr, err := NewServer(sc, mockFunction{}, mockAuth{"u1": "p1"})
+	assert.NoError(t, err)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go svr.Serve(lis)
+	return svr, lis.Addr().String()
+}
+
+func newTestClientConfig(addr string) ClientConfig {
+	var cc ClientConfig
+	defaults.Set(&cc)
+	cc.Address = addr
+	cc.Username = "u1"
+	cc.Password = "p1"
+	cc.Certificate = utils.Certificate{
+		CA:                 "../example/var/lib/baetyl/testcert/ca.pem",
+		Key:                "../example/var/lib/baetyl/testcert/client.key",
+		Cert:               "../example/var/lib/baetyl/testcert/client.pem",
+		InsecureSkipVerify: true,
+	}
+	return cc
+}
+
+func TestFunction(t *testing.T) {
+	svr, addr := newTestServer(t)
+	defer svr.Stop()
+
+	cli, err := NewClient(newTestClientConfig(addr))
+	assert.NoError(t, err)
+	defer cli.Close()
+
+	msg := &Message{ID: 1, Name: "echo", Metadata: map[string]string{"topic": "t"}, Payload: []byte("hi")}
+	res, err := cli.Invoke(msg)
+	assert.NoError(t, err)
+	assert.Equal(t, msg, res)
+
+	_, err = cli.Invoke(&Message{Name: "x"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	var payloads []string
+	err = cli.InvokeStream(context.Background(), msg, func(m *Message) error {
+		payloads = append(payloads, string(m.Payload))
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"hi-0", "hi-1", "hi-2"}, payloads)
+
+	err = cli.InvokeStream(context.Background(), msg, func(m *Message) error {
+		return fmt.Errorf("stop")
+	})
+	assert.EqualError(t, err, "stop")
+
+	cc := newTestClientConfig(addr)
+	cc.Password = "p2"
+	cli2, err := NewClient(cc)
+	assert.NoError(t, err)
+	defer cli2.Close()
+	_, err = cli2.Invoke(msg)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
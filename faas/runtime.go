@@ -0,0 +1,257 @@
+package faas
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	bcontext "github.com/baetyl/baetyl-go/context"
+	"github.com/baetyl/baetyl-go/log"
+	"github.com/baetyl/baetyl-go/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// EnvKeyConfFile the env key of the config file path of function runtime
+const EnvKeyConfFile = "BAETYL_CONF_FILE"
+
+// DefaultAddress the address function runtime listens by default
+const DefaultAddress = "0.0.0.0:50051"
+
+// Handler the handler of function
+type Handler func(context.Context, *Message) (*Message, error)
+
+// Stats the stats of a function
+type Stats struct {
+	Invocations uint64        `json:"invocations"`
+	Errors      uint64        `json:"errors"`
+	Timeouts    uint64        `json:"timeouts"`
+	Rejections  uint64        `json:"rejections"` // rejected since the concurrency limit is reached
+	Running     int64         `json:"running"`
+	Duration    time.Duration `json:"duration"` // the total duration of all invocations
+}
+
+// Runtime the function runtime which serves the invocations of handlers
+type Runtime struct {
+	cfg      RuntimeConfig
+	handlers map[string]Handler
+	sem      chan struct{}
+	stats    map[string]*Stats
+	mu       sync.Mutex
+	log      *log.Logger
+}
+
+// NewRuntime creates a new function runtime
+func NewRuntime(cfg RuntimeConfig, handlers map[string]Handler) (*Runtime, error) {
+	if len(handlers) == 0 {
+		return nil, fmt.Errorf("no function handler")
+	}
+	if cfg.Concurrency < 1 {
+		cfg.Concurrency = 1
+	}
+	r := &Runtime{
+		cfg:      cfg,
+		handlers: handlers,
+		sem:      make(chan struct{}, cfg.Concurrency),
+		stats:    map[string]*Stats{},
+		log:      log.With(log.Any("faas", "runtime")),
+	}
+	for name := range handlers {
+		r.stats[name] = &Stats{}
+	}
+	return r, nil
+}
+
+// Invoke invokes the handler of function by name, the only handler is invoked if name is empty
+func (r *Runtime) Invoke(ctx context.Context, msg *Message) (*Message, error) {
+	name := msg.Name
+	if name == "" && len(r.handlers) == 1 {
+		for n := range r.handlers {
+			name = n
+		}
+	}
+	h, ok := r.handlers[name]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "function (%s) not found", msg.Name)
+	}
+	if r.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.cfg.Timeout)
+		defer cancel()
+	}
+
+	select {
+	case r.sem <- struct{}{}:
+	case <-ctx.Done():
+		r.update(name, func(s *Stats) { s.Rejections++ })
+		return nil, status.Errorf(codes.ResourceExhausted, "function (%s) is busy", name)
+	}
+	defer func() { <-r.sem }()
+
+	r.update(name, func(s *Stats) { s.Running++ })
+	start := time.Now()
+	res, err := r.call(ctx, h, msg)
+	r.update(name, func(s *Stats) {
+		s.Running--
+		s.Invocations++
+		s.Duration += time.Since(start)
+		if err != nil {
+			s.Errors++
+			if status.Code(err) == codes.DeadlineExceeded {
+				s.Timeouts++
+			}
+		}
+	})
+	if err != nil {
+		r.log.Debug("failed to invoke function", log.Any("function", name), log.Error(err))
+	}
+	return res, err
+}
+
+// InvokeStream invokes the handler of function, and sends the result as a stream with only one message
+func (r *Runtime) InvokeStream(msg *Message, stream Function_InvokeStreamServer) error {
+	res, err := r.Invoke(stream.Context(), msg)
+	if err != nil {
+		return err
+	}
+	if res == nil {
+		return nil
+	}
+	return stream.Send(res)
+}
+
+// Stats returns the stats of all functions
+func (r *Runtime) Stats() map[string]Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res := map[string]Stats{}
+	for n, s := range r.stats {
+		res[n] = *s
+	}
+	return res
+}
+
+func (r *Runtime) call(ctx context.Context, h Handler, msg *Message) (*Message, error) {
+	type result struct {
+		msg *Message
+		err error
+	}
+	// the handler keeps running in background after timeout, since it can not be interrupted
+	ch := make(chan result, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				r.log.Error("function panics", log.Any("function", msg.Name), log.Any("panic", p), log.Any("stack", string(debug.Stack())))
+				ch <- result{err: status.Errorf(codes.Internal, "function panics: %v", p)}
+			}
+		}()
+		res, err := h(ctx, msg)
+		if err != nil {
+			if _, ok := status.FromError(err); !ok {
+				err = status.Error(codes.Unknown, err.Error())
+			}
+		}
+		ch <- result{msg: res, err: err}
+	}()
+	select {
+	case res := <-ch:
+		return res.msg, res.err
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, status.Errorf(codes.DeadlineExceeded, "function (%s) timed out", msg.Name)
+		}
+		return nil, status.Error(codes.Canceled, ctx.Err().Error())
+	}
+}
+
+func (r *Runtime) update(name string, f func(*Stats)) {
+	r.mu.Lock()
+	f(r.stats[name])
+	r.mu.Unlock()
+}
+
+func (r *Runtime) reporting(done <-chan struct{}) {
+	t := time.NewTicker(r.cfg.ReportInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			r.log.Info("function stats", log.Any("stats", r.Stats()))
+		case <-done:
+			return
+		}
+	}
+}
+
+// Run loads the config from the file (env BAETYL_CONF_FILE or /etc/baetyl/service.yml),
+// serves the handlers until SIGTERM or SIGINT is received
+func Run(handlers map[string]Handler) error {
+	fs := []log.Field{
+		log.Any("node", os.Getenv(bcontext.EnvKeyNodeName)),
+		log.Any("app", os.Getenv(bcontext.EnvKeyAppName)),
+		log.Any("service", os.Getenv(bcontext.EnvKeyServiceName)),
+	}
+	file := os.Getenv(EnvKeyConfFile)
+	if file == "" {
+		file = bcontext.DefaultConfFile
+	}
+	var err error
+	var cfg RuntimeConfig
+	if utils.FileExists(file) {
+		err = utils.LoadYAML(file, &cfg)
+	} else {
+		err = utils.UnmarshalYAML(nil, &cfg)
+	}
+	if err != nil {
+		return err
+	}
+	logger, err := log.Init(cfg.Logger, fs...)
+	if err != nil {
+		return err
+	}
+	if cfg.Server.Address == "" {
+		cfg.Server.Address = DefaultAddress
+	}
+
+	r, err := NewRuntime(cfg, handlers)
+	if err != nil {
+		return err
+	}
+	svr, err := NewServer(cfg.Server, r, nil)
+	if err != nil {
+		return err
+	}
+	addr := cfg.Server.Address
+	if i := strings.Index(addr, "://"); i >= 0 {
+		addr = addr[i+3:]
+	}
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	if cfg.ReportInterval > 0 {
+		go r.reporting(done)
+	}
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGTERM, syscall.SIGINT)
+		select {
+		case <-sig:
+			logger.Info("function runtime is stopping")
+			svr.GracefulStop()
+		case <-done:
+		}
+	}()
+	logger.Info("function runtime starts", log.Any("address", cfg.Server.Address), log.Any("functions", len(handlers)))
+	defer logger.Info("function runtime has stopped")
+	return svr.Serve(lis)
+}
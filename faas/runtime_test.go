@@ -0,0 +1,163 @@
+package faas
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/creasty/defaults"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newTestRuntime(t *testing.T, concurrency int, handlers map[string]Handler) *Runtime {
+	var cfg RuntimeConfig
+	assert.NoError(t, defaults.Set(&cfg))
+	cfg.Timeout = 100 * time.Millisecond
+	cfg.Concurrency = concurrency
+	r, err := NewRuntime(cfg, handlers)
+	assert.NoError(t, err)
+	return r
+}
+
+func TestRuntime(t *testing.T) {
+	r := newTestRuntime(t, 2, map[string]Handler{
+		"echo": func(ctx context.Context, msg *Message) (*Message, error) {
+			return msg, nil
+		},
+		"fail": func(ctx context.Context, msg *Message) (*Message, error) {
+			return nil, fmt.Errorf("failed")
+		},
+		"sleep": func(ctx context.Context, msg *Message) (*Message, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+		"panic": func(ctx context.Context, msg *Message) (*Message, error) {
+			panic("oops")
+		},
+	})
+	ctx := context.Background()
+
+	msg := &Message{Name: "echo", Payload: []byte("hi")}
+	res, err := r.Invoke(ctx, msg)
+	assert.NoError(t, err)
+	assert.Equal(t, msg, res)
+
+	_, err = r.Invoke(ctx, &Message{Name: "x"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = r.Invoke(ctx, &Message{})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = r.Invoke(ctx, &Message{Name: "fail"})
+	assert.Equal(t, codes.Unknown, status.Code(err))
+	assert.EqualError(t, err, "rpc error: code = Unknown desc = failed")
+
+	_, err = r.Invoke(ctx, &Message{Name: "sleep"})
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+
+	_, err = r.Invoke(ctx, &Message{Name: "panic"})
+	assert.Equal(t, codes.Internal, status.Code(err))
+
+	stats := r.Stats()
+	assert.Equal(t, uint64(1), stats["echo"].Invocations)
+	assert.Equal(t, uint64(1), stats["fail"].Errors)
+	assert.Equal(t, uint64(1), stats["sleep"].Timeouts)
+	assert.Equal(t, uint64(1), stats["panic"].Errors)
+	assert.Equal(t, int64(0), stats["sleep"].Running)
+}
+
+func TestRuntimeConcurrency(t *testing.T) {
+	release := make(chan struct{})
+	r := newTestRuntime(t, 1, map[string]Handler{
+		"block": func(ctx context.Context, msg *Message) (*Message, error) {
+			<-release
+			return msg, nil
+		},
+	})
+	r.cfg.Timeout = time.Second
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, err := r.Invoke(context.Background(), &Message{})
+		assert.NoError(t, err)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int64(1), r.Stats()["block"].Running)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := r.Invoke(ctx, &Message{})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, uint64(1), r.Stats()["block"].Rejections)
+
+	close(release)
+	wg.Wait()
+	_, err = r.Invoke(context.Background(), &Message{})
+	assert.NoError(t, err)
+
+	_, err = NewRuntime(RuntimeConfig{}, nil)
+	assert.EqualError(t, err, "no function handler")
+}
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", t.Name())
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := lis.Addr().String()
+	lis.Close()
+
+	file := filepath.Join(dir, "service.yml")
+	conf := fmt.Sprintf("server:\n  address: tcp://%s\nconcurrency: 2\nreportInterval: 10ms\n", addr)
+	assert.NoError(t, ioutil.WriteFile(file, []byte(conf), 0644))
+	os.Setenv(EnvKeyConfFile, file)
+	defer os.Unsetenv(EnvKeyConfFile)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- Run(map[string]Handler{
+			"echo": func(ctx context.Context, msg *Message) (*Message, error) {
+				return msg, nil
+			},
+		})
+	}()
+
+	var cc ClientConfig
+	defaults.Set(&cc)
+	cc.Address = addr
+	cli, err := NewClient(cc)
+	assert.NoError(t, err)
+	defer cli.Close()
+	res, err := cli.Invoke(&Message{ID: 1, Payload: []byte("hi")})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("hi"), res.Payload)
+
+	var payloads []string
+	err = cli.InvokeStream(context.Background(), &Message{Name: "echo", Payload: []byte("hi")}, func(m *Message) error {
+		payloads = append(payloads, string(m.Payload))
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"hi"}, payloads)
+
+	time.Sleep(20 * time.Millisecond)
+	assert.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGTERM))
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("runtime is not stopped")
+	}
+}
@@ -0,0 +1,17 @@
+package faas
+
+import (
+	"github.com/baetyl/baetyl-go/link"
+	"google.golang.org/grpc"
+)
+
+// NewServer creates a new grpc server with the function server registered,
+// tls is enabled if the certificate is configured, and the requests are authenticated if auth is not nil
+func NewServer(cfg ServerConfig, fs FunctionServer, auth link.Authenticator) (*grpc.Server, error) {
+	svr, err := link.NewServer(cfg.ServerConfig, auth)
+	if err != nil {
+		return nil, err
+	}
+	RegisterFunctionServer(svr, fs)
+	return svr, nil
+}
@@ -1,4 +1,4 @@
-// Package flow can be used to test MQTT packet flows.
+// Package flow can be used to test MQTT packet flows, and to compose operators over message streams.
 package flow
 
 import (
@@ -0,0 +1,223 @@
+package flow
+
+import (
+	"context"
+	"time"
+
+	"github.com/baetyl/baetyl-go/log"
+)
+
+// An Operator transforms the input stream into the output stream, such as *mqtt.Publish or *link.Message.
+// The output channel is unbuffered, so a slow consumer blocks the operator and then the producer (backpressure).
+// The output channel is closed after the input channel is closed or the context is done.
+type Operator func(ctx context.Context, in <-chan interface{}) <-chan interface{}
+
+// Compose chains the operators in order into one operator
+func Compose(ops ...Operator) Operator {
+	return func(ctx context.Context, in <-chan interface{}) <-chan interface{} {
+		for _, op := range ops {
+			in = op(ctx, in)
+		}
+		return in
+	}
+}
+
+// Map returns the operator which converts every message by the function,
+// the message is dropped if the function returns an error
+func Map(fn func(interface{}) (interface{}, error)) Operator {
+	logger := log.With(log.Any("flow", "map"))
+	return func(ctx context.Context, in <-chan interface{}) <-chan interface{} {
+		out := make(chan interface{})
+		go func() {
+			defer close(out)
+			for {
+				v, ok := recv(ctx, in)
+				if !ok {
+					return
+				}
+				v, err := fn(v)
+				if err != nil {
+					logger.Warn("message is dropped", log.Error(err))
+					continue
+				}
+				if !send(ctx, out, v) {
+					return
+				}
+			}
+		}()
+		return out
+	}
+}
+
+// Filter returns the operator which only passes the messages matched by the function
+func Filter(fn func(interface{}) bool) Operator {
+	return func(ctx context.Context, in <-chan interface{}) <-chan interface{} {
+		out := make(chan interface{})
+		go func() {
+			defer close(out)
+			for {
+				v, ok := recv(ctx, in)
+				if !ok {
+					return
+				}
+				if !fn(v) {
+					continue
+				}
+				if !send(ctx, out, v) {
+					return
+				}
+			}
+		}()
+		return out
+	}
+}
+
+// Batch returns the operator which groups messages into []interface{} of the size,
+// a partial batch is emitted after the timeout since its first message (if timeout > 0) or the input is closed
+func Batch(size int, timeout time.Duration) Operator {
+	return func(ctx context.Context, in <-chan interface{}) <-chan interface{} {
+		out := make(chan interface{})
+		go func() {
+			defer close(out)
+			var batch []interface{}
+			var timer *time.Timer
+			var expired <-chan time.Time
+			flush := func() bool {
+				if timer != nil {
+					timer.Stop()
+					timer, expired = nil, nil
+				}
+				if len(batch) == 0 {
+					return true
+				}
+				b := batch
+				batch = nil
+				return send(ctx, out, b)
+			}
+			for {
+				select {
+				case v, ok := <-in:
+					if !ok {
+						flush()
+						return
+					}
+					batch = append(batch, v)
+					if len(batch) == 1 && timeout > 0 {
+						timer = time.NewTimer(timeout)
+						expired = timer.C
+					}
+					if len(batch) >= size && !flush() {
+						return
+					}
+				case <-expired:
+					timer, expired = nil, nil
+					if !flush() {
+						return
+					}
+				case <-ctx.Done():
+					if timer != nil {
+						timer.Stop()
+					}
+					return
+				}
+			}
+		}()
+		return out
+	}
+}
+
+// Window returns the operator which aggregates the messages received in every tumbling window of the duration
+// by the function, such as average (aggregation) or last (downsampling). Empty windows emit nothing,
+// the aggregated value is dropped if it is nil.
+func Window(d time.Duration, fn func([]interface{}) interface{}) Operator {
+	return func(ctx context.Context, in <-chan interface{}) <-chan interface{} {
+		out := make(chan interface{})
+		go func() {
+			defer close(out)
+			t := time.NewTicker(d)
+			defer t.Stop()
+			var window []interface{}
+			flush := func() bool {
+				if len(window) == 0 {
+					return true
+				}
+				v := fn(window)
+				window = nil
+				if v == nil {
+					return true
+				}
+				return send(ctx, out, v)
+			}
+			for {
+				select {
+				case v, ok := <-in:
+					if !ok {
+						flush()
+						return
+					}
+					window = append(window, v)
+				case <-t.C:
+					if !flush() {
+						return
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		return out
+	}
+}
+
+// Buffer returns the operator which buffers up to the size of messages,
+// to absorb bursts without blocking the producer
+func Buffer(size int) Operator {
+	return func(ctx context.Context, in <-chan interface{}) <-chan interface{} {
+		out := make(chan interface{}, size)
+		go func() {
+			defer close(out)
+			for {
+				v, ok := recv(ctx, in)
+				if !ok {
+					return
+				}
+				if !send(ctx, out, v) {
+					return
+				}
+			}
+		}()
+		return out
+	}
+}
+
+// Sink consumes all messages of the stream by the function until the stream is closed or the context is done,
+// returns the first error returned by the function
+func Sink(ctx context.Context, in <-chan interface{}, fn func(interface{}) error) error {
+	for {
+		v, ok := recv(ctx, in)
+		if !ok {
+			return ctx.Err()
+		}
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+}
+
+func recv(ctx context.Context, in <-chan interface{}) (interface{}, bool) {
+	select {
+	case v, ok := <-in:
+		return v, ok
+	case <-ctx.Done():
+		return nil, false
+	}
+}
+
+func send(ctx context.Context, out chan<- interface{}, v interface{}) bool {
+	select {
+	case out <- v:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
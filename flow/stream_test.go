@@ -0,0 +1,129 @@
+package flow
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/baetyl/baetyl-go/link"
+	"github.com/baetyl/baetyl-go/mqtt"
+	"github.com/stretchr/testify/assert"
+)
+
+func source(vs ...interface{}) <-chan interface{} {
+	ch := make(chan interface{}, len(vs))
+	for _, v := range vs {
+		ch <- v
+	}
+	close(ch)
+	return ch
+}
+
+func collect(t *testing.T, in <-chan interface{}) []interface{} {
+	var vs []interface{}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := Sink(ctx, in, func(v interface{}) error {
+		vs = append(vs, v)
+		return nil
+	})
+	assert.NoError(t, err)
+	return vs
+}
+
+func publish(topic string, payload string) *mqtt.Publish {
+	pkt := mqtt.NewPublish()
+	pkt.Message.Topic = topic
+	pkt.Message.Payload = []byte(payload)
+	return pkt
+}
+
+func TestMapFilter(t *testing.T) {
+	op := Compose(
+		Filter(func(v interface{}) bool {
+			return v.(*mqtt.Publish).Message.Topic == "a"
+		}),
+		Map(func(v interface{}) (interface{}, error) {
+			pkt := v.(*mqtt.Publish)
+			if string(pkt.Message.Payload) == "bad" {
+				return nil, errors.New("bad payload")
+			}
+			msg := &link.Message{Content: pkt.Message.Payload}
+			msg.Context.Topic = pkt.Message.Topic
+			return msg, nil
+		}),
+	)
+	in := source(publish("a", "1"), publish("b", "2"), publish("a", "bad"), publish("a", "3"))
+	vs := collect(t, op(context.Background(), in))
+	assert.Len(t, vs, 2)
+	assert.Equal(t, "1", string(vs[0].(*link.Message).Content))
+	assert.Equal(t, "3", string(vs[1].(*link.Message).Content))
+}
+
+func TestBatch(t *testing.T) {
+	vs := collect(t, Batch(2, 0)(context.Background(), source(1, 2, 3, 4, 5)))
+	assert.Equal(t, []interface{}{[]interface{}{1, 2}, []interface{}{3, 4}, []interface{}{5}}, vs)
+
+	in := make(chan interface{})
+	out := Batch(10, 50*time.Millisecond)(context.Background(), in)
+	in <- 1
+	in <- 2
+	select {
+	case v := <-out:
+		assert.Equal(t, []interface{}{1, 2}, v)
+	case <-time.After(5 * time.Second):
+		t.Fatal("batch is not emitted after timeout")
+	}
+	close(in)
+	_, ok := <-out
+	assert.False(t, ok)
+}
+
+func TestWindow(t *testing.T) {
+	in := make(chan interface{})
+	sum := func(vs []interface{}) interface{} {
+		s := 0
+		for _, v := range vs {
+			s += v.(int)
+		}
+		return s
+	}
+	out := Window(50*time.Millisecond, sum)(context.Background(), in)
+	in <- 1
+	in <- 2
+	in <- 3
+	select {
+	case v := <-out:
+		assert.Equal(t, 6, v)
+	case <-time.After(5 * time.Second):
+		t.Fatal("window is not emitted")
+	}
+	in <- 4
+	close(in)
+	assert.Equal(t, []interface{}{4}, collect(t, out))
+}
+
+func TestBackpressure(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	in := make(chan interface{})
+	out := Compose(Map(func(v interface{}) (interface{}, error) { return v, nil }), Buffer(2))(ctx, in)
+
+	// 2 buffered, 1 held by buffer, 1 held by map, then the producer is blocked
+	sent := 0
+	for i := 0; i < 5; i++ {
+		select {
+		case in <- i:
+			sent++
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	assert.Equal(t, 4, sent)
+	assert.Equal(t, 0, <-out)
+
+	cancel()
+	for range out {
+	}
+	err := Sink(ctx, source(1), func(interface{}) error { return nil })
+	assert.Equal(t, context.Canceled, err)
+}
@@ -0,0 +1,12 @@
+package host
+
+import "time"
+
+// ReporterConfig the config of host metrics reporter
+type ReporterConfig struct {
+	Interval time.Duration `yaml:"interval" json:"interval" default:"1m"`
+	Topic    string        `yaml:"topic" json:"topic" default:"$baetyl/node/stats"` // the mqtt topic or the link topic
+	QOS      uint32        `yaml:"qos" json:"qos" validate:"min=0, max=1"`          // the qos of mqtt message
+	Disks    []string      `yaml:"disks" json:"disks" default:"[\"/\"]"`            // the mount paths of disks collected
+	Metrics  bool          `yaml:"metrics" json:"metrics"`                          // the stats are also set as gauges of shared metrics registry if enabled
+}
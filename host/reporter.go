@@ -0,0 +1,177 @@
+package host
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/baetyl/baetyl-go/codec"
+	"github.com/baetyl/baetyl-go/link"
+	"github.com/baetyl/baetyl-go/log"
+	"github.com/baetyl/baetyl-go/metrics"
+	"github.com/baetyl/baetyl-go/mqtt"
+	"github.com/baetyl/baetyl-go/utils"
+)
+
+// Publisher publishes the mqtt message, such as mqtt.Client
+type Publisher interface {
+	Publish(qos mqtt.QOS, topic string, payload []byte, pid mqtt.ID, retain bool, dup bool) error
+}
+
+// Sender sends the stats
+type Sender interface {
+	Send(context.Context, *Stats) error
+}
+
+// SenderFunc the function to send
+type SenderFunc func(context.Context, *Stats) error
+
+// Send calls the function
+func (f SenderFunc) Send(ctx context.Context, s *Stats) error {
+	return f(ctx, s)
+}
+
+// NewMQTTSender creates a sender which publishes the stats in json to the mqtt topic
+func NewMQTTSender(pub Publisher, topic string, qos uint32) Sender {
+	return SenderFunc(func(_ context.Context, s *Stats) error {
+		data, err := json.Marshal(s)
+		if err != nil {
+			return err
+		}
+		return pub.Publish(mqtt.QOS(qos), topic, data, 0, false, false)
+	})
+}
+
+// NewLinkSender creates a sender which calls the link server (usually by a link client) with the stats in json
+func NewLinkSender(caller link.Caller, topic string) Sender {
+	return SenderFunc(func(ctx context.Context, s *Stats) error {
+		msg := &link.Message{}
+		msg.Context.Topic = topic
+		if err := msg.EncodeContent(codec.JSON, s); err != nil {
+			return err
+		}
+		_, err := caller.CallContext(ctx, msg)
+		return err
+	})
+}
+
+var (
+	metricsOnce sync.Once
+	cpuUsage    *metrics.Gauge
+	cpuLoad     *metrics.Gauge
+	memBytes    *metrics.Gauge
+	diskBytes   *metrics.Gauge
+	netBytes    *metrics.Gauge
+	netPackets  *metrics.Gauge
+	temperature *metrics.Gauge
+)
+
+func initMetrics() {
+	metricsOnce.Do(func() {
+		ns := metrics.Namespace + "_host_"
+		cpuUsage = metrics.NewGauge(ns+"cpu_usage_percent", "The percent of cpu busy time.")
+		cpuLoad = metrics.NewGauge(ns+"cpu_load", "The load average of cpu.", "period")
+		memBytes = metrics.NewGauge(ns+"memory_bytes", "The memory in bytes.", "state")
+		diskBytes = metrics.NewGauge(ns+"disk_bytes", "The disk space in bytes.", "path", "state")
+		netBytes = metrics.NewGauge(ns+"network_bytes", "The cumulative bytes of network interface.", "interface", "direction")
+		netPackets = metrics.NewGauge(ns+"network_packets", "The cumulative packets of network interface.", "interface", "direction")
+		temperature = metrics.NewGauge(ns+"temperature_celsius", "The temperature of thermal zone in celsius.", "zone")
+	})
+}
+
+// SetMetrics sets the stats as the gauges of shared metrics registry
+func SetMetrics(s *Stats) {
+	initMetrics()
+	cpuUsage.Set(s.CPU.Usage)
+	cpuLoad.Set(s.CPU.Load1, "1m")
+	cpuLoad.Set(s.CPU.Load5, "5m")
+	cpuLoad.Set(s.CPU.Load15, "15m")
+	memBytes.Set(float64(s.Memory.Total), "total")
+	memBytes.Set(float64(s.Memory.Available), "available")
+	memBytes.Set(float64(s.Memory.Used), "used")
+	for _, d := range s.Disks {
+		diskBytes.Set(float64(d.Total), d.Path, "total")
+		diskBytes.Set(float64(d.Free), d.Path, "free")
+		diskBytes.Set(float64(d.Used), d.Path, "used")
+	}
+	for _, n := range s.Networks {
+		netBytes.Set(float64(n.RxBytes), n.Interface, "rx")
+		netBytes.Set(float64(n.TxBytes), n.Interface, "tx")
+		netPackets.Set(float64(n.RxPackets), n.Interface, "rx")
+		netPackets.Set(float64(n.TxPackets), n.Interface, "tx")
+	}
+	for _, t := range s.Temperatures {
+		temperature.Set(t.Celsius, t.Zone)
+	}
+}
+
+// Reporter collects and reports the stats of host periodically
+type Reporter struct {
+	cfg  ReporterConfig
+	col  *Collector
+	snd  Sender
+	ctx  context.Context
+	stop context.CancelFunc
+	tomb utils.Tomb
+	log  *log.Logger
+}
+
+// NewReporter creates a new reporter and starts reporting if the interval is positive
+func NewReporter(cfg ReporterConfig, snd Sender) *Reporter {
+	r := &Reporter{
+		cfg: cfg,
+		col: NewCollector(cfg.Disks...),
+		snd: snd,
+		log: log.With(log.Any("host", "reporter")),
+	}
+	r.ctx, r.stop = context.WithCancel(context.Background())
+	if cfg.Interval > 0 {
+		r.tomb.Go(r.reporting)
+	}
+	return r
+}
+
+// Report collects and reports the stats once
+func (r *Reporter) Report(ctx context.Context) (*Stats, error) {
+	s, err := r.col.Collect()
+	if err != nil {
+		return nil, err
+	}
+	if r.cfg.Metrics {
+		SetMetrics(s)
+	}
+	return s, r.snd.Send(ctx, s)
+}
+
+// Close stops reporting
+func (r *Reporter) Close() error {
+	r.tomb.Kill(nil)
+	r.stop()
+	return r.tomb.Wait()
+}
+
+func (r *Reporter) reporting() error {
+	r.log.Info("reporter starts")
+	defer r.log.Info("reporter has stopped")
+
+	// the cpu times are sampled first, so that the usage is available at the first report
+	if _, err := r.col.Collect(); err != nil {
+		r.log.Warn("failed to collect stats", log.Error(err))
+	}
+	t := time.NewTicker(r.cfg.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			ctx, cancel := context.WithTimeout(r.ctx, r.cfg.Interval)
+			_, err := r.Report(ctx)
+			cancel()
+			if err != nil {
+				r.log.Warn("failed to report stats", log.Error(err))
+			}
+		case <-r.tomb.Dying():
+			return nil
+		}
+	}
+}
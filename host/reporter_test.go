@@ -0,0 +1,99 @@
+package host
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/baetyl/baetyl-go/link"
+	"github.com/baetyl/baetyl-go/metrics"
+	"github.com/baetyl/baetyl-go/mqtt"
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockPublisher struct {
+	topics []string
+	qos    []mqtt.QOS
+	data   [][]byte
+	mu     sync.Mutex
+}
+
+func (p *mockPublisher) Publish(qos mqtt.QOS, topic string, payload []byte, pid mqtt.ID, retain bool, dup bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.topics = append(p.topics, topic)
+	p.qos = append(p.qos, qos)
+	p.data = append(p.data, payload)
+	return nil
+}
+
+func (p *mockPublisher) count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.data)
+}
+
+func TestReporter(t *testing.T) {
+	var cfg ReporterConfig
+	assert.NoError(t, utils.SetDefaults(&cfg))
+	assert.Equal(t, time.Minute, cfg.Interval)
+	assert.Equal(t, "$baetyl/node/stats", cfg.Topic)
+	assert.Equal(t, []string{"/"}, cfg.Disks)
+
+	pub := &mockPublisher{}
+	cfg.Interval = 50 * time.Millisecond
+	cfg.QOS = 1
+	cfg.Metrics = true
+	r := NewReporter(cfg, NewMQTTSender(pub, cfg.Topic, cfg.QOS))
+	assert.Eventually(t, func() bool { return pub.count() >= 2 }, 5*time.Second, 10*time.Millisecond)
+	assert.NoError(t, r.Close())
+
+	pub.mu.Lock()
+	assert.Equal(t, "$baetyl/node/stats", pub.topics[0])
+	assert.Equal(t, mqtt.QOS(1), pub.qos[0])
+	var s Stats
+	assert.NoError(t, json.Unmarshal(pub.data[0], &s))
+	pub.mu.Unlock()
+	assert.NotZero(t, s.CPU.Cores)
+	assert.False(t, s.Time.IsZero())
+
+	mfs, err := metrics.Registry().Gather()
+	assert.NoError(t, err)
+	names := map[string]bool{}
+	for _, mf := range mfs {
+		names[mf.GetName()] = true
+	}
+	assert.True(t, names["baetyl_host_cpu_usage_percent"])
+	assert.True(t, names["baetyl_host_memory_bytes"])
+}
+
+func TestLinkSender(t *testing.T) {
+	var msgs []*link.Message
+	caller := link.CallerFunc(func(ctx context.Context, msg *link.Message) (*link.Message, error) {
+		msgs = append(msgs, msg)
+		return &link.Message{}, nil
+	})
+	r := NewReporter(ReporterConfig{Topic: "stats"}, NewLinkSender(caller, "stats"))
+	defer r.Close()
+
+	s, err := r.Report(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, msgs, 1)
+	assert.Equal(t, "stats", msgs[0].Context.Topic)
+	var res Stats
+	assert.NoError(t, msgs[0].Decode(&res))
+	assert.Equal(t, s.Hostname, res.Hostname)
+	assert.Equal(t, s.CPU.Cores, res.CPU.Cores)
+
+	caller = link.CallerFunc(func(ctx context.Context, msg *link.Message) (*link.Message, error) {
+		return nil, errors.New("unavailable")
+	})
+	r2 := NewReporter(ReporterConfig{}, NewLinkSender(caller, "stats"))
+	defer r2.Close()
+	_, err = r2.Report(context.Background())
+	assert.EqualError(t, err, "unavailable")
+}
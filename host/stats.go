@@ -0,0 +1,100 @@
+// Package host collects the stats of host, such as cpu, memory, disk, network and temperature,
+// and reports them periodically to a mqtt topic or a link channel.
+package host
+
+import (
+	"os"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Stats the stats of host
+type Stats struct {
+	Time         time.Time          `json:"time"`
+	Hostname     string             `json:"hostname,omitempty"`
+	CPU          CPUStats           `json:"cpu"`
+	Memory       MemoryStats        `json:"memory"`
+	Disks        []DiskStats        `json:"disks,omitempty"`
+	Networks     []NetworkStats     `json:"networks,omitempty"`
+	Temperatures []TemperatureStats `json:"temperatures,omitempty"`
+}
+
+// CPUStats the stats of cpu, the usage is the percent of busy time since the last collection
+type CPUStats struct {
+	Cores  int     `json:"cores"`
+	Usage  float64 `json:"usage"`
+	Load1  float64 `json:"load1"`
+	Load5  float64 `json:"load5"`
+	Load15 float64 `json:"load15"`
+}
+
+// MemoryStats the stats of memory in bytes
+type MemoryStats struct {
+	Total     uint64  `json:"total"`
+	Available uint64  `json:"available"`
+	Used      uint64  `json:"used"`
+	Usage     float64 `json:"usage"`
+}
+
+// DiskStats the stats of the file system mounted at the path in bytes
+type DiskStats struct {
+	Path  string  `json:"path"`
+	Total uint64  `json:"total"`
+	Free  uint64  `json:"free"`
+	Used  uint64  `json:"used"`
+	Usage float64 `json:"usage"`
+}
+
+// NetworkStats the cumulative stats of network interface
+type NetworkStats struct {
+	Interface string `json:"interface"`
+	RxBytes   uint64 `json:"rxBytes"`
+	TxBytes   uint64 `json:"txBytes"`
+	RxPackets uint64 `json:"rxPackets"`
+	TxPackets uint64 `json:"txPackets"`
+}
+
+// TemperatureStats the temperature of thermal zone in celsius
+type TemperatureStats struct {
+	Zone    string  `json:"zone"`
+	Celsius float64 `json:"celsius"`
+}
+
+// Collector collects the stats of host, it keeps the cpu times of last collection to compute the usage
+type Collector struct {
+	disks []string
+	root  string // the root of procfs and sysfs, for tests
+	cpu   cpuTimes
+	mu    sync.Mutex
+}
+
+// NewCollector creates a new collector of the disks mounted at the paths
+func NewCollector(disks ...string) *Collector {
+	return &Collector{disks: disks, root: "/"}
+}
+
+// Collect collects the stats, the stats not supported by the platform are skipped
+func (c *Collector) Collect() (*Stats, error) {
+	s := &Stats{Time: time.Now().UTC()}
+	s.Hostname, _ = os.Hostname()
+	s.CPU.Cores = runtime.NumCPU()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.collect(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+type cpuTimes struct {
+	busy  uint64
+	total uint64
+}
+
+func percent(part, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total) * 100
+}
@@ -0,0 +1,166 @@
+package host
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// collect collects the stats from procfs and sysfs
+func (c *Collector) collect(s *Stats) error {
+	if err := c.collectCPU(s); err != nil {
+		return err
+	}
+	if err := c.collectMemory(s); err != nil {
+		return err
+	}
+	for _, p := range c.disks {
+		var st syscall.Statfs_t
+		if err := syscall.Statfs(p, &st); err != nil {
+			return err
+		}
+		d := DiskStats{
+			Path:  p,
+			Total: st.Blocks * uint64(st.Bsize),
+			Free:  st.Bavail * uint64(st.Bsize),
+		}
+		d.Used = d.Total - st.Bfree*uint64(st.Bsize)
+		d.Usage = percent(d.Used, d.Used+d.Free)
+		s.Disks = append(s.Disks, d)
+	}
+	if err := c.collectNetworks(s); err != nil {
+		return err
+	}
+	c.collectTemperatures(s)
+	return nil
+}
+
+func (c *Collector) collectCPU(s *Stats) error {
+	data, err := ioutil.ReadFile(filepath.Join(c.root, "proc", "stat"))
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 || fields[0] != "cpu" {
+			continue
+		}
+		// user nice system idle iowait irq softirq steal, the guest times are included in user and nice
+		var t cpuTimes
+		for i, f := range fields[1:] {
+			if i >= 8 {
+				break
+			}
+			v, _ := strconv.ParseUint(f, 10, 64)
+			t.total += v
+			if i != 3 && i != 4 {
+				t.busy += v
+			}
+		}
+		if c.cpu.total > 0 && t.total > c.cpu.total {
+			s.CPU.Usage = percent(t.busy-c.cpu.busy, t.total-c.cpu.total)
+		}
+		c.cpu = t
+		break
+	}
+	data, err = ioutil.ReadFile(filepath.Join(c.root, "proc", "loadavg"))
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) >= 3 {
+		s.CPU.Load1, _ = strconv.ParseFloat(fields[0], 64)
+		s.CPU.Load5, _ = strconv.ParseFloat(fields[1], 64)
+		s.CPU.Load15, _ = strconv.ParseFloat(fields[2], 64)
+	}
+	return nil
+}
+
+func (c *Collector) collectMemory(s *Stats) error {
+	f, err := os.Open(filepath.Join(c.root, "proc", "meminfo"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info := map[string]uint64{}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		v, _ := strconv.ParseUint(fields[1], 10, 64)
+		// the values are in kB
+		info[strings.TrimSuffix(fields[0], ":")] = v * 1024
+	}
+	if err = sc.Err(); err != nil {
+		return err
+	}
+	s.Memory.Total = info["MemTotal"]
+	if v, ok := info["MemAvailable"]; ok {
+		s.Memory.Available = v
+	} else {
+		// the kernels before 3.14
+		s.Memory.Available = info["MemFree"] + info["Buffers"] + info["Cached"]
+	}
+	if s.Memory.Total > s.Memory.Available {
+		s.Memory.Used = s.Memory.Total - s.Memory.Available
+	}
+	s.Memory.Usage = percent(s.Memory.Used, s.Memory.Total)
+	return nil
+}
+
+func (c *Collector) collectNetworks(s *Stats) error {
+	data, err := ioutil.ReadFile(filepath.Join(c.root, "proc", "net", "dev"))
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		i := strings.Index(line, ":")
+		if i < 0 {
+			continue
+		}
+		name := strings.TrimSpace(line[:i])
+		fields := strings.Fields(line[i+1:])
+		if name == "lo" || len(fields) < 10 {
+			continue
+		}
+		n := NetworkStats{Interface: name}
+		n.RxBytes, _ = strconv.ParseUint(fields[0], 10, 64)
+		n.RxPackets, _ = strconv.ParseUint(fields[1], 10, 64)
+		n.TxBytes, _ = strconv.ParseUint(fields[8], 10, 64)
+		n.TxPackets, _ = strconv.ParseUint(fields[9], 10, 64)
+		s.Networks = append(s.Networks, n)
+	}
+	sort.Slice(s.Networks, func(i, j int) bool {
+		return s.Networks[i].Interface < s.Networks[j].Interface
+	})
+	return nil
+}
+
+// collectTemperatures collects the temperatures of thermal zones, which are missing on many hosts
+func (c *Collector) collectTemperatures(s *Stats) {
+	zones, _ := filepath.Glob(filepath.Join(c.root, "sys", "class", "thermal", "thermal_zone*"))
+	sort.Strings(zones)
+	for _, z := range zones {
+		data, err := ioutil.ReadFile(filepath.Join(z, "temp"))
+		if err != nil {
+			continue
+		}
+		v, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+		if err != nil {
+			continue
+		}
+		name := filepath.Base(z)
+		if data, err = ioutil.ReadFile(filepath.Join(z, "type")); err == nil {
+			name = strings.TrimSpace(string(data))
+		}
+		// the value is in millidegree celsius
+		s.Temperatures = append(s.Temperatures, TemperatureStats{Zone: name, Celsius: float64(v) / 1000})
+	}
+}
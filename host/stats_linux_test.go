@@ -0,0 +1,74 @@
+package host
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+}
+
+func TestCollectLinux(t *testing.T) {
+	root, err := ioutil.TempDir("", t.Name())
+	assert.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	writeFile(t, filepath.Join(root, "proc", "stat"), "cpu  100 0 100 700 100 0 0 0 0 0\ncpu0 100 0 100 700 100 0 0 0 0 0\n")
+	writeFile(t, filepath.Join(root, "proc", "loadavg"), "0.50 0.25 0.10 1/100 1000\n")
+	writeFile(t, filepath.Join(root, "proc", "meminfo"), "MemTotal:       1000 kB\nMemFree:         100 kB\nMemAvailable:    250 kB\n")
+	writeFile(t, filepath.Join(root, "proc", "net", "dev"), `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:     100       1    0    0    0     0          0         0      100       1    0    0    0     0       0          0
+  eth0:    2000      20    0    0    0     0          0         0     1000      10    0    0    0     0       0          0
+`)
+	writeFile(t, filepath.Join(root, "sys", "class", "thermal", "thermal_zone0", "temp"), "45500\n")
+	writeFile(t, filepath.Join(root, "sys", "class", "thermal", "thermal_zone0", "type"), "cpu-thermal\n")
+	writeFile(t, filepath.Join(root, "sys", "class", "thermal", "thermal_zone1", "temp"), "invalid\n")
+
+	c := NewCollector(root)
+	c.root = root
+	s, err := c.Collect()
+	assert.NoError(t, err)
+	assert.NotZero(t, s.CPU.Cores)
+	assert.Zero(t, s.CPU.Usage)
+	assert.Equal(t, 0.5, s.CPU.Load1)
+	assert.Equal(t, 0.25, s.CPU.Load5)
+	assert.Equal(t, 0.1, s.CPU.Load15)
+	assert.Equal(t, MemoryStats{Total: 1024000, Available: 256000, Used: 768000, Usage: 75}, s.Memory)
+	assert.Len(t, s.Disks, 1)
+	assert.Equal(t, root, s.Disks[0].Path)
+	assert.NotZero(t, s.Disks[0].Total)
+	assert.Equal(t, []NetworkStats{{Interface: "eth0", RxBytes: 2000, RxPackets: 20, TxBytes: 1000, TxPackets: 10}}, s.Networks)
+	assert.Equal(t, []TemperatureStats{{Zone: "cpu-thermal", Celsius: 45.5}}, s.Temperatures)
+
+	// 100 busy of 400 in total since the last collection
+	writeFile(t, filepath.Join(root, "proc", "stat"), "cpu  150 0 150 1000 100 0 0 0 0 0\n")
+	s, err = c.Collect()
+	assert.NoError(t, err)
+	assert.Equal(t, float64(25), s.CPU.Usage)
+
+	// the kernels without MemAvailable
+	writeFile(t, filepath.Join(root, "proc", "meminfo"), "MemTotal:       1000 kB\nMemFree:         100 kB\nBuffers:          50 kB\nCached:          100 kB\n")
+	s, err = c.Collect()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(256000), s.Memory.Available)
+
+	os.Remove(filepath.Join(root, "proc", "stat"))
+	_, err = c.Collect()
+	assert.Error(t, err)
+
+	c = NewCollector(filepath.Join(root, "missing"))
+	_, err = c.Collect()
+	assert.Error(t, err)
+
+	// the real host
+	c = NewCollector("/")
+	_, err = c.Collect()
+	assert.NoError(t, err)
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package host
+
+// collect is not supported, only the hostname and the cores are collected
+func (c *Collector) collect(s *Stats) error {
+	return nil
+}
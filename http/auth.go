@@ -0,0 +1,387 @@
+package http
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/baetyl/baetyl-go/auth"
+	"github.com/baetyl/baetyl-go/log"
+	"github.com/baetyl/baetyl-go/utils"
+	jwt "github.com/golang-jwt/jwt"
+	"golang.org/x/sync/singleflight"
+)
+
+// all auth header keys
+const (
+	HeaderAuthorization = "Authorization"
+	HeaderAPIKey        = "X-Api-Key"
+)
+
+// all auth errors
+var (
+	ErrUnauthenticated = errors.New("request is unauthenticated")
+	ErrTokenMissing    = errors.New("token is missing")
+	ErrTokenInvalid    = errors.New("token is invalid")
+	ErrCertMissing     = errors.New("client certificate is missing")
+	ErrCertNotAllowed  = errors.New("client certificate is not allowed")
+)
+
+// Authenticator authenticates a http request and returns the identity of requester
+type Authenticator interface {
+	Authenticate(*http.Request) (string, error)
+}
+
+type identityKey struct{}
+
+// IdentityFromContext returns the identity set by the authentication middleware
+func IdentityFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(identityKey{}).(string)
+	return id, ok
+}
+
+// Authenticate returns a middleware which rejects the request with 401 unless one of the authenticators passes,
+// the identity is put into the request context
+func Authenticate(auths ...Authenticator) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			l := log.FromContext(r.Context())
+			for _, auth := range auths {
+				id, err := auth.Authenticate(r)
+				if err != nil {
+					l.Debug("failed to authenticate request", log.Any("path", r.URL.Path), log.Error(err))
+					continue
+				}
+				next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, id)))
+				return
+			}
+			l.Warn("request is unauthenticated", log.Any("path", r.URL.Path), log.Any("remote", r.RemoteAddr))
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		})
+	}
+}
+
+// TokenAuthenticator authenticates by static tokens (api keys)
+type TokenAuthenticator struct {
+	tokens map[string]string
+}
+
+// NewTokenAuthenticator creates a new token authenticator, the map is from token to identity
+func NewTokenAuthenticator(tokens map[string]string) *TokenAuthenticator {
+	return &TokenAuthenticator{tokens: tokens}
+}
+
+// Authenticate authenticates the token in X-Api-Key or Authorization (Bearer) header
+func (a *TokenAuthenticator) Authenticate(r *http.Request) (string, error) {
+	token := r.Header.Get(HeaderAPIKey)
+	if token == "" {
+		token = bearerToken(r)
+	}
+	if token == "" {
+		return "", ErrTokenMissing
+	}
+	id, ok := a.tokens[token]
+	if !ok {
+		return "", ErrTokenInvalid
+	}
+	return id, nil
+}
+
+// CertAuthenticator authenticates by the common name of verified client certificate
+type CertAuthenticator struct {
+	cns map[string]struct{}
+}
+
+// NewCertAuthenticator creates a new client certificate authenticator with a common name allowlist,
+// if the list is empty, any verified client certificate is allowed
+func NewCertAuthenticator(cns []string) *CertAuthenticator {
+	a := &CertAuthenticator{cns: make(map[string]struct{})}
+	for _, cn := range cns {
+		a.cns[cn] = struct{}{}
+	}
+	return a
+}
+
+// Authenticate authenticates the client certificate
+func (a *CertAuthenticator) Authenticate(r *http.Request) (string, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return "", ErrCertMissing
+	}
+	cn := r.TLS.VerifiedChains[0][0].Subject.CommonName
+	if len(a.cns) == 0 {
+		return cn, nil
+	}
+	if _, ok := a.cns[cn]; !ok {
+		return "", ErrCertNotAllowed
+	}
+	return cn, nil
+}
+
+// Guard returns a middleware which authenticates the credentials of request (basic auth, bearer token or api key,
+// and client certificate) by the authenticator of auth package, and authorizes the request method on the path,
+// the request is rejected with 401 if unauthenticated or 403 if forbidden. Both the principal and its name
+// as identity are put into the request context.
+func Guard(a auth.Authenticator, z auth.Authorizer) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			l := log.FromContext(r.Context())
+			p, err := a.Authenticate(NewCredentials(r))
+			if err != nil {
+				l.Warn("request is unauthenticated", log.Any("path", r.URL.Path), log.Any("remote", r.RemoteAddr))
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			if z != nil {
+				if err = z.Authorize(p, r.Method, r.URL.Path); err != nil {
+					l.Warn("request is forbidden", log.Any("path", r.URL.Path), log.Any("principal", p.Name))
+					http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+					return
+				}
+			}
+			ctx := auth.WithPrincipal(r.Context(), p)
+			ctx = context.WithValue(ctx, identityKey{}, p.Name)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// NewCredentials extracts the credentials from the request
+func NewCredentials(r *http.Request) *auth.Credentials {
+	c := &auth.Credentials{Token: r.Header.Get(HeaderAPIKey)}
+	if c.Token == "" {
+		c.Token = bearerToken(r)
+	}
+	c.Username, c.Password, _ = r.BasicAuth()
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		c.Certificates = r.TLS.VerifiedChains[0]
+	}
+	return c
+}
+
+// JWTAuthenticator authenticates by jwt bearer token, the subject claim is the identity.
+// The keys from jwks url are refreshed in background by the interval, a token with unknown key id
+// triggers a refresh at most once per the minimum interval, and concurrent refreshes are merged.
+type JWTAuthenticator struct {
+	cfg   JWTConfig
+	cli   *Client
+	keys  map[string]interface{}
+	last  time.Time
+	group singleflight.Group
+	mu    sync.RWMutex
+	tomb  utils.Tomb
+	log   *log.Logger
+}
+
+// NewJWTAuthenticator creates a new jwt authenticator
+func NewJWTAuthenticator(cfg JWTConfig) (*JWTAuthenticator, error) {
+	if cfg.Secret == "" && cfg.JWKS == "" {
+		return nil, errors.New("either secret or jwks is required")
+	}
+	a := &JWTAuthenticator{
+		cfg:  cfg,
+		keys: map[string]interface{}{},
+		log:  log.With(log.Any("http", "jwt")),
+	}
+	if cfg.JWKS != "" {
+		cli, err := NewClient(cfg.Client)
+		if err != nil {
+			return nil, err
+		}
+		a.cli = cli
+		err = a.refresh()
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Interval > 0 {
+			a.tomb.Go(a.refreshing)
+		}
+	}
+	return a, nil
+}
+
+// Close stops refreshing the keys in background
+func (a *JWTAuthenticator) Close() error {
+	a.tomb.Kill(nil)
+	return a.tomb.Wait()
+}
+
+// Authenticate authenticates the jwt in Authorization (Bearer) header
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (string, error) {
+	raw := bearerToken(r)
+	if raw == "" {
+		return "", ErrTokenMissing
+	}
+	claims := &jwt.StandardClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, a.key)
+	if err != nil {
+		return "", fmt.Errorf("%s: %s", ErrTokenInvalid.Error(), err.Error())
+	}
+	if a.cfg.Issuer != "" && !claims.VerifyIssuer(a.cfg.Issuer, true) {
+		return "", fmt.Errorf("%s: issuer mismatch", ErrTokenInvalid.Error())
+	}
+	if a.cfg.Audience != "" && !claims.VerifyAudience(a.cfg.Audience, true) {
+		return "", fmt.Errorf("%s: audience mismatch", ErrTokenInvalid.Error())
+	}
+	return claims.Subject, nil
+}
+
+func (a *JWTAuthenticator) key(t *jwt.Token) (interface{}, error) {
+	if a.cfg.JWKS == "" {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method (%s)", t.Header["alg"])
+		}
+		return []byte(a.cfg.Secret), nil
+	}
+	kid, _ := t.Header["kid"].(string)
+	a.mu.RLock()
+	k, ok := a.keys[kid]
+	recent := time.Since(a.last) < a.cfg.MinInterval
+	a.mu.RUnlock()
+	// the keys may be rotated, refresh them unless just refreshed
+	if !ok && !recent {
+		_, err, _ := a.group.Do("jwks", func() (interface{}, error) {
+			a.mu.RLock()
+			recent := time.Since(a.last) < a.cfg.MinInterval
+			a.mu.RUnlock()
+			if recent {
+				return nil, nil
+			}
+			return nil, a.refresh()
+		})
+		if err != nil {
+			a.log.Warn("failed to refresh jwks", log.Error(err))
+		}
+		a.mu.RLock()
+		k, ok = a.keys[kid]
+		a.mu.RUnlock()
+	}
+	if !ok {
+		return nil, fmt.Errorf("key (%s) not found", kid)
+	}
+	switch k.(type) {
+	case *rsa.PublicKey:
+		if _, ok := t.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, fmt.Errorf("unexpected signing method (%s)", t.Header["alg"])
+		}
+	case *ecdsa.PublicKey:
+		if _, ok := t.Method.(*jwt.SigningMethodECDSA); !ok {
+			return nil, fmt.Errorf("unexpected signing method (%s)", t.Header["alg"])
+		}
+	}
+	return k, nil
+}
+
+func (a *JWTAuthenticator) refreshing() error {
+	ticker := time.NewTicker(a.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			_, err, _ := a.group.Do("jwks", func() (interface{}, error) {
+				return nil, a.refresh()
+			})
+			if err != nil {
+				a.log.Warn("failed to refresh jwks", log.Error(err))
+			}
+		case <-a.tomb.Dying():
+			return nil
+		}
+	}
+}
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (a *JWTAuthenticator) refresh() error {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	err := a.cli.GetJSON(a.cfg.JWKS, &set)
+	if err != nil {
+		return err
+	}
+	keys := map[string]interface{}{}
+	for _, k := range set.Keys {
+		pub, err := k.publicKey()
+		if err != nil {
+			a.log.Warn("failed to parse jwk", log.Any("kid", k.Kid), log.Error(err))
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+	a.mu.Lock()
+	a.keys = keys
+	a.last = time.Now()
+	a.mu.Unlock()
+	return nil
+}
+
+func (k *jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("curve (%s) not supported", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("key type (%s) not supported", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+func bearerToken(r *http.Request) string {
+	v := r.Header.Get(HeaderAuthorization)
+	if len(v) > 7 && strings.EqualFold(v[:7], "bearer ") {
+		return strings.TrimSpace(v[7:])
+	}
+	return ""
+}
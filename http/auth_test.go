@@ -0,0 +1,246 @@
+package http
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/baetyl/baetyl-go/auth"
+	jwt "github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func echoIdentity() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, _ := IdentityFromContext(r.Context())
+		w.Write([]byte(id))
+	})
+}
+
+func TestTokenAuthenticator(t *testing.T) {
+	h := Authenticate(NewTokenAuthenticator(map[string]string{"t1": "svc1"}))(echoIdentity())
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := serve(h, r)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	r.Header.Set(HeaderAPIKey, "t1")
+	w = serve(h, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "svc1", w.Body.String())
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(HeaderAuthorization, "Bearer t1")
+	w = serve(h, r)
+	assert.Equal(t, "svc1", w.Body.String())
+
+	r.Header.Set(HeaderAuthorization, "Bearer t2")
+	w = serve(h, r)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestJWTAuthenticatorSecret(t *testing.T) {
+	_, err := NewJWTAuthenticator(JWTConfig{})
+	assert.EqualError(t, err, "either secret or jwks is required")
+
+	a, err := NewJWTAuthenticator(JWTConfig{Secret: "secret", Issuer: "baetyl"})
+	assert.NoError(t, err)
+	h := Authenticate(a)(echoIdentity())
+
+	sign := func(claims jwt.StandardClaims, secret string) string {
+		s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+		assert.NoError(t, err)
+		return s
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(HeaderAuthorization, "Bearer "+sign(jwt.StandardClaims{Subject: "node1", Issuer: "baetyl"}, "secret"))
+	w := serve(h, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "node1", w.Body.String())
+
+	r.Header.Set(HeaderAuthorization, "Bearer "+sign(jwt.StandardClaims{Subject: "node1", Issuer: "baetyl"}, "other"))
+	_, err = a.Authenticate(r)
+	assert.Error(t, err)
+
+	r.Header.Set(HeaderAuthorization, "Bearer "+sign(jwt.StandardClaims{Subject: "node1", Issuer: "other"}, "secret"))
+	_, err = a.Authenticate(r)
+	assert.EqualError(t, err, "token is invalid: issuer mismatch")
+
+	r.Header.Set(HeaderAuthorization, "Bearer "+sign(jwt.StandardClaims{Subject: "node1", Issuer: "baetyl", ExpiresAt: time.Now().Add(-time.Minute).Unix()}, "secret"))
+	_, err = a.Authenticate(r)
+	assert.Error(t, err)
+}
+
+func TestJWTAuthenticatorJWKS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": "k1",
+				"kty": "RSA",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}, {
+				"kid": "k2",
+				"kty": "oct",
+			}},
+		})
+	}))
+	defer ts.Close()
+
+	a, err := NewJWTAuthenticator(JWTConfig{JWKS: ts.URL, Audience: "edge", Interval: time.Hour, Client: newClientConfig("")})
+	assert.NoError(t, err)
+	defer a.Close()
+
+	tk := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.StandardClaims{Subject: "app1", Audience: "edge"})
+	tk.Header["kid"] = "k1"
+	raw, err := tk.SignedString(key)
+	assert.NoError(t, err)
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(HeaderAuthorization, "Bearer "+raw)
+	id, err := a.Authenticate(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "app1", id)
+
+	tk.Header["kid"] = "k3"
+	raw, err = tk.SignedString(key)
+	assert.NoError(t, err)
+	r.Header.Set(HeaderAuthorization, "Bearer "+raw)
+	_, err = a.Authenticate(r)
+	assert.EqualError(t, err, "token is invalid: key (k3) not found")
+
+	tk = jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{Subject: "app1", Audience: "edge"})
+	tk.Header["kid"] = "k1"
+	raw, err = tk.SignedString([]byte("secret"))
+	assert.NoError(t, err)
+	r.Header.Set(HeaderAuthorization, "Bearer "+raw)
+	_, err = a.Authenticate(r)
+	assert.EqualError(t, err, "token is invalid: unexpected signing method (HS256)")
+}
+
+func TestJWTAuthenticatorJWKSThrottle(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	var count int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": "k1",
+				"kty": "RSA",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	}))
+	defer ts.Close()
+
+	a, err := NewJWTAuthenticator(JWTConfig{JWKS: ts.URL, Interval: time.Hour, MinInterval: time.Hour, Client: newClientConfig("")})
+	assert.NoError(t, err)
+	defer a.Close()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&count))
+
+	// unknown key ids are rejected without refetching within the minimum interval
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tk := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.StandardClaims{Subject: "app1"})
+			tk.Header["kid"] = fmt.Sprintf("fake%d", i)
+			raw, err := tk.SignedString(key)
+			assert.NoError(t, err)
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set(HeaderAuthorization, "Bearer "+raw)
+			_, err = a.Authenticate(r)
+			assert.Error(t, err)
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&count))
+
+	// the keys are refreshed in background by the interval
+	b, err := NewJWTAuthenticator(JWTConfig{JWKS: ts.URL, Interval: time.Millisecond * 50, MinInterval: time.Hour, Client: newClientConfig("")})
+	assert.NoError(t, err)
+	time.Sleep(time.Millisecond * 180)
+	assert.NoError(t, b.Close())
+	assert.True(t, atomic.LoadInt32(&count) >= 3)
+}
+
+func TestCertAuthenticator(t *testing.T) {
+	data, err := ioutil.ReadFile("../example/var/lib/baetyl/testcert/client.pem")
+	assert.NoError(t, err)
+	block, _ := pem.Decode(data)
+	crt, err := x509.ParseCertificate(block.Bytes)
+	assert.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	_, err = NewCertAuthenticator(nil).Authenticate(r)
+	assert.Equal(t, ErrCertMissing, err)
+
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{crt}}}
+	id, err := NewCertAuthenticator(nil).Authenticate(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "client.example.org", id)
+
+	id, err = NewCertAuthenticator([]string{"client.example.org"}).Authenticate(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "client.example.org", id)
+
+	_, err = NewCertAuthenticator([]string{"other"}).Authenticate(r)
+	assert.Equal(t, ErrCertNotAllowed, err)
+}
+
+func TestGuard(t *testing.T) {
+	a := auth.Chain{
+		auth.NewPasswords(map[string]string{"u1": "p1"}),
+		auth.AuthenticatorFunc(func(c *auth.Credentials) (*auth.Principal, error) {
+			if c.Token != "t1" {
+				return nil, auth.ErrUnauthenticated
+			}
+			return &auth.Principal{Name: "svc1", Method: auth.MethodJWT}, nil
+		}),
+	}
+	z := auth.NewACL([]auth.Rule{{Principal: "*", Permissions: []auth.Permission{
+		{Action: http.MethodGet, Resources: []string{"/v1/#"}},
+	}}})
+	h := Guard(a, z)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, _ := auth.PrincipalFromContext(r.Context())
+		id, _ := IdentityFromContext(r.Context())
+		w.Write([]byte(p.Method + ":" + id))
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/configs", nil)
+	w := serve(h, r)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	r.SetBasicAuth("u1", "p1")
+	w = serve(h, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "password:u1", w.Body.String())
+
+	r = httptest.NewRequest(http.MethodGet, "/v1/configs", nil)
+	r.Header.Set(HeaderAuthorization, "Bearer t1")
+	w = serve(h, r)
+	assert.Equal(t, "jwt:svc1", w.Body.String())
+
+	r = httptest.NewRequest(http.MethodPost, "/v1/configs", nil)
+	r.Header.Set(HeaderAPIKey, "t1")
+	w = serve(h, r)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
@@ -0,0 +1,238 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/baetyl/baetyl-go/codec"
+	"github.com/baetyl/baetyl-go/log"
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/jpillora/backoff"
+)
+
+// all header keys and values
+const (
+	HeaderContentType    = "Content-Type"
+	HeaderIdempotencyKey = "Idempotency-Key"
+	ContentTypeJSON      = codec.ContentTypeJSON
+)
+
+// StatusError the error of unexpected response status
+type StatusError struct {
+	Code    int
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("[%d]", e.Code)
+	}
+	return fmt.Sprintf("[%d] %s", e.Code, e.Message)
+}
+
+// Client http client with retry
+type Client struct {
+	cfg ClientConfig
+	cli *http.Client
+	log *log.Logger
+}
+
+// NewClient creates a new http client
+func NewClient(cc ClientConfig) (*Client, error) {
+	var err error
+	var tc *tls.Config
+	if cc.Certificate.CA != "" || cc.Certificate.Key != "" || cc.Certificate.Cert != "" {
+		tc, err = utils.NewTLSConfigClient(cc.Certificate)
+		if err != nil {
+			return nil, err
+		}
+	}
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   cc.Timeout,
+			KeepAlive: cc.KeepAlive,
+		}).DialContext,
+		MaxIdleConns:        cc.MaxIdleConns,
+		IdleConnTimeout:     cc.IdleConnTimeout,
+		TLSHandshakeTimeout: cc.TLSHandshakeTimeout,
+		TLSClientConfig:     tc,
+	}
+	return &Client{
+		cfg: cc,
+		cli: &http.Client{
+			Timeout:   cc.Timeout,
+			Transport: transport,
+		},
+		log: log.With(log.Any("http", "client")),
+	}, nil
+}
+
+// Call sends a request and returns the response body
+func (c *Client) Call(method, path string, body []byte, header map[string]string) ([]byte, error) {
+	return c.CallContext(context.Background(), method, path, body, header)
+}
+
+// CallContext sends a request with context and returns the response body
+func (c *Client) CallContext(ctx context.Context, method, path string, body []byte, header map[string]string) ([]byte, error) {
+	res, err := c.SendContext(ctx, method, path, body, header)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	return ioutil.ReadAll(res.Body)
+}
+
+// SendContext sends a request with context and returns the response if its status is 2xx,
+// the caller must close the response body
+func (c *Client) SendContext(ctx context.Context, method, path string, body []byte, header map[string]string) (*http.Response, error) {
+	res, err := c.do(ctx, method, path, body, header)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		defer res.Body.Close()
+		msg, _ := ioutil.ReadAll(res.Body)
+		return nil, &StatusError{Code: res.StatusCode, Message: strings.TrimSpace(string(msg))}
+	}
+	return res, nil
+}
+
+// Get sends a get request
+func (c *Client) Get(path string) ([]byte, error) {
+	return c.Call(http.MethodGet, path, nil, nil)
+}
+
+// Post sends a post request
+func (c *Client) Post(path, contentType string, body []byte) ([]byte, error) {
+	return c.Call(http.MethodPost, path, body, map[string]string{HeaderContentType: contentType})
+}
+
+// GetJSON sends a get request and unmarshals the json response into out
+func (c *Client) GetJSON(path string, out interface{}) error {
+	return c.callJSON(http.MethodGet, path, nil, out)
+}
+
+// PostJSON marshals in as json, sends a post request and unmarshals the json response into out
+func (c *Client) PostJSON(path string, in, out interface{}) error {
+	return c.callJSON(http.MethodPost, path, in, out)
+}
+
+// PutJSON marshals in as json, sends a put request and unmarshals the json response into out
+func (c *Client) PutJSON(path string, in, out interface{}) error {
+	return c.callJSON(http.MethodPut, path, in, out)
+}
+
+// Close closes idle connections
+func (c *Client) Close() error {
+	c.cli.CloseIdleConnections()
+	return nil
+}
+
+// CallCodec encodes in by the codec, sends a request and decodes the response into out by the codec
+func (c *Client) CallCodec(method, path string, cd codec.Codec, in, out interface{}) error {
+	var err error
+	var body []byte
+	if in != nil {
+		body, err = cd.Marshal(in)
+		if err != nil {
+			return err
+		}
+	}
+	data, err := c.Call(method, path, body, map[string]string{HeaderContentType: cd.ContentType()})
+	if err != nil {
+		return err
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	return cd.Unmarshal(data, out)
+}
+
+func (c *Client) callJSON(method, path string, in, out interface{}) error {
+	return c.CallCodec(method, path, codec.JSON, in, out)
+}
+
+// do sends the request, which is retried on the transport errors and the retryable status if idempotent,
+// the last error is returned with the error of context if the context is done while waiting to retry
+func (c *Client) do(ctx context.Context, method, path string, body []byte, header map[string]string) (*http.Response, error) {
+	url := c.url(path)
+	maxRetries := c.cfg.MaxRetries
+	if !c.cfg.RetryNonIdempotent && !idempotent(method, header) {
+		maxRetries = 0
+	}
+	bf := backoff.Backoff{
+		Min:    time.Second,
+		Max:    c.cfg.Interval,
+		Factor: 1.6,
+	}
+	for {
+		var r io.Reader
+		if body != nil {
+			r = bytes.NewReader(body)
+		}
+		req, err := http.NewRequest(method, url, r)
+		if err != nil {
+			return nil, err
+		}
+		req = req.WithContext(ctx)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		res, err := c.cli.Do(req)
+		if err == nil && !retryable(res.StatusCode) {
+			return res, nil
+		}
+		if int(bf.Attempt()) >= maxRetries {
+			return res, err
+		}
+		if err == nil {
+			err = &StatusError{Code: res.StatusCode, Message: res.Status}
+			io.Copy(ioutil.Discard, res.Body)
+			res.Body.Close()
+		}
+		d := bf.Duration()
+		c.log.Warn("failed to send request, retry later", log.Any("url", url), log.Any("attempt", bf.Attempt()), log.Any("after", d), log.Error(err))
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %s", ctx.Err(), err.Error())
+		case <-time.After(d):
+		}
+	}
+}
+
+func (c *Client) url(path string) string {
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return path
+	}
+	if path == "" || strings.HasPrefix(path, "/") {
+		return strings.TrimSuffix(c.cfg.Address, "/") + path
+	}
+	return strings.TrimSuffix(c.cfg.Address, "/") + "/" + path
+}
+
+// idempotent checks whether the request can be retried safely, the same as the requests replayed by the transport
+func idempotent(method string, header map[string]string) bool {
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	for k := range header {
+		if http.CanonicalHeaderKey(k) == HeaderIdempotencyKey {
+			return true
+		}
+	}
+	return false
+}
+
+func retryable(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
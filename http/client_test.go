@@ -0,0 +1,285 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/creasty/defaults"
+	"github.com/stretchr/testify/assert"
+)
+
+type pet struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+func newClientConfig(address string) (c ClientConfig) {
+	defaults.Set(&c)
+	c.Address = address
+	c.Interval = time.Millisecond * 10
+	return
+}
+
+func TestClientJSON(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			w.Write([]byte(`{"name":"cat","age":1}`))
+		case http.MethodPost:
+			assert.Equal(t, ContentTypeJSON, r.Header.Get(HeaderContentType))
+			var p pet
+			data, err := ioutil.ReadAll(r.Body)
+			assert.NoError(t, err)
+			assert.NoError(t, json.Unmarshal(data, &p))
+			p.Age++
+			data, _ = json.Marshal(p)
+			w.Write(data)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			w.Write([]byte("not allowed"))
+		}
+	}))
+	defer ts.Close()
+
+	cli, err := NewClient(newClientConfig(ts.URL))
+	assert.NoError(t, err)
+	defer cli.Close()
+
+	var p pet
+	err = cli.GetJSON("/pets/cat", &p)
+	assert.NoError(t, err)
+	assert.Equal(t, pet{Name: "cat", Age: 1}, p)
+
+	var q pet
+	err = cli.PostJSON("pets", &p, &q)
+	assert.NoError(t, err)
+	assert.Equal(t, pet{Name: "cat", Age: 2}, q)
+
+	err = cli.PutJSON(ts.URL+"/pets", &p, &q)
+	assert.EqualError(t, err, "[405] not allowed")
+	serr, ok := err.(*StatusError)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusMethodNotAllowed, serr.Code)
+}
+
+func TestClientRetry(t *testing.T) {
+	count := int32(0)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		assert.Equal(t, "ping", string(data))
+		if atomic.AddInt32(&count, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("pong"))
+	}))
+	defer ts.Close()
+
+	// the non-idempotent request is not retried by default
+	cli, err := NewClient(newClientConfig(ts.URL))
+	assert.NoError(t, err)
+	_, err = cli.Post("/", "text/plain", []byte("ping"))
+	assert.EqualError(t, err, "[503]")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&count))
+
+	// retried if with the idempotency key
+	res, err := cli.Call(http.MethodPost, "/", []byte("ping"), map[string]string{HeaderIdempotencyKey: "k1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "pong", string(res))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&count))
+
+	// the last error is returned with the error of context if done while waiting to retry
+	atomic.StoreInt32(&count, -1000)
+	cfg := newClientConfig(ts.URL)
+	cfg.MaxRetries = 1000
+	cli, err = NewClient(cfg)
+	assert.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = cli.CallContext(ctx, http.MethodGet, "/", []byte("ping"), nil)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Contains(t, err.Error(), "[503]")
+
+	atomic.StoreInt32(&count, -10)
+	cfg = newClientConfig(ts.URL)
+	cfg.MaxRetries = 1
+	cfg.RetryNonIdempotent = true
+	cli, err = NewClient(cfg)
+	assert.NoError(t, err)
+	_, err = cli.Post("/", "text/plain", []byte("ping"))
+	assert.EqualError(t, err, "[503]")
+	assert.Equal(t, int32(-8), atomic.LoadInt32(&count))
+
+	cfg = newClientConfig("http://127.0.0.1:1")
+	cfg.MaxRetries = 0
+	cli, err = NewClient(cfg)
+	assert.NoError(t, err)
+	_, err = cli.Get("/")
+	assert.Error(t, err)
+}
+
+func TestClientTLS(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Len(t, r.TLS.PeerCertificates, 1)
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	tc, err := utils.NewTLSConfigServer(utils.Certificate{
+		CA:   "../example/var/lib/baetyl/testcert/ca.pem",
+		Key:  "../example/var/lib/baetyl/testcert/server.key",
+		Cert: "../example/var/lib/baetyl/testcert/server.pem",
+	})
+	assert.NoError(t, err)
+	ts.TLS = tc
+	ts.StartTLS()
+	defer ts.Close()
+
+	cfg := newClientConfig(ts.URL)
+	cfg.Certificate = utils.Certificate{
+		CA:                 "../example/var/lib/baetyl/testcert/ca.pem",
+		Key:                "../example/var/lib/baetyl/testcert/client.key",
+		Cert:               "../example/var/lib/baetyl/testcert/client.pem",
+		InsecureSkipVerify: true,
+	}
+	cli, err := NewClient(cfg)
+	assert.NoError(t, err)
+	res, err := cli.Get("/")
+	assert.NoError(t, err)
+	assert.Equal(t, "client.example.org", string(res))
+
+	cfg.Certificate.Key = "../example/var/lib/baetyl/testcert/notexist.key"
+	_, err = NewClient(cfg)
+	assert.Error(t, err)
+}
+
+func TestClientDownload(t *testing.T) {
+	dir, err := ioutil.TempDir("", t.Name())
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	content := []byte(strings.Repeat("baetyl", 1000))
+	var broken int32
+	var ranges []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		switch r.URL.Path {
+		case "/broken":
+			// the stream is broken after half of the content is sent at the first time
+			if atomic.AddInt32(&broken, 1) == 1 {
+				w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+				w.Write(content[:len(content)/2])
+				w.(http.Flusher).Flush()
+				panic(http.ErrAbortHandler)
+			}
+		case "/norange":
+			w.Write(content)
+			return
+		case "/file":
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("not found"))
+			return
+		}
+		ranges = append(ranges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(content))
+	}))
+	defer ts.Close()
+
+	cli, err := NewClient(newClientConfig(ts.URL))
+	assert.NoError(t, err)
+	defer cli.Close()
+
+	file := filepath.Join(dir, "a", "file")
+	assert.NoError(t, cli.Download(context.Background(), "/file", file))
+	data, err := ioutil.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, content, data)
+	assert.Equal(t, []string{""}, ranges)
+
+	// resumes the download interrupted last time
+	ranges = nil
+	file = filepath.Join(dir, "resumed")
+	meta := fmt.Sprintf(`{"validator":"\"v1\"","size":%d}`, len(content))
+	assert.NoError(t, ioutil.WriteFile(file+downloadSuffix, content[:100], 0644))
+	assert.NoError(t, ioutil.WriteFile(file+downloadSuffix+metaSuffix, []byte(meta), 0644))
+	assert.NoError(t, cli.Download(context.Background(), "/file", file))
+	data, err = ioutil.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, content, data)
+	assert.Equal(t, []string{"bytes=100-"}, ranges)
+	_, err = os.Stat(file + downloadSuffix)
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(file + downloadSuffix + metaSuffix)
+	assert.True(t, os.IsNotExist(err))
+
+	// the temporary file is complete already
+	assert.NoError(t, ioutil.WriteFile(file+downloadSuffix, content, 0644))
+	assert.NoError(t, ioutil.WriteFile(file+downloadSuffix+metaSuffix, []byte(meta), 0644))
+	assert.NoError(t, cli.Download(context.Background(), "/file", file))
+	data, err = ioutil.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, content, data)
+
+	// restarts if the temporary file is not verified by the meta
+	ranges = nil
+	assert.NoError(t, ioutil.WriteFile(file+downloadSuffix, []byte("xxx"), 0644))
+	assert.NoError(t, cli.Download(context.Background(), "/file", file))
+	data, err = ioutil.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, content, data)
+	assert.Equal(t, []string{""}, ranges)
+
+	// restarts if the file is changed
+	ranges = nil
+	assert.NoError(t, ioutil.WriteFile(file+downloadSuffix, []byte("xxx"), 0644))
+	assert.NoError(t, ioutil.WriteFile(file+downloadSuffix+metaSuffix, []byte(fmt.Sprintf(`{"validator":"\"v0\"","size":%d}`, len(content))), 0644))
+	assert.NoError(t, cli.Download(context.Background(), "/file", file))
+	data, err = ioutil.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, content, data)
+	assert.Equal(t, []string{"bytes=3-"}, ranges)
+
+	// restarts if the temporary file is larger than the file, which is shrunk
+	ranges = nil
+	assert.NoError(t, ioutil.WriteFile(file+downloadSuffix, append(content, 'x'), 0644))
+	assert.NoError(t, ioutil.WriteFile(file+downloadSuffix+metaSuffix, []byte(`{"validator":"\"v1\"","size":-1}`), 0644))
+	assert.NoError(t, cli.Download(context.Background(), "/file", file))
+	data, err = ioutil.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, content, data)
+	assert.Equal(t, []string{fmt.Sprintf("bytes=%d-", len(content)+1), ""}, ranges)
+
+	// resumes the stream broken
+	ranges = nil
+	file = filepath.Join(dir, "broken")
+	assert.NoError(t, cli.Download(context.Background(), "/broken", file))
+	data, err = ioutil.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, content, data)
+	assert.Len(t, ranges, 1)
+	assert.True(t, strings.HasPrefix(ranges[0], "bytes="))
+
+	// restarts if the range is not supported
+	file = filepath.Join(dir, "norange")
+	assert.NoError(t, ioutil.WriteFile(file+downloadSuffix, []byte("xxx"), 0644))
+	assert.NoError(t, cli.Download(context.Background(), "/norange", file))
+	data, err = ioutil.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, content, data)
+
+	err = cli.Download(context.Background(), "/missing", filepath.Join(dir, "missing"))
+	assert.Equal(t, &StatusError{Code: http.StatusNotFound, Message: "not found"}, err)
+}
@@ -0,0 +1,63 @@
+package http
+
+import (
+	"time"
+
+	"github.com/baetyl/baetyl-go/utils"
+)
+
+// ClientConfig http client config
+type ClientConfig struct {
+	Address             string            `yaml:"address" json:"address" validate:"regexp=^(https?://.+)?$"` // the base url of requests, such as https://127.0.0.1:443
+	Certificate         utils.Certificate `yaml:",inline" json:",inline"`
+	Timeout             time.Duration     `yaml:"timeout" json:"timeout" default:"30s"`
+	KeepAlive           time.Duration     `yaml:"keepalive" json:"keepalive" default:"30s"`
+	MaxIdleConns        int               `yaml:"maxIdleConns" json:"maxIdleConns" default:"100"`
+	IdleConnTimeout     time.Duration     `yaml:"idleConnTimeout" json:"idleConnTimeout" default:"90s"`
+	TLSHandshakeTimeout time.Duration     `yaml:"tlsHandshakeTimeout" json:"tlsHandshakeTimeout" default:"10s"`
+	MaxRetries          int               `yaml:"maxRetries" json:"maxRetries" default:"3"`
+	Interval            time.Duration     `yaml:"interval" json:"interval" default:"10s"`
+	// the requests of non-idempotent methods (such as post and put) are only retried if enabled
+	// or with the idempotency key header, since the retry may duplicate the side effects
+	RetryNonIdempotent bool `yaml:"retryNonIdempotent" json:"retryNonIdempotent"`
+}
+
+// ServerConfig http server config
+type ServerConfig struct {
+	Address         string            `yaml:"address" json:"address" default:":80" validate:"regexp=^((unix|tcp|http|https)://.+|[^/]*:[0-9]+)$"` // the address to listen, such as :80 or unix:///var/run/http.sock
+	Certificate     utils.Certificate `yaml:",inline" json:",inline"`
+	ReadTimeout     time.Duration     `yaml:"readTimeout" json:"readTimeout" default:"30s"`
+	WriteTimeout    time.Duration     `yaml:"writeTimeout" json:"writeTimeout" default:"30s"`
+	IdleTimeout     time.Duration     `yaml:"idleTimeout" json:"idleTimeout" default:"2m"`
+	ShutdownTimeout time.Duration     `yaml:"shutdownTimeout" json:"shutdownTimeout" default:"10s"`
+	MaxHeaderBytes  utils.Size        `yaml:"maxHeaderBytes" json:"maxHeaderBytes" default:"1048576"`
+}
+
+// JWTConfig jwt authentication config, the token is verified by the secret (HMAC) or the keys from jwks url
+type JWTConfig struct {
+	Secret   string        `yaml:"secret" json:"secret"`
+	JWKS     string        `yaml:"jwks" json:"jwks"`
+	Issuer   string        `yaml:"issuer" json:"issuer"`
+	Audience string        `yaml:"audience" json:"audience"`
+	Interval time.Duration `yaml:"interval" json:"interval" default:"1h"`
+	// the minimum interval between two refreshes triggered by unknown key ids
+	MinInterval time.Duration `yaml:"minInterval" json:"minInterval" default:"1m"`
+	Client      ClientConfig  `yaml:"client" json:"client"`
+}
+
+// ProxyConfig reverse proxy config
+type ProxyConfig struct {
+	Rules []ProxyRule `yaml:"rules" json:"rules"`
+}
+
+// ProxyRule routes the requests matched by host and path prefix to the target,
+// the certificate is used to connect the target if its scheme is https
+type ProxyRule struct {
+	Host          string            `yaml:"host" json:"host"`
+	Path          string            `yaml:"path" json:"path" default:"/"`
+	StripPrefix   bool              `yaml:"stripPrefix" json:"stripPrefix"`
+	Target        string            `yaml:"target" json:"target" validate:"nonzero"`
+	Certificate   utils.Certificate `yaml:",inline" json:",inline"`
+	SetHeaders    map[string]string `yaml:"setHeaders" json:"setHeaders"`
+	RemoveHeaders []string          `yaml:"removeHeaders" json:"removeHeaders"`
+}
@@ -0,0 +1,214 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/baetyl/baetyl-go/log"
+	"github.com/jpillora/backoff"
+)
+
+// the suffixes of the temporary file downloading and its meta, the meta keeps the validator and the size of the file
+// to resume the download by the same file
+const (
+	downloadSuffix = ".part"
+	metaSuffix     = ".meta"
+)
+
+const (
+	headerRange        = "Range"
+	headerIfRange      = "If-Range"
+	headerContentRange = "Content-Range"
+	headerETag         = "ETag"
+	headerLastModified = "Last-Modified"
+)
+
+// downloadMeta the meta of the file downloading
+type downloadMeta struct {
+	Validator string `json:"validator"` // the strong etag or the last modified time of the file
+	Size      int64  `json:"size"`      // the total size of the file, -1 if unknown
+}
+
+// Download downloads the file from the path streamingly, the content is written to the temporary file suffixed
+// with .part and renamed to the file once completed. The download is resumed by the range request from the size
+// of the temporary file, such as the stream is broken or the last download is interrupted, the range request is
+// conditioned by the etag or the last modified time got at the beginning, so that the content is not mixed up if
+// the file is changed. It restarts from the beginning if the file is changed, or the server does not support
+// range requests or validators
+func (c *Client) Download(ctx context.Context, path, file string) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	part := file + downloadSuffix
+	bf := backoff.Backoff{
+		Min:    time.Second,
+		Max:    c.cfg.Interval,
+		Factor: 1.6,
+	}
+	for {
+		resumable, err := c.download(ctx, path, part)
+		if err == nil {
+			c.log.Debug("file is downloaded", log.Any("path", path), log.Any("file", file))
+			os.Remove(part + metaSuffix)
+			return os.Rename(part, file)
+		}
+		if !resumable || ctx.Err() != nil || int(bf.Attempt()) >= c.cfg.MaxRetries {
+			return err
+		}
+		d := bf.Duration()
+		c.log.Warn("failed to download, resume later", log.Any("path", path), log.Any("attempt", bf.Attempt()), log.Any("after", d), log.Error(err))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(d):
+		}
+	}
+}
+
+// download appends the content from the size of the temporary file, the download is resumable
+// if the stream is broken, the errors of requests are already retried by the client
+func (c *Client) download(ctx context.Context, path, part string) (bool, error) {
+	f, err := os.OpenFile(part, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return false, err
+	}
+	meta, err := readDownloadMeta(part + metaSuffix)
+	if offset > 0 && (err != nil || meta.Validator == "" || (meta.Size >= 0 && offset > meta.Size)) {
+		// the temporary file can not be verified, restarts from the beginning
+		c.log.Debug("temporary file is stale, restart downloading", log.Any("path", path), log.Any("offset", offset))
+		if offset, err = restart(f); err != nil {
+			return false, err
+		}
+	}
+	var header map[string]string
+	if offset > 0 {
+		header = map[string]string{
+			headerRange:   fmt.Sprintf("bytes=%d-", offset),
+			headerIfRange: meta.Validator,
+		}
+	}
+	res, err := c.do(ctx, http.MethodGet, path, nil, header)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	switch {
+	case res.StatusCode == http.StatusPartialContent && offset > 0:
+		start, total, err := parseContentRange(res.Header.Get(headerContentRange))
+		if err != nil {
+			return false, err
+		}
+		if start != offset || (meta.Size >= 0 && total >= 0 && total != meta.Size) {
+			return false, fmt.Errorf("content range (%s) mismatches the file downloading (offset %d, size %d)", res.Header.Get(headerContentRange), offset, meta.Size)
+		}
+	case res.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// the content is downloaded completely last time only if the size is the same
+		_, total, err := parseContentRange(res.Header.Get(headerContentRange))
+		if err == nil && total == offset && (meta.Size < 0 || meta.Size == total) {
+			return false, nil
+		}
+		io.Copy(ioutil.Discard, res.Body)
+		res.Body.Close()
+		if _, err = restart(f); err != nil {
+			return false, err
+		}
+		f.Close()
+		return c.download(ctx, path, part)
+	case res.StatusCode >= 200 && res.StatusCode < 300:
+		// the range is ignored by the server or the file is changed, restarts from the beginning
+		if offset > 0 {
+			if offset, err = restart(f); err != nil {
+				return false, err
+			}
+		}
+		meta = downloadMeta{Validator: validator(res.Header), Size: res.ContentLength}
+		if err = writeDownloadMeta(part+metaSuffix, meta); err != nil {
+			return false, err
+		}
+	default:
+		msg, _ := ioutil.ReadAll(res.Body)
+		return false, &StatusError{Code: res.StatusCode, Message: strings.TrimSpace(string(msg))}
+	}
+	n, err := io.Copy(f, res.Body)
+	if err != nil {
+		return true, err
+	}
+	if meta.Size >= 0 && offset+n != meta.Size {
+		return true, fmt.Errorf("size (%d) of file downloaded mismatches the expected (%d)", offset+n, meta.Size)
+	}
+	return false, nil
+}
+
+// restart truncates the temporary file to download from the beginning
+func restart(f *os.File) (int64, error) {
+	if err := f.Truncate(0); err != nil {
+		return 0, err
+	}
+	return f.Seek(0, io.SeekStart)
+}
+
+// validator returns the strong etag, or the last modified time if not present, which conditions the range requests
+func validator(h http.Header) string {
+	if etag := h.Get(headerETag); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return h.Get(headerLastModified)
+}
+
+// parseContentRange parses the content range, such as "bytes 100-199/200" and "bytes */200",
+// the start is -1 if the range is unsatisfied and the total is -1 if unknown
+func parseContentRange(v string) (int64, int64, error) {
+	if !strings.HasPrefix(v, "bytes ") {
+		return 0, 0, fmt.Errorf("content range (%s) is invalid", v)
+	}
+	parts := strings.SplitN(strings.TrimPrefix(v, "bytes "), "/", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("content range (%s) is invalid", v)
+	}
+	start, total := int64(-1), int64(-1)
+	var err error
+	if parts[0] != "*" {
+		bounds := strings.SplitN(parts[0], "-", 2)
+		if start, err = strconv.ParseInt(bounds[0], 10, 64); err != nil {
+			return 0, 0, fmt.Errorf("content range (%s) is invalid", v)
+		}
+	}
+	if parts[1] != "*" {
+		if total, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+			return 0, 0, fmt.Errorf("content range (%s) is invalid", v)
+		}
+	}
+	return start, total, nil
+}
+
+func readDownloadMeta(path string) (downloadMeta, error) {
+	var meta downloadMeta
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return meta, err
+	}
+	err = json.Unmarshal(data, &meta)
+	return meta, err
+}
+
+func writeDownloadMeta(path string, meta downloadMeta) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
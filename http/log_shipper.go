@@ -0,0 +1,72 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/baetyl/baetyl-go/log"
+	"github.com/baetyl/baetyl-go/utils"
+)
+
+// all header keys and values of the batches of log entries
+const (
+	HeaderContentEncoding = "Content-Encoding"
+	ContentTypeJSONLines  = "application/x-ndjson"
+)
+
+func init() {
+	log.RegisterShipper("http", newLogShipper)
+	log.RegisterShipper("https", newLogShipper)
+}
+
+// logShipper the shipper of remote log sink posting the batches of entries to the endpoint
+type logShipper struct {
+	cli  *Client
+	path string
+}
+
+// NewLogShipper creates the shipper of remote log sink, which posts the batches in json lines compressed by gzip
+// to the path by the client, and closes the client when closed
+func NewLogShipper(cli *Client, path string) log.Shipper {
+	return &logShipper{cli: cli, path: path}
+}
+
+// Ship posts the batch, the status not 2xx is an error
+func (s *logShipper) Ship(ctx context.Context, data []byte) error {
+	header := map[string]string{
+		HeaderContentType:     ContentTypeJSONLines,
+		HeaderContentEncoding: "gzip",
+	}
+	_, err := s.cli.CallContext(ctx, http.MethodPost, s.path, data, header)
+	return err
+}
+
+// Close closes the client
+func (s *logShipper) Close() error {
+	return s.cli.Close()
+}
+
+// newLogShipper creates the shipper by the url, such as https://host/v1/logs?ca=ca.pem&cert=client.pem&key=client.key,
+// the certificate is optional, the batch failed is retried by the remote sink instead of the client
+func newLogShipper(u *url.URL) (log.Shipper, error) {
+	var cc ClientConfig
+	if err := utils.SetDefaults(&cc); err != nil {
+		return nil, err
+	}
+	args := u.Query()
+	cc.Certificate.CA = args.Get("ca")
+	cc.Certificate.Cert = args.Get("cert")
+	cc.Certificate.Key = args.Get("key")
+	cc.MaxRetries = 0
+	args.Del("ca")
+	args.Del("cert")
+	args.Del("key")
+	endpoint := *u
+	endpoint.RawQuery = args.Encode()
+	cli, err := NewClient(cc)
+	if err != nil {
+		return nil, err
+	}
+	return NewLogShipper(cli, endpoint.String()), nil
+}
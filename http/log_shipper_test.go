@@ -0,0 +1,58 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogShipper(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/v1/logs", r.URL.Path)
+		assert.Equal(t, "node=1", r.URL.RawQuery)
+		assert.Equal(t, ContentTypeJSONLines, r.Header.Get(HeaderContentType))
+		assert.Equal(t, "gzip", r.Header.Get(HeaderContentEncoding))
+		zr, err := gzip.NewReader(r.Body)
+		assert.NoError(t, err)
+		data, err := ioutil.ReadAll(zr)
+		assert.NoError(t, err)
+		if string(data) == "bad" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		assert.Equal(t, "{\"msg\":\"a\"}\n", string(data))
+	}))
+	defer ts.Close()
+
+	compress := func(s string) []byte {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write([]byte(s))
+		zw.Close()
+		return buf.Bytes()
+	}
+
+	u, err := url.Parse(ts.URL + "/v1/logs?node=1&ca=")
+	assert.NoError(t, err)
+	shipper, err := newLogShipper(u)
+	assert.NoError(t, err)
+	defer shipper.Close()
+	assert.NoError(t, shipper.Ship(context.Background(), compress("{\"msg\":\"a\"}\n")))
+	// not retried by the client but the remote sink
+	err = shipper.Ship(context.Background(), compress("bad"))
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusInternalServerError, err.(*StatusError).Code)
+
+	u, err = url.Parse(ts.URL + "/v1/logs?ca=notexist.pem")
+	assert.NoError(t, err)
+	_, err = newLogShipper(u)
+	assert.Error(t, err)
+}
@@ -0,0 +1,156 @@
+package http
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/baetyl/baetyl-go/log"
+)
+
+// HeaderRequestID the header key of request id
+const HeaderRequestID = "X-Request-Id"
+
+// RequestID reuses the request id from header or generates a new one,
+// sets it into the response header and puts a logger with the request id into the request context
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(HeaderRequestID)
+		if id == "" {
+			id = newRequestID()
+			r.Header.Set(HeaderRequestID, id)
+		}
+		w.Header().Set(HeaderRequestID, id)
+		l := log.FromContext(r.Context()).With(log.Any("requestId", id))
+		next.ServeHTTP(w, r.WithContext(log.NewContext(r.Context(), l)))
+	})
+}
+
+// Logging logs every request with the logger in the request context
+func Logging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := NewResponseWriter(w)
+		next.ServeHTTP(rw, r)
+		l := log.FromContext(r.Context())
+		fs := []log.Field{
+			log.Any("method", r.Method),
+			log.Any("path", r.URL.Path),
+			log.Any("remote", r.RemoteAddr),
+			log.Any("status", rw.Status()),
+			log.Any("size", rw.Size()),
+			log.Any("cost", time.Since(start)),
+		}
+		if rw.Status() >= http.StatusInternalServerError {
+			l.Warn("server handled a request", fs...)
+		} else {
+			l.Info("server handled a request", fs...)
+		}
+	})
+}
+
+// Recovery recovers the panic in handler and responds internal server error
+func Recovery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if p := recover(); p != nil {
+				log.FromContext(r.Context()).Error("server recovered from panic", log.Any("panic", fmt.Sprintf("%v", p)), log.Any("stack", string(debug.Stack())))
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// LimitSize rejects the request if its body is larger than max bytes
+func LimitSize(max int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > max {
+				http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, max)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ResponseWriter the response writer recording the status and the size of response, which forwards Flush, Hijack
+// and Push to the underlying writer if supported, such as to upgrade the websocket connections behind the middlewares
+type ResponseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+// NewResponseWriter wraps the response writer, the status is 200 unless written
+func NewResponseWriter(w http.ResponseWriter) *ResponseWriter {
+	return &ResponseWriter{ResponseWriter: w, status: http.StatusOK}
+}
+
+// Status returns the status of response, which is 101 if the connection is hijacked
+func (w *ResponseWriter) Status() int {
+	return w.status
+}
+
+// Size returns the size of response body written
+func (w *ResponseWriter) Size() int {
+	return w.size
+}
+
+// WriteHeader records the status and writes it
+func (w *ResponseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the size and writes the data
+func (w *ResponseWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
+
+// Flush implements http.Flusher if the underlying writer supports it
+func (w *ResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker, returns the error if the underlying writer doesn't support it
+func (w *ResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer (%T) does not implement http.Hijacker", w.ResponseWriter)
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// Push implements http.Pusher, returns http.ErrNotSupported if the underlying writer doesn't support it
+func (w *ResponseWriter) Push(target string, opts *http.PushOptions) error {
+	p, ok := w.ResponseWriter.(http.Pusher)
+	if !ok {
+		return http.ErrNotSupported
+	}
+	return p.Push(target, opts)
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
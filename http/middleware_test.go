@@ -0,0 +1,108 @@
+package http
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/baetyl/baetyl-go/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func serve(h http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestRequestID(t *testing.T) {
+	var id string
+	var l *log.Logger
+	h := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id = r.Header.Get(HeaderRequestID)
+		l = log.FromContext(r.Context())
+	}))
+
+	w := serve(h, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Len(t, id, 32)
+	assert.Equal(t, id, w.Header().Get(HeaderRequestID))
+	assert.NotEqual(t, log.L(), l)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(HeaderRequestID, "abc")
+	w = serve(h, r)
+	assert.Equal(t, "abc", id)
+	assert.Equal(t, "abc", w.Header().Get(HeaderRequestID))
+}
+
+func TestLoggingAndRecovery(t *testing.T) {
+	h := Logging(Recovery(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/panic" {
+			panic("boom")
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("created"))
+	})))
+
+	w := serve(h, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "created", w.Body.String())
+
+	w = serve(h, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "Internal Server Error\n", w.Body.String())
+}
+
+func TestLimitSize(t *testing.T) {
+	h := LimitSize(4)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+
+	w := serve(h, httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString("1234")))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = serve(h, httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString("12345")))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString("12345"))
+	r.ContentLength = -1
+	w = serve(h, r)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Contains(t, w.Body.String(), "too large")
+}
+
+func TestResponseWriterHijack(t *testing.T) {
+	ts := httptest.NewServer(Logging(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		assert.NoError(t, err)
+		defer conn.Close()
+		rw.WriteString("hijacked")
+		rw.Flush()
+		assert.Equal(t, http.StatusSwitchingProtocols, w.(*ResponseWriter).Status())
+	})))
+	defer ts.Close()
+
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	assert.NoError(t, err)
+	data, err := ioutil.ReadAll(conn)
+	assert.NoError(t, err)
+	assert.Equal(t, "hijacked", string(data))
+
+	// not supported by the underlying writer
+	rw := NewResponseWriter(httptest.NewRecorder())
+	_, _, err = rw.Hijack()
+	assert.Error(t, err)
+	assert.Equal(t, http.ErrNotSupported, rw.Push("/", nil))
+	assert.Equal(t, http.StatusOK, rw.Status())
+}
@@ -0,0 +1,159 @@
+package http
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/baetyl/baetyl-go/log"
+	"github.com/baetyl/baetyl-go/utils"
+)
+
+// Proxy reverse proxy which routes requests to targets by rules
+type Proxy struct {
+	routes []*route
+	log    *log.Logger
+}
+
+type route struct {
+	rule   ProxyRule
+	target *url.URL
+	proxy  *httputil.ReverseProxy
+}
+
+// NewProxy creates a new reverse proxy
+func NewProxy(cfg ProxyConfig) (*Proxy, error) {
+	p := &Proxy{
+		log: log.With(log.Any("http", "proxy")),
+	}
+	for _, rule := range cfg.Rules {
+		r, err := p.newRoute(rule)
+		if err != nil {
+			return nil, err
+		}
+		p.routes = append(p.routes, r)
+	}
+	// the longest path prefix takes precedence, then the rule with host
+	sort.SliceStable(p.routes, func(i, j int) bool {
+		a, b := p.routes[i].rule, p.routes[j].rule
+		if len(a.Path) != len(b.Path) {
+			return len(a.Path) > len(b.Path)
+		}
+		return a.Host != "" && b.Host == ""
+	})
+	return p, nil
+}
+
+// ServeHTTP routes the request, responds 404 if no rule matched
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	for _, rt := range p.routes {
+		if rt.match(r) {
+			rt.proxy.ServeHTTP(w, r)
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+func (p *Proxy) newRoute(rule ProxyRule) (*route, error) {
+	if rule.Path == "" {
+		rule.Path = "/"
+	}
+	target, err := url.Parse(rule.Target)
+	if err != nil {
+		return nil, err
+	}
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return nil, fmt.Errorf("target (%s) scheme not supported", rule.Target)
+	}
+	var tc *tls.Config
+	if target.Scheme == "https" && (rule.Certificate.CA != "" || rule.Certificate.Key != "" || rule.Certificate.Cert != "") {
+		tc, err = utils.NewTLSConfigClient(rule.Certificate)
+		if err != nil {
+			return nil, err
+		}
+		if !rule.Certificate.InsecureSkipVerify && rule.Certificate.Name != "" {
+			tc.ServerName = rule.Certificate.Name
+		}
+	}
+	rt := &route{rule: rule, target: target}
+	rt.proxy = &httputil.ReverseProxy{
+		Director: rt.direct,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: tc,
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			p.log.Warn("failed to proxy request", log.Any("path", r.URL.Path), log.Any("target", rule.Target), log.Error(err))
+			http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		},
+	}
+	return rt, nil
+}
+
+func (rt *route) match(r *http.Request) bool {
+	if rt.rule.Host != "" && !strings.EqualFold(hostname(r.Host), rt.rule.Host) {
+		return false
+	}
+	return matchPrefix(r.URL.Path, rt.rule.Path)
+}
+
+// matchPrefix matches the path by the prefix at the segment boundary, such as /api matches /api and /api/v1
+// but not /apiadmin
+func matchPrefix(path, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || strings.HasSuffix(prefix, "/") || path[len(prefix)] == '/'
+}
+
+func (rt *route) direct(r *http.Request) {
+	path := r.URL.Path
+	if rt.rule.StripPrefix {
+		path = "/" + strings.TrimPrefix(strings.TrimPrefix(path, rt.rule.Path), "/")
+	}
+	r.URL.Scheme = rt.target.Scheme
+	r.URL.Host = rt.target.Host
+	r.URL.Path = singleJoiningSlash(rt.target.Path, path)
+	r.URL.RawPath = ""
+	if rt.target.RawQuery == "" || r.URL.RawQuery == "" {
+		r.URL.RawQuery = rt.target.RawQuery + r.URL.RawQuery
+	} else {
+		r.URL.RawQuery = rt.target.RawQuery + "&" + r.URL.RawQuery
+	}
+	r.Host = rt.target.Host
+	if _, ok := r.Header["User-Agent"]; !ok {
+		r.Header.Set("User-Agent", "")
+	}
+	for _, k := range rt.rule.RemoveHeaders {
+		r.Header.Del(k)
+	}
+	for k, v := range rt.rule.SetHeaders {
+		r.Header.Set(k, v)
+	}
+}
+
+func hostname(host string) string {
+	h, _, err := net.SplitHostPort(host)
+	if err != nil {
+		return host
+	}
+	return h
+}
+
+func singleJoiningSlash(a, b string) string {
+	aslash := strings.HasSuffix(a, "/")
+	bslash := strings.HasPrefix(b, "/")
+	switch {
+	case aslash && bslash:
+		return a + b[1:]
+	case !aslash && !bslash:
+		return a + "/" + b
+	}
+	return a + b
+}
@@ -0,0 +1,71 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxy(t *testing.T) {
+	backend := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, "%s %s %s %s", name, r.URL.Path, r.Header.Get("X-Module"), r.Header.Get("X-Secret"))
+		}))
+	}
+	a := backend("a")
+	defer a.Close()
+	b := backend("b")
+	defer b.Close()
+
+	p, err := NewProxy(ProxyConfig{Rules: []ProxyRule{
+		{Path: "/", Target: a.URL},
+		{Path: "/b/", Target: b.URL + "/api", StripPrefix: true, SetHeaders: map[string]string{"X-Module": "b"}, RemoveHeaders: []string{"X-Secret"}},
+		{Host: "c.local", Path: "/b/", Target: a.URL},
+	}})
+	assert.NoError(t, err)
+	svr := httptest.NewServer(p)
+	defer svr.Close()
+
+	get := func(path, host string) (int, string) {
+		req, err := http.NewRequest(http.MethodGet, svr.URL+path, nil)
+		assert.NoError(t, err)
+		req.Host = host
+		req.Header.Set("X-Secret", "s")
+		cli, err := NewClient(newClientConfig(""))
+		assert.NoError(t, err)
+		res, err := cli.cli.Do(req)
+		assert.NoError(t, err)
+		defer res.Body.Close()
+		buf := make([]byte, 1024)
+		n, _ := res.Body.Read(buf)
+		return res.StatusCode, string(buf[:n])
+	}
+
+	code, body := get("/x/y", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "a /x/y  s", body)
+
+	_, body = get("/b/v1/items", "")
+	assert.Equal(t, "b /api/v1/items b ", body)
+
+	_, body = get("/b/v1/items", "c.local:8080")
+	assert.Equal(t, "a /b/v1/items  s", body)
+
+	p, err = NewProxy(ProxyConfig{Rules: []ProxyRule{{Path: "/only", Target: "http://127.0.0.1:1"}}})
+	assert.NoError(t, err)
+	w := serve(p, httptest.NewRequest(http.MethodGet, "/other", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = serve(p, httptest.NewRequest(http.MethodGet, "/only", nil))
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	w = serve(p, httptest.NewRequest(http.MethodGet, "/only/x", nil))
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	// the prefix is matched at the segment boundary
+	w = serve(p, httptest.NewRequest(http.MethodGet, "/onlyadmin/x", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	_, err = NewProxy(ProxyConfig{Rules: []ProxyRule{{Target: "ftp://127.0.0.1"}}})
+	assert.EqualError(t, err, "target (ftp://127.0.0.1) scheme not supported")
+}
@@ -0,0 +1,132 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/baetyl/baetyl-go/log"
+	"github.com/baetyl/baetyl-go/utils"
+)
+
+// Middleware wraps a http handler
+type Middleware func(http.Handler) http.Handler
+
+// Server http server with graceful shutdown
+type Server struct {
+	cfg ServerConfig
+	mux *http.ServeMux
+	svr *http.Server
+	lis net.Listener
+	log *log.Logger
+	utils.Tomb
+}
+
+// NewServer creates a new http server and starts to serve,
+// the middlewares are applied in order, the first one is the outermost
+func NewServer(cfg ServerConfig, mws ...Middleware) (*Server, error) {
+	var err error
+	var tc *tls.Config
+	if cfg.Certificate.Key != "" || cfg.Certificate.Cert != "" {
+		tc, err = utils.NewTLSConfigServer(cfg.Certificate)
+		if err != nil {
+			return nil, err
+		}
+	}
+	lis, err := listen(cfg.Address)
+	if err != nil {
+		return nil, err
+	}
+	if tc != nil {
+		lis = tls.NewListener(lis, tc)
+	}
+	mux := http.NewServeMux()
+	var handler http.Handler = mux
+	for i := len(mws) - 1; i >= 0; i-- {
+		handler = mws[i](handler)
+	}
+	s := &Server{
+		cfg: cfg,
+		mux: mux,
+		lis: lis,
+		svr: &http.Server{
+			Handler:        handler,
+			TLSConfig:      tc,
+			ReadTimeout:    cfg.ReadTimeout,
+			WriteTimeout:   cfg.WriteTimeout,
+			IdleTimeout:    cfg.IdleTimeout,
+			MaxHeaderBytes: int(cfg.MaxHeaderBytes),
+		},
+		log: log.With(log.Any("http", "server"), log.Any("address", cfg.Address)),
+	}
+	s.Go(s.serving)
+	s.log.Info("server has initialized")
+	return s, nil
+}
+
+// Handle registers the handler for the given pattern
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// HandleFunc registers the handler function for the given pattern
+func (s *Server) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	s.mux.HandleFunc(pattern, handler)
+}
+
+// Addr returns the listener address
+func (s *Server) Addr() net.Addr {
+	return s.lis.Addr()
+}
+
+// Close shuts down the server gracefully, waits for active requests until timeout
+func (s *Server) Close() error {
+	s.log.Info("server is closing")
+	defer s.log.Info("server has closed")
+
+	s.Kill(nil)
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout)
+	defer cancel()
+	err := s.svr.Shutdown(ctx)
+	if err != nil {
+		s.log.Warn("failed to shutdown server gracefully", log.Error(err))
+		s.svr.Close()
+	}
+	return s.Wait()
+}
+
+func (s *Server) serving() error {
+	s.log.Info("server starts to serve")
+	defer s.log.Info("server has stopped serving")
+
+	err := s.svr.Serve(s.lis)
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+func listen(address string) (net.Listener, error) {
+	if !strings.Contains(address, "://") {
+		return net.Listen("tcp", address)
+	}
+	u, err := utils.ParseURL(address)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "unix":
+		if utils.FileExists(u.Host) {
+			os.Remove(u.Host)
+		}
+		return net.Listen("unix", u.Host)
+	case "tcp", "http", "https":
+		return net.Listen("tcp", u.Host)
+	default:
+		return nil, fmt.Errorf("address (%s) scheme not supported", address)
+	}
+}
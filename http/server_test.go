@@ -0,0 +1,125 @@
+package http
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/creasty/defaults"
+	"github.com/stretchr/testify/assert"
+)
+
+func newServerConfig(address string) (c ServerConfig) {
+	defaults.Set(&c)
+	c.Address = address
+	return
+}
+
+func TestServer(t *testing.T) {
+	var order []string
+	mw := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	svr, err := NewServer(newServerConfig("tcp://127.0.0.1:0"), mw("a"), mw("b"))
+	assert.NoError(t, err)
+	svr.HandleFunc("/ping", func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "handler")
+		w.Write([]byte("pong"))
+	})
+
+	cli, err := NewClient(newClientConfig("http://" + svr.Addr().String()))
+	assert.NoError(t, err)
+	res, err := cli.Get("/ping")
+	assert.NoError(t, err)
+	assert.Equal(t, "pong", string(res))
+	assert.Equal(t, []string{"a", "b", "handler"}, order)
+
+	_, err = cli.Get("/notexist")
+	assert.EqualError(t, err, "[404] 404 page not found")
+	assert.NoError(t, svr.Close())
+
+	_, err = cli.Get("/ping")
+	assert.Error(t, err)
+}
+
+func TestServerGracefulShutdown(t *testing.T) {
+	svr, err := NewServer(newServerConfig("127.0.0.1:0"))
+	assert.NoError(t, err)
+	started := make(chan struct{})
+	svr.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(time.Millisecond * 200)
+		w.Write([]byte("done"))
+	})
+
+	cli, err := NewClient(newClientConfig("http://" + svr.Addr().String()))
+	assert.NoError(t, err)
+	done := make(chan error)
+	go func() {
+		res, err := cli.Get("/slow")
+		assert.Equal(t, "done", string(res))
+		done <- err
+	}()
+	<-started
+	assert.NoError(t, svr.Close())
+	assert.NoError(t, <-done)
+}
+
+func TestServerUnixAndTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", t.Name())
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	svr, err := NewServer(newServerConfig("unix://" + path.Join(dir, "api.sock")))
+	assert.NoError(t, err)
+	assert.Equal(t, "unix", svr.Addr().Network())
+	assert.NoError(t, svr.Close())
+
+	cfg := newServerConfig("https://127.0.0.1:0")
+	cfg.Certificate = utils.Certificate{
+		CA:   "../example/var/lib/baetyl/testcert/ca.pem",
+		Key:  "../example/var/lib/baetyl/testcert/server.key",
+		Cert: "../example/var/lib/baetyl/testcert/server.pem",
+	}
+	svr, err = NewServer(cfg)
+	assert.NoError(t, err)
+	defer svr.Close()
+	svr.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+	})
+	ccfg := newClientConfig("https://" + svr.Addr().String())
+	ccfg.Certificate = utils.Certificate{
+		CA:                 "../example/var/lib/baetyl/testcert/ca.pem",
+		Key:                "../example/var/lib/baetyl/testcert/client.key",
+		Cert:               "../example/var/lib/baetyl/testcert/client.pem",
+		InsecureSkipVerify: true,
+	}
+	cli, err := NewClient(ccfg)
+	assert.NoError(t, err)
+	res, err := cli.Get("/")
+	assert.NoError(t, err)
+	assert.Equal(t, "client.example.org", string(res))
+
+	_, err = NewServer(newServerConfig("udp://127.0.0.1:0"))
+	assert.EqualError(t, err, "address (udp://127.0.0.1:0) scheme not supported")
+}
+
+func TestConfigAddress(t *testing.T) {
+	var sc ServerConfig
+	assert.NoError(t, utils.UnmarshalYAML([]byte("address: unix:///var/run/http.sock"), &sc))
+	assert.NoError(t, utils.UnmarshalYAML([]byte("address: 0.0.0.0:80"), &sc))
+	assert.EqualError(t, utils.UnmarshalYAML([]byte("address: 0.0.0.0"), &sc), "address: regular expression mismatch")
+	var cc ClientConfig
+	assert.NoError(t, utils.UnmarshalYAML([]byte("address: https://127.0.0.1"), &cc))
+	assert.EqualError(t, utils.UnmarshalYAML([]byte("address: 127.0.0.1:80"), &cc), "address: regular expression mismatch")
+}
@@ -0,0 +1,276 @@
+// Package k8s provides the mapping between the models of spec and the objects of kubernetes.
+package k8s
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/baetyl/baetyl-go/context"
+	v1 "github.com/baetyl/baetyl-go/spec/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// all service states
+const (
+	ServiceRunning = "running"
+	ServicePending = "pending"
+	ServiceStopped = "stopped"
+)
+
+// ServiceStatus the status of service converted from deployment
+type ServiceStatus struct {
+	App       string `json:"app,omitempty"`
+	Version   string `json:"version,omitempty"`
+	Name      string `json:"name,omitempty"`
+	State     string `json:"state,omitempty"`
+	Replicas  int32  `json:"replicas"`
+	Ready     int32  `json:"ready"`
+	Available int32  `json:"available"`
+	Message   string `json:"message,omitempty"`
+}
+
+// ToDeployments converts all services of the application to deployments
+func ToDeployments(app *v1.Application) ([]*appsv1.Deployment, error) {
+	var ds []*appsv1.Deployment
+	for i := range app.Services {
+		d, err := ToDeployment(app, &app.Services[i])
+		if err != nil {
+			return nil, err
+		}
+		ds = append(ds, d)
+	}
+	return ds, nil
+}
+
+// ToDeployment converts the service of the application to a deployment named by the service,
+// the config and secret volumes refer to the config maps and secrets of the same names
+func ToDeployment(app *v1.Application, svc *v1.Service) (*appsv1.Deployment, error) {
+	selector := map[string]string{
+		v1.LabelAppName:     app.Name,
+		v1.LabelServiceName: svc.Name,
+	}
+	labels := map[string]string{
+		v1.LabelAppVersion: app.Version,
+	}
+	for k, v := range svc.Labels {
+		labels[k] = v
+	}
+	for k, v := range selector {
+		labels[k] = v
+	}
+	c, err := toContainer(app, svc)
+	if err != nil {
+		return nil, err
+	}
+	vols, err := toVolumes(app, svc)
+	if err != nil {
+		return nil, err
+	}
+	replicas := int32(svc.Replica)
+	if replicas < 1 {
+		replicas = 1
+	}
+	d := &appsv1.Deployment{
+		TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      svc.Name,
+			Namespace: app.Namespace,
+			Labels:    labels,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: selector},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{*c},
+					Volumes:    vols,
+				},
+			},
+		},
+	}
+	if svc.Runtime != "" {
+		d.Spec.Template.Spec.RuntimeClassName = &svc.Runtime
+	}
+	return d, nil
+}
+
+func toContainer(app *v1.Application, svc *v1.Service) (*corev1.Container, error) {
+	c := &corev1.Container{
+		Name:    svc.Name,
+		Image:   svc.Image,
+		Command: svc.Command,
+		Args:    svc.Args,
+		Env: []corev1.EnvVar{
+			{Name: context.EnvKeyAppName, Value: app.Name},
+			{Name: context.EnvKeyServiceName, Value: svc.Name},
+			{Name: context.EnvKeyNodeName, ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{FieldPath: "spec.nodeName"},
+			}},
+		},
+	}
+	for _, e := range svc.Env {
+		c.Env = append(c.Env, corev1.EnvVar{Name: e.Name, Value: e.Value})
+	}
+	for _, p := range svc.Ports {
+		c.Ports = append(c.Ports, corev1.ContainerPort{
+			ContainerPort: p.ContainerPort,
+			HostPort:      p.HostPort,
+			HostIP:        p.HostIP,
+			Protocol:      corev1.Protocol(strings.ToUpper(p.Protocol)),
+		})
+	}
+	for _, m := range svc.VolumeMounts {
+		c.VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{
+			Name:      m.Name,
+			MountPath: m.MountPath,
+			ReadOnly:  m.ReadOnly,
+		})
+	}
+	for i, d := range svc.Devices {
+		c.VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{
+			Name:      deviceVolumeName(i),
+			MountPath: d.DevicePath,
+		})
+	}
+	if len(svc.Devices) > 0 {
+		privileged := true
+		c.SecurityContext = &corev1.SecurityContext{Privileged: &privileged}
+	}
+	if svc.Resources != nil {
+		var err error
+		c.Resources.Limits, err = toResourceList(svc.Resources.Limits)
+		if err != nil {
+			return nil, err
+		}
+		c.Resources.Requests, err = toResourceList(svc.Resources.Requests)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// toVolumes converts the volumes mounted by the service, and the devices as host path volumes
+func toVolumes(app *v1.Application, svc *v1.Service) ([]corev1.Volume, error) {
+	vols := map[string]v1.Volume{}
+	for _, v := range app.Volumes {
+		vols[v.Name] = v
+	}
+	var res []corev1.Volume
+	for _, m := range svc.VolumeMounts {
+		v, ok := vols[m.Name]
+		if !ok {
+			return nil, fmt.Errorf("volume (%s) not found", m.Name)
+		}
+		kv := corev1.Volume{Name: v.Name}
+		switch {
+		case v.HostPath != nil:
+			kv.HostPath = &corev1.HostPathVolumeSource{Path: v.HostPath.Path}
+		case v.Config != nil:
+			kv.ConfigMap = &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: v.Config.Name},
+			}
+		case v.Secret != nil:
+			kv.Secret = &corev1.SecretVolumeSource{SecretName: v.Secret.Name}
+		default:
+			return nil, fmt.Errorf("the source of volume (%s) is not set", v.Name)
+		}
+		res = append(res, kv)
+	}
+	for i, d := range svc.Devices {
+		res = append(res, corev1.Volume{
+			Name: deviceVolumeName(i),
+			VolumeSource: corev1.VolumeSource{
+				HostPath: &corev1.HostPathVolumeSource{Path: d.DevicePath},
+			},
+		})
+	}
+	return res, nil
+}
+
+func toResourceList(qs map[string]string) (corev1.ResourceList, error) {
+	if len(qs) == 0 {
+		return nil, nil
+	}
+	rl := corev1.ResourceList{}
+	for k, v := range qs {
+		q, err := resource.ParseQuantity(v)
+		if err != nil {
+			return nil, fmt.Errorf("quantity (%s) of %s is invalid: %s", v, k, err.Error())
+		}
+		rl[corev1.ResourceName(k)] = q
+	}
+	return rl, nil
+}
+
+func deviceVolumeName(i int) string {
+	return fmt.Sprintf("device-%d", i)
+}
+
+// ToConfigMap converts the configuration to a config map
+func ToConfigMap(cfg *v1.Configuration) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: toObjectMeta(cfg.Name, cfg.Namespace, cfg.Labels),
+		Data:       cfg.DeepCopy().Data,
+	}
+}
+
+// ToSecret converts the secret to an opaque secret
+func ToSecret(s *v1.Secret) *corev1.Secret {
+	return &corev1.Secret{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: toObjectMeta(s.Name, s.Namespace, s.Labels),
+		Data:       s.DeepCopy().Data,
+		Type:       corev1.SecretTypeOpaque,
+	}
+}
+
+func toObjectMeta(name, namespace string, labels map[string]string) metav1.ObjectMeta {
+	m := metav1.ObjectMeta{Name: name, Namespace: namespace}
+	if len(labels) > 0 {
+		m.Labels = map[string]string{}
+		for k, v := range labels {
+			m.Labels[k] = v
+		}
+	}
+	return m
+}
+
+// FromDeployment converts the status of the deployment to the status of service
+func FromDeployment(d *appsv1.Deployment) *ServiceStatus {
+	s := &ServiceStatus{
+		App:       d.Labels[v1.LabelAppName],
+		Version:   d.Labels[v1.LabelAppVersion],
+		Name:      d.Labels[v1.LabelServiceName],
+		Replicas:  d.Status.Replicas,
+		Ready:     d.Status.ReadyReplicas,
+		Available: d.Status.AvailableReplicas,
+	}
+	if s.Name == "" {
+		s.Name = d.Name
+	}
+	desired := int32(1)
+	if d.Spec.Replicas != nil {
+		desired = *d.Spec.Replicas
+	}
+	switch {
+	case desired == 0:
+		s.State = ServiceStopped
+	case s.Available >= desired && d.Status.UpdatedReplicas >= desired:
+		s.State = ServiceRunning
+	default:
+		s.State = ServicePending
+	}
+	for _, c := range d.Status.Conditions {
+		if c.Status != corev1.ConditionTrue && c.Message != "" {
+			s.Message = c.Message
+			break
+		}
+	}
+	return s
+}
@@ -0,0 +1,142 @@
+package k8s
+
+import (
+	"testing"
+
+	v1 "github.com/baetyl/baetyl-go/spec/v1"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func newTestApp() *v1.Application {
+	return &v1.Application{
+		Name:      "app",
+		Namespace: "baetyl-edge",
+		Version:   "3",
+		Volumes: []v1.Volume{
+			{Name: "data", VolumeSource: v1.VolumeSource{HostPath: &v1.HostPathVolumeSource{Path: "/var/data"}}},
+			{Name: "conf", VolumeSource: v1.VolumeSource{Config: &v1.ObjectReference{Name: "nginx-conf"}}},
+			{Name: "cert", VolumeSource: v1.VolumeSource{Secret: &v1.ObjectReference{Name: "nginx-cert"}}},
+		},
+		Services: []v1.Service{
+			{
+				Name:    "nginx",
+				Labels:  map[string]string{"a": "b"},
+				Image:   "nginx:1.17",
+				Replica: 2,
+				Args:    []string{"-g", "daemon off;"},
+				Env:     []v1.Environment{{Name: "K", Value: "V"}},
+				Ports:   []v1.ContainerPort{{ContainerPort: 80, HostPort: 8080, Protocol: "tcp"}},
+				VolumeMounts: []v1.VolumeMount{
+					{Name: "data", MountPath: "/data"},
+					{Name: "conf", MountPath: "/etc/nginx", ReadOnly: true},
+					{Name: "cert", MountPath: "/etc/cert", ReadOnly: true},
+				},
+				Devices:   []v1.Device{{DevicePath: "/dev/ttyS0"}},
+				Resources: &v1.Resources{Limits: map[string]string{"cpu": "500m", "memory": "128Mi"}},
+				Runtime:   "nvidia",
+			},
+			{
+				Name:  "busybox",
+				Image: "busybox",
+			},
+		},
+	}
+}
+
+func TestToDeployment(t *testing.T) {
+	app := newTestApp()
+	ds, err := ToDeployments(app)
+	assert.NoError(t, err)
+	assert.Len(t, ds, 2)
+
+	d := ds[0]
+	assert.Equal(t, "nginx", d.Name)
+	assert.Equal(t, "baetyl-edge", d.Namespace)
+	assert.Equal(t, map[string]string{
+		v1.LabelAppName:     "app",
+		v1.LabelAppVersion:  "3",
+		v1.LabelServiceName: "nginx",
+		"a":                 "b",
+	}, d.Labels)
+	assert.Equal(t, map[string]string{v1.LabelAppName: "app", v1.LabelServiceName: "nginx"}, d.Spec.Selector.MatchLabels)
+	assert.Equal(t, int32(2), *d.Spec.Replicas)
+	assert.Equal(t, "nvidia", *d.Spec.Template.Spec.RuntimeClassName)
+
+	c := d.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, "nginx:1.17", c.Image)
+	assert.Equal(t, []string{"-g", "daemon off;"}, c.Args)
+	assert.Len(t, c.Env, 4)
+	assert.Equal(t, corev1.EnvVar{Name: "BAETYL_APP_NAME", Value: "app"}, c.Env[0])
+	assert.Equal(t, "spec.nodeName", c.Env[2].ValueFrom.FieldRef.FieldPath)
+	assert.Equal(t, corev1.EnvVar{Name: "K", Value: "V"}, c.Env[3])
+	assert.Equal(t, []corev1.ContainerPort{{ContainerPort: 80, HostPort: 8080, Protocol: corev1.ProtocolTCP}}, c.Ports)
+	assert.Equal(t, resource.MustParse("500m"), c.Resources.Limits[corev1.ResourceCPU])
+	assert.Equal(t, resource.MustParse("128Mi"), c.Resources.Limits[corev1.ResourceMemory])
+	assert.Nil(t, c.Resources.Requests)
+	assert.True(t, *c.SecurityContext.Privileged)
+	assert.Equal(t, corev1.VolumeMount{Name: "device-0", MountPath: "/dev/ttyS0"}, c.VolumeMounts[3])
+
+	vols := d.Spec.Template.Spec.Volumes
+	assert.Len(t, vols, 4)
+	assert.Equal(t, "/var/data", vols[0].HostPath.Path)
+	assert.Equal(t, "nginx-conf", vols[1].ConfigMap.Name)
+	assert.Equal(t, "nginx-cert", vols[2].Secret.SecretName)
+	assert.Equal(t, "/dev/ttyS0", vols[3].HostPath.Path)
+
+	d = ds[1]
+	assert.Equal(t, int32(1), *d.Spec.Replicas)
+	assert.Nil(t, d.Spec.Template.Spec.RuntimeClassName)
+	assert.Nil(t, d.Spec.Template.Spec.Containers[0].SecurityContext)
+
+	app.Services[0].Resources.Limits["cpu"] = "x"
+	_, err = ToDeployments(app)
+	assert.Contains(t, err.Error(), "quantity (x) of cpu is invalid")
+	app.Services[0].Resources = nil
+	app.Services[0].VolumeMounts = []v1.VolumeMount{{Name: "missing"}}
+	_, err = ToDeployment(app, &app.Services[0])
+	assert.EqualError(t, err, "volume (missing) not found")
+}
+
+func TestToConfigMapAndSecret(t *testing.T) {
+	cfg := &v1.Configuration{Name: "nginx-conf", Namespace: "baetyl-edge", Labels: map[string]string{"a": "b"}, Data: map[string]string{"nginx.conf": "x"}}
+	cm := ToConfigMap(cfg)
+	assert.Equal(t, "ConfigMap", cm.Kind)
+	assert.Equal(t, "nginx-conf", cm.Name)
+	assert.Equal(t, "baetyl-edge", cm.Namespace)
+	assert.Equal(t, map[string]string{"a": "b"}, cm.Labels)
+	assert.Equal(t, cfg.Data, cm.Data)
+	cm.Data["nginx.conf"] = "y"
+	assert.Equal(t, "x", cfg.Data["nginx.conf"])
+
+	s := ToSecret(&v1.Secret{Name: "nginx-cert", Data: map[string][]byte{"cert.pem": []byte("x")}})
+	assert.Equal(t, "Secret", s.Kind)
+	assert.Equal(t, corev1.SecretTypeOpaque, s.Type)
+	assert.Nil(t, s.Labels)
+	assert.Equal(t, []byte("x"), s.Data["cert.pem"])
+}
+
+func TestFromDeployment(t *testing.T) {
+	app := newTestApp()
+	d, err := ToDeployment(app, &app.Services[0])
+	assert.NoError(t, err)
+
+	d.Status = appsv1.DeploymentStatus{Replicas: 2, ReadyReplicas: 1, AvailableReplicas: 1, UpdatedReplicas: 2,
+		Conditions: []appsv1.DeploymentCondition{
+			{Type: appsv1.DeploymentAvailable, Status: corev1.ConditionFalse, Message: "minimum replicas unavailable"},
+		},
+	}
+	s := FromDeployment(d)
+	assert.Equal(t, &ServiceStatus{App: "app", Version: "3", Name: "nginx", State: ServicePending,
+		Replicas: 2, Ready: 1, Available: 1, Message: "minimum replicas unavailable"}, s)
+
+	d.Status = appsv1.DeploymentStatus{Replicas: 2, ReadyReplicas: 2, AvailableReplicas: 2, UpdatedReplicas: 2}
+	assert.Equal(t, ServiceRunning, FromDeployment(d).State)
+
+	zero := int32(0)
+	d.Spec.Replicas = &zero
+	d.Status = appsv1.DeploymentStatus{}
+	assert.Equal(t, ServiceStopped, FromDeployment(d).State)
+}
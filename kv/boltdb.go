@@ -0,0 +1,322 @@
+package kv
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/baetyl/baetyl-go/log"
+	"github.com/baetyl/baetyl-go/utils"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	bucketKV  = []byte("kv")
+	bucketTTL = []byte("ttl")
+)
+
+// BoltDB the kv store backed by an embedded boltdb file
+type BoltDB struct {
+	cfg      Config
+	db       *bolt.DB
+	expired  []ExpiredHandler
+	watchers map[*watcher]struct{}
+	closed   bool
+	wmu      sync.Mutex // serializes the writes and their events, so that the events are in the order of commits
+	mu       sync.RWMutex
+	wg       sync.WaitGroup
+	log      *log.Logger
+	tomb     utils.Tomb
+}
+
+// NewBoltDB opens (or creates) the boltdb file
+func NewBoltDB(cfg Config) (*BoltDB, error) {
+	err := os.MkdirAll(filepath.Dir(cfg.Path), 0755)
+	if err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(cfg.Path, 0600, &bolt.Options{Timeout: cfg.Timeout})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketKV)
+		if err != nil {
+			return err
+		}
+		_, err = tx.CreateBucketIfNotExists(bucketTTL)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	d := &BoltDB{
+		cfg:      cfg,
+		db:       db,
+		watchers: map[*watcher]struct{}{},
+		log:      log.With(log.Any("kv", "boltdb")),
+	}
+	if cfg.ExpireInterval > 0 {
+		d.tomb.Go(d.expiring)
+	}
+	return d, nil
+}
+
+// OnExpired registers the handler which is called after a key is expired and removed
+func (d *BoltDB) OnExpired(h ExpiredHandler) {
+	d.mu.Lock()
+	d.expired = append(d.expired, h)
+	d.mu.Unlock()
+}
+
+// Get returns the value of the key
+func (d *BoltDB) Get(key string) ([]byte, error) {
+	if key == "" {
+		return nil, ErrKeyEmpty
+	}
+	var value []byte
+	now := time.Now()
+	err := d.db.View(func(tx *bolt.Tx) error {
+		k := []byte(key)
+		v := tx.Bucket(bucketKV).Get(k)
+		if v == nil || isExpired(tx.Bucket(bucketTTL).Get(k), now) {
+			return ErrNotFound
+		}
+		// the value is only valid within the transaction
+		value = append([]byte{}, v...)
+		return nil
+	})
+	return value, err
+}
+
+// Set sets the value of the key, the ttl of the key is cleared if set before
+func (d *BoltDB) Set(key string, value []byte) error {
+	return d.SetWithTTL(key, value, 0)
+}
+
+// SetWithTTL sets the value of the key which expires after the ttl, never expires if ttl is 0
+func (d *BoltDB) SetWithTTL(key string, value []byte, ttl time.Duration) error {
+	if key == "" {
+		return ErrKeyEmpty
+	}
+	if value == nil {
+		value = []byte{}
+	}
+	e := &Event{Type: EventUpdate, KV: KV{Key: key, Value: value}}
+	d.wmu.Lock()
+	defer d.wmu.Unlock()
+	err := d.db.Update(func(tx *bolt.Tx) error {
+		k := []byte(key)
+		kb, tb := tx.Bucket(bucketKV), tx.Bucket(bucketTTL)
+		if kb.Get(k) == nil || isExpired(tb.Get(k), time.Now()) {
+			e.Type = EventCreate
+		}
+		err := kb.Put(k, value)
+		if err != nil {
+			return err
+		}
+		if ttl <= 0 {
+			return tb.Delete(k)
+		}
+		return tb.Put(k, encodeTime(time.Now().Add(ttl)))
+	})
+	if err != nil {
+		return err
+	}
+	d.notify(e)
+	return nil
+}
+
+// Del deletes the key
+func (d *BoltDB) Del(key string) error {
+	if key == "" {
+		return ErrKeyEmpty
+	}
+	var e *Event
+	d.wmu.Lock()
+	defer d.wmu.Unlock()
+	err := d.db.Update(func(tx *bolt.Tx) error {
+		k := []byte(key)
+		kb, tb := tx.Bucket(bucketKV), tx.Bucket(bucketTTL)
+		if v := kb.Get(k); v != nil && !isExpired(tb.Get(k), time.Now()) {
+			e = &Event{Type: EventDelete, KV: KV{Key: key, Value: append([]byte{}, v...)}}
+		}
+		err := kb.Delete(k)
+		if err != nil {
+			return err
+		}
+		return tb.Delete(k)
+	})
+	if err != nil {
+		return err
+	}
+	if e != nil {
+		d.notify(e)
+	}
+	return nil
+}
+
+// List returns all pairs whose key has the prefix
+func (d *BoltDB) List(prefix string) ([]*KV, error) {
+	var kvs []*KV
+	now := time.Now()
+	err := d.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketKV).Cursor()
+		ttl := tx.Bucket(bucketTTL)
+		p := []byte(prefix)
+		for k, v := c.Seek(p); k != nil && bytes.HasPrefix(k, p); k, v = c.Next() {
+			if isExpired(ttl.Get(k), now) {
+				continue
+			}
+			kvs = append(kvs, &KV{Key: string(k), Value: append([]byte{}, v...)})
+		}
+		return nil
+	})
+	return kvs, err
+}
+
+// Watch returns the events of the keys which have the prefix, the channel is closed after the context is done or the store is closed.
+// The store is blocked until the event is received, so the watcher should keep receiving events or cancel the context.
+func (d *BoltDB) Watch(ctx context.Context, prefix string) (<-chan *Event, error) {
+	w := &watcher{
+		prefix: prefix,
+		ctx:    ctx,
+		ch:     make(chan *Event, d.cfg.WatchBufferSize),
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return nil, ErrClosed
+	}
+	d.watchers[w] = struct{}{}
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		select {
+		case <-ctx.Done():
+		case <-d.tomb.Dying():
+		}
+		d.mu.Lock()
+		delete(d.watchers, w)
+		close(w.ch)
+		d.mu.Unlock()
+	}()
+	return w.ch, nil
+}
+
+// notify sends the event to the watchers of its key, the lock of writes is held
+func (d *BoltDB) notify(e *Event) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	for w := range d.watchers {
+		if !strings.HasPrefix(e.KV.Key, w.prefix) {
+			continue
+		}
+		select {
+		case w.ch <- e:
+		case <-w.ctx.Done():
+		case <-d.tomb.Dying():
+		}
+	}
+}
+
+// Close closes the boltdb file
+func (d *BoltDB) Close() error {
+	// kill first to unblock the notifying
+	d.tomb.Kill(nil)
+	d.mu.Lock()
+	d.closed = true
+	d.mu.Unlock()
+	d.tomb.Wait()
+	d.wg.Wait()
+	return d.db.Close()
+}
+
+func (d *BoltDB) expiring() error {
+	t := time.NewTicker(d.cfg.ExpireInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			d.wmu.Lock()
+			kvs, err := d.expire(time.Now())
+			if err != nil {
+				d.wmu.Unlock()
+				d.log.Error("failed to remove expired keys", log.Error(err))
+				continue
+			}
+			for _, kv := range kvs {
+				d.notify(&Event{Type: EventDelete, KV: *kv})
+			}
+			d.wmu.Unlock()
+			// the handlers are called out of the lock of writes, so that the keys can be set again by the handlers
+			for _, kv := range kvs {
+				d.mu.RLock()
+				for _, h := range d.expired {
+					h(kv)
+				}
+				d.mu.RUnlock()
+			}
+		case <-d.tomb.Dying():
+			return nil
+		}
+	}
+}
+
+// expire removes all keys expired and returns them
+func (d *BoltDB) expire(now time.Time) ([]*KV, error) {
+	var kvs []*KV
+	err := d.db.Update(func(tx *bolt.Tx) error {
+		kb, tb := tx.Bucket(bucketKV), tx.Bucket(bucketTTL)
+		c := tb.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if !isExpired(v, now) {
+				continue
+			}
+			kv := &KV{Key: string(k)}
+			if v := kb.Get(k); v != nil {
+				kv.Value = append([]byte{}, v...)
+			}
+			kvs = append(kvs, kv)
+		}
+		for _, kv := range kvs {
+			k := []byte(kv.Key)
+			if err := kb.Delete(k); err != nil {
+				return err
+			}
+			if err := tb.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return kvs, nil
+}
+
+type watcher struct {
+	prefix string
+	ctx    context.Context
+	ch     chan *Event
+}
+
+func encodeTime(t time.Time) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(t.UnixNano()))
+	return b
+}
+
+func isExpired(v []byte, now time.Time) bool {
+	if len(v) != 8 {
+		return false
+	}
+	return int64(binary.BigEndian.Uint64(v)) <= now.UnixNano()
+}
@@ -0,0 +1,251 @@
+package kv
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/baetyl/baetyl-go/codec"
+	"github.com/creasty/defaults"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestConfig(t *testing.T) (Config, func()) {
+	dir, err := ioutil.TempDir("", t.Name())
+	assert.NoError(t, err)
+	var cfg Config
+	assert.NoError(t, defaults.Set(&cfg))
+	cfg.Path = filepath.Join(dir, "kv", "kv.db")
+	return cfg, func() { os.RemoveAll(dir) }
+}
+
+func TestBoltDB(t *testing.T) {
+	cfg, clean := newTestConfig(t)
+	defer clean()
+
+	d, err := New(cfg)
+	assert.NoError(t, err)
+
+	_, err = d.Get("a")
+	assert.Equal(t, ErrNotFound, err)
+	assert.Equal(t, ErrKeyEmpty, d.Set("", nil))
+
+	assert.NoError(t, d.Set("app/a", []byte("1")))
+	assert.NoError(t, d.Set("app/b", []byte("2")))
+	assert.NoError(t, d.Set("apq", nil))
+	assert.NoError(t, d.Set("cfg/a", []byte("3")))
+
+	v, err := d.Get("app/a")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("1"), v)
+	v, err = d.Get("apq")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{}, v)
+
+	kvs, err := d.List("app/")
+	assert.NoError(t, err)
+	assert.Equal(t, []*KV{{Key: "app/a", Value: []byte("1")}, {Key: "app/b", Value: []byte("2")}}, kvs)
+	kvs, err = d.List("")
+	assert.NoError(t, err)
+	assert.Len(t, kvs, 4)
+	kvs, err = d.List("x")
+	assert.NoError(t, err)
+	assert.Len(t, kvs, 0)
+
+	assert.NoError(t, d.Del("app/a"))
+	assert.NoError(t, d.Del("app/a"))
+	_, err = d.Get("app/a")
+	assert.Equal(t, ErrNotFound, err)
+	assert.NoError(t, d.Close())
+
+	// reopen, the data is durable
+	d, err = New(cfg)
+	assert.NoError(t, err)
+	defer d.Close()
+	v, err = d.Get("cfg/a")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("3"), v)
+
+	cfg.Driver = "redis"
+	_, err = New(cfg)
+	assert.EqualError(t, err, "driver (redis) not supported")
+}
+
+func TestBoltDBTTL(t *testing.T) {
+	cfg, clean := newTestConfig(t)
+	defer clean()
+	cfg.ExpireInterval = 10 * time.Millisecond
+
+	d, err := NewBoltDB(cfg)
+	assert.NoError(t, err)
+	defer d.Close()
+
+	expired := make(chan *KV, 10)
+	d.OnExpired(func(kv *KV) { expired <- kv })
+
+	assert.NoError(t, d.SetWithTTL("session/a", []byte("1"), 50*time.Millisecond))
+	assert.NoError(t, d.SetWithTTL("session/b", []byte("2"), time.Hour))
+	assert.NoError(t, d.SetWithTTL("session/c", []byte("3"), 50*time.Millisecond))
+	// set without ttl clears the ttl
+	assert.NoError(t, d.Set("session/c", []byte("4")))
+
+	v, err := d.Get("session/a")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("1"), v)
+
+	select {
+	case kv := <-expired:
+		assert.Equal(t, &KV{Key: "session/a", Value: []byte("1")}, kv)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+	_, err = d.Get("session/a")
+	assert.Equal(t, ErrNotFound, err)
+	kvs, err := d.List("session/")
+	assert.NoError(t, err)
+	assert.Equal(t, []*KV{{Key: "session/b", Value: []byte("2")}, {Key: "session/c", Value: []byte("4")}}, kvs)
+	select {
+	case kv := <-expired:
+		t.Fatalf("unexpected expired key: %s", kv.Key)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestBoltDBExpireLazily(t *testing.T) {
+	cfg, clean := newTestConfig(t)
+	defer clean()
+	cfg.ExpireInterval = 0
+
+	d, err := NewBoltDB(cfg)
+	assert.NoError(t, err)
+	defer d.Close()
+
+	assert.NoError(t, d.SetWithTTL("a", []byte("1"), time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+	_, err = d.Get("a")
+	assert.Equal(t, ErrNotFound, err)
+	kvs, err := d.List("")
+	assert.NoError(t, err)
+	assert.Len(t, kvs, 0)
+
+	kvs, err = d.expire(time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, []*KV{{Key: "a", Value: []byte("1")}}, kvs)
+}
+
+func TestBoltDBWatch(t *testing.T) {
+	cfg, clean := newTestConfig(t)
+	defer clean()
+	cfg.ExpireInterval = 10 * time.Millisecond
+
+	d, err := NewBoltDB(cfg)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := d.Watch(ctx, "app/")
+	assert.NoError(t, err)
+	all, err := d.Watch(context.Background(), "")
+	assert.NoError(t, err)
+
+	assert.NoError(t, d.Set("app/a", []byte("1")))
+	assert.NoError(t, d.Set("cfg/a", []byte("1")))
+	assert.NoError(t, d.Set("app/a", []byte("2")))
+	assert.NoError(t, d.Del("app/a"))
+	assert.NoError(t, d.Del("app/b"))
+	assert.NoError(t, d.SetWithTTL("app/c", []byte("3"), time.Millisecond))
+
+	assertEvents(t, ch,
+		&Event{Type: EventCreate, KV: KV{Key: "app/a", Value: []byte("1")}},
+		&Event{Type: EventUpdate, KV: KV{Key: "app/a", Value: []byte("2")}},
+		&Event{Type: EventDelete, KV: KV{Key: "app/a", Value: []byte("2")}},
+		&Event{Type: EventCreate, KV: KV{Key: "app/c", Value: []byte("3")}},
+		&Event{Type: EventDelete, KV: KV{Key: "app/c", Value: []byte("3")}},
+	)
+	assertEvents(t, all,
+		&Event{Type: EventCreate, KV: KV{Key: "app/a", Value: []byte("1")}},
+		&Event{Type: EventCreate, KV: KV{Key: "cfg/a", Value: []byte("1")}},
+	)
+
+	cancel()
+	for range ch {
+	}
+	// the store is not blocked by the canceled watcher
+	assert.NoError(t, d.Set("app/d", []byte("4")))
+
+	assert.NoError(t, d.Close())
+	_, err = d.Watch(context.Background(), "")
+	assert.Equal(t, ErrClosed, err)
+	for range all {
+	}
+}
+
+func TestBoltDBWatchOrder(t *testing.T) {
+	cfg, clean := newTestConfig(t)
+	defer clean()
+
+	d, err := NewBoltDB(cfg)
+	assert.NoError(t, err)
+	defer d.Close()
+	ch, err := d.Watch(context.Background(), "")
+	assert.NoError(t, err)
+	last := make(chan *Event)
+	go func() {
+		var e *Event
+		for i := 0; i < 100; i++ {
+			e = <-ch
+		}
+		last <- e
+	}()
+
+	// the events of concurrent writes are in the order of commits, so the last one is the final state
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, d.Set("a", []byte(strconv.Itoa(i))))
+		}(i)
+	}
+	wg.Wait()
+	v, err := d.Get("a")
+	assert.NoError(t, err)
+	select {
+	case e := <-last:
+		assert.Equal(t, string(v), string(e.KV.Value))
+	case <-time.After(5 * time.Second):
+		t.Fatal("events not received")
+	}
+}
+
+func assertEvents(t *testing.T, ch <-chan *Event, es ...*Event) {
+	for _, e := range es {
+		select {
+		case a := <-ch:
+			assert.Equal(t, e, a)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout, expect event (%s)", e.String())
+		}
+	}
+}
+
+func TestValueCodec(t *testing.T) {
+	cfg, clean := newTestConfig(t)
+	defer clean()
+
+	d, err := New(cfg)
+	assert.NoError(t, err)
+	defer d.Close()
+
+	in := map[string]int{"a": 1}
+	assert.NoError(t, SetValue(d, "v", codec.CBOR, in))
+	var out map[string]int
+	assert.NoError(t, GetValue(d, "v", codec.CBOR, &out))
+	assert.Equal(t, in, out)
+	assert.Equal(t, ErrNotFound, GetValue(d, "x", codec.CBOR, &out))
+	assert.Equal(t, codec.ErrNotProtoMessage, SetValue(d, "v", codec.Protobuf, in))
+}
@@ -0,0 +1,122 @@
+package kv
+
+import (
+	"context"
+	"time"
+
+	"github.com/baetyl/baetyl-go/utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// Client the client of kv server, which implements the driver interface
+type Client struct {
+	cfg  ClientConfig
+	cli  KVServiceClient
+	conn *grpc.ClientConn
+}
+
+// NewClient creates a new client of kv server
+func NewClient(cc ClientConfig) (*Client, error) {
+	opts := []grpc.DialOption{
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(int(cc.MaxMessageSize))),
+	}
+	if cc.Certificate.Key != "" || cc.Certificate.Cert != "" {
+		tlsCfg, err := utils.NewTLSConfigClient(cc.Certificate)
+		if err != nil {
+			return nil, err
+		}
+		if !cc.Certificate.InsecureSkipVerify {
+			tlsCfg.ServerName = cc.Certificate.Name
+		}
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(tlsCfg)))
+	} else {
+		opts = append(opts, grpc.WithInsecure())
+	}
+	conn, err := grpc.Dial(cc.Address, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		cfg:  cc,
+		cli:  NewKVServiceClient(conn),
+		conn: conn,
+	}, nil
+}
+
+// Get returns the value of the key
+func (c *Client) Get(key string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.Timeout)
+	defer cancel()
+	res, err := c.cli.Get(ctx, &KV{Key: key}, grpc.WaitForReady(true))
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	return res.Value, nil
+}
+
+// Set sets the value of the key
+func (c *Client) Set(key string, value []byte) error {
+	return c.SetWithTTL(key, value, 0)
+}
+
+// SetWithTTL sets the value of the key which expires after the ttl
+func (c *Client) SetWithTTL(key string, value []byte, ttl time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.Timeout)
+	defer cancel()
+	_, err := c.cli.Set(ctx, &KV{Key: key, Value: value, TTL: int64(ttl)}, grpc.WaitForReady(true))
+	return fromStatus(err)
+}
+
+// Del deletes the key
+func (c *Client) Del(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.Timeout)
+	defer cancel()
+	_, err := c.cli.Del(ctx, &KV{Key: key}, grpc.WaitForReady(true))
+	return fromStatus(err)
+}
+
+// List returns all pairs whose key has the prefix
+func (c *Client) List(prefix string) ([]*KV, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.Timeout)
+	defer cancel()
+	res, err := c.cli.List(ctx, &KV{Key: prefix}, grpc.WaitForReady(true))
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	return res.KVs, nil
+}
+
+// Watch returns the events of the keys which have the prefix, the channel is closed after the context is done or the stream is broken
+func (c *Client) Watch(ctx context.Context, prefix string) (<-chan *Event, error) {
+	stream, err := c.cli.Watch(ctx, &KV{Key: prefix}, grpc.WaitForReady(true))
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	// waits until the watcher is ready
+	_, err = stream.Header()
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	ch := make(chan *Event)
+	go func() {
+		defer close(ch)
+		for {
+			e, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			select {
+			case ch <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// Close closes the connection
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,31 @@
+package kv
+
+import (
+	"time"
+
+	"github.com/baetyl/baetyl-go/utils"
+)
+
+// Config the config of kv store
+type Config struct {
+	Driver          string        `yaml:"driver" json:"driver" default:"boltdb" validate:"regexp=^(boltdb)$"`
+	Path            string        `yaml:"path" json:"path" default:"var/lib/baetyl/kv/kv.db"`
+	Timeout         time.Duration `yaml:"timeout" json:"timeout" default:"5s"`
+	ExpireInterval  time.Duration `yaml:"expireInterval" json:"expireInterval" default:"1s"` // the interval to remove expired keys in background
+	WatchBufferSize int           `yaml:"watchBufferSize" json:"watchBufferSize" default:"64"`
+}
+
+// ServerConfig the config of kv server
+type ServerConfig struct {
+	Address        string            `yaml:"address" json:"address"`
+	Certificate    utils.Certificate `yaml:",inline" json:",inline"`
+	MaxMessageSize utils.Size        `yaml:"maxMessageSize" json:"maxMessageSize" default:"4194304"`
+}
+
+// ClientConfig the config of kv client
+type ClientConfig struct {
+	Address        string            `yaml:"address" json:"address"`
+	Certificate    utils.Certificate `yaml:",inline" json:",inline"`
+	Timeout        time.Duration     `yaml:"timeout" json:"timeout" default:"30s"`
+	MaxMessageSize utils.Size        `yaml:"maxMessageSize" json:"maxMessageSize" default:"4194304"`
+}
@@ -0,0 +1,66 @@
+package kv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/baetyl/baetyl-go/codec"
+)
+
+// all errors of kv
+var (
+	ErrNotFound = errors.New("key not found")
+	ErrKeyEmpty = errors.New("key is empty")
+	ErrClosed   = errors.New("store is closed")
+)
+
+// Driver the interface of kv store
+type Driver interface {
+	// Get returns the value of the key, returns ErrNotFound if the key does not exist
+	Get(key string) ([]byte, error)
+	// Set sets the value of the key
+	Set(key string, value []byte) error
+	// SetWithTTL sets the value of the key which expires after the ttl
+	SetWithTTL(key string, value []byte, ttl time.Duration) error
+	// Del deletes the key, no error if the key does not exist
+	Del(key string) error
+	// List returns all pairs whose key has the prefix, sorted by key
+	List(prefix string) ([]*KV, error)
+	// Watch returns the events of the keys which have the prefix, the channel is closed after the context is done
+	Watch(ctx context.Context, prefix string) (<-chan *Event, error)
+	// Close closes the store
+	Close() error
+}
+
+// ExpiredHandler the handler called after a key is expired and removed
+type ExpiredHandler func(*KV)
+
+// New creates a kv store by the driver configured
+func New(cfg Config) (Driver, error) {
+	switch cfg.Driver {
+	case "", "boltdb":
+		return NewBoltDB(cfg)
+	default:
+		return nil, fmt.Errorf("driver (%s) not supported", cfg.Driver)
+	}
+}
+
+// GetValue gets the value of the key and decodes it into out by the codec
+func GetValue(d Driver, key string, c codec.Codec, out interface{}) error {
+	data, err := d.Get(key)
+	if err != nil {
+		return err
+	}
+	return c.Unmarshal(data, out)
+}
+
+// SetValue encodes the value by the codec and sets it as the value of the key
+func SetValue(d Driver, key string, c codec.Codec, v interface{}) error {
+	data, err := c.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return d.Set(key, data)
+}
@@ -0,0 +1,125 @@
+package kv
+
+import (
+	"context"
+	"time"
+
+	"github.com/baetyl/baetyl-go/utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Service the kv grpc service which serves the store
+type Service struct {
+	d Driver
+}
+
+// NewService creates a new kv grpc service
+func NewService(d Driver) *Service {
+	return &Service{d: d}
+}
+
+// Get returns the value of the key
+func (s *Service) Get(_ context.Context, req *KV) (*KV, error) {
+	v, err := s.d.Get(req.Key)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &KV{Key: req.Key, Value: v}, nil
+}
+
+// Set sets the value of the key
+func (s *Service) Set(_ context.Context, req *KV) (*Empty, error) {
+	err := s.d.SetWithTTL(req.Key, req.Value, time.Duration(req.TTL))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &Empty{}, nil
+}
+
+// Del deletes the key
+func (s *Service) Del(_ context.Context, req *KV) (*Empty, error) {
+	err := s.d.Del(req.Key)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &Empty{}, nil
+}
+
+// List returns all pairs whose key has the prefix
+func (s *Service) List(_ context.Context, req *KV) (*KVs, error) {
+	kvs, err := s.d.List(req.Key)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &KVs{KVs: kvs}, nil
+}
+
+// Watch sends the events of the keys which have the prefix until the stream is closed
+func (s *Service) Watch(req *KV, stream KVService_WatchServer) error {
+	ch, err := s.d.Watch(stream.Context(), req.Key)
+	if err != nil {
+		return toStatus(err)
+	}
+	// sends header to tell the client that the watcher is ready
+	err = stream.SendHeader(metadata.MD{})
+	if err != nil {
+		return err
+	}
+	for e := range ch {
+		err = stream.Send(e)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewServer creates a new grpc server with the kv service registered
+func NewServer(cfg ServerConfig, d Driver) (*grpc.Server, error) {
+	opts := []grpc.ServerOption{
+		grpc.MaxRecvMsgSize(int(cfg.MaxMessageSize)),
+		grpc.MaxSendMsgSize(int(cfg.MaxMessageSize)),
+	}
+	if cfg.Certificate.Key != "" || cfg.Certificate.Cert != "" {
+		tlsCfg, err := utils.NewTLSConfigServer(cfg.Certificate)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsCfg)))
+	}
+	svr := grpc.NewServer(opts...)
+	RegisterKVServiceServer(svr, NewService(d))
+	return svr, nil
+}
+
+func toStatus(err error) error {
+	switch err {
+	case ErrNotFound:
+		return status.Error(codes.NotFound, err.Error())
+	case ErrKeyEmpty:
+		return status.Error(codes.InvalidArgument, err.Error())
+	case ErrClosed:
+		return status.Error(codes.Unavailable, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+func fromStatus(err error) error {
+	s, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	switch {
+	case s.Code() == codes.NotFound && s.Message() == ErrNotFound.Error():
+		return ErrNotFound
+	case s.Code() == codes.InvalidArgument && s.Message() == ErrKeyEmpty.Error():
+		return ErrKeyEmpty
+	default:
+		return err
+	}
+}
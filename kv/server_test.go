@@ -0,0 +1,84 @@
+package kv
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/creasty/defaults"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerClient(t *testing.T) {
+	cfg, clean := newTestConfig(t)
+	defer clean()
+	d, err := New(cfg)
+	assert.NoError(t, err)
+	defer d.Close()
+
+	var sc ServerConfig
+	assert.NoError(t, defaults.Set(&sc))
+	sc.Certificate = utils.Certificate{
+		CA:   "../example/var/lib/baetyl/testcert/ca.pem",
+		Key:  "../example/var/lib/baetyl/testcert/server.key",
+		Cert: "../example/var/lib/baetyl/testcert/server.pem",
+	}
+	svr, err := NewServer(sc, d)
+	assert.NoError(t, err)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go svr.Serve(lis)
+	defer svr.Stop()
+
+	var cc ClientConfig
+	assert.NoError(t, defaults.Set(&cc))
+	cc.Address = lis.Addr().String()
+	cc.Certificate = utils.Certificate{
+		CA:                 "../example/var/lib/baetyl/testcert/ca.pem",
+		Key:                "../example/var/lib/baetyl/testcert/client.key",
+		Cert:               "../example/var/lib/baetyl/testcert/client.pem",
+		InsecureSkipVerify: true,
+	}
+	cli, err := NewClient(cc)
+	assert.NoError(t, err)
+	defer cli.Close()
+
+	var _ Driver = cli
+	_, err = cli.Get("a")
+	assert.Equal(t, ErrNotFound, err)
+	assert.Equal(t, ErrKeyEmpty, cli.Set("", []byte("1")))
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := cli.Watch(ctx, "a/")
+	assert.NoError(t, err)
+
+	assert.NoError(t, cli.Set("a/1", []byte("1")))
+	assert.NoError(t, cli.Set("a/2", []byte("2")))
+	assert.NoError(t, cli.Set("b/1", []byte("3")))
+
+	v, err := cli.Get("a/1")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("1"), v)
+	kvs, err := cli.List("a/")
+	assert.NoError(t, err)
+	assert.Equal(t, []*KV{{Key: "a/1", Value: []byte("1")}, {Key: "a/2", Value: []byte("2")}}, kvs)
+
+	assert.NoError(t, cli.SetWithTTL("c/1", []byte("4"), time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+	_, err = cli.Get("c/1")
+	assert.Equal(t, ErrNotFound, err)
+
+	assert.NoError(t, cli.Del("a/1"))
+	_, err = d.Get("a/1")
+	assert.Equal(t, ErrNotFound, err)
+
+	assertEvents(t, ch,
+		&Event{Type: EventCreate, KV: KV{Key: "a/1", Value: []byte("1")}},
+		&Event{Type: EventCreate, KV: KV{Key: "a/2", Value: []byte("2")}},
+		&Event{Type: EventDelete, KV: KV{Key: "a/1", Value: []byte("1")}},
+	)
+	cancel()
+	for range ch {
+	}
+}
@@ -0,0 +1,11 @@
+package license
+
+import "time"
+
+// Config the config of license
+type Config struct {
+	File        string        `yaml:"file" json:"file" default:"etc/baetyl/license.lic"`
+	PublicKey   string        `yaml:"publicKey" json:"publicKey" default:"etc/baetyl/license.pub"` // the pem-encoded public key of issuer
+	Fingerprint string        `yaml:"fingerprint" json:"fingerprint"`                              // the fingerprint of device, computed if empty
+	Grace       time.Duration `yaml:"grace" json:"grace"`                                          // the license is still valid in the grace period after expired
+}
@@ -0,0 +1,41 @@
+package license
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net"
+	"sort"
+	"strings"
+)
+
+// the files of machine id, the first one found is used
+var machineIDFiles = []string{"/etc/machine-id", "/var/lib/dbus/machine-id"}
+
+// Fingerprint computes the fingerprint of device, which is the sha256 of the machine id
+// and the hardware addresses of all network interfaces (sorted)
+func Fingerprint() (string, error) {
+	var parts []string
+	for _, f := range machineIDFiles {
+		data, err := ioutil.ReadFile(f)
+		if err == nil {
+			parts = append(parts, strings.TrimSpace(string(data)))
+			break
+		}
+	}
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return "", err
+	}
+	var macs []string
+	for _, i := range ifaces {
+		if i.Flags&net.FlagLoopback != 0 || len(i.HardwareAddr) == 0 {
+			continue
+		}
+		macs = append(macs, i.HardwareAddr.String())
+	}
+	sort.Strings(macs)
+	parts = append(parts, macs...)
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\n")))
+	return hex.EncodeToString(sum[:]), nil
+}
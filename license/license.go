@@ -0,0 +1,129 @@
+// Package license verifies the signed license (activation) file, which binds the device fingerprint,
+// the validity period, the features and the quotas, and exposes the entitlement checks to services.
+package license
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/baetyl/baetyl-go/errors"
+)
+
+// FeatureAll the feature which entitles all features
+const FeatureAll = "*"
+
+// all errors of license
+var (
+	ErrSignatureInvalid   = errors.Coded(errors.CodeUnauthenticated, "license signature is invalid")
+	ErrFingerprintInvalid = errors.Coded(errors.CodePermissionDenied, "license is not issued to this device")
+	ErrNotYetValid        = errors.Coded(errors.CodeFailedPrecondition, "license is not yet valid")
+	ErrExpired            = errors.Coded(errors.CodeFailedPrecondition, "license is expired")
+)
+
+// License the license issued to a device (or any device if the fingerprint is empty)
+type License struct {
+	ID          string           `json:"id"`
+	Subject     string           `json:"subject,omitempty"` // the customer or product licensed
+	Fingerprint string           `json:"fingerprint,omitempty"`
+	IssuedAt    time.Time        `json:"issuedAt"`
+	NotBefore   time.Time        `json:"notBefore,omitempty"`
+	ExpiresAt   time.Time        `json:"expiresAt,omitempty"` // never expires if zero
+	Features    []string         `json:"features,omitempty"`
+	Quotas      map[string]int64 `json:"quotas,omitempty"`
+}
+
+// HasFeature checks whether the feature is licensed
+func (l *License) HasFeature(feature string) bool {
+	for _, f := range l.Features {
+		if f == feature || f == FeatureAll {
+			return true
+		}
+	}
+	return false
+}
+
+// Quota returns the quota of the name, returns false if not licensed
+func (l *License) Quota(name string) (int64, bool) {
+	q, ok := l.Quotas[name]
+	return q, ok
+}
+
+// file the license file, the payload is the license in json, which is signed as is
+type file struct {
+	Payload   []byte `json:"payload"`
+	Signature []byte `json:"signature"`
+}
+
+// Sign signs the license by the private key of issuer (ecdsa, rsa or ed25519), returns the license file
+func Sign(l *License, key crypto.Signer) ([]byte, error) {
+	payload, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+	var sig []byte
+	if _, ok := key.(ed25519.PrivateKey); ok {
+		sig, err = key.Sign(rand.Reader, payload, crypto.Hash(0))
+	} else {
+		digest := sha256.Sum256(payload)
+		sig, err = key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(&file{Payload: payload, Signature: sig}, "", "  ")
+}
+
+// Parse verifies the signature of the license file by the public key of issuer and returns the license,
+// the fingerprint and the validity period are not checked
+func Parse(data []byte, pub crypto.PublicKey) (*License, error) {
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, errors.Wrap(err, errors.CodeInvalidArgument, "license file is invalid: "+err.Error())
+	}
+	if !verify(pub, f.Payload, f.Signature) {
+		return nil, ErrSignatureInvalid
+	}
+	var l License
+	if err := json.Unmarshal(f.Payload, &l); err != nil {
+		return nil, errors.Wrap(err, errors.CodeInvalidArgument, "license is invalid: "+err.Error())
+	}
+	return &l, nil
+}
+
+func verify(pub crypto.PublicKey, payload, sig []byte) bool {
+	digest := sha256.Sum256(payload)
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		var es struct{ R, S *big.Int }
+		if rest, err := asn1.Unmarshal(sig, &es); err != nil || len(rest) != 0 {
+			return false
+		}
+		return ecdsa.Verify(k, digest[:], es.R, es.S)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) == nil
+	case ed25519.PublicKey:
+		return ed25519.Verify(k, payload, sig)
+	default:
+		return false
+	}
+}
+
+// ParsePublicKey parses the pem-encoded public key (PKIX) of issuer
+func ParsePublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("public key is not pem-encoded")
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
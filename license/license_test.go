@@ -0,0 +1,170 @@
+package license
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/baetyl/baetyl-go/errors"
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSign(t *testing.T) {
+	ek, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	rk, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	_, dk, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	lic := &License{ID: "1", Features: []string{"a"}, Quotas: map[string]int64{"devices": 10}}
+	for _, key := range []crypto.Signer{ek, rk, dk} {
+		data, err := Sign(lic, key)
+		assert.NoError(t, err)
+		res, err := Parse(data, key.Public())
+		assert.NoError(t, err)
+		assert.Equal(t, lic, res)
+
+		// tampered
+		var f file
+		assert.NoError(t, json.Unmarshal(data, &f))
+		f.Payload = []byte(`{"id":"1","features":["*"]}`)
+		tampered, _ := json.Marshal(&f)
+		_, err = Parse(tampered, key.Public())
+		assert.Equal(t, ErrSignatureInvalid, err)
+	}
+
+	// the public key is of another issuer
+	data, err := Sign(lic, ek)
+	assert.NoError(t, err)
+	_, err = Parse(data, rk.Public())
+	assert.Equal(t, ErrSignatureInvalid, err)
+	_, err = Parse([]byte("{"), ek.Public())
+	assert.Equal(t, errors.CodeInvalidArgument, errors.CodeOf(err))
+
+	assert.True(t, lic.HasFeature("a"))
+	assert.False(t, lic.HasFeature("b"))
+	assert.True(t, (&License{Features: []string{FeatureAll}}).HasFeature("b"))
+}
+
+func TestFingerprint(t *testing.T) {
+	fp1, err := Fingerprint()
+	assert.NoError(t, err)
+	assert.Len(t, fp1, 64)
+	fp2, err := Fingerprint()
+	assert.NoError(t, err)
+	assert.Equal(t, fp1, fp2)
+}
+
+func TestVerifier(t *testing.T) {
+	dir, err := ioutil.TempDir("", t.Name())
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	assert.NoError(t, err)
+
+	var cfg Config
+	assert.NoError(t, utils.SetDefaults(&cfg))
+	assert.Equal(t, "etc/baetyl/license.lic", cfg.File)
+	cfg.File = filepath.Join(dir, "license.lic")
+	cfg.PublicKey = filepath.Join(dir, "license.pub")
+	cfg.Fingerprint = "device-1"
+	cfg.Grace = time.Hour
+	assert.NoError(t, ioutil.WriteFile(cfg.PublicKey, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644))
+
+	now := time.Now()
+	write := func(lic *License) {
+		data, err := Sign(lic, key)
+		assert.NoError(t, err)
+		assert.NoError(t, ioutil.WriteFile(cfg.File, data, 0644))
+	}
+	lic := &License{
+		ID:          "1",
+		Fingerprint: "device-1",
+		IssuedAt:    now,
+		ExpiresAt:   now.Add(24 * time.Hour),
+		Features:    []string{"rule"},
+		Quotas:      map[string]int64{"devices": 10},
+	}
+	write(lic)
+
+	v, err := NewVerifier(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, "device-1", v.Fingerprint())
+	assert.Equal(t, "1", v.License().ID)
+	assert.NoError(t, v.Check("rule"))
+	err = v.Check("bridge")
+	assert.EqualError(t, err, "feature (bridge) not licensed")
+	assert.Equal(t, errors.CodePermissionDenied, errors.CodeOf(err))
+	assert.NoError(t, v.CheckQuota("devices", 10))
+	err = v.CheckQuota("devices", 11)
+	assert.EqualError(t, err, "quota (devices) exceeded: 11 > 10")
+	assert.Equal(t, errors.CodeResourceExhausted, errors.CodeOf(err))
+	assert.EqualError(t, v.CheckQuota("nodes", 1), "quota (nodes) not licensed")
+	remaining, ok := v.Expiring()
+	assert.True(t, ok)
+	assert.True(t, remaining > 23*time.Hour)
+
+	// expired, but in the grace period
+	v.now = func() time.Time { return now.Add(24*time.Hour + 30*time.Minute) }
+	assert.NoError(t, v.Check("rule"))
+	v.now = func() time.Time { return now.Add(26 * time.Hour) }
+	assert.Equal(t, ErrExpired, v.Check("rule"))
+	assert.Equal(t, ErrExpired, v.CheckQuota("devices", 1))
+	v.now = func() time.Time { return now.Add(-time.Hour) }
+	assert.NoError(t, v.Valid())
+
+	// reload the license activated again
+	lic.ID = "2"
+	lic.NotBefore = now
+	lic.ExpiresAt = time.Time{}
+	write(lic)
+	v.now = time.Now
+	assert.NoError(t, v.Reload())
+	assert.Equal(t, "2", v.License().ID)
+	_, ok = v.Expiring()
+	assert.False(t, ok)
+	v.now = func() time.Time { return now.Add(-time.Hour) }
+	assert.Equal(t, ErrNotYetValid, v.Valid())
+	v.now = time.Now
+
+	// the license of another device is rejected and the current one is kept
+	lic.ID = "3"
+	lic.Fingerprint = "device-2"
+	write(lic)
+	assert.Equal(t, ErrFingerprintInvalid, v.Reload())
+	assert.Equal(t, "2", v.License().ID)
+	_, err = NewVerifier(cfg)
+	assert.Equal(t, ErrFingerprintInvalid, err)
+
+	// the license for any device
+	lic.Fingerprint = ""
+	write(lic)
+	assert.NoError(t, v.Reload())
+	assert.Equal(t, "3", v.License().ID)
+
+	// expired
+	lic.ExpiresAt = now.Add(-2 * time.Hour)
+	write(lic)
+	_, err = NewVerifier(cfg)
+	assert.Equal(t, ErrExpired, err)
+
+	assert.NoError(t, ioutil.WriteFile(cfg.PublicKey, []byte("invalid"), 0644))
+	_, err = NewVerifier(cfg)
+	assert.EqualError(t, err, "public key is not pem-encoded")
+}
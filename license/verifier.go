@@ -0,0 +1,141 @@
+package license
+
+import (
+	"crypto"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/baetyl/baetyl-go/errors"
+	"github.com/baetyl/baetyl-go/log"
+)
+
+// Verifier the verifier which loads the license file and checks the entitlements of services
+type Verifier struct {
+	cfg         Config
+	pub         crypto.PublicKey
+	fingerprint string
+	lic         *License
+	now         func() time.Time
+	mu          sync.RWMutex
+	log         *log.Logger
+}
+
+// NewVerifier creates a new verifier, the license file is loaded and verified,
+// returns error if the license is not issued to this device or not valid now
+func NewVerifier(cfg Config) (*Verifier, error) {
+	data, err := ioutil.ReadFile(cfg.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := ParsePublicKey(data)
+	if err != nil {
+		return nil, err
+	}
+	fp := cfg.Fingerprint
+	if fp == "" {
+		fp, err = Fingerprint()
+		if err != nil {
+			return nil, err
+		}
+	}
+	v := &Verifier{
+		cfg:         cfg,
+		pub:         pub,
+		fingerprint: fp,
+		now:         time.Now,
+		log:         log.With(log.Any("license", "verifier")),
+	}
+	if err = v.Reload(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Reload reloads the license file, such as the device is activated again,
+// the current license is kept if the new one is invalid
+func (v *Verifier) Reload() error {
+	data, err := ioutil.ReadFile(v.cfg.File)
+	if err != nil {
+		return err
+	}
+	lic, err := Parse(data, v.pub)
+	if err != nil {
+		return err
+	}
+	if lic.Fingerprint != "" && lic.Fingerprint != v.fingerprint {
+		return ErrFingerprintInvalid
+	}
+	if err = v.valid(lic); err != nil {
+		return err
+	}
+	v.mu.Lock()
+	v.lic = lic
+	v.mu.Unlock()
+	v.log.Info("license loaded", log.Any("id", lic.ID), log.Any("subject", lic.Subject), log.Any("expiresAt", lic.ExpiresAt))
+	return nil
+}
+
+// Fingerprint returns the fingerprint of this device
+func (v *Verifier) Fingerprint() string {
+	return v.fingerprint
+}
+
+// License returns the license loaded
+func (v *Verifier) License() *License {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.lic
+}
+
+// Valid checks whether the license is valid now, the license is still valid in the grace period after expired
+func (v *Verifier) Valid() error {
+	return v.valid(v.License())
+}
+
+func (v *Verifier) valid(lic *License) error {
+	now := v.now()
+	if !lic.NotBefore.IsZero() && now.Before(lic.NotBefore) {
+		return ErrNotYetValid
+	}
+	if !lic.ExpiresAt.IsZero() && now.After(lic.ExpiresAt.Add(v.cfg.Grace)) {
+		return ErrExpired
+	}
+	return nil
+}
+
+// Expiring returns the remaining time before expired, returns false if the license never expires
+func (v *Verifier) Expiring() (time.Duration, bool) {
+	lic := v.License()
+	if lic.ExpiresAt.IsZero() {
+		return 0, false
+	}
+	return lic.ExpiresAt.Sub(v.now()), true
+}
+
+// Check checks whether the feature is entitled, the license must be valid
+func (v *Verifier) Check(feature string) error {
+	if err := v.Valid(); err != nil {
+		return err
+	}
+	if !v.License().HasFeature(feature) {
+		return errors.Coded(errors.CodePermissionDenied, "feature (%s) not licensed", feature)
+	}
+	return nil
+}
+
+// CheckQuota checks whether the amount used doesn't exceed the quota, the license must be valid,
+// the resource is denied if the quota is not present in the license
+func (v *Verifier) CheckQuota(name string, used int64) error {
+	if err := v.Valid(); err != nil {
+		return err
+	}
+	q, ok := v.License().Quota(name)
+	if !ok {
+		return errors.Coded(errors.CodePermissionDenied, "quota (%s) not licensed", name)
+	}
+	if used > q {
+		return errors.Coded(errors.CodeResourceExhausted, "quota (%s) exceeded: %d > %d", name, used, q)
+	}
+	return nil
+}
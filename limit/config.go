@@ -0,0 +1,16 @@
+package limit
+
+import "github.com/baetyl/baetyl-go/utils"
+
+// Config the config of limiter, the zero values mean unlimited. The rates of each client are adapted to
+// the fair share of the total rates among the clients connected, so that a runaway client can not starve others.
+type Config struct {
+	MaxConnections          int        `yaml:"maxConnections" json:"maxConnections" validate:"min=0"`                   // the max connections of all clients
+	MaxConnectionsPerClient int        `yaml:"maxConnectionsPerClient" json:"maxConnectionsPerClient" validate:"min=0"` // the max connections of each client
+	MaxPayloadSize          utils.Size `yaml:"maxPayloadSize" json:"maxPayloadSize"`                                    // the max size of each message
+	MessageRate             float64    `yaml:"messageRate" json:"messageRate" validate:"min=0"`                         // the messages per second of each client
+	TotalMessageRate        float64    `yaml:"totalMessageRate" json:"totalMessageRate" validate:"min=0"`               // the messages per second of all clients
+	ByteRate                utils.Size `yaml:"byteRate" json:"byteRate"`                                                // the payload bytes per second of each client
+	TotalByteRate           utils.Size `yaml:"totalByteRate" json:"totalByteRate"`                                      // the payload bytes per second of all clients
+	Burst                   float64    `yaml:"burst" json:"burst" default:"1" validate:"min=0"`                         // the seconds of rates allowed to burst
+}
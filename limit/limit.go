@@ -0,0 +1,213 @@
+// Package limit enforces the quotas of clients on the servers, such as the connections, the message rate,
+// the byte rate and the payload size of each client, which protects the gateway from runaway or malicious clients.
+package limit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/baetyl/baetyl-go/errors"
+)
+
+// all errors, which are coded as resource exhausted
+var (
+	ErrTooManyConnections = errors.Coded(errors.CodeResourceExhausted, "too many connections")
+	ErrRateLimited        = errors.Coded(errors.CodeResourceExhausted, "rate limited")
+	ErrPayloadTooLarge    = errors.Coded(errors.CodeResourceExhausted, "payload too large")
+)
+
+// the states of clients disconnected and idle for the interval are swept
+const sweepInterval = time.Minute
+
+// Limiter the limiter of clients identified by names, such as the client ids of mqtt or the usernames of link
+type Limiter struct {
+	cfg     Config
+	clients map[string]*client
+	conns   int
+	active  int // the number of clients connected
+	swept   time.Time
+	mu      sync.Mutex
+	now     func() time.Time
+}
+
+type client struct {
+	conns int
+	seen  time.Time
+	msgs  bucket
+	bytes bucket
+}
+
+// NewLimiter creates a new limiter
+func NewLimiter(cfg Config) *Limiter {
+	return &Limiter{
+		cfg:     cfg,
+		clients: map[string]*client{},
+		now:     time.Now,
+	}
+}
+
+// Connect takes a connection of the client, the release function must be called once the connection is closed
+func (l *Limiter) Connect(name string) (func(), error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.cfg.MaxConnections > 0 && l.conns >= l.cfg.MaxConnections {
+		return nil, ErrTooManyConnections
+	}
+	c := l.client(name)
+	if l.cfg.MaxConnectionsPerClient > 0 && c.conns >= l.cfg.MaxConnectionsPerClient {
+		return nil, ErrTooManyConnections
+	}
+	l.conns++
+	c.conns++
+	if c.conns == 1 {
+		l.active++
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.conns--
+			c.conns--
+			if c.conns == 0 {
+				l.active--
+			}
+		})
+	}, nil
+}
+
+// Allow checks the quotas of a message of the size sent by the client, the message is rejected if exceeded
+func (l *Limiter) Allow(name string, size int) error {
+	if l.cfg.MaxPayloadSize > 0 && int64(size) > int64(l.cfg.MaxPayloadSize) {
+		return ErrPayloadTooLarge
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	c := l.client(name)
+	mr, br := l.rates()
+	if !c.msgs.allow(now, mr, l.cfg.Burst, 1) {
+		return ErrRateLimited
+	}
+	if !c.bytes.allow(now, br, l.cfg.Burst, float64(size)) {
+		c.msgs.refund(1)
+		return ErrRateLimited
+	}
+	return nil
+}
+
+// Wait waits until the quotas of a message of the size sent by the client are available, which throttles the client,
+// returns ErrPayloadTooLarge if the size exceeds the max payload size, or the error of context if it is done
+func (l *Limiter) Wait(ctx context.Context, name string, size int) error {
+	if l.cfg.MaxPayloadSize > 0 && int64(size) > int64(l.cfg.MaxPayloadSize) {
+		return ErrPayloadTooLarge
+	}
+	l.mu.Lock()
+	now := l.now()
+	c := l.client(name)
+	mr, br := l.rates()
+	d := c.msgs.reserve(now, mr, l.cfg.Burst, 1)
+	if bd := c.bytes.reserve(now, br, l.cfg.Burst, float64(size)); bd > d {
+		d = bd
+	}
+	l.mu.Unlock()
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// client returns the state of client, which is created if not found (but not counted as active until connected),
+// the states are kept after disconnected so that the client can not reset its quotas by reconnecting
+func (l *Limiter) client(name string) *client {
+	now := l.now()
+	if now.Sub(l.swept) > sweepInterval {
+		for k, c := range l.clients {
+			if c.conns == 0 && now.Sub(c.seen) > sweepInterval {
+				delete(l.clients, k)
+			}
+		}
+		l.swept = now
+	}
+	c, ok := l.clients[name]
+	if !ok {
+		c = &client{}
+		l.clients[name] = c
+	}
+	c.seen = now
+	return c
+}
+
+// rates returns the effective message rate and byte rate of each client, which is adapted to the fair share
+func (l *Limiter) rates() (float64, float64) {
+	n := l.active
+	if n < 1 {
+		n = 1
+	}
+	return share(l.cfg.MessageRate, l.cfg.TotalMessageRate, n), share(float64(l.cfg.ByteRate), float64(l.cfg.TotalByteRate), n)
+}
+
+func share(rate, total float64, n int) float64 {
+	if total <= 0 {
+		return rate
+	}
+	fair := total / float64(n)
+	if rate <= 0 || fair < rate {
+		return fair
+	}
+	return rate
+}
+
+// bucket the token bucket, whose capacity is the rate multiplied by the burst seconds (at least one token)
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func (b *bucket) fill(now time.Time, rate, burst float64) {
+	capacity := math.Max(rate*burst, 1)
+	if b.last.IsZero() {
+		b.tokens = capacity
+	} else if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(capacity, b.tokens+elapsed*rate)
+	}
+	b.last = now
+}
+
+func (b *bucket) allow(now time.Time, rate, burst, n float64) bool {
+	if rate <= 0 {
+		return true
+	}
+	b.fill(now, rate, burst)
+	if b.tokens < math.Min(n, math.Max(rate*burst, 1)) {
+		return false
+	}
+	b.tokens -= n
+	return true
+}
+
+// reserve takes the tokens in debt and returns the duration to wait until the debt is paid
+func (b *bucket) reserve(now time.Time, rate, burst, n float64) time.Duration {
+	if rate <= 0 {
+		return 0
+	}
+	b.fill(now, rate, burst)
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / rate * float64(time.Second))
+}
+
+func (b *bucket) refund(n float64) {
+	b.tokens += n
+}
@@ -0,0 +1,113 @@
+package limit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/baetyl/baetyl-go/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time {
+	return c.t
+}
+
+func newTestLimiter(cfg Config) (*Limiter, *clock) {
+	c := &clock{t: time.Unix(1000, 0)}
+	l := NewLimiter(cfg)
+	l.now = c.now
+	return l, c
+}
+
+func TestConnect(t *testing.T) {
+	l, _ := newTestLimiter(Config{MaxConnections: 3, MaxConnectionsPerClient: 2})
+	r1, err := l.Connect("c1")
+	assert.NoError(t, err)
+	r2, err := l.Connect("c1")
+	assert.NoError(t, err)
+	_, err = l.Connect("c1")
+	assert.Equal(t, ErrTooManyConnections, err)
+	assert.Equal(t, errors.CodeResourceExhausted, errors.CodeOf(err))
+	r3, err := l.Connect("c2")
+	assert.NoError(t, err)
+	_, err = l.Connect("c3")
+	assert.Equal(t, ErrTooManyConnections, err)
+
+	r1()
+	r1()
+	_, err = l.Connect("c3")
+	assert.NoError(t, err)
+	r2()
+	r3()
+	assert.Equal(t, 1, l.conns)
+	assert.Equal(t, 1, l.active)
+}
+
+func TestAllow(t *testing.T) {
+	l, c := newTestLimiter(Config{MessageRate: 2, ByteRate: 100, Burst: 1, MaxPayloadSize: 80})
+	assert.Equal(t, ErrPayloadTooLarge, l.Allow("c1", 81))
+	assert.NoError(t, l.Allow("c1", 10))
+	assert.NoError(t, l.Allow("c1", 10))
+	assert.Equal(t, ErrRateLimited, l.Allow("c1", 10))
+	// the other client has its own quotas
+	assert.NoError(t, l.Allow("c2", 80))
+	assert.Equal(t, ErrRateLimited, l.Allow("c2", 80))
+
+	c.t = c.t.Add(500 * time.Millisecond)
+	assert.NoError(t, l.Allow("c1", 10))
+	assert.Equal(t, ErrRateLimited, l.Allow("c1", 10))
+
+	// byte rate exceeded, the message token is refunded
+	c.t = c.t.Add(time.Second)
+	assert.NoError(t, l.Allow("c2", 80))
+	assert.Equal(t, ErrRateLimited, l.Allow("c2", 30))
+	assert.NoError(t, l.Allow("c2", 20))
+}
+
+func TestAdaptive(t *testing.T) {
+	l, c := newTestLimiter(Config{MessageRate: 10, TotalMessageRate: 10, Burst: 1})
+	r1, err := l.Connect("c1")
+	assert.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, l.Allow("c1", 0))
+	}
+	assert.Equal(t, ErrRateLimited, l.Allow("c1", 0))
+
+	// the rate of each client is the fair share after another client connected
+	_, err = l.Connect("c2")
+	assert.NoError(t, err)
+	c.t = c.t.Add(time.Second)
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, l.Allow("c1", 0))
+	}
+	assert.Equal(t, ErrRateLimited, l.Allow("c1", 0))
+
+	// the state is kept after disconnected, and swept after idle
+	r1()
+	assert.Len(t, l.clients, 2)
+	c.t = c.t.Add(2 * sweepInterval)
+	assert.NoError(t, l.Allow("c3", 0))
+	assert.Len(t, l.clients, 2)
+	_, ok := l.clients["c1"]
+	assert.False(t, ok)
+}
+
+func TestWait(t *testing.T) {
+	l := NewLimiter(Config{MessageRate: 20, Burst: 0.05, MaxPayloadSize: 10})
+	assert.Equal(t, ErrPayloadTooLarge, l.Wait(context.Background(), "c1", 11))
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, l.Wait(context.Background(), "c1", 1))
+	}
+	assert.True(t, time.Since(start) >= 90*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, l.Wait(ctx, "c1", 1))
+}
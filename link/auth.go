@@ -0,0 +1,83 @@
+package link
+
+import (
+	"strings"
+
+	"github.com/baetyl/baetyl-go/auth"
+	"github.com/baetyl/baetyl-go/errors"
+	"github.com/baetyl/baetyl-go/utils"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// KeyAuthorization the metadata key of bearer token
+const KeyAuthorization = "authorization"
+
+type authenticator struct {
+	a auth.Authenticator
+	z auth.Authorizer
+}
+
+// NewAuthenticator creates the authenticator of server by the authenticator and the authorizer of auth package,
+// the credentials are the username and password or the bearer token in metadata, and the client certificate,
+// the action authorized is call and the resource is the full method name, such as /link.Link/Call
+func NewAuthenticator(a auth.Authenticator, z auth.Authorizer) Authenticator {
+	return &authenticator{a: a, z: z}
+}
+
+func (a *authenticator) Authenticate(ctx context.Context) error {
+	p, err := a.a.Authenticate(NewCredentials(ctx))
+	if err != nil {
+		return errors.ToGRPC(auth.ErrUnauthenticated)
+	}
+	if a.z == nil {
+		return nil
+	}
+	method, _ := grpc.Method(ctx)
+	if err = a.z.Authorize(p, auth.ActionCall, method); err != nil {
+		return errors.ToGRPC(err)
+	}
+	return nil
+}
+
+// NewCredentials extracts the credentials from the incoming context of server
+func NewCredentials(ctx context.Context) *auth.Credentials {
+	c := &auth.Credentials{}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		c.Username = first(md, KeyUsername)
+		c.Password = first(md, KeyPassword)
+		if v := first(md, KeyAuthorization); len(v) > 7 && strings.EqualFold(v[:7], "bearer ") {
+			c.Token = strings.TrimSpace(v[7:])
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(info.State.VerifiedChains) > 0 {
+			c.Certificates = info.State.VerifiedChains[0]
+		}
+	}
+	return c
+}
+
+// PeerIdentity returns the identity of the client certificate verified from the incoming context of server,
+// so that the device is identified by the certificate instead of the username and password
+func PeerIdentity(ctx context.Context) (*utils.PeerIdentity, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, false
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return nil, false
+	}
+	return utils.GetPeerIdentity(info.State)
+}
+
+func first(md metadata.MD, key string) string {
+	if vs := md.Get(key); len(vs) > 0 {
+		return vs[0]
+	}
+	return ""
+}
@@ -0,0 +1,52 @@
+package link
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/baetyl/baetyl-go/auth"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthenticator(t *testing.T) {
+	a := auth.NewPasswords(map[string]string{"u1": "p1", "u2": "p2"})
+	z := auth.NewACL([]auth.Rule{{Principal: "u1", Permissions: []auth.Permission{
+		{Action: auth.ActionCall, Resources: []string{"/link.Link/Call"}},
+	}}})
+	s, err := NewServer(newServerConfig(), NewAuthenticator(a, z))
+	assert.NoError(t, err)
+	RegisterLinkServer(s, &mockServer{t: t})
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go s.Serve(lis)
+	defer s.Stop()
+
+	call := func(username, password string) error {
+		cc := newClientConfig()
+		cc.Address = lis.Addr().String()
+		cc.Username = username
+		cc.Password = password
+		conn, err := NewClientConn(cc)
+		assert.NoError(t, err)
+		defer conn.Close()
+		_, err = NewLinkClient(conn).Call(context.Background(), &Message{})
+		return err
+	}
+	assert.NoError(t, call("u1", "p1"))
+	assert.Equal(t, codes.Unauthenticated, status.Code(call("u1", "p2")))
+	assert.Equal(t, codes.PermissionDenied, status.Code(call("u2", "p2")))
+}
+
+func TestNewCredentials(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		KeyUsername, "u1",
+		KeyPassword, "p1",
+		KeyAuthorization, "Bearer t1",
+	))
+	assert.Equal(t, &auth.Credentials{Username: "u1", Password: "p1", Token: "t1"}, NewCredentials(ctx))
+	assert.Equal(t, &auth.Credentials{}, NewCredentials(context.Background()))
+}
@@ -0,0 +1,105 @@
+package link
+
+import (
+	"context"
+	"encoding/binary"
+	"strconv"
+
+	"github.com/baetyl/baetyl-go/errors"
+)
+
+// KeyBatchCount the header key of the count of messages packed in the batch, which is set in the ack of batch as well
+const KeyBatchCount = "batchCount"
+
+// ErrBatchInvalid the content of batch is invalid
+var ErrBatchInvalid = errors.Coded(errors.CodeInvalidArgument, "batch is invalid")
+
+// IsBatch checks whether the message is a batch of messages
+func (m *Message) IsBatch() bool {
+	return m.Header(KeyBatchCount) != ""
+}
+
+// NewBatch packs the messages into a batch, each message is framed by its length (4 bytes in big endian).
+// The batch is of qos 1 if any message is of qos 1, and takes the id of the last message, so that the batch is
+// acknowledged once by the peer after all messages are handled.
+func NewBatch(msgs []*Message) (*Message, error) {
+	if len(msgs) == 0 {
+		return nil, errors.Coded(errors.CodeInvalidArgument, "batch is empty")
+	}
+	size := 0
+	for _, m := range msgs {
+		size += 4 + m.Size()
+	}
+	batch := &Message{Content: make([]byte, size)}
+	off := 0
+	for _, m := range msgs {
+		n, err := m.MarshalTo(batch.Content[off+4:])
+		if err != nil {
+			return nil, err
+		}
+		binary.BigEndian.PutUint32(batch.Content[off:], uint32(n))
+		off += 4 + n
+		if m.Context.QOS > batch.Context.QOS {
+			batch.Context.QOS = m.Context.QOS
+		}
+	}
+	batch.Context.ID = msgs[len(msgs)-1].Context.ID
+	batch.SetHeader(KeyBatchCount, strconv.Itoa(len(msgs)))
+	return batch, nil
+}
+
+// Unpack unpacks the messages of the batch, the content compressed is decompressed first
+func (m *Message) Unpack() ([]*Message, error) {
+	count, err := strconv.Atoi(m.Header(KeyBatchCount))
+	if err != nil || count < 0 {
+		return nil, ErrBatchInvalid
+	}
+	if err = m.Decompress(0); err != nil {
+		return nil, err
+	}
+	msgs := make([]*Message, 0, count)
+	data := m.Content
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return nil, ErrBatchInvalid
+		}
+		n := int(binary.BigEndian.Uint32(data))
+		if len(data) < 4+n {
+			return nil, ErrBatchInvalid
+		}
+		msg := &Message{}
+		if err = msg.Unmarshal(data[4 : 4+n]); err != nil {
+			return nil, ErrBatchInvalid
+		}
+		msgs = append(msgs, msg)
+		data = data[4+n:]
+	}
+	if len(msgs) != count {
+		return nil, ErrBatchInvalid
+	}
+	return msgs, nil
+}
+
+// SendBatch packs the messages into a batch and sends it asynchronously, the messages are handled one by one by the peer,
+// and the batch is acknowledged once, so that the observer gets one ack (with the count in header) for all messages
+func (c *Client) SendBatch(msgs []*Message) error {
+	return c.SendBatchContext(context.Background(), msgs)
+}
+
+// SendBatchContext packs the messages into a batch and sends it with context asynchronously
+func (c *Client) SendBatchContext(ctx context.Context, msgs []*Message) error {
+	batch, err := NewBatch(msgs)
+	if err != nil {
+		return err
+	}
+	return c.SendContext(ctx, batch)
+}
+
+// SendBatch packs the messages into a batch and sends it to the client
+func (s *ServiceStream) SendBatch(msgs []*Message) error {
+	batch, err := NewBatch(msgs)
+	if err != nil {
+		return err
+	}
+	return s.Send(batch)
+}
@@ -0,0 +1,113 @@
+package link
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatch(t *testing.T) {
+	_, err := NewBatch(nil)
+	assert.EqualError(t, err, "batch is empty")
+
+	var msgs []*Message
+	for i, c := range []string{"a", "b", "c"} {
+		m := &Message{Content: bytes.Repeat([]byte(c), 100)}
+		m.Context.ID = uint64(i + 1)
+		m.Context.Topic = "t"
+		m.SetHeader("k", c)
+		msgs = append(msgs, m)
+	}
+	msgs[1].Context.QOS = 1
+	batch, err := NewBatch(msgs)
+	assert.NoError(t, err)
+	assert.True(t, batch.IsBatch())
+	assert.Equal(t, uint64(3), batch.Context.ID)
+	assert.Equal(t, uint32(1), batch.Context.QOS)
+	assert.Equal(t, "3", batch.Header(KeyBatchCount))
+
+	assert.NoError(t, batch.Compress(1))
+	assert.Equal(t, EncodingGzip, batch.Context.ContentEncoding)
+	res, err := batch.Unpack()
+	assert.NoError(t, err)
+	assert.Equal(t, msgs, res)
+
+	ack := newAck(batch)
+	assert.Equal(t, Ack, ack.Context.Type)
+	assert.Equal(t, uint64(3), ack.Context.ID)
+	assert.Equal(t, "3", ack.Header(KeyBatchCount))
+
+	batch.SetHeader(KeyBatchCount, "2")
+	_, err = batch.Unpack()
+	assert.Equal(t, ErrBatchInvalid, err)
+	batch.SetHeader(KeyBatchCount, "3")
+	batch.Content = batch.Content[:len(batch.Content)-1]
+	_, err = batch.Unpack()
+	assert.Equal(t, ErrBatchInvalid, err)
+}
+
+func TestSendBatch(t *testing.T) {
+	cfg := newServerConfig()
+	cfg.Address = "127.0.0.1:0"
+	obs := &mockProgressObserver{mockServiceObserver: mockServiceObserver{msgs: make(chan *Message, 10), acks: make(chan *Message, 10)}}
+	svc, err := NewService(cfg, obs, nil)
+	assert.NoError(t, err)
+	defer svc.Close()
+
+	cc := newClientConfig()
+	cc.Address = svc.Addr().String()
+	cobs := newMockObserver(t)
+	cli, err := NewClient(cc, cobs)
+	assert.NoError(t, err)
+	defer cli.Close()
+
+	// the messages are handled one by one, and acknowledged once
+	var msgs []*Message
+	for i, c := range []string{"a", "b", "c"} {
+		m := &Message{Content: []byte(c)}
+		m.Context.ID = uint64(i + 1)
+		m.Context.QOS = 1
+		msgs = append(msgs, m)
+	}
+	assert.NoError(t, cli.SendBatch(msgs))
+	for _, expected := range []string{"a", "b", "c"} {
+		select {
+		case m := <-obs.msgs:
+			assert.Equal(t, expected, string(m.Content))
+		case <-time.After(3 * time.Second):
+			t.Fatal("message not received")
+		}
+	}
+	select {
+	case ack := <-cobs.msgs:
+		assert.Equal(t, Ack, ack.Context.Type)
+		assert.Equal(t, uint64(3), ack.Context.ID)
+		assert.Equal(t, "3", ack.Header(KeyBatchCount))
+	case <-time.After(3 * time.Second):
+		t.Fatal("ack not received")
+	}
+	select {
+	case m := <-cobs.msgs:
+		t.Fatalf("unexpected message: %v", m)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// the batch sent to the client
+	svc.mu.Lock()
+	var ss *ServiceStream
+	for v := range svc.streams {
+		ss = v
+	}
+	svc.mu.Unlock()
+	assert.NoError(t, ss.SendBatch(msgs))
+	for _, expected := range []string{"a", "b", "c"} {
+		select {
+		case m := <-cobs.msgs:
+			assert.Equal(t, expected, string(m.Content))
+		case <-time.After(3 * time.Second):
+			t.Fatal("message not received")
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package link
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/baetyl/baetyl-go/errors"
+	"github.com/baetyl/baetyl-go/log"
+	"github.com/baetyl/baetyl-go/mqtt"
+)
+
+// ErrBridgeNoStream no link stream is talking to the bridge
+var ErrBridgeNoStream = errors.Coded(errors.CodeUnavailable, "no link stream to forward")
+
+// BridgeConfig the config of bridge between link and mqtt, the mqtt topic is the prefix followed by the topic of
+// link message, and the prefix is stripped from the mqtt topic subscribed when forwarded to link.
+// The message is forwarded with the lower qos of itself and the max qos.
+type BridgeConfig struct {
+	MQTT          mqtt.ClientConfig `yaml:"mqtt" json:"mqtt"`
+	Subscriptions []mqtt.QOSTopic   `yaml:"subscriptions" json:"subscriptions"` // the mqtt topics forwarded to link streams
+	Prefix        string            `yaml:"prefix" json:"prefix"`
+	MaxQOS        uint32            `yaml:"maxQos" json:"maxQos" default:"1" validate:"max=1"`
+}
+
+type publisher interface {
+	Publish(qos mqtt.QOS, topic string, payload []byte, pid mqtt.ID, retain bool, dup bool) error
+}
+
+type bridgeStream struct {
+	stream Link_TalkServer
+	mu     sync.Mutex
+}
+
+func (s *bridgeStream) send(msg *Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stream.Send(msg)
+}
+
+// Bridge the bridge which serves as a link server, the messages called or sent by link clients are published to
+// the local mqtt broker, and the mqtt messages subscribed are sent to all link streams talking to the bridge,
+// so that the legacy modules only speaking mqtt interoperate with the services based on link
+type Bridge struct {
+	cfg     BridgeConfig
+	pub     publisher
+	cli     *mqtt.Client
+	streams map[*bridgeStream]struct{}
+	mu      sync.RWMutex
+	log     *log.Logger
+}
+
+// NewBridge creates a new bridge, which connects to the broker and subscribes the topics,
+// the bridge should be registered to the link server by RegisterLinkServer
+func NewBridge(cfg BridgeConfig) (*Bridge, error) {
+	b := newBridge(cfg, nil)
+	var subs []mqtt.Subscription
+	for _, s := range cfg.Subscriptions {
+		if !mqtt.CheckTopic(s.Topic, true) {
+			return nil, fmt.Errorf("topic (%s) is invalid", s.Topic)
+		}
+		subs = append(subs, mqtt.Subscription{Topic: s.Topic, QOS: mqtt.QOS(s.QOS)})
+	}
+	cli, err := mqtt.NewClient(cfg.MQTT, mqtt.NewObserverWrapper(b.OnPublish, nil, b.onError))
+	if err != nil {
+		return nil, err
+	}
+	if len(subs) > 0 {
+		if err = cli.Subscribe(subs); err != nil {
+			cli.Close()
+			return nil, err
+		}
+	}
+	b.pub, b.cli = cli, cli
+	return b, nil
+}
+
+func newBridge(cfg BridgeConfig, pub publisher) *Bridge {
+	return &Bridge{
+		cfg:     cfg,
+		pub:     pub,
+		streams: map[*bridgeStream]struct{}{},
+		log:     log.With(log.Any("link", "bridge")),
+	}
+}
+
+// Call publishes the message to mqtt, an empty message is returned
+func (b *Bridge) Call(_ context.Context, msg *Message) (*Message, error) {
+	if err := b.publish(msg); err != nil {
+		return nil, err
+	}
+	return &Message{}, nil
+}
+
+// Talk publishes the messages received from the stream to mqtt, the message of qos 1 is acknowledged once published,
+// and sends the mqtt messages subscribed to the stream until it is closed
+func (b *Bridge) Talk(stream Link_TalkServer) error {
+	s := &bridgeStream{stream: stream}
+	b.mu.Lock()
+	b.streams[s] = struct{}{}
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		delete(b.streams, s)
+		b.mu.Unlock()
+	}()
+
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			return nil
+		}
+		switch msg.Context.Type {
+		case Msg, MsgRtn:
+			if err = b.publish(msg); err != nil {
+				b.log.Warn("failed to publish message", log.Any("topic", msg.Context.Topic), log.Error(err))
+				continue
+			}
+			if msg.Context.QOS == 1 {
+				ack := &Message{}
+				ack.Context.ID = msg.Context.ID
+				ack.Context.Type = Ack
+				if err = s.send(ack); err != nil {
+					return err
+				}
+			}
+		case Ack:
+			// the messages are forwarded to link at most once, the acks are ignored
+		default:
+			return ErrClientMessageTypeInvalid
+		}
+	}
+}
+
+func (b *Bridge) publish(msg *Message) error {
+	topic := b.cfg.Prefix + msg.Context.Topic
+	if !mqtt.CheckTopic(topic, false) {
+		return errors.Coded(errors.CodeInvalidArgument, "topic (%s) is invalid", topic)
+	}
+	qos := msg.Context.QOS
+	if qos > b.cfg.MaxQOS {
+		qos = b.cfg.MaxQOS
+	}
+	// the mqtt peers don't know the content encoding of link
+	if err := msg.Decompress(0); err != nil {
+		return errors.Wrap(err, errors.CodeInvalidArgument, err.Error())
+	}
+	return b.pub.Publish(mqtt.QOS(qos), topic, msg.Content, 0, msg.Retain(), false)
+}
+
+// OnPublish sends the mqtt message to all link streams, returns error if no stream
+// so that the message of qos 1 is not acknowledged
+func (b *Bridge) OnPublish(pkt *mqtt.Publish) error {
+	msg := &Message{Content: pkt.Message.Payload}
+	msg.Context.ID = uint64(pkt.ID)
+	msg.Context.Topic = strings.TrimPrefix(pkt.Message.Topic, b.cfg.Prefix)
+	msg.Context.QOS = uint32(pkt.Message.QOS)
+	if msg.Context.QOS > b.cfg.MaxQOS {
+		msg.Context.QOS = b.cfg.MaxQOS
+	}
+	if pkt.Message.Retain {
+		msg.Context.Type = MsgRtn
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.streams) == 0 {
+		return ErrBridgeNoStream
+	}
+	for s := range b.streams {
+		if err := s.send(msg); err != nil {
+			b.log.Warn("failed to send message to link stream", log.Any("topic", msg.Context.Topic), log.Error(err))
+		}
+	}
+	return nil
+}
+
+func (b *Bridge) onError(err error) {
+	b.log.Error("error occurs", log.Error(err))
+}
+
+// Close closes the mqtt client
+func (b *Bridge) Close() error {
+	if b.cli == nil {
+		return nil
+	}
+	return b.cli.Close()
+}
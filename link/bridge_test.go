@@ -0,0 +1,151 @@
+package link
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/baetyl/baetyl-go/mqtt"
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+type bridgePublished struct {
+	qos     mqtt.QOS
+	topic   string
+	payload string
+	retain  bool
+}
+
+type mockPublisher struct {
+	pubs chan bridgePublished
+}
+
+func (p *mockPublisher) Publish(qos mqtt.QOS, topic string, payload []byte, pid mqtt.ID, retain bool, dup bool) error {
+	p.pubs <- bridgePublished{qos: qos, topic: topic, payload: string(payload), retain: retain}
+	return nil
+}
+
+func newBridgePublish(topic string, qos mqtt.QOS, payload string) *mqtt.Publish {
+	pkt := mqtt.NewPublish()
+	pkt.Message.Topic = topic
+	pkt.Message.QOS = qos
+	pkt.Message.Payload = []byte(payload)
+	return pkt
+}
+
+func TestBridge(t *testing.T) {
+	var cfg BridgeConfig
+	assert.NoError(t, utils.SetDefaults(&cfg))
+	assert.Equal(t, uint32(1), cfg.MaxQOS)
+	cfg.Prefix = "link/"
+	pub := &mockPublisher{pubs: make(chan bridgePublished, 10)}
+	b := newBridge(cfg, pub)
+	defer b.Close()
+
+	svr, err := NewServer(newServerConfig(), nil)
+	assert.NoError(t, err)
+	RegisterLinkServer(svr, b)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go svr.Serve(lis)
+	defer svr.Stop()
+
+	// no stream yet
+	assert.Equal(t, ErrBridgeNoStream, b.OnPublish(newBridgePublish("link/a", 0, "x")))
+
+	cc := newClientConfig()
+	cc.Address = lis.Addr().String()
+	obs := newMockObserver(t)
+	cli, err := NewClient(cc, obs)
+	assert.NoError(t, err)
+	defer cli.Close()
+
+	// link to mqtt by call
+	msg := &Message{Content: []byte("hello")}
+	msg.Context.Topic = "a/b"
+	msg.Context.QOS = 1
+	_, err = cli.Call(msg)
+	assert.NoError(t, err)
+	assert.Equal(t, bridgePublished{qos: 1, topic: "link/a/b", payload: "hello"}, <-pub.pubs)
+	msg.Context.Topic = "a/+"
+	_, err = cli.Call(msg)
+	assert.EqualError(t, err, "rpc error: code = InvalidArgument desc = topic (link/a/+) is invalid")
+
+	// link to mqtt by stream, the message of qos 1 is acknowledged
+	msg = &Message{Content: []byte("retained")}
+	msg.Context.ID = 7
+	msg.Context.QOS = 1
+	msg.Context.Type = MsgRtn
+	msg.Context.Topic = "c"
+	assert.NoError(t, cli.Send(msg))
+	assert.Equal(t, bridgePublished{qos: 1, topic: "link/c", payload: "retained", retain: true}, <-pub.pubs)
+	ack := <-obs.msgs
+	assert.Equal(t, Ack, ack.Context.Type)
+	assert.Equal(t, uint64(7), ack.Context.ID)
+
+	// mqtt to link
+	assert.Eventually(t, func() bool {
+		return b.OnPublish(newBridgePublish("link/d", 1, "world")) == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	res := <-obs.msgs
+	assert.Equal(t, "d", res.Context.Topic)
+	assert.Equal(t, uint32(1), res.Context.QOS)
+	assert.Equal(t, "world", string(res.Content))
+
+	// the qos is limited
+	b.cfg.MaxQOS = 0
+	assert.NoError(t, b.OnPublish(newBridgePublish("other", 1, "x")))
+	res = <-obs.msgs
+	assert.Equal(t, "other", res.Context.Topic)
+	assert.Equal(t, uint32(0), res.Context.QOS)
+	_, err = cli.CallContext(context.Background(), msg)
+	assert.NoError(t, err)
+	assert.Equal(t, bridgePublished{qos: 0, topic: "link/c", payload: "retained", retain: true}, <-pub.pubs)
+}
+
+func TestBridgeStreams(t *testing.T) {
+	var cfg BridgeConfig
+	assert.NoError(t, utils.SetDefaults(&cfg))
+	b := newBridge(cfg, &mockPublisher{pubs: make(chan bridgePublished, 10)})
+
+	svr, err := NewServer(newServerConfig(), nil)
+	assert.NoError(t, err)
+	RegisterLinkServer(svr, b)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go svr.Serve(lis)
+	defer svr.Stop()
+
+	// the message is sent to all streams
+	var obss []*mockObserver
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		cc := newClientConfig()
+		cc.Address = lis.Addr().String()
+		obs := newMockObserver(t)
+		cli, err := NewClient(cc, obs)
+		assert.NoError(t, err)
+		defer cli.Close()
+		obss = append(obss, obs)
+	}
+	assert.Eventually(t, func() bool {
+		b.mu.RLock()
+		defer b.mu.RUnlock()
+		return len(b.streams) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.NoError(t, b.OnPublish(newBridgePublish("a", 0, "x")))
+	for _, obs := range obss {
+		wg.Add(1)
+		go func(obs *mockObserver) {
+			defer wg.Done()
+			assert.Equal(t, "x", string((<-obs.msgs).Content))
+		}(obs)
+	}
+	wg.Wait()
+
+	_, err = NewBridge(BridgeConfig{Subscriptions: []mqtt.QOSTopic{{Topic: "a/#/b"}}})
+	assert.EqualError(t, err, "topic (a/#/b) is invalid")
+}
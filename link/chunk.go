@@ -0,0 +1,176 @@
+package link
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/baetyl/baetyl-go/errors"
+)
+
+// all header keys of chunked messages
+const (
+	KeyChunkID       = "chunkId"       // the id of the message chunked, generated if not set
+	KeyChunkOffset   = "chunkOffset"   // the offset of chunk in the content
+	KeyChunkTotal    = "chunkTotal"    // the size of the whole content
+	KeyChunkChecksum = "chunkChecksum" // the sha256 checksum of the whole content, carried by the last chunk
+)
+
+// ErrChunkChecksumMismatch the checksum of message reassembled is mismatched
+var ErrChunkChecksumMismatch = errors.Coded(errors.CodeDataLoss, "checksum of chunked message mismatched")
+
+// ProgressObserver the observer of the progress of chunked messages, which is implemented optionally by Observer and
+// ServiceObserver, it is called after every chunk is sent or received with the bytes done and the total size
+type ProgressObserver interface {
+	OnProgress(id string, done, total int64)
+}
+
+// IsChunk checks whether the message is a chunk of a large message
+func (m *Message) IsChunk() bool {
+	return m.Header(KeyChunkID) != ""
+}
+
+// sendChunks reads the content of the size from the reader, and sends it in chunks of the message by the function in order,
+// the chunks except the last one are sent with qos 0, so that the message is acknowledged once after reassembled
+func sendChunks(ctx context.Context, send func(context.Context, *Message) error, msg *Message, r io.Reader, total int64, chunkSize int, progress func(string, int64, int64)) error {
+	if chunkSize <= 0 {
+		return errors.Coded(errors.CodeInvalidArgument, "chunk size (%d) is invalid", chunkSize)
+	}
+	id := msg.Header(KeyChunkID)
+	if id == "" {
+		id = chunkIDs.next()
+	}
+	sum := sha256.New()
+	var offset int64
+	for {
+		n := total - offset
+		if n > int64(chunkSize) {
+			n = int64(chunkSize)
+		}
+		// the content is not reused, since the chunk may be sent asynchronously
+		content := make([]byte, n)
+		if _, err := io.ReadFull(r, content); err != nil {
+			return err
+		}
+		sum.Write(content)
+		last := offset+n == total
+
+		chunk := *msg
+		chunk.Content = content
+		chunk.Context.Headers = make(map[string]string, len(msg.Context.Headers)+4)
+		for k, v := range msg.Context.Headers {
+			chunk.Context.Headers[k] = v
+		}
+		chunk.SetHeader(KeyChunkID, id)
+		chunk.SetHeader(KeyChunkOffset, strconv.FormatInt(offset, 10))
+		chunk.SetHeader(KeyChunkTotal, strconv.FormatInt(total, 10))
+		if last {
+			chunk.SetHeader(KeyChunkChecksum, hex.EncodeToString(sum.Sum(nil)))
+		} else {
+			chunk.Context.QOS = 0
+		}
+		if err := send(ctx, &chunk); err != nil {
+			return err
+		}
+		offset += n
+		if progress != nil {
+			progress(id, offset, total)
+		}
+		if last {
+			return nil
+		}
+	}
+}
+
+// sendFile sends the content of file in chunks of the message
+func sendFile(ctx context.Context, send func(context.Context, *Message) error, msg *Message, path string, chunkSize int, progress func(string, int64, int64)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return sendChunks(ctx, send, msg, f, info.Size(), chunkSize, progress)
+}
+
+// chunkIDs generates the ids of messages chunked, which are unique in the process
+var chunkIDs = newIDGenerator()
+
+// assembler reassembles the chunks received in order into the whole messages
+type assembler struct {
+	limit int64
+	parts map[string]*assembly
+	mu    sync.Mutex
+}
+
+type assembly struct {
+	content []byte
+	total   int64
+	sum     hash.Hash
+}
+
+func newAssembler(limit int64) *assembler {
+	return &assembler{limit: limit, parts: map[string]*assembly{}}
+}
+
+// add adds the chunk, returns the whole message once all chunks are received and verified, otherwise returns nil.
+// The message is discarded if a chunk is missing or the checksum is mismatched, the chunk resent is ignored.
+func (a *assembler) add(chunk *Message) (*Message, int64, int64, error) {
+	id := chunk.Header(KeyChunkID)
+	offset, err := strconv.ParseInt(chunk.Header(KeyChunkOffset), 10, 64)
+	if err != nil || offset < 0 {
+		return nil, 0, 0, errors.Coded(errors.CodeInvalidArgument, "chunk offset (%s) is invalid", chunk.Header(KeyChunkOffset))
+	}
+	total, err := strconv.ParseInt(chunk.Header(KeyChunkTotal), 10, 64)
+	if err != nil || total < 0 || total > a.limit {
+		return nil, 0, 0, errors.Coded(errors.CodeInvalidArgument, "chunk total (%s) is invalid or exceeds the limit", chunk.Header(KeyChunkTotal))
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, ok := a.parts[id]
+	if offset == 0 {
+		// the first chunk starts or restarts the assembly
+		p = &assembly{content: make([]byte, 0, total), total: total, sum: sha256.New()}
+		a.parts[id] = p
+	} else if !ok {
+		return nil, 0, 0, errors.Coded(errors.CodeDataLoss, "chunk (id=%s, offset=%d) is received before the first one", id, offset)
+	}
+	done := int64(len(p.content))
+	if offset < done {
+		return nil, done, p.total, nil
+	}
+	if offset > done || total != p.total || done+int64(len(chunk.Content)) > total {
+		delete(a.parts, id)
+		return nil, 0, 0, errors.Coded(errors.CodeDataLoss, "chunk (id=%s, offset=%d) is unexpected", id, offset)
+	}
+	p.content = append(p.content, chunk.Content...)
+	p.sum.Write(chunk.Content)
+	done = int64(len(p.content))
+	if done < total {
+		return nil, done, total, nil
+	}
+
+	delete(a.parts, id)
+	if hex.EncodeToString(p.sum.Sum(nil)) != chunk.Header(KeyChunkChecksum) {
+		return nil, 0, 0, ErrChunkChecksumMismatch
+	}
+	msg := *chunk
+	msg.Content = p.content
+	msg.Context.Headers = make(map[string]string, len(chunk.Context.Headers))
+	for k, v := range chunk.Context.Headers {
+		msg.Context.Headers[k] = v
+	}
+	delete(msg.Context.Headers, KeyChunkOffset)
+	delete(msg.Context.Headers, KeyChunkTotal)
+	delete(msg.Context.Headers, KeyChunkChecksum)
+	return &msg, done, total, nil
+}
@@ -0,0 +1,162 @@
+package link
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockProgressObserver struct {
+	mockServiceObserver
+	progress []int64
+	mu       sync.Mutex
+}
+
+func (o *mockProgressObserver) OnMsg(_ *ServiceStream, msg *Message) error {
+	o.msgs <- msg
+	return nil
+}
+
+func (o *mockProgressObserver) OnProgress(_ string, done, _ int64) {
+	o.mu.Lock()
+	o.progress = append(o.progress, done)
+	o.mu.Unlock()
+}
+
+func collectChunks(t *testing.T, msg *Message, chunkSize int) []*Message {
+	var chunks []*Message
+	send := func(_ context.Context, m *Message) error {
+		chunks = append(chunks, m)
+		return nil
+	}
+	assert.NoError(t, sendChunks(context.Background(), send, msg, bytes.NewReader(msg.Content), int64(len(msg.Content)), chunkSize, nil))
+	return chunks
+}
+
+func TestAssembler(t *testing.T) {
+	msg := &Message{Content: []byte("0123456789")}
+	msg.Context.QOS = 1
+	msg.Context.Topic = "t"
+	msg.SetHeader("k", "v")
+	msg.SetHeader(KeyChunkID, "c1")
+	chunks := collectChunks(t, msg, 4)
+	assert.Len(t, chunks, 3)
+	assert.Equal(t, uint32(0), chunks[0].Context.QOS)
+	assert.Equal(t, uint32(1), chunks[2].Context.QOS)
+	assert.Equal(t, "", chunks[0].Header(KeyChunkChecksum))
+	assert.NotEmpty(t, chunks[2].Header(KeyChunkChecksum))
+	// the message of caller is not modified
+	assert.Equal(t, map[string]string{"k": "v", KeyChunkID: "c1"}, msg.Context.Headers)
+
+	// the chunk resent is ignored
+	a := newAssembler(100)
+	for _, c := range []*Message{chunks[0], chunks[1], chunks[1]} {
+		res, _, _, err := a.add(c)
+		assert.NoError(t, err)
+		assert.Nil(t, res)
+	}
+	res, done, total, err := a.add(chunks[2])
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), done)
+	assert.Equal(t, int64(10), total)
+	assert.Equal(t, "0123456789", string(res.Content))
+	assert.Equal(t, "t", res.Context.Topic)
+	assert.Equal(t, uint32(1), res.Context.QOS)
+	assert.Equal(t, map[string]string{"k": "v", KeyChunkID: "c1"}, res.Context.Headers)
+	assert.Empty(t, a.parts)
+
+	// the chunk missing
+	_, _, _, err = a.add(chunks[1])
+	assert.EqualError(t, err, "chunk (id=c1, offset=4) is received before the first one")
+	_, _, _, err = a.add(chunks[0])
+	assert.NoError(t, err)
+	_, _, _, err = a.add(chunks[2])
+	assert.EqualError(t, err, "chunk (id=c1, offset=8) is unexpected")
+	assert.Empty(t, a.parts)
+
+	// the checksum mismatched
+	for _, c := range chunks[:2] {
+		_, _, _, err = a.add(c)
+		assert.NoError(t, err)
+	}
+	broken := *chunks[2]
+	broken.Content = []byte("xx")
+	_, _, _, err = a.add(&broken)
+	assert.Equal(t, ErrChunkChecksumMismatch, err)
+
+	// the limit exceeded
+	_, _, _, err = newAssembler(5).add(chunks[0])
+	assert.EqualError(t, err, "chunk total (10) is invalid or exceeds the limit")
+
+	// the empty content is sent in one chunk
+	chunks = collectChunks(t, &Message{}, 4)
+	assert.Len(t, chunks, 1)
+	res, _, _, err = a.add(chunks[0])
+	assert.NoError(t, err)
+	assert.Empty(t, res.Content)
+}
+
+func TestSendChunked(t *testing.T) {
+	dir, err := ioutil.TempDir("", t.Name())
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "file")
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	assert.NoError(t, ioutil.WriteFile(path, data, 0644))
+
+	cfg := newServerConfig()
+	cfg.Address = "127.0.0.1:0"
+	cfg.ChunkSize = 300
+	obs := &mockProgressObserver{mockServiceObserver: mockServiceObserver{msgs: make(chan *Message, 10), acks: make(chan *Message, 10)}}
+	svc, err := NewService(cfg, obs, nil)
+	assert.NoError(t, err)
+	defer svc.Close()
+
+	cc := newClientConfig()
+	cc.Address = svc.Addr().String()
+	cc.ChunkSize = 300
+	cc.CompressThreshold = 100
+	cobs := newMockObserver(t)
+	cli, err := NewClient(cc, cobs)
+	assert.NoError(t, err)
+	defer cli.Close()
+
+	// the file is sent to the service in chunks
+	msg := &Message{}
+	msg.Context.Topic = "ota"
+	assert.NoError(t, cli.SendFile(context.Background(), msg, path))
+	select {
+	case m := <-obs.msgs:
+		assert.Equal(t, "ota", m.Context.Topic)
+		assert.Equal(t, data, m.Content)
+		assert.Equal(t, "", m.Context.ContentEncoding)
+	case <-time.After(3 * time.Second):
+		t.Fatal("chunked message not received")
+	}
+	obs.mu.Lock()
+	assert.Equal(t, []int64{300, 600, 900, 1000}, obs.progress)
+	obs.mu.Unlock()
+	assert.Error(t, cli.SendFile(context.Background(), msg, filepath.Join(dir, "missing")))
+
+	// the content is sent to the client in chunks
+	svc.mu.Lock()
+	var ss *ServiceStream
+	for v := range svc.streams {
+		ss = v
+	}
+	svc.mu.Unlock()
+	assert.NoError(t, ss.SendChunked(context.Background(), &Message{Content: data}))
+	select {
+	case m := <-cobs.msgs:
+		assert.Equal(t, data, m.Content)
+	case <-time.After(3 * time.Second):
+		t.Fatal("chunked message not received")
+	}
+}
@@ -1,38 +1,92 @@
 package link
 
 import (
+	"bytes"
 	"context"
-	"errors"
+	"crypto/tls"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/baetyl/baetyl-go/errors"
 	"github.com/baetyl/baetyl-go/log"
+	"github.com/baetyl/baetyl-go/metrics"
+	"github.com/baetyl/baetyl-go/queue"
 	"github.com/baetyl/baetyl-go/utils"
-	"github.com/jpillora/backoff"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	_ "google.golang.org/grpc/encoding/gzip" // registers the gzip compressor for both clients and servers
+	"google.golang.org/grpc/keepalive"
 )
 
 // ErrClientAlreadyClosed the client is closed
-var ErrClientAlreadyClosed = errors.New("client is closed")
+var ErrClientAlreadyClosed = errors.Coded(errors.CodeUnavailable, "client is closed")
 
 // ErrClientMessageTypeInvalid the message type is invalid
-var ErrClientMessageTypeInvalid = errors.New("message type is invalid")
+var ErrClientMessageTypeInvalid = errors.Coded(errors.CodeInvalidArgument, "message type is invalid")
+
+// ErrClientSpillStreams the spill is enabled with multiple streams
+var ErrClientSpillStreams = errors.Coded(errors.CodeInvalidArgument, "spill is not supported with multiple streams")
+
+// ErrClientTokenInsecure the token provider is configured without tls
+var ErrClientTokenInsecure = errors.Coded(errors.CodeInvalidArgument, "bearer token requires tls unless token insecure is enabled")
 
 // Client client of contact server
 type Client struct {
+	rr    uint64 // the counter to pick the stream in round robin, the first field to be aligned for atomic operations
 	cfg   ClientConfig
 	cli   LinkClient
 	obs   Observer
 	conn  *grpc.ClientConn
-	cache chan *Message
+	cache []chan *Message // the caches of streams
+	spill *queue.Queue
+	reqs  *requests
+	parts *assembler
+	token *tokenCredentials
+	certs *utils.CertWatcher // reloads the certificate rotated, nil if not watched
+	mu    sync.RWMutex       // protects the connection redialed once the certificate is rotated
 	log   *log.Logger
-	tomb  utils.Tomb
+	sup   *utils.Supervisor
 }
 
 // NewClient creates a new client of functions server
 func NewClient(cc ClientConfig, obs Observer) (*Client, error) {
-	conn, err := NewClientConn(cc)
+	if cc.SpillEnabled && cc.Streams > 1 {
+		return nil, ErrClientSpillStreams
+	}
+	if cc.TokenProvider != nil && !cc.TokenInsecure && cc.Certificate.Key == "" && cc.Certificate.Cert == "" {
+		return nil, ErrClientTokenInsecure
+	}
+	var spill *queue.Queue
+	if cc.SpillEnabled {
+		var err error
+		spill, err = queue.New(cc.Spill)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var certs *utils.CertWatcher
+	if cc.Certificate.WatchInterval > 0 && (cc.Certificate.Key != "" || cc.Certificate.Cert != "") {
+		var err error
+		certs, err = utils.NewCertWatcher(cc.Certificate)
+		if err != nil {
+			if spill != nil {
+				spill.Close()
+			}
+			return nil, err
+		}
+	}
+	token := newTokenCredentials(cc)
+	conn, err := newClientConn(cc, certs, token)
 	if err != nil {
+		if spill != nil {
+			spill.Close()
+		}
+		if certs != nil {
+			certs.Close()
+		}
 		return nil, err
 	}
 	cli := &Client{
@@ -40,109 +94,251 @@ func NewClient(cc ClientConfig, obs Observer) (*Client, error) {
 		obs:   obs,
 		conn:  conn,
 		cli:   NewLinkClient(conn),
-		cache: make(chan *Message, cc.MaxCacheMessages),
+		spill: spill,
+		reqs:  newRequests(),
+		parts: newAssembler(int64(cc.MaxChunkedSize)),
+		token: token,
+		certs: certs,
 		log:   log.With(log.Any("link", "client")),
 	}
-	cli.tomb.Go(cli.connecting)
+	n := cc.Streams
+	if n < 1 {
+		n = 1
+	}
+	cli.sup = utils.NewSupervisor(cli.log)
+	policy := utils.RestartPolicy{MinBackoff: time.Second, MaxBackoff: cc.Interval}
+	for i := 0; i < n; i++ {
+		cache := make(chan *Message, cc.MaxCacheMessages)
+		cli.cache = append(cli.cache, cache)
+		cli.sup.Go(fmt.Sprintf("connecting-%d", i), policy, cli.connecting(cache))
+	}
+	if certs != nil {
+		cli.sup.Go("rotating", policy, cli.rotating)
+	}
 	return cli, nil
 }
 
 // Call calls a request synchronously
 func (c *Client) Call(msg *Message) (*Message, error) {
-	return c.cli.Call(context.Background(), msg, grpc.WaitForReady(true))
+	return c.CallContext(context.Background(), msg)
 }
 
-// CallContext calls a request with context synchronously
+// CallContext calls a request with context synchronously, the response compressed is decompressed
 func (c *Client) CallContext(ctx context.Context, msg *Message) (*Message, error) {
-	return c.cli.Call(ctx, msg, grpc.WaitForReady(true))
+	msg, err := c.compress(msg)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	res, err := c.linkClient().Call(ctx, msg, grpc.WaitForReady(true))
+	if err != nil {
+		return nil, err
+	}
+	metrics.ObserveClientCall(metrics.ProtocolLink, start)
+	if err = res.Decompress(0); err != nil {
+		return nil, err
+	}
+	return res, nil
 }
 
-// Send sends a message asynchronously
+// Send sends a message asynchronously, the message is pushed to the disk queue if spill is enabled
 func (c *Client) Send(msg *Message) error {
-	select {
-	case c.cache <- msg:
-	case <-c.tomb.Dying():
-		return ErrClientAlreadyClosed
-	}
-	return nil
+	return c.SendContext(context.Background(), msg)
 }
 
-// SendContext sends a message with context asynchronously
+// SendContext sends a message with context asynchronously, the message is pushed to the disk queue if spill is enabled
 func (c *Client) SendContext(ctx context.Context, msg *Message) error {
+	msg, err := c.compress(c.inject(ctx, msg))
+	if err != nil {
+		return err
+	}
+	if c.spill != nil {
+		return c.push(msg)
+	}
 	select {
-	case c.cache <- msg:
+	case c.pick(msg) <- msg:
+		metrics.ClientCacheMessages.Inc(metrics.ProtocolLink)
 	case <-ctx.Done():
 		return ctx.Err()
-	case <-c.tomb.Dying():
+	case <-c.sup.Dying():
 		return ErrClientAlreadyClosed
 	}
 	return nil
 }
 
+// pick returns the cache of the stream to send the message, the messages of the same partition key (or the chunks of
+// the same message) are sent by the same stream in order, the others are sent by the streams in round robin
+func (c *Client) pick(msg *Message) chan *Message {
+	n := len(c.cache)
+	if n == 1 {
+		return c.cache[0]
+	}
+	key := msg.PartitionKey()
+	if key == "" {
+		key = msg.Header(KeyChunkID)
+	}
+	if key == "" {
+		return c.cache[atomic.AddUint64(&c.rr, 1)%uint64(n)]
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return c.cache[h.Sum32()%uint32(n)]
+}
+
+// SendChunked sends the message of large content in chunks over the stream, which is reassembled and verified by
+// the peer before handled, the progress is reported to the observer if it implements ProgressObserver
+func (c *Client) SendChunked(ctx context.Context, msg *Message) error {
+	return sendChunks(ctx, c.SendContext, msg, bytes.NewReader(msg.Content), int64(len(msg.Content)), int(c.cfg.ChunkSize), c.onProgress)
+}
+
+// SendFile sends the content of file as the content of message in chunks, such as the packages of ota
+func (c *Client) SendFile(ctx context.Context, msg *Message, path string) error {
+	return sendFile(ctx, c.SendContext, msg, path, int(c.cfg.ChunkSize), c.onProgress)
+}
+
 // Close closes client
 func (c *Client) Close() error {
 	c.log.Info("client is closing")
 	defer c.log.Info("client has closed")
 
-	c.tomb.Kill(nil)
-	err := c.tomb.Wait()
+	err := c.sup.Close()
+	// the messages left in the caches are not sent
+	for _, cache := range c.cache {
+		metrics.ClientCacheMessages.Add(-float64(len(cache)), metrics.ProtocolLink)
+	}
+	c.mu.RLock()
 	c.conn.Close()
+	c.mu.RUnlock()
+	if c.certs != nil {
+		c.certs.Close()
+	}
+	if c.spill != nil {
+		if cerr := c.spill.Close(); cerr != nil {
+			c.log.Warn("failed to close spill queue", log.Error(cerr))
+		}
+	}
 	return err
 }
 
-func (c *Client) connecting() error {
-	c.log.Info("client starts to keep connect")
-	defer c.log.Info("client has stopped connecting")
-
-	var err error
+// connecting returns the task to connect and keep sending the messages of the cache, which is restarted by the
+// supervisor with backoff once disconnected, the message not sent by the previous stream is sent first by the next
+func (c *Client) connecting(cache chan *Message) utils.Task {
 	var curr *Message
-	var next time.Time
-	var stream *stream
-	timer := time.NewTimer(0)
-	defer timer.Stop()
-	bf := backoff.Backoff{
-		Min:    time.Second,
-		Max:    c.cfg.Interval,
-		Factor: 1.6,
+	var rejected bool
+	var attempted bool
+	return func(r *utils.TaskRun) error {
+		for {
+			c.log.Info("client starts to connect")
+			if attempted {
+				metrics.ClientReconnectsTotal.Inc(metrics.ProtocolLink)
+			}
+			attempted = true
+			stream, err := c.connect(cache)
+			if err != nil {
+				c.onErr("failed to connect", err)
+				return err
+			}
+			c.log.Info("client has connected")
+			metrics.ClientConnections.Inc(metrics.ProtocolLink)
+			r.Reset()
+			if c.spill != nil {
+				stream.draining()
+			} else {
+				curr = stream.sending(curr)
+			}
+			invalid := c.token.invalidate(stream.close())
+			metrics.ClientConnections.Dec(metrics.ProtocolLink)
+			c.log.Info("client has disconnected")
+			// reconnects at once with a new token if the token is rejected, such as expired,
+			// but only once in a row, so that the client keeps backing off if the new token is rejected as well
+			if !invalid {
+				rejected = false
+				return nil
+			}
+			c.log.Info("token of client is rejected")
+			if rejected || !c.sup.Alive() {
+				return nil
+			}
+			rejected = true
+		}
 	}
+}
 
-	for {
-		if !next.IsZero() {
-			timer.Reset(next.Sub(time.Now()))
-			c.log.Info("next reconnect", log.Any("at", next), log.Any("attempt", bf.Attempt()))
-		}
-		if stream != nil {
-			stream.close()
-			stream = nil
-			c.log.Info("client has disconnected")
+// inject returns a copy of the message with the headers injected by the context if the injector is set,
+// so that the message of caller is not modified
+func (c *Client) inject(ctx context.Context, msg *Message) *Message {
+	if c.cfg.MessageInjector == nil {
+		return msg
+	}
+	cp := *msg
+	cp.Context.Headers = make(map[string]string, len(msg.Context.Headers)+2)
+	for k, v := range msg.Context.Headers {
+		cp.Context.Headers[k] = v
+	}
+	c.cfg.MessageInjector(ctx, &cp)
+	return &cp
+}
+
+// compress returns a compressed copy of the message if its content reaches the threshold,
+// so that the message of caller is not modified
+func (c *Client) compress(msg *Message) (*Message, error) {
+	threshold := int(c.cfg.CompressThreshold)
+	if threshold <= 0 || len(msg.Content) < threshold || msg.Context.ContentEncoding != "" {
+		return msg, nil
+	}
+	cp := *msg
+	if err := cp.Compress(threshold); err != nil {
+		return nil, err
+	}
+	return &cp, nil
+}
+
+func (c *Client) onMsg(msg *Message) error {
+	if err := msg.Decompress(0); err != nil {
+		return err
+	}
+	if msg.IsChunk() {
+		whole, done, total, err := c.parts.add(msg)
+		if err != nil {
+			return err
 		}
-		select {
-		case <-c.tomb.Dying():
+		c.onProgress(msg.Header(KeyChunkID), done, total)
+		if whole == nil {
 			return nil
-		case <-timer.C:
 		}
-
-		c.log.Info("client starts to connect")
-		next = time.Now().Add(bf.Duration())
-		stream, err = c.connect()
+		msg = whole
+	}
+	if msg.IsBatch() {
+		msgs, err := msg.Unpack()
 		if err != nil {
-			c.onErr("failed to connect", err)
-			continue
+			return err
 		}
-		c.log.Info("client has connected")
-		bf.Reset()
-		curr = stream.sending(curr)
+		for _, m := range msgs {
+			if err = c.onMsg(m); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
-}
-
-func (c *Client) onMsg(msg *Message) error {
-	if c.obs == nil {
+	if c.reqs.complete(msg) || c.obs == nil {
 		return nil
 	}
+	if c.cfg.ReceiveInterceptor != nil {
+		return c.cfg.ReceiveInterceptor(msg, func() error {
+			return c.obs.OnMsg(msg)
+		})
+	}
 	return c.obs.OnMsg(msg)
 }
 
+func (c *Client) onProgress(id string, done, total int64) {
+	if p, ok := c.obs.(ProgressObserver); ok {
+		p.OnProgress(id, done, total)
+	}
+}
+
 func (c *Client) onAck(msg *Message) error {
+	metrics.ObserveClientMessage(metrics.ProtocolLink, metrics.StateAcked)
 	if c.obs == nil {
 		return nil
 	}
@@ -157,19 +353,78 @@ func (c *Client) onErr(msg string, err error) {
 	c.obs.OnErr(err)
 }
 
-// NewClientConn creates a new grpc client connection
-func NewClientConn(cc ClientConfig) (*grpc.ClientConn, error) {
+// linkClient returns the client of the current connection
+func (c *Client) linkClient() LinkClient {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cli
+}
+
+// rotating redials the connection once the certificate is rotated, so that the streams reconnect by the new connection,
+// which verifies the server by the CA rotated, the key pair rotated is used by the handshakes of the new connection
+func (c *Client) rotating(r *utils.TaskRun) error {
+	rotated := c.certs.Changed()
+	for {
+		select {
+		case <-r.Dying():
+			return nil
+		case <-rotated:
+		}
+		rotated = c.certs.Changed()
+		conn, err := newClientConn(c.cfg, c.certs, c.token)
+		if err != nil {
+			c.log.Warn("failed to redial by the certificate rotated", log.Error(err))
+			continue
+		}
+		c.mu.Lock()
+		old := c.conn
+		c.conn = conn
+		c.cli = NewLinkClient(conn)
+		c.mu.Unlock()
+		old.Close()
+		c.log.Info("certificate is rotated, client reconnects")
+	}
+}
+
+// NewClientConn creates a new grpc client connection, the extra options (such as interceptors) are appended
+func NewClientConn(cc ClientConfig, extra ...grpc.DialOption) (*grpc.ClientConn, error) {
+	return newClientConn(cc, nil, newTokenCredentials(cc), extra...)
+}
+
+func newClientConn(cc ClientConfig, certs *utils.CertWatcher, token *tokenCredentials, extra ...grpc.DialOption) (*grpc.ClientConn, error) {
+	callOpts := []grpc.CallOption{grpc.MaxCallRecvMsgSize(int(cc.MaxMessageSize))}
+	if cc.Compressor != "" {
+		callOpts = append(callOpts, grpc.UseCompressor(cc.Compressor))
+	}
 	opts := []grpc.DialOption{
-		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(int(cc.MaxMessageSize))),
+		grpc.WithDefaultCallOptions(callOpts...),
+	}
+	// enable keepalive pings, the interval must not be less than the min time allowed by the server
+	if cc.KeepaliveTime > 0 {
+		opts = append(opts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:    cc.KeepaliveTime,
+			Timeout: cc.KeepaliveTimeout,
+		}))
+	}
+	// enable retry of calls
+	if cc.MaxRetries > 0 {
+		opts = append(opts, grpc.WithChainUnaryInterceptor(newRetryInterceptor(cc.MaxRetries, cc.RetryInterval)))
 	}
 	// enable tls
 	if cc.Certificate.Key != "" || cc.Certificate.Cert != "" {
-		tlsCfg, err := utils.NewTLSConfigClient(cc.Certificate)
+		var tlsCfg *tls.Config
+		var err error
+		if certs != nil {
+			tlsCfg = certs.TLSConfigClient()
+		} else {
+			tlsCfg, err = utils.NewTLSConfigClient(cc.Certificate)
+		}
 		if err != nil {
 			return nil, err
 		}
 		if tlsCfg != nil {
-			// TODO: thing auth
+			// the client is identified by its certificate if given, the username and password are optional,
+			// and the server certificate is checked by the common names and organizational units allowed
 			if !cc.Certificate.InsecureSkipVerify {
 				tlsCfg.ServerName = cc.Certificate.Name
 			}
@@ -180,6 +435,11 @@ func NewClientConn(cc ClientConfig) (*grpc.ClientConn, error) {
 		opts = append(opts, grpc.WithInsecure())
 	}
 
+	// enable bearer token
+	if token != nil {
+		opts = append(opts, grpc.WithPerRPCCredentials(token), grpc.WithChainUnaryInterceptor(token.interceptor()))
+	}
+
 	//  enable username/password
 	if cc.Username != "" || cc.Password != "" {
 		opts = append(opts, grpc.WithPerRPCCredentials(MD{
@@ -188,5 +448,6 @@ func NewClientConn(cc ClientConfig) (*grpc.ClientConn, error) {
 		}))
 	}
 
-	return grpc.Dial(cc.Address, opts...)
+	opts = append(opts, cc.DialOptions...)
+	return grpc.Dial(cc.Address, append(opts, extra...)...)
 }
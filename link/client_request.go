@@ -0,0 +1,106 @@
+package link
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// idGenerator generates the ids of the prefix of the creation time and an increasing sequence
+type idGenerator struct {
+	prefix string
+	seq    uint64
+}
+
+func newIDGenerator() *idGenerator {
+	return &idGenerator{prefix: strconv.FormatInt(time.Now().UnixNano(), 36) + "-"}
+}
+
+func (g *idGenerator) next() string {
+	return g.prefix + strconv.FormatUint(atomic.AddUint64(&g.seq, 1), 10)
+}
+
+// requests the callers of Request waiting for the replies of the correlation ids
+type requests struct {
+	ids *idGenerator
+	chs map[string]chan *Message
+	mu  sync.Mutex
+}
+
+func newRequests() *requests {
+	return &requests{
+		ids: newIDGenerator(),
+		chs: map[string]chan *Message{},
+	}
+}
+
+func (r *requests) add(id string) chan *Message {
+	ch := make(chan *Message, 1)
+	r.mu.Lock()
+	r.chs[id] = ch
+	r.mu.Unlock()
+	return ch
+}
+
+func (r *requests) remove(id string) {
+	r.mu.Lock()
+	delete(r.chs, id)
+	r.mu.Unlock()
+}
+
+// complete notifies the waiter of the correlation id of the reply, returns false if no one is waiting
+func (r *requests) complete(msg *Message) bool {
+	id := msg.CorrelationID()
+	if id == "" {
+		return false
+	}
+	r.mu.Lock()
+	ch, ok := r.chs[id]
+	delete(r.chs, id)
+	r.mu.Unlock()
+	if ok {
+		ch <- msg
+	}
+	return ok
+}
+
+// Request sends a request over the stream and waits for the reply until the timeout of config
+func (c *Client) Request(msg *Message) (*Message, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.Timeout)
+	defer cancel()
+	return c.RequestContext(ctx, msg)
+}
+
+// RequestContext sends a request over the stream instead of a unary call, and blocks until the reply with the same
+// correlation id is received, returns an error if the context expires or the client is closed. The correlation id is
+// generated if not set, and the reply is created by NewReply of the request at the peer. The reply received after
+// the caller returns is handled by the observer as other messages.
+func (c *Client) RequestContext(ctx context.Context, msg *Message) (*Message, error) {
+	// the headers are copied, so that the message of caller is not modified
+	req := *msg
+	req.Context.Headers = make(map[string]string, len(msg.Context.Headers)+1)
+	for k, v := range msg.Context.Headers {
+		req.Context.Headers[k] = v
+	}
+	id := req.CorrelationID()
+	if id == "" {
+		id = c.reqs.ids.next()
+		req.SetCorrelationID(id)
+	}
+	ch := c.reqs.add(id)
+	defer c.reqs.remove(id)
+
+	if err := c.SendContext(ctx, &req); err != nil {
+		return nil, err
+	}
+	select {
+	case res := <-ch:
+		return res, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.sup.Dying():
+		return nil, ErrClientAlreadyClosed
+	}
+}
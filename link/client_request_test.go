@@ -0,0 +1,103 @@
+package link
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockReplyObserver struct {
+	mockServiceObserver
+}
+
+func (o *mockReplyObserver) OnMsg(s *ServiceStream, msg *Message) error {
+	switch string(msg.Content) {
+	case "ignore":
+		return nil
+	case "late":
+		time.Sleep(200 * time.Millisecond)
+	}
+	res := msg.NewReply()
+	res.Content = append([]byte("re:"), msg.Content...)
+	return s.Send(res)
+}
+
+func TestMessageCorrelationID(t *testing.T) {
+	msg := &Message{}
+	assert.Equal(t, "", msg.CorrelationID())
+	msg.Context.Topic = "t"
+	msg.SetCorrelationID("c1")
+	assert.Equal(t, "c1", msg.Header(KeyCorrelationID))
+
+	res := msg.NewReply()
+	assert.Equal(t, Msg, res.Context.Type)
+	assert.Equal(t, "t", res.Context.Topic)
+	assert.Equal(t, "c1", res.CorrelationID())
+	assert.Nil(t, (&Message{}).NewReply().Context.Headers)
+}
+
+func TestClientRequest(t *testing.T) {
+	cfg := newServerConfig()
+	cfg.Address = "127.0.0.1:0"
+	svc, err := NewService(cfg, &mockReplyObserver{}, nil)
+	assert.NoError(t, err)
+	defer svc.Close()
+
+	cc := newClientConfig()
+	cc.Address = svc.Addr().String()
+	cc.Timeout = 100 * time.Millisecond
+	cobs := newMockObserver(t)
+	cli, err := NewClient(cc, cobs)
+	assert.NoError(t, err)
+	defer cli.Close()
+
+	msg := &Message{Content: []byte("hi")}
+	msg.Context.Topic = "t"
+	msg.SetHeader("k", "v")
+	res, err := cli.RequestContext(context.Background(), msg)
+	assert.NoError(t, err)
+	assert.Equal(t, "re:hi", string(res.Content))
+	assert.Equal(t, "t", res.Context.Topic)
+	assert.NotEmpty(t, res.CorrelationID())
+	// the message of caller is not modified
+	assert.Equal(t, map[string]string{"k": "v"}, msg.Context.Headers)
+
+	// the correlation id set by the caller is kept
+	msg = &Message{Content: []byte("hello")}
+	msg.SetCorrelationID("c1")
+	res, err = cli.Request(msg)
+	assert.NoError(t, err)
+	assert.Equal(t, "re:hello", string(res.Content))
+	assert.Equal(t, "c1", res.CorrelationID())
+
+	// timed out if not replied
+	_, err = cli.Request(&Message{Content: []byte("ignore")})
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	// the reply received after timeout is handled by the observer
+	_, err = cli.Request(&Message{Content: []byte("late")})
+	assert.Equal(t, context.DeadlineExceeded, err)
+	select {
+	case m := <-cobs.msgs:
+		assert.Equal(t, "re:late", string(m.Content))
+	case <-time.After(3 * time.Second):
+		t.Fatal("late reply not received")
+	}
+
+	// the replies are not passed to the observer
+	for i := 0; i < 3; i++ {
+		_, err = cli.Request(&Message{Content: []byte("hi")})
+		assert.NoError(t, err)
+	}
+	select {
+	case m := <-cobs.msgs:
+		t.Fatalf("unexpected message: %v", m)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	assert.NoError(t, cli.Close())
+	_, err = cli.Request(&Message{Content: []byte("hi")})
+	assert.Equal(t, ErrClientAlreadyClosed, err)
+}
@@ -0,0 +1,114 @@
+package link
+
+import (
+	"context"
+	"sync"
+
+	"github.com/baetyl/baetyl-go/log"
+	"github.com/baetyl/baetyl-go/metrics"
+)
+
+// push appends the message to the disk queue, returns queue.ErrQueueFull if the queue is full and rejects it
+func (c *Client) push(msg *Message) error {
+	if !c.sup.Alive() {
+		return ErrClientAlreadyClosed
+	}
+	data, err := msg.Marshal()
+	if err != nil {
+		return err
+	}
+	_, err = c.spill.Push(data)
+	return err
+}
+
+// draining sends the messages of disk queue until the stream or the client is closed, the message of qos 0
+// is removed from the queue once it is sent, and the message of qos 1 is removed once acknowledged by the server,
+// otherwise it is sent again by the next stream
+func (s *stream) draining() {
+	s.cli.log.Info("client starts to drain messages")
+	defer s.cli.log.Info("client has stopped draining messages")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-s.cli.sup.Dying():
+		case <-s.tomb.Dying():
+		case <-ctx.Done():
+		}
+		cancel()
+	}()
+
+	// the messages popped but not acknowledged by the last stream are delivered again
+	s.cli.spill.Rewind()
+	for {
+		qm, err := s.cli.spill.Pop(ctx)
+		if err != nil {
+			return
+		}
+		msg := &Message{}
+		if err = msg.Unmarshal(qm.Data); err != nil {
+			metrics.ObserveClientMessage(metrics.ProtocolLink, metrics.StateDropped)
+			s.cli.log.Warn("drop the broken message of spill queue", log.Any("offset", qm.Offset), log.Error(err))
+			s.ack(qm.Offset)
+			continue
+		}
+		if msg.Context.QOS == 1 {
+			s.inflight.add(msg.Context.ID, qm.Offset)
+		}
+		if err = s.send(msg); err != nil {
+			return
+		}
+		metrics.ObserveClientMessage(metrics.ProtocolLink, metrics.StateSent)
+		if msg.Context.QOS != 1 {
+			s.ack(qm.Offset)
+		}
+	}
+}
+
+// acked removes the message acknowledged by the server from the disk queue
+func (s *stream) acked(msg *Message) {
+	if s.cli.spill == nil {
+		return
+	}
+	if offset, ok := s.inflight.remove(msg.Context.ID); ok {
+		s.ack(offset)
+	}
+}
+
+func (s *stream) ack(offset uint64) {
+	if err := s.cli.spill.Ack(offset); err != nil {
+		s.cli.log.Warn("failed to acknowledge message of spill queue", log.Any("offset", offset), log.Error(err))
+	}
+}
+
+// inflight tracks the offsets of the messages sent by message id, the messages of the same id are acknowledged in order
+type inflight struct {
+	offsets map[uint64][]uint64
+	mu      sync.Mutex
+}
+
+func newInflight() *inflight {
+	return &inflight{offsets: map[uint64][]uint64{}}
+}
+
+func (f *inflight) add(id, offset uint64) {
+	f.mu.Lock()
+	f.offsets[id] = append(f.offsets[id], offset)
+	f.mu.Unlock()
+}
+
+func (f *inflight) remove(id uint64) (uint64, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	offsets, ok := f.offsets[id]
+	if !ok {
+		return 0, false
+	}
+	if len(offsets) == 1 {
+		delete(f.offsets, id)
+	} else {
+		f.offsets[id] = offsets[1:]
+	}
+	return offsets[0], true
+}
@@ -0,0 +1,116 @@
+package link
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/baetyl/baetyl-go/queue"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientSpill(t *testing.T) {
+	dir, err := ioutil.TempDir("", t.Name())
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// reserves an address not listened yet
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := lis.Addr().String()
+	lis.Close()
+
+	cc := newClientConfig()
+	cc.Address = addr
+	cc.SpillEnabled = true
+	cc.Spill.Dir = dir
+	cc.Spill.MaxSize = 1024
+	cc.Spill.Overflow = queue.OverflowReject
+	cli, err := NewClient(cc, nil)
+	assert.NoError(t, err)
+	for _, c := range []string{"a", "b", "c"} {
+		assert.NoError(t, cli.Send(&Message{Content: []byte(c)}))
+	}
+	assert.Equal(t, queue.ErrQueueFull, cli.Send(&Message{Content: make([]byte, 1024)}))
+	assert.NoError(t, cli.Close())
+	assert.Equal(t, ErrClientAlreadyClosed, cli.Send(&Message{Content: []byte("d")}))
+
+	// the messages spilled are sent after the client is created again
+	cfg := newServerConfig()
+	cfg.Address = addr
+	obs := &mockServiceObserver{msgs: make(chan *Message, 10), acks: make(chan *Message, 10)}
+	svc, err := NewService(cfg, obs, nil)
+	assert.NoError(t, err)
+	defer svc.Close()
+
+	cli, err = NewClient(cc, nil)
+	assert.NoError(t, err)
+	defer cli.Close()
+	assert.NoError(t, cli.Send(&Message{Content: []byte("d")}))
+	for _, expected := range []string{"a", "b", "c", "d"} {
+		select {
+		case msg := <-obs.msgs:
+			assert.Equal(t, expected, string(msg.Content))
+		case <-time.After(3 * time.Second):
+			t.Fatal("message not received")
+		}
+	}
+	assert.Eventually(t, func() bool { return cli.spill.Len() == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestClientSpillAck(t *testing.T) {
+	dir, err := ioutil.TempDir("", t.Name())
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cfg := newServerConfig()
+	cfg.Address = "127.0.0.1:0"
+	obs := &mockServiceObserver{msgs: make(chan *Message, 10), acks: make(chan *Message, 10)}
+	svc, err := NewService(cfg, obs, nil)
+	assert.NoError(t, err)
+	defer svc.Close()
+
+	cc := newClientConfig()
+	cc.Address = svc.Addr().String()
+	cc.SpillEnabled = true
+	cc.Spill.Dir = dir
+	cc.Streams = 2
+	_, err = NewClient(cc, nil)
+	assert.Equal(t, ErrClientSpillStreams, err)
+
+	// the observer fails to handle the messages of other users, so that the message of qos 1 is not acknowledged
+	cc.Streams = 1
+	cc.Username = "u2"
+	cli, err := NewClient(cc, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, cli.Send(&Message{Context: Context{ID: 1, QOS: 1}, Content: []byte("a")}))
+	assert.NoError(t, cli.Send(&Message{Context: Context{ID: 2}, Content: []byte("b")}))
+	for _, expected := range []string{"a", "b"} {
+		select {
+		case msg := <-obs.msgs:
+			assert.Equal(t, expected, string(msg.Content))
+		case <-time.After(3 * time.Second):
+			t.Fatal("message not received")
+		}
+	}
+	assert.Eventually(t, func() bool { return cli.spill.Len() == 1 }, time.Second, 10*time.Millisecond)
+	assert.NoError(t, cli.Close())
+
+	// the message not acknowledged is sent again, and removed once acknowledged, the message after it
+	// is delivered again as well since only the offset acknowledged in order survives restarts
+	cc.Username = "u1"
+	cli, err = NewClient(cc, nil)
+	assert.NoError(t, err)
+	defer cli.Close()
+	for _, expected := range []string{"a", "b"} {
+		select {
+		case msg := <-obs.msgs:
+			assert.Equal(t, expected, string(msg.Content))
+		case <-time.After(3 * time.Second):
+			t.Fatal("message not received")
+		}
+	}
+	assert.Eventually(t, func() bool { return cli.spill.Len() == 0 }, time.Second, 10*time.Millisecond)
+}
@@ -6,25 +6,30 @@ import (
 	"sync"
 
 	"github.com/baetyl/baetyl-go/log"
+	"github.com/baetyl/baetyl-go/metrics"
 	"github.com/baetyl/baetyl-go/utils"
 )
 
 type stream struct {
-	cli  *Client
-	conn Link_TalkClient
-	tomb utils.Tomb
-	once sync.Once
-	mu   sync.Mutex
+	cli      *Client
+	conn     Link_TalkClient
+	cache    chan *Message
+	inflight *inflight // the messages of spill queue sent but not acknowledged by the server
+	tomb     utils.Tomb
+	once     sync.Once
+	mu       sync.Mutex
 }
 
-func (c *Client) connect() (*stream, error) {
-	cs, err := c.cli.Talk(context.Background())
+func (c *Client) connect(cache chan *Message) (*stream, error) {
+	cs, err := c.linkClient().Talk(context.Background())
 	if err != nil {
 		return nil, err
 	}
 	s := &stream{
-		cli:  c,
-		conn: cs,
+		cli:      c,
+		conn:     cs,
+		cache:    cache,
+		inflight: newInflight(),
 	}
 	s.tomb.Go(s.receiving)
 	return s, nil
@@ -56,15 +61,18 @@ func (s *stream) sending(curr *Message) *Message {
 		if err != nil {
 			return curr
 		}
+		metrics.ObserveClientMessage(metrics.ProtocolLink, metrics.StateSent)
 	}
 	for {
 		select {
-		case msg := <-s.cli.cache:
+		case msg := <-s.cache:
+			metrics.ClientCacheMessages.Dec(metrics.ProtocolLink)
 			err = s.send(msg)
 			if err != nil {
 				return msg
 			}
-		case <-s.cli.tomb.Dying():
+			metrics.ObserveClientMessage(metrics.ProtocolLink, metrics.StateSent)
+		case <-s.cli.sup.Dying():
 			return nil
 		case <-s.tomb.Dying():
 			return nil
@@ -96,12 +104,10 @@ func (s *stream) receiving() error {
 			if uerr != nil {
 				s.cli.log.Warn("failed to handle publish packet in user code", log.Error(uerr))
 			} else if !s.cli.cfg.DisableAutoAck && qos == 1 {
-				ack := &Message{}
-				ack.Context.ID = msg.Context.ID
-				ack.Context.Type = Ack
-				err = s.send(ack)
+				err = s.send(newAck(msg))
 			}
 		case Ack:
+			s.acked(msg)
 			err = s.cli.onAck(msg)
 		default:
 			err = ErrClientMessageTypeInvalid
@@ -123,6 +129,9 @@ func (s *stream) die(msg string, err error) {
 // ! called in the same goroutine with sending
 func (s *stream) close() error {
 	s.die("", nil)
+	// not concurrent with the acks sent by receiving
+	s.mu.Lock()
 	s.conn.CloseSend()
+	s.mu.Unlock()
 	return s.tomb.Wait()
 }
@@ -0,0 +1,103 @@
+package link
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientStreams(t *testing.T) {
+	cfg := newServerConfig()
+	cfg.Address = "127.0.0.1:0"
+	obs := &mockProgressObserver{mockServiceObserver: mockServiceObserver{msgs: make(chan *Message, 1000), acks: make(chan *Message, 10)}}
+	svc, err := NewService(cfg, obs, nil)
+	assert.NoError(t, err)
+	defer svc.Close()
+
+	cc := newClientConfig()
+	cc.Address = svc.Addr().String()
+	cc.Streams = 4
+	cli, err := NewClient(cc, nil)
+	assert.NoError(t, err)
+	defer cli.Close()
+	assert.Len(t, cli.cache, 4)
+
+	// the messages of the same key are picked to the same stream, others in round robin
+	a, b := &Message{}, &Message{}
+	a.SetPartitionKey("a")
+	b.SetPartitionKey("a")
+	assert.True(t, cli.pick(a) == cli.pick(b))
+	picked := map[chan *Message]struct{}{}
+	for i := 0; i < 4; i++ {
+		picked[cli.pick(&Message{})] = struct{}{}
+	}
+	assert.Len(t, picked, 4)
+
+	assert.Eventually(t, func() bool {
+		svc.mu.Lock()
+		defer svc.mu.Unlock()
+		return len(svc.streams) == 4
+	}, 3*time.Second, 10*time.Millisecond)
+
+	// the messages of the same key are received in order
+	keys := []string{"k1", "k2", "k3", ""}
+	for i := 0; i < 100; i++ {
+		for _, k := range keys {
+			msg := &Message{Content: []byte(strconv.Itoa(i))}
+			if k != "" {
+				msg.SetPartitionKey(k)
+			}
+			assert.NoError(t, cli.Send(msg))
+		}
+	}
+	next := map[string]int{}
+	for i := 0; i < 100*len(keys); i++ {
+		select {
+		case msg := <-obs.msgs:
+			k := msg.PartitionKey()
+			if k == "" {
+				continue
+			}
+			assert.Equal(t, strconv.Itoa(next[k]), string(msg.Content), k)
+			next[k]++
+		case <-time.After(3 * time.Second):
+			t.Fatal("message not received")
+		}
+	}
+	assert.Equal(t, map[string]int{"k1": 100, "k2": 100, "k3": 100}, next)
+}
+
+func TestClientInjector(t *testing.T) {
+	cfg := newServerConfig()
+	cfg.Address = "127.0.0.1:0"
+	obs := &mockServiceObserver{msgs: make(chan *Message, 10), acks: make(chan *Message, 10)}
+	svc, err := NewService(cfg, obs, nil)
+	assert.NoError(t, err)
+	defer svc.Close()
+
+	cc := newClientConfig()
+	cc.Address = svc.Addr().String()
+	cc.MessageInjector = func(_ context.Context, msg *Message) {
+		msg.SetHeader("injected", "yes")
+	}
+	cli, err := NewClient(cc, nil)
+	assert.NoError(t, err)
+	defer cli.Close()
+
+	// the messages sent by both Send and SendContext are injected
+	msg := &Message{Content: []byte("a")}
+	assert.NoError(t, cli.Send(msg))
+	assert.NoError(t, cli.SendContext(context.Background(), msg))
+	assert.Empty(t, msg.Header("injected"))
+	for i := 0; i < 2; i++ {
+		select {
+		case m := <-obs.msgs:
+			assert.Equal(t, "yes", m.Header("injected"))
+		case <-time.After(3 * time.Second):
+			t.Fatal("message not received")
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package link
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/baetyl/baetyl-go/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Token the token of client, such as a short-lived jwt
+type Token struct {
+	Value  string
+	Expiry time.Time // the token never expires if zero
+}
+
+// TokenProvider provides the tokens of client, which is called to get a new token before the current one expires
+// or after the server rejects it
+type TokenProvider interface {
+	Token(ctx context.Context) (*Token, error)
+}
+
+// TokenProviderFunc the function of token provider
+type TokenProviderFunc func(ctx context.Context) (*Token, error)
+
+// Token gets a new token
+func (f TokenProviderFunc) Token(ctx context.Context) (*Token, error) {
+	return f(ctx)
+}
+
+// tokenCredentials the per-rpc credentials attaching the bearer token, the token is cached and refreshed before expiry
+type tokenCredentials struct {
+	provider TokenProvider
+	advance  time.Duration // the duration before expiry to refresh
+	insecure bool          // the token is allowed to be sent in plaintext
+	token    *Token
+	mu       sync.Mutex
+}
+
+// newTokenCredentials creates the credentials by the token provider of config, returns nil if not configured
+func newTokenCredentials(cc ClientConfig) *tokenCredentials {
+	if cc.TokenProvider == nil {
+		return nil
+	}
+	return &tokenCredentials{provider: cc.TokenProvider, advance: cc.TokenRefreshAdvance, insecure: cc.TokenInsecure}
+}
+
+// GetRequestMetadata gets the metadata of bearer token, refreshing the token if required
+func (t *tokenCredentials) GetRequestMetadata(ctx context.Context, _ ...string) (map[string]string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token == nil || (!t.token.Expiry.IsZero() && time.Now().Add(t.advance).After(t.token.Expiry)) {
+		token, err := t.provider.Token(ctx)
+		if err != nil {
+			return nil, errors.Coded(errors.CodeUnauthenticated, "failed to get token: %s", err.Error())
+		}
+		t.token = token
+	}
+	return map[string]string{KeyAuthorization: "Bearer " + t.token.Value}, nil
+}
+
+// RequireTransportSecurity indicates whether the credentials requires transport security,
+// the bearer token is not sent in plaintext unless token insecure is enabled
+func (t *tokenCredentials) RequireTransportSecurity() bool {
+	return !t.insecure
+}
+
+// invalidate drops the token cached if the error is unauthenticated, such as the token is expired or revoked,
+// so that a new token is got by the next rpc, returns whether it is dropped
+func (t *tokenCredentials) invalidate(err error) bool {
+	if t == nil || status.Code(err) != codes.Unauthenticated {
+		return false
+	}
+	t.mu.Lock()
+	t.token = nil
+	t.mu.Unlock()
+	return true
+}
+
+// interceptor calls again with a new token once the call is rejected as unauthenticated
+func (t *tokenCredentials) interceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		if t.invalidate(err) {
+			err = invoker(ctx, method, req, reply, cc, opts...)
+		}
+		return err
+	}
+}
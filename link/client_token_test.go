@@ -0,0 +1,74 @@
+package link
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/baetyl/baetyl-go/auth"
+	jwt "github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientToken(t *testing.T) {
+	a, err := auth.NewJWT(auth.JWTConfig{Secret: "secret"})
+	assert.NoError(t, err)
+	cfg := newServerConfig()
+	cfg.Address = "127.0.0.1:0"
+	obs := &mockServiceObserver{msgs: make(chan *Message, 10), acks: make(chan *Message, 10)}
+	svc, err := NewService(cfg, obs, NewAuthenticator(a, nil))
+	assert.NoError(t, err)
+	defer svc.Close()
+
+	// the first token is rejected
+	var count int32
+	provider := TokenProviderFunc(func(context.Context) (*Token, error) {
+		if atomic.AddInt32(&count, 1) == 1 {
+			return &Token{Value: "bad"}, nil
+		}
+		exp := time.Now().Add(500 * time.Millisecond)
+		claims := jwt.StandardClaims{Subject: "u1", ExpiresAt: exp.Add(time.Second).Unix()}
+		v, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+		return &Token{Value: v, Expiry: exp}, err
+	})
+
+	cc := newClientConfig()
+	cc.Address = svc.Addr().String()
+	cc.Username, cc.Password = "", ""
+	cc.TokenProvider = provider
+	cc.TokenRefreshAdvance = 100 * time.Millisecond
+	// the token is not sent in plaintext unless enabled explicitly
+	_, err = NewClient(cc, nil)
+	assert.Equal(t, ErrClientTokenInsecure, err)
+	cc.TokenInsecure = true
+	cli, err := NewClient(cc, nil)
+	assert.NoError(t, err)
+	defer cli.Close()
+
+	// the stream rejected is reconnected at once with a new token, earlier than the min backoff
+	assert.Eventually(t, func() bool {
+		svc.mu.Lock()
+		defer svc.mu.Unlock()
+		return len(svc.streams) == 1
+	}, 800*time.Millisecond, 10*time.Millisecond)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&count))
+
+	// the token cached is used until it is about to expire
+	res, err := cli.Call(&Message{Content: []byte("hi")})
+	assert.NoError(t, err)
+	assert.Equal(t, "hi", string(res.Content))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&count))
+	time.Sleep(500 * time.Millisecond)
+	_, err = cli.Call(&Message{Content: []byte("hi")})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&count))
+
+	// the call rejected is called again with a new token
+	cli.token.mu.Lock()
+	cli.token.token = &Token{Value: "expired"}
+	cli.token.mu.Unlock()
+	_, err = cli.Call(&Message{Content: []byte("hi")})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&count))
+}
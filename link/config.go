@@ -1,28 +1,62 @@
 package link
 
 import (
+	"context"
 	"time"
 
+	"github.com/baetyl/baetyl-go/queue"
 	"github.com/baetyl/baetyl-go/utils"
+	"google.golang.org/grpc"
 )
 
 // ServerConfig link server config
 type ServerConfig struct {
-	Address        string            `yaml:"address" json:"address"`
-	Certificate    utils.Certificate `yaml:",inline" json:",inline"`
-	MaxConcurrent  uint32            `yaml:"maxConcurrent" json:"maxConcurrent"`
-	MaxMessageSize utils.Size        `yaml:"maxMessageSize" json:"maxMessageSize" default:"4m"`
+	Address          string            `yaml:"address" json:"address" validate:"regexp=^(((tcp|grpc|grpcs|ssl|tls)://)?[^/]*:[0-9]+)?$"` // the address to listen, such as 0.0.0.0:8273
+	Certificate      utils.Certificate `yaml:",inline" json:",inline"`
+	MaxConcurrent    uint32            `yaml:"maxConcurrent" json:"maxConcurrent"`
+	MaxMessageSize   utils.Size        `yaml:"maxMessageSize" json:"maxMessageSize" default:"4m"`
+	ShutdownTimeout  time.Duration     `yaml:"shutdownTimeout" json:"shutdownTimeout" default:"10s"`     // the timeout to wait for the calls inflight when the service is closed, stopped at once if zero
+	KeepaliveMinTime time.Duration     `yaml:"keepaliveMinTime" json:"keepaliveMinTime" default:"10s"`   // the min interval of keepalive pings allowed, the client pinging more frequently is disconnected
+	ChunkSize        utils.Size        `yaml:"chunkSize" json:"chunkSize" default:"1048576"`             // the size of chunks of large messages sent, should be less than the max message size
+	MaxChunkedSize   utils.Size        `yaml:"maxChunkedSize" json:"maxChunkedSize" default:"268435456"` // the max size of large messages reassembled from chunks
 }
 
 // ClientConfig link client config
 type ClientConfig struct {
-	Address          string            `yaml:"address" json:"address"`
-	Username         string            `yaml:"username" json:"username"`
-	Password         string            `yaml:"password" json:"password"`
-	Certificate      utils.Certificate `yaml:",inline" json:",inline"`
-	Timeout          time.Duration     `yaml:"timeout" json:"timeout" default:"30s"`
-	Interval         time.Duration     `yaml:"interval" json:"interval" default:"2m"`
-	MaxMessageSize   utils.Size        `yaml:"maxMessageSize" json:"maxMessageSize" default:"4m"`
-	MaxCacheMessages int               `yaml:"maxCacheMessages" json:"maxCacheMessages" default:"10"`
-	DisableAutoAck   bool              `yaml:"disableAutoAck" json:"disableAutoAck"`
+	Address           string            `yaml:"address" json:"address" validate:"regexp=^(((tcp|grpc|grpcs|ssl|tls)://)?[^/]*:[0-9]+|(dns|unix|passthrough):.+)?$"` // the target to dial, such as 127.0.0.1:8273 or unix:///var/run/link.sock
+	Username          string            `yaml:"username" json:"username"`
+	Password          string            `yaml:"password" json:"password"`
+	Certificate       utils.Certificate `yaml:",inline" json:",inline"`
+	Timeout           time.Duration     `yaml:"timeout" json:"timeout" default:"30s"`
+	Interval          time.Duration     `yaml:"interval" json:"interval" default:"2m"`
+	MaxMessageSize    utils.Size        `yaml:"maxMessageSize" json:"maxMessageSize" default:"4m"`
+	MaxCacheMessages  int               `yaml:"maxCacheMessages" json:"maxCacheMessages" default:"10"`
+	Streams           int               `yaml:"streams" json:"streams" default:"1" validate:"min=1"` // the number of streams sending messages in parallel, each has its own cache
+	DisableAutoAck    bool              `yaml:"disableAutoAck" json:"disableAutoAck"`
+	CompressThreshold utils.Size        `yaml:"compressThreshold" json:"compressThreshold"` // the contents reaching the threshold are compressed by gzip, disabled if zero
+	KeepaliveTime     time.Duration     `yaml:"keepaliveTime" json:"keepaliveTime"`         // pings the server after the connection is idle for the duration (at least 10s), disabled if zero
+	KeepaliveTimeout  time.Duration     `yaml:"keepaliveTimeout" json:"keepaliveTimeout" default:"20s"`
+	Compressor        string            `yaml:"compressor" json:"compressor" validate:"regexp=^(gzip)?$"` // the compressor of grpc messages, disabled if empty
+	MaxRetries        int               `yaml:"maxRetries" json:"maxRetries"`                             // the max retries of calls failed as unavailable, disabled if zero
+	RetryInterval     time.Duration     `yaml:"retryInterval" json:"retryInterval" default:"10s"`         // the max interval to retry the calls
+	ChunkSize         utils.Size        `yaml:"chunkSize" json:"chunkSize" default:"1048576"`             // the size of chunks of large messages sent, should be less than the max message size
+	MaxChunkedSize    utils.Size        `yaml:"maxChunkedSize" json:"maxChunkedSize" default:"268435456"` // the max size of large messages reassembled from chunks
+	// the tokens of provider are attached as the bearer tokens of calls, and refreshed the duration in advance of expiry,
+	// the tokens are only sent over tls unless token insecure is enabled explicitly, such as for the local tests
+	TokenProvider       TokenProvider `yaml:"-" json:"-"`
+	TokenRefreshAdvance time.Duration `yaml:"tokenRefreshAdvance" json:"tokenRefreshAdvance" default:"1m"`
+	TokenInsecure       bool          `yaml:"tokenInsecure" json:"tokenInsecure"`
+	// the messages sent asynchronously are spilled to the disk queue instead of the cache in memory if enabled,
+	// which survive restarts, the directory of queue must not be shared by other clients. The queue is drained
+	// by one stream to keep the order, so the streams must not be more than one if enabled
+	SpillEnabled bool         `yaml:"spillEnabled" json:"spillEnabled"`
+	Spill        queue.Config `yaml:"spill" json:"spill"`
+	// the options appended to dial the server, such as the interceptors of tracing
+	DialOptions []grpc.DialOption `yaml:"-" json:"-"`
+	// the injector is called with the context before the message is sent by Send or SendContext, such as to inject
+	// the trace context into the headers, the headers of message are copied so that the caller's is not modified
+	MessageInjector func(ctx context.Context, msg *Message) `yaml:"-" json:"-"`
+	// the interceptor is called with the message received and the handling by the observer, such as to start the span
+	// of the trace context extracted from the headers, the responses of Request are not intercepted
+	ReceiveInterceptor ReceiveInterceptor `yaml:"-" json:"-"`
 }
@@ -0,0 +1,32 @@
+package link
+
+import (
+	"strconv"
+
+	"github.com/baetyl/baetyl-go/dedupe"
+)
+
+type dedupeObserver struct {
+	Observer
+	d     *dedupe.Deduper
+	scope string
+}
+
+// NewDedupeObserver wraps the observer to detect the messages of qos 1 redelivered, the message handled before
+// is acknowledged without passing to the observer again, even across restarts
+func NewDedupeObserver(obs Observer, d *dedupe.Deduper, scope string) Observer {
+	return &dedupeObserver{Observer: obs, d: d, scope: scope}
+}
+
+// OnMsg handles next message
+func (o *dedupeObserver) OnMsg(msg *Message) error {
+	if msg.Context.QOS == 0 {
+		return o.Observer.OnMsg(msg)
+	}
+	id := strconv.FormatUint(msg.Context.ID, 10)
+	content := append(append([]byte(msg.Context.Topic), 0), msg.Content...)
+	_, err := o.d.Handle(o.scope, id, content, func() error {
+		return o.Observer.OnMsg(msg)
+	})
+	return err
+}
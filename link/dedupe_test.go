@@ -0,0 +1,60 @@
+package link
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/baetyl/baetyl-go/dedupe"
+	"github.com/baetyl/baetyl-go/kv"
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDedupeObserver(t *testing.T) {
+	dir, err := ioutil.TempDir("", t.Name())
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	var kc kv.Config
+	assert.NoError(t, utils.SetDefaults(&kc))
+	kc.Path = filepath.Join(dir, "kv.db")
+	store, err := kv.New(kc)
+	assert.NoError(t, err)
+	defer store.Close()
+
+	mock := newMockObserver(t)
+	obs := NewDedupeObserver(mock, dedupe.NewDeduper(dedupe.Config{Prefix: "link/", Window: time.Minute}, store), "c1")
+
+	msg := &Message{Content: []byte("cmd")}
+	msg.Context.ID = 1
+	msg.Context.QOS = 1
+	msg.Context.Topic = "a"
+	assert.NoError(t, obs.OnMsg(msg))
+	assert.NoError(t, obs.OnMsg(msg))
+	mock.assertMsgs(msg)
+
+	// the message failed to handle is passed again
+	msg2 := &Message{Content: []byte("cmd")}
+	msg2.Context.ID = 2
+	msg2.Context.QOS = 1
+	mock.setErrOnMsg(errors.New("error"))
+	assert.Error(t, obs.OnMsg(msg2))
+	mock.setErrOnMsg(nil)
+	assert.NoError(t, obs.OnMsg(msg2))
+	assert.NoError(t, obs.OnMsg(msg2))
+	mock.assertMsgs(msg2, msg2)
+
+	// the message of qos 0 is always passed
+	msg3 := &Message{Content: []byte("cmd")}
+	assert.NoError(t, obs.OnMsg(msg3))
+	assert.NoError(t, obs.OnMsg(msg3))
+	mock.assertMsgs(msg3, msg3)
+	select {
+	case m := <-mock.msgs:
+		t.Fatalf("unexpected message: %v", m)
+	default:
+	}
+}
@@ -0,0 +1,129 @@
+package link
+
+import (
+	"context"
+	"net"
+	gohttp "net/http"
+	"strings"
+
+	"github.com/baetyl/baetyl-go/auth"
+	"github.com/baetyl/baetyl-go/http"
+	"github.com/baetyl/baetyl-go/log"
+	"github.com/baetyl/baetyl-go/utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// PathGatewayCall the http path of gateway to call, which is the full method name of grpc as grpc-gateway does
+const PathGatewayCall = "/link.Link/Call"
+
+// HeaderMetadataPrefix the prefix of http headers which are passed to the link server as metadata
+const HeaderMetadataPrefix = "Grpc-Metadata-"
+
+// GatewayConfig the config of rest gateway, which exposes the link server over http/json
+type GatewayConfig struct {
+	Server http.ServerConfig `yaml:"server" json:"server"`
+	Client ClientConfig      `yaml:"client" json:"client"` // the link server to call
+	// the requests are authenticated by the gateway if set, otherwise the credentials of requests are
+	// passed to the link server (the username and password of client config should be empty)
+	Auth *auth.Config `yaml:"auth" json:"auth"`
+}
+
+// Gateway the rest gateway of link server, the call is mapped to POST whose request and response bodies
+// are messages in json, so that curl-level tooling and legacy apps can reach function services
+type Gateway struct {
+	svr  *http.Server
+	conn *grpc.ClientConn
+	log  *log.Logger
+}
+
+// NewGateway creates a new gateway and starts to serve at PathGatewayCall and PathCall
+func NewGateway(cfg GatewayConfig) (*Gateway, error) {
+	conn, err := NewClientConn(cfg.Client)
+	if err != nil {
+		return nil, err
+	}
+	var mws []http.Middleware
+	if cfg.Auth != nil {
+		a, err := auth.NewAuthenticator(*cfg.Auth)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		mws = append(mws, http.Guard(a, auth.NewAuthorizer(*cfg.Auth)))
+	} else {
+		mws = append(mws, PassCredentials)
+	}
+	svr, err := http.NewServer(cfg.Server, mws...)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	h := NewGatewayHandler(NewLinkClient(conn), cfg.Client.MaxMessageSize)
+	svr.Handle(PathGatewayCall, h)
+	svr.Handle(PathCall, h)
+	return &Gateway{
+		svr:  svr,
+		conn: conn,
+		log:  log.With(log.Any("link", "gateway")),
+	}, nil
+}
+
+// Addr returns the listener address of gateway
+func (g *Gateway) Addr() net.Addr {
+	return g.svr.Addr()
+}
+
+// Close closes the http server and the connection to link server
+func (g *Gateway) Close() error {
+	err := g.svr.Close()
+	if cerr := g.conn.Close(); cerr != nil {
+		g.log.Warn("failed to close connection", log.Error(cerr))
+	}
+	return err
+}
+
+// NewGatewayHandler creates a http handler which calls the link server by the client,
+// the headers prefixed with HeaderMetadataPrefix are passed as metadata, and the request
+// whose body is larger than the max message size (DefaultMaxMessageSize if zero) is rejected
+func NewGatewayHandler(cli LinkClient, maxMessageSize utils.Size) gohttp.Handler {
+	caller := CallerFunc(func(ctx context.Context, msg *Message) (*Message, error) {
+		return cli.Call(ctx, msg, grpc.WaitForReady(true))
+	})
+	h := NewHTTPHandler(caller, maxMessageSize)
+	return gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		var kvs []string
+		for k, vs := range r.Header {
+			if !strings.HasPrefix(k, HeaderMetadataPrefix) {
+				continue
+			}
+			key := strings.ToLower(k[len(HeaderMetadataPrefix):])
+			for _, v := range vs {
+				kvs = append(kvs, key, v)
+			}
+		}
+		if len(kvs) > 0 {
+			r = r.WithContext(metadata.AppendToOutgoingContext(r.Context(), kvs...))
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// PassCredentials the middleware which passes the credentials of request (basic auth or bearer token)
+// to the link server as metadata, so that the requests are authenticated by the link server
+func PassCredentials(next gohttp.Handler) gohttp.Handler {
+	return gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		c := http.NewCredentials(r)
+		var kvs []string
+		if c.Username != "" || c.Password != "" {
+			kvs = append(kvs, KeyUsername, c.Username, KeyPassword, c.Password)
+		}
+		if c.Token != "" {
+			kvs = append(kvs, KeyAuthorization, "Bearer "+c.Token)
+		}
+		if len(kvs) > 0 {
+			r = r.WithContext(metadata.AppendToOutgoingContext(r.Context(), kvs...))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
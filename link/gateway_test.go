@@ -0,0 +1,109 @@
+package link
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	gohttp "net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/baetyl/baetyl-go/auth"
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+)
+
+type metadataServer struct {
+	mockServer
+	md chan metadata.MD
+}
+
+func (s *metadataServer) Call(ctx context.Context, msg *Message) (*Message, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.md <- md
+	return msg, nil
+}
+
+func TestGateway(t *testing.T) {
+	s, err := NewServer(newServerConfig(), NewAuthenticator(auth.NewPasswords(map[string]string{"u1": "p1"}), nil))
+	assert.NoError(t, err)
+	ms := &metadataServer{md: make(chan metadata.MD, 10)}
+	RegisterLinkServer(s, ms)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go s.Serve(lis)
+	defer s.Stop()
+
+	var cfg GatewayConfig
+	assert.NoError(t, utils.SetDefaults(&cfg))
+	cfg.Server.Address = "127.0.0.1:0"
+	cfg.Client.Address = lis.Addr().String()
+
+	call := func(g *Gateway, path, username, password string, headers map[string]string) (int, *Message) {
+		msg := &Message{Content: []byte("hi")}
+		msg.Context.Topic = "t"
+		data, err := json.Marshal(msg)
+		assert.NoError(t, err)
+		req, err := gohttp.NewRequest(gohttp.MethodPost, "http://"+g.Addr().String()+path, bytes.NewReader(data))
+		assert.NoError(t, err)
+		if username != "" {
+			req.SetBasicAuth(username, password)
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		res, err := gohttp.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		defer res.Body.Close()
+		data, err = ioutil.ReadAll(res.Body)
+		assert.NoError(t, err)
+		if res.StatusCode != gohttp.StatusOK {
+			return res.StatusCode, nil
+		}
+		out := &Message{}
+		assert.NoError(t, json.Unmarshal(data, out))
+		return res.StatusCode, out
+	}
+
+	// the credentials are passed to the link server
+	g, err := NewGateway(cfg)
+	assert.NoError(t, err)
+	code, res := call(g, PathGatewayCall, "u1", "p1", map[string]string{"Grpc-Metadata-Trace-Id": "x1"})
+	assert.Equal(t, gohttp.StatusOK, code)
+	assert.Equal(t, "t", res.Context.Topic)
+	assert.Equal(t, []byte("hi"), res.Content)
+	md := <-ms.md
+	assert.Equal(t, []string{"x1"}, md.Get("trace-id"))
+	assert.Equal(t, []string{"u1"}, md.Get(KeyUsername))
+	code, _ = call(g, PathCall, "u1", "p2", nil)
+	assert.Equal(t, gohttp.StatusUnauthorized, code)
+	code, _ = call(g, PathCall, "", "", nil)
+	assert.Equal(t, gohttp.StatusUnauthorized, code)
+	assert.NoError(t, g.Close())
+
+	// the requests are authenticated by the gateway
+	dir, err := ioutil.TempDir("", t.Name())
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	pf := filepath.Join(dir, "passwd")
+	assert.NoError(t, ioutil.WriteFile(pf, []byte("u2:p2\n"), 0600))
+	cfg.Auth = &auth.Config{PasswordFile: pf}
+	cfg.Client.Username = "u1"
+	cfg.Client.Password = "p1"
+	g, err = NewGateway(cfg)
+	assert.NoError(t, err)
+	defer g.Close()
+	code, _ = call(g, PathCall, "u2", "p2", nil)
+	assert.Equal(t, gohttp.StatusOK, code)
+	<-ms.md
+	code, _ = call(g, PathCall, "u1", "p1", nil)
+	assert.Equal(t, gohttp.StatusUnauthorized, code)
+
+	cfg.Auth = &auth.Config{PasswordFile: filepath.Join(dir, "none")}
+	_, err = NewGateway(cfg)
+	assert.Error(t, err)
+}
@@ -0,0 +1,172 @@
+package link
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	gohttp "net/http"
+
+	"github.com/baetyl/baetyl-go/errors"
+	"github.com/baetyl/baetyl-go/http"
+	"github.com/baetyl/baetyl-go/log"
+	"github.com/baetyl/baetyl-go/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// PathCall the http path to call
+const PathCall = "/call"
+
+// DefaultMaxMessageSize the max message size of http handler if not set, the same as the default of config
+const DefaultMaxMessageSize = 4 << 20
+
+// Caller calls a request synchronously, such as link client
+type Caller interface {
+	CallContext(context.Context, *Message) (*Message, error)
+}
+
+// CallerFunc the function to call a request
+type CallerFunc func(context.Context, *Message) (*Message, error)
+
+// CallContext calls the function
+func (f CallerFunc) CallContext(ctx context.Context, msg *Message) (*Message, error) {
+	return f(ctx, msg)
+}
+
+// NewHTTPHandler creates a http handler which exposes the caller over REST,
+// the request (POST) and response bodies are messages in json, the request whose body
+// is larger than the max message size (DefaultMaxMessageSize if zero) is rejected with 413
+func NewHTTPHandler(caller Caller, maxMessageSize utils.Size) gohttp.Handler {
+	logger := log.With(log.Any("link", "http"))
+	max := int64(maxMessageSize)
+	if max <= 0 {
+		max = DefaultMaxMessageSize
+	}
+	return gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		if r.Method != gohttp.MethodPost {
+			gohttp.Error(w, gohttp.StatusText(gohttp.StatusMethodNotAllowed), gohttp.StatusMethodNotAllowed)
+			return
+		}
+		data, err := ioutil.ReadAll(gohttp.MaxBytesReader(w, r.Body, max))
+		if err != nil {
+			// the reader fails once the limit is reached
+			if int64(len(data)) >= max {
+				gohttp.Error(w, gohttp.StatusText(gohttp.StatusRequestEntityTooLarge), gohttp.StatusRequestEntityTooLarge)
+				return
+			}
+			gohttp.Error(w, err.Error(), gohttp.StatusBadRequest)
+			return
+		}
+		var req Message
+		err = json.Unmarshal(data, &req)
+		if err != nil {
+			gohttp.Error(w, err.Error(), gohttp.StatusBadRequest)
+			return
+		}
+		res, err := caller.CallContext(r.Context(), &req)
+		if err != nil {
+			logger.Warn("failed to call", log.Error(err))
+			errors.WriteHTTP(w, errors.FromGRPC(err))
+			return
+		}
+		if res == nil {
+			res = &Message{}
+		}
+		data, err = json.Marshal(res)
+		if err != nil {
+			gohttp.Error(w, err.Error(), gohttp.StatusInternalServerError)
+			return
+		}
+		w.Header().Set(http.HeaderContentType, http.ContentTypeJSON)
+		w.Write(data)
+	})
+}
+
+// HTTPForwarder the link server which forwards messages to a http endpoint (exposed by NewHTTPHandler or others),
+// so that the services only speaking http can serve link clients
+type HTTPForwarder struct {
+	cli  *http.Client
+	path string
+	log  *log.Logger
+}
+
+// NewHTTPForwarder creates a new forwarder, the messages are posted to the path
+func NewHTTPForwarder(cc http.ClientConfig, path string) (*HTTPForwarder, error) {
+	cli, err := http.NewClient(cc)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		path = PathCall
+	}
+	return &HTTPForwarder{
+		cli:  cli,
+		path: path,
+		log:  log.With(log.Any("link", "forwarder")),
+	}, nil
+}
+
+// Call forwards the request to http endpoint
+func (f *HTTPForwarder) Call(ctx context.Context, msg *Message) (*Message, error) {
+	return f.CallContext(ctx, msg)
+}
+
+// CallContext forwards the request to http endpoint
+func (f *HTTPForwarder) CallContext(ctx context.Context, msg *Message) (*Message, error) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	data, err = f.cli.CallContext(ctx, gohttp.MethodPost, f.path, data, map[string]string{http.HeaderContentType: http.ContentTypeJSON})
+	if err != nil {
+		if serr, ok := err.(*http.StatusError); ok {
+			return nil, errors.ToGRPC(errors.FromHTTP(serr.Code, []byte(serr.Message)))
+		}
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	res := &Message{}
+	err = json.Unmarshal(data, res)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return res, nil
+}
+
+// Talk forwards every message in stream to http endpoint, sends back the response if it has content,
+// and acknowledges the message whose qos is 1 after forwarded
+func (f *HTTPForwarder) Talk(stream Link_TalkServer) error {
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		if msg.Context.Type == Ack {
+			continue
+		}
+		res, err := f.CallContext(stream.Context(), msg)
+		if err != nil {
+			f.log.Warn("failed to forward message", log.Any("topic", msg.Context.Topic), log.Error(err))
+			continue
+		}
+		if len(res.Content) > 0 {
+			err = stream.Send(res)
+			if err != nil {
+				return err
+			}
+		}
+		if msg.Context.QOS == 1 {
+			ack := &Message{}
+			ack.Context.ID = msg.Context.ID
+			ack.Context.Type = Ack
+			err = stream.Send(ack)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Close closes the http client
+func (f *HTTPForwarder) Close() error {
+	return f.cli.Close()
+}
@@ -0,0 +1,114 @@
+package link
+
+import (
+	"bytes"
+	"context"
+	"net"
+	gohttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/baetyl/baetyl-go/http"
+	"github.com/creasty/defaults"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func echo(ctx context.Context, msg *Message) (*Message, error) {
+	if msg.Context.Topic == "error" {
+		return nil, status.Error(codes.NotFound, "topic not found")
+	}
+	return msg, nil
+}
+
+func TestHTTPHandler(t *testing.T) {
+	h := NewHTTPHandler(CallerFunc(echo), 1024)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(gohttp.MethodPost, PathCall, bytes.NewBufferString(`{"Context":{"ID":1,"Topic":"t"},"Content":"aGk="}`)))
+	assert.Equal(t, gohttp.StatusOK, w.Code)
+	assert.Equal(t, `{"Context":{"ID":1,"Topic":"t"},"Content":"aGk="}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(gohttp.MethodGet, PathCall, nil))
+	assert.Equal(t, gohttp.StatusMethodNotAllowed, w.Code)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(gohttp.MethodPost, PathCall, bytes.NewBufferString(`{`)))
+	assert.Equal(t, gohttp.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(gohttp.MethodPost, PathCall, bytes.NewBufferString(`{"Content":"`+strings.Repeat("a", 1024)+`"}`)))
+	assert.Equal(t, gohttp.StatusRequestEntityTooLarge, w.Code)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(gohttp.MethodPost, PathCall, bytes.NewBufferString(`{"Context":{"Topic":"error"}}`)))
+	assert.Equal(t, gohttp.StatusNotFound, w.Code)
+	assert.Equal(t, `{"code":"NotFound","message":"topic not found"}`, strings.TrimSpace(w.Body.String()))
+
+	// the default max message size is used if not set
+	h = NewHTTPHandler(CallerFunc(echo), 0)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(gohttp.MethodPost, PathCall, bytes.NewBufferString(`{"Content":"`+strings.Repeat("a", 1024)+`"}`)))
+	assert.Equal(t, gohttp.StatusOK, w.Code)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(gohttp.MethodPost, PathCall, bytes.NewBufferString(`{"Content":"`+strings.Repeat("a", DefaultMaxMessageSize)+`"}`)))
+	assert.Equal(t, gohttp.StatusRequestEntityTooLarge, w.Code)
+}
+
+func TestHTTPForwarder(t *testing.T) {
+	ts := httptest.NewServer(NewHTTPHandler(CallerFunc(echo), 1024))
+	defer ts.Close()
+
+	var hc http.ClientConfig
+	defaults.Set(&hc)
+	hc.Address = ts.URL
+	f, err := NewHTTPForwarder(hc, "")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	msg := &Message{Content: []byte("hi")}
+	msg.Context.ID = 1
+	msg.Context.QOS = 1
+	msg.Context.Topic = "t"
+	res, err := f.Call(context.Background(), msg)
+	assert.NoError(t, err)
+	assert.Equal(t, msg, res)
+
+	msg2 := &Message{}
+	msg2.Context.Topic = "error"
+	_, err = f.Call(context.Background(), msg2)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, "topic not found", status.Convert(err).Message())
+
+	s, err := NewServer(newServerConfig(), nil)
+	assert.NoError(t, err)
+	RegisterLinkServer(s, f)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go s.Serve(lis)
+	defer s.Stop()
+
+	cc := newClientConfig()
+	cc.Address = lis.Addr().String()
+	obs := newMockObserver(t)
+	cli, err := NewClient(cc, obs)
+	assert.NoError(t, err)
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	res, err = cli.CallContext(ctx, msg)
+	assert.NoError(t, err)
+	assert.Equal(t, msg, res)
+
+	err = cli.Send(msg)
+	assert.NoError(t, err)
+	ack := &Message{}
+	ack.Context.ID = 1
+	ack.Context.Type = Ack
+	obs.assertMsgs(msg, ack)
+}
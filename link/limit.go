@@ -0,0 +1,71 @@
+package link
+
+import (
+	"net"
+
+	"github.com/baetyl/baetyl-go/errors"
+	"github.com/baetyl/baetyl-go/limit"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// NewLimitInterceptor creates the interceptor of server enforcing the quotas of clients by the limiter,
+// the calls are rejected with resource exhausted if the quotas are exceeded, the talk streams take connections
+// of the limiter and the messages received are throttled. The client is identified by the username in metadata,
+// or the host of peer address if no username.
+func NewLimitInterceptor(l *limit.Limiter) Interceptor {
+	return Interceptor{
+		Unary: func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if err := l.Allow(clientName(ctx), sizeOf(req)); err != nil {
+				return nil, errors.ToGRPC(err)
+			}
+			return handler(ctx, req)
+		},
+		Stream: func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			name := clientName(ss.Context())
+			release, err := l.Connect(name)
+			if err != nil {
+				return errors.ToGRPC(err)
+			}
+			defer release()
+			return handler(srv, &limitedStream{ServerStream: ss, l: l, name: name})
+		},
+	}
+}
+
+type limitedStream struct {
+	grpc.ServerStream
+	l    *limit.Limiter
+	name string
+}
+
+func (s *limitedStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return errors.ToGRPC(s.l.Wait(s.Context(), s.name, sizeOf(m)))
+}
+
+func clientName(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if name := first(md, KeyUsername); name != "" {
+			return name
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+			return host
+		}
+		return p.Addr.String()
+	}
+	return ""
+}
+
+func sizeOf(m interface{}) int {
+	if s, ok := m.(interface{ Size() int }); ok {
+		return s.Size()
+	}
+	return 0
+}
@@ -0,0 +1,38 @@
+package link
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/baetyl/baetyl-go/limit"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestLimitInterceptor(t *testing.T) {
+	l := limit.NewLimiter(limit.Config{MessageRate: 1, Burst: 1, MaxPayloadSize: 10})
+	s, err := NewServer(newServerConfig(), nil, NewLimitInterceptor(l))
+	assert.NoError(t, err)
+	RegisterLinkServer(s, &mockServer{t: t})
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go s.Serve(lis)
+	defer s.Stop()
+
+	cc := newClientConfig()
+	cc.Address = lis.Addr().String()
+	conn, err := NewClientConn(cc)
+	assert.NoError(t, err)
+	defer conn.Close()
+	cli := NewLinkClient(conn)
+
+	_, err = cli.Call(context.Background(), &Message{Content: []byte("0123456789")})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	_, err = cli.Call(context.Background(), &Message{})
+	assert.NoError(t, err)
+	_, err = cli.Call(context.Background(), &Message{})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, "rate limited", status.Convert(err).Message())
+}
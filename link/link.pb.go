@@ -9,6 +9,7 @@ import (
 	fmt "fmt"
 	_ "github.com/gogo/protobuf/gogoproto"
 	proto "github.com/gogo/protobuf/proto"
+	github_com_gogo_protobuf_sortkeys "github.com/gogo/protobuf/sortkeys"
 	grpc "google.golang.org/grpc"
 	codes "google.golang.org/grpc/codes"
 	status "google.golang.org/grpc/status"
@@ -59,11 +60,15 @@ func (Type) EnumDescriptor() ([]byte, []int) {
 }
 
 type Context struct {
-	ID    uint64 `protobuf:"varint,1,opt,name=ID,proto3" json:"ID,omitempty"`
-	TS    uint64 `protobuf:"varint,2,opt,name=TS,proto3" json:"TS,omitempty"`
-	QOS   uint32 `protobuf:"varint,3,opt,name=QOS,proto3" json:"QOS,omitempty"`
-	Type  Type   `protobuf:"varint,4,opt,name=Type,proto3,enum=link.Type" json:"Type,omitempty"`
-	Topic string `protobuf:"bytes,5,opt,name=Topic,proto3" json:"Topic,omitempty"`
+	ID              uint64            `protobuf:"varint,1,opt,name=ID,proto3" json:"ID,omitempty"`
+	TS              uint64            `protobuf:"varint,2,opt,name=TS,proto3" json:"TS,omitempty"`
+	QOS             uint32            `protobuf:"varint,3,opt,name=QOS,proto3" json:"QOS,omitempty"`
+	Type            Type              `protobuf:"varint,4,opt,name=Type,proto3,enum=link.Type" json:"Type,omitempty"`
+	Topic           string            `protobuf:"bytes,5,opt,name=Topic,proto3" json:"Topic,omitempty"`
+	Headers         map[string]string `protobuf:"bytes,6,rep,name=Headers,proto3" json:"Headers,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	ContentType     string            `protobuf:"bytes,7,opt,name=ContentType,proto3" json:"ContentType,omitempty"`
+	ContentEncoding string            `protobuf:"bytes,8,opt,name=ContentEncoding,proto3" json:"ContentEncoding,omitempty"`
+	Seq             uint64            `protobuf:"varint,9,opt,name=Seq,proto3" json:"Seq,omitempty"`
 }
 
 func (m *Context) Reset()         { *m = Context{} }
@@ -140,35 +145,42 @@ var xxx_messageInfo_Message proto.InternalMessageInfo
 func init() {
 	proto.RegisterEnum("link.Type", Type_name, Type_value)
 	proto.RegisterType((*Context)(nil), "link.Context")
+	proto.RegisterMapType((map[string]string)(nil), "link.Context.HeadersEntry")
 	proto.RegisterType((*Message)(nil), "link.Message")
 }
 
 func init() { proto.RegisterFile("link.proto", fileDescriptor_2ee656911eb8a56a) }
 
 var fileDescriptor_2ee656911eb8a56a = []byte{
-	// 342 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x84, 0x91, 0xbd, 0x4e, 0xeb, 0x40,
-	0x10, 0x85, 0x77, 0x9c, 0x4d, 0xa2, 0x3b, 0xf7, 0x26, 0xb2, 0x56, 0xb7, 0xb0, 0x52, 0x0c, 0x56,
-	0x0a, 0x64, 0x45, 0xca, 0x8f, 0xc2, 0x13, 0x90, 0xa4, 0x89, 0x44, 0x84, 0xd8, 0xb8, 0xa2, 0x4b,
-	0x22, 0x63, 0x2c, 0x1b, 0x3b, 0x60, 0x47, 0x82, 0x37, 0xa0, 0xe4, 0x1d, 0x68, 0x78, 0x04, 0x4a,
-	0xca, 0x94, 0x29, 0xa9, 0x10, 0x71, 0x5e, 0x80, 0x92, 0x12, 0x79, 0x6d, 0x22, 0x51, 0xd1, 0x9d,
-	0xef, 0xec, 0xcc, 0x9e, 0x23, 0x0d, 0x62, 0xe0, 0x85, 0x7e, 0x67, 0x79, 0x13, 0x25, 0x91, 0xe0,
-	0x99, 0x6e, 0xb4, 0x5d, 0x2f, 0xb9, 0x5c, 0xcd, 0x3b, 0x8b, 0xe8, 0xaa, 0xeb, 0x46, 0x6e, 0xd4,
-	0x55, 0x8f, 0xf3, 0xd5, 0x85, 0x22, 0x05, 0x4a, 0xe5, 0x4b, 0xcd, 0x6b, 0xac, 0x0e, 0xa3, 0x30,
-	0x71, 0x6e, 0x13, 0x51, 0x47, 0x6d, 0x3c, 0x32, 0xc0, 0x04, 0x8b, 0x4b, 0x6d, 0x3c, 0xca, 0xd8,
-	0x9e, 0x1a, 0x5a, 0xce, 0xf6, 0x54, 0xe8, 0x58, 0x3a, 0x3b, 0x9d, 0x1a, 0x25, 0x13, 0xac, 0x9a,
-	0xcc, 0xa4, 0x20, 0xe4, 0xf6, 0xdd, 0xd2, 0x31, 0xb8, 0x09, 0x56, 0xbd, 0x8f, 0x1d, 0x55, 0x26,
-	0x73, 0xa4, 0xf2, 0xc5, 0x7f, 0x2c, 0xdb, 0xd1, 0xd2, 0x5b, 0x18, 0x65, 0x13, 0xac, 0x3f, 0x32,
-	0x87, 0xa6, 0xc4, 0xea, 0xc4, 0x89, 0xe3, 0x99, 0xeb, 0x88, 0xf6, 0x3e, 0x5d, 0xe5, 0xfe, 0xed,
-	0xd7, 0xf2, 0x3f, 0x0a, 0x73, 0xc0, 0xd7, 0x6f, 0x07, 0x4c, 0xee, 0x1b, 0x1a, 0xc5, 0x78, 0x98,
-	0xa8, 0x5a, 0xff, 0xe4, 0x37, 0xb6, 0x5a, 0x79, 0x13, 0x51, 0xc5, 0xd2, 0x24, 0x76, 0x75, 0x26,
-	0x10, 0x2b, 0x93, 0xd8, 0x95, 0x49, 0xa8, 0x43, 0x66, 0x1e, 0x2f, 0x7c, 0x5d, 0x6b, 0xf0, 0xfb,
-	0x47, 0x62, 0xfd, 0x73, 0xe4, 0x27, 0x5e, 0xe8, 0x8b, 0x6c, 0x67, 0x16, 0xf8, 0xa2, 0xc8, 0x2c,
-	0x3a, 0x35, 0x7e, 0x62, 0x93, 0x59, 0xd0, 0x03, 0x71, 0x88, 0x7c, 0x38, 0x0b, 0x82, 0xdf, 0x66,
-	0x07, 0xbd, 0xf5, 0x96, 0xd8, 0xc7, 0x96, 0xe0, 0x73, 0x4b, 0xf0, 0x94, 0x12, 0x3c, 0xa7, 0x04,
-	0x2f, 0x29, 0xc1, 0x3a, 0x25, 0xd8, 0xa4, 0x04, 0xef, 0x29, 0xc1, 0xc3, 0x8e, 0xd8, 0x66, 0x47,
-	0xec, 0x75, 0x47, 0x6c, 0x5e, 0x51, 0x77, 0x38, 0xfa, 0x0a, 0x00, 0x00, 0xff, 0xff, 0x09, 0x25,
-	0x1b, 0xcc, 0xca, 0x01, 0x00, 0x00,
+	// 440 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x84, 0x52, 0x3d, 0x6f, 0xd3, 0x40,
+	0x18, 0xf6, 0xeb, 0xb8, 0x31, 0x79, 0xd3, 0x96, 0xe8, 0xc4, 0x70, 0xf2, 0x70, 0x58, 0x19, 0x90,
+	0x55, 0xa9, 0x69, 0x15, 0x18, 0x50, 0x37, 0xfa, 0x21, 0x51, 0x89, 0x08, 0x71, 0xc9, 0xc4, 0xe6,
+	0xb8, 0x87, 0xb1, 0x6c, 0xce, 0x21, 0x76, 0x10, 0xf9, 0x07, 0x8c, 0xfc, 0x07, 0x16, 0x7e, 0x02,
+	0x23, 0x6c, 0x19, 0x3b, 0x32, 0x21, 0xe2, 0xfc, 0x01, 0x46, 0x46, 0x74, 0xaf, 0xdd, 0xaa, 0x65,
+	0xe9, 0xf6, 0x3c, 0xcf, 0x3d, 0xef, 0xd7, 0xa3, 0x43, 0xcc, 0x12, 0x9d, 0x0e, 0x66, 0xf3, 0xbc,
+	0xcc, 0x99, 0x63, 0xb0, 0xb7, 0x1f, 0x27, 0xe5, 0xdb, 0xc5, 0x74, 0x10, 0xe5, 0xef, 0x0e, 0xe2,
+	0x3c, 0xce, 0x0f, 0xe8, 0x71, 0xba, 0x78, 0x43, 0x8c, 0x08, 0xa1, 0xba, 0xa8, 0xff, 0xc3, 0x46,
+	0xf7, 0x24, 0xd7, 0xa5, 0xfa, 0x58, 0xb2, 0x5d, 0xb4, 0xcf, 0x4f, 0x39, 0xf8, 0x10, 0x38, 0xd2,
+	0x3e, 0x3f, 0x35, 0x7c, 0x32, 0xe6, 0x76, 0xcd, 0x27, 0x63, 0xd6, 0xc3, 0xd6, 0xab, 0x97, 0x63,
+	0xde, 0xf2, 0x21, 0xd8, 0x91, 0x06, 0x32, 0x81, 0xce, 0x64, 0x39, 0x53, 0xdc, 0xf1, 0x21, 0xd8,
+	0x1d, 0xe2, 0x80, 0xb6, 0x31, 0x8a, 0x24, 0x9d, 0x3d, 0xc0, 0xad, 0x49, 0x3e, 0x4b, 0x22, 0xbe,
+	0xe5, 0x43, 0xd0, 0x91, 0x35, 0x61, 0x4f, 0xd0, 0x7d, 0xae, 0xc2, 0x0b, 0x35, 0x2f, 0x78, 0xdb,
+	0x6f, 0x05, 0xdd, 0xa1, 0x57, 0x17, 0x36, 0x7b, 0x0c, 0x9a, 0xc7, 0x33, 0x5d, 0xce, 0x97, 0xf2,
+	0xca, 0xca, 0x7c, 0xec, 0x92, 0x41, 0x97, 0x34, 0xd2, 0xa5, 0x8e, 0x37, 0x25, 0x16, 0xe0, 0xfd,
+	0x86, 0x9e, 0xe9, 0x28, 0xbf, 0x48, 0x74, 0xcc, 0xef, 0x91, 0xeb, 0x7f, 0xd9, 0x5c, 0x32, 0x56,
+	0xef, 0x79, 0x87, 0x4e, 0x33, 0xd0, 0x3b, 0xc2, 0xed, 0x9b, 0x63, 0x8d, 0x23, 0x55, 0x4b, 0x0a,
+	0xa3, 0x23, 0x0d, 0x34, 0xb7, 0x7c, 0x08, 0xb3, 0x85, 0xa2, 0x40, 0x3a, 0xb2, 0x26, 0x47, 0xf6,
+	0x53, 0xe8, 0x4b, 0x74, 0x47, 0xaa, 0x28, 0xc2, 0x58, 0xb1, 0xfd, 0xeb, 0x34, 0xa9, 0xb4, 0x3b,
+	0xdc, 0xb9, 0x75, 0xda, 0xb1, 0xb3, 0xfa, 0xf5, 0xd0, 0x92, 0xd7, 0x89, 0xf3, 0xc6, 0xae, 0x4b,
+	0xea, 0xba, 0x2d, 0xaf, 0xe8, 0xde, 0x5e, 0x9d, 0x2c, 0x73, 0xb1, 0x35, 0x2a, 0xe2, 0x9e, 0xc5,
+	0x10, 0xdb, 0xa3, 0x22, 0x96, 0xa5, 0xee, 0x81, 0x11, 0x9f, 0x45, 0x69, 0xcf, 0xf6, 0x9c, 0x4f,
+	0x5f, 0x84, 0x35, 0x7c, 0x8d, 0xce, 0x8b, 0x44, 0xa7, 0xcc, 0xd4, 0x84, 0x59, 0xca, 0x9a, 0x99,
+	0xcd, 0x4e, 0xde, 0x6d, 0xda, 0xb7, 0x02, 0x38, 0x04, 0xf6, 0x08, 0x9d, 0x93, 0x30, 0xcb, 0xee,
+	0xf2, 0x1e, 0x1f, 0xae, 0xd6, 0xc2, 0xfa, 0xb3, 0x16, 0xf0, 0x77, 0x2d, 0xe0, 0x6b, 0x25, 0xe0,
+	0x5b, 0x25, 0xe0, 0x7b, 0x25, 0x60, 0x55, 0x09, 0xb8, 0xac, 0x04, 0xfc, 0xae, 0x04, 0x7c, 0xde,
+	0x08, 0xeb, 0x72, 0x23, 0xac, 0x9f, 0x1b, 0x61, 0x4d, 0xdb, 0xf4, 0xb1, 0x1e, 0xff, 0x1b, 0x00,
+	0x24, 0x2d, 0x21, 0xc9, 0x9b, 0x02, 0x00, 0x00,
 }
 
 func (this *Context) Equal(that interface{}) bool {
@@ -205,6 +217,23 @@ func (this *Context) Equal(that interface{}) bool {
 	if this.Topic != that1.Topic {
 		return false
 	}
+	if len(this.Headers) != len(that1.Headers) {
+		return false
+	}
+	for i := range this.Headers {
+		if this.Headers[i] != that1.Headers[i] {
+			return false
+		}
+	}
+	if this.ContentType != that1.ContentType {
+		return false
+	}
+	if this.ContentEncoding != that1.ContentEncoding {
+		return false
+	}
+	if this.Seq != that1.Seq {
+		return false
+	}
 	return true
 }
 func (this *Message) Equal(that interface{}) bool {
@@ -238,13 +267,29 @@ func (this *Context) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 9)
+	s := make([]string, 0, 13)
 	s = append(s, "&link.Context{")
 	s = append(s, "ID: "+fmt.Sprintf("%#v", this.ID)+",\n")
 	s = append(s, "TS: "+fmt.Sprintf("%#v", this.TS)+",\n")
 	s = append(s, "QOS: "+fmt.Sprintf("%#v", this.QOS)+",\n")
 	s = append(s, "Type: "+fmt.Sprintf("%#v", this.Type)+",\n")
 	s = append(s, "Topic: "+fmt.Sprintf("%#v", this.Topic)+",\n")
+	keysForHeaders := make([]string, 0, len(this.Headers))
+	for k, _ := range this.Headers {
+		keysForHeaders = append(keysForHeaders, k)
+	}
+	github_com_gogo_protobuf_sortkeys.Strings(keysForHeaders)
+	mapStringForHeaders := "map[string]string{"
+	for _, k := range keysForHeaders {
+		mapStringForHeaders += fmt.Sprintf("%#v: %#v,", k, this.Headers[k])
+	}
+	mapStringForHeaders += "}"
+	if this.Headers != nil {
+		s = append(s, "Headers: "+mapStringForHeaders+",\n")
+	}
+	s = append(s, "ContentType: "+fmt.Sprintf("%#v", this.ContentType)+",\n")
+	s = append(s, "ContentEncoding: "+fmt.Sprintf("%#v", this.ContentEncoding)+",\n")
+	s = append(s, "Seq: "+fmt.Sprintf("%#v", this.Seq)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -437,6 +482,44 @@ func (m *Context) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if m.Seq != 0 {
+		i = encodeVarintLink(dAtA, i, uint64(m.Seq))
+		i--
+		dAtA[i] = 0x48
+	}
+	if len(m.ContentEncoding) > 0 {
+		i -= len(m.ContentEncoding)
+		copy(dAtA[i:], m.ContentEncoding)
+		i = encodeVarintLink(dAtA, i, uint64(len(m.ContentEncoding)))
+		i--
+		dAtA[i] = 0x42
+	}
+	if len(m.ContentType) > 0 {
+		i -= len(m.ContentType)
+		copy(dAtA[i:], m.ContentType)
+		i = encodeVarintLink(dAtA, i, uint64(len(m.ContentType)))
+		i--
+		dAtA[i] = 0x3a
+	}
+	if len(m.Headers) > 0 {
+		for k := range m.Headers {
+			v := m.Headers[k]
+			baseI := i
+			i -= len(v)
+			copy(dAtA[i:], v)
+			i = encodeVarintLink(dAtA, i, uint64(len(v)))
+			i--
+			dAtA[i] = 0x12
+			i -= len(k)
+			copy(dAtA[i:], k)
+			i = encodeVarintLink(dAtA, i, uint64(len(k)))
+			i--
+			dAtA[i] = 0xa
+			i = encodeVarintLink(dAtA, i, uint64(baseI-i))
+			i--
+			dAtA[i] = 0x32
+		}
+	}
 	if len(m.Topic) > 0 {
 		i -= len(m.Topic)
 		copy(dAtA[i:], m.Topic)
@@ -525,6 +608,16 @@ func NewPopulatedContext(r randyLink, easy bool) *Context {
 	this.QOS = uint32(r.Uint32())
 	this.Type = Type([]int32{0, 1, 2}[r.Intn(3)])
 	this.Topic = string(randStringLink(r))
+	if r.Intn(5) != 0 {
+		v1 := r.Intn(10)
+		this.Headers = make(map[string]string)
+		for i := 0; i < v1; i++ {
+			this.Headers[randStringLink(r)] = randStringLink(r)
+		}
+	}
+	this.ContentType = string(randStringLink(r))
+	this.ContentEncoding = string(randStringLink(r))
+	this.Seq = uint64(uint64(r.Uint32()))
 	if !easy && r.Intn(10) != 0 {
 	}
 	return this
@@ -532,11 +625,11 @@ func NewPopulatedContext(r randyLink, easy bool) *Context {
 
 func NewPopulatedMessage(r randyLink, easy bool) *Message {
 	this := &Message{}
-	v1 := NewPopulatedContext(r, easy)
-	this.Context = *v1
-	v2 := r.Intn(100)
-	this.Content = make([]byte, v2)
-	for i := 0; i < v2; i++ {
+	v2 := NewPopulatedContext(r, easy)
+	this.Context = *v2
+	v3 := r.Intn(100)
+	this.Content = make([]byte, v3)
+	for i := 0; i < v3; i++ {
 		this.Content[i] = byte(r.Intn(256))
 	}
 	if !easy && r.Intn(10) != 0 {
@@ -563,9 +656,9 @@ func randUTF8RuneLink(r randyLink) rune {
 	return rune(ru + 61)
 }
 func randStringLink(r randyLink) string {
-	v3 := r.Intn(100)
-	tmps := make([]rune, v3)
-	for i := 0; i < v3; i++ {
+	v4 := r.Intn(100)
+	tmps := make([]rune, v4)
+	for i := 0; i < v4; i++ {
 		tmps[i] = randUTF8RuneLink(r)
 	}
 	return string(tmps)
@@ -587,11 +680,11 @@ func randFieldLink(dAtA []byte, r randyLink, fieldNumber int, wire int) []byte {
 	switch wire {
 	case 0:
 		dAtA = encodeVarintPopulateLink(dAtA, uint64(key))
-		v4 := r.Int63()
+		v5 := r.Int63()
 		if r.Intn(2) == 0 {
-			v4 *= -1
+			v5 *= -1
 		}
-		dAtA = encodeVarintPopulateLink(dAtA, uint64(v4))
+		dAtA = encodeVarintPopulateLink(dAtA, uint64(v5))
 	case 1:
 		dAtA = encodeVarintPopulateLink(dAtA, uint64(key))
 		dAtA = append(dAtA, byte(r.Intn(256)), byte(r.Intn(256)), byte(r.Intn(256)), byte(r.Intn(256)), byte(r.Intn(256)), byte(r.Intn(256)), byte(r.Intn(256)), byte(r.Intn(256)))
@@ -638,6 +731,25 @@ func (m *Context) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovLink(uint64(l))
 	}
+	if len(m.Headers) > 0 {
+		for k, v := range m.Headers {
+			_ = k
+			_ = v
+			mapEntrySize := 1 + len(k) + sovLink(uint64(len(k))) + 1 + len(v) + sovLink(uint64(len(v)))
+			n += mapEntrySize + 1 + sovLink(uint64(mapEntrySize))
+		}
+	}
+	l = len(m.ContentType)
+	if l > 0 {
+		n += 1 + l + sovLink(uint64(l))
+	}
+	l = len(m.ContentEncoding)
+	if l > 0 {
+		n += 1 + l + sovLink(uint64(l))
+	}
+	if m.Seq != 0 {
+		n += 1 + sovLink(uint64(m.Seq))
+	}
 	return n
 }
 
@@ -799,6 +911,216 @@ func (m *Context) Unmarshal(dAtA []byte) error {
 			}
 			m.Topic = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
+		case 6:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Headers", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLink
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthLink
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthLink
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Headers == nil {
+				m.Headers = make(map[string]string)
+			}
+			var mapkey string
+			var mapvalue string
+			for iNdEx < postIndex {
+				entryPreIndex := iNdEx
+				var wire uint64
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowLink
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					wire |= uint64(b&0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				fieldNum := int32(wire >> 3)
+				if fieldNum == 1 {
+					var stringLenmapkey uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowLink
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapkey |= uint64(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapkey := int(stringLenmapkey)
+					if intStringLenmapkey < 0 {
+						return ErrInvalidLengthLink
+					}
+					postStringIndexmapkey := iNdEx + intStringLenmapkey
+					if postStringIndexmapkey < 0 {
+						return ErrInvalidLengthLink
+					}
+					if postStringIndexmapkey > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapkey = string(dAtA[iNdEx:postStringIndexmapkey])
+					iNdEx = postStringIndexmapkey
+				} else if fieldNum == 2 {
+					var stringLenmapvalue uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowLink
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapvalue |= uint64(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapvalue := int(stringLenmapvalue)
+					if intStringLenmapvalue < 0 {
+						return ErrInvalidLengthLink
+					}
+					postStringIndexmapvalue := iNdEx + intStringLenmapvalue
+					if postStringIndexmapvalue < 0 {
+						return ErrInvalidLengthLink
+					}
+					if postStringIndexmapvalue > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapvalue = string(dAtA[iNdEx:postStringIndexmapvalue])
+					iNdEx = postStringIndexmapvalue
+				} else {
+					iNdEx = entryPreIndex
+					skippy, err := skipLink(dAtA[iNdEx:])
+					if err != nil {
+						return err
+					}
+					if skippy < 0 {
+						return ErrInvalidLengthLink
+					}
+					if (iNdEx + skippy) > postIndex {
+						return io.ErrUnexpectedEOF
+					}
+					iNdEx += skippy
+				}
+			}
+			m.Headers[mapkey] = mapvalue
+			iNdEx = postIndex
+		case 7:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ContentType", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLink
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthLink
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthLink
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ContentType = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 8:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ContentEncoding", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLink
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthLink
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthLink
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ContentEncoding = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 9:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Seq", wireType)
+			}
+			m.Seq = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLink
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Seq |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipLink(dAtA[iNdEx:])
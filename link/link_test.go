@@ -4,13 +4,24 @@ import (
 	"context"
 	"errors"
 	fmt "fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/baetyl/baetyl-go/codec"
 	"github.com/baetyl/baetyl-go/flow"
 	"github.com/baetyl/baetyl-go/log"
+	"github.com/baetyl/baetyl-go/metrics"
+	"github.com/baetyl/baetyl-go/security"
 	"github.com/baetyl/baetyl-go/utils"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestLinkClientConnectErrorMissingAddress(t *testing.T) {
@@ -29,7 +40,7 @@ func TestLinkClientConnectErrorMissingAddress(t *testing.T) {
 	defer cel()
 	req := &Message{}
 	res, err := c.CallContext(ctx, req)
-	assert.EqualError(t, err, "rpc error: code = DeadlineExceeded desc = latest connection error: connection error: desc = \"transport: Error while dialing dial tcp: missing address\"")
+	assert.EqualError(t, err, "rpc error: code = DeadlineExceeded desc = latest balancer error: connection error: desc = \"transport: Error while dialing dial tcp: missing address\"")
 	assert.Nil(t, res)
 }
 
@@ -45,7 +56,7 @@ func TestLinkClientConnectErrorWrongPort(t *testing.T) {
 	defer cel()
 	req := &Message{}
 	res, err := c.CallContext(ctx, req)
-	assert.EqualError(t, err, "rpc error: code = DeadlineExceeded desc = latest connection error: connection error: desc = \"transport: Error while dialing dial tcp: address 123456789: invalid port\"")
+	assert.EqualError(t, err, "rpc error: code = DeadlineExceeded desc = latest balancer error: connection error: desc = \"transport: Error while dialing dial tcp: address 123456789: invalid port\"")
 	assert.Nil(t, res)
 }
 
@@ -77,6 +88,12 @@ func TestLinkClientSendRecvMessage(t *testing.T) {
 
 	done := initMockServer(t, server, nil)
 
+	sent := metrics.ClientMessagesTotal.Value(metrics.ProtocolLink, metrics.StateSent)
+	acked := metrics.ClientMessagesTotal.Value(metrics.ProtocolLink, metrics.StateAcked)
+	conns := metrics.ClientConnections.Value(metrics.ProtocolLink)
+	cached := metrics.ClientCacheMessages.Value(metrics.ProtocolLink)
+	calls := metrics.ClientCallDuration.Count(metrics.ProtocolLink)
+
 	cc := newClientConfig()
 	obs := newMockObserver(t)
 	c, err := NewClient(cc, obs)
@@ -106,6 +123,53 @@ func TestLinkClientSendRecvMessage(t *testing.T) {
 
 	assert.NoError(t, c.Close())
 	safeReceive(done)
+
+	// the metrics populated by the client
+	assert.Equal(t, sent+3, metrics.ClientMessagesTotal.Value(metrics.ProtocolLink, metrics.StateSent))
+	assert.Equal(t, acked+1, metrics.ClientMessagesTotal.Value(metrics.ProtocolLink, metrics.StateAcked))
+	assert.Equal(t, conns, metrics.ClientConnections.Value(metrics.ProtocolLink))
+	assert.Equal(t, cached, metrics.ClientCacheMessages.Value(metrics.ProtocolLink))
+	assert.Equal(t, calls+2, metrics.ClientCallDuration.Count(metrics.ProtocolLink))
+}
+
+func TestLinkClientCompress(t *testing.T) {
+	msg := &Message{Content: []byte(strings.Repeat("baetyl", 100))}
+	msg.Context.Topic = "t"
+	small := &Message{Content: []byte("baetyl")}
+	zipped := *msg
+	assert.NoError(t, zipped.Compress(100))
+	assert.Equal(t, EncodingGzip, zipped.Context.ContentEncoding)
+	assert.True(t, len(zipped.Content) < len(msg.Content))
+
+	server := flow.New().Debug().
+		Receive(&zipped).
+		Receive(small).
+		Send(&zipped).
+		End().
+		Close()
+
+	done := initMockServer(t, server, nil)
+
+	cc := newClientConfig()
+	cc.CompressThreshold = 100
+	obs := newMockObserver(t)
+	c, err := NewClient(cc, obs)
+	assert.NoError(t, err)
+
+	// the response echoed is decompressed
+	res, err := c.Call(msg)
+	assert.NoError(t, err)
+	assert.Equal(t, msg.Content, res.Content)
+	assert.Equal(t, "", res.Context.ContentEncoding)
+
+	assert.NoError(t, c.Send(msg))
+	assert.NoError(t, c.Send(small))
+	obs.assertMsgs(msg)
+	// the message of caller is not modified
+	assert.Equal(t, "", msg.Context.ContentEncoding)
+
+	assert.NoError(t, c.Close())
+	safeReceive(done)
 }
 
 func TestLinkClientSendRecvMessageDisableAutoAck(t *testing.T) {
@@ -287,3 +351,292 @@ func TestLinkClientReconnect(t *testing.T) {
 	assert.NoError(t, c.Close())
 	safeReceive(done)
 }
+
+func TestLinkServerInterceptor(t *testing.T) {
+	var calls []string
+	record := func(name string) Interceptor {
+		return Interceptor{
+			Unary: func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+				calls = append(calls, name)
+				return handler(ctx, req)
+			},
+		}
+	}
+	ui := chainUnary([]grpc.UnaryServerInterceptor{record("a").Unary, record("b").Unary})
+	res, err := ui(context.Background(), "req", &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		calls = append(calls, "handler")
+		return req, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "req", res)
+	assert.Equal(t, []string{"a", "b", "handler"}, calls)
+
+	calls = nil
+	si := chainStream([]grpc.StreamServerInterceptor{
+		func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			calls = append(calls, "a")
+			return handler(srv, ss)
+		},
+	})
+	err = si(nil, nil, &grpc.StreamServerInfo{}, func(srv interface{}, ss grpc.ServerStream) error {
+		calls = append(calls, "handler")
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "handler"}, calls)
+
+	// the interceptors are called before authentication
+	calls = nil
+	s, err := NewServer(newServerConfig(), mockAuth{"u1": "p1"}, record("server"))
+	assert.NoError(t, err)
+	RegisterLinkServer(s, &mockServer{t: t})
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go s.Serve(lis)
+	defer s.Stop()
+
+	cc := newClientConfig()
+	cc.Address = lis.Addr().String()
+	cc.Password = "p2"
+	conn, err := NewClientConn(cc)
+	assert.NoError(t, err)
+	defer conn.Close()
+	_, err = NewLinkClient(conn).Call(context.Background(), &Message{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	assert.Equal(t, []string{"server"}, calls)
+}
+
+func TestMessageV2(t *testing.T) {
+	msg := &Message{}
+	msg.Context.ID = 1
+	msg.Context.Topic = "t"
+	assert.NoError(t, msg.EncodeContent(codec.CBOR, map[string]int{"a": 1}))
+	assert.Equal(t, codec.ContentTypeCBOR, msg.Context.ContentType)
+	msg.SetHeader("k", "v")
+	msg.Context.Seq = 9
+	assert.Equal(t, "v", msg.Header("k"))
+	assert.Equal(t, "", msg.Header("x"))
+
+	data, err := msg.Marshal()
+	assert.NoError(t, err)
+	var msg2 Message
+	assert.NoError(t, msg2.Unmarshal(data))
+	assert.Equal(t, msg, &msg2)
+	var v map[string]int
+	assert.NoError(t, msg2.Decode(&v))
+	assert.Equal(t, map[string]int{"a": 1}, v)
+
+	// the message of v1 without the new fields is decoded as json
+	v1 := &Message{Content: []byte(`{"a":2}`)}
+	v1.Context.Topic = "t"
+	data, err = v1.Marshal()
+	assert.NoError(t, err)
+	var msg3 Message
+	assert.NoError(t, msg3.Unmarshal(data))
+	assert.Nil(t, msg3.Context.Headers)
+	assert.NoError(t, msg3.Decode(&v))
+	assert.Equal(t, map[string]int{"a": 2}, v)
+
+	msg3.Context.ContentType = "text/unknown"
+	assert.EqualError(t, msg3.Decode(&v), "codec of content type (text/unknown) not found")
+	msg3.Context.ContentEncoding = "br"
+	assert.EqualError(t, msg3.Decode(&v), "content encoding (br) not supported")
+	assert.EqualError(t, msg3.Decompress(0), "content encoding (br) not supported")
+}
+
+func TestMessageCompress(t *testing.T) {
+	content := []byte(`{"a":"` + strings.Repeat("baetyl", 100) + `"}`)
+	msg := &Message{Content: content}
+	assert.NoError(t, msg.Compress(0))
+	assert.Equal(t, "", msg.Context.ContentEncoding)
+	assert.NoError(t, msg.Compress(len(content)+1))
+	assert.Equal(t, "", msg.Context.ContentEncoding)
+
+	assert.NoError(t, msg.Compress(len(content)))
+	assert.Equal(t, EncodingGzip, msg.Context.ContentEncoding)
+	assert.True(t, len(msg.Content) < len(content))
+	// compressed only once
+	zipped := msg.Content
+	assert.NoError(t, msg.Compress(1))
+	assert.Equal(t, zipped, msg.Content)
+
+	// the content compressed is decoded
+	var v map[string]string
+	assert.NoError(t, msg.Decode(&v))
+	assert.Equal(t, strings.Repeat("baetyl", 100), v["a"])
+	assert.Equal(t, zipped, msg.Content)
+
+	msg2 := *msg
+	assert.EqualError(t, msg2.Decompress(10), utils.ErrDecompressedTooLarge.Error())
+	assert.NoError(t, msg.Decompress(0))
+	assert.Equal(t, content, msg.Content)
+	assert.Equal(t, "", msg.Context.ContentEncoding)
+	assert.NoError(t, msg.Decompress(0))
+
+	// the content not reduced is kept as is
+	msg = &Message{Content: []byte("abc")}
+	assert.NoError(t, msg.Compress(1))
+	assert.Equal(t, "", msg.Context.ContentEncoding)
+	assert.Equal(t, []byte("abc"), msg.Content)
+
+	msg = &Message{Content: []byte(`{"a":1}`)}
+	msg.Context.ContentEncoding = EncodingGzip
+	assert.Error(t, msg.Decode(&v))
+}
+
+type rotationServer struct {
+	talks chan Link_TalkServer
+}
+
+func (s *rotationServer) Call(_ context.Context, msg *Message) (*Message, error) {
+	return msg, nil
+}
+
+func (s *rotationServer) Talk(stream Link_TalkServer) error {
+	s.talks <- stream
+	for {
+		if _, err := stream.Recv(); err != nil {
+			return err
+		}
+	}
+}
+
+func TestLinkClientReconnectOnCertRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", t.Name())
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	cert, err := ioutil.ReadFile("../example/var/lib/baetyl/testcert/client.pem")
+	assert.NoError(t, err)
+	key, err := ioutil.ReadFile("../example/var/lib/baetyl/testcert/client.key")
+	assert.NoError(t, err)
+	certFile, keyFile := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+	assert.NoError(t, ioutil.WriteFile(certFile, cert, 0644))
+	assert.NoError(t, ioutil.WriteFile(keyFile, key, 0644))
+
+	sc := newServerConfig()
+	sc.Certificate = utils.Certificate{
+		CA:   "../example/var/lib/baetyl/testcert/ca.pem",
+		Key:  "../example/var/lib/baetyl/testcert/server.key",
+		Cert: "../example/var/lib/baetyl/testcert/server.pem",
+	}
+	s, err := NewServer(sc, nil)
+	assert.NoError(t, err)
+	rs := &rotationServer{talks: make(chan Link_TalkServer, 10)}
+	RegisterLinkServer(s, rs)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go s.Serve(lis)
+	defer s.Stop()
+
+	cc := newClientConfig()
+	cc.Address = lis.Addr().String()
+	cc.Certificate = utils.Certificate{
+		CA:                 "../example/var/lib/baetyl/testcert/ca.pem",
+		Cert:               certFile,
+		Key:                keyFile,
+		InsecureSkipVerify: true,
+		WatchInterval:      10 * time.Millisecond,
+	}
+	c, err := NewClient(cc, newMockObserver(t))
+	assert.NoError(t, err)
+	defer c.Close()
+
+	msg := &Message{Content: []byte("rotation")}
+	res, err := c.Call(msg)
+	assert.NoError(t, err)
+	assert.Equal(t, msg.Content, res.Content)
+	assert.NoError(t, c.Send(msg))
+	select {
+	case <-rs.talks:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out to wait stream")
+	}
+
+	// the certificate renewed makes the client redial and the stream reconnect
+	assert.NoError(t, ioutil.WriteFile(certFile, append(cert, '\n'), 0644))
+	select {
+	case <-rs.talks:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out to wait stream reconnected")
+	}
+	res, err = c.Call(msg)
+	assert.NoError(t, err)
+	assert.Equal(t, msg.Content, res.Content)
+}
+
+type identityServer struct {
+	rotationServer
+}
+
+func (s *identityServer) Call(ctx context.Context, msg *Message) (*Message, error) {
+	id, ok := PeerIdentity(ctx)
+	if !ok {
+		return nil, ErrUnauthenticated
+	}
+	return &Message{Content: []byte(id.CN + "/" + strings.Join(id.OU, ","))}, nil
+}
+
+func TestLinkClientIdentifiedByCert(t *testing.T) {
+	dir, err := ioutil.TempDir("", t.Name())
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	ca := utils.Certificate{Key: filepath.Join(dir, "ca.key"), Cert: filepath.Join(dir, "ca.pem")}
+	assert.NoError(t, security.GenerateCAFiles(security.CertOptions{CommonName: "ca"}, security.KeyTypeEC, ca))
+	server := utils.Certificate{CA: ca.Cert, Key: filepath.Join(dir, "server.key"), Cert: filepath.Join(dir, "server.pem")}
+	opts := security.CertOptions{CommonName: "server", IPAddresses: []net.IP{net.ParseIP("127.0.0.1")}}
+	assert.NoError(t, security.GenerateCertFiles(opts, security.KeyTypeEC, ca, server))
+	client := utils.Certificate{CA: ca.Cert, Key: filepath.Join(dir, "client.key"), Cert: filepath.Join(dir, "client.pem")}
+	opts = security.CertOptions{CommonName: "node1", OrganizationalUnit: []string{"device"}}
+	assert.NoError(t, security.GenerateCertFiles(opts, security.KeyTypeEC, ca, client))
+
+	sc := newServerConfig()
+	sc.Certificate = server
+	sc.Certificate.ClientAuth = utils.ClientAuthRequireAndVerify
+	sc.Certificate.PeerOU = []string{"device"}
+	s, err := NewServer(sc, nil)
+	assert.NoError(t, err)
+	RegisterLinkServer(s, &identityServer{})
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go s.Serve(lis)
+	defer s.Stop()
+
+	// the device is identified by the certificate without username and password
+	cc := newClientConfig()
+	cc.Address = lis.Addr().String()
+	cc.Username, cc.Password = "", ""
+	cc.Certificate = client
+	cc.Certificate.Name = "127.0.0.1"
+	cc.Certificate.PeerCN = []string{"server"}
+	conn, err := NewClientConn(cc)
+	assert.NoError(t, err)
+	defer conn.Close()
+	res, err := NewLinkClient(conn).Call(context.Background(), &Message{})
+	assert.NoError(t, err)
+	assert.Equal(t, "node1/device", string(res.Content))
+
+	// the client of the organizational unit not allowed is rejected
+	other := utils.Certificate{CA: ca.Cert, Key: filepath.Join(dir, "other.key"), Cert: filepath.Join(dir, "other.pem"), Name: "127.0.0.1"}
+	opts = security.CertOptions{CommonName: "node2", OrganizationalUnit: []string{"gateway"}}
+	assert.NoError(t, security.GenerateCertFiles(opts, security.KeyTypeEC, ca, other))
+	cc.Certificate = other
+	conn2, err := NewClientConn(cc)
+	assert.NoError(t, err)
+	defer conn2.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err = NewLinkClient(conn2).Call(ctx, &Message{})
+	assert.Error(t, err)
+}
+
+func TestConfigAddress(t *testing.T) {
+	var sc ServerConfig
+	assert.NoError(t, utils.UnmarshalYAML([]byte("address: 0.0.0.0:8273"), &sc))
+	assert.NoError(t, utils.UnmarshalYAML([]byte("address: tcp://0.0.0.0:8273"), &sc))
+	assert.EqualError(t, utils.UnmarshalYAML([]byte("address: 0.0.0.0"), &sc), "address: regular expression mismatch")
+	var cc ClientConfig
+	assert.NoError(t, utils.UnmarshalYAML([]byte("address: 127.0.0.1:8273"), &cc))
+	assert.NoError(t, utils.UnmarshalYAML([]byte("address: ssl://baetyl-broker:8886"), &cc))
+	assert.NoError(t, utils.UnmarshalYAML([]byte("address: unix:///var/run/link.sock"), &cc))
+	assert.EqualError(t, utils.UnmarshalYAML([]byte("address: http://127.0.0.1"), &cc), "address: regular expression mismatch")
+}
@@ -1,6 +1,156 @@
 package link
 
+import (
+	"fmt"
+
+	"github.com/baetyl/baetyl-go/codec"
+	"github.com/baetyl/baetyl-go/utils"
+)
+
+// EncodingGzip the content encoding of gzip
+const EncodingGzip = "gzip"
+
+// KeyPartition the header key of partition key, the messages of the same key are sent in order by the same stream
+const KeyPartition = "partitionKey"
+
+// KeyCorrelationID the header key of correlation id, which relates the reply to the request sent over the stream
+const KeyCorrelationID = "correlationId"
+
 // Retain checks whether the message is need to retain
 func (m *Message) Retain() bool {
 	return m.Context.Type == MsgRtn
 }
+
+// Header returns the value of header, returns empty if not found
+func (m *Message) Header(key string) string {
+	return m.Context.Headers[key]
+}
+
+// SetHeader sets the value of header
+func (m *Message) SetHeader(key, value string) {
+	if m.Context.Headers == nil {
+		m.Context.Headers = map[string]string{}
+	}
+	m.Context.Headers[key] = value
+}
+
+// CorrelationID returns the correlation id, returns empty if not set
+func (m *Message) CorrelationID() string {
+	return m.Header(KeyCorrelationID)
+}
+
+// SetCorrelationID sets the correlation id
+func (m *Message) SetCorrelationID(id string) {
+	m.SetHeader(KeyCorrelationID, id)
+}
+
+// PartitionKey returns the partition key, returns empty if not set
+func (m *Message) PartitionKey() string {
+	return m.Header(KeyPartition)
+}
+
+// SetPartitionKey sets the partition key
+func (m *Message) SetPartitionKey(key string) {
+	m.SetHeader(KeyPartition, key)
+}
+
+// NewReply creates a reply of the message with the same topic and correlation id, the content is set by the caller
+func (m *Message) NewReply() *Message {
+	res := &Message{}
+	res.Context.Type = Msg
+	res.Context.Topic = m.Context.Topic
+	if id := m.CorrelationID(); id != "" {
+		res.SetCorrelationID(id)
+	}
+	return res
+}
+
+// newAck creates the ack of the message, the count of batch is kept, so that the observer knows how many messages are acknowledged
+func newAck(msg *Message) *Message {
+	ack := &Message{}
+	ack.Context.ID = msg.Context.ID
+	ack.Context.Type = Ack
+	if v := msg.Header(KeyBatchCount); v != "" {
+		ack.SetHeader(KeyBatchCount, v)
+	}
+	return ack
+}
+
+// EncodeContent encodes the value by the codec as the content, the content type is set as well
+func (m *Message) EncodeContent(c codec.Codec, v interface{}) error {
+	data, err := c.Marshal(v)
+	if err != nil {
+		return err
+	}
+	m.Content = data
+	m.Context.ContentType = c.ContentType()
+	return nil
+}
+
+// DecodeContent decodes the content into the value by the codec
+func (m *Message) DecodeContent(c codec.Codec, v interface{}) error {
+	return c.Unmarshal(m.Content, v)
+}
+
+// Compress compresses the content by gzip if its size reaches the threshold (if positive) and it is not encoded,
+// the content encoding is set. The content is kept as is if the compression doesn't reduce its size.
+func (m *Message) Compress(threshold int) error {
+	if threshold <= 0 || len(m.Content) < threshold || m.Context.ContentEncoding != "" {
+		return nil
+	}
+	data, err := utils.Gzip(m.Content)
+	if err != nil {
+		return err
+	}
+	if len(data) >= len(m.Content) {
+		return nil
+	}
+	m.Content = data
+	m.Context.ContentEncoding = EncodingGzip
+	return nil
+}
+
+// Decompress decompresses the content by its encoding and clears the encoding,
+// the size of content decompressed is limited if the limit is positive
+func (m *Message) Decompress(limit int64) error {
+	switch m.Context.ContentEncoding {
+	case "":
+		return nil
+	case EncodingGzip:
+		data, err := utils.Gunzip(m.Content, limit)
+		if err != nil {
+			return err
+		}
+		m.Content = data
+		m.Context.ContentEncoding = ""
+		return nil
+	default:
+		return fmt.Errorf("content encoding (%s) not supported", m.Context.ContentEncoding)
+	}
+}
+
+// Decode decodes the content into the value by the codec of its content type, the content compressed is decompressed first,
+// the content of messages sent by the peers of v1 (without content type) is decoded as json
+func (m *Message) Decode(v interface{}) error {
+	content := m.Content
+	switch m.Context.ContentEncoding {
+	case "":
+	case EncodingGzip:
+		var err error
+		content, err = utils.Gunzip(content, 0)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("content encoding (%s) not supported", m.Context.ContentEncoding)
+	}
+	c := codec.JSON
+	if m.Context.ContentType != "" {
+		var err error
+		c, err = codec.Get(m.Context.ContentType)
+		if err != nil {
+			return err
+		}
+	}
+	return c.Unmarshal(content, v)
+}
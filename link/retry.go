@@ -0,0 +1,38 @@
+package link
+
+import (
+	"context"
+	"time"
+
+	"github.com/baetyl/baetyl-go/log"
+	"github.com/jpillora/backoff"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newRetryInterceptor creates the interceptor of client retrying the calls failed as unavailable with backoff,
+// such as the connection is broken during the call, until the max retries is reached or the context is done
+func newRetryInterceptor(maxRetries int, interval time.Duration) grpc.UnaryClientInterceptor {
+	logger := log.With(log.Any("link", "retry"))
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		bf := backoff.Backoff{
+			Min:    100 * time.Millisecond,
+			Max:    interval,
+			Factor: 1.6,
+		}
+		for {
+			err := invoker(ctx, method, req, reply, cc, opts...)
+			if err == nil || status.Code(err) != codes.Unavailable || int(bf.Attempt()) >= maxRetries {
+				return err
+			}
+			d := bf.Duration()
+			logger.Warn("failed to call, retry later", log.Any("method", method), log.Any("attempt", bf.Attempt()), log.Any("after", d), log.Error(err))
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(d):
+			}
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package link
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRetryInterceptor(t *testing.T) {
+	ri := newRetryInterceptor(2, 10*time.Millisecond)
+	var calls int
+	invoker := func(errs ...error) grpc.UnaryInvoker {
+		calls = 0
+		return func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+			calls++
+			if calls > len(errs) {
+				return nil
+			}
+			return errs[calls-1]
+		}
+	}
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+
+	// succeeded after retried
+	assert.NoError(t, ri(context.Background(), "m", nil, nil, nil, invoker(unavailable, unavailable)))
+	assert.Equal(t, 3, calls)
+	// the max retries is reached
+	assert.Equal(t, unavailable, ri(context.Background(), "m", nil, nil, nil, invoker(unavailable, unavailable, unavailable)))
+	assert.Equal(t, 3, calls)
+	// the other errors are not retried
+	invalid := status.Error(codes.InvalidArgument, "invalid")
+	assert.Equal(t, invalid, ri(context.Background(), "m", nil, nil, nil, invoker(invalid)))
+	assert.Equal(t, 1, calls)
+	// not retried once the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, unavailable, ri(ctx, "m", nil, nil, nil, invoker(unavailable)))
+	assert.Equal(t, 1, calls)
+}
+
+func TestClientConnOptions(t *testing.T) {
+	cfg := newServerConfig()
+	cfg.Address = "127.0.0.1:0"
+	cfg.KeepaliveMinTime = 10 * time.Second
+	obs := &mockServiceObserver{msgs: make(chan *Message, 10), acks: make(chan *Message, 10)}
+	svc, err := NewService(cfg, obs, nil)
+	assert.NoError(t, err)
+	defer svc.Close()
+
+	cc := newClientConfig()
+	cc.Address = svc.Addr().String()
+	cc.Compressor = "gzip"
+	cc.KeepaliveTime = 10 * time.Second
+	cc.KeepaliveTimeout = time.Second
+	cc.MaxRetries = 3
+	cli, err := NewClient(cc, nil)
+	assert.NoError(t, err)
+	defer cli.Close()
+
+	res, err := cli.Call(&Message{Content: []byte("hi")})
+	assert.NoError(t, err)
+	assert.Equal(t, "hi", string(res.Content))
+}
@@ -9,6 +9,7 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/reflection"
 	"google.golang.org/grpc/status"
 )
@@ -27,8 +28,30 @@ type Authenticator interface {
 	Authenticate(context.Context) error
 }
 
+// Interceptor the interceptors of grpc server, such as tracing and metrics, which are chained before authentication
+type Interceptor struct {
+	Unary  grpc.UnaryServerInterceptor
+	Stream grpc.StreamServerInterceptor
+	// used by Service only, which intercepts the handling of each message received over the streams
+	Receive ReceiveInterceptor
+}
+
+// ReceiveInterceptor intercepts the handling of the message received, such as to start the span of the trace context
+// extracted from the headers, the handle must be called to pass the message on
+type ReceiveInterceptor func(msg *Message, handle func() error) error
+
+// intercept calls the handle through the receive interceptors in order
+func intercept(is []ReceiveInterceptor, msg *Message, handle func() error) error {
+	if len(is) == 0 {
+		return handle()
+	}
+	return is[0](msg, func() error {
+		return intercept(is[1:], msg, handle)
+	})
+}
+
 // NewServer creates a new grpc server
-func NewServer(cfg ServerConfig, auth Authenticator) (*grpc.Server, error) {
+func NewServer(cfg ServerConfig, auth Authenticator, interceptors ...Interceptor) (*grpc.Server, error) {
 	logger := log.With(log.Any("link", "server"))
 
 	opts := []grpc.ServerOption{
@@ -36,6 +59,9 @@ func NewServer(cfg ServerConfig, auth Authenticator) (*grpc.Server, error) {
 		grpc.MaxRecvMsgSize(int(cfg.MaxMessageSize)),
 		grpc.MaxSendMsgSize(int(cfg.MaxMessageSize)),
 	}
+	if cfg.KeepaliveMinTime > 0 {
+		opts = append(opts, grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{MinTime: cfg.KeepaliveMinTime}))
+	}
 	if cfg.Certificate.Key != "" || cfg.Certificate.Cert != "" {
 		tlsCfg, err := utils.NewTLSConfigServer(cfg.Certificate)
 		if err != nil {
@@ -44,6 +70,16 @@ func NewServer(cfg ServerConfig, auth Authenticator) (*grpc.Server, error) {
 		creds := credentials.NewTLS(tlsCfg)
 		opts = append(opts, grpc.Creds(creds))
 	}
+	var uis []grpc.UnaryServerInterceptor
+	var sis []grpc.StreamServerInterceptor
+	for _, i := range interceptors {
+		if i.Unary != nil {
+			uis = append(uis, i.Unary)
+		}
+		if i.Stream != nil {
+			sis = append(sis, i.Stream)
+		}
+	}
 	if auth != nil {
 		ui := func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 			if ent := logger.Check(log.DebugLevel, "server received a message"); ent != nil {
@@ -65,10 +101,45 @@ func NewServer(cfg ServerConfig, auth Authenticator) (*grpc.Server, error) {
 			}
 			return handler(srv, ss)
 		}
-		opts = append(opts, grpc.UnaryInterceptor(ui), grpc.StreamInterceptor(si))
+		uis = append(uis, ui)
+		sis = append(sis, si)
+	}
+	if len(uis) > 0 {
+		opts = append(opts, grpc.UnaryInterceptor(chainUnary(uis)))
+	}
+	if len(sis) > 0 {
+		opts = append(opts, grpc.StreamInterceptor(chainStream(sis)))
 	}
 
 	svr := grpc.NewServer(opts...)
 	reflection.Register(svr)
 	return svr, nil
 }
+
+// chainUnary chains the interceptors, the first one is the outermost
+func chainUnary(is []grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		next := handler
+		for i := len(is) - 1; i >= 0; i-- {
+			interceptor, h := is[i], next
+			next = func(ctx context.Context, req interface{}) (interface{}, error) {
+				return interceptor(ctx, req, info, h)
+			}
+		}
+		return next(ctx, req)
+	}
+}
+
+// chainStream chains the interceptors, the first one is the outermost
+func chainStream(is []grpc.StreamServerInterceptor) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		next := handler
+		for i := len(is) - 1; i >= 0; i-- {
+			interceptor, h := is[i], next
+			next = func(srv interface{}, ss grpc.ServerStream) error {
+				return interceptor(srv, ss, info, h)
+			}
+		}
+		return next(srv, ss)
+	}
+}
@@ -0,0 +1,301 @@
+package link
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/baetyl/baetyl-go/errors"
+	"github.com/baetyl/baetyl-go/log"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// ServiceObserver the observer of the messages of link clients served by the service
+type ServiceObserver interface {
+	// OnCall serves the request called by the client
+	OnCall(ctx context.Context, msg *Message) (*Message, error)
+	// OnMsg handles the message sent over the stream, the message of qos 1 is acknowledged if no error returned
+	OnMsg(s *ServiceStream, msg *Message) error
+	// OnAck handles the ack of the message of qos 1 sent to the stream
+	OnAck(s *ServiceStream, msg *Message) error
+}
+
+// ServiceStream the stream of a link client talking to the service
+type ServiceStream struct {
+	svc    *Service
+	stream Link_TalkServer
+	parts  *assembler
+	closed bool // the stream can't be sent once the handler returned
+	mu     sync.Mutex
+}
+
+// ErrServiceStreamClosed the error returned if the message is sent to the stream closed
+var ErrServiceStreamClosed = errors.Coded(errors.CodeUnavailable, "stream is closed")
+
+// Context returns the context of stream, such as to get the credentials of client by NewCredentials
+func (s *ServiceStream) Context() context.Context {
+	return s.stream.Context()
+}
+
+// Send sends the message to the client, it is safe to be called concurrently
+func (s *ServiceStream) Send(msg *Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrServiceStreamClosed
+	}
+	return s.stream.Send(msg)
+}
+
+// SendChunked sends the message of large content in chunks to the client, the progress is reported to the observer
+// of service if it implements ProgressObserver
+func (s *ServiceStream) SendChunked(ctx context.Context, msg *Message) error {
+	return sendChunks(ctx, s.send, msg, bytes.NewReader(msg.Content), int64(len(msg.Content)), int(s.svc.cfg.ChunkSize), s.svc.onProgress)
+}
+
+// SendFile sends the content of file as the content of message in chunks to the client
+func (s *ServiceStream) SendFile(ctx context.Context, msg *Message, path string) error {
+	return sendFile(ctx, s.send, msg, path, int(s.svc.cfg.ChunkSize), s.svc.onProgress)
+}
+
+func (s *ServiceStream) send(ctx context.Context, msg *Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Send(msg)
+}
+
+// Service the link server listening on the address of config, which serves the requests of link clients by the observer,
+// tls is enabled if the certificate is configured, and the clients are authenticated if the authenticator is not nil,
+// such as the one created by NewAuthenticator to check the username and password in metadata
+type Service struct {
+	cfg     ServerConfig
+	obs     ServiceObserver
+	recvs   []ReceiveInterceptor
+	svr     *grpc.Server
+	lis     net.Listener
+	streams map[*ServiceStream]struct{}
+	closing chan struct{}
+	once    sync.Once
+	mu      sync.Mutex
+	log     *log.Logger
+}
+
+// NewService creates and starts a new link service
+func NewService(cfg ServerConfig, obs ServiceObserver, auth Authenticator, interceptors ...Interceptor) (*Service, error) {
+	svr, err := NewServer(cfg, auth, interceptors...)
+	if err != nil {
+		return nil, err
+	}
+	lis, err := net.Listen("tcp", cfg.Address)
+	if err != nil {
+		svr.Stop()
+		return nil, err
+	}
+	var recvs []ReceiveInterceptor
+	for _, i := range interceptors {
+		if i.Receive != nil {
+			recvs = append(recvs, i.Receive)
+		}
+	}
+	s := &Service{
+		cfg:     cfg,
+		obs:     obs,
+		recvs:   recvs,
+		svr:     svr,
+		lis:     lis,
+		streams: map[*ServiceStream]struct{}{},
+		closing: make(chan struct{}),
+		log:     log.With(log.Any("link", "service"), log.Any("address", lis.Addr().String())),
+	}
+	RegisterLinkServer(svr, s)
+	go func() {
+		if err := svr.Serve(lis); err != nil {
+			s.log.Error("service has stopped serving", log.Error(err))
+		}
+	}()
+	s.log.Info("service starts to serve")
+	return s, nil
+}
+
+// Addr returns the address listened
+func (s *Service) Addr() net.Addr {
+	return s.lis.Addr()
+}
+
+// Call serves the request by the observer, the request compressed by the client is decompressed first
+func (s *Service) Call(ctx context.Context, msg *Message) (*Message, error) {
+	if err := msg.Decompress(0); err != nil {
+		return nil, errors.ToGRPC(errors.Coded(errors.CodeInvalidArgument, "failed to decompress request: %s", err.Error()))
+	}
+	res, err := s.obs.OnCall(ctx, msg)
+	if err != nil {
+		return nil, errors.ToGRPC(err)
+	}
+	return res, nil
+}
+
+// Talk handles the messages received from the stream by the observer until the stream or the service is closed
+func (s *Service) Talk(stream Link_TalkServer) error {
+	ss := &ServiceStream{svc: s, stream: stream, parts: newAssembler(int64(s.cfg.MaxChunkedSize))}
+	s.mu.Lock()
+	s.streams[ss] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.streams, ss)
+		s.mu.Unlock()
+		ss.mu.Lock()
+		ss.closed = true
+		ss.mu.Unlock()
+	}()
+
+	// the messages are received by another goroutine, which exits once the stream is cancelled after returned,
+	// so that the messages are handled and acknowledged by this goroutine only while the stream is open
+	msgs := make(chan *Message)
+	go func() {
+		defer close(msgs)
+		for {
+			msg, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			select {
+			case msgs <- msg:
+			case <-stream.Context().Done():
+				return
+			}
+		}
+	}()
+	return s.receiving(ss, msgs)
+}
+
+// receiving handles the messages received until the stream or the service is closed
+func (s *Service) receiving(ss *ServiceStream, msgs <-chan *Message) error {
+	for {
+		var msg *Message
+		select {
+		case m, ok := <-msgs:
+			if !ok {
+				return nil
+			}
+			msg = m
+		case <-s.closing:
+			return nil
+		}
+		var err error
+		switch msg.Context.Type {
+		case Msg, MsgRtn:
+			if err = msg.Decompress(0); err != nil {
+				s.log.Warn("failed to decompress message", log.Any("topic", msg.Context.Topic), log.Error(err))
+				continue
+			}
+			whole, err := s.assemble(ss, msg)
+			if err != nil {
+				s.log.Warn("failed to reassemble chunked message", log.Any("topic", msg.Context.Topic), log.Error(err))
+				continue
+			}
+			if whole == nil {
+				continue
+			}
+			msg = whole
+			if err = s.handle(ss, msg); err != nil {
+				s.log.Warn("failed to handle message", log.Any("topic", msg.Context.Topic), log.Error(err))
+				continue
+			}
+			if msg.Context.QOS == 1 {
+				if err = ss.Send(newAck(msg)); err != nil {
+					return err
+				}
+			}
+		case Ack:
+			if err = s.obs.OnAck(ss, msg); err != nil {
+				s.log.Warn("failed to handle ack", log.Any("id", msg.Context.ID), log.Error(err))
+			}
+		default:
+			return ErrClientMessageTypeInvalid
+		}
+	}
+}
+
+// handle handles the message by the observer through the receive interceptors, the messages of batch are handled one by one
+func (s *Service) handle(ss *ServiceStream, msg *Message) error {
+	if !msg.IsBatch() {
+		return intercept(s.recvs, msg, func() error {
+			return s.obs.OnMsg(ss, msg)
+		})
+	}
+	msgs, err := msg.Unpack()
+	if err != nil {
+		return err
+	}
+	for _, m := range msgs {
+		if err = s.handle(ss, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// assemble adds the chunk to the stream, returns the whole message once reassembled, returns the message as is if not chunked
+func (s *Service) assemble(ss *ServiceStream, msg *Message) (*Message, error) {
+	if !msg.IsChunk() {
+		return msg, nil
+	}
+	whole, done, total, err := ss.parts.add(msg)
+	if err != nil {
+		return nil, err
+	}
+	s.onProgress(msg.Header(KeyChunkID), done, total)
+	return whole, nil
+}
+
+func (s *Service) onProgress(id string, done, total int64) {
+	if p, ok := s.obs.(ProgressObserver); ok {
+		p.OnProgress(id, done, total)
+	}
+}
+
+// Send sends the message to all streams talking to the service, returns the first error
+func (s *Service) Send(msg *Message) error {
+	s.mu.Lock()
+	ss := make([]*ServiceStream, 0, len(s.streams))
+	for v := range s.streams {
+		ss = append(ss, v)
+	}
+	s.mu.Unlock()
+	var res error
+	for _, v := range ss {
+		if err := v.Send(msg); err != nil && res == nil {
+			res = err
+		}
+	}
+	return res
+}
+
+// Close stops the service gracefully, the streams are closed and the calls inflight are waited until the timeout
+func (s *Service) Close() error {
+	s.once.Do(func() {
+		s.log.Info("service is closing")
+		defer s.log.Info("service has closed")
+
+		close(s.closing)
+		stopped := make(chan struct{})
+		go func() {
+			s.svr.GracefulStop()
+			close(stopped)
+		}()
+		t := time.NewTimer(s.cfg.ShutdownTimeout)
+		defer t.Stop()
+		select {
+		case <-stopped:
+		case <-t.C:
+			s.log.Warn("service is stopped forcibly since shutdown timed out")
+			s.svr.Stop()
+			<-stopped
+		}
+	})
+	return nil
+}
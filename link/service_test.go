@@ -0,0 +1,124 @@
+package link
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/baetyl/baetyl-go/auth"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type mockServiceObserver struct {
+	msgs chan *Message
+	acks chan *Message
+}
+
+func (o *mockServiceObserver) OnCall(_ context.Context, msg *Message) (*Message, error) {
+	if string(msg.Content) == "fail" {
+		return nil, errors.New("failed")
+	}
+	return msg, nil
+}
+
+func (o *mockServiceObserver) OnMsg(s *ServiceStream, msg *Message) error {
+	o.msgs <- msg
+	if NewCredentials(s.Context()).Username != "u1" {
+		return errors.New("unexpected username")
+	}
+	// replies to the stream
+	return s.Send(&Message{Content: []byte("reply")})
+}
+
+func (o *mockServiceObserver) OnAck(_ *ServiceStream, msg *Message) error {
+	o.acks <- msg
+	return nil
+}
+
+func TestService(t *testing.T) {
+	cfg := newServerConfig()
+	cfg.Address = "127.0.0.1:0"
+	obs := &mockServiceObserver{msgs: make(chan *Message, 10), acks: make(chan *Message, 10)}
+	svc, err := NewService(cfg, obs, NewAuthenticator(auth.NewPasswords(map[string]string{"u1": "p1"}), nil))
+	assert.NoError(t, err)
+	defer svc.Close()
+
+	cc := newClientConfig()
+	cc.Address = svc.Addr().String()
+	// the requests and the messages are compressed by the client, and decompressed by the service
+	cc.CompressThreshold = 64
+	cobs := newMockObserver(t)
+	cli, err := NewClient(cc, cobs)
+	assert.NoError(t, err)
+	defer cli.Close()
+
+	// call
+	res, err := cli.Call(&Message{Content: []byte("hi")})
+	assert.NoError(t, err)
+	assert.Equal(t, "hi", string(res.Content))
+	big := strings.Repeat("baetyl", 100)
+	res, err = cli.Call(&Message{Content: []byte(big)})
+	assert.NoError(t, err)
+	assert.Equal(t, big, string(res.Content))
+	_, err = cli.Call(&Message{Content: []byte("fail")})
+	assert.Equal(t, codes.Unknown, status.Code(err))
+
+	// talk, the message of qos 1 is acknowledged after handled
+	msg := &Message{Content: []byte(big)}
+	msg.Context.ID = 1
+	msg.Context.QOS = 1
+	assert.NoError(t, cli.Send(msg))
+	assert.Equal(t, big, string((<-obs.msgs).Content))
+	got := map[Type]string{}
+	for i := 0; i < 2; i++ {
+		select {
+		case m := <-cobs.msgs:
+			got[m.Context.Type] = string(m.Content)
+		case <-time.After(3 * time.Second):
+			t.Fatal("message not received")
+		}
+	}
+	assert.Equal(t, map[Type]string{Msg: "reply", Ack: ""}, got)
+
+	// sent to all streams, and the ack of client is observed
+	push := &Message{Content: []byte("push")}
+	push.Context.ID = 2
+	push.Context.QOS = 1
+	assert.NoError(t, svc.Send(push))
+	select {
+	case m := <-cobs.msgs:
+		assert.Equal(t, "push", string(m.Content))
+	case <-time.After(3 * time.Second):
+		t.Fatal("message not received")
+	}
+	select {
+	case m := <-obs.acks:
+		assert.Equal(t, uint64(2), m.Context.ID)
+	case <-time.After(3 * time.Second):
+		t.Fatal("ack not received")
+	}
+
+	// unauthenticated
+	cc.Password = "p2"
+	cli2, err := NewClient(cc, nil)
+	assert.NoError(t, err)
+	defer cli2.Close()
+	_, err = cli2.Call(&Message{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// closed gracefully even if the stream is talking
+	done := make(chan struct{})
+	go func() {
+		svc.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("service not closed")
+	}
+}
@@ -0,0 +1,20 @@
+package log
+
+import "context"
+
+type ctxKey struct{}
+
+// NewContext returns a copy of the context carrying the logger
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// FromContext returns the logger carried by the context, or the global logger if not found
+func FromContext(ctx context.Context) *Logger {
+	if ctx != nil {
+		if l, ok := ctx.Value(ctxKey{}).(*Logger); ok && l != nil {
+			return l
+		}
+	}
+	return L()
+}
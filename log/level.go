@@ -0,0 +1,119 @@
+package log
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// the level shared by all loggers, which can be changed at runtime
+var level = zap.NewAtomicLevelAt(InfoLevel)
+
+var levelCtl struct {
+	base   Level        // the level configured, restored by ResetLevel
+	server *http.Server // the http endpoint of level
+	once   sync.Once    // watches the signals once
+	mu     sync.Mutex
+}
+
+// SetLevel changes the level of all loggers at runtime, such as to turn on debug logging temporarily
+func SetLevel(lvl string) error {
+	lvl = strings.ToLower(lvl)
+	if lvl == "warning" {
+		lvl = "warn"
+	}
+	var l Level
+	if err := l.UnmarshalText([]byte(lvl)); err != nil {
+		return fmt.Errorf("log level (%s) is invalid", lvl)
+	}
+	level.SetLevel(l)
+	return nil
+}
+
+// GetLevel returns the current level
+func GetLevel() string {
+	return level.Level().String()
+}
+
+// ResetLevel restores the level configured by Init
+func ResetLevel() {
+	levelCtl.mu.Lock()
+	defer levelCtl.mu.Unlock()
+	level.SetLevel(levelCtl.base)
+}
+
+// LevelHandler returns the http handler to get the level by GET and change it by PUT with the json body,
+// such as {"level":"debug"}, which can be mounted to an existing http server
+func LevelHandler() http.Handler {
+	return level
+}
+
+// moduleCore the core filtering the entries by the level of module, the module is the key of the field added by With,
+// such as mqtt of With(Any("mqtt", "client")), the global level is used if the module is not configured
+type moduleCore struct {
+	zapcore.Core
+	enabler zapcore.LevelEnabler
+	modules map[string]zap.AtomicLevel
+}
+
+// newModuleCore wraps the core, which should enable all levels, the levels of modules are parsed from the config
+func newModuleCore(core zapcore.Core, levels map[string]string) zapcore.Core {
+	modules := make(map[string]zap.AtomicLevel, len(levels))
+	for k, v := range levels {
+		modules[k] = zap.NewAtomicLevelAt(parseLevel(v))
+	}
+	return &moduleCore{Core: core, enabler: level, modules: modules}
+}
+
+func (c *moduleCore) Enabled(lvl Level) bool {
+	return c.enabler.Enabled(lvl)
+}
+
+// With picks the level of module if the key of any field is a module configured
+func (c *moduleCore) With(fields []Field) zapcore.Core {
+	res := &moduleCore{Core: c.Core.With(fields), enabler: c.enabler, modules: c.modules}
+	for _, f := range fields {
+		if l, ok := c.modules[f.Key]; ok {
+			res.enabler = l
+		}
+	}
+	return res
+}
+
+func (c *moduleCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.enabler.Enabled(ent.Level) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
+
+func initLevel(cfg Config) error {
+	lvl := parseLevel(cfg.Level)
+	levelCtl.mu.Lock()
+	defer levelCtl.mu.Unlock()
+	levelCtl.base = lvl
+	level.SetLevel(lvl)
+
+	if cfg.LevelSignals {
+		levelCtl.once.Do(watchLevelSignals)
+	}
+	if levelCtl.server != nil {
+		levelCtl.server.Close()
+		levelCtl.server = nil
+	}
+	if cfg.LevelAddress == "" {
+		return nil
+	}
+	lis, err := net.Listen("tcp", cfg.LevelAddress)
+	if err != nil {
+		return err
+	}
+	levelCtl.server = &http.Server{Handler: level}
+	go levelCtl.server.Serve(lis)
+	return nil
+}
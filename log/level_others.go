@@ -0,0 +1,9 @@
+//go:build windows
+// +build windows
+
+package log
+
+// watchLevelSignals is not supported, since there are no user signals
+func watchLevelSignals() {
+	L().Warn("log level signals not supported")
+}
@@ -0,0 +1,26 @@
+//go:build !windows
+// +build !windows
+
+package log
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// watchLevelSignals turns on debug logging on SIGUSR1, and restores the level configured on SIGUSR2
+func watchLevelSignals() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGUSR1, syscall.SIGUSR2)
+	go func() {
+		for s := range sig {
+			if s == syscall.SIGUSR1 {
+				level.SetLevel(DebugLevel)
+			} else {
+				ResetLevel()
+			}
+			L().Info("log level is changed by signal", Any("signal", s.String()), Any("level", GetLevel()))
+		}
+	}()
+}
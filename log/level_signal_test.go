@@ -0,0 +1,24 @@
+//go:build !windows
+// +build !windows
+
+package log
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLevelSignals(t *testing.T) {
+	cfg := Config{Level: "warn", Encoding: "json", MaxAge: 15, MaxSize: 1, MaxBackups: 15, LevelSignals: true}
+	_, err := Init(cfg)
+	assert.NoError(t, err)
+	defer Init(Config{Level: "info", Encoding: "json", MaxAge: 15, MaxSize: 1, MaxBackups: 15})
+
+	assert.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR1))
+	assert.Eventually(t, func() bool { return GetLevel() == "debug" }, time.Second, 10*time.Millisecond)
+	assert.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR2))
+	assert.Eventually(t, func() bool { return GetLevel() == "warn" }, time.Second, 10*time.Millisecond)
+}
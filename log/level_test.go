@@ -0,0 +1,103 @@
+package log
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLevel(t *testing.T) {
+	dir, err := ioutil.TempDir("", t.Name())
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := path.Join(dir, "level.log")
+	cfg := Config{Filename: file, Level: "info", Encoding: "json", MaxAge: 15, MaxSize: 1, MaxBackups: 15, LevelAddress: "127.0.0.1:0"}
+	logger, err := Init(cfg)
+	assert.NoError(t, err)
+	defer Init(Config{Level: "info", Encoding: "json", MaxAge: 15, MaxSize: 1, MaxBackups: 15})
+	assert.Equal(t, "info", GetLevel())
+	assert.NotNil(t, levelCtl.server)
+
+	assert.EqualError(t, SetLevel("xxx"), "log level (xxx) is invalid")
+	assert.NoError(t, SetLevel("DEBUG"))
+	assert.Equal(t, "debug", GetLevel())
+	// the level of loggers created before is changed as well
+	logger.Debug("debug1")
+	With(Any("k", "v")).Debug("debug2")
+	ResetLevel()
+	assert.Equal(t, "info", GetLevel())
+	logger.Debug("debug3")
+	logger.Sync()
+	data, err := ioutil.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "debug1")
+	assert.Contains(t, string(data), "debug2")
+	assert.NotContains(t, string(data), "debug3")
+
+	assert.NoError(t, SetLevel("warning"))
+	assert.Equal(t, "warn", GetLevel())
+
+	// changed by http
+	h := LevelHandler()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"level":"error"}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "error", GetLevel())
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.JSONEq(t, `{"level":"error"}`, w.Body.String())
+
+	// the endpoint is closed once initialized again without address
+	cfg.LevelAddress = ""
+	_, err = Init(cfg)
+	assert.NoError(t, err)
+	assert.Nil(t, levelCtl.server)
+	assert.Equal(t, "info", GetLevel())
+
+	cfg.LevelAddress = "127.0.0.1:-1"
+	_, err = Init(cfg)
+	assert.Error(t, err)
+}
+
+func TestModuleLevels(t *testing.T) {
+	dir, err := ioutil.TempDir("", t.Name())
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := path.Join(dir, "modules.log")
+	cfg := Config{Filename: file, Level: "warn", Encoding: "json", MaxAge: 15, MaxSize: 1, MaxBackups: 15}
+	cfg.Levels = map[string]string{"mqtt": "debug", "link": "error"}
+	logger, err := Init(cfg)
+	assert.NoError(t, err)
+	defer Init(Config{Level: "info", Encoding: "json", MaxAge: 15, MaxSize: 1, MaxBackups: 15})
+
+	mqtt := With(Any("mqtt", "client"))
+	mqtt.Debug("mqtt-debug")
+	// the children of module keep its level
+	mqtt.With(Any("id", "1")).Debug("mqtt-child-debug")
+	With(Any("link", "client")).Warn("link-warn")
+	With(Any("link", "client")).Error("link-error")
+	logger.Info("global-info")
+	logger.Warn("global-warn")
+	// the global level is changed at runtime, but not the levels of modules
+	assert.NoError(t, SetLevel("info"))
+	logger.Info("global-info-changed")
+	With(Any("link", "client")).Warn("link-warn-changed")
+	logger.Sync()
+
+	data, err := ioutil.ReadFile(file)
+	assert.NoError(t, err)
+	for _, s := range []string{"mqtt-debug", "mqtt-child-debug", "link-error", "global-warn", "global-info-changed"} {
+		assert.Contains(t, string(data), s)
+	}
+	for _, s := range []string{"link-warn", "global-info\""} {
+		assert.NotContains(t, string(data), s)
+	}
+}
@@ -28,6 +28,7 @@ func init() {
 	c := zap.NewProductionConfig()
 	c.Sampling = nil
 	c.OutputPaths = []string{"stdout"}
+	c.Level = level
 	l, err := c.Build()
 	if err != nil {
 		panic(fmt.Sprintf("failed to create default logger: %s", err.Error()))
@@ -36,6 +37,14 @@ func init() {
 	if err != nil {
 		l.Error("failed to register lumberjack", Error(err))
 	}
+	err = zap.RegisterSink("syslog", newSyslogSink)
+	if err != nil {
+		l.Error("failed to register syslog", Error(err))
+	}
+	err = zap.RegisterSink("journald", newJournaldSink)
+	if err != nil {
+		l.Error("failed to register journald", Error(err))
+	}
 	zap.ReplaceGlobals(l)
 }
 
@@ -43,19 +52,45 @@ func init() {
 func Init(cfg Config, fields ...Field) (*Logger, error) {
 	c := zap.NewProductionConfig()
 	c.Sampling = nil
-	if cfg.Filename != "" {
+	if cfg.Output == "syslog" || cfg.Output == "journald" {
+		c.OutputPaths = []string{cfg.Output + ":?" + cfg.outputQuery()}
+	}
+	if customSink(cfg.Filename) {
+		c.OutputPaths = append(c.OutputPaths, cfg.Filename)
+	} else if cfg.Filename != "" {
 		c.OutputPaths = append(c.OutputPaths, "lumberjack:?"+cfg.String())
 	}
 	if cfg.Encoding == "console" {
 		c.Encoding = "console"
 		c.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
 	}
-	c.Level = zap.NewAtomicLevelAt(parseLevel(cfg.Level))
-	l, err := c.Build(zap.Fields(fields...))
+	// the entries are filtered by the global level or the levels of modules
+	c.Level = zap.NewAtomicLevelAt(DebugLevel)
+	if err := initLevel(cfg); err != nil {
+		return nil, err
+	}
+	var remote *remoteSink
+	if cfg.Remote.URL != "" {
+		shipper, err := newShipper(cfg.Remote.URL)
+		if err != nil {
+			return nil, err
+		}
+		remote = newRemoteSink(cfg.Remote, shipper)
+	}
+	l, err := c.Build(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		if remote != nil {
+			core = zapcore.NewTee(core, newRemoteCore(c.EncoderConfig, remote))
+		}
+		return newModuleCore(newSamplingCore(core, cfg), cfg.Levels)
+	}), zap.Fields(fields...))
 	if err != nil {
+		if remote != nil {
+			remote.Close()
+		}
 		return nil, err
 	}
 	zap.ReplaceGlobals(l)
+	replaceRemote(remote)
 	return L(), nil
 }
 
@@ -78,6 +113,9 @@ func newFileHook(u *url.URL) (zap.Sink, error) {
 		L().Warn("failed to create log directory", Error(err))
 		return nil, err
 	}
+	if cfg.RotateInterval != "" {
+		return newRotateSink(cfg)
+	}
 	return &lumberjackSink{&lumberjack.Logger{
 		Compress:   cfg.Compress,
 		Filename:   cfg.Filename,
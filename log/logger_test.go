@@ -1,6 +1,7 @@
 package log
 
 import (
+	"context"
 	"io/ioutil"
 	"net/url"
 	"os"
@@ -199,3 +200,12 @@ func BenchmarkConsoleAndFile(b *testing.B) {
 		logger.Sync()
 	})
 }
+
+func TestContext(t *testing.T) {
+	assert.Equal(t, L(), FromContext(nil))
+	assert.Equal(t, L(), FromContext(context.Background()))
+
+	l := With(Any("id", "1"))
+	ctx := NewContext(context.Background(), l)
+	assert.Equal(t, l, FromContext(ctx))
+}
@@ -0,0 +1,235 @@
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jpillora/backoff"
+	"go.uber.org/zap/zapcore"
+)
+
+// Shipper ships the batches of entries to the remote, such as to publish to a mqtt topic or to post to a http endpoint,
+// the batch is the entries in json lines compressed by gzip. The shipper must not log by the logger of remote sink.
+type Shipper interface {
+	Ship(ctx context.Context, data []byte) error
+	Close() error
+}
+
+// ShipperFactory creates the shipper by the url of remote sink
+type ShipperFactory func(u *url.URL) (Shipper, error)
+
+var shippers = struct {
+	factories map[string]ShipperFactory
+	sync.Mutex
+}{factories: map[string]ShipperFactory{}}
+
+// RegisterShipper registers the factory of shipper for the scheme of url, such as mqtt and http registered
+// by the packages of mqtt and http, the factory registered later replaces the former one of the same scheme
+func RegisterShipper(scheme string, factory ShipperFactory) {
+	shippers.Lock()
+	shippers.factories[scheme] = factory
+	shippers.Unlock()
+}
+
+func newShipper(addr string) (Shipper, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, err
+	}
+	shippers.Lock()
+	factory, ok := shippers.factories[u.Scheme]
+	shippers.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("shipper of scheme (%s) not registered", u.Scheme)
+	}
+	return factory(u)
+}
+
+// the remote sink of the logger initialized, closed when the logger is initialized again
+var remoteCtl struct {
+	sink *remoteSink
+	mu   sync.Mutex
+}
+
+// replaceRemote replaces the remote sink and closes the previous one, the entries buffered are shipped once more
+func replaceRemote(s *remoteSink) {
+	remoteCtl.mu.Lock()
+	prev := remoteCtl.sink
+	remoteCtl.sink = s
+	remoteCtl.mu.Unlock()
+	if prev != nil {
+		prev.Close()
+	}
+}
+
+// remoteSink the sink batching the entries, the batches are compressed and buffered in memory,
+// and shipped one by one with retry, the oldest batch is dropped if the buffer is full
+type remoteSink struct {
+	cfg     RemoteConfig
+	shipper Shipper
+	batch   bytes.Buffer
+	count   int
+	pending chan []byte
+	dropped int // the batches dropped since the last report
+	mu      sync.Mutex
+	closing chan struct{}
+	done    chan struct{}
+	once    sync.Once
+	err     error
+}
+
+// newRemoteSink creates the remote sink, the config not set is defaulted since the config of logger may be not defaulted
+func newRemoteSink(cfg RemoteConfig, shipper Shipper) *remoteSink {
+	defaultInt(&cfg.BatchSize, 100)
+	defaultInt(&cfg.BatchBytes, 65536)
+	defaultInt(&cfg.MaxPending, 100)
+	defaultDuration(&cfg.Interval, 5*time.Second)
+	defaultDuration(&cfg.Timeout, 30*time.Second)
+	defaultDuration(&cfg.RetryMin, time.Second)
+	defaultDuration(&cfg.RetryMax, time.Minute)
+	s := &remoteSink{
+		cfg:     cfg,
+		shipper: shipper,
+		pending: make(chan []byte, cfg.MaxPending),
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go s.shipping()
+	return s
+}
+
+// Write appends the entry to the batch, the batch is flushed if it is full
+func (s *remoteSink) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batch.Write(p)
+	s.count++
+	if s.count >= s.cfg.BatchSize || s.batch.Len() >= s.cfg.BatchBytes {
+		s.flush()
+	}
+	return len(p), nil
+}
+
+// Sync flushes the batch to the buffer, which is shipped asynchronously
+func (s *remoteSink) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flush()
+	return nil
+}
+
+// Close flushes the batch, ships the batches buffered once without retry and closes the shipper
+func (s *remoteSink) Close() error {
+	s.once.Do(func() {
+		s.Sync()
+		close(s.closing)
+		<-s.done
+		s.err = s.shipper.Close()
+	})
+	return s.err
+}
+
+// flush compresses the batch and buffers it, must be called with the lock held
+func (s *remoteSink) flush() {
+	if s.count == 0 {
+		return
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(s.batch.Bytes())
+	zw.Close()
+	s.batch.Reset()
+	s.count = 0
+	for {
+		select {
+		case s.pending <- buf.Bytes():
+			return
+		default:
+		}
+		select {
+		case <-s.pending:
+			s.dropped++
+		default:
+		}
+	}
+}
+
+func (s *remoteSink) shipping() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+	bf := backoff.Backoff{Min: s.cfg.RetryMin, Max: s.cfg.RetryMax, Factor: 2}
+	for {
+		select {
+		case data := <-s.pending:
+			for !s.ship(data) {
+				select {
+				case <-time.After(bf.Duration()):
+				case <-s.closing:
+					s.drain()
+					return
+				}
+			}
+			bf.Reset()
+		case <-ticker.C:
+			s.Sync()
+		case <-s.closing:
+			s.drain()
+			return
+		}
+	}
+}
+
+// drain ships the batches buffered once when the sink is closing
+func (s *remoteSink) drain() {
+	for {
+		select {
+		case data := <-s.pending:
+			s.ship(data)
+		default:
+			return
+		}
+	}
+}
+
+func (s *remoteSink) ship(data []byte) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.Timeout)
+	defer cancel()
+	err := s.shipper.Ship(ctx, data)
+	s.mu.Lock()
+	dropped := s.dropped
+	if err == nil {
+		s.dropped = 0
+	}
+	s.mu.Unlock()
+	// reports to stderr instead of the logger, otherwise the reports are shipped as well
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\tfailed to ship log entries, retry later: %s\n", time.Now().Format(time.RFC3339), err.Error())
+	} else if dropped > 0 {
+		fmt.Fprintf(os.Stderr, "%s\t%d batches of log entries dropped since the buffer is full\n", time.Now().Format(time.RFC3339), dropped)
+	}
+	return err == nil
+}
+
+func defaultInt(v *int, d int) {
+	if *v <= 0 {
+		*v = d
+	}
+}
+
+func defaultDuration(v *time.Duration, d time.Duration) {
+	if *v <= 0 {
+		*v = d
+	}
+}
+
+// newRemoteCore creates the core of remote sink, the entries are always encoded in json
+func newRemoteCore(enc zapcore.EncoderConfig, s *remoteSink) zapcore.Core {
+	return zapcore.NewCore(zapcore.NewJSONEncoder(enc), s, DebugLevel)
+}
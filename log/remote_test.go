@@ -0,0 +1,137 @@
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockShipper struct {
+	batches chan []string
+	fails   int // the count of failures before success
+	closed  bool
+	mu      sync.Mutex
+}
+
+func newMockShipper(fails int) *mockShipper {
+	return &mockShipper{batches: make(chan []string, 100), fails: fails}
+}
+
+func (s *mockShipper) Ship(_ context.Context, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fails > 0 {
+		s.fails--
+		return errors.New("network is unreachable")
+	}
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	lines, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	s.batches <- strings.Split(strings.TrimSpace(string(lines)), "\n")
+	return nil
+}
+
+func (s *mockShipper) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *mockShipper) assertBatch(t *testing.T, expected ...string) {
+	select {
+	case batch := <-s.batches:
+		assert.Equal(t, expected, batch)
+	case <-time.After(3 * time.Second):
+		t.Fatal("batch not shipped")
+	}
+}
+
+func TestRemoteSink(t *testing.T) {
+	cfg := RemoteConfig{BatchSize: 2, BatchBytes: 1024, Interval: 100 * time.Millisecond, MaxPending: 2, RetryMin: 10 * time.Millisecond, RetryMax: 10 * time.Millisecond}
+
+	// shipped once the batch is full, or in the interval
+	shipper := newMockShipper(0)
+	s := newRemoteSink(cfg, shipper)
+	s.Write([]byte("a\n"))
+	s.Write([]byte("b\n"))
+	shipper.assertBatch(t, "a", "b")
+	s.Write([]byte("c\n"))
+	shipper.assertBatch(t, "c")
+	s.Write([]byte(strings.Repeat("d", 1024) + "\n"))
+	shipper.assertBatch(t, strings.Repeat("d", 1024))
+	assert.NoError(t, s.Close())
+	assert.True(t, shipper.closed)
+
+	// retried on failure
+	shipper = newMockShipper(3)
+	s = newRemoteSink(cfg, shipper)
+	s.Write([]byte("a\n"))
+	s.Write([]byte("b\n"))
+	shipper.assertBatch(t, "a", "b")
+	assert.NoError(t, s.Close())
+
+	// the oldest batch is dropped if the buffer is full, the batches buffered are shipped once closed
+	cfg.Interval = time.Hour
+	cfg.RetryMin, cfg.RetryMax = time.Hour, time.Hour
+	shipper = newMockShipper(1)
+	shipper.mu.Lock()
+	s = newRemoteSink(cfg, shipper)
+	s.Write([]byte("a\n"))
+	s.Write([]byte("b\n"))
+	// the batch being shipped fails
+	assert.Eventually(t, func() bool { return len(s.pending) == 0 }, time.Second, time.Millisecond)
+	for _, v := range []string{"c", "d", "e", "f", "g"} {
+		s.Write([]byte(v + "\n"))
+	}
+	shipper.mu.Unlock()
+	assert.NoError(t, s.Close())
+	shipper.assertBatch(t, "e", "f")
+	shipper.assertBatch(t, "g")
+	assert.Len(t, shipper.batches, 0)
+}
+
+func TestRemoteInit(t *testing.T) {
+	shipper := newMockShipper(0)
+	RegisterShipper("mock", func(u *url.URL) (Shipper, error) {
+		assert.Equal(t, "mock://host/logs", u.String())
+		return shipper, nil
+	})
+
+	cfg := Config{Level: "info", Encoding: "console", MaxAge: 15, MaxSize: 1, MaxBackups: 15}
+	cfg.Remote = RemoteConfig{URL: "mock://host/logs", BatchSize: 10, Interval: time.Hour}
+	logger, err := Init(cfg)
+	assert.NoError(t, err)
+	logger.Info("remote-info")
+	logger.Debug("remote-debug")
+	// the entries are shipped once the logger is initialized again
+	_, err = Init(Config{Level: "info", Encoding: "json", MaxAge: 15, MaxSize: 1, MaxBackups: 15})
+	assert.NoError(t, err)
+	assert.True(t, shipper.closed)
+	select {
+	case batch := <-shipper.batches:
+		assert.Len(t, batch, 1)
+		// always encoded in json
+		assert.Contains(t, batch[0], `"msg":"remote-info"`)
+	case <-time.After(3 * time.Second):
+		t.Fatal("batch not shipped")
+	}
+
+	cfg.Remote.URL = "unknown://host"
+	_, err = Init(cfg)
+	assert.EqualError(t, err, "shipper of scheme (unknown) not registered")
+}
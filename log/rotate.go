@@ -0,0 +1,147 @@
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	lumberjack "gopkg.in/natefinch/lumberjack.v2"
+)
+
+// the layouts of the periods stamped in the filenames, and the durations of the periods
+var rotateIntervals = map[string]struct {
+	layout   string
+	duration time.Duration
+}{
+	"hourly": {"2006-01-02T15", time.Hour},
+	"daily":  {"2006-01-02", 24 * time.Hour},
+}
+
+// rotateSink the sink rotating the file of each period, the file of a period is still rotated by the size by lumberjack,
+// and the files of the periods expired by max age or beyond max backups are removed once the period changes
+type rotateSink struct {
+	cfg      Config
+	layout   string
+	duration time.Duration
+	prefix   string
+	ext      string
+	period   string
+	file     *lumberjack.Logger
+	now      func() time.Time
+	mu       sync.Mutex
+}
+
+func newRotateSink(cfg Config) (*rotateSink, error) {
+	interval, ok := rotateIntervals[cfg.RotateInterval]
+	if !ok {
+		return nil, os.ErrInvalid
+	}
+	ext := filepath.Ext(cfg.Filename)
+	return &rotateSink{
+		cfg:      cfg,
+		layout:   interval.layout,
+		duration: interval.duration,
+		prefix:   strings.TrimSuffix(cfg.Filename, ext) + "-",
+		ext:      ext,
+		now:      time.Now,
+	}, nil
+}
+
+func (s *rotateSink) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if period := now.Format(s.layout); period != s.period {
+		if s.file != nil {
+			s.file.Close()
+		}
+		s.period = period
+		s.file = &lumberjack.Logger{
+			Compress:   s.cfg.Compress,
+			Filename:   s.prefix + period + s.ext,
+			MaxAge:     s.cfg.MaxAge,
+			MaxSize:    s.cfg.MaxSize,
+			MaxBackups: s.cfg.MaxBackups,
+		}
+		s.prune(now)
+	}
+	return s.file.Write(p)
+}
+
+// Sync commits the file of the current period to the disk, lumberjack doesn't expose its file,
+// so the file is reopened by the name and synced, which flushes the same inode
+func (s *rotateSink) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	f, err := os.OpenFile(s.file.Filename, os.O_WRONLY, 0)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *rotateSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	return s.file.Close()
+}
+
+// prune removes the files of the previous periods, including the backups rotated by size,
+// which are older than max age or beyond max backups
+func (s *rotateSink) prune(now time.Time) {
+	infos, err := ioutil.ReadDir(filepath.Dir(s.cfg.Filename))
+	if err != nil {
+		return
+	}
+	base := filepath.Base(s.prefix)
+	files := map[string][]string{}
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !strings.HasPrefix(name, base) || len(name) < len(base)+len(s.layout) {
+			continue
+		}
+		// the rest is the extension of the file or the timestamp of the backup by lumberjack
+		period, rest := name[len(base):len(base)+len(s.layout)], name[len(base)+len(s.layout):]
+		if period == s.period || !strings.HasPrefix(rest, "-") && !strings.HasPrefix(rest, s.ext) {
+			continue
+		}
+		if _, err := time.ParseInLocation(s.layout, period, now.Location()); err != nil {
+			continue
+		}
+		files[period] = append(files[period], filepath.Join(filepath.Dir(s.cfg.Filename), name))
+	}
+	periods := make([]string, 0, len(files))
+	for period := range files {
+		periods = append(periods, period)
+	}
+	// the periods are sorted from the newest since the layouts are in order
+	sort.Sort(sort.Reverse(sort.StringSlice(periods)))
+	expiry := now.Add(-time.Duration(s.cfg.MaxAge) * 24 * time.Hour)
+	for i, period := range periods {
+		// the period is expired if it ends before the expiry
+		t, _ := time.ParseInLocation(s.layout, period, now.Location())
+		if (s.cfg.MaxBackups <= 0 || i < s.cfg.MaxBackups) && (s.cfg.MaxAge <= 0 || t.Add(s.duration).After(expiry)) {
+			continue
+		}
+		for _, f := range files[period] {
+			os.Remove(f)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package log
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRotateSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", t.Name())
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cfg := Config{
+		Filename:       path.Join(dir, "baetyl.log"),
+		MaxAge:         15,
+		MaxSize:        1,
+		MaxBackups:     2,
+		RotateInterval: "daily",
+	}
+	u := url.URL{Scheme: "lumberjack", RawQuery: cfg.String()}
+	sink, err := newFileHook(&u)
+	assert.NoError(t, err)
+	s := sink.(*rotateSink)
+	defer s.Close()
+	// nothing to sync before the first write
+	assert.NoError(t, s.Sync())
+
+	// the unrelated files are kept
+	other := path.Join(dir, "baetyl-other.log")
+	assert.NoError(t, ioutil.WriteFile(other, []byte("other"), 0644))
+
+	now := time.Date(2020, 3, 1, 8, 0, 0, 0, time.Local)
+	s.now = func() time.Time { return now }
+	_, err = s.Write([]byte("day1\n"))
+	assert.NoError(t, err)
+	now = now.Add(time.Hour)
+	_, err = s.Write([]byte("day1 again\n"))
+	assert.NoError(t, err)
+	data, err := ioutil.ReadFile(path.Join(dir, "baetyl-2020-03-01.log"))
+	assert.NoError(t, err)
+	assert.Equal(t, "day1\nday1 again\n", string(data))
+	assert.NoError(t, s.Sync())
+
+	for _, day := range []int{2, 3, 4} {
+		now = time.Date(2020, 3, day, 0, 0, 1, 0, time.Local)
+		_, err = s.Write([]byte("next day\n"))
+		assert.NoError(t, err)
+	}
+	// the files of the previous periods beyond max backups are removed
+	assertNotExist(t, path.Join(dir, "baetyl-2020-03-01.log"))
+	assert.FileExists(t, path.Join(dir, "baetyl-2020-03-02.log"))
+	assert.FileExists(t, path.Join(dir, "baetyl-2020-03-03.log"))
+	assert.FileExists(t, path.Join(dir, "baetyl-2020-03-04.log"))
+	assert.FileExists(t, other)
+
+	// the files of the previous periods older than max age are removed
+	now = time.Date(2020, 3, 19, 0, 0, 1, 0, time.Local)
+	_, err = s.Write([]byte("later\n"))
+	assert.NoError(t, err)
+	assertNotExist(t, path.Join(dir, "baetyl-2020-03-02.log"))
+	assertNotExist(t, path.Join(dir, "baetyl-2020-03-03.log"))
+	assert.FileExists(t, path.Join(dir, "baetyl-2020-03-04.log"))
+	assert.FileExists(t, path.Join(dir, "baetyl-2020-03-19.log"))
+
+	cfg.RotateInterval = "hourly"
+	hourly, err := newRotateSink(cfg)
+	assert.NoError(t, err)
+	defer hourly.Close()
+	hourly.now = func() time.Time { return now }
+	_, err = hourly.Write([]byte("hourly\n"))
+	assert.NoError(t, err)
+	assert.FileExists(t, path.Join(dir, "baetyl-2020-03-19T00.log"))
+
+	cfg.RotateInterval = "weekly"
+	_, err = newRotateSink(cfg)
+	assert.Error(t, err)
+}
+
+func assertNotExist(t *testing.T, file string) {
+	_, err := os.Stat(file)
+	assert.True(t, os.IsNotExist(err), file)
+}
@@ -0,0 +1,123 @@
+package log
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// KeyDropped the key of the field of the entries dropped by the rate limiter before the entry
+const KeyDropped = "dropped"
+
+// the max keys tracked by the rate limiter, the idle keys are pruned once reached
+const maxRateKeys = 1024
+
+// newSamplingCore wraps the core by the rate limiter and then the sampler if configured
+func newSamplingCore(core zapcore.Core, cfg Config) zapcore.Core {
+	if cfg.RateLimit.Rate > 0 {
+		core = &rateCore{Core: core, limiter: newRateLimiter(cfg.RateLimit)}
+	}
+	if cfg.Sampling.Initial > 0 {
+		tick := cfg.Sampling.Tick
+		if tick <= 0 {
+			tick = time.Second
+		}
+		core = zapcore.NewSampler(core, tick, cfg.Sampling.Initial, cfg.Sampling.Thereafter)
+	}
+	return core
+}
+
+// rateCore the core limiting the rate of the entries of the same level and message, the entries dropped
+// are collapsed into the count of the next entry allowed
+type rateCore struct {
+	zapcore.Core
+	limiter *rateLimiter
+}
+
+func (c *rateCore) With(fields []Field) zapcore.Core {
+	return &rateCore{Core: c.Core.With(fields), limiter: c.limiter}
+}
+
+func (c *rateCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.Enabled(ent.Level) {
+		return ce
+	}
+	return ce.AddCore(ent, c)
+}
+
+func (c *rateCore) Write(ent zapcore.Entry, fields []Field) error {
+	dropped, ok := c.limiter.allow(ent.Level, ent.Message, ent.Time)
+	if !ok {
+		return nil
+	}
+	if dropped > 0 {
+		fields = append(fields[:len(fields):len(fields)], zap.Int(KeyDropped, dropped))
+	}
+	return c.Core.Write(ent, fields)
+}
+
+type rateKey struct {
+	level   Level
+	message string
+}
+
+// rateBucket the token bucket of a key
+type rateBucket struct {
+	tokens  float64
+	last    time.Time
+	dropped int
+}
+
+type rateLimiter struct {
+	rate    float64
+	burst   float64
+	buckets map[rateKey]*rateBucket
+	mu      sync.Mutex
+}
+
+func newRateLimiter(cfg RateLimitConfig) *rateLimiter {
+	burst := float64(cfg.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{rate: cfg.Rate, burst: burst, buckets: map[rateKey]*rateBucket{}}
+}
+
+// allow checks whether the entry is allowed, returns the count of entries of the same key dropped before if allowed
+func (l *rateLimiter) allow(lvl Level, msg string, now time.Time) (int, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	k := rateKey{level: lvl, message: msg}
+	b, ok := l.buckets[k]
+	if !ok {
+		if len(l.buckets) >= maxRateKeys {
+			l.prune(now)
+		}
+		b = &rateBucket{tokens: l.burst, last: now}
+		l.buckets[k] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+	if b.tokens < 1 {
+		b.dropped++
+		return 0, false
+	}
+	b.tokens--
+	dropped := b.dropped
+	b.dropped = 0
+	return dropped, true
+}
+
+// prune removes the buckets refilled without drops, which are the same as new ones
+func (l *rateLimiter) prune(now time.Time) {
+	for k, b := range l.buckets {
+		if b.dropped == 0 && b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, k)
+		}
+	}
+}
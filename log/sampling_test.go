@@ -0,0 +1,87 @@
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter(RateLimitConfig{Rate: 1, Burst: 2})
+	now := time.Now()
+	for i := 0; i < 2; i++ {
+		dropped, ok := l.allow(ErrorLevel, "m", now)
+		assert.True(t, ok)
+		assert.Equal(t, 0, dropped)
+	}
+	for i := 0; i < 3; i++ {
+		_, ok := l.allow(ErrorLevel, "m", now)
+		assert.False(t, ok)
+	}
+	// the other keys are not limited
+	_, ok := l.allow(WarnLevel, "m", now)
+	assert.True(t, ok)
+	_, ok = l.allow(ErrorLevel, "n", now)
+	assert.True(t, ok)
+	// refilled by the rate, the count dropped is reported once
+	dropped, ok := l.allow(ErrorLevel, "m", now.Add(time.Second))
+	assert.True(t, ok)
+	assert.Equal(t, 3, dropped)
+	_, ok = l.allow(ErrorLevel, "m", now.Add(time.Second))
+	assert.False(t, ok)
+	dropped, ok = l.allow(ErrorLevel, "m", now.Add(3*time.Second))
+	assert.True(t, ok)
+	assert.Equal(t, 1, dropped)
+
+	// the buckets refilled are pruned
+	for i := 0; i < maxRateKeys; i++ {
+		l.allow(InfoLevel, string(rune('a'+i)), now)
+	}
+	l.allow(InfoLevel, "new", now.Add(time.Hour))
+	assert.Len(t, l.buckets, 1)
+}
+
+func TestSamplingAndRateLimit(t *testing.T) {
+	dir, err := ioutil.TempDir("", t.Name())
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := path.Join(dir, "sampling.log")
+	cfg := Config{Filename: file, Level: "info", Encoding: "json", MaxAge: 15, MaxSize: 1, MaxBackups: 15}
+	cfg.Sampling = SamplingConfig{Initial: 10, Thereafter: 5, Tick: time.Hour}
+	logger, err := Init(cfg)
+	assert.NoError(t, err)
+	defer Init(Config{Level: "info", Encoding: "json", MaxAge: 15, MaxSize: 1, MaxBackups: 15})
+	for i := 0; i < 30; i++ {
+		logger.Error("sampled")
+	}
+	logger.Sync()
+	data, err := ioutil.ReadFile(file)
+	assert.NoError(t, err)
+	// the first 10, and then every 5th of the other 20
+	assert.Equal(t, 14, strings.Count(string(data), `"msg":"sampled"`))
+
+	file = path.Join(dir, "limited.log")
+	cfg = Config{Filename: file, Level: "info", Encoding: "json", MaxAge: 15, MaxSize: 1, MaxBackups: 15}
+	cfg.RateLimit = RateLimitConfig{Rate: 10, Burst: 1}
+	logger, err = Init(cfg)
+	assert.NoError(t, err)
+	for i := 0; i < 30; i++ {
+		logger.With(Any("k", "v")).Error("limited")
+	}
+	time.Sleep(150 * time.Millisecond)
+	logger.Error("limited")
+	logger.Sync()
+	data, err = ioutil.ReadFile(file)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"k":"v"`)
+	assert.NotContains(t, lines[0], `"dropped"`)
+	assert.Contains(t, lines[1], `"dropped":29`)
+}
@@ -0,0 +1,117 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// Sink the sink of the entries encoded, such as the file, syslog and the custom ones registered
+type Sink = zap.Sink
+
+// SinkFactory creates the sink by the url of the filename in config
+type SinkFactory func(u *url.URL) (Sink, error)
+
+// the schemes of the custom sinks registered
+var sinks = struct {
+	schemes map[string]struct{}
+	sync.Mutex
+}{schemes: map[string]struct{}{}}
+
+// RegisterSink registers the factory of custom sink for the scheme, the sink is used instead of the file
+// if the filename in config is the url of the scheme, such as kafka://broker/topic, the scheme can not be
+// registered twice, nor be the ones built in, such as file, lumberjack, syslog and journald
+func RegisterSink(scheme string, factory SinkFactory) error {
+	sinks.Lock()
+	defer sinks.Unlock()
+	err := zap.RegisterSink(scheme, func(u *url.URL) (zap.Sink, error) {
+		return factory(u)
+	})
+	if err != nil {
+		return err
+	}
+	sinks.schemes[scheme] = struct{}{}
+	return nil
+}
+
+// customSink checks whether the filename is the url of the custom sink registered
+func customSink(filename string) bool {
+	u, err := url.Parse(filename)
+	if err != nil {
+		return false
+	}
+	sinks.Lock()
+	defer sinks.Unlock()
+	_, ok := sinks.schemes[u.Scheme]
+	return ok
+}
+
+// the facilities of syslog
+var facilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11, "local0": 16, "local1": 17, "local2": 18,
+	"local3": 19, "local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// outputQuery encodes the options of syslog and journald into the query of sink url
+func (c *Config) outputQuery() string {
+	args := url.Values{}
+	args.Set("encoding", c.Encoding)
+	args.Set("facility", c.Facility)
+	args.Set("tag", c.Tag)
+	args.Set("address", c.OutputAddress)
+	return args.Encode()
+}
+
+// parseFacility parses the facility of syslog, user by default
+func parseFacility(name string) (int, error) {
+	if name == "" {
+		return facilities["user"], nil
+	}
+	f, ok := facilities[name]
+	if !ok {
+		return 0, fmt.Errorf("syslog facility (%s) is invalid", name)
+	}
+	return f, nil
+}
+
+// severity gets the severity of syslog by the level of entry, such as 6 (informational) of info
+func severity(lvl Level) int {
+	switch lvl {
+	case DebugLevel:
+		return 7
+	case InfoLevel:
+		return 6
+	case WarnLevel:
+		return 4
+	case ErrorLevel:
+		return 3
+	default:
+		return 2
+	}
+}
+
+// entryLevel gets the level of the entry encoded, which is the field level in json, or the second column in console,
+// since the sink only gets the entries encoded, info is returned if not found
+func entryLevel(p []byte, encoding string) Level {
+	var text []byte
+	if encoding == "console" {
+		cols := bytes.SplitN(p, []byte("\t"), 3)
+		if len(cols) > 1 {
+			text = cols[1]
+		}
+	} else if i := bytes.Index(p, []byte(`"level":"`)); i >= 0 {
+		text = p[i+len(`"level":"`):]
+		if j := bytes.IndexByte(text, '"'); j >= 0 {
+			text = text[:j]
+		}
+	}
+	var lvl Level
+	if err := lvl.UnmarshalText(text); err != nil || len(text) == 0 {
+		return InfoLevel
+	}
+	return lvl
+}
@@ -0,0 +1,21 @@
+//go:build windows
+// +build windows
+
+package log
+
+import (
+	"errors"
+	"net/url"
+
+	"go.uber.org/zap"
+)
+
+// newSyslogSink is not supported, since there is no syslog
+func newSyslogSink(*url.URL) (zap.Sink, error) {
+	return nil, errors.New("log output (syslog) not supported")
+}
+
+// newJournaldSink is not supported, since there is no journald
+func newJournaldSink(*url.URL) (zap.Sink, error) {
+	return nil, errors.New("log output (journald) not supported")
+}
@@ -0,0 +1,133 @@
+//go:build !windows
+// +build !windows
+
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+	"log/syslog"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"go.uber.org/zap"
+)
+
+// the socket of the native protocol of journald
+const journaldSocket = "/run/systemd/journal/socket"
+
+type syslogSink struct {
+	w        *syslog.Writer
+	encoding string
+}
+
+// newSyslogSink creates the sink writing to the local syslog, or to the remote one if the address is set,
+// such as udp://host:514
+func newSyslogSink(u *url.URL) (zap.Sink, error) {
+	args := u.Query()
+	facility, err := parseFacility(args.Get("facility"))
+	if err != nil {
+		return nil, err
+	}
+	var network, raddr string
+	if addr := args.Get("address"); addr != "" {
+		a, err := url.Parse(addr)
+		if err != nil {
+			return nil, err
+		}
+		network, raddr = a.Scheme, a.Host
+	}
+	w, err := syslog.Dial(network, raddr, syslog.Priority(facility<<3)|syslog.LOG_INFO, args.Get("tag"))
+	if err != nil {
+		return nil, err
+	}
+	return &syslogSink{w: w, encoding: args.Get("encoding")}, nil
+}
+
+func (s *syslogSink) Write(p []byte) (int, error) {
+	msg := string(bytes.TrimRight(p, "\n"))
+	var err error
+	switch entryLevel(p, s.encoding) {
+	case DebugLevel:
+		err = s.w.Debug(msg)
+	case InfoLevel:
+		err = s.w.Info(msg)
+	case WarnLevel:
+		err = s.w.Warning(msg)
+	case ErrorLevel:
+		err = s.w.Err(msg)
+	default:
+		err = s.w.Crit(msg)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (s *syslogSink) Sync() error {
+	return nil
+}
+
+func (s *syslogSink) Close() error {
+	return s.w.Close()
+}
+
+type journaldSink struct {
+	conn     *net.UnixConn
+	facility int
+	tag      string
+	encoding string
+}
+
+// newJournaldSink creates the sink writing to journald by the native protocol, the socket is
+// /run/systemd/journal/socket unless the address is set
+func newJournaldSink(u *url.URL) (zap.Sink, error) {
+	args := u.Query()
+	facility, err := parseFacility(args.Get("facility"))
+	if err != nil {
+		return nil, err
+	}
+	tag := args.Get("tag")
+	if tag == "" {
+		tag = filepath.Base(os.Args[0])
+	}
+	addr := args.Get("address")
+	if addr == "" {
+		addr = journaldSocket
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	return &journaldSink{conn: conn, facility: facility, tag: tag, encoding: args.Get("encoding")}, nil
+}
+
+// Write sends the entry as a datagram of fields, the message is always in the binary format
+// since the entry may contain newlines, such as the stacktrace of console encoding
+func (s *journaldSink) Write(p []byte) (int, error) {
+	msg := bytes.TrimRight(p, "\n")
+	var buf bytes.Buffer
+	buf.WriteString("PRIORITY=" + strconv.Itoa(severity(entryLevel(p, s.encoding))) + "\n")
+	buf.WriteString("SYSLOG_FACILITY=" + strconv.Itoa(s.facility) + "\n")
+	buf.WriteString("SYSLOG_IDENTIFIER=" + s.tag + "\n")
+	buf.WriteString("MESSAGE\n")
+	binary.Write(&buf, binary.LittleEndian, uint64(len(msg)))
+	buf.Write(msg)
+	buf.WriteString("\n")
+	if _, err := s.conn.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (s *journaldSink) Sync() error {
+	return nil
+}
+
+func (s *journaldSink) Close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,80 @@
+//go:build !windows
+// +build !windows
+
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyslogOutput(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	cfg := Config{Level: "info", Encoding: "json", MaxAge: 15, MaxSize: 1, MaxBackups: 15}
+	cfg.Output = "syslog"
+	cfg.Facility = "local0"
+	cfg.Tag = "baetyl"
+	cfg.OutputAddress = "udp://" + conn.LocalAddr().String()
+	logger, err := Init(cfg)
+	assert.NoError(t, err)
+	defer Init(Config{Level: "info", Encoding: "json", MaxAge: 15, MaxSize: 1, MaxBackups: 15})
+
+	logger.Warn("syslog-warn")
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	assert.NoError(t, err)
+	// the priority is local0 (16) * 8 + warning (4)
+	assert.Contains(t, string(buf[:n]), "<132>")
+	assert.Contains(t, string(buf[:n]), "baetyl[")
+	assert.Contains(t, string(buf[:n]), `"msg":"syslog-warn"`)
+
+	cfg.Facility = "xxx"
+	_, err = Init(cfg)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "syslog facility (xxx) is invalid")
+}
+
+func TestJournaldOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journald")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	socket := path.Join(dir, "journal.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	cfg := Config{Level: "info", Encoding: "console", MaxAge: 15, MaxSize: 1, MaxBackups: 15}
+	cfg.Output = "journald"
+	cfg.Facility = "daemon"
+	cfg.Tag = "baetyl"
+	cfg.OutputAddress = socket
+	logger, err := Init(cfg)
+	assert.NoError(t, err)
+	defer Init(Config{Level: "info", Encoding: "json", MaxAge: 15, MaxSize: 1, MaxBackups: 15})
+
+	logger.Error("journald-error")
+	buf := make([]byte, 65536)
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	n, err := conn.Read(buf)
+	assert.NoError(t, err)
+	data := buf[:n]
+	assert.True(t, bytes.HasPrefix(data, []byte("PRIORITY=3\nSYSLOG_FACILITY=3\nSYSLOG_IDENTIFIER=baetyl\nMESSAGE\n")))
+	msg := data[len("PRIORITY=3\nSYSLOG_FACILITY=3\nSYSLOG_IDENTIFIER=baetyl\nMESSAGE\n"):]
+	size := binary.LittleEndian.Uint64(msg)
+	assert.Equal(t, int(size)+9, len(msg))
+	assert.Contains(t, string(msg[8:8+size]), "journald-error")
+	assert.Contains(t, string(msg[8:8+size]), "\ntesting.tRunner") // the stacktrace of error in lines
+}
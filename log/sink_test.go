@@ -0,0 +1,80 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEntryLevel(t *testing.T) {
+	assert.Equal(t, WarnLevel, entryLevel([]byte(`{"level":"warn","ts":1,"msg":"m"}`), "json"))
+	assert.Equal(t, ErrorLevel, entryLevel([]byte("2020-01-01T00:00:00.000Z\terror\tmain.go:1\tm\n"), "console"))
+	assert.Equal(t, InfoLevel, entryLevel([]byte(`{"msg":"m"}`), "json"))
+	assert.Equal(t, InfoLevel, entryLevel([]byte("m"), "console"))
+	assert.Equal(t, 7, severity(DebugLevel))
+	assert.Equal(t, 2, severity(FatalLevel))
+
+	f, err := parseFacility("")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, f)
+	f, err = parseFacility("local7")
+	assert.NoError(t, err)
+	assert.Equal(t, 23, f)
+	_, err = parseFacility("xxx")
+	assert.EqualError(t, err, "syslog facility (xxx) is invalid")
+}
+
+type mockSink struct {
+	bytes.Buffer
+	sync.Mutex
+}
+
+func (s *mockSink) Write(p []byte) (int, error) {
+	s.Lock()
+	defer s.Unlock()
+	return s.Buffer.Write(p)
+}
+
+func (s *mockSink) Sync() error {
+	return nil
+}
+
+func (s *mockSink) Close() error {
+	return nil
+}
+
+func TestRegisterSink(t *testing.T) {
+	// the sinks are registered globally, so the scheme is unique to run repeatedly, such as by -count
+	scheme := fmt.Sprintf("mocksink%d", time.Now().UnixNano())
+	sink := &mockSink{}
+	var addr *url.URL
+	err := RegisterSink(scheme, func(u *url.URL) (Sink, error) {
+		addr = u
+		return sink, nil
+	})
+	require.NoError(t, err)
+	err = RegisterSink(scheme, func(u *url.URL) (Sink, error) { return sink, nil })
+	assert.Error(t, err)
+	err = RegisterSink("lumberjack", func(u *url.URL) (Sink, error) { return sink, nil })
+	assert.Error(t, err)
+	assert.False(t, customSink("/var/log/baetyl.log"))
+	assert.False(t, customSink("kafka://broker/topic"))
+	assert.True(t, customSink(scheme+"://broker/topic"))
+
+	logger, err := Init(Config{Level: "info", Encoding: "json", Filename: scheme + "://broker/topic"})
+	require.NoError(t, err)
+	defer Init(Config{Level: "info", Encoding: "json", MaxAge: 15, MaxSize: 1, MaxBackups: 15})
+	logger.Info("custom")
+	require.NotNil(t, addr)
+	assert.Equal(t, "broker", addr.Host)
+	assert.Equal(t, "/topic", addr.Path)
+	sink.Lock()
+	assert.Contains(t, sink.String(), `"msg":"custom"`)
+	sink.Unlock()
+}
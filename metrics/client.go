@@ -0,0 +1,40 @@
+package metrics
+
+import "time"
+
+// all protocols of the clients instrumented
+const (
+	ProtocolMQTT = "mqtt"
+	ProtocolLink = "link"
+)
+
+// all states of the messages of clients
+const (
+	StateSent    = "sent"
+	StateAcked   = "acked"
+	StateDropped = "dropped"
+)
+
+// the builtin metrics of the clients of mqtt and link, which are populated by the clients automatically
+var (
+	ClientConnections = NewGauge(Namespace+"_client_connections",
+		"The number of connections established by the clients.", "protocol")
+	ClientReconnectsTotal = NewCounter(Namespace+"_client_reconnects_total",
+		"The total number of reconnect attempts of the clients.", "protocol")
+	ClientMessagesTotal = NewCounter(Namespace+"_client_messages_total",
+		"The total number of messages sent, acked or dropped by the clients.", "protocol", "state")
+	ClientCacheMessages = NewGauge(Namespace+"_client_cache_messages",
+		"The number of messages in the caches of the clients waiting to be sent.", "protocol")
+	ClientCallDuration = NewHistogram(Namespace+"_client_call_duration_seconds",
+		"The duration of the calls of the clients, such as the publish of mqtt until acknowledged.", nil, "protocol")
+)
+
+// ObserveClientMessage counts a message of the client of the protocol in the state
+func ObserveClientMessage(protocol, state string) {
+	ClientMessagesTotal.Inc(protocol, state)
+}
+
+// ObserveClientCall observes the duration of a call of the client of the protocol since the start
+func ObserveClientCall(protocol string, start time.Time) {
+	ClientCallDuration.Observe(time.Since(start).Seconds(), protocol)
+}
@@ -0,0 +1,32 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/baetyl/baetyl-go/http"
+	"github.com/baetyl/baetyl-go/utils"
+)
+
+// all push modes
+const (
+	PushGateway     = "pushgateway"
+	PushRemoteWrite = "remotewrite"
+)
+
+// PushConfig the config to push metrics to a pushgateway or a prometheus remote write endpoint,
+// the path is /metrics/job/<job> by default for pushgateway, and /api/v1/write for remote write
+type PushConfig struct {
+	Mode     string            `yaml:"mode" json:"mode" default:"pushgateway" validate:"regexp=^(pushgateway|remotewrite)$"`
+	Path     string            `yaml:"path" json:"path"`
+	Job      string            `yaml:"job" json:"job" default:"baetyl"`
+	Labels   map[string]string `yaml:"labels" json:"labels"` // the grouping labels of pushgateway, or the extra labels of every series for remote write
+	Interval time.Duration     `yaml:"interval" json:"interval" default:"1m"`
+	Client   http.ClientConfig `yaml:",inline" json:",inline"`
+}
+
+// ServerConfig the config of the http server exposing the metrics of shared registry at the path
+type ServerConfig struct {
+	Address     string            `yaml:"address" json:"address" default:":9090"`
+	Path        string            `yaml:"path" json:"path" default:"/metrics"`
+	Certificate utils.Certificate `yaml:",inline" json:",inline"`
+}
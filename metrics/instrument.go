@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"context"
+	gohttp "net/http"
+	"strconv"
+	"time"
+
+	"github.com/baetyl/baetyl-go/http"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// all directions of messages
+const (
+	DirectionIn  = "in"
+	DirectionOut = "out"
+)
+
+// the builtin metrics shared by mqtt, link and http
+var (
+	MessagesTotal = NewCounter(Namespace+"_messages_total",
+		"The total number of messages received (in) or sent (out).", "protocol", "direction")
+	MessageErrorsTotal = NewCounter(Namespace+"_message_errors_total",
+		"The total number of errors occurred while receiving or sending messages.", "protocol")
+	HTTPRequestsTotal = NewCounter(Namespace+"_http_requests_total",
+		"The total number of http requests handled.", "method", "code")
+	HTTPRequestDuration = NewHistogram(Namespace+"_http_request_duration_seconds",
+		"The duration of http requests handled.", nil, "method")
+	GRPCRequestsTotal = NewCounter(Namespace+"_grpc_requests_total",
+		"The total number of grpc requests handled.", "method", "code")
+	GRPCRequestDuration = NewHistogram(Namespace+"_grpc_request_duration_seconds",
+		"The duration of grpc requests handled.", nil, "method")
+)
+
+// ObserveMessage counts a message of the protocol (such as mqtt and link) in the direction
+func ObserveMessage(protocol, direction string) {
+	MessagesTotal.Inc(protocol, direction)
+}
+
+// ObserveMessageError counts an error of the protocol
+func ObserveMessageError(protocol string) {
+	MessageErrorsTotal.Inc(protocol)
+}
+
+// InstrumentHTTP the http middleware which counts requests and observes their duration
+func InstrumentHTTP(next gohttp.Handler) gohttp.Handler {
+	return gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		start := time.Now()
+		rw := http.NewResponseWriter(w)
+		next.ServeHTTP(rw, r)
+		HTTPRequestsTotal.Inc(r.Method, strconv.Itoa(rw.Status()))
+		HTTPRequestDuration.Observe(time.Since(start).Seconds(), r.Method)
+	})
+}
+
+// UnaryServerInterceptor the grpc interceptor which counts unary requests and observes their duration
+func UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	res, err := handler(ctx, req)
+	GRPCRequestsTotal.Inc(info.FullMethod, status.Code(err).String())
+	GRPCRequestDuration.Observe(time.Since(start).Seconds(), info.FullMethod)
+	return res, err
+}
+
+// StreamServerInterceptor the grpc interceptor which counts streams and observes their duration
+func StreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	GRPCRequestsTotal.Inc(info.FullMethod, status.Code(err).String())
+	GRPCRequestDuration.Observe(time.Since(start).Seconds(), info.FullMethod)
+	return err
+}
@@ -0,0 +1,132 @@
+// Package metrics provides a small facade of prometheus (counters, gauges and histograms with labels)
+// backed by a shared registry, and the instrumentation for http, grpc (link) and mqtt
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Namespace the namespace of all builtin metrics
+const Namespace = "baetyl"
+
+var registry = prometheus.NewRegistry()
+
+func init() {
+	registry.MustRegister(prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+	registry.MustRegister(prometheus.NewGoCollector())
+}
+
+// Registry returns the shared registry, which has the process and go collectors registered
+func Registry() *prometheus.Registry {
+	return registry
+}
+
+// Handler returns the http handler which exposes the metrics of shared registry
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// Counter the counter with labels
+type Counter struct {
+	vec *prometheus.CounterVec
+}
+
+// NewCounter creates (or returns the registered) counter in shared registry, panics if the definition is invalid
+func NewCounter(name, help string, labels ...string) *Counter {
+	vec := prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help}, labels)
+	return &Counter{vec: register(vec).(*prometheus.CounterVec)}
+}
+
+// Inc increases the counter of the label values by 1
+func (c *Counter) Inc(values ...string) {
+	c.vec.WithLabelValues(values...).Inc()
+}
+
+// Add adds the value (must not be negative) to the counter of the label values
+func (c *Counter) Add(v float64, values ...string) {
+	c.vec.WithLabelValues(values...).Add(v)
+}
+
+// Value returns the current value of the counter of the label values
+func (c *Counter) Value(values ...string) float64 {
+	return write(c.vec.WithLabelValues(values...)).GetCounter().GetValue()
+}
+
+// Gauge the gauge with labels
+type Gauge struct {
+	vec *prometheus.GaugeVec
+}
+
+// NewGauge creates (or returns the registered) gauge in shared registry, panics if the definition is invalid
+func NewGauge(name, help string, labels ...string) *Gauge {
+	vec := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: name, Help: help}, labels)
+	return &Gauge{vec: register(vec).(*prometheus.GaugeVec)}
+}
+
+// Set sets the gauge of the label values
+func (g *Gauge) Set(v float64, values ...string) {
+	g.vec.WithLabelValues(values...).Set(v)
+}
+
+// Inc increases the gauge of the label values by 1
+func (g *Gauge) Inc(values ...string) {
+	g.vec.WithLabelValues(values...).Inc()
+}
+
+// Dec decreases the gauge of the label values by 1
+func (g *Gauge) Dec(values ...string) {
+	g.vec.WithLabelValues(values...).Dec()
+}
+
+// Add adds the value to the gauge of the label values
+func (g *Gauge) Add(v float64, values ...string) {
+	g.vec.WithLabelValues(values...).Add(v)
+}
+
+// Value returns the current value of the gauge of the label values
+func (g *Gauge) Value(values ...string) float64 {
+	return write(g.vec.WithLabelValues(values...)).GetGauge().GetValue()
+}
+
+// Histogram the histogram with labels
+type Histogram struct {
+	vec *prometheus.HistogramVec
+}
+
+// NewHistogram creates (or returns the registered) histogram in shared registry, the default buckets are used if buckets is nil,
+// panics if the definition is invalid
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	vec := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: name, Help: help, Buckets: buckets}, labels)
+	return &Histogram{vec: register(vec).(*prometheus.HistogramVec)}
+}
+
+// Observe adds an observation to the histogram of the label values
+func (h *Histogram) Observe(v float64, values ...string) {
+	h.vec.WithLabelValues(values...).Observe(v)
+}
+
+// Count returns the count of observations of the histogram of the label values
+func (h *Histogram) Count(values ...string) uint64 {
+	return write(h.vec.WithLabelValues(values...).(prometheus.Metric)).GetHistogram().GetSampleCount()
+}
+
+func write(m prometheus.Metric) *dto.Metric {
+	var res dto.Metric
+	m.Write(&res)
+	return &res
+}
+
+func register(c prometheus.Collector) prometheus.Collector {
+	err := registry.Register(c)
+	if err == nil {
+		return c
+	}
+	if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+		return are.ExistingCollector
+	}
+	panic(err)
+}
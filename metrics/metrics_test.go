@@ -0,0 +1,129 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func scrape(t *testing.T) string {
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	data, err := ioutil.ReadAll(w.Body)
+	assert.NoError(t, err)
+	return string(data)
+}
+
+// the metrics are registered globally, so the tests assert the deltas to run repeatedly, such as by -count
+func TestMetrics(t *testing.T) {
+	c := NewCounter("test_counter", "test counter", "k")
+	a, b := c.Value("a"), c.Value("b")
+	c.Inc("a")
+	c.Add(2, "a")
+	c.Inc("b")
+	assert.Equal(t, a+3, c.Value("a"))
+	assert.Equal(t, b+1, c.Value("b"))
+	// the registered one is returned
+	c2 := NewCounter("test_counter", "test counter", "k")
+	assert.Equal(t, c.vec, c2.vec)
+	assert.Panics(t, func() { NewGauge("test_counter", "test counter", "k") })
+
+	g := NewGauge("test_gauge", "test gauge")
+	g.Set(5)
+	g.Inc()
+	g.Dec()
+	g.Add(-2)
+	assert.Equal(t, 3.0, testutil.ToFloat64(g.vec.WithLabelValues()))
+
+	h := NewHistogram("test_histogram", "test histogram", []float64{1, 10})
+	n := h.Count()
+	h.Observe(0.5)
+	h.Observe(5)
+	assert.Equal(t, n+2, h.Count())
+
+	out := scrape(t)
+	assert.Contains(t, out, fmt.Sprintf(`test_counter{k="a"} %v`, a+3))
+	assert.Contains(t, out, `test_gauge 3`)
+	// half of the observations are in the bucket of 1
+	assert.Contains(t, out, fmt.Sprintf(`test_histogram_bucket{le="1"} %d`, (n+2)/2))
+	assert.Contains(t, out, fmt.Sprintf(`test_histogram_count %d`, n+2))
+	assert.Contains(t, out, "go_goroutines")
+	assert.Contains(t, out, "process_start_time_seconds")
+}
+
+func TestInstrument(t *testing.T) {
+	h := InstrumentHTTP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	requests := HTTPRequestsTotal.Value(http.MethodGet, "404")
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, requests+1, HTTPRequestsTotal.Value(http.MethodGet, "404"))
+	// the writer is still a hijacker, such as to upgrade the websocket connections
+	InstrumentHTTP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, ok := w.(http.Hijacker)
+		assert.True(t, ok)
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPut, "/", nil))
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/link.Link/Call"}
+	calls := GRPCRequestsTotal.Value("/link.Link/Call", "NotFound")
+	_, err := UnaryServerInterceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "not found")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, calls+1, GRPCRequestsTotal.Value("/link.Link/Call", "NotFound"))
+
+	sinfo := &grpc.StreamServerInfo{FullMethod: "/link.Link/Talk"}
+	talks := GRPCRequestsTotal.Value("/link.Link/Talk", "Unknown")
+	err = StreamServerInterceptor(nil, nil, sinfo, func(srv interface{}, ss grpc.ServerStream) error {
+		return errors.New("failed")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, talks+1, GRPCRequestsTotal.Value("/link.Link/Talk", "Unknown"))
+
+	msgs, errs := MessagesTotal.Value("mqtt", DirectionIn), MessageErrorsTotal.Value("mqtt")
+	ObserveMessage("mqtt", DirectionIn)
+	ObserveMessageError("mqtt")
+	assert.Equal(t, msgs+1, MessagesTotal.Value("mqtt", DirectionIn))
+	assert.Equal(t, errs+1, MessageErrorsTotal.Value("mqtt"))
+}
+
+func TestClientMetrics(t *testing.T) {
+	sent := ClientMessagesTotal.Value(ProtocolMQTT, StateSent)
+	ObserveClientMessage(ProtocolMQTT, StateSent)
+	assert.Equal(t, sent+1, ClientMessagesTotal.Value(ProtocolMQTT, StateSent))
+
+	ClientCacheMessages.Set(2, ProtocolLink)
+	ClientCacheMessages.Dec(ProtocolLink)
+	assert.Equal(t, 1.0, ClientCacheMessages.Value(ProtocolLink))
+
+	calls := ClientCallDuration.Count(ProtocolLink)
+	ObserveClientCall(ProtocolLink, time.Now().Add(-time.Second))
+	assert.Equal(t, calls+1, ClientCallDuration.Count(ProtocolLink))
+	assert.Contains(t, scrape(t), `baetyl_client_call_duration_seconds_bucket{protocol="link",le="1"} 0`)
+}
+
+func TestServer(t *testing.T) {
+	s, err := NewServer(ServerConfig{Address: "127.0.0.1:0", Path: "/metrics"})
+	assert.NoError(t, err)
+	defer s.Close()
+
+	res, err := http.Get("http://" + s.Addr().String() + "/metrics")
+	assert.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	data, err := ioutil.ReadAll(res.Body)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "go_goroutines")
+}
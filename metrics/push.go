@@ -0,0 +1,196 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	gohttp "net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/baetyl/baetyl-go/http"
+	"github.com/baetyl/baetyl-go/log"
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// Pusher pushes the metrics of shared registry periodically, since the edge node behind NAT can't be scraped
+type Pusher struct {
+	cfg      PushConfig
+	path     string
+	cli      *http.Client
+	gatherer prometheus.Gatherer
+	tomb     utils.Tomb
+	log      *log.Logger
+}
+
+// NewPusher creates a new pusher and starts pushing if the interval is positive
+func NewPusher(cfg PushConfig) (*Pusher, error) {
+	if cfg.Mode != PushGateway && cfg.Mode != PushRemoteWrite {
+		return nil, fmt.Errorf("push mode (%s) not supported", cfg.Mode)
+	}
+	cli, err := http.NewClient(cfg.Client)
+	if err != nil {
+		return nil, err
+	}
+	p := &Pusher{
+		cfg:      cfg,
+		path:     cfg.Path,
+		cli:      cli,
+		gatherer: registry,
+		log:      log.With(log.Any("metrics", "pusher")),
+	}
+	if p.path == "" {
+		p.path = p.defaultPath()
+	}
+	if cfg.Interval > 0 {
+		p.tomb.Go(p.pushing)
+	}
+	return p, nil
+}
+
+// Push gathers and pushes the metrics once
+func (p *Pusher) Push(ctx context.Context) error {
+	mfs, err := p.gatherer.Gather()
+	if err != nil {
+		return err
+	}
+	if p.cfg.Mode == PushRemoteWrite {
+		return p.write(ctx, mfs)
+	}
+	var buf bytes.Buffer
+	enc := expfmt.NewEncoder(&buf, expfmt.FmtText)
+	for _, mf := range mfs {
+		if err := enc.Encode(mf); err != nil {
+			return err
+		}
+	}
+	_, err = p.cli.CallContext(ctx, gohttp.MethodPut, p.path, buf.Bytes(), map[string]string{
+		http.HeaderContentType: string(expfmt.FmtText),
+	})
+	return err
+}
+
+// Close stops pushing
+func (p *Pusher) Close() error {
+	p.tomb.Kill(nil)
+	p.tomb.Wait()
+	return p.cli.Close()
+}
+
+func (p *Pusher) pushing() error {
+	t := time.NewTicker(p.cfg.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			ctx, cancel := context.WithTimeout(context.Background(), p.cfg.Client.Timeout)
+			err := p.Push(ctx)
+			cancel()
+			if err != nil {
+				p.log.Warn("failed to push metrics", log.Error(err))
+			}
+		case <-p.tomb.Dying():
+			return nil
+		}
+	}
+}
+
+func (p *Pusher) write(ctx context.Context, mfs []*dto.MetricFamily) error {
+	req := toWriteRequest(mfs, p.cfg.Labels, time.Now())
+	data, err := req.Marshal()
+	if err != nil {
+		return err
+	}
+	_, err = p.cli.CallContext(ctx, gohttp.MethodPost, p.path, snappy.Encode(nil, data), map[string]string{
+		http.HeaderContentType:              "application/x-protobuf",
+		"Content-Encoding":                  "snappy",
+		"X-Prometheus-Remote-Write-Version": "0.1.0",
+	})
+	return err
+}
+
+func (p *Pusher) defaultPath() string {
+	if p.cfg.Mode == PushRemoteWrite {
+		return "/api/v1/write"
+	}
+	path := "/metrics/job/" + url.PathEscape(p.cfg.Job)
+	for _, k := range sortedKeys(p.cfg.Labels) {
+		path += "/" + url.PathEscape(k) + "/" + url.PathEscape(p.cfg.Labels[k])
+	}
+	return path
+}
+
+// toWriteRequest converts the metric families to the time series of remote write,
+// the histograms and summaries are flattened as prometheus does
+func toWriteRequest(mfs []*dto.MetricFamily, extra map[string]string, now time.Time) *WriteRequest {
+	ts := now.UnixNano() / int64(time.Millisecond)
+	req := &WriteRequest{}
+	add := func(name string, m *dto.Metric, v float64, lns ...string) {
+		labels := []Label{{Name: "__name__", Value: name}}
+		for _, lp := range m.GetLabel() {
+			labels = append(labels, Label{Name: lp.GetName(), Value: lp.GetValue()})
+		}
+		for i := 0; i+1 < len(lns); i += 2 {
+			labels = append(labels, Label{Name: lns[i], Value: lns[i+1]})
+		}
+		for _, k := range sortedKeys(extra) {
+			labels = append(labels, Label{Name: k, Value: extra[k]})
+		}
+		t := ts
+		if m.TimestampMs != nil {
+			t = m.GetTimestampMs()
+		}
+		req.Timeseries = append(req.Timeseries, TimeSeries{
+			Labels:  labels,
+			Samples: []Sample{{Value: v, Timestamp: t}},
+		})
+	}
+	for _, mf := range mfs {
+		name := mf.GetName()
+		for _, m := range mf.GetMetric() {
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				add(name, m, m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				add(name, m, m.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				add(name, m, m.GetUntyped().GetValue())
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				for _, b := range h.GetBucket() {
+					add(name+"_bucket", m, float64(b.GetCumulativeCount()), "le", formatFloat(b.GetUpperBound()))
+				}
+				add(name+"_bucket", m, float64(h.GetSampleCount()), "le", "+Inf")
+				add(name+"_sum", m, h.GetSampleSum())
+				add(name+"_count", m, float64(h.GetSampleCount()))
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				for _, q := range s.GetQuantile() {
+					add(name, m, q.GetValue(), "quantile", formatFloat(q.GetQuantile()))
+				}
+				add(name+"_sum", m, s.GetSampleSum())
+				add(name+"_count", m, float64(s.GetSampleCount()))
+			}
+		}
+	}
+	return req
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
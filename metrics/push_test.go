@@ -0,0 +1,137 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	gohttp "net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+type request struct {
+	method string
+	path   string
+	header gohttp.Header
+	body   []byte
+}
+
+func newTestServer(t *testing.T) (*httptest.Server, chan *request) {
+	reqs := make(chan *request, 10)
+	ts := httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		assert.NoError(t, err)
+		reqs <- &request{method: r.Method, path: r.URL.EscapedPath(), header: r.Header, body: body}
+	}))
+	return ts, reqs
+}
+
+func newPushConfig(t *testing.T, address string) PushConfig {
+	var cfg PushConfig
+	assert.NoError(t, utils.SetDefaults(&cfg))
+	cfg.Client.Address = address
+	cfg.Interval = 0
+	return cfg
+}
+
+func TestPushGateway(t *testing.T) {
+	ts, reqs := newTestServer(t)
+	defer ts.Close()
+
+	c := NewCounter("test_push_counter", "test push counter")
+	c.Inc()
+
+	cfg := newPushConfig(t, ts.URL)
+	cfg.Labels = map[string]string{"node": "n1", "app": "a/b"}
+	p, err := NewPusher(cfg)
+	assert.NoError(t, err)
+	defer p.Close()
+
+	assert.NoError(t, p.Push(context.Background()))
+	req := <-reqs
+	assert.Equal(t, gohttp.MethodPut, req.method)
+	assert.Equal(t, "/metrics/job/baetyl/app/a%2Fb/node/n1", req.path)
+	assert.Contains(t, req.header.Get("Content-Type"), "text/plain")
+	assert.Contains(t, string(req.body), fmt.Sprintf("test_push_counter %v", c.Value()))
+	assert.Contains(t, string(req.body), "go_goroutines")
+
+	// push periodically
+	cfg.Interval = 10 * time.Millisecond
+	p2, err := NewPusher(cfg)
+	assert.NoError(t, err)
+	defer p2.Close()
+	select {
+	case req = <-reqs:
+		assert.Equal(t, gohttp.MethodPut, req.method)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "metrics not pushed")
+	}
+
+	cfg.Mode = "unknown"
+	_, err = NewPusher(cfg)
+	assert.EqualError(t, err, "push mode (unknown) not supported")
+}
+
+func TestPushRemoteWrite(t *testing.T) {
+	ts, reqs := newTestServer(t)
+	defer ts.Close()
+
+	NewGauge("test_push_gauge", "test push gauge", "k").Set(2, "v")
+
+	cfg := newPushConfig(t, ts.URL)
+	cfg.Mode = PushRemoteWrite
+	cfg.Labels = map[string]string{"node": "n1"}
+	p, err := NewPusher(cfg)
+	assert.NoError(t, err)
+	defer p.Close()
+
+	assert.NoError(t, p.Push(context.Background()))
+	req := <-reqs
+	assert.Equal(t, gohttp.MethodPost, req.method)
+	assert.Equal(t, "/api/v1/write", req.path)
+	assert.Equal(t, "snappy", req.header.Get("Content-Encoding"))
+	assert.Equal(t, "application/x-protobuf", req.header.Get("Content-Type"))
+	data, err := snappy.Decode(nil, req.body)
+	assert.NoError(t, err)
+	var wr WriteRequest
+	assert.NoError(t, wr.Unmarshal(data))
+	var found bool
+	for _, s := range wr.Timeseries {
+		if s.Labels[0].Value == "test_push_gauge" {
+			found = true
+			assert.Equal(t, []Label{{Name: "__name__", Value: "test_push_gauge"}, {Name: "k", Value: "v"}, {Name: "node", Value: "n1"}}, s.Labels)
+			assert.Equal(t, 2.0, s.Samples[0].Value)
+		}
+	}
+	assert.True(t, found)
+}
+
+func TestToWriteRequest(t *testing.T) {
+	r := prometheus.NewRegistry()
+	h := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "h", Help: "h", Buckets: []float64{0.5}})
+	s := prometheus.NewSummary(prometheus.SummaryOpts{Name: "s", Help: "s", Objectives: map[float64]float64{0.5: 0.05}})
+	r.MustRegister(h, s)
+	h.Observe(1)
+	s.Observe(1)
+	mfs, err := r.Gather()
+	assert.NoError(t, err)
+
+	now := time.Unix(1, 0)
+	req := toWriteRequest(mfs, nil, now)
+	var names []string
+	for _, ts := range req.Timeseries {
+		name := ts.Labels[0].Value
+		if len(ts.Labels) > 1 {
+			name += "{" + ts.Labels[1].Name + "=" + ts.Labels[1].Value + "}"
+		}
+		names = append(names, name)
+		assert.Equal(t, int64(1000), ts.Samples[0].Timestamp)
+	}
+	assert.Equal(t, []string{"h_bucket{le=0.5}", "h_bucket{le=+Inf}", "h_sum", "h_count", "s{quantile=0.5}", "s_sum", "s_count"}, names)
+}
@@ -0,0 +1,23 @@
+package metrics
+
+import (
+	"github.com/baetyl/baetyl-go/http"
+	"github.com/baetyl/baetyl-go/utils"
+)
+
+// NewServer creates the http server exposing the metrics of shared registry at the path, such as /metrics,
+// the other configs of the server are the defaults
+func NewServer(cfg ServerConfig) (*http.Server, error) {
+	var sc http.ServerConfig
+	if err := utils.SetDefaults(&sc); err != nil {
+		return nil, err
+	}
+	sc.Address = cfg.Address
+	sc.Certificate = cfg.Certificate
+	s, err := http.NewServer(sc)
+	if err != nil {
+		return nil, err
+	}
+	s.Handle(cfg.Path, Handler())
+	return s, nil
+}
@@ -0,0 +1,264 @@
+// Package mock provides in-memory test doubles, such as a minimal mqtt broker and a loopback link server,
+// so that the applications using this sdk can be tested without external infrastructure.
+package mock
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/baetyl/baetyl-go/auth"
+	"github.com/baetyl/baetyl-go/limit"
+	"github.com/baetyl/baetyl-go/log"
+	"github.com/baetyl/baetyl-go/mqtt"
+)
+
+// Broker the minimal mqtt broker which routes messages to subscribers (qos 0 and 1),
+// retained messages, will messages and persistent sessions are not supported.
+type Broker struct {
+	tp       *mqtt.Transport
+	subs     *mqtt.Trie
+	sessions map[*session]struct{}
+	pubs     []*mqtt.Publish
+	authn    auth.Authenticator
+	authz    auth.Authorizer
+	limiter  *limit.Limiter
+	mu       sync.Mutex
+	log      *log.Logger
+}
+
+type session struct {
+	id   string
+	p    *auth.Principal
+	conn mqtt.Connection
+	ids  *mqtt.Counter
+	subs map[string]*subscription
+	mu   sync.Mutex
+}
+
+type subscription struct {
+	s   *session
+	qos mqtt.QOS
+}
+
+// NewBroker creates and starts a new broker listening on a random local port
+func NewBroker() (*Broker, error) {
+	return NewBrokerWithAddress("tcp://127.0.0.1:0")
+}
+
+// NewBrokerWithAddress creates and starts a new broker listening on the address, such as tcp://127.0.0.1:1883
+func NewBrokerWithAddress(address string) (*Broker, error) {
+	b := &Broker{
+		subs:     mqtt.NewTrie(),
+		sessions: map[*session]struct{}{},
+		log:      log.With(log.Any("mock", "broker")),
+	}
+	tp, err := mqtt.NewTransport(mqtt.ServerConfig{Addresses: []string{address}}, b.handle)
+	if err != nil {
+		return nil, err
+	}
+	b.tp = tp
+	return b, nil
+}
+
+// Address returns the address of broker to connect, such as tcp://127.0.0.1:51883
+func (b *Broker) Address() string {
+	return "tcp://" + b.tp.GetServers()[0].Addr().String()
+}
+
+// SetAuth sets the authenticator to authenticate the connections and the authorizer to authorize
+// the publications and subscriptions, all are allowed by default
+func (b *Broker) SetAuth(a auth.Authenticator, z auth.Authorizer) {
+	b.mu.Lock()
+	b.authn, b.authz = a, z
+	b.mu.Unlock()
+}
+
+// SetLimiter sets the limiter to enforce the quotas of clients identified by client ids, no limit by default
+func (b *Broker) SetLimiter(l *limit.Limiter) {
+	b.mu.Lock()
+	b.limiter = l
+	b.mu.Unlock()
+}
+
+// Publish publishes a message to the subscribers as if it is published by a client
+func (b *Broker) Publish(qos mqtt.QOS, topic string, payload []byte) {
+	pkt := mqtt.NewPublish()
+	pkt.Message.QOS = qos
+	pkt.Message.Topic = topic
+	pkt.Message.Payload = payload
+	b.route(pkt)
+}
+
+// Published returns all messages published by clients so far
+func (b *Broker) Published() []*mqtt.Publish {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]*mqtt.Publish{}, b.pubs...)
+}
+
+// Close closes the broker and all connections
+func (b *Broker) Close() error {
+	err := b.tp.Close()
+	b.mu.Lock()
+	for s := range b.sessions {
+		s.conn.Close()
+	}
+	b.mu.Unlock()
+	return err
+}
+
+func (b *Broker) handle(conn mqtt.Connection) {
+	go func() {
+		s := &session{conn: conn, ids: mqtt.NewCounter(), subs: map[string]*subscription{}}
+		err := b.serve(s)
+		if err != nil {
+			b.log.Debug("connection is closed", log.Any("client", s.id), log.Error(err))
+		}
+		b.mu.Lock()
+		delete(b.sessions, s)
+		for t, sub := range s.subs {
+			b.subs.Remove(t, sub)
+		}
+		b.mu.Unlock()
+		s.conn.Close()
+	}()
+}
+
+func (b *Broker) serve(s *session) error {
+	pkt, err := s.conn.Receive()
+	if err != nil {
+		return err
+	}
+	c, ok := pkt.(*mqtt.Connect)
+	if !ok {
+		return errors.New("the first packet is not connect")
+	}
+	s.id = c.ClientID
+	b.mu.Lock()
+	authn, authz, limiter := b.authn, b.authz, b.limiter
+	b.mu.Unlock()
+	ack := mqtt.NewConnack()
+	ack.ReturnCode = mqtt.ConnectionAccepted
+	if authn != nil {
+		s.p, ack.ReturnCode = mqtt.Authenticate(authn, s.conn, c)
+	}
+	if limiter != nil && ack.ReturnCode == mqtt.ConnectionAccepted {
+		lc, err := mqtt.NewLimitedConnection(limiter, s.conn, s.id)
+		if err != nil {
+			ack.ReturnCode = mqtt.ServerUnavailable
+		} else {
+			s.conn = lc
+		}
+	}
+	if err = s.send(ack); err != nil {
+		return err
+	}
+	if ack.ReturnCode != mqtt.ConnectionAccepted {
+		return mqtt.ConnackError(ack.ReturnCode)
+	}
+	b.mu.Lock()
+	b.sessions[s] = struct{}{}
+	b.mu.Unlock()
+
+	for {
+		pkt, err = s.conn.Receive()
+		if err != nil {
+			return err
+		}
+		switch p := pkt.(type) {
+		case *mqtt.Publish:
+			if authz != nil && authz.Authorize(s.p, auth.ActionPublish, p.Message.Topic) != nil {
+				b.log.Debug("publication is forbidden", log.Any("client", s.id), log.Any("topic", p.Message.Topic))
+			} else {
+				b.mu.Lock()
+				b.pubs = append(b.pubs, p)
+				b.mu.Unlock()
+				b.route(p)
+			}
+			if p.Message.QOS == 1 {
+				ack := mqtt.NewPuback()
+				ack.ID = p.ID
+				err = s.send(ack)
+			}
+		case *mqtt.Subscribe:
+			ack := mqtt.NewSuback()
+			ack.ID = p.ID
+			b.mu.Lock()
+			for _, sub := range p.Subscriptions {
+				if authz != nil && authz.Authorize(s.p, auth.ActionSubscribe, sub.Topic) != nil {
+					ack.ReturnCodes = append(ack.ReturnCodes, mqtt.QOSFailure)
+					continue
+				}
+				qos := sub.QOS
+				if qos > 1 {
+					qos = 1
+				}
+				if old, ok := s.subs[sub.Topic]; ok {
+					b.subs.Remove(sub.Topic, old)
+				}
+				ns := &subscription{s: s, qos: qos}
+				s.subs[sub.Topic] = ns
+				b.subs.Add(sub.Topic, ns)
+				ack.ReturnCodes = append(ack.ReturnCodes, qos)
+			}
+			b.mu.Unlock()
+			err = s.send(ack)
+		case *mqtt.Unsubscribe:
+			b.mu.Lock()
+			for _, t := range p.Topics {
+				if old, ok := s.subs[t]; ok {
+					b.subs.Remove(t, old)
+					delete(s.subs, t)
+				}
+			}
+			b.mu.Unlock()
+			ack := mqtt.NewUnsuback()
+			ack.ID = p.ID
+			err = s.send(ack)
+		case *mqtt.Pingreq:
+			err = s.send(mqtt.NewPingresp())
+		case *mqtt.Disconnect:
+			return nil
+		case *mqtt.Puback:
+			// the messages are delivered at most once to subscribers, not resent
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// route delivers the message to all subscriptions matched, the qos is the lower one of message and subscription
+func (b *Broker) route(pkt *mqtt.Publish) {
+	b.mu.Lock()
+	matched := b.subs.Match(pkt.Message.Topic)
+	b.mu.Unlock()
+	// one delivery per session, with the highest qos of its subscriptions matched
+	targets := map[*session]mqtt.QOS{}
+	for _, v := range matched {
+		sub := v.(*subscription)
+		if q, ok := targets[sub.s]; !ok || sub.qos > q {
+			targets[sub.s] = sub.qos
+		}
+	}
+	for s, qos := range targets {
+		out := mqtt.NewPublish()
+		out.Message = pkt.Message
+		out.Message.Retain = false
+		if out.Message.QOS > qos {
+			out.Message.QOS = qos
+		}
+		if out.Message.QOS > 0 {
+			out.ID = s.ids.NextID()
+		}
+		if err := s.send(out); err != nil {
+			b.log.Debug("failed to deliver message", log.Any("client", s.id), log.Error(err))
+		}
+	}
+}
+
+func (s *session) send(pkt mqtt.Packet) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn.Send(pkt, false)
+}
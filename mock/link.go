@@ -0,0 +1,125 @@
+package mock
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/baetyl/baetyl-go/link"
+	"github.com/baetyl/baetyl-go/utils"
+	"google.golang.org/grpc"
+)
+
+// LinkServer the loopback link server, the messages sent over Talk are delivered back to all streams connected
+// (including the sender) and acknowledged if qos is 1, the requests of Call are served by the caller (echo by default)
+type LinkServer struct {
+	svr     *grpc.Server
+	lis     net.Listener
+	caller  link.Caller
+	streams map[link.Link_TalkServer]*sync.Mutex
+	msgs    []*link.Message
+	mu      sync.Mutex
+}
+
+// NewLinkServer creates and starts a new link server listening on a random local port, the caller is optional
+func NewLinkServer(caller link.Caller) (*LinkServer, error) {
+	if caller == nil {
+		caller = link.CallerFunc(func(_ context.Context, msg *link.Message) (*link.Message, error) {
+			return msg, nil
+		})
+	}
+	var cfg link.ServerConfig
+	if err := utils.SetDefaults(&cfg); err != nil {
+		return nil, err
+	}
+	svr, err := link.NewServer(cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &LinkServer{
+		svr:     svr,
+		lis:     lis,
+		caller:  caller,
+		streams: map[link.Link_TalkServer]*sync.Mutex{},
+	}
+	link.RegisterLinkServer(svr, s)
+	go svr.Serve(lis)
+	return s, nil
+}
+
+// Address returns the address of server to connect, such as 127.0.0.1:50061
+func (s *LinkServer) Address() string {
+	return s.lis.Addr().String()
+}
+
+// Received returns all messages received over Talk so far
+func (s *LinkServer) Received() []*link.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*link.Message{}, s.msgs...)
+}
+
+// Send sends the message to all streams connected as if it is sent by a client
+func (s *LinkServer) Send(msg *link.Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for stream, mu := range s.streams {
+		mu.Lock()
+		stream.Send(msg)
+		mu.Unlock()
+	}
+}
+
+// Call serves the request by the caller
+func (s *LinkServer) Call(ctx context.Context, msg *link.Message) (*link.Message, error) {
+	return s.caller.CallContext(ctx, msg)
+}
+
+// Talk delivers the messages back to all streams
+func (s *LinkServer) Talk(stream link.Link_TalkServer) error {
+	mu := &sync.Mutex{}
+	s.mu.Lock()
+	s.streams[stream] = mu
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.streams, stream)
+		s.mu.Unlock()
+	}()
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		if msg.Context.Type == link.Ack {
+			continue
+		}
+		s.mu.Lock()
+		s.msgs = append(s.msgs, msg)
+		s.mu.Unlock()
+		out := *msg
+		out.Context.Type = link.Msg
+		s.Send(&out)
+		if msg.Context.QOS == 1 {
+			ack := &link.Message{}
+			ack.Context.ID = msg.Context.ID
+			ack.Context.Type = link.Ack
+			mu.Lock()
+			err = stream.Send(ack)
+			mu.Unlock()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Close stops the server
+func (s *LinkServer) Close() error {
+	s.svr.Stop()
+	return nil
+}
@@ -0,0 +1,268 @@
+package mock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/baetyl/baetyl-go/auth"
+	"github.com/baetyl/baetyl-go/limit"
+	"github.com/baetyl/baetyl-go/link"
+	"github.com/baetyl/baetyl-go/mqtt"
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+type mqttObserver struct {
+	pubs chan *mqtt.Publish
+	acks chan *mqtt.Puback
+	errs chan error
+}
+
+func newMQTTObserver() *mqttObserver {
+	return &mqttObserver{pubs: make(chan *mqtt.Publish, 10), acks: make(chan *mqtt.Puback, 10), errs: make(chan error, 10)}
+}
+
+func (o *mqttObserver) OnPublish(pkt *mqtt.Publish) error {
+	o.pubs <- pkt
+	return nil
+}
+
+func (o *mqttObserver) OnPuback(pkt *mqtt.Puback) error {
+	o.acks <- pkt
+	return nil
+}
+
+func (o *mqttObserver) OnError(err error) {
+	select {
+	case o.errs <- err:
+	default:
+	}
+}
+
+func (o *mqttObserver) assertPublish(t *testing.T, qos mqtt.QOS, topic, payload string) {
+	select {
+	case pkt := <-o.pubs:
+		assert.Equal(t, qos, pkt.Message.QOS)
+		assert.Equal(t, topic, pkt.Message.Topic)
+		assert.Equal(t, payload, string(pkt.Message.Payload))
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not received")
+	}
+}
+
+func newMQTTClient(t *testing.T, b *Broker, id string, obs mqtt.Observer) *mqtt.Client {
+	return newMQTTClientWithPassword(t, b, id, "", "", obs)
+}
+
+func newMQTTClientWithPassword(t *testing.T, b *Broker, id, username, password string, obs mqtt.Observer) *mqtt.Client {
+	var cc mqtt.ClientConfig
+	assert.NoError(t, utils.SetDefaults(&cc))
+	cc.Address = b.Address()
+	cc.ClientID = id
+	cc.Username = username
+	cc.Password = password
+	cc.CleanSession = true
+	cli, err := mqtt.NewClient(cc, obs)
+	assert.NoError(t, err)
+	return cli
+}
+
+func TestBroker(t *testing.T) {
+	b, err := NewBroker()
+	assert.NoError(t, err)
+	defer b.Close()
+
+	obs1 := newMQTTObserver()
+	sub := newMQTTClient(t, b, "sub", obs1)
+	defer sub.Close()
+	assert.NoError(t, sub.Subscribe([]mqtt.Subscription{{Topic: "a/+", QOS: 1}, {Topic: "b", QOS: 0}}))
+
+	obs2 := newMQTTObserver()
+	pub := newMQTTClient(t, b, "pub", obs2)
+	defer pub.Close()
+
+	// the subscription is established after suback, retries until delivered
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		b.Publish(0, "a/ready", nil)
+		select {
+		case <-obs1.pubs:
+		case <-time.After(50 * time.Millisecond):
+			if time.Now().Before(deadline) {
+				continue
+			}
+			t.Fatal("subscription not established")
+		}
+		break
+	}
+
+	assert.NoError(t, pub.Publish(1, "a/1", []byte("x"), 1, false, false))
+	obs1.assertPublish(t, 1, "a/1", "x")
+	select {
+	case ack := <-obs2.acks:
+		assert.Equal(t, mqtt.ID(1), ack.ID)
+	case <-time.After(5 * time.Second):
+		t.Fatal("puback not received")
+	}
+
+	// qos is downgraded to the subscription
+	assert.NoError(t, pub.Publish(1, "b", []byte("y"), 2, false, false))
+	obs1.assertPublish(t, 0, "b", "y")
+	assert.NoError(t, pub.Publish(0, "c", []byte("z"), 0, false, false))
+	b.Publish(0, "a/2", []byte("w"))
+	obs1.assertPublish(t, 0, "a/2", "w")
+	select {
+	case pkt := <-obs1.pubs:
+		t.Fatalf("unexpected message: %v", pkt)
+	default:
+	}
+	assert.Len(t, b.Published(), 3)
+}
+
+func TestBrokerAuth(t *testing.T) {
+	b, err := NewBroker()
+	assert.NoError(t, err)
+	defer b.Close()
+	b.SetAuth(auth.NewPasswords(map[string]string{"u1": "p1"}), auth.NewACL([]auth.Rule{{Principal: "u1", Permissions: []auth.Permission{
+		{Action: auth.ActionPublish, Resources: []string{"a/#"}},
+		{Action: auth.ActionSubscribe, Resources: []string{"a/#"}},
+	}}}))
+
+	obs1 := newMQTTObserver()
+	bad := newMQTTClientWithPassword(t, b, "bad", "u1", "p2", obs1)
+	defer bad.Close()
+	select {
+	case err := <-obs1.errs:
+		assert.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection is not rejected")
+	}
+
+	obs2 := newMQTTObserver()
+	cli := newMQTTClientWithPassword(t, b, "good", "u1", "p1", obs2)
+	defer cli.Close()
+	assert.NoError(t, cli.Subscribe([]mqtt.Subscription{{Topic: "b", QOS: 0}}))
+	select {
+	case err := <-obs2.errs:
+		assert.Equal(t, mqtt.ErrClientSubscriptionFailed, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscription is not rejected")
+	}
+
+	assert.NoError(t, cli.Publish(1, "c", []byte("x"), 1, false, false))
+	assert.NoError(t, cli.Publish(1, "a/1", []byte("y"), 2, false, false))
+	for i := 0; i < 2; i++ {
+		select {
+		case <-obs2.acks:
+		case <-time.After(5 * time.Second):
+			t.Fatal("puback not received")
+		}
+	}
+	pubs := b.Published()
+	assert.Len(t, pubs, 1)
+	assert.Equal(t, "a/1", pubs[0].Message.Topic)
+}
+
+func TestBrokerLimit(t *testing.T) {
+	b, err := NewBroker()
+	assert.NoError(t, err)
+	defer b.Close()
+	b.SetLimiter(limit.NewLimiter(limit.Config{MaxConnections: 1, MaxPayloadSize: 2}))
+
+	obs1 := newMQTTObserver()
+	cli := newMQTTClient(t, b, "c1", obs1)
+	defer cli.Close()
+	assert.NoError(t, cli.Publish(1, "a", []byte("x"), 1, false, false))
+	select {
+	case <-obs1.acks:
+	case <-time.After(5 * time.Second):
+		t.Fatal("puback not received")
+	}
+
+	obs2 := newMQTTObserver()
+	cli2 := newMQTTClient(t, b, "c2", obs2)
+	defer cli2.Close()
+	select {
+	case err := <-obs2.errs:
+		assert.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection is not rejected")
+	}
+
+	// the connection is closed if the payload is too large
+	assert.NoError(t, cli.Publish(0, "a", []byte("xyz"), 0, false, false))
+	select {
+	case err := <-obs1.errs:
+		assert.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection is not closed")
+	}
+	assert.Len(t, b.Published(), 1)
+}
+
+type linkObserver struct {
+	msgs chan *link.Message
+	acks chan *link.Message
+}
+
+func (o *linkObserver) OnMsg(msg *link.Message) error {
+	o.msgs <- msg
+	return nil
+}
+
+func (o *linkObserver) OnAck(msg *link.Message) error {
+	o.acks <- msg
+	return nil
+}
+
+func (o *linkObserver) OnErr(error) {}
+
+func TestLinkServer(t *testing.T) {
+	s, err := NewLinkServer(nil)
+	assert.NoError(t, err)
+	defer s.Close()
+
+	var cc link.ClientConfig
+	assert.NoError(t, utils.SetDefaults(&cc))
+	cc.Address = s.Address()
+	obs := &linkObserver{msgs: make(chan *link.Message, 10), acks: make(chan *link.Message, 10)}
+	cli, err := link.NewClient(cc, obs)
+	assert.NoError(t, err)
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	msg := &link.Message{Content: []byte("hi")}
+	msg.Context.Topic = "t"
+	res, err := cli.CallContext(ctx, msg)
+	assert.NoError(t, err)
+	assert.Equal(t, "hi", string(res.Content))
+
+	msg.Context.ID = 1
+	msg.Context.QOS = 1
+	assert.NoError(t, cli.SendContext(ctx, msg))
+	select {
+	case m := <-obs.msgs:
+		assert.Equal(t, "t", m.Context.Topic)
+		assert.Equal(t, "hi", string(m.Content))
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not received")
+	}
+	select {
+	case ack := <-obs.acks:
+		assert.Equal(t, uint64(1), ack.Context.ID)
+	case <-time.After(5 * time.Second):
+		t.Fatal("ack not received")
+	}
+	assert.Len(t, s.Received(), 1)
+
+	out := &link.Message{Content: []byte("push")}
+	s.Send(out)
+	select {
+	case m := <-obs.msgs:
+		assert.Equal(t, "push", string(m.Content))
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not received")
+	}
+}
@@ -0,0 +1,29 @@
+package mqtt
+
+import "github.com/baetyl/baetyl-go/auth"
+
+// NewCredentials extracts the credentials from the connect packet and the client certificate of connection,
+// the password is also taken as the token so that the clients can connect with jwt
+func NewCredentials(conn Connection, pkt *Connect) *auth.Credentials {
+	c := &auth.Credentials{
+		Username: pkt.Username,
+		Password: pkt.Password,
+		Token:    pkt.Password,
+	}
+	if state, ok := getTLSState(conn); ok && len(state.VerifiedChains) > 0 {
+		c.Certificates = state.VerifiedChains[0]
+	}
+	return c
+}
+
+// Authenticate authenticates the connect packet by the authenticator, returns the connack code to reply
+func Authenticate(a auth.Authenticator, conn Connection, pkt *Connect) (*auth.Principal, ConnackCode) {
+	p, err := a.Authenticate(NewCredentials(conn, pkt))
+	if err != nil {
+		if pkt.Username == "" && pkt.Password == "" {
+			return nil, NotAuthorized
+		}
+		return nil, BadUsernameOrPassword
+	}
+	return p, ConnectionAccepted
+}
@@ -0,0 +1,159 @@
+package mqtt
+
+import (
+	"strings"
+
+	"github.com/baetyl/baetyl-go/errors"
+	"github.com/baetyl/baetyl-go/log"
+)
+
+// the directions of bridge rules
+const (
+	BridgeOut = "out" // from the local broker to the remote broker
+	BridgeIn  = "in"  // from the remote broker to the local broker
+)
+
+// BridgeConfig the config of bridge between the local broker and the remote broker
+type BridgeConfig struct {
+	Local  ClientConfig `yaml:"local" json:"local"`
+	Remote ClientConfig `yaml:"remote" json:"remote"`
+	Rules  []BridgeRule `yaml:"rules" json:"rules"`
+}
+
+// BridgeRule the rule to forward the messages matched by the topic filter from the source broker to the target broker,
+// the topic is remapped by replacing the prefix, and the qos is downgraded to the qos of rule if higher
+type BridgeRule struct {
+	Direction  string `yaml:"direction" json:"direction" default:"out" validate:"regexp=^(in|out)?$"` // out if empty
+	Topic      string `yaml:"topic" json:"topic" validate:"nonzero"`                                  // the topic filter subscribed from the source broker
+	QOS        QOS    `yaml:"qos" json:"qos" validate:"min=0, max=2"`                                 // the qos subscribed and the max qos forwarded
+	TrimPrefix string `yaml:"trimPrefix" json:"trimPrefix"`                                           // the prefix removed from the topic if matched
+	AddPrefix  string `yaml:"addPrefix" json:"addPrefix"`                                             // the prefix added to the topic after trimmed
+}
+
+// target returns the topic forwarded to the target broker
+func (r *BridgeRule) target(topic string) string {
+	return r.AddPrefix + strings.TrimPrefix(topic, r.TrimPrefix)
+}
+
+// Bridge forwards the messages between the local broker and the remote broker by the rules in both directions,
+// such as syncing the messages of edge to cloud. The subscriptions are replayed once either side reconnects, and the
+// message of qos 1 or 2 is acknowledged to the source only after it is queued to the target client, so that it is
+// forwarded at least once if the target client persists its session. The rules in both directions must not overlap,
+// otherwise the messages are forwarded back and forth.
+type Bridge struct {
+	cfg    BridgeConfig
+	local  *Client
+	remote *Client
+	log    *log.Logger
+}
+
+// NewBridge creates a new bridge, which connects to both brokers and subscribes the topic filters of rules
+func NewBridge(cfg BridgeConfig) (*Bridge, error) {
+	// the rules of the same source and topic filter are handled by one handler
+	outs, ins := map[string][]BridgeRule{}, map[string][]BridgeRule{}
+	for _, r := range cfg.Rules {
+		if !CheckTopic(r.Topic, true) {
+			return nil, errors.Coded(errors.CodeInvalidArgument, "topic filter (%s) of bridge rule is invalid", r.Topic)
+		}
+		if r.QOS > QOSExactlyOnce {
+			return nil, errors.Coded(errors.CodeInvalidArgument, "qos (%d) of bridge rule is invalid", r.QOS)
+		}
+		switch r.Direction {
+		case "", BridgeOut:
+			outs[r.Topic] = append(outs[r.Topic], r)
+		case BridgeIn:
+			ins[r.Topic] = append(ins[r.Topic], r)
+		default:
+			return nil, errors.Coded(errors.CodeInvalidArgument, "direction (%s) of bridge rule is invalid", r.Direction)
+		}
+	}
+
+	b := &Bridge{
+		cfg: cfg,
+		log: log.With(log.Any("mqtt", "bridge")),
+	}
+	var err error
+	b.remote, err = NewClient(cfg.Remote, b.observer("remote"))
+	if err != nil {
+		return nil, err
+	}
+	b.local, err = NewClient(cfg.Local, b.observer("local"))
+	if err != nil {
+		b.remote.Close()
+		return nil, err
+	}
+	if err = b.route(b.local, b.remote, outs); err != nil {
+		b.Close()
+		return nil, err
+	}
+	if err = b.route(b.remote, b.local, ins); err != nil {
+		b.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+// route handles and subscribes the topic filters of rules at the source, the messages matched are sent to the target
+func (b *Bridge) route(source, target *Client, rules map[string][]BridgeRule) error {
+	var subs []Subscription
+	for filter, rs := range rules {
+		rs := rs
+		err := source.Handle(filter, func(p *Publish) error {
+			for i := range rs {
+				if err := b.forward(target, &rs[i], p); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		qos := QOSAtMostOnce
+		for _, r := range rs {
+			if r.QOS > qos {
+				qos = r.QOS
+			}
+		}
+		subs = append(subs, Subscription{Topic: filter, QOS: qos})
+	}
+	if len(subs) == 0 {
+		return nil
+	}
+	return source.Subscribe(subs)
+}
+
+// forward sends the message to the target with the topic remapped and the qos downgraded,
+// the error is returned to the source client so that the message is not acknowledged
+func (b *Bridge) forward(target *Client, r *BridgeRule, p *Publish) error {
+	qos := p.Message.QOS
+	if qos > r.QOS {
+		qos = r.QOS
+	}
+	topic := r.target(p.Message.Topic)
+	err := target.Publish(qos, topic, p.Message.Payload, 0, p.Message.Retain, false)
+	if err != nil {
+		b.log.Warn("failed to forward message", log.Any("topic", p.Message.Topic), log.Any("target", topic), log.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (b *Bridge) observer(side string) Observer {
+	logger := b.log.With(log.Any("side", side))
+	return NewObserverWrapper(nil, nil, func(err error) {
+		logger.Warn("bridge client reports an error", log.Error(err))
+	})
+}
+
+// Close closes the clients of both sides
+func (b *Bridge) Close() error {
+	b.log.Info("bridge is closing")
+	defer b.log.Info("bridge has closed")
+
+	err := b.local.Close()
+	if e := b.remote.Close(); err == nil {
+		err = e
+	}
+	return err
+}
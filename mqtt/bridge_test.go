@@ -0,0 +1,109 @@
+package mqtt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// waitSubscriptions waits until the client has the number of subscriptions in the broker
+func waitSubscriptions(t *testing.T, b *Broker, id string, n int) {
+	for i := 0; i < 100; i++ {
+		b.mu.Lock()
+		s, ok := b.sessions[id]
+		b.mu.Unlock()
+		if ok {
+			s.mu.Lock()
+			l := len(s.subs)
+			s.mu.Unlock()
+			if l == n {
+				return
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("subscriptions of client (%s) not expected (%d)", id, n)
+}
+
+func TestBridge(t *testing.T) {
+	local := newTestBroker(t, StoreConfig{Driver: "memory"})
+	defer local.Close()
+	remote := newTestBroker(t, StoreConfig{Driver: "memory"})
+	defer remote.Close()
+
+	var cfg BridgeConfig
+	assert.NoError(t, utils.SetDefaults(&cfg))
+	cfg.Local.Address = "tcp://" + local.Addresses()[0]
+	cfg.Local.ClientID = "bridge"
+	cfg.Remote.Address = "tcp://" + remote.Addresses()[0]
+	cfg.Remote.ClientID = "bridge"
+
+	cfg.Rules = []BridgeRule{{Topic: "a/#/b"}}
+	_, err := NewBridge(cfg)
+	assert.EqualError(t, err, "topic filter (a/#/b) of bridge rule is invalid")
+	cfg.Rules = []BridgeRule{{Topic: "a", Direction: "both"}}
+	_, err = NewBridge(cfg)
+	assert.EqualError(t, err, "direction (both) of bridge rule is invalid")
+
+	cfg.Rules = []BridgeRule{
+		{Topic: "edge/#", QOS: 1, TrimPrefix: "edge/", AddPrefix: "cloud/node1/"},
+		{Topic: "edge/#", Direction: BridgeOut, AddPrefix: "backup/"},
+		{Topic: "cloud/node1/cmd/#", Direction: BridgeIn, QOS: 1, TrimPrefix: "cloud/node1/"},
+	}
+	br, err := NewBridge(cfg)
+	assert.NoError(t, err)
+	defer br.Close()
+	waitSubscriptions(t, local, "bridge", 1)
+	waitSubscriptions(t, remote, "bridge", 1)
+
+	obsRemote := newMockObserver(t)
+	cliRemote := newTestBrokerClient(t, remote, "r1", true, obsRemote)
+	defer cliRemote.Close()
+	subscribeSync(t, cliRemote, Subscription{Topic: "cloud/#", QOS: 1}, Subscription{Topic: "backup/#", QOS: 1})
+	obsLocal := newMockObserver(t)
+	cliLocal := newTestBrokerClient(t, local, "l1", true, obsLocal)
+	defer cliLocal.Close()
+	subscribeSync(t, cliLocal, Subscription{Topic: "cmd/#", QOS: 1})
+
+	// out with the topic remapped, and the qos downgraded by the second rule
+	assert.NoError(t, local.Publish(&Message{Topic: "edge/t1", Payload: []byte("up"), QOS: 1}))
+	got := map[string]QOS{}
+	for i := 0; i < 2; i++ {
+		p := receivePublish(t, obsRemote)
+		assert.Equal(t, "up", string(p.Message.Payload))
+		got[p.Message.Topic] = p.Message.QOS
+	}
+	assert.Equal(t, map[string]QOS{"cloud/node1/t1": 1, "backup/edge/t1": 0}, got)
+
+	// in
+	assert.NoError(t, remote.Publish(&Message{Topic: "cloud/node1/cmd/reboot", Payload: []byte("down"), QOS: 1}))
+	p := receivePublish(t, obsLocal)
+	assert.Equal(t, "cmd/reboot", p.Message.Topic)
+	assert.Equal(t, "down", string(p.Message.Payload))
+	assert.Equal(t, QOS(1), p.Message.QOS)
+
+	// survives the restart of remote broker
+	addr := remote.Addresses()[0]
+	assert.NoError(t, remote.Close())
+	var rc BrokerConfig
+	assert.NoError(t, utils.SetDefaults(&rc))
+	rc.Server.Addresses = []string{"tcp://" + addr}
+	rc.Store = StoreConfig{Driver: "memory"}
+	remote, err = NewBroker(rc)
+	assert.NoError(t, err)
+	defer remote.Close()
+	waitSubscriptions(t, remote, "bridge", 1)
+	cliRemote2 := newTestBrokerClient(t, remote, "r2", true, obsRemote)
+	defer cliRemote2.Close()
+	subscribeSync(t, cliRemote2, Subscription{Topic: "cloud/#", QOS: 1})
+	assert.NoError(t, local.Publish(&Message{Topic: "edge/t2", Payload: []byte("again"), QOS: 1}))
+	for {
+		p = receivePublish(t, obsRemote)
+		if p.Message.Topic == "cloud/node1/t2" {
+			break
+		}
+	}
+	assert.Equal(t, "again", string(p.Message.Payload))
+}
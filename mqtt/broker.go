@@ -0,0 +1,562 @@
+package mqtt
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/baetyl/baetyl-go/auth"
+	"github.com/baetyl/baetyl-go/errors"
+	"github.com/baetyl/baetyl-go/limit"
+	"github.com/baetyl/baetyl-go/log"
+)
+
+// Broker the embedded mqtt broker for the messaging between local modules, which accepts the clients over the
+// listeners of transport (tcp, ssl, ws and wss), keeps the sessions, routes the messages by the topic tree, and
+// keeps the retained messages. The messages are delivered to subscribers with qos 0 or 1, the publications
+// of qos 2 are accepted and routed exactly once. The sessions not clean and the retained messages are persisted
+// in the store, so that the messages of qos 1 published while the clients are offline are delivered after
+// reconnection, even across restarts.
+type Broker struct {
+	cfg      BrokerConfig
+	tp       *Transport
+	store    Store
+	sessions map[string]*brokerSession
+	subs     *TopicTree // the subscriptions of sessions, including the shared ones
+	retained *Trie      // the retained messages of topics
+	authn    auth.Authenticator
+	authz    auth.Authorizer
+	limiter  *limit.Limiter
+	ids      uint64 // the sequence of the client ids assigned
+	mu       sync.Mutex
+	log      *log.Logger
+}
+
+type brokerSession struct {
+	id    string
+	clean bool
+	conn  Connection // nil if the client is offline
+	p     *auth.Principal
+	ids   *Counter
+	subs  map[string]*brokerSubscription
+	seqs  map[ID]uint64 // the sequences in store of the qos 1 messages sent
+	will  *Message
+	mu    sync.Mutex
+}
+
+type brokerSubscription struct {
+	s   *brokerSession
+	qos QOS
+}
+
+// NewBroker creates and starts a new broker, the sessions and the retained messages are loaded from the store
+func NewBroker(cfg BrokerConfig) (*Broker, error) {
+	store, err := NewStore(cfg.Store)
+	if err != nil {
+		return nil, err
+	}
+	b := &Broker{
+		cfg:      cfg,
+		store:    store,
+		sessions: map[string]*brokerSession{},
+		subs:     NewTopicTree(),
+		retained: NewTrie(),
+		log:      log.With(log.Any("mqtt", "broker")),
+	}
+	if err = b.load(); err != nil {
+		store.Close()
+		return nil, err
+	}
+	b.tp, err = NewTransport(cfg.Server, b.handle)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+// load restores the sessions not clean and the retained messages from the store
+func (b *Broker) load() error {
+	ss, err := b.store.ListSessions()
+	if err != nil {
+		return err
+	}
+	for _, v := range ss {
+		s := newBrokerSession(v.ClientID, false)
+		for _, sub := range v.Subscriptions {
+			bs := &brokerSubscription{s: s, qos: sub.QOS}
+			s.subs[sub.Topic] = bs
+			b.subs.Add(sub.Topic, bs)
+		}
+		b.sessions[v.ClientID] = s
+	}
+	ms, err := b.store.ListRetained()
+	if err != nil {
+		return err
+	}
+	for _, m := range ms {
+		b.retained.Set(m.Topic, m)
+	}
+	b.log.Info("broker loads the store", log.Any("sessions", len(ss)), log.Any("retained", len(ms)))
+	return nil
+}
+
+func newBrokerSession(id string, clean bool) *brokerSession {
+	return &brokerSession{
+		id:    id,
+		clean: clean,
+		ids:   NewCounter(),
+		subs:  map[string]*brokerSubscription{},
+		seqs:  map[ID]uint64{},
+	}
+}
+
+// Addresses returns the addresses of the listeners, such as 127.0.0.1:1883
+func (b *Broker) Addresses() []string {
+	var res []string
+	for _, svr := range b.tp.GetServers() {
+		res = append(res, svr.Addr().String())
+	}
+	return res
+}
+
+// SetAuth sets the authenticator to authenticate the connections and the authorizer to authorize
+// the publications and subscriptions, all are allowed if not set
+func (b *Broker) SetAuth(a auth.Authenticator, z auth.Authorizer) {
+	b.mu.Lock()
+	b.authn, b.authz = a, z
+	b.mu.Unlock()
+}
+
+// SetLimiter sets the limiter to enforce the quotas of clients identified by client ids, no limit if not set
+func (b *Broker) SetLimiter(l *limit.Limiter) {
+	b.mu.Lock()
+	b.limiter = l
+	b.mu.Unlock()
+}
+
+// Publish publishes the message to the subscribers as if it is published by a client
+func (b *Broker) Publish(m *Message) error {
+	if !CheckTopic(m.Topic, false) {
+		return errors.Coded(errors.CodeInvalidArgument, "topic (%s) is invalid", m.Topic)
+	}
+	b.publish(m)
+	return nil
+}
+
+// Close closes the listeners, all connections and the store
+func (b *Broker) Close() error {
+	b.log.Info("broker is closing")
+	defer b.log.Info("broker has closed")
+
+	err := b.tp.Close()
+	b.mu.Lock()
+	for _, s := range b.sessions {
+		s.mu.Lock()
+		if s.conn != nil {
+			s.conn.Close()
+		}
+		s.mu.Unlock()
+	}
+	b.mu.Unlock()
+	if e := b.store.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+func (b *Broker) handle(conn Connection) {
+	go func() {
+		err := b.serve(conn)
+		if err != nil {
+			b.log.Debug("connection is closed", log.Any("remote", conn.RemoteAddr().String()), log.Error(err))
+		}
+		conn.Close()
+	}()
+}
+
+func (b *Broker) serve(conn Connection) error {
+	conn.SetReadTimeout(b.cfg.ConnectTimeout)
+	pkt, err := conn.Receive()
+	if err != nil {
+		return err
+	}
+	c, ok := pkt.(*Connect)
+	if !ok {
+		return errors.Coded(errors.CodeInvalidArgument, "the first packet (%v) is not connect", pkt)
+	}
+
+	b.mu.Lock()
+	authn, authz, limiter := b.authn, b.authz, b.limiter
+	b.mu.Unlock()
+	var p *auth.Principal
+	code := ConnectionAccepted
+	if c.ClientID == "" {
+		if c.CleanSession {
+			c.ClientID = "baetyl-" + strconv.FormatUint(atomic.AddUint64(&b.ids, 1), 10) + "-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+		} else {
+			code = IdentifierRejected
+		}
+	}
+	if authn != nil && code == ConnectionAccepted {
+		p, code = Authenticate(authn, conn, c)
+	}
+	if limiter != nil && code == ConnectionAccepted {
+		lc, err := NewLimitedConnection(limiter, conn, c.ClientID)
+		if err != nil {
+			code = ServerUnavailable
+		} else {
+			conn = lc
+		}
+	}
+	if code != ConnectionAccepted {
+		ack := NewConnack()
+		ack.ReturnCode = code
+		if err = conn.Send(ack, false); err != nil {
+			return err
+		}
+		return ConnackError(code)
+	}
+
+	s, err := b.attach(conn, c, p)
+	if err != nil {
+		return err
+	}
+	// the connection is closed if nothing received in 1.5 times of keep alive
+	conn.SetReadTimeout(time.Duration(c.KeepAlive) * 1500 * time.Millisecond)
+	err = b.serving(s, conn, authz)
+	b.detach(s, conn, err == nil)
+	return err
+}
+
+// attach binds the connection to the session of the client, replaces the connection of the session if exists,
+// sends the connack and then the messages queued while the client is offline
+func (b *Broker) attach(conn Connection, c *Connect, p *auth.Principal) (*brokerSession, error) {
+	b.mu.Lock()
+	s, present := b.sessions[c.ClientID]
+	if present {
+		s.mu.Lock()
+		if s.conn != nil {
+			b.log.Info("client is taken over", log.Any("client", c.ClientID))
+			s.conn.Close()
+		}
+		s.mu.Unlock()
+	}
+	if c.CleanSession || !present || s.clean {
+		if present {
+			b.unsubscribeAll(s)
+		}
+		if present && !s.clean || c.CleanSession {
+			if err := b.store.DeleteSession(c.ClientID); err != nil {
+				b.mu.Unlock()
+				return nil, err
+			}
+		}
+		s, present = newBrokerSession(c.ClientID, c.CleanSession), false
+	}
+	b.sessions[c.ClientID] = s
+	b.mu.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// the sequences of the messages sent over the replaced connection are kept, so that a late puback
+	// over it still removes the message, instead of the message redelivered after every reconnection
+	s.conn, s.p, s.will = conn, p, c.Will
+	if !s.clean {
+		if err := b.persist(s); err != nil {
+			return nil, err
+		}
+	}
+	ack := NewConnack()
+	ack.SessionPresent = present
+	if err := conn.Send(ack, false); err != nil {
+		return nil, err
+	}
+	if s.clean {
+		return s, nil
+	}
+	queued, err := b.store.Queued(s.id)
+	if err != nil {
+		return nil, err
+	}
+	for _, sm := range queued {
+		if err := s.send(&sm.Message, sm.Seq); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// detach unbinds the connection from the session, publishes the will message if the client is not disconnected
+// gracefully, and deletes the session if clean
+func (b *Broker) detach(s *brokerSession, conn Connection, graceful bool) {
+	s.mu.Lock()
+	if s.conn != conn {
+		// taken over by another connection
+		s.mu.Unlock()
+		return
+	}
+	s.conn = nil
+	will := s.will
+	s.will = nil
+	s.mu.Unlock()
+
+	if will != nil && !graceful {
+		b.publish(will)
+	}
+	if s.clean {
+		b.mu.Lock()
+		if b.sessions[s.id] == s {
+			delete(b.sessions, s.id)
+		}
+		b.unsubscribeAll(s)
+		b.mu.Unlock()
+	}
+}
+
+// serving handles the packets of the client, returns nil if disconnected gracefully
+func (b *Broker) serving(s *brokerSession, conn Connection, authz auth.Authorizer) error {
+	// the ids of qos 2 publications received but not released
+	received := map[ID]struct{}{}
+	for {
+		pkt, err := conn.Receive()
+		if err != nil {
+			return err
+		}
+		switch p := pkt.(type) {
+		case *Publish:
+			if !CheckTopic(p.Message.Topic, false) {
+				return errors.Coded(errors.CodeInvalidArgument, "topic (%s) of publication is invalid", p.Message.Topic)
+			}
+			handle := func() {
+				if authz != nil && authz.Authorize(s.p, auth.ActionPublish, p.Message.Topic) != nil {
+					b.log.Debug("publication is forbidden", log.Any("client", s.id), log.Any("topic", p.Message.Topic))
+					return
+				}
+				b.publish(&p.Message)
+			}
+			switch p.Message.QOS {
+			case QOSAtMostOnce:
+				handle()
+			case QOSAtLeastOnce:
+				handle()
+				err = s.reply(conn, &Puback{ID: p.ID})
+			case QOSExactlyOnce:
+				if _, ok := received[p.ID]; !ok {
+					handle()
+					received[p.ID] = struct{}{}
+				}
+				err = s.reply(conn, &Pubrec{ID: p.ID})
+			default:
+				return errors.Coded(errors.CodeInvalidArgument, "qos (%d) of publication is invalid", p.Message.QOS)
+			}
+		case *Pubrel:
+			delete(received, p.ID)
+			err = s.reply(conn, &Pubcomp{ID: p.ID})
+		case *Puback:
+			err = b.ack(s, p.ID)
+		case *Subscribe:
+			err = b.subscribe(s, conn, p, authz)
+		case *Unsubscribe:
+			err = b.unsubscribe(s, conn, p)
+		case *Pingreq:
+			err = s.reply(conn, NewPingresp())
+		case *Disconnect:
+			return nil
+		default:
+			return errors.Coded(errors.CodeInvalidArgument, "packet (%v) not supported", p)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (b *Broker) subscribe(s *brokerSession, conn Connection, p *Subscribe, authz auth.Authorizer) error {
+	ack := NewSuback()
+	ack.ID = p.ID
+	var granted []Subscription
+	b.mu.Lock()
+	s.mu.Lock()
+	for _, sub := range p.Subscriptions {
+		// the topic filter of shared subscription is authorized without the prefix
+		_, filter, _ := ParseSharedFilter(sub.Topic)
+		if authz != nil && authz.Authorize(s.p, auth.ActionSubscribe, filter) != nil {
+			ack.ReturnCodes = append(ack.ReturnCodes, QOSFailure)
+			continue
+		}
+		// the messages are delivered to subscribers at most qos 1
+		qos := sub.QOS
+		if qos > QOSAtLeastOnce {
+			qos = QOSAtLeastOnce
+		}
+		bs := &brokerSubscription{s: s, qos: qos}
+		if b.subs.Add(sub.Topic, bs) != nil {
+			ack.ReturnCodes = append(ack.ReturnCodes, QOSFailure)
+			continue
+		}
+		if old, ok := s.subs[sub.Topic]; ok {
+			b.subs.Remove(sub.Topic, old)
+		}
+		s.subs[sub.Topic] = bs
+		ack.ReturnCodes = append(ack.ReturnCodes, qos)
+		granted = append(granted, Subscription{Topic: sub.Topic, QOS: qos})
+	}
+	b.mu.Unlock()
+	var err error
+	if !s.clean {
+		err = b.persist(s)
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err = s.reply(conn, ack); err != nil {
+		return err
+	}
+
+	// the retained messages matched are delivered after the suback
+	for _, sub := range granted {
+		b.mu.Lock()
+		ms := b.retained.Search(sub.Topic)
+		b.mu.Unlock()
+		for _, v := range ms {
+			if err = b.deliver(s, v.(*Message), sub.QOS, true); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (b *Broker) unsubscribe(s *brokerSession, conn Connection, p *Unsubscribe) error {
+	b.mu.Lock()
+	s.mu.Lock()
+	for _, t := range p.Topics {
+		if old, ok := s.subs[t]; ok {
+			b.subs.Remove(t, old)
+			delete(s.subs, t)
+		}
+	}
+	b.mu.Unlock()
+	var err error
+	if !s.clean {
+		err = b.persist(s)
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	ack := NewUnsuback()
+	ack.ID = p.ID
+	return s.reply(conn, ack)
+}
+
+// unsubscribeAll removes all subscriptions of the session from the topic tree, the lock of broker is held
+func (b *Broker) unsubscribeAll(s *brokerSession) {
+	for t, sub := range s.subs {
+		b.subs.Remove(t, sub)
+	}
+}
+
+// ack removes the qos 1 message acknowledged from the queue of the session
+func (b *Broker) ack(s *brokerSession, id ID) error {
+	s.mu.Lock()
+	seq, ok := s.seqs[id]
+	delete(s.seqs, id)
+	s.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return b.store.Ack(s.id, seq)
+}
+
+// persist stores the session, the lock of session is held
+func (b *Broker) persist(s *brokerSession) error {
+	v := &Session{ClientID: s.id, Will: s.will}
+	for t, sub := range s.subs {
+		v.Subscriptions = append(v.Subscriptions, Subscription{Topic: t, QOS: sub.qos})
+	}
+	return b.store.PutSession(v)
+}
+
+// publish keeps the message if retained, and routes it to the subscribers
+func (b *Broker) publish(m *Message) {
+	if m.Retain {
+		if err := b.store.PutRetained(m); err != nil {
+			b.log.Error("failed to store retained message", log.Any("topic", m.Topic), log.Error(err))
+		}
+		b.mu.Lock()
+		if len(m.Payload) == 0 {
+			b.retained.Empty(m.Topic)
+		} else {
+			b.retained.Set(m.Topic, copyMessage(m))
+		}
+		b.mu.Unlock()
+	}
+
+	b.mu.Lock()
+	matched := b.subs.Match(m.Topic)
+	b.mu.Unlock()
+	// one delivery per session, with the highest qos of its subscriptions matched
+	targets := map[*brokerSession]QOS{}
+	for _, v := range matched {
+		sub := v.(*brokerSubscription)
+		if q, ok := targets[sub.s]; !ok || sub.qos > q {
+			targets[sub.s] = sub.qos
+		}
+	}
+	for s, qos := range targets {
+		if err := b.deliver(s, m, qos, false); err != nil {
+			b.log.Debug("failed to deliver message", log.Any("client", s.id), log.Error(err))
+		}
+	}
+}
+
+// deliver sends the message to the session with the lower qos of message and subscription, the message of qos 1
+// is queued in the store for the session not clean until acknowledged, even if the client is offline
+func (b *Broker) deliver(s *brokerSession, m *Message, qos QOS, retain bool) error {
+	out := *m
+	out.Retain = retain
+	if out.QOS > qos {
+		out.QOS = qos
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var seq uint64
+	if out.QOS > QOSAtMostOnce && !s.clean {
+		var err error
+		seq, err = b.store.Enqueue(s.id, &out)
+		if err != nil {
+			return err
+		}
+	}
+	if s.conn == nil {
+		return nil
+	}
+	return s.send(&out, seq)
+}
+
+// send sends the message with a new packet id if qos 1, the lock of session is held
+func (s *brokerSession) send(m *Message, seq uint64) error {
+	pkt := NewPublish()
+	pkt.Message = *m
+	if m.QOS > QOSAtMostOnce {
+		pkt.ID = s.ids.NextID()
+		if seq > 0 {
+			s.seqs[pkt.ID] = seq
+		}
+	}
+	return s.conn.Send(pkt, false)
+}
+
+// reply sends the packet over the connection unless it is taken over by another connection
+func (s *brokerSession) reply(conn Connection, pkt Packet) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn != conn {
+		return ErrClientNotConnected
+	}
+	return conn.Send(pkt, false)
+}
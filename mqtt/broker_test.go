@@ -0,0 +1,290 @@
+package mqtt
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/256dpi/gomqtt/transport"
+	"github.com/baetyl/baetyl-go/auth"
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestBroker(t *testing.T, store StoreConfig) *Broker {
+	var cfg BrokerConfig
+	assert.NoError(t, utils.SetDefaults(&cfg))
+	cfg.Server.Addresses = []string{"tcp://127.0.0.1:0"}
+	cfg.Store = store
+	b, err := NewBroker(cfg)
+	assert.NoError(t, err)
+	return b
+}
+
+func newTestBrokerClient(t *testing.T, b *Broker, id string, clean bool, obs Observer) *Client {
+	var cc ClientConfig
+	assert.NoError(t, utils.SetDefaults(&cc))
+	cc.Address = "tcp://" + b.Addresses()[0]
+	cc.ClientID = id
+	cc.CleanSession = clean
+	cli, err := NewClient(cc, obs)
+	assert.NoError(t, err)
+	return cli
+}
+
+func receivePublish(t *testing.T, obs *mockObserver) *Publish {
+	for {
+		select {
+		case pkt := <-obs.pkts:
+			if p, ok := pkt.(*Publish); ok {
+				return p
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("message not received")
+			return nil
+		}
+	}
+}
+
+func assertNoPublish(t *testing.T, obs *mockObserver) {
+	select {
+	case pkt := <-obs.pkts:
+		if p, ok := pkt.(*Publish); ok {
+			t.Fatalf("unexpected message %v", p)
+		}
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func dialTestBroker(t *testing.T, b *Broker, connect *Connect) (Connection, Packet) {
+	conn, err := transport.Dial("tcp://" + b.Addresses()[0])
+	assert.NoError(t, err)
+	assert.NoError(t, conn.Send(connect, false))
+	pkt, err := conn.Receive()
+	assert.NoError(t, err)
+	return conn, pkt
+}
+
+func subscribeSync(t *testing.T, cli *Client, subs ...Subscription) {
+	assert.NoError(t, cli.Subscribe(subs))
+	// waits for the suback by a round trip of qos 1 publication
+	assert.NoError(t, cli.PublishSync(context.Background(), 1, "sync", nil))
+}
+
+func TestBroker(t *testing.T) {
+	b := newTestBroker(t, StoreConfig{Driver: "memory"})
+	defer b.Close()
+
+	// retained before subscribed
+	assert.NoError(t, b.Publish(&Message{Topic: "r/1", Payload: []byte("retained"), QOS: 1, Retain: true}))
+	assert.NoError(t, b.Publish(&Message{Topic: "r/2", Payload: []byte("deleted"), Retain: true}))
+	assert.NoError(t, b.Publish(&Message{Topic: "r/2", Retain: true}))
+	assert.Error(t, b.Publish(&Message{Topic: "r/#"}))
+
+	obs1 := newMockObserver(t)
+	sub := newTestBrokerClient(t, b, "sub", true, obs1)
+	defer sub.Close()
+	subscribeSync(t, sub, Subscription{Topic: "a/+", QOS: 1}, Subscription{Topic: "a/0", QOS: 0}, Subscription{Topic: "r/#", QOS: 1})
+	p := receivePublish(t, obs1)
+	assert.Equal(t, "r/1", p.Message.Topic)
+	assert.True(t, p.Message.Retain)
+	assert.Equal(t, QOS(1), p.Message.QOS)
+
+	obs2 := newMockObserver(t)
+	pub := newTestBrokerClient(t, b, "", true, obs2)
+	defer pub.Close()
+	assert.NoError(t, pub.PublishSync(context.Background(), 1, "a/1", []byte("qos1")))
+	p = receivePublish(t, obs1)
+	assert.Equal(t, "a/1", p.Message.Topic)
+	assert.Equal(t, "qos1", string(p.Message.Payload))
+	assert.Equal(t, QOS(1), p.Message.QOS)
+	assert.False(t, p.Message.Retain)
+
+	// the highest qos of subscriptions matched, and at most qos 1
+	assert.NoError(t, pub.PublishSync(context.Background(), 2, "a/0", []byte("qos2")))
+	p = receivePublish(t, obs1)
+	assert.Equal(t, "a/0", p.Message.Topic)
+	assert.Equal(t, QOS(1), p.Message.QOS)
+	assertNoPublish(t, obs1)
+
+	assert.NoError(t, sub.Unsubscribe([]string{"a/+"}))
+	assert.NoError(t, sub.PublishSync(context.Background(), 1, "sync", nil))
+	assert.NoError(t, pub.PublishSync(context.Background(), 1, "a/0", []byte("qos0")))
+	p = receivePublish(t, obs1)
+	assert.Equal(t, QOS(0), p.Message.QOS)
+	assert.NoError(t, pub.PublishSync(context.Background(), 1, "a/1", []byte("unsubscribed")))
+	assertNoPublish(t, obs1)
+
+	// the will message is published if the connection is lost
+	connect := NewConnect()
+	connect.ClientID = "will"
+	connect.Will = &Message{Topic: "a/0", Payload: []byte("bye"), QOS: 1}
+	conn, pkt := dialTestBroker(t, b, connect)
+	assert.Equal(t, &Connack{}, pkt)
+	// the invalid topic filter is rejected
+	assert.NoError(t, conn.Send(&Subscribe{ID: 1, Subscriptions: []Subscription{{Topic: "a/#/b"}, {Topic: "a/2", QOS: 2}}}, false))
+	pkt, err := conn.Receive()
+	assert.NoError(t, err)
+	assert.Equal(t, &Suback{ID: 1, ReturnCodes: []QOS{QOSFailure, 1}}, pkt)
+	assert.NoError(t, conn.Close())
+	p = receivePublish(t, obs1)
+	assert.Equal(t, "a/0", p.Message.Topic)
+	assert.Equal(t, "bye", string(p.Message.Payload))
+}
+
+func TestBrokerAuth(t *testing.T) {
+	b := newTestBroker(t, StoreConfig{Driver: "memory"})
+	defer b.Close()
+	b.SetAuth(auth.NewPasswords(map[string]string{"u1": "p1"}), auth.NewACL([]auth.Rule{{Principal: "u1", Permissions: []auth.Permission{
+		{Action: auth.ActionPublish, Resources: []string{"a/#"}},
+		{Action: auth.ActionSubscribe, Resources: []string{"a/#"}},
+	}}}))
+
+	connect := NewConnect()
+	connect.Username = "u1"
+	connect.Password = "p2"
+	conn, pkt := dialTestBroker(t, b, connect)
+	assert.Equal(t, &Connack{ReturnCode: BadUsernameOrPassword}, pkt)
+	conn.Close()
+
+	connect.Password = "p1"
+	conn, pkt = dialTestBroker(t, b, connect)
+	assert.Equal(t, &Connack{}, pkt)
+	assert.NoError(t, conn.Send(&Subscribe{ID: 1, Subscriptions: []Subscription{{Topic: "a/1", QOS: 1}, {Topic: "b/1", QOS: 1}}}, false))
+	pkt, err := conn.Receive()
+	assert.NoError(t, err)
+	assert.Equal(t, &Suback{ID: 1, ReturnCodes: []QOS{1, QOSFailure}}, pkt)
+	conn.Close()
+
+	var cc ClientConfig
+	assert.NoError(t, utils.SetDefaults(&cc))
+	cc.Address = "tcp://" + b.Addresses()[0]
+	cc.Username = "u1"
+	cc.Password = "p1"
+	cc.CleanSession = true
+	obs := newMockObserver(t)
+	cli, err := NewClient(cc, obs)
+	assert.NoError(t, err)
+	defer cli.Close()
+	subscribeSync(t, cli, Subscription{Topic: "a/1", QOS: 1})
+	// the forbidden publication is acknowledged but dropped
+	assert.NoError(t, cli.PublishSync(context.Background(), 1, "b/1", []byte("forbidden")))
+	assert.NoError(t, cli.PublishSync(context.Background(), 1, "a/1", []byte("allowed")))
+	p := receivePublish(t, obs)
+	assert.Equal(t, "allowed", string(p.Message.Payload))
+}
+
+func TestBrokerSession(t *testing.T) {
+	dir, err := ioutil.TempDir("", "broker")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	store := StoreConfig{Driver: "boltdb", Path: filepath.Join(dir, "store"), Timeout: time.Second}
+
+	b := newTestBroker(t, store)
+	obs := newMockObserver(t)
+	cli := newTestBrokerClient(t, b, "c1", false, obs)
+	subscribeSync(t, cli, Subscription{Topic: "s/#", QOS: 1})
+	assert.NoError(t, cli.Close())
+
+	// queued while offline, and delivered after the broker restarts
+	assert.NoError(t, b.Publish(&Message{Topic: "s/1", Payload: []byte("offline0"), QOS: 0}))
+	assert.NoError(t, b.Publish(&Message{Topic: "s/1", Payload: []byte("offline1"), QOS: 1}))
+	assert.NoError(t, b.Close())
+	b = newTestBroker(t, store)
+	defer b.Close()
+	assert.NoError(t, b.Publish(&Message{Topic: "s/2", Payload: []byte("offline2"), QOS: 1}))
+
+	obs = newMockObserver(t)
+	cli = newTestBrokerClient(t, b, "c1", false, obs)
+	assert.Equal(t, "offline1", string(receivePublish(t, obs).Message.Payload))
+	assert.Equal(t, "offline2", string(receivePublish(t, obs).Message.Payload))
+	assert.NoError(t, b.Publish(&Message{Topic: "s/3", Payload: []byte("online"), QOS: 1}))
+	assert.Equal(t, "online", string(receivePublish(t, obs).Message.Payload))
+
+	assert.NoError(t, cli.Close())
+
+	// taken over by another connection of the same client id, the old connection is closed
+	// and its will is not published
+	obs2 := newMockObserver(t)
+	watcher := newTestBrokerClient(t, b, "watcher", true, obs2)
+	defer watcher.Close()
+	subscribeSync(t, watcher, Subscription{Topic: "will/#", QOS: 1})
+	connect := NewConnect()
+	connect.ClientID = "c1"
+	connect.CleanSession = false
+	connect.Will = &Message{Topic: "will/c1", Payload: []byte("bye"), QOS: 1}
+	conn1, pkt := dialTestBroker(t, b, connect)
+	assert.Equal(t, &Connack{SessionPresent: true}, pkt)
+	connect.Will = nil
+	conn2, pkt := dialTestBroker(t, b, connect)
+	assert.Equal(t, &Connack{SessionPresent: true}, pkt)
+	conn1.SetReadTimeout(5 * time.Second)
+	start := time.Now()
+	for {
+		// the message not acknowledged yet may be redelivered before closed
+		if _, err = conn1.Receive(); err != nil {
+			break
+		}
+	}
+	assert.True(t, time.Since(start) < 5*time.Second, "the old connection is not closed")
+	assertNoPublish(t, obs2)
+
+	// the session is kept by the new connection
+	assert.NoError(t, b.Publish(&Message{Topic: "s/4", Payload: []byte("takeover"), QOS: 1}))
+	conn2.SetReadTimeout(5 * time.Second)
+	for {
+		pkt, err = conn2.Receive()
+		if !assert.NoError(t, err) {
+			break
+		}
+		p, ok := pkt.(*Publish)
+		if !assert.True(t, ok) {
+			break
+		}
+		assert.NoError(t, conn2.Send(&Puback{ID: p.ID}, false))
+		if string(p.Message.Payload) == "takeover" {
+			break
+		}
+	}
+	assert.NoError(t, conn2.Send(NewDisconnect(), false))
+	conn2.Close()
+
+	// the session is discarded if clean
+	obs3 := newMockObserver(t)
+	cli3 := newTestBrokerClient(t, b, "c1", true, obs3)
+	defer cli3.Close()
+	assert.NoError(t, cli3.PublishSync(context.Background(), 1, "s/5", []byte("discarded")))
+	assertNoPublish(t, obs3)
+	ss, err := b.store.ListSessions()
+	assert.NoError(t, err)
+	assert.Empty(t, ss)
+	queued, err := b.store.Queued("c1")
+	assert.NoError(t, err)
+	assert.Empty(t, queued)
+}
+
+func TestBrokerSharedSubscription(t *testing.T) {
+	b := newTestBroker(t, StoreConfig{Driver: "memory"})
+	defer b.Close()
+
+	obs1 := newMockObserver(t)
+	cli1 := newTestBrokerClient(t, b, "c1", true, obs1)
+	defer cli1.Close()
+	subscribeSync(t, cli1, Subscription{Topic: "$share/g/s/+", QOS: 1})
+	obs2 := newMockObserver(t)
+	cli2 := newTestBrokerClient(t, b, "c2", true, obs2)
+	defer cli2.Close()
+	subscribeSync(t, cli2, Subscription{Topic: "$share/g/s/+", QOS: 1})
+
+	// each message is delivered to one member of the group
+	assert.NoError(t, b.Publish(&Message{Topic: "s/1", Payload: []byte("m1"), QOS: 1}))
+	assert.NoError(t, b.Publish(&Message{Topic: "s/1", Payload: []byte("m2"), QOS: 1}))
+	p1 := receivePublish(t, obs1)
+	p2 := receivePublish(t, obs2)
+	assert.ElementsMatch(t, []string{"m1", "m2"}, []string{string(p1.Message.Payload), string(p2.Message.Payload)})
+	assertNoPublish(t, obs1)
+	assertNoPublish(t, obs2)
+}
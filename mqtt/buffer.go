@@ -0,0 +1,29 @@
+package mqtt
+
+import (
+	"bytes"
+	"sync"
+)
+
+// the buffers grown larger are not put back to the pool, so that the pool does not pin the memory of large packets
+const maxPooledBufferSize = 64 * 1024
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// getBuffer gets an empty buffer from the pool, which must be put back by putBuffer once not used
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+// putBuffer puts the buffer back to the pool, the bytes of the buffer must not be referenced after put
+func putBuffer(b *bytes.Buffer) {
+	if b.Cap() > maxPooledBufferSize {
+		return
+	}
+	b.Reset()
+	bufferPool.Put(b)
+}
@@ -1,59 +1,194 @@
 package mqtt
 
 import (
+	"context"
 	"crypto/tls"
-	"fmt"
+	"sync"
 	"time"
 
+	"github.com/baetyl/baetyl-go/errors"
+	"github.com/baetyl/baetyl-go/limit"
 	"github.com/baetyl/baetyl-go/log"
+	"github.com/baetyl/baetyl-go/metrics"
 	"github.com/baetyl/baetyl-go/utils"
-	"github.com/jpillora/backoff"
 )
 
+// the server rejects mqtt 5.0, the client falls back to mqtt 3.1.1
+var errFallbackVersion = errors.New("mqtt 5.0 not supported by server")
+
 // Client auto reconnection client
 type Client struct {
-	cfg   ClientConfig
-	obs   Observer
-	tls   *tls.Config
-	ids   *Counter
-	cache chan Packet
-	log   *log.Logger
-	tomb  utils.Tomb
+	cfg       ClientConfig
+	version   byte // the protocol version, changed to 3.1.1 if the server rejects mqtt 5.0
+	obs       Observer
+	router    *Router
+	tls       *tls.Config
+	certs     *utils.CertWatcher // reloads the certificate rotated, nil if not watched
+	enc       *Encryptor
+	store     ClientStore
+	ids       *Counter
+	inflights *inflights
+	waiters   *waiters
+	window    *window               // the messages inflight, nil if unlimited
+	limiter   *limit.Limiter        // the rate of messages sent, nil if unlimited
+	subs      []Subscription        // the active subscriptions, replayed after reconnection
+	subbing   map[ID][]Subscription // the subscriptions waiting for suback, dropped if rejected
+	subsMu    sync.Mutex
+	connected bool // the client has connected once, only accessed by the connecting task
+	cache     chan Packet
+	log       *log.Logger
+	sup       *utils.Supervisor
 }
 
-// NewClient creates a new client
+// NewClient creates a new client, the session is persisted if the path of store is configured
 func NewClient(cc ClientConfig, obs Observer) (*Client, error) {
+	store, err := NewClientStore(cc.Store)
+	if err != nil {
+		return nil, err
+	}
+	c, err := NewClientWithStore(cc, obs, store)
+	if err != nil && store != nil {
+		store.Close()
+	}
+	return c, err
+}
+
+// NewClientWithStore creates a new client with the store of session, which is closed when the client is closed,
+// the session is not persisted if the store is nil
+func NewClientWithStore(cc ClientConfig, obs Observer, store ClientStore) (*Client, error) {
+	version := byte(cc.ProtocolVersion)
+	switch version {
+	case 0:
+		version = Version311
+	case Version31, Version311, Version5:
+	default:
+		return nil, errors.Coded(errors.CodeInvalidArgument, "protocol version (%d) not supported", version)
+	}
+	if cc.Will != nil && (!CheckTopic(cc.Will.Topic, false) || cc.Will.QOS > QOSExactlyOnce) {
+		return nil, errors.Coded(errors.CodeInvalidArgument, "will (topic=%s, qos=%d) is invalid", cc.Will.Topic, cc.Will.QOS)
+	}
+	if err := checkFlowControl(cc.FlowControl); err != nil {
+		return nil, err
+	}
 	var err error
+	var enc *Encryptor
+	if len(cc.Encryption.Keys) > 0 {
+		enc, err = NewEncryptor(cc.Encryption)
+		if err != nil {
+			return nil, err
+		}
+	}
 	var tc *tls.Config
+	var certs *utils.CertWatcher
 	if cc.Certificate.Key != "" || cc.Certificate.Cert != "" {
-		tc, err = utils.NewTLSConfigClient(cc.Certificate)
+		if cc.Certificate.WatchInterval > 0 {
+			certs, err = utils.NewCertWatcher(cc.Certificate)
+		} else {
+			tc, err = utils.NewTLSConfigClient(cc.Certificate)
+		}
 		if err != nil {
 			return nil, err
 		}
 	}
 	c := &Client{
-		cfg:   cc,
-		obs:   obs,
-		tls:   tc,
-		ids:   NewCounter(),
-		cache: make(chan Packet, cc.BufferSize),
-		log:   log.With(log.Any("mqtt", "client"), log.Any("cid", cc.ClientID)),
-	}
-	c.tomb.Go(c.connecting)
+		cfg:       cc,
+		version:   version,
+		obs:       obs,
+		router:    NewRouter(),
+		subbing:   map[ID][]Subscription{},
+		tls:       tc,
+		certs:     certs,
+		enc:       enc,
+		store:     store,
+		ids:       NewCounter(),
+		inflights: newInflights(),
+		waiters:   newWaiters(),
+		window:    newWindow(cc.FlowControl.MaxInflight),
+		limiter:   newFlowLimiter(cc.FlowControl),
+		cache:     make(chan Packet, cc.BufferSize),
+		log:       log.With(log.Any("mqtt", "client"), log.Any("cid", cc.ClientID)),
+	}
+	if store != nil {
+		if err = c.loadSession(); err != nil {
+			if certs != nil {
+				certs.Close()
+			}
+			return nil, err
+		}
+	}
+	c.sup = utils.NewSupervisor(c.log)
+	c.sup.Go("connecting", utils.RestartPolicy{MinBackoff: time.Second, MaxBackoff: cc.Interval}, c.connecting())
 	return c, nil
 }
 
-// Subscribe sends a subscribe packet
+// Subscribe sends a subscribe packet, the subscriptions are replayed after reconnection unless disabled
 func (c *Client) Subscribe(s []Subscription) error {
 	subscribe := &Subscribe{
 		ID:            c.ids.NextID(),
 		Subscriptions: s,
 	}
+	c.trackSubscriptions(subscribe.ID, s)
 	return c.Send(subscribe)
 }
 
+// Unsubscribe sends an unsubscribe packet, and stops replaying the subscriptions of the topics
+func (c *Client) Unsubscribe(topics []string) error {
+	unsubscribe := &Unsubscribe{
+		ID:     c.ids.NextID(),
+		Topics: topics,
+	}
+	c.subsMu.Lock()
+	for _, topic := range topics {
+		c.subs = removeSubscription(c.subs, topic)
+	}
+	c.subsMu.Unlock()
+	return c.Send(unsubscribe)
+}
+
+// trackSubscriptions records the subscriptions sent by the subscribe packet of the id
+func (c *Client) trackSubscriptions(id ID, s []Subscription) {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+	for _, sub := range s {
+		c.subs = removeSubscription(c.subs, sub.Topic)
+		c.subs = append(c.subs, sub)
+	}
+	c.subbing[id] = s
+}
+
+// subscriptions returns a copy of the active subscriptions
+func (c *Client) subscriptions() []Subscription {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+	return append([]Subscription{}, c.subs...)
+}
+
+func removeSubscription(subs []Subscription, topic string) []Subscription {
+	for i, s := range subs {
+		if s.Topic == topic {
+			return append(subs[:i], subs[i+1:]...)
+		}
+	}
+	return subs
+}
+
 // Publish sends a publish packet
 func (c *Client) Publish(qos QOS, topic string, payload []byte, pid ID, retain bool, dup bool) error {
+	return c.PublishContext(context.Background(), qos, topic, payload, pid, retain, dup)
+}
+
+// PublishContext sends a publish packet with the properties injected by the context, such as the trace context
+func (c *Client) PublishContext(ctx context.Context, qos QOS, topic string, payload []byte, pid ID, retain bool, dup bool) error {
+	return c.SendContext(ctx, c.newPublish(qos, topic, payload, pid, retain, dup))
+}
+
+// PublishWithProperties sends a publish packet with the properties of mqtt 5.0, such as the user properties,
+// the properties are dropped if the connection is not mqtt 5.0
+func (c *Client) PublishWithProperties(qos QOS, topic string, payload []byte, pid ID, retain bool, dup bool, props Properties) error {
+	return c.Send(&Publish5{Publish: c.newPublish(qos, topic, payload, pid, retain, dup), Properties: props})
+}
+
+func (c *Client) newPublish(qos QOS, topic string, payload []byte, pid ID, retain bool, dup bool) *Publish {
 	publish := NewPublish()
 	publish.ID = pid
 	publish.Dup = dup
@@ -64,17 +199,81 @@ func (c *Client) Publish(qos QOS, topic string, payload []byte, pid ID, retain b
 	if qos != 0 && pid == 0 {
 		publish.ID = c.ids.NextID()
 	}
-	return c.Send(publish)
+	return publish
+}
+
+// Handle registers the handler of the topic filter, the publish packets matched are dispatched to the handlers
+// instead of the observer, the topic filter is not subscribed by the client
+func (c *Client) Handle(filter string, handler Handler) error {
+	return c.router.Handle(filter, handler)
+}
+
+// Unhandle removes the handler of the topic filter
+func (c *Client) Unhandle(filter string) {
+	c.router.Remove(filter)
+}
+
+// Encryptor returns the encryptor of payloads, such as to rotate the keys, returns nil if the encryption is disabled
+func (c *Client) Encryptor() *Encryptor {
+	return c.enc
 }
 
-// Send sends a generic packet
+// Send sends a generic packet, the payload of publish packet is compressed and then encrypted if enabled,
+// the payload compressed is marked by the user property of mqtt 5.0, which is dropped if the connection is not mqtt 5.0
 func (c *Client) Send(pkt Packet) error {
-	select {
-	case c.cache <- pkt:
-		return nil
-	case <-c.tomb.Dying():
-		return ErrClientAlreadyClosed
+	return c.SendContext(context.Background(), pkt)
+}
+
+// SendContext sends a generic packet, the properties of publish packet are injected by the context if the injector is set
+func (c *Client) SendContext(ctx context.Context, pkt Packet) error {
+	pkt = c.inject(ctx, pkt)
+	var err error
+	var zipped bool
+	switch p := pkt.(type) {
+	case *Publish:
+		var pub *Publish
+		pub, zipped, err = c.encodePayload(p)
+		pkt = pub
+		if zipped {
+			pkt = &Publish5{Publish: pub, Properties: markCompressed(Properties{})}
+		}
+	case *Publish5:
+		var pub *Publish
+		pub, zipped, err = c.encodePayload(p.Publish)
+		props := p.Properties
+		if zipped {
+			props = markCompressed(props)
+		}
+		pkt = &Publish5{Publish: pub, Properties: props}
+	}
+	if err != nil {
+		return err
 	}
+	_, err = c.persist(pkt)
+	if err != nil {
+		return err
+	}
+	return c.enqueue(pkt)
+}
+
+// inject returns the publish packet of mqtt 5.0 with the properties injected by the context if the injector is set,
+// the properties of the caller are not modified
+func (c *Client) inject(ctx context.Context, pkt Packet) Packet {
+	if c.cfg.PropertiesInjector == nil {
+		return pkt
+	}
+	switch p := pkt.(type) {
+	case *Publish:
+		res := &Publish5{Publish: p}
+		c.cfg.PropertiesInjector(ctx, &res.Properties)
+		return res
+	case *Publish5:
+		res := &Publish5{Publish: p.Publish, Properties: p.Properties}
+		res.Properties.UserProperties = append([]UserProperty(nil), p.Properties.UserProperties...)
+		c.cfg.PropertiesInjector(ctx, &res.Properties)
+		return res
+	}
+	return pkt
 }
 
 // Close closes client
@@ -82,87 +281,220 @@ func (c *Client) Close() error {
 	c.log.Info("client is closing")
 	defer c.log.Info("client has closed")
 
-	c.tomb.Kill(nil)
-	return c.tomb.Wait()
+	err := c.sup.Close()
+	// the packets left in the buffer are not sent
+	metrics.ClientCacheMessages.Add(-float64(len(c.cache)), metrics.ProtocolMQTT)
+	if c.certs != nil {
+		c.certs.Close()
+	}
+	if c.store != nil {
+		if e := c.store.Close(); err == nil {
+			err = e
+		}
+	}
+	return err
 }
 
-func (c *Client) connecting() error {
-	c.log.Info("client starts to keep connecting")
-	defer c.log.Info("client has stopped connecting")
-
-	var err error
-	var curr Packet
-	var stream *stream
-	var next time.Time
-	timer := time.NewTimer(0)
-	defer timer.Stop()
-	bf := backoff.Backoff{
-		Min:    time.Second,
-		Max:    c.cfg.Interval,
-		Factor: 1.6,
+// encodePayload returns a copy of the publish packet whose payload is compressed and encrypted if enabled,
+// and whether the payload is compressed
+func (c *Client) encodePayload(p *Publish) (*Publish, bool, error) {
+	if c.enc == nil && c.cfg.CompressThreshold <= 0 {
+		return p, false, nil
 	}
-
-	for {
-		if !next.IsZero() {
-			timer.Reset(next.Sub(time.Now()))
-			c.log.Info("next reconnect", log.Any("at", next), log.Any("attempt", bf.Attempt()))
-		}
-		if stream != nil {
-			stream.close()
-			stream = nil
-			c.log.Info("client has disconnected")
-		}
-		select {
-		case <-c.tomb.Dying():
-			return nil
-		case <-timer.C:
+	payload, err := CompressPayload(p.Message.Payload, int(c.cfg.CompressThreshold))
+	if err != nil {
+		return nil, false, err
+	}
+	// the payload is returned as is by CompressPayload unless compressed, which is smaller
+	zipped := len(payload) != len(p.Message.Payload)
+	if c.enc != nil {
+		payload, err = c.enc.Encrypt(p.Message.Topic, payload)
+		if err != nil {
+			return nil, false, err
 		}
+	}
+	cp := *p
+	cp.Message.Payload = payload
+	return &cp, zipped, nil
+}
 
+// connecting returns the task to connect and keep sending, which is restarted by the supervisor with backoff once
+// disconnected, the packet not sent by the previous connection is sent first by the next
+func (c *Client) connecting() utils.Task {
+	var curr Packet
+	var attempted bool
+	return func(r *utils.TaskRun) error {
 		c.log.Info("client starts to connect")
-		next = time.Now().Add(bf.Duration())
-		stream, err = c.connect()
+		if attempted {
+			metrics.ClientReconnectsTotal.Inc(metrics.ProtocolMQTT)
+		}
+		attempted = true
+		stream, err := c.connect()
 		if err != nil {
 			c.onError("failed to connect", err)
-			continue
+			return err
 		}
 		c.log.Info("client has connected")
-		bf.Reset()
+		metrics.ClientConnections.Inc(metrics.ProtocolMQTT)
+		c.connected = true
+		r.Reset()
 		curr = stream.sending(curr)
+		stream.close()
+		metrics.ClientConnections.Dec(metrics.ProtocolMQTT)
+		c.log.Info("client has disconnected")
+		return nil
 	}
 }
 
 func (c *Client) onConnack(pkt Packet) error {
-	p, ok := pkt.(*Connack)
-	if !ok {
+	switch p := pkt.(type) {
+	case *Connack:
+		return ConnackError(p.ReturnCode)
+	case *Connack5:
+		if p.ReasonCode == ReasonUnsupportedProtocolVersion {
+			return errFallbackVersion
+		}
+		if p.ReasonCode.Failed() {
+			c.log.Warn("server rejects connection", log.Any("reason", p.ReasonCode.String()), log.Any("reasonString", p.Properties.ReasonString), log.Any("serverReference", p.Properties.ServerReference))
+			return ReasonError(p.ReasonCode)
+		}
+		if p.Properties.AssignedClientID != "" {
+			c.log.Info("server assigns client id", log.Any("assigned", p.Properties.AssignedClientID))
+		}
+		return nil
+	default:
 		return ErrClientExpectedConnack
 	}
-	if p.ReturnCode != ConnectionAccepted {
-		return fmt.Errorf(p.ReturnCode.String())
+}
+
+// onDisconnect handles the disconnect packet sent by server, always returns an error to reconnect
+func (c *Client) onDisconnect(pkt *Disconnect5) error {
+	c.log.Warn("server disconnects", log.Any("reason", pkt.ReasonCode.String()), log.Any("reasonString", pkt.Properties.ReasonString), log.Any("serverReference", pkt.Properties.ServerReference))
+	code := errors.CodeUnavailable
+	if err := ReasonError(pkt.ReasonCode); err != nil {
+		code = errors.CodeOf(err)
+	}
+	return errors.Coded(code, "client is disconnected by server: %s", pkt.ReasonCode)
+}
+
+// decodePayload decrypts and then decompresses the payload of publish packet if needed, the payload is decompressed
+// if marked by the properties of mqtt 5.0, or sniffed by the magic only if the compression is enabled
+func (c *Client) decodePayload(pkt *Publish, props *Properties) error {
+	payload := pkt.Message.Payload
+	if c.enc != nil {
+		var err error
+		payload, err = c.enc.Decrypt(pkt.Message.Topic, payload)
+		if err != nil {
+			return err
+		}
+	}
+	var marked bool
+	if props != nil {
+		v, ok := props.UserProperty(PropertyContentEncoding)
+		marked = ok && v == ContentEncodingGzip
 	}
+	if marked || c.cfg.CompressThreshold > 0 {
+		var err error
+		payload, err = DecompressPayload(payload)
+		if err != nil {
+			return err
+		}
+	}
+	pkt.Message.Payload = payload
 	return nil
 }
 
 func (c *Client) onPublish(pkt *Publish) error {
-	if c.obs == nil {
+	if c.obs == nil && c.router.Len() == 0 {
 		return nil
 	}
+	if err := c.decodePayload(pkt, nil); err != nil {
+		return err
+	}
+	if ok, err := c.router.Route(pkt); ok || c.obs == nil {
+		return err
+	}
 	return c.obs.OnPublish(pkt)
 }
 
+// onPublish5 passes the properties to the observer if it implements Observer5, otherwise only the packet of mqtt 3.1.1
+func (c *Client) onPublish5(pkt *Publish5) error {
+	if c.obs == nil && c.router.Len() == 0 {
+		return nil
+	}
+	if err := c.decodePayload(pkt.Publish, &pkt.Properties); err != nil {
+		return err
+	}
+	if c.cfg.ReceiveInterceptor != nil {
+		return c.cfg.ReceiveInterceptor(pkt, func() error { return c.dispatch5(pkt) })
+	}
+	return c.dispatch5(pkt)
+}
+
+// dispatch5 dispatches the publish packet to the handler routed or the observer
+func (c *Client) dispatch5(pkt *Publish5) error {
+	if ok, err := c.router.Route(pkt.Publish); ok || c.obs == nil {
+		return err
+	}
+	if obs, ok := c.obs.(Observer5); ok {
+		return obs.OnPublish5(pkt)
+	}
+	return c.obs.OnPublish(pkt.Publish)
+}
+
 func (c *Client) onPuback(pkt *Puback) error {
+	metrics.ObserveClientMessage(metrics.ProtocolMQTT, metrics.StateAcked)
+	c.window.release(pkt.ID)
+	if err := c.forget(pkt.ID); err != nil {
+		return err
+	}
+	c.waiters.complete(pkt.ID, nil)
 	if c.obs == nil {
 		return nil
 	}
 	return c.obs.OnPuback(pkt)
 }
 
+// onPuback5 reports the error if the message is rejected by server, the packet id is released anyway
+func (c *Client) onPuback5(pkt *Puback5) error {
+	if pkt.ReasonCode.Failed() {
+		err := ReasonError(pkt.ReasonCode)
+		c.waiters.complete(pkt.ID, err)
+		c.onError("message is rejected by server", err)
+	}
+	return c.onPuback(pkt.Puback)
+}
+
+// onPubcomp reports the pubcomp to the observer as the puback of the id, since the qos 2 message is acknowledged
+func (c *Client) onPubcomp(pkt *Pubcomp) error {
+	metrics.ObserveClientMessage(metrics.ProtocolMQTT, metrics.StateAcked)
+	c.window.release(pkt.ID)
+	if err := c.forget(pkt.ID); err != nil {
+		return err
+	}
+	c.waiters.complete(pkt.ID, nil)
+	if c.obs == nil {
+		return nil
+	}
+	return c.obs.OnPuback(&Puback{ID: pkt.ID})
+}
+
 func (c *Client) onSuback(pkt *Suback) error {
-	for _, code := range pkt.ReturnCodes {
+	c.subsMu.Lock()
+	subs := c.subbing[pkt.ID]
+	delete(c.subbing, pkt.ID)
+	var err error
+	for i, code := range pkt.ReturnCodes {
 		if code == QOSFailure {
-			return ErrClientSubscriptionFailed
+			// the subscriptions rejected are not replayed
+			if i < len(subs) {
+				c.subs = removeSubscription(c.subs, subs[i].Topic)
+			}
+			err = ErrClientSubscriptionFailed
 		}
 	}
-	return nil
+	c.subsMu.Unlock()
+	return err
 }
 
 func (c *Client) onError(msg string, err error) {
@@ -0,0 +1,245 @@
+package mqtt
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/256dpi/gomqtt/transport"
+	"github.com/baetyl/baetyl-go/flow"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockObserver5 struct {
+	*mockObserver
+}
+
+func (o *mockObserver5) OnPublish5(pkt *Publish5) error {
+	o.pkts <- pkt
+	return nil
+}
+
+// initMockBroker5 speaks mqtt 5.0 if the version of flow is 5, otherwise mqtt 3.1.1
+func initMockBroker5(t *testing.T, versions []byte, testFlows ...*flow.Flow) (chan struct{}, string) {
+	done := make(chan struct{})
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	assert.NoError(t, err)
+
+	go func() {
+		for i, f := range testFlows {
+			conn, err := lis.Accept()
+			assert.NoError(t, err)
+
+			var c Connection = transport.NewNetConn(conn)
+			if versions[i] == Version5 {
+				c = newConn5(conn)
+			}
+			err = f.Test(newWrapper(c))
+			assert.NoError(t, err)
+		}
+
+		assert.NoError(t, lis.Close())
+		close(done)
+	}()
+
+	_, port, _ := net.SplitHostPort(lis.Addr().String())
+	return done, port
+}
+
+func TestMqttClient5(t *testing.T) {
+	connect := NewConnect5()
+	connect.CleanSession = true
+	connect.Properties.SessionExpiryInterval = 60
+	connect.Properties.AddUserProperty("client", "edge")
+
+	connack := NewConnack5()
+	connack.Properties.TopicAliasMaximum = 5
+
+	publish1 := NewPublish5()
+	publish1.ID = 1
+	publish1.Message = Message{Topic: "test", Payload: []byte("hello"), QOS: 1}
+	publish1.Properties.AddUserProperty("trace-id", "abc")
+	publish2 := NewPublish5()
+	publish2.ID = 2
+	publish2.Message = Message{Topic: "test", Payload: []byte("world"), QOS: 1}
+	publish2.Properties.AddUserProperty("trace-id", "def")
+
+	puback1 := NewPuback()
+	puback1.ID = 1
+	puback2 := NewPuback5()
+	puback2.ID = 2
+	puback2.ReasonCode = ReasonNotAuthorized
+
+	// the topics are aliased by the broker, since the client accepts
+	cmd1 := NewPublish5()
+	cmd1.Message = Message{Topic: "cmd", Payload: []byte("1")}
+	cmd1.Properties.AddUserProperty("trace-id", "x")
+	cmd2 := NewPublish5()
+	cmd2.Message = Message{Topic: "cmd", Payload: []byte("2")}
+	cmd2.Properties.AddUserProperty("trace-id", "y")
+
+	disconnect := NewDisconnect5()
+	disconnect.ReasonCode = ReasonServerShuttingDown
+	disconnect.Properties.ReasonString = "maintenance"
+
+	broker1 := flow.New().Debug().
+		Receive(connect).
+		Send(connack).
+		Receive(publish1, publish2).
+		Send(puback1, puback2, cmd1, cmd2, disconnect).
+		End()
+	broker2 := flow.New().Debug().
+		Receive(connect).
+		Send(connack).
+		Receive(NewDisconnect5()).
+		End()
+
+	done, port := initMockBroker5(t, []byte{Version5, Version5}, broker1, broker2)
+
+	cc := newConfig(port)
+	cc.ProtocolVersion = 5
+	cc.SessionExpiry = time.Minute
+	cc.TopicAliasMaximum = 10
+	cc.UserProperties = []UserProperty{{Key: "client", Value: "edge"}}
+	obs := &mockObserver5{newMockObserver(t)}
+	cli, err := NewClient(cc, obs)
+	assert.NoError(t, err)
+
+	err = cli.PublishWithProperties(1, "test", []byte("hello"), 1, false, false, publish1.Properties)
+	assert.NoError(t, err)
+	err = cli.PublishWithProperties(1, "test", []byte("world"), 2, false, false, publish2.Properties)
+	assert.NoError(t, err)
+
+	obs.assertErrs(errors.New("not authorized"))
+	obs.assertPkts(puback1, puback2.Puback, cmd1, cmd2)
+	obs.assertErrs(errors.New("client is disconnected by server: server shutting down"))
+
+	// reconnects
+	time.Sleep(2 * time.Second)
+	assert.NoError(t, cli.Close())
+	safeReceive(done)
+}
+
+func TestMqttClient5Fallback(t *testing.T) {
+	connect := NewConnect5()
+	connect.CleanSession = true
+
+	broker1 := flow.New().Debug().
+		Receive(connect).
+		Send(&Connack{ReturnCode: InvalidProtocolVersion}).
+		Close()
+	publish := NewPublish()
+	publish.ID = 1
+	publish.Message = Message{Topic: "test", Payload: []byte("hello"), QOS: 1}
+	puback := NewPuback()
+	puback.ID = 1
+
+	broker2 := flow.New().Debug().
+		Receive(connectPacket()).
+		Send(connackPacket()).
+		Receive(publish).
+		Send(puback).
+		Receive(disconnectPacket()).
+		End()
+
+	done, port := initMockBroker5(t, []byte{Version5, Version311}, broker1, broker2)
+
+	cc := newConfig(port)
+	cc.ProtocolVersion = 5
+	obs := newMockObserver(t)
+	cli, err := NewClient(cc, obs)
+	assert.NoError(t, err)
+
+	// the properties are dropped
+	props := Properties{}
+	props.AddUserProperty("trace-id", "abc")
+	err = cli.PublishWithProperties(1, "test", []byte("hello"), 1, false, false, props)
+	assert.NoError(t, err)
+	obs.assertPkts(puback)
+
+	assert.NoError(t, cli.Close())
+	safeReceive(done)
+	assert.Len(t, obs.errs, 0)
+
+	cc.ProtocolVersion = 6
+	_, err = NewClient(cc, obs)
+	assert.EqualError(t, err, "protocol version (6) not supported")
+}
+
+func TestMqttClient5PropertiesInjector(t *testing.T) {
+	connect := NewConnect5()
+	connect.CleanSession = true
+
+	publish := NewPublish5()
+	publish.ID = 1
+	publish.Message = Message{Topic: "test", Payload: []byte("hello"), QOS: 1}
+	publish.Properties.AddUserProperty("traceparent", "abc")
+	puback := NewPuback()
+	puback.ID = 1
+
+	// the properties are injected on all the publish paths
+	publish2 := NewPublish5()
+	publish2.ID = 2
+	publish2.Message = Message{Topic: "test", Payload: []byte("world"), QOS: 1}
+	publish2.Properties.AddUserProperty("traceparent", "def")
+	publish3 := NewPublish5()
+	publish3.Message = Message{Topic: "test", Payload: []byte("!")}
+	publish3.Properties.AddUserProperty("a", "b")
+	publish3.Properties.AddUserProperty("traceparent", "")
+	puback2 := NewPuback()
+	puback2.ID = 2
+
+	cmd := NewPublish5()
+	cmd.Message = Message{Topic: "cmd", Payload: []byte("1")}
+	cmd.Properties.AddUserProperty("traceparent", "xyz")
+
+	broker := flow.New().Debug().
+		Receive(connect).
+		Send(NewConnack5()).
+		Receive(publish).
+		Send(puback).
+		Receive(publish2, publish3).
+		Send(puback2, cmd).
+		Receive(NewDisconnect5()).
+		End()
+
+	done, port := initMockBroker5(t, []byte{Version5}, broker)
+
+	type key struct{}
+	cc := newConfig(port)
+	cc.ProtocolVersion = 5
+	cc.PropertiesInjector = func(ctx context.Context, props *Properties) {
+		v, _ := ctx.Value(key{}).(string)
+		props.AddUserProperty("traceparent", v)
+	}
+	received := make(chan string, 1)
+	cc.ReceiveInterceptor = func(pkt *Publish5, handle func() error) error {
+		v, _ := pkt.Properties.UserProperty("traceparent")
+		received <- v
+		return handle()
+	}
+	obs := &mockObserver5{newMockObserver(t)}
+	cli, err := NewClient(cc, obs)
+	assert.NoError(t, err)
+
+	ctx := context.WithValue(context.Background(), key{}, "abc")
+	assert.NoError(t, cli.PublishSync(ctx, 1, "test", []byte("hello")))
+	obs.assertPkts(puback)
+
+	ctx = context.WithValue(context.Background(), key{}, "def")
+	assert.NoError(t, cli.PublishContext(ctx, 1, "test", []byte("world"), 0, false, false))
+	props := Properties{}
+	props.AddUserProperty("a", "b")
+	assert.NoError(t, cli.PublishWithProperties(0, "test", []byte("!"), 0, false, false, props))
+	// the properties of the caller are not modified
+	assert.Len(t, props.UserProperties, 1)
+
+	obs.assertPkts(puback2, cmd)
+	assert.Equal(t, "xyz", <-received)
+
+	assert.NoError(t, cli.Close())
+	safeReceive(done)
+}
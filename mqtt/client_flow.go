@@ -0,0 +1,229 @@
+package mqtt
+
+import (
+	"context"
+	"sync"
+
+	"github.com/baetyl/baetyl-go/errors"
+	"github.com/baetyl/baetyl-go/limit"
+	"github.com/baetyl/baetyl-go/log"
+	"github.com/baetyl/baetyl-go/metrics"
+)
+
+// window the ids of qos 1 and 2 publish packets sent but not acknowledged, which limits the messages inflight
+type window struct {
+	max      int
+	ids      map[ID]struct{}
+	released chan struct{}
+	mu       sync.Mutex
+}
+
+// newWindow creates a new window, returns nil if unlimited
+func newWindow(max int) *window {
+	if max <= 0 {
+		return nil
+	}
+	return &window{
+		max:      max,
+		ids:      map[ID]struct{}{},
+		released: make(chan struct{}, 1),
+	}
+}
+
+// acquire takes a slot for the id, blocks until a slot is released or the context is done
+func (w *window) acquire(ctx context.Context, id ID) error {
+	if w == nil {
+		return nil
+	}
+	for {
+		w.mu.Lock()
+		_, ok := w.ids[id]
+		if ok || len(w.ids) < w.max {
+			w.ids[id] = struct{}{}
+			w.mu.Unlock()
+			return nil
+		}
+		w.mu.Unlock()
+		select {
+		case <-w.released:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// add takes a slot for the id without waiting, such as the packets resent after reconnection
+func (w *window) add(id ID) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	w.ids[id] = struct{}{}
+	w.mu.Unlock()
+}
+
+// release releases the slot of the id acknowledged
+func (w *window) release(id ID) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	_, ok := w.ids[id]
+	delete(w.ids, id)
+	w.mu.Unlock()
+	if ok {
+		select {
+		case w.released <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// reset releases all slots, since the acknowledgements of the last connection never come
+func (w *window) reset() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	w.ids = map[ID]struct{}{}
+	w.mu.Unlock()
+}
+
+// newFlowLimiter creates the limiter of the message rate, returns nil if unlimited
+func newFlowLimiter(cfg FlowControlConfig) *limit.Limiter {
+	if cfg.Rate <= 0 {
+		return nil
+	}
+	return limit.NewLimiter(limit.Config{MessageRate: cfg.Rate, Burst: cfg.Burst})
+}
+
+func checkFlowControl(cfg FlowControlConfig) error {
+	switch cfg.Policy {
+	case "", PolicyBlock, PolicyDropOldest, PolicyDropNewest:
+	default:
+		return errors.Coded(errors.CodeInvalidArgument, "policy (%s) of flow control not supported", cfg.Policy)
+	}
+	if cfg.MaxInflight < 0 || cfg.Rate < 0 || cfg.Burst < 0 {
+		return errors.Coded(errors.CodeInvalidArgument, "flow control (maxInflight=%d, rate=%v, burst=%v) is invalid", cfg.MaxInflight, cfg.Rate, cfg.Burst)
+	}
+	return nil
+}
+
+// enqueue puts the packet into the buffer to send, the publish packets are dropped by the policy if the buffer is full,
+// while the other packets are always blocked
+func (c *Client) enqueue(pkt Packet) error {
+	policy := c.cfg.FlowControl.Policy
+	if !isPublish(pkt) {
+		policy = PolicyBlock
+	}
+	for {
+		select {
+		case c.cache <- pkt:
+			metrics.ClientCacheMessages.Inc(metrics.ProtocolMQTT)
+			return nil
+		default:
+		}
+		switch policy {
+		case PolicyDropNewest:
+			c.drop(pkt)
+			return ErrClientMessageDropped
+		case PolicyDropOldest:
+			select {
+			case old := <-c.cache:
+				metrics.ClientCacheMessages.Dec(metrics.ProtocolMQTT)
+				if isPublish(old) {
+					c.drop(old)
+					continue
+				}
+				// the packets other than publish are never dropped, which are put back
+				if err := c.put(old); err != nil {
+					c.discard(pkt)
+					return err
+				}
+			default:
+			}
+		default:
+			if err := c.put(pkt); err != nil {
+				c.discard(pkt)
+				return err
+			}
+			return nil
+		}
+	}
+}
+
+// put puts the packet into the buffer, blocks until the buffer is available or the client is closed
+func (c *Client) put(pkt Packet) error {
+	select {
+	case c.cache <- pkt:
+		metrics.ClientCacheMessages.Inc(metrics.ProtocolMQTT)
+		return nil
+	case <-c.sup.Dying():
+		return ErrClientAlreadyClosed
+	}
+}
+
+// discard forgets the packet not sent if tracked
+func (c *Client) discard(pkt Packet) {
+	if id, ok := c.trackedID(pkt); ok {
+		c.forget(id)
+	}
+}
+
+// drop discards the publish packet by the policy, the caller of PublishSync is notified
+func (c *Client) drop(pkt Packet) {
+	c.discard(pkt)
+	if p := publishOf(pkt); p != nil {
+		metrics.ObserveClientMessage(metrics.ProtocolMQTT, metrics.StateDropped)
+		if p.ID != 0 {
+			c.waiters.complete(p.ID, ErrClientMessageDropped)
+		}
+		c.log.Warn("client drops a message since buffer is full", log.Any("topic", p.Message.Topic), log.Any("qos", p.Message.QOS), log.Any("pid", p.ID))
+	}
+}
+
+// throttle waits for the rate limiter and the inflight window before the publish packet is sent
+func (s *stream) throttle(ctx context.Context, pkt Packet) error {
+	p := publishOf(pkt)
+	if p == nil {
+		return nil
+	}
+	if s.cli.limiter != nil {
+		if err := s.cli.limiter.Wait(ctx, "", 0); err != nil {
+			return err
+		}
+	}
+	if p.Message.QOS > QOSAtMostOnce {
+		return s.cli.window.acquire(ctx, p.ID)
+	}
+	return nil
+}
+
+// flowContext returns the context done once the stream or the client is closed, which cancels the throttling
+func (s *stream) flowContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-s.cli.sup.Dying():
+		case <-s.tomb.Dying():
+		case <-ctx.Done():
+		}
+		cancel()
+	}()
+	return ctx, cancel
+}
+
+func isPublish(pkt Packet) bool {
+	return publishOf(pkt) != nil
+}
+
+func publishOf(pkt Packet) *Publish {
+	switch p := pkt.(type) {
+	case *Publish:
+		return p
+	case *Publish5:
+		return p.Publish
+	default:
+		return nil
+	}
+}
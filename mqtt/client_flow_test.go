@@ -0,0 +1,124 @@
+package mqtt
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWindow(t *testing.T) {
+	assert.Nil(t, newWindow(0))
+	var unlimited *window
+	assert.NoError(t, unlimited.acquire(context.Background(), 1))
+	unlimited.release(1)
+
+	w := newWindow(2)
+	assert.NoError(t, w.acquire(context.Background(), 1))
+	assert.NoError(t, w.acquire(context.Background(), 2))
+	// acquired again by the packet of the same id
+	assert.NoError(t, w.acquire(context.Background(), 2))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, w.acquire(ctx, 3))
+
+	done := make(chan error)
+	go func() {
+		done <- w.acquire(context.Background(), 3)
+	}()
+	w.release(1)
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("slot not released")
+	}
+
+	w.reset()
+	w.add(4)
+	assert.NoError(t, w.acquire(context.Background(), 5))
+	assert.Len(t, w.ids, 2)
+}
+
+func TestMqttClientFlowControlPolicy(t *testing.T) {
+	// the client never connects, so that the buffer is never consumed
+	cc := newConfig("1234567")
+	cc.BufferSize = 2
+
+	cc.FlowControl.Policy = "unknown"
+	_, err := NewClient(cc, nil)
+	assert.EqualError(t, err, "policy (unknown) of flow control not supported")
+
+	cc.FlowControl.Policy = PolicyDropNewest
+	cli, err := NewClient(cc, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, cli.Publish(0, "t", []byte("1"), 0, false, false))
+	assert.NoError(t, cli.Publish(1, "t", []byte("2"), 0, false, false))
+	assert.Equal(t, ErrClientMessageDropped, cli.Publish(1, "t", []byte("3"), 0, false, false))
+	// the packets other than publish are never dropped
+	go cli.Subscribe([]Subscription{{Topic: "t"}})
+	assert.Equal(t, "1", string((<-cli.cache).(*Publish).Message.Payload))
+	assert.Equal(t, "2", string((<-cli.cache).(*Publish).Message.Payload))
+	assert.IsType(t, &Subscribe{}, <-cli.cache)
+	assert.NoError(t, cli.Close())
+
+	cc.FlowControl.Policy = PolicyDropOldest
+	cli, err = NewClient(cc, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, cli.Publish(0, "t", []byte("1"), 0, false, false))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	done := make(chan error)
+	go func() {
+		done <- cli.PublishSync(ctx, 1, "t", []byte("2"))
+	}()
+	time.Sleep(100 * time.Millisecond)
+	assert.NoError(t, cli.Publish(1, "t", []byte("3"), 0, false, false))
+	assert.NoError(t, cli.Publish(1, "t", []byte("4"), 0, false, false))
+	// the waiting caller is notified once its message is dropped
+	assert.Equal(t, ErrClientMessageDropped, <-done)
+	assert.Equal(t, "3", string((<-cli.cache).(*Publish).Message.Payload))
+	assert.Equal(t, "4", string((<-cli.cache).(*Publish).Message.Payload))
+	assert.NoError(t, cli.Close())
+
+	cc.FlowControl.Policy = PolicyBlock
+	cli, err = NewClient(cc, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, cli.Publish(0, "t", []byte("1"), 0, false, false))
+	assert.NoError(t, cli.Publish(0, "t", []byte("2"), 0, false, false))
+	go func() {
+		done <- cli.Publish(0, "t", []byte("3"), 0, false, false)
+	}()
+	select {
+	case <-done:
+		t.Fatal("publish not blocked")
+	case <-time.After(100 * time.Millisecond):
+	}
+	assert.NoError(t, cli.Close())
+	assert.Equal(t, ErrClientAlreadyClosed, <-done)
+}
+
+func TestMqttClientFlowControlRate(t *testing.T) {
+	b := newTestBroker(t, StoreConfig{Driver: "memory"})
+	defer b.Close()
+
+	var cc ClientConfig
+	assert.NoError(t, utils.SetDefaults(&cc))
+	cc.Address = "tcp://" + b.Addresses()[0]
+	cc.CleanSession = true
+	cc.FlowControl.Rate = 20
+	cc.FlowControl.Burst = 0 // the burst of one message
+	cc.FlowControl.MaxInflight = 1
+	cli, err := NewClient(cc, nil)
+	assert.NoError(t, err)
+	defer cli.Close()
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, cli.PublishSync(context.Background(), 1, "t", nil))
+	}
+	assert.True(t, time.Since(start) >= 200*time.Millisecond, time.Since(start).String())
+}
@@ -18,6 +18,12 @@ type Observer interface {
 	OnError(error)
 }
 
+// Observer5 the observer which receives the publish packets of mqtt 5.0 with the properties, such as the user properties
+type Observer5 interface {
+	Observer
+	OnPublish5(*Publish5) error
+}
+
 // ObserverWrapper MQTT message handler wrapper
 type ObserverWrapper struct {
 	onPublish OnPublish
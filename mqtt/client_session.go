@@ -0,0 +1,201 @@
+package mqtt
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/baetyl/baetyl-go/log"
+)
+
+// inflights the packets pending or inflight, the qos 2 publish packets and the pubrel packets are always tracked,
+// while the qos 1 publish packets are tracked only if the session is persisted
+type inflights struct {
+	pkts     map[ID]*inflight
+	seq      uint64
+	received map[ID]struct{} // the ids of qos 2 publish packets received but not released by server
+	mu       sync.Mutex
+}
+
+type inflight struct {
+	seq  uint64
+	pkt  Packet
+	sent bool
+}
+
+func newInflights() *inflights {
+	return &inflights{
+		pkts:     map[ID]*inflight{},
+		received: map[ID]struct{}{},
+	}
+}
+
+// loadSession loads the packets stored before restart, which are resent after connected
+func (c *Client) loadSession() error {
+	datas, err := c.store.List()
+	if err != nil {
+		return err
+	}
+	var last ID
+	for _, data := range datas {
+		pkt, err := DecodePacket5(data)
+		if err != nil {
+			return err
+		}
+		var id ID
+		switch p := pkt.(type) {
+		case *Publish5:
+			id = p.ID
+		case *Pubrel:
+			id = p.ID
+		default:
+			continue
+		}
+		c.inflights.seq++
+		c.inflights.pkts[id] = &inflight{seq: c.inflights.seq, pkt: pkt, sent: true}
+		if id > last {
+			last = id
+		}
+	}
+	if len(datas) > 0 {
+		c.log.Info("client loads the session", log.Any("inflights", len(c.inflights.pkts)))
+	}
+	// the ids of new packets follow the ones stored
+	c.ids = NewCounterWithNext(last + 1)
+	return nil
+}
+
+// persist tracks the packet before sending, which is stored if the session is persisted,
+// the pubrel packet replaces the publish packet of the same id and keeps its order
+func (c *Client) persist(pkt Packet) (bool, error) {
+	id, ok := c.trackedID(pkt)
+	if !ok {
+		return false, nil
+	}
+	c.inflights.mu.Lock()
+	defer c.inflights.mu.Unlock()
+	if c.store != nil {
+		data, err := EncodePacket5(pkt)
+		if err != nil {
+			return false, err
+		}
+		err = c.store.Put(id, data)
+		if err != nil {
+			return false, err
+		}
+	}
+	if p, ok := c.inflights.pkts[id]; ok {
+		p.pkt = pkt
+		p.sent = false
+		return true, nil
+	}
+	c.inflights.seq++
+	c.inflights.pkts[id] = &inflight{seq: c.inflights.seq, pkt: pkt}
+	return true, nil
+}
+
+// track marks the packet as sent, returns whether the packet is tracked
+func (c *Client) track(pkt Packet) bool {
+	id, ok := c.trackedID(pkt)
+	if !ok {
+		return false
+	}
+	c.inflights.mu.Lock()
+	defer c.inflights.mu.Unlock()
+	p, ok := c.inflights.pkts[id]
+	if ok {
+		p.sent = true
+	}
+	return ok
+}
+
+// forget deletes the packet acknowledged or dropped
+func (c *Client) forget(id ID) error {
+	c.inflights.mu.Lock()
+	defer c.inflights.mu.Unlock()
+	if _, ok := c.inflights.pkts[id]; !ok {
+		return nil
+	}
+	delete(c.inflights.pkts, id)
+	if c.store == nil {
+		return nil
+	}
+	return c.store.Delete(id)
+}
+
+// resends returns the copies of the packets sent but not acknowledged in the order of tracking,
+// with the dup flag set for the publish packets
+func (c *Client) resends() []Packet {
+	c.inflights.mu.Lock()
+	var ps []*inflight
+	for _, p := range c.inflights.pkts {
+		if p.sent {
+			ps = append(ps, p)
+		}
+	}
+	c.inflights.mu.Unlock()
+	sort.Slice(ps, func(i, j int) bool { return ps[i].seq < ps[j].seq })
+	res := make([]Packet, 0, len(ps))
+	for _, p := range ps {
+		switch v := p.pkt.(type) {
+		case *Publish:
+			cp := *v
+			cp.Dup = true
+			res = append(res, &cp)
+		case *Publish5:
+			cp := *v.Publish
+			cp.Dup = true
+			res = append(res, &Publish5{Publish: &cp, Properties: v.Properties})
+		case *Pubrel:
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// receive records the id of qos 2 publish packet handled, the packets of the id are not handled again until released
+func (c *Client) receive(id ID) {
+	c.inflights.mu.Lock()
+	c.inflights.received[id] = struct{}{}
+	c.inflights.mu.Unlock()
+}
+
+// received checks whether the qos 2 publish packet of the id is handled but not released by server
+func (c *Client) received(id ID) bool {
+	c.inflights.mu.Lock()
+	defer c.inflights.mu.Unlock()
+	_, ok := c.inflights.received[id]
+	return ok
+}
+
+// release deletes the id of qos 2 publish packet released by server
+func (c *Client) release(id ID) {
+	c.inflights.mu.Lock()
+	delete(c.inflights.received, id)
+	c.inflights.mu.Unlock()
+}
+
+// trackedID returns the id of the packet if tracked in the session
+func (c *Client) trackedID(pkt Packet) (ID, bool) {
+	var p *Publish
+	switch v := pkt.(type) {
+	case *Publish:
+		p = v
+	case *Publish5:
+		p = v.Publish
+	case *Pubrel:
+		return v.ID, true
+	default:
+		return 0, false
+	}
+	if p.ID == 0 {
+		return 0, false
+	}
+	switch p.Message.QOS {
+	case QOSAtLeastOnce:
+		return p.ID, c.store != nil
+	case QOSExactlyOnce:
+		return p.ID, true
+	default:
+		return 0, false
+	}
+}
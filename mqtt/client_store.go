@@ -0,0 +1,83 @@
+package mqtt
+
+import (
+	"sort"
+	"sync"
+)
+
+// ClientStore the persistence of the client session, which keeps the qos 1 and qos 2 publish packets pending or inflight
+// (sent but not acknowledged) and the pubrel packets not completed, so that they are resent after reconnection or restart
+type ClientStore interface {
+	// Put stores the encoded publish or pubrel packet of the packet id, replaces the one of the same id
+	Put(id ID, data []byte) error
+	// Delete deletes the publish packet of the packet id
+	Delete(id ID) error
+	// List returns the encoded publish packets in the order of storing
+	List() ([][]byte, error)
+	// Close closes the store
+	Close() error
+}
+
+// NewClientStore creates the store of client session, returns nil if the path is not configured
+func NewClientStore(cfg ClientStoreConfig) (ClientStore, error) {
+	if cfg.Path == "" {
+		return nil, nil
+	}
+	return NewBoltClientStore(cfg)
+}
+
+type storedPacket struct {
+	seq  uint64
+	data []byte
+}
+
+// MemoryClientStore the store of client session in memory, which resends the packets after reconnection
+// but does not survive restarts
+type MemoryClientStore struct {
+	pkts map[ID]storedPacket
+	seq  uint64
+	mu   sync.Mutex
+}
+
+// NewMemoryClientStore creates a new store of client session in memory
+func NewMemoryClientStore() *MemoryClientStore {
+	return &MemoryClientStore{pkts: map[ID]storedPacket{}}
+}
+
+// Put stores the encoded publish packet of the packet id, replaces the one of the same id
+func (s *MemoryClientStore) Put(id ID, data []byte) error {
+	s.mu.Lock()
+	s.seq++
+	s.pkts[id] = storedPacket{seq: s.seq, data: append([]byte{}, data...)}
+	s.mu.Unlock()
+	return nil
+}
+
+// Delete deletes the publish packet of the packet id
+func (s *MemoryClientStore) Delete(id ID) error {
+	s.mu.Lock()
+	delete(s.pkts, id)
+	s.mu.Unlock()
+	return nil
+}
+
+// List returns the encoded publish packets in the order of storing
+func (s *MemoryClientStore) List() ([][]byte, error) {
+	s.mu.Lock()
+	pkts := make([]storedPacket, 0, len(s.pkts))
+	for _, p := range s.pkts {
+		pkts = append(pkts, p)
+	}
+	s.mu.Unlock()
+	sort.Slice(pkts, func(i, j int) bool { return pkts[i].seq < pkts[j].seq })
+	res := make([][]byte, 0, len(pkts))
+	for _, p := range pkts {
+		res = append(res, append([]byte{}, p.data...))
+	}
+	return res, nil
+}
+
+// Close closes the store
+func (s *MemoryClientStore) Close() error {
+	return nil
+}
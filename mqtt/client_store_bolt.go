@@ -0,0 +1,109 @@
+package mqtt
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	bucketInflight = []byte("inflight") // the packets keyed by sequence
+	bucketIDs      = []byte("ids")      // the sequences keyed by packet id
+)
+
+// BoltClientStore the store of client session backed by an embedded boltdb file
+type BoltClientStore struct {
+	db *bolt.DB
+}
+
+// NewBoltClientStore opens (or creates) the boltdb file of client session
+func NewBoltClientStore(cfg ClientStoreConfig) (*BoltClientStore, error) {
+	err := os.MkdirAll(filepath.Dir(cfg.Path), 0755)
+	if err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(cfg.Path, 0600, &bolt.Options{Timeout: cfg.Timeout})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{bucketInflight, bucketIDs} {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltClientStore{db: db}, nil
+}
+
+// Put stores the encoded publish packet of the packet id, replaces the one of the same id
+func (s *BoltClientStore) Put(id ID, data []byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := deleteInflight(tx, id); err != nil {
+			return err
+		}
+		b := tx.Bucket(bucketInflight)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		k := encodeSeq(seq)
+		if err = b.Put(k, data); err != nil {
+			return err
+		}
+		return tx.Bucket(bucketIDs).Put(encodeID(id), k)
+	})
+}
+
+// Delete deletes the publish packet of the packet id
+func (s *BoltClientStore) Delete(id ID) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return deleteInflight(tx, id)
+	})
+}
+
+// List returns the encoded publish packets in the order of storing
+func (s *BoltClientStore) List() ([][]byte, error) {
+	var res [][]byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketInflight).ForEach(func(_, v []byte) error {
+			res = append(res, append([]byte{}, v...))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Close closes the boltdb file
+func (s *BoltClientStore) Close() error {
+	return s.db.Close()
+}
+
+func deleteInflight(tx *bolt.Tx, id ID) error {
+	ids := tx.Bucket(bucketIDs)
+	k := encodeID(id)
+	seq := ids.Get(k)
+	if seq == nil {
+		return nil
+	}
+	if err := tx.Bucket(bucketInflight).Delete(seq); err != nil {
+		return err
+	}
+	return ids.Delete(k)
+}
+
+func encodeID(id ID) []byte {
+	k := make([]byte, 2)
+	binary.BigEndian.PutUint16(k, uint16(id))
+	return k
+}
@@ -0,0 +1,174 @@
+package mqtt
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/baetyl/baetyl-go/flow"
+	"github.com/baetyl/baetyl-go/log"
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "client-store")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var cfg ClientStoreConfig
+	assert.NoError(t, utils.SetDefaults(&cfg))
+	s, err := NewClientStore(cfg)
+	assert.NoError(t, err)
+	assert.Nil(t, s)
+
+	cfg.Path = filepath.Join(dir, "sub", "session.db")
+	s, err = NewClientStore(cfg)
+	assert.NoError(t, err)
+
+	for name, s := range map[string]ClientStore{"boltdb": s, "memory": NewMemoryClientStore()} {
+		t.Run(name, func(t *testing.T) {
+			assert.NoError(t, s.Put(1, []byte("a")))
+			assert.NoError(t, s.Put(2, []byte("b")))
+			assert.NoError(t, s.Put(3, []byte("c")))
+			assert.NoError(t, s.Put(1, []byte("d")))
+			assert.NoError(t, s.Delete(2))
+			assert.NoError(t, s.Delete(9))
+
+			if name == "boltdb" {
+				// reopen, the packets are kept
+				assert.NoError(t, s.Close())
+				s, err = NewBoltClientStore(cfg)
+				assert.NoError(t, err)
+			}
+			defer s.Close()
+
+			datas, err := s.List()
+			assert.NoError(t, err)
+			assert.Equal(t, [][]byte{[]byte("c"), []byte("d")}, datas)
+		})
+	}
+}
+
+func TestMqttClientSession(t *testing.T) {
+	dir, err := ioutil.TempDir("", "client-session")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	publish := NewPublish()
+	publish.ID = 1
+	publish.Message = Message{Topic: "test", Payload: []byte("hello"), QOS: 1}
+	resent := NewPublish()
+	resent.ID = 1
+	resent.Dup = true
+	resent.Message = publish.Message
+	puback := NewPuback()
+	puback.ID = 1
+	publish2 := NewPublish()
+	publish2.ID = 2
+	publish2.Message = Message{Topic: "test", Payload: []byte("world"), QOS: 1}
+	puback2 := NewPuback()
+	puback2.ID = 2
+
+	// not acknowledged before disconnected
+	broker1 := flow.New().Debug().
+		Receive(connectPacket()).
+		Send(connackPacket()).
+		Receive(publish).
+		Close()
+	// resent after reconnected, and not acknowledged before the client is closed
+	reconnected := make(chan struct{})
+	broker2 := flow.New().Debug().
+		Receive(connectPacket()).
+		Send(connackPacket()).
+		Receive(resent).
+		Run(func() { close(reconnected) }).
+		Receive(disconnectPacket()).
+		End()
+	// resent after restarted
+	broker3 := flow.New().Debug().
+		Receive(connectPacket()).
+		Send(connackPacket()).
+		Receive(resent).
+		Send(puback).
+		Receive(publish2).
+		Send(puback2).
+		Receive(disconnectPacket()).
+		End()
+
+	done, port := initMockBroker5(t, []byte{Version311, Version311, Version311}, broker1, broker2, broker3)
+
+	cc := newConfig(port)
+	cc.Store.Path = filepath.Join(dir, "session.db")
+	obs := newMockObserver(t)
+	cli, err := NewClient(cc, obs)
+	assert.NoError(t, err)
+	assert.NoError(t, cli.Publish(1, "test", []byte("hello"), 0, false, false))
+	obs.assertErrs(io.EOF)
+	<-reconnected
+	assert.NoError(t, cli.Close())
+
+	obs = newMockObserver(t)
+	cli, err = NewClient(cc, obs)
+	assert.NoError(t, err)
+	obs.assertPkts(puback)
+	assert.NoError(t, cli.Publish(1, "test", []byte("world"), 0, false, false))
+	obs.assertPkts(puback2)
+	assert.NoError(t, cli.Close())
+	safeReceive(done)
+
+	s, err := NewBoltClientStore(cc.Store)
+	assert.NoError(t, err)
+	defer s.Close()
+	datas, err := s.List()
+	assert.NoError(t, err)
+	assert.Empty(t, datas)
+}
+
+func TestClientSessionQOS2(t *testing.T) {
+	store := NewMemoryClientStore()
+	c := &Client{store: store, inflights: newInflights(), log: log.With()}
+
+	publish := NewPublish()
+	publish.ID = 1
+	publish.Message = Message{Topic: "test", Payload: []byte("hello"), QOS: 2}
+	pubrel := NewPubrel()
+	pubrel.ID = 1
+	publish0 := NewPublish()
+	publish0.Message = Message{Topic: "test", Payload: []byte("hello")}
+
+	ok, err := c.persist(publish0)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = c.persist(publish)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Empty(t, c.resends())
+	assert.True(t, c.track(publish))
+	assert.Equal(t, true, c.resends()[0].(*Publish).Dup)
+
+	// the pubrel replaces the publish packet
+	ok, err = c.persist(pubrel)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, c.track(pubrel))
+	assert.Equal(t, []Packet{pubrel}, c.resends())
+
+	// loaded after restart
+	c2 := &Client{store: store, inflights: newInflights(), log: log.With()}
+	assert.NoError(t, c2.loadSession())
+	assert.Equal(t, []Packet{pubrel}, c2.resends())
+	assert.Equal(t, ID(2), c2.ids.NextID())
+
+	assert.NoError(t, c.forget(1))
+	datas, err := store.List()
+	assert.NoError(t, err)
+	assert.Empty(t, datas)
+
+	c.receive(3)
+	assert.True(t, c.received(3))
+	c.release(3)
+	assert.False(t, c.received(3))
+}
@@ -7,52 +7,119 @@ import (
 	"time"
 
 	"github.com/baetyl/baetyl-go/log"
+	"github.com/baetyl/baetyl-go/metrics"
 	"github.com/baetyl/baetyl-go/utils"
 )
 
 type stream struct {
-	cli     *Client
-	conn    Connection
-	future  *Future
-	tracker *Tracker
-	tomb    utils.Tomb
-	once    sync.Once
-	mu      sync.Mutex
+	cli       *Client
+	conn      Connection
+	future    *Future
+	tracker   *Tracker
+	keepalive time.Duration
+	rotated   <-chan struct{} // closed once the certificate is rotated, then the client reconnects
+	present   bool            // the session is present on server
+	resub     *Future         // completed when the subscriptions replayed are acknowledged
+	resubID   ID
+	tomb      utils.Tomb
+	once      sync.Once
+	mu        sync.Mutex
 }
 
 func (c *Client) connect() (*stream, error) {
+	s, err := c.connectVersion()
+	if err == errFallbackVersion {
+		c.log.Warn("server rejects mqtt 5.0, falls back to mqtt 3.1.1")
+		c.version = Version311
+		return c.connectVersion()
+	}
+	return s, err
+}
+
+// dial dials the server over tcp or websocket, and speaks mqtt 5.0 over the connection if required
+func (c *Client) dial() (Connection, error) {
+	tc := c.tls
+	if c.certs != nil {
+		// the CA rotated is used by the new connection, and the key pair is got in handshakes
+		tc = c.certs.TLSConfigClient()
+	}
+	var conn Connection
+	var err error
+	if isWebSocket(c.cfg.Address) {
+		conn, err = dialWebSocket(c.cfg.Address, c.cfg.WebSocket, tc, c.cfg.Timeout)
+	} else {
+		conn, err = NewDialer(tc, c.cfg.Timeout).Dial(c.cfg.Address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if c.version == Version5 {
+		return upgrade5(conn)
+	}
+	return conn, nil
+}
+
+func (c *Client) connectVersion() (*stream, error) {
+	// the rotation after dialing makes the client reconnect
+	var rotated <-chan struct{}
+	if c.certs != nil {
+		rotated = c.certs.Changed()
+	}
 	// dialing
-	dialer := NewDialer(c.tls, c.cfg.Timeout)
-	conn, err := dialer.Dial(c.cfg.Address)
+	conn, err := c.dial()
 	if err != nil {
 		return nil, err
 	}
 
 	// send connect
 	connect := NewConnect()
+	connect.Version = c.version
 	connect.ClientID = c.cfg.ClientID
 	connect.KeepAlive = uint16(math.Ceil(c.cfg.KeepAlive.Seconds()))
 	connect.CleanSession = c.cfg.CleanSession
 	connect.Username = c.cfg.Username
 	connect.Password = c.cfg.Password
-	// connect.Will = c.cfg.WillMessage
-	err = conn.Send(connect, false)
+	if w := c.cfg.Will; w != nil {
+		connect.Will = &Message{Topic: w.Topic, Payload: []byte(w.Payload), QOS: w.QOS, Retain: w.Retain}
+	}
+	var pkt Packet = connect
+	if c.version == Version5 {
+		pkt = &Connect5{
+			Connect: connect,
+			Properties: Properties{
+				SessionExpiryInterval: uint32(c.cfg.SessionExpiry.Seconds()),
+				TopicAliasMaximum:     c.cfg.TopicAliasMaximum,
+				UserProperties:        c.cfg.UserProperties,
+			},
+		}
+	}
+	err = conn.Send(pkt, false)
 	if err != nil {
 		conn.Close()
 		return nil, err
 	}
 
 	s := &stream{
-		cli:     c,
-		conn:    conn,
-		future:  NewFuture(),
-		tracker: NewTracker(c.cfg.KeepAlive),
+		cli:       c,
+		conn:      conn,
+		future:    NewFuture(),
+		tracker:   NewTracker(c.cfg.KeepAlive),
+		keepalive: c.cfg.KeepAlive,
+		rotated:   rotated,
 	}
 	s.tomb.Go(s.receiving)
-	if c.cfg.KeepAlive > 0 {
+	err = s.future.Wait(c.cfg.Timeout)
+	if err != nil {
+		if s.close() == errFallbackVersion {
+			return nil, errFallbackVersion
+		}
+		return nil, err
+	}
+	// the keep alive may be overridden by the server of mqtt 5.0 in connack
+	if s.keepalive > 0 {
 		s.tomb.Go(s.pinging)
 	}
-	err = s.future.Wait(c.cfg.Timeout)
+	err = s.resubscribe()
 	if err != nil {
 		s.close()
 		return nil, err
@@ -61,9 +128,8 @@ func (c *Client) connect() (*stream, error) {
 }
 
 func (s *stream) send(pkt Packet, async bool) error {
-	s.tracker.Reset()
-
 	s.mu.Lock()
+	s.tracker.Reset()
 	err := s.conn.Send(pkt, async)
 	s.mu.Unlock()
 	if err != nil {
@@ -82,9 +148,25 @@ func (s *stream) sending(curr Packet) Packet {
 	s.cli.log.Info("client starts to send packets")
 	defer s.cli.log.Info("client has stopped sending packets")
 
+	ctx, cancel := s.flowContext()
+	defer cancel()
+
 	var err error
+	s.cli.window.reset()
+	for _, pkt := range s.cli.resends() {
+		if p := publishOf(pkt); p != nil {
+			s.cli.window.add(p.ID)
+		}
+		err = s.send(pkt, true)
+		if err != nil {
+			return curr
+		}
+	}
 	if curr != nil {
-		err = s.send(curr, true)
+		if s.throttle(ctx, curr) != nil {
+			return curr
+		}
+		curr, err = s.forward(curr)
 		if err != nil {
 			return curr
 		}
@@ -92,18 +174,39 @@ func (s *stream) sending(curr Packet) Packet {
 	for {
 		select {
 		case pkt := <-s.cli.cache:
-			err = s.send(pkt, true)
+			metrics.ClientCacheMessages.Dec(metrics.ProtocolMQTT)
+			if s.throttle(ctx, pkt) != nil {
+				return pkt
+			}
+			pkt, err = s.forward(pkt)
 			if err != nil {
 				return pkt
 			}
-		case <-s.cli.tomb.Dying():
+		case <-s.cli.sup.Dying():
 			return nil
 		case <-s.tomb.Dying():
 			return nil
+		case <-s.rotated:
+			s.cli.log.Info("certificate is rotated, client reconnects")
+			return nil
 		}
 	}
 }
 
+// forward sends the packet, returns the packet to send again after reconnection if failed,
+// which is nil if the packet is tracked in the session since it is resent anyway
+func (s *stream) forward(pkt Packet) (Packet, error) {
+	tracked := s.cli.track(pkt)
+	err := s.send(pkt, true)
+	if err == nil && isPublish(pkt) {
+		metrics.ObserveClientMessage(metrics.ProtocolMQTT, metrics.StateSent)
+	}
+	if err != nil && tracked {
+		return nil, err
+	}
+	return pkt, err
+}
+
 func (s *stream) receiving() error {
 	s.cli.log.Info("client starts to receive packets")
 	defer s.cli.log.Info("client has stopped receiving packets")
@@ -127,28 +230,47 @@ func (s *stream) receiving() error {
 				s.die("failed to handle connack", err)
 				return err
 			}
+			switch p := pkt.(type) {
+			case *Connack:
+				s.present = p.SessionPresent
+			case *Connack5:
+				s.present = p.SessionPresent
+			}
+			if p, ok := pkt.(*Connack5); ok && p.Properties.ServerKeepAlive > 0 {
+				s.mu.Lock()
+				s.keepalive = time.Duration(p.Properties.ServerKeepAlive) * time.Second
+				s.tracker = NewTracker(s.keepalive)
+				s.mu.Unlock()
+			}
 			s.future.Complete()
 			continue
 		}
 
 		switch p := pkt.(type) {
 		case *Publish:
-			qos := p.Message.QOS
-			uerr := s.cli.onPublish(p)
-			if uerr != nil {
-				s.cli.log.Warn("failed to handle publish packet in user code", log.Error(uerr))
-			} else if !s.cli.cfg.DisableAutoAck && qos == 1 {
-				ack := NewPuback()
-				ack.ID = p.ID
-				err = s.send(ack, true)
-			}
+			err = s.onPublish(p, func() error { return s.cli.onPublish(p) })
+		case *Publish5:
+			err = s.onPublish(p.Publish, func() error { return s.cli.onPublish5(p) })
 		case *Puback:
 			err = s.cli.onPuback(p)
+		case *Puback5:
+			err = s.cli.onPuback5(p)
+		case *Pubrec:
+			err = s.onPubrec(p)
+		case *Pubrel:
+			err = s.onPubrel(p)
+		case *Pubcomp:
+			err = s.cli.onPubcomp(p)
 		case *Suback:
-			err = s.cli.onSuback(p)
+			err = s.onSuback(p)
+		case *Suback5:
+			err = s.onSuback(p.Suback)
+		case *Unsuback:
 		case *Pingresp:
 			s.tracker.Pong()
-		case *Connack:
+		case *Disconnect5:
+			err = s.cli.onDisconnect(p)
+		case *Connack, *Connack5:
 			err = ErrClientAlreadyConnecting
 		default:
 			err = fmt.Errorf("packet (%v) not supported", p)
@@ -161,6 +283,109 @@ func (s *stream) receiving() error {
 	}
 }
 
+// resubscribe replays the subscriptions after reconnection and waits for the suback, unless the session is present on server
+func (s *stream) resubscribe() error {
+	if !s.cli.connected || s.cli.cfg.DisableResubscribe || s.present {
+		return nil
+	}
+	subs := s.cli.subscriptions()
+	if len(subs) == 0 {
+		return nil
+	}
+	subscribe := NewSubscribe()
+	subscribe.ID = s.cli.ids.NextID()
+	subscribe.Subscriptions = subs
+	s.cli.trackSubscriptions(subscribe.ID, subs)
+	resub := NewFuture()
+	s.mu.Lock()
+	s.resub = resub
+	s.resubID = subscribe.ID
+	s.mu.Unlock()
+	err := s.send(subscribe, false)
+	if err != nil {
+		return err
+	}
+	err = resub.Wait(s.cli.cfg.Timeout)
+	if err != nil {
+		if !s.tomb.Alive() && s.tomb.Err() != nil {
+			return s.tomb.Err()
+		}
+		return err
+	}
+	s.cli.log.Info("client has resubscribed", log.Any("subscriptions", len(subs)))
+	return nil
+}
+
+func (s *stream) onSuback(p *Suback) error {
+	err := s.cli.onSuback(p)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	resub := s.resub
+	if resub != nil && p.ID == s.resubID {
+		s.resub = nil
+	} else {
+		resub = nil
+	}
+	s.mu.Unlock()
+	if resub != nil {
+		resub.Complete()
+	}
+	return nil
+}
+
+// onPublish handles the publish packet by user code, the qos 2 publish packet is handled only once until released by server
+func (s *stream) onPublish(p *Publish, handle func() error) error {
+	qos := p.Message.QOS
+	if qos == QOSExactlyOnce && s.cli.received(p.ID) {
+		// the duplicate is acknowledged anyway since handled before
+		return s.send(&Pubrec{ID: p.ID}, true)
+	}
+	err := handle()
+	if qos == QOSExactlyOnce && err == nil {
+		s.cli.receive(p.ID)
+	}
+	return s.ack(p.ID, qos, err)
+}
+
+// ack acknowledges the publish packet of qos 1 by puback and the one of qos 2 by pubrec
+// if handled by user code and the auto ack is enabled
+func (s *stream) ack(id ID, qos QOS, uerr error) error {
+	if uerr != nil {
+		s.cli.log.Warn("failed to handle publish packet in user code", log.Error(uerr))
+		return nil
+	}
+	if s.cli.cfg.DisableAutoAck {
+		return nil
+	}
+	switch qos {
+	case QOSAtLeastOnce:
+		return s.send(&Puback{ID: id}, true)
+	case QOSExactlyOnce:
+		return s.send(&Pubrec{ID: id}, true)
+	default:
+		return nil
+	}
+}
+
+// onPubrec releases the qos 2 publish packet sent, the pubrel is tracked and resent after reconnection until completed
+func (s *stream) onPubrec(p *Pubrec) error {
+	pubrel := &Pubrel{ID: p.ID}
+	_, err := s.cli.persist(pubrel)
+	if err != nil {
+		return err
+	}
+	_, err = s.forward(pubrel)
+	return err
+}
+
+// onPubrel completes the qos 2 publish packet received
+func (s *stream) onPubrel(p *Pubrel) error {
+	s.cli.release(p.ID)
+	return s.send(&Pubcomp{ID: p.ID}, true)
+}
+
 func (s *stream) pinging() error {
 	s.cli.log.Info("client starts to send pings")
 	defer s.cli.log.Info("client has stopped sending pings")
@@ -188,7 +413,7 @@ func (s *stream) pinging() error {
 		select {
 		case <-time.After(window):
 			continue
-		case <-s.cli.tomb.Dying():
+		case <-s.cli.sup.Dying():
 			return nil
 		case <-s.tomb.Dying():
 			return nil
@@ -200,10 +425,24 @@ func (s *stream) die(msg string, err error) {
 	s.once.Do(func() {
 		s.future.Cancel()
 		s.tomb.Kill(err)
+		s.mu.Lock()
+		if s.resub != nil {
+			s.resub.Cancel()
+		}
+		s.mu.Unlock()
+		// the callers of PublishSync are notified of the disconnection
+		if err == nil {
+			s.cli.waiters.fail(ErrClientAlreadyClosed)
+		} else if err != errFallbackVersion {
+			s.cli.waiters.fail(err)
+		}
 		if err == nil {
 			s.send(NewDisconnect(), false)
 		}
-		s.cli.onError(msg, err)
+		// the fallback of version is not reported
+		if err != errFallbackVersion {
+			s.cli.onError(msg, err)
+		}
 	})
 }
 
@@ -0,0 +1,83 @@
+package mqtt
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/baetyl/baetyl-go/metrics"
+)
+
+// waiters the callers of PublishSync waiting for the acknowledgements of the packet ids
+type waiters struct {
+	chs map[ID]chan error
+	mu  sync.Mutex
+}
+
+func newWaiters() *waiters {
+	return &waiters{chs: map[ID]chan error{}}
+}
+
+func (w *waiters) add(id ID) chan error {
+	ch := make(chan error, 1)
+	w.mu.Lock()
+	w.chs[id] = ch
+	w.mu.Unlock()
+	return ch
+}
+
+func (w *waiters) remove(id ID) {
+	w.mu.Lock()
+	delete(w.chs, id)
+	w.mu.Unlock()
+}
+
+// complete notifies the waiter of the id if exists, only the first result is notified
+func (w *waiters) complete(id ID, err error) {
+	w.mu.Lock()
+	ch, ok := w.chs[id]
+	delete(w.chs, id)
+	w.mu.Unlock()
+	if ok {
+		ch <- err
+	}
+}
+
+// fail notifies all the waiters with the error, such as the error of disconnection
+func (w *waiters) fail(err error) {
+	w.mu.Lock()
+	chs := w.chs
+	w.chs = map[ID]chan error{}
+	w.mu.Unlock()
+	for _, ch := range chs {
+		ch <- err
+	}
+}
+
+// PublishSync sends a publish packet and blocks until it is acknowledged by puback (qos 1) or pubcomp (qos 2),
+// returns an error if the context expires, the message is rejected or the client is disconnected before acknowledged,
+// the message may be delivered anyway in the case of error, since it may be resent after reconnection.
+// The publish packet of qos 0 returns once it is queued to send.
+func (c *Client) PublishSync(ctx context.Context, qos QOS, topic string, payload []byte) error {
+	publish := c.newPublish(qos, topic, payload, 0, false, false)
+	if qos == QOSAtMostOnce {
+		return c.SendContext(ctx, publish)
+	}
+	start := time.Now()
+	ch := c.waiters.add(publish.ID)
+	defer c.waiters.remove(publish.ID)
+	if err := c.SendContext(ctx, publish); err != nil {
+		return err
+	}
+	select {
+	case err := <-ch:
+		if err == nil {
+			metrics.ObserveClientCall(metrics.ProtocolMQTT, start)
+		}
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.sup.Dying():
+		return ErrClientAlreadyClosed
+	}
+}
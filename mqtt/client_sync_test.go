@@ -0,0 +1,86 @@
+package mqtt
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/baetyl/baetyl-go/flow"
+	"github.com/baetyl/baetyl-go/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMqttClientPublishSync(t *testing.T) {
+	newPub := func(id ID, qos QOS, payload string) *Publish {
+		pub := NewPublish()
+		pub.ID = id
+		pub.Message = Message{Topic: "test", Payload: []byte(payload), QOS: qos}
+		return pub
+	}
+	pub0 := newPub(0, 0, "a")
+	pub1 := newPub(1, 1, "b")
+	pub2 := newPub(2, 2, "c")
+	pub3 := newPub(3, 1, "d")
+	pub4 := newPub(4, 1, "e")
+
+	broker1 := flow.New().Debug().
+		Receive(connectPacket()).
+		Send(connackPacket()).
+		Receive(pub0).
+		Receive(pub1).
+		Send(&Puback{ID: 1}).
+		Receive(pub2).
+		Send(&Pubrec{ID: 2}).
+		Receive(&Pubrel{ID: 2}).
+		Send(&Pubcomp{ID: 2}).
+		Receive(pub3). // not acknowledged
+		Receive(pub4).
+		Close()
+	reconnected := make(chan struct{})
+	broker2 := flow.New().Debug().
+		Receive(connectPacket()).
+		Send(connackPacket()).
+		Run(func() { close(reconnected) }).
+		Receive(disconnectPacket()).
+		End()
+
+	done, port := initMockBroker(t, broker1, broker2)
+
+	sent := metrics.ClientMessagesTotal.Value(metrics.ProtocolMQTT, metrics.StateSent)
+	acked := metrics.ClientMessagesTotal.Value(metrics.ProtocolMQTT, metrics.StateAcked)
+	reconnects := metrics.ClientReconnectsTotal.Value(metrics.ProtocolMQTT)
+	conns := metrics.ClientConnections.Value(metrics.ProtocolMQTT)
+	calls := metrics.ClientCallDuration.Count(metrics.ProtocolMQTT)
+
+	cc := newConfig(port)
+	obs := newMockObserver(t)
+	cli, err := NewClient(cc, obs)
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	assert.NoError(t, cli.PublishSync(ctx, 0, "test", []byte("a")))
+	assert.NoError(t, cli.PublishSync(ctx, 1, "test", []byte("b")))
+	assert.NoError(t, cli.PublishSync(ctx, 2, "test", []byte("c")))
+	obs.assertPkts(&Puback{ID: 1}, &Puback{ID: 2})
+
+	tctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, cli.PublishSync(tctx, 1, "test", []byte("d")))
+
+	// disconnected before acknowledged
+	assert.Equal(t, io.EOF, cli.PublishSync(ctx, 1, "test", []byte("e")))
+	obs.assertErrs(io.EOF)
+
+	<-reconnected
+	assert.NoError(t, cli.Close())
+	safeReceive(done)
+	assert.Equal(t, ErrClientAlreadyClosed, cli.PublishSync(ctx, 1, "test", []byte("f")))
+
+	// the metrics populated by the client
+	assert.Equal(t, sent+5, metrics.ClientMessagesTotal.Value(metrics.ProtocolMQTT, metrics.StateSent))
+	assert.Equal(t, acked+2, metrics.ClientMessagesTotal.Value(metrics.ProtocolMQTT, metrics.StateAcked))
+	assert.Equal(t, reconnects+1, metrics.ClientReconnectsTotal.Value(metrics.ProtocolMQTT))
+	assert.Equal(t, conns, metrics.ClientConnections.Value(metrics.ProtocolMQTT))
+	assert.Equal(t, calls+2, metrics.ClientCallDuration.Count(metrics.ProtocolMQTT))
+}
@@ -3,9 +3,13 @@ package mqtt
 import (
 	"errors"
 	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	baetylerrors "github.com/baetyl/baetyl-go/errors"
 	"github.com/baetyl/baetyl-go/flow"
 	"github.com/baetyl/baetyl-go/log"
 	"github.com/baetyl/baetyl-go/utils"
@@ -66,6 +70,35 @@ func TestMqttClientConnectWithCredentials(t *testing.T) {
 	safeReceive(done)
 }
 
+func TestMqttClientConnectWithWill(t *testing.T) {
+	connect := connectPacket()
+	connect.KeepAlive = 10
+	connect.Will = &Message{Topic: "will", Payload: []byte("bye"), QOS: 1, Retain: true}
+
+	broker := flow.New().Debug().
+		Receive(connect).
+		Send(connackPacket()).
+		Receive(disconnectPacket()).
+		End()
+
+	done, port := initMockBroker(t, broker)
+
+	cc := newConfig(port)
+	cc.KeepAlive = 10 * time.Second
+	cc.Will = &WillConfig{Topic: "will", Payload: "bye", QOS: 1, Retain: true}
+	obs := newMockObserver(t)
+	cli, err := NewClient(cc, obs)
+	assert.NoError(t, err)
+	assert.NotNil(t, cli)
+	time.Sleep(100 * time.Millisecond)
+	assert.NoError(t, cli.Close())
+	safeReceive(done)
+
+	cc.Will = &WillConfig{Topic: "will/#"}
+	_, err = NewClient(cc, obs)
+	assert.EqualError(t, err, "will (topic=will/#, qos=0) is invalid")
+}
+
 func TestMqttClientConnectionDenied(t *testing.T) {
 	connack := connackPacket()
 	connack.ReturnCode = NotAuthorized
@@ -88,6 +121,16 @@ func TestMqttClientConnectionDenied(t *testing.T) {
 	safeReceive(done)
 }
 
+func TestMqttConnackError(t *testing.T) {
+	assert.NoError(t, ConnackError(ConnectionAccepted))
+	err := ConnackError(BadUsernameOrPassword)
+	assert.EqualError(t, err, "connection refused: bad user name or password")
+	assert.Equal(t, baetylerrors.CodeUnauthenticated, baetylerrors.CodeOf(err))
+	assert.Equal(t, baetylerrors.CodePermissionDenied, baetylerrors.CodeOf(ConnackError(NotAuthorized)))
+	assert.Equal(t, baetylerrors.CodeUnavailable, baetylerrors.CodeOf(ConnackError(ServerUnavailable)))
+	assert.Equal(t, baetylerrors.CodeUnavailable, baetylerrors.CodeOf(ErrClientAlreadyClosed))
+}
+
 func TestMqttClientExpectedConnack(t *testing.T) {
 	broker := flow.New().Debug().
 		Receive(connectPacket()).
@@ -311,6 +354,81 @@ func TestMqttClientPublishSubscribeQOS1(t *testing.T) {
 	safeReceive(done)
 }
 
+func TestMqttClientPublishSubscribeQOS2(t *testing.T) {
+	publish := NewPublish()
+	publish.Message.Topic = "test"
+	publish.Message.Payload = []byte("test")
+	publish.Message.QOS = 2
+	publish.ID = 1
+
+	pubrec := NewPubrec()
+	pubrec.ID = 1
+	pubrel := NewPubrel()
+	pubrel.ID = 1
+	pubcomp := NewPubcomp()
+	pubcomp.ID = 1
+
+	cmd := NewPublish()
+	cmd.Message.Topic = "cmd"
+	cmd.Message.Payload = []byte("cmd")
+	cmd.Message.QOS = 2
+	cmd.ID = 5
+	dup := *cmd
+	dup.Dup = true
+
+	cmdrec := NewPubrec()
+	cmdrec.ID = 5
+	cmdrel := NewPubrel()
+	cmdrel.ID = 5
+	cmdcomp := NewPubcomp()
+	cmdcomp.ID = 5
+
+	completed := make(chan struct{})
+	broker1 := flow.New().Debug().
+		Receive(connectPacket()).
+		Send(connackPacket()).
+		Receive(publish).
+		Send(pubrec).
+		Receive(pubrel).
+		Close()
+	// the pubrel is resent after reconnection, and the duplicate is not handled again
+	broker2 := flow.New().Debug().
+		Receive(connectPacket()).
+		Send(connackPacket()).
+		Receive(pubrel).
+		Send(pubcomp).
+		Send(cmd).
+		Receive(cmdrec).
+		Send(&dup).
+		Receive(cmdrec).
+		Send(cmdrel).
+		Receive(cmdcomp).
+		Run(func() { close(completed) }).
+		Receive(disconnectPacket()).
+		End()
+
+	done, port := initMockBroker(t, broker1, broker2)
+
+	cc := newConfig(port)
+	cc.DisableAutoAck = false
+	obs := newMockObserver(t)
+	cli, err := NewClient(cc, obs)
+	assert.NoError(t, err)
+	assert.NotNil(t, cli)
+
+	err = cli.Publish(publish.Message.QOS, publish.Message.Topic, publish.Message.Payload, publish.ID, publish.Message.Retain, publish.Dup)
+	assert.NoError(t, err)
+
+	obs.assertErrs(io.EOF)
+	// the pubcomp is reported as the puback
+	obs.assertPkts(&Puback{ID: 1}, cmd)
+
+	<-completed
+	assert.NoError(t, cli.Close())
+	safeReceive(done)
+	assert.Len(t, obs.pkts, 0)
+}
+
 func TestMqttClientAutoAck(t *testing.T) {
 	subscribe := NewSubscribe()
 	subscribe.Subscriptions = []Subscription{{Topic: "test", QOS: 1}}
@@ -566,3 +684,175 @@ func TestMqttClientReconnect2(t *testing.T) {
 	assert.NoError(t, cli.Close())
 	safeReceive(done)
 }
+
+func TestMqttClientResubscribe(t *testing.T) {
+	subscribe := NewSubscribe()
+	subscribe.Subscriptions = []Subscription{{Topic: "a", QOS: 1}, {Topic: "b"}}
+	subscribe.ID = 1
+	suback := NewSuback()
+	suback.ReturnCodes = []QOS{1, 0}
+	suback.ID = 1
+
+	unsubscribe := NewUnsubscribe()
+	unsubscribe.Topics = []string{"b"}
+	unsubscribe.ID = 2
+	unsuback := NewUnsuback()
+	unsuback.ID = 2
+
+	resubscribe := NewSubscribe()
+	resubscribe.Subscriptions = []Subscription{{Topic: "a", QOS: 1}}
+	resubscribe.ID = 3
+	resuback := NewSuback()
+	resuback.ReturnCodes = []QOS{1}
+	resuback.ID = 3
+
+	connack := connackPacket()
+	connack.SessionPresent = true
+
+	reconnected := make(chan struct{})
+	broker1 := flow.New().Debug().
+		Receive(connectPacket()).
+		Send(connackPacket()).
+		Receive(subscribe).
+		Send(suback).
+		Receive(unsubscribe).
+		Send(unsuback).
+		Close()
+	broker2 := flow.New().Debug().
+		Receive(connectPacket()).
+		Send(connackPacket()).
+		Receive(resubscribe).
+		Send(resuback).
+		Close()
+	// the subscriptions are not replayed since the session is present
+	broker3 := flow.New().Debug().
+		Receive(connectPacket()).
+		Send(connack).
+		Run(func() { close(reconnected) }).
+		Receive(disconnectPacket()).
+		End()
+
+	done, port := initMockBroker(t, broker1, broker2, broker3)
+
+	cc := newConfig(port)
+	obs := newMockObserver(t)
+	cli, err := NewClient(cc, obs)
+	assert.NoError(t, err)
+
+	assert.NoError(t, cli.Subscribe(subscribe.Subscriptions))
+	assert.NoError(t, cli.Unsubscribe(unsubscribe.Topics))
+	obs.assertErrs(io.EOF)
+	obs.assertErrs(io.EOF)
+
+	<-reconnected
+	assert.NoError(t, cli.Close())
+	safeReceive(done)
+}
+
+func TestMqttClientResubscribeDisabled(t *testing.T) {
+	subscribe := NewSubscribe()
+	subscribe.Subscriptions = []Subscription{{Topic: "a"}}
+	subscribe.ID = 1
+	suback := NewSuback()
+	suback.ReturnCodes = []QOS{0}
+	suback.ID = 1
+
+	reconnected := make(chan struct{})
+	broker1 := flow.New().Debug().
+		Receive(connectPacket()).
+		Send(connackPacket()).
+		Receive(subscribe).
+		Send(suback).
+		Close()
+	broker2 := flow.New().Debug().
+		Receive(connectPacket()).
+		Send(connackPacket()).
+		Run(func() { close(reconnected) }).
+		Receive(disconnectPacket()).
+		End()
+
+	done, port := initMockBroker(t, broker1, broker2)
+
+	cc := newConfig(port)
+	cc.DisableResubscribe = true
+	obs := newMockObserver(t)
+	cli, err := NewClient(cc, obs)
+	assert.NoError(t, err)
+
+	assert.NoError(t, cli.Subscribe(subscribe.Subscriptions))
+	obs.assertErrs(io.EOF)
+
+	<-reconnected
+	assert.NoError(t, cli.Close())
+	safeReceive(done)
+}
+
+func TestMqttClientReconnectOnCertRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", t.Name())
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	cert, err := ioutil.ReadFile("../example/var/lib/baetyl/testcert/client.pem")
+	assert.NoError(t, err)
+	key, err := ioutil.ReadFile("../example/var/lib/baetyl/testcert/client.key")
+	assert.NoError(t, err)
+	certFile, keyFile := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+	assert.NoError(t, ioutil.WriteFile(certFile, cert, 0644))
+	assert.NoError(t, ioutil.WriteFile(keyFile, key, 0644))
+
+	conns := make(chan Connection, 10)
+	handle := func(conn Connection) {
+		if _, err := conn.Receive(); err != nil {
+			return
+		}
+		conns <- conn
+		conn.Send(NewConnack(), false)
+		for {
+			if _, err := conn.Receive(); err != nil {
+				return
+			}
+		}
+	}
+	m, err := NewTransport(ServerConfig{
+		Addresses: []string{"ssl://127.0.0.1:0"},
+		Certificate: utils.Certificate{
+			CA:   "../example/var/lib/baetyl/testcert/ca.pem",
+			Key:  "../example/var/lib/baetyl/testcert/server.key",
+			Cert: "../example/var/lib/baetyl/testcert/server.pem",
+		},
+	}, handle)
+	assert.NoError(t, err)
+	defer m.Close()
+
+	cfg := ClientConfig{
+		Address:      getURL(m.servers[0], "ssl"),
+		CleanSession: true,
+		Certificate: utils.Certificate{
+			CA:                 "../example/var/lib/baetyl/testcert/ca.pem",
+			Cert:               certFile,
+			Key:                keyFile,
+			InsecureSkipVerify: true,
+			WatchInterval:      10 * time.Millisecond,
+		},
+	}
+	utils.SetDefaults(&cfg)
+	c, err := NewClient(cfg, nil)
+	assert.NoError(t, err)
+	defer c.Close()
+
+	var first Connection
+	select {
+	case first = <-conns:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out to wait connection")
+	}
+	_, cn := GetTLSCommonName(first)
+	assert.True(t, cn)
+
+	// the certificate renewed makes the client reconnect
+	assert.NoError(t, ioutil.WriteFile(certFile, append(cert, '\n'), 0644))
+	select {
+	case <-conns:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out to wait reconnection")
+	}
+}
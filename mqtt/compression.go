@@ -0,0 +1,54 @@
+package mqtt
+
+import (
+	"bytes"
+
+	"github.com/baetyl/baetyl-go/utils"
+)
+
+// the magic (and version) of compressed payload, followed by the payload compressed by gzip,
+// since the mqtt 3.1.1 has no property to negotiate the content encoding
+var compressMagic = []byte{0xba, 0xe7, 0x02}
+
+// MaxPayloadSize the max size of mqtt payload
+const MaxPayloadSize = 268435455
+
+// the user property of mqtt 5.0 marking the payload compressed, the payload is only sniffed by the magic
+// if compression is enabled and not marked, such as published by the clients of mqtt 3.1.1
+const (
+	PropertyContentEncoding = "content-encoding"
+	ContentEncodingGzip     = "gzip"
+)
+
+// CompressPayload compresses the payload by gzip if its size reaches the threshold (if positive),
+// the payload is returned as is if the compression doesn't reduce its size
+func CompressPayload(payload []byte, threshold int) ([]byte, error) {
+	if threshold <= 0 || len(payload) < threshold {
+		return payload, nil
+	}
+	data, err := utils.Gzip(payload)
+	if err != nil {
+		return nil, err
+	}
+	if len(compressMagic)+len(data) >= len(payload) {
+		return payload, nil
+	}
+	return append(append(make([]byte, 0, len(compressMagic)+len(data)), compressMagic...), data...), nil
+}
+
+// DecompressPayload decompresses the payload compressed by CompressPayload, others are returned as is,
+// it should only be called if the payload is marked as compressed or the compression is enabled,
+// otherwise a foreign payload starting with the magic is mistaken as compressed
+func DecompressPayload(payload []byte) ([]byte, error) {
+	if !bytes.HasPrefix(payload, compressMagic) {
+		return payload, nil
+	}
+	return utils.Gunzip(payload[len(compressMagic):], MaxPayloadSize)
+}
+
+// markCompressed returns a copy of the properties with the user property marking the payload compressed
+func markCompressed(props Properties) Properties {
+	props.UserProperties = append(append(make([]UserProperty, 0, len(props.UserProperties)+1), props.UserProperties...),
+		UserProperty{Key: PropertyContentEncoding, Value: ContentEncodingGzip})
+	return props
+}
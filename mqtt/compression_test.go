@@ -0,0 +1,109 @@
+package mqtt
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompressPayload(t *testing.T) {
+	plain := []byte(strings.Repeat("baetyl", 100))
+
+	res, err := CompressPayload(plain, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, plain, res)
+	res, err = CompressPayload(plain, len(plain)+1)
+	assert.NoError(t, err)
+	assert.Equal(t, plain, res)
+
+	zipped, err := CompressPayload(plain, len(plain))
+	assert.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(zipped, []byte{0xba, 0xe7, 0x02}))
+	assert.True(t, len(zipped) < len(plain))
+	res, err = DecompressPayload(zipped)
+	assert.NoError(t, err)
+	assert.Equal(t, plain, res)
+
+	// the payload not reduced is kept as is
+	res, err = CompressPayload([]byte("abc"), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("abc"), res)
+
+	// the payload not compressed is returned as is
+	res, err = DecompressPayload([]byte("abc"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("abc"), res)
+	res, err = DecompressPayload(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, res)
+
+	_, err = DecompressPayload(append([]byte{0xba, 0xe7, 0x02}, "bad"...))
+	assert.Error(t, err)
+}
+
+func TestClientCompression(t *testing.T) {
+	var cc ClientConfig
+	assert.NoError(t, utils.SetDefaults(&cc))
+	cc.CompressThreshold = 100
+	cc.Encryption.Keys = []TopicKeys{{Topic: "secure/#", Keys: []EncryptionKey{newTestKey(t, "k1")}}}
+	obs := newMockObserver(t)
+	cli, err := NewClient(cc, obs)
+	assert.NoError(t, err)
+	defer cli.Close()
+
+	plain := []byte(strings.Repeat("baetyl", 100))
+	for _, topic := range []string{"a", "secure/a"} {
+		pkt := NewPublish()
+		pkt.Message.Topic = topic
+		pkt.Message.Payload = plain
+		assert.NoError(t, cli.Send(pkt))
+		sent := (<-cli.cache).(*Publish5)
+		assert.Equal(t, plain, pkt.Message.Payload)
+		assert.True(t, len(sent.Message.Payload) < len(plain))
+		v, ok := sent.Properties.UserProperty(PropertyContentEncoding)
+		assert.True(t, ok)
+		assert.Equal(t, ContentEncodingGzip, v)
+
+		// decompressed if marked, or sniffed if not marked, such as published by the clients of mqtt 3.1.1
+		cp := *sent.Publish
+		assert.NoError(t, cli.onPublish5(sent))
+		res := (<-obs.pkts).(*Publish)
+		assert.Equal(t, plain, res.Message.Payload)
+		assert.NoError(t, cli.onPublish(&cp))
+		res = (<-obs.pkts).(*Publish)
+		assert.Equal(t, plain, res.Message.Payload)
+	}
+
+	// the small payload is not compressed
+	pkt := NewPublish()
+	pkt.Message.Topic = "a"
+	pkt.Message.Payload = []byte("hello")
+	assert.NoError(t, cli.Send(pkt))
+	sent := (<-cli.cache).(*Publish)
+	assert.Equal(t, "hello", string(sent.Message.Payload))
+
+	// the payload starting with the magic is kept as is if the compression is disabled and not marked
+	cc.CompressThreshold = 0
+	cc.Encryption.Keys = nil
+	obs2 := newMockObserver(t)
+	cli2, err := NewClient(cc, obs2)
+	assert.NoError(t, err)
+	defer cli2.Close()
+	foreign := append([]byte{0xba, 0xe7, 0x02}, "foreign"...)
+	pkt = NewPublish()
+	pkt.Message.Topic = "a"
+	pkt.Message.Payload = foreign
+	assert.NoError(t, cli2.onPublish(pkt))
+	assert.Equal(t, foreign, (<-obs2.pkts).(*Publish).Message.Payload)
+	zipped, err := CompressPayload(plain, 1)
+	assert.NoError(t, err)
+	marked := NewPublish5()
+	marked.Message.Topic = "a"
+	marked.Message.Payload = zipped
+	marked.Properties = markCompressed(Properties{})
+	assert.NoError(t, cli2.onPublish5(marked))
+	assert.Equal(t, plain, (<-obs2.pkts).(*Publish).Message.Payload)
+}
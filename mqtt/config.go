@@ -1,6 +1,7 @@
 package mqtt
 
 import (
+	"context"
 	"time"
 
 	"github.com/baetyl/baetyl-go/utils"
@@ -18,17 +19,77 @@ type ServerConfig struct {
 	Certificate utils.Certificate `yaml:",inline" json:",inline"`
 }
 
+// BrokerConfig the config of the embedded broker
+type BrokerConfig struct {
+	Server         ServerConfig  `yaml:",inline" json:",inline"`
+	Store          StoreConfig   `yaml:"store" json:"store"`                                 // the sessions of clients not clean and the retained messages are persisted
+	ConnectTimeout time.Duration `yaml:"connectTimeout" json:"connectTimeout" default:"10s"` // the timeout to receive the connect packet after accepted
+}
+
+// StoreConfig the config of the persistence backend of broker
+type StoreConfig struct {
+	Driver  string        `yaml:"driver" json:"driver" default:"boltdb" validate:"regexp=^(boltdb|badger|memory)$"`
+	Path    string        `yaml:"path" json:"path" default:"var/lib/baetyl/mqtt"` // the directory of store
+	Timeout time.Duration `yaml:"timeout" json:"timeout" default:"5s"`            // the timeout to open the store file
+}
+
+// ClientStoreConfig the config of the persistence of client session
+type ClientStoreConfig struct {
+	Path    string        `yaml:"path" json:"path"`                    // the boltdb file of session, the session is not persisted if empty
+	Timeout time.Duration `yaml:"timeout" json:"timeout" default:"5s"` // the timeout to open the store file
+}
+
+// the back-pressure policies of the publish packets if the buffer of client is full
+const (
+	PolicyBlock      = "block"      // the caller is blocked until the buffer is available
+	PolicyDropOldest = "dropOldest" // the oldest publish packet in buffer is dropped
+	PolicyDropNewest = "dropNewest" // the new publish packet is dropped, and ErrClientMessageDropped is returned
+)
+
+// FlowControlConfig the config of outbound flow control of publish packets
+type FlowControlConfig struct {
+	MaxInflight int     `yaml:"maxInflight" json:"maxInflight" validate:"min=0"`                                         // the max qos 1 and 2 messages sent but not acknowledged, unlimited if zero
+	Rate        float64 `yaml:"rate" json:"rate" validate:"min=0"`                                                       // the messages per second sent, unlimited if zero
+	Burst       float64 `yaml:"burst" json:"burst" default:"1" validate:"min=0"`                                         // the seconds of rate allowed to burst
+	Policy      string  `yaml:"policy" json:"policy" default:"block" validate:"regexp=^(block|dropOldest|dropNewest)?$"` // the policy if the buffer is full
+}
+
 // ClientConfig mqtt client config
 type ClientConfig struct {
-	Address        string            `yaml:"address" json:"address"`
-	Username       string            `yaml:"username" json:"username"`
-	Password       string            `yaml:"password" json:"password"`
-	Certificate    utils.Certificate `yaml:",inline" json:",inline"`
-	ClientID       string            `yaml:"clientid" json:"clientid"`
-	CleanSession   bool              `yaml:"cleansession" json:"cleansession"`
-	KeepAlive      time.Duration     `yaml:"keepalive" json:"keepalive"` // keepalive not enabled by default
-	Timeout        time.Duration     `yaml:"timeout" json:"timeout" default:"30s"`
-	Interval       time.Duration     `yaml:"interval" json:"interval" default:"2m"`
-	BufferSize     int               `yaml:"buffersize" json:"buffersize" default:"10"`
-	DisableAutoAck bool              `yaml:"disableAutoAck" json:"disableAutoAck"`
+	Address            string            `yaml:"address" json:"address" validate:"regexp=^((tcp|mqtt|ssl|tls|mqtts|ws|wss)://.+)?$"`
+	Username           string            `yaml:"username" json:"username"`
+	Password           string            `yaml:"password" json:"password"`
+	Certificate        utils.Certificate `yaml:",inline" json:",inline"`
+	ClientID           string            `yaml:"clientid" json:"clientid"`
+	CleanSession       bool              `yaml:"cleansession" json:"cleansession"`
+	KeepAlive          time.Duration     `yaml:"keepalive" json:"keepalive"` // keepalive not enabled by default
+	Timeout            time.Duration     `yaml:"timeout" json:"timeout" default:"30s"`
+	Interval           time.Duration     `yaml:"interval" json:"interval" default:"2m"`
+	BufferSize         int               `yaml:"buffersize" json:"buffersize" default:"10"`
+	DisableAutoAck     bool              `yaml:"disableAutoAck" json:"disableAutoAck"`
+	Encryption         EncryptionConfig  `yaml:"encryption" json:"encryption"`                                               // the payload encryption is disabled if no key
+	CompressThreshold  utils.Size        `yaml:"compressThreshold" json:"compressThreshold"`                                 // the payloads reaching the threshold are compressed, disabled if zero
+	ProtocolVersion    int               `yaml:"protocolVersion" json:"protocolVersion" default:"4" validate:"min=3, max=5"` // 5 falls back to 4 if the server rejects mqtt 5.0
+	SessionExpiry      time.Duration     `yaml:"sessionExpiry" json:"sessionExpiry"`                                         // mqtt 5.0 only, the session ends when disconnected if zero
+	TopicAliasMaximum  uint16            `yaml:"topicAliasMaximum" json:"topicAliasMaximum"`                                 // mqtt 5.0 only, the max topic alias accepted from server
+	UserProperties     []UserProperty    `yaml:"userProperties" json:"userProperties"`                                       // mqtt 5.0 only, the user properties sent in connect
+	Store              ClientStoreConfig `yaml:"store" json:"store"`                                                         // the qos 1 messages not acknowledged are resent after restart if persisted
+	DisableResubscribe bool              `yaml:"disableResubscribe" json:"disableResubscribe"`                               // the subscriptions are not replayed after reconnection if disabled
+	WebSocket          WebSocketConfig   `yaml:"websocket" json:"websocket"`                                                 // used if the scheme of address is ws:// or wss://
+	Will               *WillConfig       `yaml:"will" json:"will"`                                                           // the last will published by server if the connection is lost
+	FlowControl        FlowControlConfig `yaml:"flowControl" json:"flowControl"`                                             // the publish packets are buffered in the size of BufferSize
+	// mqtt 5.0 only, the injector is called with the context before the publish packet is sent, such as by PublishContext,
+	// PublishSync or SendContext, to inject the trace context into the user properties, which are dropped if the connection is not mqtt 5.0
+	PropertiesInjector func(ctx context.Context, props *Properties) `yaml:"-" json:"-"`
+	// mqtt 5.0 only, the interceptor is called with the publish packet received and the handling by the router or observer,
+	// such as to start the span of the trace context extracted from the user properties
+	ReceiveInterceptor func(pkt *Publish5, handle func() error) error `yaml:"-" json:"-"`
+}
+
+// WillConfig the config of the last will message
+type WillConfig struct {
+	Topic   string `yaml:"topic" json:"topic" validate:"nonzero"`
+	Payload string `yaml:"payload" json:"payload"`
+	QOS     QOS    `yaml:"qos" json:"qos" validate:"min=0, max=2"`
+	Retain  bool   `yaml:"retain" json:"retain"`
 }
@@ -0,0 +1,311 @@
+package mqtt
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/256dpi/gomqtt/packet"
+	"github.com/256dpi/gomqtt/transport"
+	"github.com/baetyl/baetyl-go/errors"
+	"github.com/gorilla/websocket"
+)
+
+// carrier the stream of bytes under the connection
+type carrier interface {
+	io.ReadWriteCloser
+	SetReadDeadline(time.Time) error
+	LocalAddr() net.Addr
+	RemoteAddr() net.Addr
+}
+
+// conn5 the connection of mqtt 5.0, which encodes and decodes the packets by EncodePacket5 and DecodePacket5,
+// and resolves the topic aliases in both directions
+type conn5 struct {
+	carrier carrier
+	reader  *bufio.Reader
+	limit   int64
+	timeout time.Duration
+	sendMu  sync.Mutex
+	recvMu  sync.Mutex
+
+	mu          sync.Mutex
+	aliasMax    uint16            // the max topic alias accepted, sent in connect or connack
+	aliases     map[uint16]string // the topic aliases received
+	peerMax     uint16            // the max topic alias the peer accepts
+	peerAliases map[string]uint16 // the topic aliases sent
+	peerSizeMax uint32            // the max packet size the peer accepts
+}
+
+func newConn5(c carrier) *conn5 {
+	return &conn5{
+		carrier:     c,
+		reader:      bufio.NewReader(c),
+		aliases:     map[uint16]string{},
+		peerAliases: map[string]uint16{},
+	}
+}
+
+// upgrade5 speaks mqtt 5.0 over the underlying connection instead
+func upgrade5(conn Connection) (Connection, error) {
+	switch c := conn.(type) {
+	case *transport.NetConn:
+		return newConn5(c.UnderlyingConn()), nil
+	case *transport.WebSocketConn:
+		return newConn5(&wsCarrier{conn: c.UnderlyingConn()}), nil
+	default:
+		conn.Close()
+		return nil, errors.Coded(errors.CodeUnimplemented, "connection (%T) not supported by mqtt 5.0", conn)
+	}
+}
+
+// Send encodes and writes the packet, the packets are always written immediately
+func (c *conn5) Send(pkt packet.Generic, _ bool) error {
+	pkt = c.outbound(pkt)
+	buf := getBuffer()
+	defer putBuffer(buf)
+	err := encodePacket5(buf, pkt)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	sizeMax := c.peerSizeMax
+	c.mu.Unlock()
+	if sizeMax > 0 && uint32(buf.Len()) > sizeMax {
+		return errors.Coded(errors.CodeResourceExhausted, "packet size (%d) exceeds the maximum (%d) of server", buf.Len(), sizeMax)
+	}
+
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	_, err = c.carrier.Write(buf.Bytes())
+	if err != nil {
+		c.carrier.Close()
+		return err
+	}
+	return nil
+}
+
+// Receive reads and decodes the next packet
+func (c *conn5) Receive() (packet.Generic, error) {
+	c.recvMu.Lock()
+	defer c.recvMu.Unlock()
+
+	data, err := c.read()
+	if err != nil {
+		c.carrier.Close()
+		return nil, err
+	}
+	// the data is read for the packet only, which is referred by the payload without copying
+	pkt, err := decodePacket5(&reader{data: data, owned: true})
+	if err != nil {
+		c.carrier.Close()
+		return nil, err
+	}
+	pkt, err = c.inbound(pkt)
+	if err != nil {
+		c.carrier.Close()
+		return nil, err
+	}
+	return pkt, c.resetTimeout()
+}
+
+func (c *conn5) read() ([]byte, error) {
+	var header [5]byte
+	var err error
+	header[0], err = c.reader.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	var l, m, n int
+	for n = 1; ; n++ {
+		if n == 5 {
+			return nil, malformed("variable byte integer exceeds 4 bytes")
+		}
+		b, err := c.reader.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		header[n] = b
+		l += int(b&0x7f) << m
+		if b&0x80 == 0 {
+			n++
+			break
+		}
+		m += 7
+	}
+	if c.limit > 0 && int64(n+l) > c.limit {
+		return nil, packet.ErrReadLimitExceeded
+	}
+	data := make([]byte, n+l)
+	copy(data, header[:n])
+	_, err = io.ReadFull(c.reader, data[n:])
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// outbound records the properties sent, and replaces the topics of publish packets with aliases if allowed
+func (c *conn5) outbound(pkt packet.Generic) packet.Generic {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch p := pkt.(type) {
+	case *Connect5:
+		c.aliasMax = p.Properties.TopicAliasMaximum
+	case *Connack5:
+		c.aliasMax = p.Properties.TopicAliasMaximum
+	case *Publish:
+		if c.peerMax > 0 {
+			return c.alias(&Publish5{Publish: p})
+		}
+	case *Publish5:
+		if c.peerMax > 0 && p.Properties.TopicAlias == 0 {
+			return c.alias(p)
+		}
+	}
+	return pkt
+}
+
+// alias returns a copy of the publish packet with the topic alias
+func (c *conn5) alias(p *Publish5) *Publish5 {
+	topic := p.Message.Topic
+	a, ok := c.peerAliases[topic]
+	if !ok && len(c.peerAliases) >= int(c.peerMax) {
+		return p
+	}
+	cp := *p.Publish
+	res := &Publish5{Publish: &cp, Properties: p.Properties}
+	if ok {
+		cp.Message.Topic = ""
+	} else {
+		a = uint16(len(c.peerAliases) + 1)
+		c.peerAliases[topic] = a
+	}
+	res.Properties.TopicAlias = a
+	return res
+}
+
+// inbound records the properties received, and resolves the topic aliases of publish packets
+func (c *conn5) inbound(pkt packet.Generic) (packet.Generic, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch p := pkt.(type) {
+	case *Connect5:
+		c.peerMax = p.Properties.TopicAliasMaximum
+		c.peerSizeMax = p.Properties.MaximumPacketSize
+	case *Connack5:
+		c.peerMax = p.Properties.TopicAliasMaximum
+		c.peerSizeMax = p.Properties.MaximumPacketSize
+	case *Publish5:
+		a := p.Properties.TopicAlias
+		if a == 0 {
+			break
+		}
+		if a > c.aliasMax {
+			return nil, ReasonError(ReasonTopicAliasInvalid)
+		}
+		if p.Message.Topic != "" {
+			c.aliases[a] = p.Message.Topic
+		} else if topic, ok := c.aliases[a]; ok {
+			p.Message.Topic = topic
+		} else {
+			return nil, ReasonError(ReasonTopicAliasInvalid)
+		}
+		p.Properties.TopicAlias = 0
+	}
+	return pkt, nil
+}
+
+// Close closes the connection
+func (c *conn5) Close() error {
+	return c.carrier.Close()
+}
+
+// SetReadLimit sets the max size of packets to read
+func (c *conn5) SetReadLimit(limit int64) {
+	c.limit = limit
+}
+
+// SetReadTimeout sets the timeout to read the next packet, which is reset after each packet read
+func (c *conn5) SetReadTimeout(timeout time.Duration) {
+	c.timeout = timeout
+	c.resetTimeout()
+}
+
+func (c *conn5) resetTimeout() error {
+	if c.timeout > 0 {
+		return c.carrier.SetReadDeadline(time.Now().Add(c.timeout))
+	}
+	return c.carrier.SetReadDeadline(time.Time{})
+}
+
+// SetMaxWriteDelay does nothing since the packets are always written immediately
+func (c *conn5) SetMaxWriteDelay(time.Duration) {}
+
+// LocalAddr returns the local address
+func (c *conn5) LocalAddr() net.Addr {
+	return c.carrier.LocalAddr()
+}
+
+// RemoteAddr returns the remote address
+func (c *conn5) RemoteAddr() net.Addr {
+	return c.carrier.RemoteAddr()
+}
+
+// wsCarrier the stream of bytes over the binary messages of websocket,
+// a packet may be chunked over several messages and several packets may be coalesced to one message
+type wsCarrier struct {
+	conn   *websocket.Conn
+	reader io.Reader
+}
+
+func (s *wsCarrier) Read(buf []byte) (int, error) {
+	for {
+		if s.reader == nil {
+			mt, reader, err := s.conn.NextReader()
+			if _, ok := err.(*websocket.CloseError); ok {
+				return 0, io.EOF
+			} else if err != nil {
+				return 0, err
+			} else if mt != websocket.BinaryMessage {
+				return 0, transport.ErrNotBinary
+			}
+			s.reader = reader
+		}
+		n, err := s.reader.Read(buf)
+		if err == io.EOF {
+			s.reader = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (s *wsCarrier) Write(buf []byte) (int, error) {
+	err := s.conn.WriteMessage(websocket.BinaryMessage, buf)
+	if err != nil {
+		return 0, err
+	}
+	return len(buf), nil
+}
+
+func (s *wsCarrier) Close() error {
+	return s.conn.Close()
+}
+
+func (s *wsCarrier) SetReadDeadline(t time.Time) error {
+	return s.conn.SetReadDeadline(t)
+}
+
+func (s *wsCarrier) LocalAddr() net.Addr {
+	return s.conn.LocalAddr()
+}
+
+func (s *wsCarrier) RemoteAddr() net.Addr {
+	return s.conn.RemoteAddr()
+}
@@ -0,0 +1,100 @@
+package mqtt
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// benchCarrier discards the bytes written, and reads the bytes of data repeatedly
+type benchCarrier struct {
+	data []byte
+	pos  int
+}
+
+func (c *benchCarrier) Read(buf []byte) (int, error) {
+	if len(c.data) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(buf, c.data[c.pos:])
+	c.pos = (c.pos + n) % len(c.data)
+	return n, nil
+}
+
+func (c *benchCarrier) Write(buf []byte) (int, error)   { return len(buf), nil }
+func (c *benchCarrier) Close() error                    { return nil }
+func (c *benchCarrier) SetReadDeadline(time.Time) error { return nil }
+func (c *benchCarrier) LocalAddr() net.Addr             { return nil }
+func (c *benchCarrier) RemoteAddr() net.Addr            { return nil }
+
+func benchPublish() *Publish5 {
+	p := NewPublish5()
+	p.ID = 1
+	p.Message.QOS = 1
+	p.Message.Topic = "device/1/telemetry"
+	p.Message.Payload = bytes.Repeat([]byte("x"), 256)
+	p.Properties.AddUserProperty("k", "v")
+	return p
+}
+
+func TestConn5Buffer(t *testing.T) {
+	p := benchPublish()
+	data, err := EncodePacket5(p)
+	assert.NoError(t, err)
+	c := newConn5(&benchCarrier{data: data})
+	pkt1, err := c.Receive()
+	assert.NoError(t, err)
+	assert.Equal(t, p, pkt1)
+	pkt2, err := c.Receive()
+	assert.NoError(t, err)
+	// the payloads refer to the data read for each packet, not shared by packets
+	pkt2.(*Publish5).Message.Payload[0] = 'y'
+	assert.Equal(t, p.Message.Payload, pkt1.(*Publish5).Message.Payload)
+
+	// the large buffers are not pooled
+	b := getBuffer()
+	b.Grow(maxPooledBufferSize + 1)
+	putBuffer(b)
+	b = getBuffer()
+	assert.Equal(t, 0, b.Len())
+	putBuffer(b)
+}
+
+func BenchmarkEncodePacket5(b *testing.B) {
+	p := benchPublish()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := EncodePacket5(p); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkConn5Send(b *testing.B) {
+	c := newConn5(&benchCarrier{})
+	p := benchPublish()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := c.Send(p, false); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkConn5Receive(b *testing.B) {
+	data, err := EncodePacket5(benchPublish())
+	if err != nil {
+		b.Fatal(err)
+	}
+	c := newConn5(&benchCarrier{data: data})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.Receive(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package mqtt
+
+import (
+	"strconv"
+
+	"github.com/baetyl/baetyl-go/dedupe"
+)
+
+type dedupeObserver struct {
+	Observer
+	d     *dedupe.Deduper
+	scope string
+}
+
+// NewDedupeObserver wraps the observer to emulate the exactly once delivery of qos 2 over qos 1, the message redelivered
+// (with dup flag) and handled before is acknowledged without passing to the observer again, even across restarts
+func NewDedupeObserver(obs Observer, d *dedupe.Deduper, scope string) Observer {
+	return &dedupeObserver{Observer: obs, d: d, scope: scope}
+}
+
+// OnPublish handles publish packet
+func (o *dedupeObserver) OnPublish(pkt *Publish) error {
+	if pkt.Message.QOS == 0 {
+		return o.Observer.OnPublish(pkt)
+	}
+	// the packet id is reused after acknowledged, so the topic is hashed with the payload as well
+	id := strconv.FormatUint(uint64(pkt.ID), 10)
+	content := append(append([]byte(pkt.Message.Topic), 0), pkt.Message.Payload...)
+	if !pkt.Dup {
+		if err := o.Observer.OnPublish(pkt); err != nil {
+			return err
+		}
+		return o.d.Mark(o.scope, id, content)
+	}
+	_, err := o.d.Handle(o.scope, id, content, func() error {
+		return o.Observer.OnPublish(pkt)
+	})
+	return err
+}
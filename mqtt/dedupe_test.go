@@ -0,0 +1,70 @@
+package mqtt
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/baetyl/baetyl-go/dedupe"
+	"github.com/baetyl/baetyl-go/kv"
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDedupeObserver(t *testing.T) {
+	dir, err := ioutil.TempDir("", t.Name())
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	var kc kv.Config
+	assert.NoError(t, utils.SetDefaults(&kc))
+	kc.Path = filepath.Join(dir, "kv.db")
+	store, err := kv.New(kc)
+	assert.NoError(t, err)
+	defer store.Close()
+
+	mock := newMockObserver(t)
+	obs := NewDedupeObserver(mock, dedupe.NewDeduper(dedupe.Config{Prefix: "mqtt/", Window: time.Minute}, store), "c1")
+
+	pkt := NewPublish()
+	pkt.ID = 1
+	pkt.Message.Topic = "a"
+	pkt.Message.QOS = 1
+	pkt.Message.Payload = []byte("cmd")
+	assert.NoError(t, obs.OnPublish(pkt))
+	mock.assertPkts(pkt)
+
+	// the packet redelivered is not passed again
+	dup := NewPublish()
+	*dup = *pkt
+	dup.Dup = true
+	assert.NoError(t, obs.OnPublish(dup))
+	// the packet id reused without dup flag is a new message
+	assert.NoError(t, obs.OnPublish(pkt))
+	mock.assertPkts(pkt)
+
+	// the packet failed to handle is passed again
+	pkt.ID = 2
+	mock.setErrOnPublish(errors.New("error"))
+	assert.Error(t, obs.OnPublish(pkt))
+	mock.assertPkts(pkt)
+	mock.setErrOnPublish(nil)
+	*dup = *pkt
+	dup.Dup = true
+	assert.NoError(t, obs.OnPublish(dup))
+	mock.assertPkts(dup)
+
+	// the packet of qos 0 is always passed
+	pkt.ID = 0
+	pkt.Message.QOS = 0
+	assert.NoError(t, obs.OnPublish(pkt))
+	assert.NoError(t, obs.OnPublish(pkt))
+	mock.assertPkts(pkt, pkt)
+	select {
+	case p := <-mock.pkts:
+		t.Fatalf("unexpected packet: %v", p)
+	default:
+	}
+}
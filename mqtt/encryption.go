@@ -0,0 +1,192 @@
+package mqtt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"sync"
+
+	"github.com/baetyl/baetyl-go/errors"
+	"github.com/baetyl/baetyl-go/security"
+)
+
+// the magic (and version) of encrypted payload, followed by the length of key id, the key id and the ciphertext
+var envelopeMagic = []byte{0xba, 0xe7, 0x01}
+
+// all errors of encryption
+var (
+	ErrPayloadNotEncrypted = errors.Coded(errors.CodePermissionDenied, "payload is not encrypted")
+	ErrPayloadKeyUnknown   = errors.Coded(errors.CodeFailedPrecondition, "key of payload is unknown")
+)
+
+// EncryptionConfig the config of end-to-end payload encryption, the payloads of topics matched are encrypted
+// by AES-GCM before published and decrypted after received, so that the brokers in the middle can't read them.
+// If strict, the payloads not encrypted on the topics matched are rejected.
+type EncryptionConfig struct {
+	Keys   []TopicKeys `yaml:"keys" json:"keys"`
+	Strict bool        `yaml:"strict" json:"strict"`
+}
+
+// TopicKeys the keys of the topic filter, the first key encrypts and all keys decrypt,
+// so the key is rotated by adding the new key in front and removing the old one after all peers rotated
+type TopicKeys struct {
+	Topic string          `yaml:"topic" json:"topic" validate:"nonzero"`
+	Keys  []EncryptionKey `yaml:"keys" json:"keys"`
+}
+
+// EncryptionKey the key of encryption, the key is 16, 24 or 32 bytes encoded in base64
+type EncryptionKey struct {
+	ID  string `yaml:"id" json:"id" validate:"nonzero"`
+	Key string `yaml:"key" json:"key" validate:"nonzero"`
+}
+
+type topicKeys struct {
+	index int
+	ids   []string
+}
+
+// Encryptor encrypts and decrypts the payloads by the keys of topics
+type Encryptor struct {
+	strict bool
+	trie   *Trie
+	filter map[string]*topicKeys
+	keys   map[string][]byte
+	mu     sync.RWMutex
+}
+
+// NewEncryptor creates a new encryptor
+func NewEncryptor(cfg EncryptionConfig) (*Encryptor, error) {
+	e := &Encryptor{
+		strict: cfg.Strict,
+		trie:   NewTrie(),
+		filter: map[string]*topicKeys{},
+		keys:   map[string][]byte{},
+	}
+	for _, tk := range cfg.Keys {
+		if !CheckTopic(tk.Topic, true) {
+			return nil, fmt.Errorf("topic (%s) is invalid", tk.Topic)
+		}
+		if len(tk.Keys) == 0 {
+			return nil, fmt.Errorf("keys of topic (%s) are missing", tk.Topic)
+		}
+		for i := len(tk.Keys) - 1; i >= 0; i-- {
+			if err := e.Rotate(tk.Topic, tk.Keys[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return e, nil
+}
+
+// Rotate adds the key as the one to encrypt the topic filter, the keys added before are still used to decrypt
+func (e *Encryptor) Rotate(topic string, key EncryptionKey) error {
+	k, err := base64.StdEncoding.DecodeString(key.Key)
+	if err != nil {
+		return fmt.Errorf("key (%s) is invalid: %s", key.ID, err.Error())
+	}
+	switch len(k) {
+	case 16, 24, 32:
+	default:
+		return fmt.Errorf("key (%s) is invalid: %s", key.ID, security.ErrKeySize.Error())
+	}
+	if key.ID == "" || len(key.ID) > 255 {
+		return fmt.Errorf("key id (%s) is invalid", key.ID)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if old, ok := e.keys[key.ID]; ok && !bytes.Equal(old, k) {
+		return fmt.Errorf("key (%s) is duplicated", key.ID)
+	}
+	e.keys[key.ID] = k
+	tk, ok := e.filter[topic]
+	if !ok {
+		tk = &topicKeys{index: len(e.filter)}
+		e.filter[topic] = tk
+		e.trie.Add(topic, tk)
+	}
+	tk.ids = append([]string{key.ID}, tk.ids...)
+	return nil
+}
+
+// match returns the keys of the first topic filter matched, must be called with lock
+func (e *Encryptor) match(topic string) *topicKeys {
+	var res *topicKeys
+	for _, v := range e.trie.Match(topic) {
+		if tk := v.(*topicKeys); res == nil || tk.index < res.index {
+			res = tk
+		}
+	}
+	return res
+}
+
+// Encrypt encrypts the payload by the current key of the topic, the payload is returned as is if no key matched
+func (e *Encryptor) Encrypt(topic string, payload []byte) ([]byte, error) {
+	e.mu.RLock()
+	tk := e.match(topic)
+	if tk == nil {
+		e.mu.RUnlock()
+		return payload, nil
+	}
+	id := tk.ids[0]
+	key := e.keys[id]
+	e.mu.RUnlock()
+
+	header := make([]byte, 0, len(envelopeMagic)+1+len(id))
+	header = append(header, envelopeMagic...)
+	header = append(header, byte(len(id)))
+	header = append(header, id...)
+	// the header is authenticated, so that the key id can't be altered
+	ct, err := security.Encrypt(key, payload, header)
+	if err != nil {
+		return nil, err
+	}
+	return append(header, ct...), nil
+}
+
+// Decrypt decrypts the payload by the key in its envelope, the payload not encrypted is returned as is
+// unless the topic is matched in strict mode
+func (e *Encryptor) Decrypt(topic string, payload []byte) ([]byte, error) {
+	n := len(envelopeMagic)
+	if len(payload) <= n || !bytes.Equal(payload[:n], envelopeMagic) || len(payload) < n+1+int(payload[n]) {
+		if e.strict {
+			e.mu.RLock()
+			tk := e.match(topic)
+			e.mu.RUnlock()
+			if tk != nil {
+				return nil, ErrPayloadNotEncrypted
+			}
+		}
+		return payload, nil
+	}
+	end := n + 1 + int(payload[n])
+	id := string(payload[n+1 : end])
+	e.mu.RLock()
+	key, ok := e.keys[id]
+	e.mu.RUnlock()
+	if !ok {
+		return nil, ErrPayloadKeyUnknown
+	}
+	return security.Decrypt(key, payload[end:], payload[:end])
+}
+
+// Remove removes the key after all peers rotated, the key encrypting a topic filter can't be removed
+func (e *Encryptor) Remove(id string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for topic, tk := range e.filter {
+		if tk.ids[0] == id {
+			return fmt.Errorf("key (%s) is encrypting topic (%s)", id, topic)
+		}
+	}
+	for _, tk := range e.filter {
+		for i, v := range tk.ids {
+			if v == id {
+				tk.ids = append(tk.ids[:i:i], tk.ids[i+1:]...)
+				break
+			}
+		}
+	}
+	delete(e.keys, id)
+	return nil
+}
@@ -0,0 +1,130 @@
+package mqtt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/baetyl/baetyl-go/security"
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestKey(t *testing.T, id string) EncryptionKey {
+	k, err := security.NewKey()
+	assert.NoError(t, err)
+	return EncryptionKey{ID: id, Key: base64.StdEncoding.EncodeToString(k)}
+}
+
+func TestEncryptor(t *testing.T) {
+	k1, k2, k3 := newTestKey(t, "k1"), newTestKey(t, "k2"), newTestKey(t, "k3")
+	e, err := NewEncryptor(EncryptionConfig{
+		Keys: []TopicKeys{
+			{Topic: "secure/#", Keys: []EncryptionKey{k1}},
+			{Topic: "secure/+/high", Keys: []EncryptionKey{k2}},
+			{Topic: "other", Keys: []EncryptionKey{k3, k1}},
+		},
+	})
+	assert.NoError(t, err)
+
+	plain := []byte("the reading of sensor")
+	ct, err := e.Encrypt("secure/d1/high", plain)
+	assert.NoError(t, err)
+	assert.False(t, bytes.Contains(ct, plain))
+	assert.Equal(t, []byte{0xba, 0xe7, 0x01, 2, 'k', '1'}, ct[:6]) // the first filter matched
+	res, err := e.Decrypt("secure/d1/high", ct)
+	assert.NoError(t, err)
+	assert.Equal(t, plain, res)
+
+	// the key of envelope is used, whatever the topic is
+	ct, err = e.Encrypt("other", plain)
+	assert.NoError(t, err)
+	assert.Equal(t, "k3", string(ct[4:6]))
+	res, err = e.Decrypt("any", ct)
+	assert.NoError(t, err)
+	assert.Equal(t, plain, res)
+
+	// the key id is authenticated
+	ct[5] = '1'
+	_, err = e.Decrypt("other", ct)
+	assert.Equal(t, security.ErrDecryptFailed, err)
+	ct[5] = '9'
+	_, err = e.Decrypt("other", ct)
+	assert.Equal(t, ErrPayloadKeyUnknown, err)
+
+	// not matched or not encrypted
+	res, err = e.Encrypt("public", plain)
+	assert.NoError(t, err)
+	assert.Equal(t, plain, res)
+	res, err = e.Decrypt("secure/a", plain)
+	assert.NoError(t, err)
+	assert.Equal(t, plain, res)
+	e.strict = true
+	_, err = e.Decrypt("secure/a", plain)
+	assert.Equal(t, ErrPayloadNotEncrypted, err)
+	res, err = e.Decrypt("public", plain)
+	assert.NoError(t, err)
+	assert.Equal(t, plain, res)
+}
+
+func TestEncryptorRotate(t *testing.T) {
+	k1, k2 := newTestKey(t, "k1"), newTestKey(t, "k2")
+	e, err := NewEncryptor(EncryptionConfig{Keys: []TopicKeys{{Topic: "a", Keys: []EncryptionKey{k1}}}})
+	assert.NoError(t, err)
+	old, err := e.Encrypt("a", []byte("old"))
+	assert.NoError(t, err)
+
+	assert.NoError(t, e.Rotate("a", k2))
+	ct, err := e.Encrypt("a", []byte("new"))
+	assert.NoError(t, err)
+	assert.Equal(t, "k2", string(ct[4:6]))
+	res, err := e.Decrypt("a", old)
+	assert.NoError(t, err)
+	assert.Equal(t, "old", string(res))
+
+	assert.EqualError(t, e.Remove("k2"), "key (k2) is encrypting topic (a)")
+	assert.NoError(t, e.Remove("k1"))
+	_, err = e.Decrypt("a", old)
+	assert.Equal(t, ErrPayloadKeyUnknown, err)
+	res, err = e.Decrypt("a", ct)
+	assert.NoError(t, err)
+	assert.Equal(t, "new", string(res))
+
+	dup := newTestKey(t, "k2")
+	assert.EqualError(t, e.Rotate("b", dup), "key (k2) is duplicated")
+	assert.EqualError(t, e.Rotate("b", EncryptionKey{ID: "k4", Key: "short"}), "key (k4) is invalid: illegal base64 data at input byte 4")
+	assert.EqualError(t, e.Rotate("b", EncryptionKey{ID: "k4", Key: "c2hvcnQ="}), "key (k4) is invalid: key size is invalid")
+	_, err = NewEncryptor(EncryptionConfig{Keys: []TopicKeys{{Topic: "a/#/b", Keys: []EncryptionKey{k1}}}})
+	assert.EqualError(t, err, "topic (a/#/b) is invalid")
+	_, err = NewEncryptor(EncryptionConfig{Keys: []TopicKeys{{Topic: "a"}}})
+	assert.EqualError(t, err, "keys of topic (a) are missing")
+}
+
+func TestClientEncryption(t *testing.T) {
+	var cc ClientConfig
+	assert.NoError(t, utils.SetDefaults(&cc))
+	cc.Encryption.Keys = []TopicKeys{{Topic: "secure/#", Keys: []EncryptionKey{newTestKey(t, "k1")}}}
+	obs := newMockObserver(t)
+	cli, err := NewClient(cc, obs)
+	assert.NoError(t, err)
+	defer cli.Close()
+	assert.NotNil(t, cli.Encryptor())
+
+	// the payload is encrypted before sent, and the packet of caller is untouched
+	pkt := NewPublish()
+	pkt.Message.Topic = "secure/a"
+	pkt.Message.Payload = []byte("hello")
+	assert.NoError(t, cli.Send(pkt))
+	sent := (<-cli.cache).(*Publish)
+	assert.Equal(t, "hello", string(pkt.Message.Payload))
+	assert.NotEqual(t, "hello", string(sent.Message.Payload))
+
+	// the payload is decrypted after received
+	assert.NoError(t, cli.onPublish(sent))
+	res := (<-obs.pkts).(*Publish)
+	assert.Equal(t, "hello", string(res.Message.Payload))
+
+	cc.Encryption.Keys[0].Keys[0].Key = "x"
+	_, err = NewClient(cc, obs)
+	assert.Error(t, err)
+}
@@ -2,7 +2,6 @@ package mqtt
 
 import (
 	"crypto/tls"
-	"errors"
 	"net"
 	"time"
 
@@ -12,6 +11,7 @@ import (
 	"github.com/256dpi/gomqtt/session"
 	"github.com/256dpi/gomqtt/topic"
 	"github.com/256dpi/gomqtt/transport"
+	"github.com/baetyl/baetyl-go/errors"
 )
 
 // The supported MQTT versions.
@@ -33,6 +33,26 @@ const (
 	NotAuthorized
 )
 
+// ConnackError creates the error with code of the connack return code
+func ConnackError(code ConnackCode) error {
+	switch code {
+	case ConnectionAccepted:
+		return nil
+	case InvalidProtocolVersion:
+		return errors.Coded(errors.CodeFailedPrecondition, code.String())
+	case IdentifierRejected:
+		return errors.Coded(errors.CodeInvalidArgument, code.String())
+	case ServerUnavailable:
+		return errors.Coded(errors.CodeUnavailable, code.String())
+	case BadUsernameOrPassword:
+		return errors.Coded(errors.CodeUnauthenticated, code.String())
+	case NotAuthorized:
+		return errors.Coded(errors.CodePermissionDenied, code.String())
+	default:
+		return errors.Coded(errors.CodeUnknown, code.String())
+	}
+}
+
 // QOS the quality of service levels
 type QOS = packet.QOS
 
@@ -72,6 +92,30 @@ func NewPuback() *Puback {
 	return &Puback{}
 }
 
+// Pubrec the pubrec packet
+type Pubrec = packet.Pubrec
+
+// NewPubrec creates a new Pubrec packet
+func NewPubrec() *Pubrec {
+	return &Pubrec{}
+}
+
+// Pubrel the pubrel packet
+type Pubrel = packet.Pubrel
+
+// NewPubrel creates a new Pubrel packet
+func NewPubrel() *Pubrel {
+	return &Pubrel{}
+}
+
+// Pubcomp the pubcomp packet
+type Pubcomp = packet.Pubcomp
+
+// NewPubcomp creates a new Pubcomp packet
+func NewPubcomp() *Pubcomp {
+	return &Pubcomp{}
+}
+
 // Subscribe the subscribe packet
 type Subscribe = packet.Subscribe
 
@@ -158,6 +202,11 @@ func NewCounter() *Counter {
 	return session.NewIDCounter()
 }
 
+// NewCounterWithNext creates a new counter which returns the id as the next
+func NewCounterWithNext(next ID) *Counter {
+	return session.NewIDCounterWithNext(next)
+}
+
 // Trie the trie of topic subscription
 type Trie = topic.Tree
 
@@ -190,6 +239,23 @@ type Connection transport.Conn
 
 // GetTLSCommonName check bidirectional authentication and return commonName
 func GetTLSCommonName(conn Connection) (string, bool) {
+	state, ok := getTLSState(conn)
+	if !ok {
+		return "", false
+	}
+	length := len(state.PeerCertificates)
+	if length == 0 {
+		return "", false
+	}
+	cn := state.PeerCertificates[length-1].Subject.CommonName
+	return cn, true
+}
+
+// getTLSState returns the state of tls connection if the handshake is complete
+func getTLSState(conn Connection) (tls.ConnectionState, bool) {
+	if lc, ok := conn.(*limitedConnection); ok {
+		conn = lc.Connection
+	}
 	var inner net.Conn
 	if nc, ok := conn.(*transport.NetConn); ok {
 		inner = nc.UnderlyingConn()
@@ -198,18 +264,10 @@ func GetTLSCommonName(conn Connection) (string, bool) {
 	}
 	tlsconn, ok := inner.(*tls.Conn)
 	if !ok {
-		return "", false
+		return tls.ConnectionState{}, false
 	}
 	state := tlsconn.ConnectionState()
-	if !state.HandshakeComplete {
-		return "", false
-	}
-	length := len(state.PeerCertificates)
-	if length == 0 {
-		return "", false
-	}
-	cn := state.PeerCertificates[length-1].Subject.CommonName
-	return cn, true
+	return state, state.HandshakeComplete
 }
 
 // all gomqtt client errors
@@ -222,7 +280,8 @@ var (
 	ErrClientMissingPong        = gomqtt.ErrClientMissingPong
 	ErrClientExpectedConnack    = gomqtt.ErrClientExpectedConnack
 	ErrClientSubscriptionFailed = gomqtt.ErrFailedSubscription
-	ErrClientAlreadyClosed      = errors.New("client is closed")
+	ErrClientAlreadyClosed      = errors.Coded(errors.CodeUnavailable, "client is closed")
+	ErrClientMessageDropped     = errors.Coded(errors.CodeResourceExhausted, "message is dropped since buffer of client is full")
 
 	// future's errors
 	ErrFutureTimeout  = future.ErrTimeout
@@ -0,0 +1,61 @@
+package mqtt
+
+import (
+	"context"
+	"sync"
+
+	"github.com/baetyl/baetyl-go/limit"
+)
+
+// limitedConnection the connection whose publications are throttled by the limiter
+type limitedConnection struct {
+	Connection
+	l       *limit.Limiter
+	client  string
+	release func()
+	ctx     context.Context
+	cancel  context.CancelFunc
+	once    sync.Once
+}
+
+// NewLimitedConnection takes a connection of the client from the limiter, and wraps the connection so that
+// the publications received are throttled by the message rate and the byte rate of the client, and rejected
+// with the error of receiving if the payload is too large. The connection is released once closed.
+func NewLimitedConnection(l *limit.Limiter, conn Connection, client string) (Connection, error) {
+	release, err := l.Connect(client)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &limitedConnection{
+		Connection: conn,
+		l:          l,
+		client:     client,
+		release:    release,
+		ctx:        ctx,
+		cancel:     cancel,
+	}, nil
+}
+
+// Receive receives the packet and waits for the quotas if it is a publication
+func (c *limitedConnection) Receive() (Packet, error) {
+	pkt, err := c.Connection.Receive()
+	if err != nil {
+		return nil, err
+	}
+	if p, ok := pkt.(*Publish); ok {
+		if err = c.l.Wait(c.ctx, c.client, len(p.Message.Payload)); err != nil {
+			return nil, err
+		}
+	}
+	return pkt, nil
+}
+
+// Close closes the connection and releases it to the limiter
+func (c *limitedConnection) Close() error {
+	c.once.Do(func() {
+		c.cancel()
+		c.release()
+	})
+	return c.Connection.Close()
+}
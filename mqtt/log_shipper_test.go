@@ -0,0 +1,41 @@
+package mqtt
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/baetyl/baetyl-go/flow"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMqttLogShipper(t *testing.T) {
+	pub := NewPublish()
+	pub.ID = 1
+	pub.Message = Message{Topic: "logs/node1", Payload: []byte("batch"), QOS: 1}
+
+	broker := flow.New().Debug().
+		Receive(connectPacket()).
+		Send(connackPacket()).
+		Receive(pub).
+		Send(&Puback{ID: 1}).
+		Receive(disconnectPacket()).
+		End()
+
+	done, port := initMockBroker(t, broker)
+
+	u, err := url.Parse("mqtt://localhost:" + port + "/logs/node1")
+	assert.NoError(t, err)
+	shipper, err := newLogShipper(u)
+	assert.NoError(t, err)
+	assert.NoError(t, shipper.Ship(context.Background(), []byte("batch")))
+	assert.NoError(t, shipper.Close())
+	safeReceive(done)
+
+	for _, v := range []string{"mqtt://localhost/logs/+", "mqtt://localhost/", "mqtt://localhost/logs?qos=2"} {
+		u, err = url.Parse(v)
+		assert.NoError(t, err)
+		_, err = newLogShipper(u)
+		assert.Error(t, err, v)
+	}
+}
@@ -0,0 +1,775 @@
+package mqtt
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/256dpi/gomqtt/packet"
+	"github.com/baetyl/baetyl-go/errors"
+)
+
+// Version5 the version of mqtt 5.0
+const Version5 byte = 5
+
+// ReasonCode the reason code of mqtt 5.0 packets
+type ReasonCode byte
+
+// All reason codes, the codes less than 0x80 indicate success
+const (
+	ReasonSuccess                             ReasonCode = 0x00
+	ReasonNormalDisconnection                 ReasonCode = 0x00
+	ReasonGrantedQOS0                         ReasonCode = 0x00
+	ReasonGrantedQOS1                         ReasonCode = 0x01
+	ReasonGrantedQOS2                         ReasonCode = 0x02
+	ReasonDisconnectWithWill                  ReasonCode = 0x04
+	ReasonNoMatchingSubscribers               ReasonCode = 0x10
+	ReasonNoSubscriptionExisted               ReasonCode = 0x11
+	ReasonContinueAuthentication              ReasonCode = 0x18
+	ReasonReAuthenticate                      ReasonCode = 0x19
+	ReasonUnspecifiedError                    ReasonCode = 0x80
+	ReasonMalformedPacket                     ReasonCode = 0x81
+	ReasonProtocolError                       ReasonCode = 0x82
+	ReasonImplementationSpecificError         ReasonCode = 0x83
+	ReasonUnsupportedProtocolVersion          ReasonCode = 0x84
+	ReasonClientIdentifierNotValid            ReasonCode = 0x85
+	ReasonBadUsernameOrPassword               ReasonCode = 0x86
+	ReasonNotAuthorized                       ReasonCode = 0x87
+	ReasonServerUnavailable                   ReasonCode = 0x88
+	ReasonServerBusy                          ReasonCode = 0x89
+	ReasonBanned                              ReasonCode = 0x8A
+	ReasonServerShuttingDown                  ReasonCode = 0x8B
+	ReasonBadAuthenticationMethod             ReasonCode = 0x8C
+	ReasonKeepAliveTimeout                    ReasonCode = 0x8D
+	ReasonSessionTakenOver                    ReasonCode = 0x8E
+	ReasonTopicFilterInvalid                  ReasonCode = 0x8F
+	ReasonTopicNameInvalid                    ReasonCode = 0x90
+	ReasonPacketIdentifierInUse               ReasonCode = 0x91
+	ReasonPacketIdentifierNotFound            ReasonCode = 0x92
+	ReasonReceiveMaximumExceeded              ReasonCode = 0x93
+	ReasonTopicAliasInvalid                   ReasonCode = 0x94
+	ReasonPacketTooLarge                      ReasonCode = 0x95
+	ReasonMessageRateTooHigh                  ReasonCode = 0x96
+	ReasonQuotaExceeded                       ReasonCode = 0x97
+	ReasonAdministrativeAction                ReasonCode = 0x98
+	ReasonPayloadFormatInvalid                ReasonCode = 0x99
+	ReasonRetainNotSupported                  ReasonCode = 0x9A
+	ReasonQOSNotSupported                     ReasonCode = 0x9B
+	ReasonUseAnotherServer                    ReasonCode = 0x9C
+	ReasonServerMoved                         ReasonCode = 0x9D
+	ReasonSharedSubscriptionsNotSupported     ReasonCode = 0x9E
+	ReasonConnectionRateExceeded              ReasonCode = 0x9F
+	ReasonMaximumConnectTime                  ReasonCode = 0xA0
+	ReasonSubscriptionIdentifiersNotSupported ReasonCode = 0xA1
+	ReasonWildcardSubscriptionsNotSupported   ReasonCode = 0xA2
+)
+
+var reasonStrings = map[ReasonCode]string{
+	ReasonSuccess:                             "success",
+	ReasonGrantedQOS1:                         "granted qos 1",
+	ReasonGrantedQOS2:                         "granted qos 2",
+	ReasonDisconnectWithWill:                  "disconnect with will message",
+	ReasonNoMatchingSubscribers:               "no matching subscribers",
+	ReasonNoSubscriptionExisted:               "no subscription existed",
+	ReasonContinueAuthentication:              "continue authentication",
+	ReasonReAuthenticate:                      "re-authenticate",
+	ReasonUnspecifiedError:                    "unspecified error",
+	ReasonMalformedPacket:                     "malformed packet",
+	ReasonProtocolError:                       "protocol error",
+	ReasonImplementationSpecificError:         "implementation specific error",
+	ReasonUnsupportedProtocolVersion:          "unsupported protocol version",
+	ReasonClientIdentifierNotValid:            "client identifier not valid",
+	ReasonBadUsernameOrPassword:               "bad user name or password",
+	ReasonNotAuthorized:                       "not authorized",
+	ReasonServerUnavailable:                   "server unavailable",
+	ReasonServerBusy:                          "server busy",
+	ReasonBanned:                              "banned",
+	ReasonServerShuttingDown:                  "server shutting down",
+	ReasonBadAuthenticationMethod:             "bad authentication method",
+	ReasonKeepAliveTimeout:                    "keep alive timeout",
+	ReasonSessionTakenOver:                    "session taken over",
+	ReasonTopicFilterInvalid:                  "topic filter invalid",
+	ReasonTopicNameInvalid:                    "topic name invalid",
+	ReasonPacketIdentifierInUse:               "packet identifier in use",
+	ReasonPacketIdentifierNotFound:            "packet identifier not found",
+	ReasonReceiveMaximumExceeded:              "receive maximum exceeded",
+	ReasonTopicAliasInvalid:                   "topic alias invalid",
+	ReasonPacketTooLarge:                      "packet too large",
+	ReasonMessageRateTooHigh:                  "message rate too high",
+	ReasonQuotaExceeded:                       "quota exceeded",
+	ReasonAdministrativeAction:                "administrative action",
+	ReasonPayloadFormatInvalid:                "payload format invalid",
+	ReasonRetainNotSupported:                  "retain not supported",
+	ReasonQOSNotSupported:                     "qos not supported",
+	ReasonUseAnotherServer:                    "use another server",
+	ReasonServerMoved:                         "server moved",
+	ReasonSharedSubscriptionsNotSupported:     "shared subscriptions not supported",
+	ReasonConnectionRateExceeded:              "connection rate exceeded",
+	ReasonMaximumConnectTime:                  "maximum connect time",
+	ReasonSubscriptionIdentifiersNotSupported: "subscription identifiers not supported",
+	ReasonWildcardSubscriptionsNotSupported:   "wildcard subscriptions not supported",
+}
+
+func (rc ReasonCode) String() string {
+	if s, ok := reasonStrings[rc]; ok {
+		return s
+	}
+	return fmt.Sprintf("unknown reason code (%d)", byte(rc))
+}
+
+// Failed returns whether the reason code indicates failure
+func (rc ReasonCode) Failed() bool {
+	return rc >= 0x80
+}
+
+// ReasonError creates the error with code of the reason code, returns nil if the reason code indicates success
+func ReasonError(rc ReasonCode) error {
+	if !rc.Failed() {
+		return nil
+	}
+	var code errors.Code
+	switch rc {
+	case ReasonMalformedPacket, ReasonProtocolError, ReasonClientIdentifierNotValid, ReasonTopicFilterInvalid,
+		ReasonTopicNameInvalid, ReasonTopicAliasInvalid, ReasonPayloadFormatInvalid, ReasonPacketIdentifierInUse,
+		ReasonPacketIdentifierNotFound:
+		code = errors.CodeInvalidArgument
+	case ReasonUnsupportedProtocolVersion, ReasonRetainNotSupported, ReasonQOSNotSupported,
+		ReasonSharedSubscriptionsNotSupported, ReasonSubscriptionIdentifiersNotSupported,
+		ReasonWildcardSubscriptionsNotSupported:
+		code = errors.CodeFailedPrecondition
+	case ReasonBadUsernameOrPassword, ReasonBadAuthenticationMethod:
+		code = errors.CodeUnauthenticated
+	case ReasonNotAuthorized, ReasonBanned:
+		code = errors.CodePermissionDenied
+	case ReasonServerUnavailable, ReasonServerBusy, ReasonServerShuttingDown, ReasonUseAnotherServer,
+		ReasonServerMoved, ReasonSessionTakenOver, ReasonAdministrativeAction, ReasonKeepAliveTimeout,
+		ReasonMaximumConnectTime:
+		code = errors.CodeUnavailable
+	case ReasonReceiveMaximumExceeded, ReasonPacketTooLarge, ReasonMessageRateTooHigh, ReasonQuotaExceeded,
+		ReasonConnectionRateExceeded:
+		code = errors.CodeResourceExhausted
+	default:
+		code = errors.CodeUnknown
+	}
+	return errors.Coded(code, rc.String())
+}
+
+// connackCode maps the reason code of mqtt 5.0 to the return code of mqtt 3.1.1
+func connackCode(rc ReasonCode) ConnackCode {
+	switch {
+	case !rc.Failed():
+		return ConnectionAccepted
+	case rc == ReasonUnsupportedProtocolVersion:
+		return InvalidProtocolVersion
+	case rc == ReasonClientIdentifierNotValid:
+		return IdentifierRejected
+	case rc == ReasonBadUsernameOrPassword:
+		return BadUsernameOrPassword
+	case rc == ReasonNotAuthorized || rc == ReasonBanned:
+		return NotAuthorized
+	default:
+		return ServerUnavailable
+	}
+}
+
+// reasonCode maps the return code of mqtt 3.1.1 to the reason code of mqtt 5.0
+func reasonCode(cc ConnackCode) ReasonCode {
+	switch cc {
+	case ConnectionAccepted:
+		return ReasonSuccess
+	case InvalidProtocolVersion:
+		return ReasonUnsupportedProtocolVersion
+	case IdentifierRejected:
+		return ReasonClientIdentifierNotValid
+	case BadUsernameOrPassword:
+		return ReasonBadUsernameOrPassword
+	case NotAuthorized:
+		return ReasonNotAuthorized
+	default:
+		return ReasonServerUnavailable
+	}
+}
+
+// The packets of mqtt 5.0 embed the packets of mqtt 3.1.1, and carry the properties and the reason codes in addition.
+// They are encoded as the packets of mqtt 3.1.1 without the additions if sent over connections of mqtt 3.1.1.
+
+// Connect5 the connect packet of mqtt 5.0
+type Connect5 struct {
+	*Connect
+	Properties     Properties
+	WillProperties Properties
+}
+
+// NewConnect5 creates a new Connect5 packet
+func NewConnect5() *Connect5 {
+	c := NewConnect()
+	c.Version = Version5
+	return &Connect5{Connect: c}
+}
+
+func (p *Connect5) String() string {
+	return fmt.Sprintf("<Connect5 ClientID=%q KeepAlive=%d Username=%q CleanSession=%t SessionExpiry=%d UserProperties=%v>",
+		p.ClientID, p.KeepAlive, p.Username, p.CleanSession, p.Properties.SessionExpiryInterval, p.Properties.UserProperties)
+}
+
+// Connack5 the connack packet of mqtt 5.0
+type Connack5 struct {
+	*Connack
+	ReasonCode ReasonCode
+	Properties Properties
+}
+
+// NewConnack5 creates a new Connack5 packet
+func NewConnack5() *Connack5 {
+	return &Connack5{Connack: NewConnack()}
+}
+
+func (p *Connack5) String() string {
+	return fmt.Sprintf("<Connack5 SessionPresent=%t ReasonCode=%s>", p.SessionPresent, p.ReasonCode)
+}
+
+// Publish5 the publish packet of mqtt 5.0
+type Publish5 struct {
+	*Publish
+	Properties Properties
+}
+
+// NewPublish5 creates a new Publish5 packet
+func NewPublish5() *Publish5 {
+	return &Publish5{Publish: NewPublish()}
+}
+
+func (p *Publish5) String() string {
+	return fmt.Sprintf("<Publish5 ID=%d Message=%s Dup=%t UserProperties=%v>",
+		p.ID, p.Message.String(), p.Dup, p.Properties.UserProperties)
+}
+
+// Puback5 the puback packet of mqtt 5.0
+type Puback5 struct {
+	*Puback
+	ReasonCode ReasonCode
+	Properties Properties
+}
+
+// NewPuback5 creates a new Puback5 packet
+func NewPuback5() *Puback5 {
+	return &Puback5{Puback: NewPuback()}
+}
+
+func (p *Puback5) String() string {
+	return fmt.Sprintf("<Puback5 ID=%d ReasonCode=%s>", p.ID, p.ReasonCode)
+}
+
+// Subscribe5 the subscribe packet of mqtt 5.0
+type Subscribe5 struct {
+	*Subscribe
+	Properties Properties
+}
+
+// NewSubscribe5 creates a new Subscribe5 packet
+func NewSubscribe5() *Subscribe5 {
+	return &Subscribe5{Subscribe: NewSubscribe()}
+}
+
+// Suback5 the suback packet of mqtt 5.0, the return codes of the embedded packet are QOSFailure
+// if the reason codes indicate failure
+type Suback5 struct {
+	*Suback
+	ReasonCodes []ReasonCode
+	Properties  Properties
+}
+
+// NewSuback5 creates a new Suback5 packet
+func NewSuback5() *Suback5 {
+	return &Suback5{Suback: NewSuback()}
+}
+
+// Disconnect5 the disconnect packet of mqtt 5.0
+type Disconnect5 struct {
+	*Disconnect
+	ReasonCode ReasonCode
+	Properties Properties
+}
+
+// NewDisconnect5 creates a new Disconnect5 packet
+func NewDisconnect5() *Disconnect5 {
+	return &Disconnect5{Disconnect: NewDisconnect()}
+}
+
+func (p *Disconnect5) String() string {
+	return fmt.Sprintf("<Disconnect5 ReasonCode=%s ReasonString=%s>", p.ReasonCode, p.Properties.ReasonString)
+}
+
+// EncodePacket5 encodes the packet in the format of mqtt 5.0, the packets of mqtt 3.1.1 are encoded without properties
+func EncodePacket5(pkt Packet) ([]byte, error) {
+	out := getBuffer()
+	defer putBuffer(out)
+	if err := encodePacket5(out, pkt); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), out.Bytes()...), nil
+}
+
+// encodePacket5 writes the whole packet into the buffer, the variable header is encoded into a pooled buffer
+// to get the remaining length, while the payload of publish packet is written to the buffer directly
+func encodePacket5(out *bytes.Buffer, pkt Packet) error {
+	var header byte
+	var payload []byte
+	b := getBuffer()
+	defer putBuffer(b)
+	switch p := pkt.(type) {
+	case *Connect5:
+		header = 0x10
+		encodeConnect(b, p)
+	case *Connect:
+		header = 0x10
+		encodeConnect(b, &Connect5{Connect: p})
+	case *Connack5:
+		header = 0x20
+		encodeConnack(b, p)
+	case *Connack:
+		header = 0x20
+		encodeConnack(b, &Connack5{Connack: p, ReasonCode: reasonCode(p.ReturnCode)})
+	case *Publish5:
+		if err := encodePublish(b, &header, p); err != nil {
+			return err
+		}
+		payload = p.Message.Payload
+	case *Publish:
+		if err := encodePublish(b, &header, &Publish5{Publish: p}); err != nil {
+			return err
+		}
+		payload = p.Message.Payload
+	case *Puback5:
+		header = 0x40
+		encodeAck(b, p.ID, p.ReasonCode, &p.Properties)
+	case *Puback:
+		header = 0x40
+		encodeAck(b, p.ID, ReasonSuccess, nil)
+	case *packet.Pubrec:
+		header = 0x50
+		encodeAck(b, p.ID, ReasonSuccess, nil)
+	case *packet.Pubrel:
+		header = 0x62
+		encodeAck(b, p.ID, ReasonSuccess, nil)
+	case *packet.Pubcomp:
+		header = 0x70
+		encodeAck(b, p.ID, ReasonSuccess, nil)
+	case *Subscribe5:
+		header = 0x82
+		encodeSubscribe(b, p)
+	case *Subscribe:
+		header = 0x82
+		encodeSubscribe(b, &Subscribe5{Subscribe: p})
+	case *Suback5:
+		header = 0x90
+		encodeSuback(b, p)
+	case *Suback:
+		header = 0x90
+		encodeSuback(b, &Suback5{Suback: p})
+	case *Unsubscribe:
+		header = 0xA2
+		writeUint16(b, uint16(p.ID))
+		b.WriteByte(0)
+		for _, t := range p.Topics {
+			writeString(b, t)
+		}
+	case *Pingreq:
+		header = 0xC0
+	case *Pingresp:
+		header = 0xD0
+	case *Disconnect5:
+		header = 0xE0
+		if p.ReasonCode != ReasonNormalDisconnection || !isEmpty(&p.Properties) {
+			b.WriteByte(byte(p.ReasonCode))
+			p.Properties.encode(b)
+		}
+	case *Disconnect:
+		header = 0xE0
+	default:
+		return errors.Coded(errors.CodeUnimplemented, "packet (%v) not supported by mqtt 5.0", pkt)
+	}
+	l := b.Len() + len(payload)
+	if l > maxVarint {
+		return errors.Coded(errors.CodeInvalidArgument, "packet size (%d) exceeds the limit (%d)", l, maxVarint)
+	}
+	out.Grow(l + 5)
+	out.WriteByte(header)
+	writeVarint(out, l)
+	out.Write(b.Bytes())
+	out.Write(payload)
+	return nil
+}
+
+func isEmpty(p *Properties) bool {
+	b := getBuffer()
+	defer putBuffer(b)
+	p.encode(b)
+	return b.Len() == 1
+}
+
+func encodeConnect(b *bytes.Buffer, p *Connect5) {
+	writeString(b, "MQTT")
+	b.WriteByte(Version5)
+	var flags byte
+	if p.CleanSession {
+		flags |= 0x02
+	}
+	if p.Will != nil {
+		flags |= 0x04 | byte(p.Will.QOS)<<3
+		if p.Will.Retain {
+			flags |= 0x20
+		}
+	}
+	if p.Password != "" {
+		flags |= 0x40
+	}
+	if p.Username != "" {
+		flags |= 0x80
+	}
+	b.WriteByte(flags)
+	writeUint16(b, p.KeepAlive)
+	p.Properties.encode(b)
+	writeString(b, p.ClientID)
+	if p.Will != nil {
+		p.WillProperties.encode(b)
+		writeString(b, p.Will.Topic)
+		writeBinary(b, p.Will.Payload)
+	}
+	if p.Username != "" {
+		writeString(b, p.Username)
+	}
+	if p.Password != "" {
+		writeString(b, p.Password)
+	}
+}
+
+func encodeConnack(b *bytes.Buffer, p *Connack5) {
+	var flags byte
+	if p.SessionPresent {
+		flags = 0x01
+	}
+	b.WriteByte(flags)
+	b.WriteByte(byte(p.ReasonCode))
+	p.Properties.encode(b)
+}
+
+func encodePublish(b *bytes.Buffer, header *byte, p *Publish5) error {
+	if p.Message.QOS > QOSExactlyOnce {
+		return errors.Coded(errors.CodeInvalidArgument, "qos (%d) is invalid", p.Message.QOS)
+	}
+	if p.Message.Topic == "" && p.Properties.TopicAlias == 0 {
+		return errors.Coded(errors.CodeInvalidArgument, "topic is empty")
+	}
+	*header = 0x30 | byte(p.Message.QOS)<<1
+	if p.Dup {
+		*header |= 0x08
+	}
+	if p.Message.Retain {
+		*header |= 0x01
+	}
+	writeString(b, p.Message.Topic)
+	if p.Message.QOS > 0 {
+		writeUint16(b, uint16(p.ID))
+	}
+	p.Properties.encode(b)
+	return nil
+}
+
+func encodeAck(b *bytes.Buffer, id ID, rc ReasonCode, props *Properties) {
+	writeUint16(b, uint16(id))
+	if props != nil && (rc != ReasonSuccess || !isEmpty(props)) {
+		b.WriteByte(byte(rc))
+		props.encode(b)
+	}
+}
+
+func encodeSubscribe(b *bytes.Buffer, p *Subscribe5) {
+	writeUint16(b, uint16(p.ID))
+	p.Properties.encode(b)
+	for _, s := range p.Subscriptions {
+		writeString(b, s.Topic)
+		b.WriteByte(byte(s.QOS))
+	}
+}
+
+func encodeSuback(b *bytes.Buffer, p *Suback5) {
+	writeUint16(b, uint16(p.ID))
+	p.Properties.encode(b)
+	if p.ReasonCodes != nil {
+		for _, rc := range p.ReasonCodes {
+			b.WriteByte(byte(rc))
+		}
+		return
+	}
+	for _, qos := range p.ReturnCodes {
+		// the QOSFailure is the same as the unspecified error
+		b.WriteByte(byte(qos))
+	}
+}
+
+// DecodePacket5 decodes the packet of mqtt 5.0 from the bytes of the whole packet,
+// the packets with properties or reason codes are decoded as the packets of mqtt 5.0, such as *Publish5
+func DecodePacket5(src []byte) (Packet, error) {
+	return decodePacket5(&reader{data: src})
+}
+
+func decodePacket5(r *reader) (Packet, error) {
+	header, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+	l, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	if l != r.len() {
+		return nil, malformed("remaining length (%d) mismatches (%d)", l, r.len())
+	}
+	t, flags := Type(header>>4), header&0x0f
+	switch t {
+	case packet.PUBREL, packet.SUBSCRIBE, packet.UNSUBSCRIBE:
+		if flags != 0x02 {
+			return nil, malformed("flags (%d) of %s are invalid", flags, t)
+		}
+	case packet.PUBLISH:
+	default:
+		if flags != 0 {
+			return nil, malformed("flags (%d) of %s are invalid", flags, t)
+		}
+	}
+	switch t {
+	case packet.CONNECT:
+		return decodeConnect(r)
+	case packet.CONNACK:
+		p := NewConnack5()
+		if flags, err = r.byte(); err != nil {
+			return nil, err
+		}
+		p.SessionPresent = flags&0x01 == 0x01
+		rc, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		p.ReasonCode = ReasonCode(rc)
+		if p.ReasonCode == ReasonCode(InvalidProtocolVersion) {
+			// the server of mqtt 3.1.1 rejects the version
+			p.ReasonCode = ReasonUnsupportedProtocolVersion
+		}
+		p.ReturnCode = connackCode(p.ReasonCode)
+		if r.len() > 0 {
+			err = p.Properties.decode(r)
+		}
+		return p, err
+	case packet.PUBLISH:
+		return decodePublish(r, flags)
+	case packet.PUBACK, packet.PUBREC, packet.PUBREL, packet.PUBCOMP:
+		id, err := r.uint16()
+		if err != nil {
+			return nil, err
+		}
+		p := NewPuback5()
+		p.ID = ID(id)
+		if r.len() > 0 {
+			var rc byte
+			if rc, err = r.byte(); err != nil {
+				return nil, err
+			}
+			p.ReasonCode = ReasonCode(rc)
+		}
+		if r.len() > 0 {
+			if err = p.Properties.decode(r); err != nil {
+				return nil, err
+			}
+		}
+		switch t {
+		case packet.PUBREC:
+			return &packet.Pubrec{ID: p.ID}, nil
+		case packet.PUBREL:
+			return &packet.Pubrel{ID: p.ID}, nil
+		case packet.PUBCOMP:
+			return &packet.Pubcomp{ID: p.ID}, nil
+		}
+		return p, nil
+	case packet.SUBSCRIBE:
+		id, err := r.uint16()
+		if err != nil {
+			return nil, err
+		}
+		p := NewSubscribe5()
+		p.ID = ID(id)
+		if err = p.Properties.decode(r); err != nil {
+			return nil, err
+		}
+		for r.len() > 0 {
+			var s Subscription
+			if s.Topic, err = r.string(); err != nil {
+				return nil, err
+			}
+			opts, err := r.byte()
+			if err != nil {
+				return nil, err
+			}
+			s.QOS = QOS(opts & 0x03)
+			p.Subscriptions = append(p.Subscriptions, s)
+		}
+		if len(p.Subscriptions) == 0 {
+			return nil, malformed("no subscription")
+		}
+		return p, nil
+	case packet.SUBACK:
+		id, err := r.uint16()
+		if err != nil {
+			return nil, err
+		}
+		p := NewSuback5()
+		p.ID = ID(id)
+		if err = p.Properties.decode(r); err != nil {
+			return nil, err
+		}
+		for _, rc := range r.rest() {
+			p.ReasonCodes = append(p.ReasonCodes, ReasonCode(rc))
+			if ReasonCode(rc).Failed() {
+				p.ReturnCodes = append(p.ReturnCodes, QOSFailure)
+			} else {
+				p.ReturnCodes = append(p.ReturnCodes, QOS(rc))
+			}
+		}
+		return p, nil
+	case packet.UNSUBSCRIBE:
+		id, err := r.uint16()
+		if err != nil {
+			return nil, err
+		}
+		p := NewUnsubscribe()
+		p.ID = ID(id)
+		if err = new(Properties).decode(r); err != nil {
+			return nil, err
+		}
+		for r.len() > 0 {
+			topic, err := r.string()
+			if err != nil {
+				return nil, err
+			}
+			p.Topics = append(p.Topics, topic)
+		}
+		return p, nil
+	case packet.UNSUBACK:
+		id, err := r.uint16()
+		if err != nil {
+			return nil, err
+		}
+		p := NewUnsuback()
+		p.ID = ID(id)
+		if r.len() > 0 {
+			err = new(Properties).decode(r)
+		}
+		return p, err
+	case packet.PINGREQ, packet.PINGRESP:
+		if r.len() != 0 {
+			return nil, malformed("remaining length of %s is not zero", t)
+		}
+		if t == packet.PINGREQ {
+			return NewPingreq(), nil
+		}
+		return NewPingresp(), nil
+	case packet.DISCONNECT:
+		p := NewDisconnect5()
+		if r.len() > 0 {
+			rc, _ := r.byte()
+			p.ReasonCode = ReasonCode(rc)
+		}
+		if r.len() > 0 {
+			err = p.Properties.decode(r)
+		}
+		return p, err
+	default:
+		return nil, errors.Coded(errors.CodeUnimplemented, "packet type (%d) not supported", t)
+	}
+}
+
+func decodeConnect(r *reader) (Packet, error) {
+	name, err := r.string()
+	if err != nil {
+		return nil, err
+	}
+	version, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+	if name != "MQTT" || version != Version5 {
+		return nil, errors.Coded(errors.CodeFailedPrecondition, "protocol (%s %d) not supported", name, version)
+	}
+	flags, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+	if flags&0x01 != 0 {
+		return nil, malformed("reserved flag of connect is set")
+	}
+	p := NewConnect5()
+	p.CleanSession = flags&0x02 != 0
+	if p.KeepAlive, err = r.uint16(); err != nil {
+		return nil, err
+	}
+	if err = p.Properties.decode(r); err != nil {
+		return nil, err
+	}
+	if p.ClientID, err = r.string(); err != nil {
+		return nil, err
+	}
+	if flags&0x04 != 0 {
+		p.Will = &Message{QOS: QOS(flags >> 3 & 0x03), Retain: flags&0x20 != 0}
+		if err = p.WillProperties.decode(r); err != nil {
+			return nil, err
+		}
+		if p.Will.Topic, err = r.string(); err != nil {
+			return nil, err
+		}
+		if p.Will.Payload, err = r.binary(); err != nil {
+			return nil, err
+		}
+	}
+	if flags&0x80 != 0 {
+		if p.Username, err = r.string(); err != nil {
+			return nil, err
+		}
+	}
+	if flags&0x40 != 0 {
+		var password []byte
+		if password, err = r.binary(); err != nil {
+			return nil, err
+		}
+		p.Password = string(password)
+	}
+	return p, nil
+}
+
+func decodePublish(r *reader, flags byte) (Packet, error) {
+	p := NewPublish5()
+	p.Dup = flags&0x08 != 0
+	p.Message.Retain = flags&0x01 != 0
+	p.Message.QOS = QOS(flags >> 1 & 0x03)
+	if p.Message.QOS > QOSExactlyOnce {
+		return nil, malformed("qos (%d) is invalid", p.Message.QOS)
+	}
+	var err error
+	if p.Message.Topic, err = r.string(); err != nil {
+		return nil, err
+	}
+	if p.Message.QOS > 0 {
+		id, err := r.uint16()
+		if err != nil {
+			return nil, err
+		}
+		p.ID = ID(id)
+	}
+	if err = p.Properties.decode(r); err != nil {
+		return nil, err
+	}
+	if r.owned {
+		p.Message.Payload = r.rest()
+	} else {
+		p.Message.Payload = append([]byte{}, r.rest()...)
+	}
+	return p, nil
+}
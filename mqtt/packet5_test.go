@@ -0,0 +1,186 @@
+package mqtt
+
+import (
+	"testing"
+
+	"github.com/256dpi/gomqtt/packet"
+	"github.com/baetyl/baetyl-go/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPacket5(t *testing.T) {
+	one := byte(1)
+	props := Properties{
+		PayloadFormat:                   1,
+		MessageExpiryInterval:           60,
+		ContentType:                     "application/json",
+		ResponseTopic:                   "reply",
+		CorrelationData:                 []byte{1, 2},
+		SubscriptionIdentifiers:         []int{1, 268435455},
+		SessionExpiryInterval:           3600,
+		AssignedClientID:                "cid",
+		ServerKeepAlive:                 30,
+		AuthenticationMethod:            "token",
+		AuthenticationData:              []byte("secret"),
+		RequestProblemInformation:       &one,
+		WillDelayInterval:               10,
+		RequestResponseInformation:      1,
+		ResponseInformation:             "info",
+		ServerReference:                 "other",
+		ReasonString:                    "reason",
+		ReceiveMaximum:                  100,
+		TopicAliasMaximum:               10,
+		TopicAlias:                      0,
+		MaximumQOS:                      &one,
+		RetainAvailable:                 &one,
+		UserProperties:                  []UserProperty{{"trace-id", "abc"}, {"trace-id", "def"}},
+		MaximumPacketSize:               1024,
+		WildcardSubscriptionAvailable:   &one,
+		SubscriptionIdentifierAvailable: &one,
+		SharedSubscriptionAvailable:     &one,
+	}
+	v, ok := props.UserProperty("trace-id")
+	assert.True(t, ok)
+	assert.Equal(t, "abc", v)
+	_, ok = props.UserProperty("none")
+	assert.False(t, ok)
+
+	connect := NewConnect5()
+	connect.ClientID = "c1"
+	connect.KeepAlive = 30
+	connect.Username = "u"
+	connect.Password = "p"
+	connect.Will = &Message{Topic: "will", Payload: []byte("bye"), QOS: 1, Retain: true}
+	connect.Properties = props
+	connect.WillProperties.WillDelayInterval = 5
+
+	connack := NewConnack5()
+	connack.SessionPresent = true
+	connack.ReasonCode = ReasonNotAuthorized
+	connack.ReturnCode = NotAuthorized
+	connack.Properties.ReasonString = "denied"
+
+	publish := NewPublish5()
+	publish.ID = 3
+	publish.Dup = true
+	publish.Message = Message{Topic: "a/b", Payload: []byte("hi"), QOS: 2, Retain: true}
+	publish.Properties.AddUserProperty("trace-id", "abc")
+
+	puback := NewPuback5()
+	puback.ID = 3
+	puback.ReasonCode = ReasonQuotaExceeded
+	puback.Properties.ReasonString = "quota"
+
+	subscribe := NewSubscribe5()
+	subscribe.ID = 4
+	subscribe.Subscriptions = []Subscription{{Topic: "a/#", QOS: 1}, {Topic: "b", QOS: 0}}
+	subscribe.Properties.SubscriptionIdentifiers = []int{7}
+
+	suback := NewSuback5()
+	suback.ID = 4
+	suback.ReasonCodes = []ReasonCode{ReasonGrantedQOS1, ReasonNotAuthorized}
+	suback.ReturnCodes = []QOS{1, QOSFailure}
+
+	disconnect := NewDisconnect5()
+	disconnect.ReasonCode = ReasonServerMoved
+	disconnect.Properties.ServerReference = "other:1883"
+
+	for _, pkt := range []Packet{connect, connack, publish, puback, subscribe, suback, disconnect} {
+		data, err := EncodePacket5(pkt)
+		assert.NoError(t, err, "%v", pkt)
+		res, err := DecodePacket5(data)
+		assert.NoError(t, err, "%v", pkt)
+		assert.Equal(t, pkt, res)
+	}
+
+	// the packets of mqtt 3.1.1 are encoded without properties
+	unsubscribe := NewUnsubscribe()
+	unsubscribe.ID = 5
+	unsubscribe.Topics = []string{"a/#"}
+	for _, pkt := range []Packet{unsubscribe, NewUnsuback(), NewPingreq(), NewPingresp(), &packet.Pubrec{ID: 1}, &packet.Pubrel{ID: 1}, &packet.Pubcomp{ID: 1}} {
+		data, err := EncodePacket5(pkt)
+		if pkt.Type() == packet.UNSUBACK {
+			assert.Error(t, err)
+			continue
+		}
+		assert.NoError(t, err, "%v", pkt)
+		res, err := DecodePacket5(data)
+		assert.NoError(t, err, "%v", pkt)
+		assert.Equal(t, pkt, res)
+	}
+	for _, c := range []struct {
+		pkt  Packet
+		data []byte
+		res  Packet
+	}{
+		{NewDisconnect(), []byte{0xe0, 0x00}, NewDisconnect5()},
+		{NewPuback(), []byte{0x40, 0x02, 0x00, 0x00}, NewPuback5()},
+		{&Connack{ReturnCode: BadUsernameOrPassword}, []byte{0x20, 0x03, 0x00, 0x86, 0x00}, &Connack5{Connack: &Connack{ReturnCode: BadUsernameOrPassword}, ReasonCode: ReasonBadUsernameOrPassword}},
+		{&Publish{Message: Message{Topic: "t", Payload: []byte("x")}}, []byte{0x30, 0x05, 0x00, 0x01, 't', 0x00, 'x'}, &Publish5{Publish: &Publish{Message: Message{Topic: "t", Payload: []byte("x")}}}},
+		{&Suback{ID: 1, ReturnCodes: []QOS{0, QOSFailure}}, []byte{0x90, 0x05, 0x00, 0x01, 0x00, 0x00, 0x80}, &Suback5{Suback: &Suback{ID: 1, ReturnCodes: []QOS{0, QOSFailure}}, ReasonCodes: []ReasonCode{ReasonGrantedQOS0, ReasonUnspecifiedError}}},
+	} {
+		data, err := EncodePacket5(c.pkt)
+		assert.NoError(t, err)
+		assert.Equal(t, c.data, data)
+		res, err := DecodePacket5(data)
+		assert.NoError(t, err)
+		assert.Equal(t, c.res, res)
+	}
+
+	// the connack of mqtt 3.1.1 which rejects the version
+	res, err := DecodePacket5([]byte{0x20, 0x02, 0x00, 0x01})
+	assert.NoError(t, err)
+	assert.Equal(t, ReasonUnsupportedProtocolVersion, res.(*Connack5).ReasonCode)
+	assert.Equal(t, InvalidProtocolVersion, res.(*Connack5).ReturnCode)
+
+	// the packets of mqtt 5.0 are encoded as the packets of mqtt 3.1.1 over connections of mqtt 3.1.1
+	data := make([]byte, publish.Len())
+	_, err = publish.Encode(data)
+	assert.NoError(t, err)
+	p := NewPublish()
+	_, err = p.Decode(data)
+	assert.NoError(t, err)
+	assert.Equal(t, publish.Publish, p)
+
+	// malformed
+	for _, data := range [][]byte{
+		{},
+		{0x30},
+		{0x30, 0x05, 0x00},
+		{0x36, 0x03, 0x00, 0x01, 't'},
+		{0x80, 0x00},
+		{0xf0, 0x00},
+		{0xc0, 0x01, 0x00},
+		{0x30, 0xff, 0xff, 0xff, 0xff},
+		{0x30, 0x05, 0x00, 0x01, 't', 0x02, 0x7f},
+		{0x30, 0x06, 0x00, 0x01, 't', 0x01, 0x2f, 0x00},
+		{0x30, 0x06, 0x00, 0x02, 0xff, 0xfe, 0x00, 0x00},
+		{0x82, 0x03, 0x00, 0x01, 0x00},
+	} {
+		_, err := DecodePacket5(data)
+		assert.Error(t, err, "%v", data)
+	}
+	_, err = EncodePacket5(&Publish{})
+	assert.EqualError(t, err, "topic is empty")
+	_, err = EncodePacket5(&Publish{Message: Message{Topic: "t", QOS: 3}})
+	assert.EqualError(t, err, "qos (3) is invalid")
+}
+
+func TestReasonCode(t *testing.T) {
+	assert.Equal(t, "success", ReasonSuccess.String())
+	assert.Equal(t, "unknown reason code (255)", ReasonCode(255).String())
+	assert.NoError(t, ReasonError(ReasonNoMatchingSubscribers))
+	for rc, code := range map[ReasonCode]errors.Code{
+		ReasonUnspecifiedError:           errors.CodeUnknown,
+		ReasonTopicAliasInvalid:          errors.CodeInvalidArgument,
+		ReasonUnsupportedProtocolVersion: errors.CodeFailedPrecondition,
+		ReasonBadUsernameOrPassword:      errors.CodeUnauthenticated,
+		ReasonNotAuthorized:              errors.CodePermissionDenied,
+		ReasonServerShuttingDown:         errors.CodeUnavailable,
+		ReasonQuotaExceeded:              errors.CodeResourceExhausted,
+	} {
+		err := ReasonError(rc)
+		assert.EqualError(t, err, rc.String())
+		assert.Equal(t, code, errors.CodeOf(err))
+	}
+}
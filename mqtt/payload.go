@@ -0,0 +1,21 @@
+package mqtt
+
+import "github.com/baetyl/baetyl-go/codec"
+
+// NewPublishPayload creates a new publish packet whose payload is the value encoded by the codec
+func NewPublishPayload(topic string, qos QOS, c codec.Codec, v interface{}) (*Publish, error) {
+	data, err := c.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	pkt := NewPublish()
+	pkt.Message.Topic = topic
+	pkt.Message.QOS = qos
+	pkt.Message.Payload = data
+	return pkt, nil
+}
+
+// DecodePayload decodes the payload of the publish packet into the value by the codec
+func DecodePayload(pkt *Publish, c codec.Codec, v interface{}) error {
+	return c.Unmarshal(pkt.Message.Payload, v)
+}
@@ -0,0 +1,23 @@
+package mqtt
+
+import (
+	"testing"
+
+	"github.com/baetyl/baetyl-go/codec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPayloadCodec(t *testing.T) {
+	in := map[string]string{"a": "b"}
+	pkt, err := NewPublishPayload("t", 1, codec.MsgPack, in)
+	assert.NoError(t, err)
+	assert.Equal(t, "t", pkt.Message.Topic)
+	assert.Equal(t, QOS(1), pkt.Message.QOS)
+
+	var out map[string]string
+	assert.NoError(t, DecodePayload(pkt, codec.MsgPack, &out))
+	assert.Equal(t, in, out)
+
+	_, err = NewPublishPayload("t", 0, codec.Protobuf, in)
+	assert.Equal(t, codec.ErrNotProtoMessage, err)
+}
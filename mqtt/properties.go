@@ -0,0 +1,401 @@
+package mqtt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/baetyl/baetyl-go/errors"
+)
+
+// the identifiers of mqtt 5.0 properties
+const (
+	propPayloadFormat                   = 0x01
+	propMessageExpiryInterval           = 0x02
+	propContentType                     = 0x03
+	propResponseTopic                   = 0x08
+	propCorrelationData                 = 0x09
+	propSubscriptionIdentifier          = 0x0B
+	propSessionExpiryInterval           = 0x11
+	propAssignedClientID                = 0x12
+	propServerKeepAlive                 = 0x13
+	propAuthenticationMethod            = 0x15
+	propAuthenticationData              = 0x16
+	propRequestProblemInformation       = 0x17
+	propWillDelayInterval               = 0x18
+	propRequestResponseInformation      = 0x19
+	propResponseInformation             = 0x1A
+	propServerReference                 = 0x1C
+	propReasonString                    = 0x1F
+	propReceiveMaximum                  = 0x21
+	propTopicAliasMaximum               = 0x22
+	propTopicAlias                      = 0x23
+	propMaximumQOS                      = 0x24
+	propRetainAvailable                 = 0x25
+	propUserProperty                    = 0x26
+	propMaximumPacketSize               = 0x27
+	propWildcardSubscriptionAvailable   = 0x28
+	propSubscriptionIdentifierAvailable = 0x29
+	propSharedSubscriptionAvailable     = 0x2A
+)
+
+// the max value of variable byte integer
+const maxVarint = 268435455
+
+// UserProperty the user property of mqtt 5.0, a key may appear more than once
+type UserProperty struct {
+	Key   string `yaml:"key" json:"key"`
+	Value string `yaml:"value" json:"value"`
+}
+
+// Properties the properties of mqtt 5.0 packets, the zero value means the property is absent,
+// the properties whose default value is not zero are pointers
+type Properties struct {
+	PayloadFormat                   byte
+	MessageExpiryInterval           uint32
+	ContentType                     string
+	ResponseTopic                   string
+	CorrelationData                 []byte
+	SubscriptionIdentifiers         []int
+	SessionExpiryInterval           uint32
+	AssignedClientID                string
+	ServerKeepAlive                 uint16
+	AuthenticationMethod            string
+	AuthenticationData              []byte
+	RequestProblemInformation       *byte
+	WillDelayInterval               uint32
+	RequestResponseInformation      byte
+	ResponseInformation             string
+	ServerReference                 string
+	ReasonString                    string
+	ReceiveMaximum                  uint16
+	TopicAliasMaximum               uint16
+	TopicAlias                      uint16
+	MaximumQOS                      *byte
+	RetainAvailable                 *byte
+	UserProperties                  []UserProperty
+	MaximumPacketSize               uint32
+	WildcardSubscriptionAvailable   *byte
+	SubscriptionIdentifierAvailable *byte
+	SharedSubscriptionAvailable     *byte
+}
+
+// UserProperty returns the value of the first user property with the key
+func (p *Properties) UserProperty(key string) (string, bool) {
+	for _, up := range p.UserProperties {
+		if up.Key == key {
+			return up.Value, true
+		}
+	}
+	return "", false
+}
+
+// AddUserProperty appends a user property
+func (p *Properties) AddUserProperty(key, value string) {
+	p.UserProperties = append(p.UserProperties, UserProperty{Key: key, Value: value})
+}
+
+// encode writes the length and the properties into the buffer
+func (p *Properties) encode(buf *bytes.Buffer) {
+	b := getBuffer()
+	defer putBuffer(b)
+	putByte := func(id, v byte) {
+		if v != 0 {
+			b.WriteByte(id)
+			b.WriteByte(v)
+		}
+	}
+	putOptional := func(id byte, v *byte) {
+		if v != nil {
+			b.WriteByte(id)
+			b.WriteByte(*v)
+		}
+	}
+	putUint16 := func(id byte, v uint16) {
+		if v != 0 {
+			b.WriteByte(id)
+			writeUint16(b, v)
+		}
+	}
+	putUint32 := func(id byte, v uint32) {
+		if v != 0 {
+			b.WriteByte(id)
+			writeUint32(b, v)
+		}
+	}
+	putString := func(id byte, v string) {
+		if v != "" {
+			b.WriteByte(id)
+			writeString(b, v)
+		}
+	}
+	putBinary := func(id byte, v []byte) {
+		if v != nil {
+			b.WriteByte(id)
+			writeBinary(b, v)
+		}
+	}
+
+	putByte(propPayloadFormat, p.PayloadFormat)
+	putUint32(propMessageExpiryInterval, p.MessageExpiryInterval)
+	putString(propContentType, p.ContentType)
+	putString(propResponseTopic, p.ResponseTopic)
+	putBinary(propCorrelationData, p.CorrelationData)
+	for _, id := range p.SubscriptionIdentifiers {
+		b.WriteByte(propSubscriptionIdentifier)
+		writeVarint(b, id)
+	}
+	putUint32(propSessionExpiryInterval, p.SessionExpiryInterval)
+	putString(propAssignedClientID, p.AssignedClientID)
+	putUint16(propServerKeepAlive, p.ServerKeepAlive)
+	putString(propAuthenticationMethod, p.AuthenticationMethod)
+	putBinary(propAuthenticationData, p.AuthenticationData)
+	putOptional(propRequestProblemInformation, p.RequestProblemInformation)
+	putUint32(propWillDelayInterval, p.WillDelayInterval)
+	putByte(propRequestResponseInformation, p.RequestResponseInformation)
+	putString(propResponseInformation, p.ResponseInformation)
+	putString(propServerReference, p.ServerReference)
+	putString(propReasonString, p.ReasonString)
+	putUint16(propReceiveMaximum, p.ReceiveMaximum)
+	putUint16(propTopicAliasMaximum, p.TopicAliasMaximum)
+	putUint16(propTopicAlias, p.TopicAlias)
+	putOptional(propMaximumQOS, p.MaximumQOS)
+	putOptional(propRetainAvailable, p.RetainAvailable)
+	for _, up := range p.UserProperties {
+		b.WriteByte(propUserProperty)
+		writeString(b, up.Key)
+		writeString(b, up.Value)
+	}
+	putUint32(propMaximumPacketSize, p.MaximumPacketSize)
+	putOptional(propWildcardSubscriptionAvailable, p.WildcardSubscriptionAvailable)
+	putOptional(propSubscriptionIdentifierAvailable, p.SubscriptionIdentifierAvailable)
+	putOptional(propSharedSubscriptionAvailable, p.SharedSubscriptionAvailable)
+
+	writeVarint(buf, b.Len())
+	buf.Write(b.Bytes())
+}
+
+// decode reads the length and the properties from the reader
+func (p *Properties) decode(r *reader) error {
+	l, err := r.varint()
+	if err != nil {
+		return err
+	}
+	data, err := r.next(l)
+	if err != nil {
+		return err
+	}
+	pr := &reader{data: data}
+	for pr.len() > 0 {
+		id, _ := pr.byte()
+		switch id {
+		case propPayloadFormat:
+			p.PayloadFormat, err = pr.byte()
+		case propMessageExpiryInterval:
+			p.MessageExpiryInterval, err = pr.uint32()
+		case propContentType:
+			p.ContentType, err = pr.string()
+		case propResponseTopic:
+			p.ResponseTopic, err = pr.string()
+		case propCorrelationData:
+			p.CorrelationData, err = pr.binary()
+		case propSubscriptionIdentifier:
+			var v int
+			v, err = pr.varint()
+			p.SubscriptionIdentifiers = append(p.SubscriptionIdentifiers, v)
+		case propSessionExpiryInterval:
+			p.SessionExpiryInterval, err = pr.uint32()
+		case propAssignedClientID:
+			p.AssignedClientID, err = pr.string()
+		case propServerKeepAlive:
+			p.ServerKeepAlive, err = pr.uint16()
+		case propAuthenticationMethod:
+			p.AuthenticationMethod, err = pr.string()
+		case propAuthenticationData:
+			p.AuthenticationData, err = pr.binary()
+		case propRequestProblemInformation:
+			p.RequestProblemInformation, err = pr.optional()
+		case propWillDelayInterval:
+			p.WillDelayInterval, err = pr.uint32()
+		case propRequestResponseInformation:
+			p.RequestResponseInformation, err = pr.byte()
+		case propResponseInformation:
+			p.ResponseInformation, err = pr.string()
+		case propServerReference:
+			p.ServerReference, err = pr.string()
+		case propReasonString:
+			p.ReasonString, err = pr.string()
+		case propReceiveMaximum:
+			p.ReceiveMaximum, err = pr.uint16()
+		case propTopicAliasMaximum:
+			p.TopicAliasMaximum, err = pr.uint16()
+		case propTopicAlias:
+			p.TopicAlias, err = pr.uint16()
+		case propMaximumQOS:
+			p.MaximumQOS, err = pr.optional()
+		case propRetainAvailable:
+			p.RetainAvailable, err = pr.optional()
+		case propUserProperty:
+			var up UserProperty
+			if up.Key, err = pr.string(); err == nil {
+				up.Value, err = pr.string()
+			}
+			p.UserProperties = append(p.UserProperties, up)
+		case propMaximumPacketSize:
+			p.MaximumPacketSize, err = pr.uint32()
+		case propWildcardSubscriptionAvailable:
+			p.WildcardSubscriptionAvailable, err = pr.optional()
+		case propSubscriptionIdentifierAvailable:
+			p.SubscriptionIdentifierAvailable, err = pr.optional()
+		case propSharedSubscriptionAvailable:
+			p.SharedSubscriptionAvailable, err = pr.optional()
+		default:
+			return malformed("property (%d) not supported", id)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func malformed(format string, args ...interface{}) error {
+	return errors.Coded(errors.CodeInvalidArgument, "malformed packet: "+fmt.Sprintf(format, args...))
+}
+
+func writeUint16(b *bytes.Buffer, v uint16) {
+	b.WriteByte(byte(v >> 8))
+	b.WriteByte(byte(v))
+}
+
+func writeUint32(b *bytes.Buffer, v uint32) {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+	b.Write(buf[:])
+}
+
+func writeString(b *bytes.Buffer, v string) {
+	writeUint16(b, uint16(len(v)))
+	b.WriteString(v)
+}
+
+func writeBinary(b *bytes.Buffer, v []byte) {
+	writeUint16(b, uint16(len(v)))
+	b.Write(v)
+}
+
+func writeVarint(b *bytes.Buffer, v int) {
+	for {
+		d := byte(v % 128)
+		v /= 128
+		if v > 0 {
+			d |= 0x80
+		}
+		b.WriteByte(d)
+		if v == 0 {
+			return
+		}
+	}
+}
+
+// reader reads the fields of packet
+type reader struct {
+	data  []byte
+	pos   int
+	owned bool // the data is owned by the packet decoded, so that the payload refers to the data without copying
+}
+
+func (r *reader) len() int {
+	return len(r.data) - r.pos
+}
+
+func (r *reader) next(n int) ([]byte, error) {
+	if n < 0 || r.len() < n {
+		return nil, malformed("insufficient bytes")
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+func (r *reader) rest() []byte {
+	b := r.data[r.pos:]
+	r.pos = len(r.data)
+	return b
+}
+
+func (r *reader) byte() (byte, error) {
+	b, err := r.next(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+func (r *reader) optional() (*byte, error) {
+	b, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (r *reader) uint16() (uint16, error) {
+	b, err := r.next(2)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint16(b), nil
+}
+
+func (r *reader) uint32() (uint32, error) {
+	b, err := r.next(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(b), nil
+}
+
+func (r *reader) binary() ([]byte, error) {
+	l, err := r.uint16()
+	if err != nil {
+		return nil, err
+	}
+	b, err := r.next(int(l))
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{}, b...), nil
+}
+
+func (r *reader) string() (string, error) {
+	l, err := r.uint16()
+	if err != nil {
+		return "", err
+	}
+	b, err := r.next(int(l))
+	if err != nil {
+		return "", err
+	}
+	if !utf8.Valid(b) {
+		return "", malformed("string is not utf-8")
+	}
+	return string(b), nil
+}
+
+func (r *reader) varint() (int, error) {
+	var v, m int
+	for i := 0; i < 4; i++ {
+		b, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+		v += int(b&0x7f) << m
+		if b&0x80 == 0 {
+			return v, nil
+		}
+		m += 7
+	}
+	return 0, malformed("variable byte integer exceeds 4 bytes")
+}
@@ -0,0 +1,79 @@
+package mqtt
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Handler handles the publish packet of the topic matched
+type Handler func(*Publish) error
+
+type route struct {
+	index   uint64
+	handler Handler
+}
+
+// Router dispatches the publish packets to the handlers of the topic filters matched, the wildcards (+ and #) are supported
+type Router struct {
+	routes *TopicTree
+	index  uint64
+	mu     sync.Mutex
+}
+
+// NewRouter creates a new router
+func NewRouter() *Router {
+	return &Router{routes: NewTopicTree()}
+}
+
+// Handle registers the handler of the topic filter, replaces the one registered for the same filter
+func (r *Router) Handle(filter string, handler Handler) error {
+	if !CheckTopic(filter, true) {
+		return fmt.Errorf("topic filter (%s) is invalid", filter)
+	}
+	if handler == nil {
+		return fmt.Errorf("handler of topic filter (%s) is nil", filter)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, v := range r.routes.Get(filter) {
+		r.routes.Remove(filter, v)
+	}
+	r.index++
+	return r.routes.Add(filter, &route{index: r.index, handler: handler})
+}
+
+// Remove removes the handler of the topic filter
+func (r *Router) Remove(filter string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, v := range r.routes.Get(filter) {
+		r.routes.Remove(filter, v)
+	}
+}
+
+// Len returns the number of topic filters registered
+func (r *Router) Len() int {
+	return r.routes.Len()
+}
+
+// Route calls the handlers of all topic filters matched in the order of registration, returns whether any matched
+// and the first error of handlers
+func (r *Router) Route(pkt *Publish) (bool, error) {
+	vs := r.routes.Match(pkt.Message.Topic)
+	if len(vs) == 0 {
+		return false, nil
+	}
+	rs := make([]*route, 0, len(vs))
+	for _, v := range vs {
+		rs = append(rs, v.(*route))
+	}
+	sort.Slice(rs, func(i, j int) bool { return rs[i].index < rs[j].index })
+	var res error
+	for _, rt := range rs {
+		if err := rt.handler(pkt); err != nil && res == nil {
+			res = err
+		}
+	}
+	return true, res
+}
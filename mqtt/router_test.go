@@ -0,0 +1,91 @@
+package mqtt
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/baetyl/baetyl-go/flow"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouter(t *testing.T) {
+	r := NewRouter()
+	var calls []string
+	handler := func(name string, err error) Handler {
+		return func(pkt *Publish) error {
+			calls = append(calls, name+":"+pkt.Message.Topic)
+			return err
+		}
+	}
+	assert.EqualError(t, r.Handle("a/#/b", handler("x", nil)), "topic filter (a/#/b) is invalid")
+	assert.EqualError(t, r.Handle("a", nil), "handler of topic filter (a) is nil")
+	assert.NoError(t, r.Handle("device/+/telemetry", handler("plus", nil)))
+	assert.NoError(t, r.Handle("device/#", handler("hash", errors.New("hash failed"))))
+	assert.NoError(t, r.Handle("device/1/telemetry", handler("exact", errors.New("exact failed"))))
+	assert.Equal(t, 3, r.Len())
+
+	pkt := NewPublish()
+	pkt.Message.Topic = "device/1/telemetry"
+	ok, err := r.Route(pkt)
+	assert.True(t, ok)
+	assert.EqualError(t, err, "hash failed")
+	assert.Equal(t, []string{"plus:device/1/telemetry", "hash:device/1/telemetry", "exact:device/1/telemetry"}, calls)
+
+	// replaced
+	calls = nil
+	assert.NoError(t, r.Handle("device/#", handler("hash2", nil)))
+	assert.Equal(t, 3, r.Len())
+	pkt.Message.Topic = "device/2"
+	ok, err = r.Route(pkt)
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"hash2:device/2"}, calls)
+
+	calls = nil
+	r.Remove("device/#")
+	r.Remove("none")
+	assert.Equal(t, 2, r.Len())
+	ok, err = r.Route(pkt)
+	assert.False(t, ok)
+	assert.NoError(t, err)
+	assert.Empty(t, calls)
+}
+
+func TestMqttClientHandle(t *testing.T) {
+	telemetry := NewPublish()
+	telemetry.Message = Message{Topic: "device/1/telemetry", Payload: []byte("1")}
+	other := NewPublish()
+	other.Message = Message{Topic: "device/1/event", Payload: []byte("2")}
+
+	registered := make(chan struct{})
+	broker := flow.New().Debug().
+		Receive(connectPacket()).
+		Send(connackPacket()).
+		Run(func() { <-registered }).
+		Send(telemetry, other).
+		Receive(disconnectPacket()).
+		End()
+
+	done, port := initMockBroker(t, broker)
+
+	cc := newConfig(port)
+	obs := newMockObserver(t)
+	cli, err := NewClient(cc, obs)
+	assert.NoError(t, err)
+	handled := make(chan *Publish, 1)
+	assert.NoError(t, cli.Handle("device/+/telemetry", func(pkt *Publish) error {
+		handled <- pkt
+		return nil
+	}))
+	assert.EqualError(t, cli.Handle("", nil), "topic filter () is invalid")
+	close(registered)
+
+	// the packets not matched are passed to the observer
+	obs.assertPkts(other)
+	assert.Equal(t, telemetry, <-handled)
+
+	cli.Unhandle("device/+/telemetry")
+	assert.Equal(t, 0, cli.router.Len())
+	assert.NoError(t, cli.Close())
+	safeReceive(done)
+}
@@ -0,0 +1,205 @@
+package mqtt
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Session the persistent state of the client session, which is kept if the session is not clean
+type Session struct {
+	ClientID      string         `json:"clientid"`
+	Subscriptions []Subscription `json:"subscriptions,omitempty"`
+	Will          *Message       `json:"will,omitempty"`
+}
+
+// StoredMessage the message queued for the client, such as the qos 1 messages not acknowledged
+// or published while the client is offline, the sequence is increased in the queue of each client
+type StoredMessage struct {
+	Seq     uint64  `json:"seq"`
+	Message Message `json:"message"`
+}
+
+// Store the persistence backend of the broker, which keeps the sessions, the retained messages
+// and the queued messages, so that the broker survives restarts
+type Store interface {
+	// GetSession returns the session of the client, returns nil if not found
+	GetSession(clientID string) (*Session, error)
+	// PutSession creates or replaces the session
+	PutSession(s *Session) error
+	// DeleteSession deletes the session and the messages queued for the client
+	DeleteSession(clientID string) error
+	// ListSessions returns all sessions ordered by client id
+	ListSessions() ([]*Session, error)
+
+	// PutRetained creates or replaces the retained message of the topic, deletes it if the payload is empty
+	PutRetained(m *Message) error
+	// ListRetained returns all retained messages ordered by topic
+	ListRetained() ([]*Message, error)
+
+	// Enqueue appends the message to the queue of the client and returns its sequence
+	Enqueue(clientID string, m *Message) (uint64, error)
+	// Queued returns the messages queued for the client ordered by sequence
+	Queued(clientID string) ([]*StoredMessage, error)
+	// Ack removes the message of the sequence from the queue of the client
+	Ack(clientID string, seq uint64) error
+
+	// Close closes the store
+	Close() error
+}
+
+// NewStore creates the store of the driver
+func NewStore(cfg StoreConfig) (Store, error) {
+	switch cfg.Driver {
+	case "", "boltdb":
+		return NewBoltStore(cfg)
+	case "badger":
+		return NewBadgerStore(cfg)
+	case "memory":
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("driver (%s) of store not supported", cfg.Driver)
+	}
+}
+
+// MemoryStore the store in memory, which does not survive restarts, for the devices without writable storage
+type MemoryStore struct {
+	sessions map[string]*Session
+	retained map[string]*Message
+	queues   map[string][]*StoredMessage
+	seqs     map[string]uint64
+	mu       sync.Mutex
+}
+
+// NewMemoryStore creates a new store in memory
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		sessions: map[string]*Session{},
+		retained: map[string]*Message{},
+		queues:   map[string][]*StoredMessage{},
+		seqs:     map[string]uint64{},
+	}
+}
+
+// GetSession returns the session of the client, returns nil if not found
+func (s *MemoryStore) GetSession(clientID string) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.sessions[clientID]
+	if !ok {
+		return nil, nil
+	}
+	return copySession(v), nil
+}
+
+// PutSession creates or replaces the session
+func (s *MemoryStore) PutSession(v *Session) error {
+	s.mu.Lock()
+	s.sessions[v.ClientID] = copySession(v)
+	s.mu.Unlock()
+	return nil
+}
+
+// DeleteSession deletes the session and the messages queued for the client
+func (s *MemoryStore) DeleteSession(clientID string) error {
+	s.mu.Lock()
+	delete(s.sessions, clientID)
+	delete(s.queues, clientID)
+	delete(s.seqs, clientID)
+	s.mu.Unlock()
+	return nil
+}
+
+// ListSessions returns all sessions ordered by client id
+func (s *MemoryStore) ListSessions() ([]*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make([]*Session, 0, len(s.sessions))
+	for _, v := range s.sessions {
+		res = append(res, copySession(v))
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ClientID < res[j].ClientID })
+	return res, nil
+}
+
+// PutRetained creates or replaces the retained message of the topic, deletes it if the payload is empty
+func (s *MemoryStore) PutRetained(m *Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(m.Payload) == 0 {
+		delete(s.retained, m.Topic)
+		return nil
+	}
+	s.retained[m.Topic] = copyMessage(m)
+	return nil
+}
+
+// ListRetained returns all retained messages ordered by topic
+func (s *MemoryStore) ListRetained() ([]*Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make([]*Message, 0, len(s.retained))
+	for _, m := range s.retained {
+		res = append(res, copyMessage(m))
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Topic < res[j].Topic })
+	return res, nil
+}
+
+// Enqueue appends the message to the queue of the client and returns its sequence
+func (s *MemoryStore) Enqueue(clientID string, m *Message) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seqs[clientID]++
+	seq := s.seqs[clientID]
+	s.queues[clientID] = append(s.queues[clientID], &StoredMessage{Seq: seq, Message: *copyMessage(m)})
+	return seq, nil
+}
+
+// Queued returns the messages queued for the client ordered by sequence
+func (s *MemoryStore) Queued(clientID string) ([]*StoredMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := s.queues[clientID]
+	res := make([]*StoredMessage, 0, len(q))
+	for _, sm := range q {
+		res = append(res, &StoredMessage{Seq: sm.Seq, Message: *copyMessage(&sm.Message)})
+	}
+	return res, nil
+}
+
+// Ack removes the message of the sequence from the queue of the client
+func (s *MemoryStore) Ack(clientID string, seq uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := s.queues[clientID]
+	i := sort.Search(len(q), func(i int) bool { return q[i].Seq >= seq })
+	if i < len(q) && q[i].Seq == seq {
+		s.queues[clientID] = append(q[:i], q[i+1:]...)
+	}
+	return nil
+}
+
+// Close closes the store
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+func copySession(s *Session) *Session {
+	c := &Session{ClientID: s.ClientID}
+	if s.Subscriptions != nil {
+		c.Subscriptions = append([]Subscription{}, s.Subscriptions...)
+	}
+	if s.Will != nil {
+		c.Will = copyMessage(s.Will)
+	}
+	return c
+}
+
+func copyMessage(m *Message) *Message {
+	c := *m
+	if m.Payload != nil {
+		c.Payload = append([]byte{}, m.Payload...)
+	}
+	return &c
+}
@@ -0,0 +1,280 @@
+package mqtt
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/baetyl/baetyl-go/log"
+	"github.com/dgraph-io/badger"
+)
+
+// the key prefixes of badger store, the client id and the sequence of queued message are separated by zero
+// which is not allowed in client ids
+var (
+	prefixSession  = []byte("s/")
+	prefixRetained = []byte("r/")
+	prefixQueue    = []byte("q/")
+)
+
+// BadgerStore the store backed by badger, for the devices with fast storage and high message rates
+type BadgerStore struct {
+	db   *badger.DB
+	seqs map[string]uint64 // the last sequences of queues loaded
+	mu   sync.Mutex
+}
+
+// NewBadgerStore opens (or creates) the badger database in the directory of store
+func NewBadgerStore(cfg StoreConfig) (*BadgerStore, error) {
+	err := os.MkdirAll(cfg.Path, 0755)
+	if err != nil {
+		return nil, err
+	}
+	opts := badger.DefaultOptions(cfg.Path).
+		WithValueLogFileSize(64 << 20).
+		WithTruncate(true).
+		WithLogger(&badgerLogger{log.With(log.Any("mqtt", "badger")).Sugar()})
+	db, err := badger.Open(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &BadgerStore{db: db, seqs: map[string]uint64{}}, nil
+}
+
+// GetSession returns the session of the client, returns nil if not found
+func (s *BadgerStore) GetSession(clientID string) (*Session, error) {
+	var res *Session
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(badgerKey(prefixSession, clientID))
+		if err == badger.ErrKeyNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		res = &Session{}
+		return item.Value(func(v []byte) error {
+			return json.Unmarshal(v, res)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// PutSession creates or replaces the session
+func (s *BadgerStore) PutSession(v *Session) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(badgerKey(prefixSession, v.ClientID), data)
+	})
+}
+
+// DeleteSession deletes the session and the messages queued for the client
+func (s *BadgerStore) DeleteSession(clientID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.seqs, clientID)
+	return s.db.Update(func(txn *badger.Txn) error {
+		err := txn.Delete(badgerKey(prefixSession, clientID))
+		if err != nil {
+			return err
+		}
+		prefix := queueKey(clientID, nil)
+		it := txn.NewIterator(badger.IteratorOptions{Prefix: prefix})
+		var keys [][]byte
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			keys = append(keys, it.Item().KeyCopy(nil))
+		}
+		it.Close()
+		for _, k := range keys {
+			if err := txn.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ListSessions returns all sessions ordered by client id
+func (s *BadgerStore) ListSessions() ([]*Session, error) {
+	var res []*Session
+	err := s.iterate(prefixSession, func(_, v []byte) error {
+		sess := &Session{}
+		if err := json.Unmarshal(v, sess); err != nil {
+			return err
+		}
+		res = append(res, sess)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// PutRetained creates or replaces the retained message of the topic, deletes it if the payload is empty
+func (s *BadgerStore) PutRetained(m *Message) error {
+	k := badgerKey(prefixRetained, m.Topic)
+	if len(m.Payload) == 0 {
+		return s.db.Update(func(txn *badger.Txn) error {
+			return txn.Delete(k)
+		})
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(k, data)
+	})
+}
+
+// ListRetained returns all retained messages ordered by topic
+func (s *BadgerStore) ListRetained() ([]*Message, error) {
+	var res []*Message
+	err := s.iterate(prefixRetained, func(_, v []byte) error {
+		m := &Message{}
+		if err := json.Unmarshal(v, m); err != nil {
+			return err
+		}
+		res = append(res, m)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Enqueue appends the message to the queue of the client and returns its sequence
+func (s *BadgerStore) Enqueue(clientID string, m *Message) (uint64, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seq, ok := s.seqs[clientID]
+	if !ok {
+		seq, err = s.lastSeq(clientID)
+		if err != nil {
+			return 0, err
+		}
+	}
+	seq++
+	err = s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(queueKey(clientID, encodeSeq(seq)), data)
+	})
+	if err != nil {
+		return 0, err
+	}
+	s.seqs[clientID] = seq
+	return seq, nil
+}
+
+// Queued returns the messages queued for the client ordered by sequence
+func (s *BadgerStore) Queued(clientID string) ([]*StoredMessage, error) {
+	var res []*StoredMessage
+	prefix := queueKey(clientID, nil)
+	err := s.iterate(prefix, func(k, v []byte) error {
+		sm := &StoredMessage{Seq: binary.BigEndian.Uint64(k[len(prefix):])}
+		if err := json.Unmarshal(v, &sm.Message); err != nil {
+			return err
+		}
+		res = append(res, sm)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Ack removes the message of the sequence from the queue of the client
+func (s *BadgerStore) Ack(clientID string, seq uint64) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(queueKey(clientID, encodeSeq(seq)))
+	})
+}
+
+// Close closes the badger database
+func (s *BadgerStore) Close() error {
+	return s.db.Close()
+}
+
+// lastSeq returns the sequence of the last message queued, the sequence is not reused after restarts
+// unless all messages are acknowledged
+func (s *BadgerStore) lastSeq(clientID string) (uint64, error) {
+	var seq uint64
+	prefix := queueKey(clientID, nil)
+	err := s.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.IteratorOptions{Prefix: prefix, Reverse: true})
+		defer it.Close()
+		it.Seek(append(append([]byte{}, prefix...), 0xff))
+		if it.ValidForPrefix(prefix) {
+			seq = binary.BigEndian.Uint64(it.Item().Key()[len(prefix):])
+		}
+		return nil
+	})
+	return seq, err
+}
+
+func (s *BadgerStore) iterate(prefix []byte, f func(k, v []byte) error) error {
+	return s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			k := item.Key()
+			err := item.Value(func(v []byte) error {
+				return f(k, v)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func badgerKey(prefix []byte, name string) []byte {
+	return append(append([]byte{}, prefix...), name...)
+}
+
+func queueKey(clientID string, seq []byte) []byte {
+	k := append(badgerKey(prefixQueue, clientID), 0)
+	return append(k, seq...)
+}
+
+// badgerLogger the logger of badger, whose infos are logged as debug since they are too verbose
+type badgerLogger struct {
+	l interface {
+		Errorf(string, ...interface{})
+		Warnf(string, ...interface{})
+		Debugf(string, ...interface{})
+	}
+}
+
+func (b *badgerLogger) Errorf(format string, args ...interface{}) {
+	b.l.Errorf(format, args...)
+}
+
+func (b *badgerLogger) Warningf(format string, args ...interface{}) {
+	b.l.Warnf(format, args...)
+}
+
+func (b *badgerLogger) Infof(format string, args ...interface{}) {
+	b.l.Debugf(format, args...)
+}
+
+func (b *badgerLogger) Debugf(format string, args ...interface{}) {
+	b.l.Debugf(format, args...)
+}
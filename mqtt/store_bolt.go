@@ -0,0 +1,214 @@
+package mqtt
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	bucketSessions = []byte("sessions")
+	bucketRetained = []byte("retained")
+	bucketQueues   = []byte("queues") // the bucket of each client is nested
+)
+
+// BoltStore the store backed by an embedded boltdb file, for the devices with small memory
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore opens (or creates) the boltdb file in the directory of store
+func NewBoltStore(cfg StoreConfig) (*BoltStore, error) {
+	err := os.MkdirAll(cfg.Path, 0755)
+	if err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(filepath.Join(cfg.Path, "broker.db"), 0600, &bolt.Options{Timeout: cfg.Timeout})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{bucketSessions, bucketRetained, bucketQueues} {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+// GetSession returns the session of the client, returns nil if not found
+func (s *BoltStore) GetSession(clientID string) (*Session, error) {
+	var res *Session
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(bucketSessions).Get([]byte(clientID))
+		if v == nil {
+			return nil
+		}
+		res = &Session{}
+		return json.Unmarshal(v, res)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// PutSession creates or replaces the session
+func (s *BoltStore) PutSession(v *Session) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketSessions).Put([]byte(v.ClientID), data)
+	})
+}
+
+// DeleteSession deletes the session and the messages queued for the client
+func (s *BoltStore) DeleteSession(clientID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		k := []byte(clientID)
+		err := tx.Bucket(bucketSessions).Delete(k)
+		if err != nil {
+			return err
+		}
+		err = tx.Bucket(bucketQueues).DeleteBucket(k)
+		if err == bolt.ErrBucketNotFound {
+			return nil
+		}
+		return err
+	})
+}
+
+// ListSessions returns all sessions ordered by client id
+func (s *BoltStore) ListSessions() ([]*Session, error) {
+	var res []*Session
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketSessions).ForEach(func(_, v []byte) error {
+			sess := &Session{}
+			if err := json.Unmarshal(v, sess); err != nil {
+				return err
+			}
+			res = append(res, sess)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// PutRetained creates or replaces the retained message of the topic, deletes it if the payload is empty
+func (s *BoltStore) PutRetained(m *Message) error {
+	if len(m.Payload) == 0 {
+		return s.db.Update(func(tx *bolt.Tx) error {
+			return tx.Bucket(bucketRetained).Delete([]byte(m.Topic))
+		})
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketRetained).Put([]byte(m.Topic), data)
+	})
+}
+
+// ListRetained returns all retained messages ordered by topic
+func (s *BoltStore) ListRetained() ([]*Message, error) {
+	var res []*Message
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketRetained).ForEach(func(_, v []byte) error {
+			m := &Message{}
+			if err := json.Unmarshal(v, m); err != nil {
+				return err
+			}
+			res = append(res, m)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Enqueue appends the message to the queue of the client and returns its sequence
+func (s *BoltStore) Enqueue(clientID string, m *Message) (uint64, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return 0, err
+	}
+	var seq uint64
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.Bucket(bucketQueues).CreateBucketIfNotExists([]byte(clientID))
+		if err != nil {
+			return err
+		}
+		seq, err = b.NextSequence()
+		if err != nil {
+			return err
+		}
+		return b.Put(encodeSeq(seq), data)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return seq, nil
+}
+
+// Queued returns the messages queued for the client ordered by sequence
+func (s *BoltStore) Queued(clientID string) ([]*StoredMessage, error) {
+	var res []*StoredMessage
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketQueues).Bucket([]byte(clientID))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			sm := &StoredMessage{Seq: binary.BigEndian.Uint64(k)}
+			if err := json.Unmarshal(v, &sm.Message); err != nil {
+				return err
+			}
+			res = append(res, sm)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Ack removes the message of the sequence from the queue of the client
+func (s *BoltStore) Ack(clientID string, seq uint64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketQueues).Bucket([]byte(clientID))
+		if b == nil {
+			return nil
+		}
+		return b.Delete(encodeSeq(seq))
+	})
+}
+
+// Close closes the boltdb file
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// encodeSeq encodes the sequence in big endian, so that the keys are ordered by sequence
+func encodeSeq(seq uint64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, seq)
+	return k
+}
@@ -0,0 +1,94 @@
+package mqtt
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore(t *testing.T) {
+	for _, driver := range []string{"boltdb", "badger", "memory"} {
+		t.Run(driver, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "store")
+			assert.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			var cfg StoreConfig
+			assert.NoError(t, utils.SetDefaults(&cfg))
+			cfg.Driver = driver
+			cfg.Path = dir
+			s, err := NewStore(cfg)
+			assert.NoError(t, err)
+
+			sess, err := s.GetSession("c1")
+			assert.NoError(t, err)
+			assert.Nil(t, sess)
+			c1 := &Session{ClientID: "c1", Subscriptions: []Subscription{{Topic: "a/+", QOS: 1}}, Will: &Message{Topic: "w", Payload: []byte("bye")}}
+			assert.NoError(t, s.PutSession(c1))
+			assert.NoError(t, s.PutSession(&Session{ClientID: "c0"}))
+			sess, err = s.GetSession("c1")
+			assert.NoError(t, err)
+			assert.Equal(t, c1, sess)
+
+			assert.NoError(t, s.PutRetained(&Message{Topic: "b", Payload: []byte("2"), Retain: true}))
+			assert.NoError(t, s.PutRetained(&Message{Topic: "a", Payload: []byte("1"), QOS: 1, Retain: true}))
+			assert.NoError(t, s.PutRetained(&Message{Topic: "c", Payload: []byte("3")}))
+			assert.NoError(t, s.PutRetained(&Message{Topic: "c"}))
+
+			for i, p := range []string{"x", "y", "z"} {
+				seq, err := s.Enqueue("c1", &Message{Topic: "a/b", Payload: []byte(p), QOS: 1})
+				assert.NoError(t, err)
+				assert.Equal(t, uint64(i+1), seq)
+			}
+			_, err = s.Enqueue("c1/sub", &Message{Topic: "a/b"})
+			assert.NoError(t, err)
+			assert.NoError(t, s.Ack("c1", 2))
+			assert.NoError(t, s.Ack("c1", 9))
+			assert.NoError(t, s.Ack("none", 1))
+
+			if driver != "memory" {
+				// reopen, the states are kept
+				assert.NoError(t, s.Close())
+				s, err = NewStore(cfg)
+				assert.NoError(t, err)
+			}
+			defer s.Close()
+
+			sessions, err := s.ListSessions()
+			assert.NoError(t, err)
+			assert.Equal(t, []*Session{{ClientID: "c0"}, c1}, sessions)
+			retained, err := s.ListRetained()
+			assert.NoError(t, err)
+			assert.Equal(t, []*Message{
+				{Topic: "a", Payload: []byte("1"), QOS: 1, Retain: true},
+				{Topic: "b", Payload: []byte("2"), Retain: true},
+			}, retained)
+			queued, err := s.Queued("c1")
+			assert.NoError(t, err)
+			assert.Equal(t, []*StoredMessage{
+				{Seq: 1, Message: Message{Topic: "a/b", Payload: []byte("x"), QOS: 1}},
+				{Seq: 3, Message: Message{Topic: "a/b", Payload: []byte("z"), QOS: 1}},
+			}, queued)
+			seq, err := s.Enqueue("c1", &Message{Topic: "a/b"})
+			assert.NoError(t, err)
+			assert.Equal(t, uint64(4), seq)
+
+			assert.NoError(t, s.DeleteSession("c1"))
+			sess, err = s.GetSession("c1")
+			assert.NoError(t, err)
+			assert.Nil(t, sess)
+			queued, err = s.Queued("c1")
+			assert.NoError(t, err)
+			assert.Empty(t, queued)
+			queued, err = s.Queued("c1/sub")
+			assert.NoError(t, err)
+			assert.Len(t, queued, 1)
+		})
+	}
+
+	_, err := NewStore(StoreConfig{Driver: "unknown"})
+	assert.EqualError(t, err, "driver (unknown) of store not supported")
+}
@@ -0,0 +1,238 @@
+package mqtt
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/baetyl/baetyl-go/errors"
+)
+
+// the prefix of shared subscriptions, such as $share/group/a/+
+const sharedPrefix = "$share/"
+
+// ParseSharedFilter parses the shared subscription of the form $share/{group}/{filter},
+// returns the group and the topic filter, and whether it is shared. The filter is returned as is if not shared.
+func ParseSharedFilter(filter string) (string, string, bool) {
+	if !strings.HasPrefix(filter, sharedPrefix) {
+		return "", filter, false
+	}
+	parts := strings.SplitN(filter[len(sharedPrefix):], "/", 2)
+	if len(parts) != 2 {
+		return parts[0], "", true
+	}
+	return parts[0], parts[1], true
+}
+
+// TopicTree the tree of topic filters and their values, such as the handlers of a router or the subscriptions
+// of a broker. The wildcards (+ and #) and the shared subscriptions ($share/{group}/{filter}) are supported,
+// the topics starting with $ are not matched by the filters starting with wildcards. It is safe for concurrent use.
+// The values must be comparable, since a value is added once for a filter and removed by equality.
+type TopicTree struct {
+	root  *topicNode
+	count int
+	mu    sync.RWMutex
+}
+
+type topicNode struct {
+	children map[string]*topicNode
+	values   []interface{}
+	groups   map[string]*topicGroup // the values of shared subscriptions by group
+}
+
+type topicGroup struct {
+	values []interface{}
+	next   uint64 // the next member to deliver in round robin
+}
+
+// NewTopicTree creates a new topic tree
+func NewTopicTree() *TopicTree {
+	return &TopicTree{root: newTopicNode()}
+}
+
+func newTopicNode() *topicNode {
+	return &topicNode{children: map[string]*topicNode{}}
+}
+
+// Add adds the value to the topic filter, which may be a shared subscription,
+// returns an error if the topic filter is invalid
+func (t *TopicTree) Add(filter string, value interface{}) error {
+	group, f, shared := ParseSharedFilter(filter)
+	if (shared && !checkShareGroup(group)) || !CheckTopic(f, true) {
+		return errors.Coded(errors.CodeInvalidArgument, "topic filter (%s) is invalid", filter)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n := t.root
+	for _, level := range strings.Split(f, "/") {
+		child, ok := n.children[level]
+		if !ok {
+			child = newTopicNode()
+			n.children[level] = child
+		}
+		n = child
+	}
+	var added bool
+	if shared {
+		if n.groups == nil {
+			n.groups = map[string]*topicGroup{}
+		}
+		g, ok := n.groups[group]
+		if !ok {
+			g = &topicGroup{}
+			n.groups[group] = g
+		}
+		g.values, added = addValue(g.values, value)
+	} else {
+		n.values, added = addValue(n.values, value)
+	}
+	if added {
+		t.count++
+	}
+	return nil
+}
+
+// Remove removes the value from the topic filter, returns whether the value is found
+func (t *TopicTree) Remove(filter string, value interface{}) bool {
+	group, f, shared := ParseSharedFilter(filter)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	levels := strings.Split(f, "/")
+	path := make([]*topicNode, 0, len(levels)+1)
+	n := t.root
+	path = append(path, n)
+	for _, level := range levels {
+		child, ok := n.children[level]
+		if !ok {
+			return false
+		}
+		n = child
+		path = append(path, n)
+	}
+	var removed bool
+	if shared {
+		g, ok := n.groups[group]
+		if !ok {
+			return false
+		}
+		g.values, removed = removeValue(g.values, value)
+		if len(g.values) == 0 {
+			delete(n.groups, group)
+		}
+	} else {
+		n.values, removed = removeValue(n.values, value)
+	}
+	if !removed {
+		return false
+	}
+	t.count--
+	// prunes the nodes without values and children
+	for i := len(levels); i > 0; i-- {
+		n := path[i]
+		if len(n.values) > 0 || len(n.groups) > 0 || len(n.children) > 0 {
+			break
+		}
+		delete(path[i-1].children, levels[i-1])
+	}
+	return true
+}
+
+// Get returns the values of the topic filter exactly, not matched by wildcards
+func (t *TopicTree) Get(filter string) []interface{} {
+	group, f, shared := ParseSharedFilter(filter)
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	n := t.root
+	for _, level := range strings.Split(f, "/") {
+		child, ok := n.children[level]
+		if !ok {
+			return nil
+		}
+		n = child
+	}
+	var vs []interface{}
+	if !shared {
+		vs = n.values
+	} else if g, ok := n.groups[group]; ok {
+		vs = g.values
+	}
+	return append([]interface{}(nil), vs...)
+}
+
+// Match returns the distinct values of all topic filters matched by the topic, only one member of each shared group
+// is returned in round robin, returns nil if the topic is invalid or contains wildcards
+func (t *TopicTree) Match(topic string) []interface{} {
+	if !CheckTopic(topic, false) {
+		return nil
+	}
+	levels := strings.Split(topic, "/")
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	var res []interface{}
+	seen := map[interface{}]struct{}{}
+	t.root.match(levels, 0, strings.HasPrefix(topic, "$"), func(n *topicNode) {
+		for _, v := range n.values {
+			if _, ok := seen[v]; !ok {
+				seen[v] = struct{}{}
+				res = append(res, v)
+			}
+		}
+		for _, g := range n.groups {
+			v := g.values[int((atomic.AddUint64(&g.next, 1)-1)%uint64(len(g.values)))]
+			if _, ok := seen[v]; !ok {
+				seen[v] = struct{}{}
+				res = append(res, v)
+			}
+		}
+	})
+	return res
+}
+
+// Len returns the number of values of all topic filters
+func (t *TopicTree) Len() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.count
+}
+
+// match visits the nodes matched by the levels from the index, the wildcards are skipped at the first level
+// of the system topics starting with $
+func (n *topicNode) match(levels []string, i int, sys bool, visit func(*topicNode)) {
+	wildcard := i > 0 || !sys
+	// the multi-level wildcard also matches the parent level, such as a/# matches a
+	if child, ok := n.children["#"]; ok && wildcard {
+		visit(child)
+	}
+	if i == len(levels) {
+		visit(n)
+		return
+	}
+	if child, ok := n.children[levels[i]]; ok {
+		child.match(levels, i+1, sys, visit)
+	}
+	if child, ok := n.children["+"]; ok && wildcard {
+		child.match(levels, i+1, sys, visit)
+	}
+}
+
+func checkShareGroup(group string) bool {
+	return group != "" && !strings.ContainsAny(group, "+#")
+}
+
+func addValue(vs []interface{}, value interface{}) ([]interface{}, bool) {
+	for _, v := range vs {
+		if v == value {
+			return vs, false
+		}
+	}
+	return append(vs, value), true
+}
+
+func removeValue(vs []interface{}, value interface{}) ([]interface{}, bool) {
+	for i, v := range vs {
+		if v == value {
+			return append(vs[:i], vs[i+1:]...), true
+		}
+	}
+	return vs, false
+}
@@ -0,0 +1,98 @@
+package mqtt
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func matchStrings(t *TopicTree, topic string) []string {
+	var res []string
+	for _, v := range t.Match(topic) {
+		res = append(res, v.(string))
+	}
+	sort.Strings(res)
+	return res
+}
+
+func TestParseSharedFilter(t *testing.T) {
+	g, f, ok := ParseSharedFilter("$share/g1/a/+")
+	assert.True(t, ok)
+	assert.Equal(t, "g1", g)
+	assert.Equal(t, "a/+", f)
+	g, f, ok = ParseSharedFilter("$share/g1")
+	assert.True(t, ok)
+	assert.Equal(t, "g1", g)
+	assert.Equal(t, "", f)
+	g, f, ok = ParseSharedFilter("a/+")
+	assert.False(t, ok)
+	assert.Equal(t, "", g)
+	assert.Equal(t, "a/+", f)
+}
+
+func TestTopicTree(t *testing.T) {
+	tt := NewTopicTree()
+	assert.EqualError(t, tt.Add("a/#/b", "x"), "topic filter (a/#/b) is invalid")
+	assert.EqualError(t, tt.Add("$share/g1", "x"), "topic filter ($share/g1) is invalid")
+	assert.EqualError(t, tt.Add("$share//a", "x"), "topic filter ($share//a) is invalid")
+	assert.EqualError(t, tt.Add("$share/g+/a", "x"), "topic filter ($share/g+/a) is invalid")
+
+	assert.NoError(t, tt.Add("a/b", "exact"))
+	assert.NoError(t, tt.Add("a/+", "plus"))
+	assert.NoError(t, tt.Add("a/#", "hash"))
+	assert.NoError(t, tt.Add("#", "all"))
+	assert.NoError(t, tt.Add("+/+", "plus2"))
+	assert.NoError(t, tt.Add("$SYS/#", "sys"))
+	// added once for the same filter
+	assert.NoError(t, tt.Add("a/b", "exact"))
+	assert.Equal(t, 6, tt.Len())
+	assert.Equal(t, []interface{}{"exact"}, tt.Get("a/b"))
+	assert.Nil(t, tt.Get("a/c"))
+
+	assert.Equal(t, []string{"all", "exact", "hash", "plus", "plus2"}, matchStrings(tt, "a/b"))
+	assert.Equal(t, []string{"all", "hash"}, matchStrings(tt, "a"))
+	assert.Equal(t, []string{"all", "hash"}, matchStrings(tt, "a/b/c"))
+	assert.Equal(t, []string{"all", "plus2"}, matchStrings(tt, "b/c"))
+	// the system topics are not matched by the filters starting with wildcards
+	assert.Equal(t, []string{"sys"}, matchStrings(tt, "$SYS/a"))
+	// the topics with wildcards are invalid to match
+	assert.Empty(t, tt.Match("a/+"))
+
+	assert.False(t, tt.Remove("a/b", "plus"))
+	assert.False(t, tt.Remove("x/y", "plus"))
+	assert.True(t, tt.Remove("a/b", "exact"))
+	assert.True(t, tt.Remove("a/+", "plus"))
+	assert.Equal(t, 4, tt.Len())
+	assert.Equal(t, []string{"all", "hash", "plus2"}, matchStrings(tt, "a/b"))
+	assert.Empty(t, tt.root.children["a"].children["b"])
+	assert.NotContains(t, tt.root.children["a"].children, "+")
+}
+
+func TestTopicTreeShared(t *testing.T) {
+	tt := NewTopicTree()
+	assert.NoError(t, tt.Add("$share/g1/a/+", "g1m1"))
+	assert.NoError(t, tt.Add("$share/g1/a/+", "g1m2"))
+	assert.NoError(t, tt.Add("$share/g2/a/#", "g2m1"))
+	assert.NoError(t, tt.Add("a/+", "normal"))
+	assert.Equal(t, []interface{}{"g1m1", "g1m2"}, tt.Get("$share/g1/a/+"))
+	assert.Nil(t, tt.Get("$share/g3/a/+"))
+
+	// one member of each group in round robin
+	counts := map[string]int{}
+	for i := 0; i < 4; i++ {
+		vs := matchStrings(tt, "a/b")
+		assert.Len(t, vs, 3)
+		for _, v := range vs {
+			counts[v]++
+		}
+	}
+	assert.Equal(t, map[string]int{"g1m1": 2, "g1m2": 2, "g2m1": 4, "normal": 4}, counts)
+
+	assert.True(t, tt.Remove("$share/g1/a/+", "g1m1"))
+	assert.Equal(t, []string{"g1m2", "g2m1", "normal"}, matchStrings(tt, "a/b"))
+	assert.True(t, tt.Remove("$share/g1/a/+", "g1m2"))
+	assert.False(t, tt.Remove("$share/g1/a/+", "g1m2"))
+	assert.Equal(t, []string{"g2m1", "normal"}, matchStrings(tt, "a/b"))
+	assert.Equal(t, 2, tt.Len())
+}
@@ -0,0 +1,79 @@
+package mqtt
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/256dpi/gomqtt/transport"
+	"github.com/baetyl/baetyl-go/errors"
+	"github.com/gorilla/websocket"
+)
+
+// the default ports of websocket if not specified in address
+const (
+	defaultWSPort  = "80"
+	defaultWSSPort = "443"
+)
+
+// WebSocketConfig the config of mqtt over websocket
+type WebSocketConfig struct {
+	Path        string            `yaml:"path" json:"path"`                              // overrides the path of address if set
+	Subprotocol string            `yaml:"subprotocol" json:"subprotocol" default:"mqtt"` // the sub-protocol requested in handshake
+	Header      map[string]string `yaml:"header" json:"header"`                          // the additional headers of handshake request
+}
+
+// isWebSocket checks whether the address is of websocket scheme (ws:// or wss://)
+func isWebSocket(address string) bool {
+	u, err := url.Parse(address)
+	return err == nil && (u.Scheme == "ws" || u.Scheme == "wss")
+}
+
+// dialWebSocket dials the server of the websocket address, the tls config is used for wss:// only
+func dialWebSocket(address string, cfg WebSocketConfig, tc *tls.Config, timeout time.Duration) (*transport.WebSocketConn, error) {
+	u, err := url.ParseRequestURI(address)
+	if err != nil {
+		return nil, errors.Coded(errors.CodeInvalidArgument, "address (%s) is invalid: %s", address, err.Error())
+	}
+	port := defaultWSPort
+	switch u.Scheme {
+	case "ws":
+	case "wss":
+		port = defaultWSSPort
+	default:
+		return nil, errors.Coded(errors.CodeInvalidArgument, "scheme (%s) of websocket not supported", u.Scheme)
+	}
+	if u.Port() == "" {
+		u.Host = net.JoinHostPort(u.Hostname(), port)
+	}
+	if cfg.Path != "" {
+		u.Path = cfg.Path
+	}
+
+	dialer := &websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		TLSClientConfig:  tc,
+		HandshakeTimeout: timeout,
+	}
+	if cfg.Subprotocol != "" {
+		dialer.Subprotocols = []string{cfg.Subprotocol}
+	}
+	header := http.Header{}
+	for k, v := range cfg.Header {
+		header.Set(k, v)
+	}
+	conn, resp, err := dialer.Dial(u.String(), header)
+	if err != nil {
+		if resp != nil {
+			return nil, errors.Coded(errors.CodeUnavailable, "failed to dial websocket (%s): %s (%s)", u.String(), err.Error(), resp.Status)
+		}
+		return nil, err
+	}
+	if cfg.Subprotocol != "" && conn.Subprotocol() != cfg.Subprotocol {
+		conn.Close()
+		return nil, errors.Coded(errors.CodeFailedPrecondition, "sub-protocol (%s) not accepted by server", cfg.Subprotocol)
+	}
+	return transport.NewWebSocketConn(conn), nil
+}
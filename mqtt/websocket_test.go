@@ -0,0 +1,147 @@
+package mqtt
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/256dpi/gomqtt/transport"
+	"github.com/baetyl/baetyl-go/flow"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// initMockBrokerWS serves the flows over websocket in order, speaks mqtt 5.0 if the version of flow is 5
+func initMockBrokerWS(t *testing.T, path string, versions []byte, testFlows ...*flow.Flow) (chan struct{}, *httptest.Server) {
+	done := make(chan struct{})
+	conns := make(chan *websocket.Conn, len(testFlows))
+	upgrader := websocket.Upgrader{Subprotocols: []string{"mqtt"}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			http.NotFound(w, r)
+			return
+		}
+		assert.Equal(t, "test", r.Header.Get("X-Client"))
+		conn, err := upgrader.Upgrade(w, r, nil)
+		assert.NoError(t, err)
+		conns <- conn
+	}))
+
+	go func() {
+		for i, f := range testFlows {
+			conn := <-conns
+			var c Connection = transport.NewWebSocketConn(conn)
+			if versions[i] == Version5 {
+				c = newConn5(&wsCarrier{conn: conn})
+			}
+			err := f.Test(newWrapper(c))
+			assert.NoError(t, err)
+		}
+		close(done)
+	}()
+	return done, srv
+}
+
+func TestMqttClientWebSocket(t *testing.T) {
+	publish := NewPublish()
+	publish.ID = 1
+	publish.Message = Message{Topic: "test", Payload: []byte("hello"), QOS: 1}
+	puback := NewPuback()
+	puback.ID = 1
+
+	broker := flow.New().Debug().
+		Receive(connectPacket()).
+		Send(connackPacket()).
+		Receive(publish).
+		Send(puback).
+		Receive(disconnectPacket()).
+		End()
+
+	done, srv := initMockBrokerWS(t, "/mqtt", []byte{Version311}, broker)
+	defer srv.Close()
+
+	cc := newConfig("")
+	cc.Address = strings.Replace(srv.URL, "http://", "ws://", 1) + "/ignored"
+	cc.WebSocket.Path = "/mqtt"
+	cc.WebSocket.Header = map[string]string{"X-Client": "test"}
+	obs := newMockObserver(t)
+	cli, err := NewClient(cc, obs)
+	assert.NoError(t, err)
+
+	err = cli.Publish(1, "test", []byte("hello"), 1, false, false)
+	assert.NoError(t, err)
+	obs.assertPkts(puback)
+
+	assert.NoError(t, cli.Close())
+	safeReceive(done)
+}
+
+func TestMqttClientWebSocket5(t *testing.T) {
+	connect := NewConnect5()
+	connect.CleanSession = true
+
+	publish := NewPublish5()
+	publish.ID = 1
+	publish.Message = Message{Topic: "test", Payload: []byte("hello"), QOS: 1}
+	publish.Properties.AddUserProperty("trace-id", "abc")
+	puback := NewPuback5()
+	puback.ID = 1
+
+	broker := flow.New().Debug().
+		Receive(connect).
+		Send(NewConnack5()).
+		Receive(publish).
+		Send(puback).
+		Receive(NewDisconnect5()).
+		End()
+
+	done, srv := initMockBrokerWS(t, "/mqtt", []byte{Version5}, broker)
+	defer srv.Close()
+
+	cc := newConfig("")
+	cc.Address = strings.Replace(srv.URL, "http://", "ws://", 1) + "/mqtt"
+	cc.ProtocolVersion = 5
+	cc.WebSocket.Header = map[string]string{"X-Client": "test"}
+	obs := newMockObserver(t)
+	cli, err := NewClient(cc, obs)
+	assert.NoError(t, err)
+
+	err = cli.PublishWithProperties(1, "test", []byte("hello"), 1, false, false, publish.Properties)
+	assert.NoError(t, err)
+	obs.assertPkts(puback.Puback)
+
+	assert.NoError(t, cli.Close())
+	safeReceive(done)
+}
+
+func TestDialWebSocket(t *testing.T) {
+	assert.True(t, isWebSocket("ws://localhost/mqtt"))
+	assert.True(t, isWebSocket("wss://localhost"))
+	assert.False(t, isWebSocket("tcp://localhost:1883"))
+	assert.False(t, isWebSocket("ssl://localhost:8883"))
+
+	upgrader := websocket.Upgrader{Subprotocols: []string{"mqttv3.1"}}
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	defer srv.Close()
+	tc := srv.Client().Transport.(*http.Transport).TLSClientConfig
+	address := strings.Replace(srv.URL, "https://", "wss://", 1)
+
+	conn, err := dialWebSocket(address, WebSocketConfig{Subprotocol: "mqttv3.1"}, tc, 0)
+	assert.NoError(t, err)
+	assert.NoError(t, conn.Close())
+
+	_, err = dialWebSocket(address, WebSocketConfig{Subprotocol: "mqtt"}, tc, 0)
+	assert.EqualError(t, err, "sub-protocol (mqtt) not accepted by server")
+
+	_, err = dialWebSocket(address, WebSocketConfig{}, nil, 0)
+	assert.Error(t, err)
+
+	_, err = dialWebSocket("tcp://localhost:1883", WebSocketConfig{}, nil, 0)
+	assert.EqualError(t, err, "scheme (tcp) of websocket not supported")
+}
@@ -0,0 +1,18 @@
+package native
+
+import (
+	"time"
+
+	"github.com/baetyl/baetyl-go/utils"
+)
+
+// Config the config of native process engine
+type Config struct {
+	Dir         string            `yaml:"dir" json:"dir" default:"var/lib/baetyl/run"` // the working directory of services is dir/app/service
+	NodeName    string            `yaml:"nodeName" json:"nodeName"`
+	Env         map[string]string `yaml:"env" json:"env"`                 // the env injected into all services
+	Certificate utils.Certificate `yaml:"certificate" json:"certificate"` // the certificate files injected into all services by env
+	StopTimeout time.Duration     `yaml:"stopTimeout" json:"stopTimeout" default:"10s"`
+	MinBackoff  time.Duration     `yaml:"minBackoff" json:"minBackoff" default:"1s"`
+	MaxBackoff  time.Duration     `yaml:"maxBackoff" json:"maxBackoff" default:"1m"` // overridden by the backoff of service restart policy
+}
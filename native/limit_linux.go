@@ -0,0 +1,27 @@
+package native
+
+import (
+	"syscall"
+	"unsafe"
+
+	v1 "github.com/baetyl/baetyl-go/spec/v1"
+)
+
+// setLimits sets the memory limit of the process as the limit of its address space (RLIMIT_AS),
+// other resources such as cpu are not limited
+func setLimits(pid int, limits map[string]string) error {
+	v, ok := limits["memory"]
+	if !ok {
+		return nil
+	}
+	n, err := v1.ParseQuantity(v)
+	if err != nil {
+		return err
+	}
+	rl := &syscall.Rlimit{Cur: uint64(n), Max: uint64(n)}
+	_, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64, uintptr(pid), uintptr(syscall.RLIMIT_AS), uintptr(unsafe.Pointer(rl)), 0, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
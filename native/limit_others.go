@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package native
+
+// setLimits is not supported
+func setLimits(pid int, limits map[string]string) error {
+	return nil
+}
@@ -0,0 +1,251 @@
+// Package native provides the process engine which runs services as child processes, for non-container deployments.
+package native
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/baetyl/baetyl-go/context"
+	"github.com/baetyl/baetyl-go/log"
+	v1 "github.com/baetyl/baetyl-go/spec/v1"
+)
+
+// all env keys of the certificate files injected
+const (
+	EnvKeyCertCA   = "BAETYL_CERT_CA"
+	EnvKeyCertKey  = "BAETYL_CERT_KEY"
+	EnvKeyCertFile = "BAETYL_CERT_FILE"
+)
+
+// all states of process
+const (
+	StateRunning    = "running"
+	StateRestarting = "restarting"
+	StateExited     = "exited"
+	StateStopped    = "stopped"
+)
+
+// all errors of engine
+var (
+	ErrEngineClosed    = errors.New("engine is closed")
+	ErrServiceNotFound = errors.New("service not found")
+)
+
+// Status the status of a service instance
+type Status struct {
+	App       string    `json:"app"`
+	Service   string    `json:"service"`
+	Instance  int       `json:"instance"`
+	PID       int       `json:"pid,omitempty"`
+	State     string    `json:"state"`
+	Restarts  int       `json:"restarts"`
+	ExitCode  int       `json:"exitCode"`
+	Error     string    `json:"error,omitempty"`
+	StartTime time.Time `json:"startTime,omitempty"`
+}
+
+// Engine the process engine which starts, stops and restarts the processes of services
+type Engine struct {
+	cfg    Config
+	svcs   map[string][]*process
+	closed bool
+	mu     sync.Mutex
+	log    *log.Logger
+}
+
+// NewEngine creates a new process engine
+func NewEngine(cfg Config) (*Engine, error) {
+	err := os.MkdirAll(cfg.Dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	return &Engine{
+		cfg:  cfg,
+		svcs: map[string][]*process{},
+		log:  log.With(log.Any("engine", "native")),
+	}, nil
+}
+
+// Start starts the processes (one per replica) of the service, the running ones of the same service are stopped first.
+// The program is the first element of command, or the image if command is not set, and is evaluated relative to
+// the working directory if it is a relative path.
+func (e *Engine) Start(app string, svc v1.Service) error {
+	program, args := svc.Image, svc.Args
+	if len(svc.Command) > 0 {
+		program = svc.Command[0]
+		args = append(append([]string{}, svc.Command[1:]...), svc.Args...)
+	}
+	if program == "" {
+		return fmt.Errorf("the program of service (%s) is not set", svc.Name)
+	}
+	var limits map[string]string
+	if svc.Resources != nil {
+		limits = svc.Resources.Limits
+	}
+	for k, v := range limits {
+		if _, err := v1.ParseQuantity(v); err != nil {
+			return fmt.Errorf("the limit (%s) of service (%s) is invalid: %s", k, svc.Name, err.Error())
+		}
+	}
+	dir := filepath.Join(e.cfg.Dir, app, svc.Name)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return ErrEngineClosed
+	}
+	key := app + "/" + svc.Name
+	stopAll(e.svcs[key])
+	delete(e.svcs, key)
+
+	n := svc.Replica
+	if n < 1 {
+		n = 1
+	}
+	env := e.env(app, svc)
+	var ps []*process
+	for i := 0; i < n; i++ {
+		p := newProcess(e.cfg, app, svc, i, program, args, dir, env, limits)
+		ps = append(ps, p)
+	}
+	e.svcs[key] = ps
+	e.log.Info("service is started", log.Any("app", app), log.Any("service", svc.Name), log.Any("replica", n))
+	return nil
+}
+
+// Stop stops the processes of the service
+func (e *Engine) Stop(app, name string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	key := app + "/" + name
+	ps, ok := e.svcs[key]
+	if !ok {
+		return ErrServiceNotFound
+	}
+	stopAll(ps)
+	delete(e.svcs, key)
+	e.log.Info("service is stopped", log.Any("app", app), log.Any("service", name))
+	return nil
+}
+
+// Restart restarts the processes of the service immediately, the restart count is kept
+func (e *Engine) Restart(app, name string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	ps, ok := e.svcs[app+"/"+name]
+	if !ok {
+		return ErrServiceNotFound
+	}
+	for _, p := range ps {
+		p.restart()
+	}
+	return nil
+}
+
+// Status returns the status of all instances of the service
+func (e *Engine) Status(app, name string) ([]Status, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	ps, ok := e.svcs[app+"/"+name]
+	if !ok {
+		return nil, ErrServiceNotFound
+	}
+	ss := make([]Status, 0, len(ps))
+	for _, p := range ps {
+		ss = append(ss, p.status())
+	}
+	return ss, nil
+}
+
+// List returns the status of all instances of all services, sorted by app and service
+func (e *Engine) List() []Status {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	keys := make([]string, 0, len(e.svcs))
+	for k := range e.svcs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var ss []Status
+	for _, k := range keys {
+		for _, p := range e.svcs[k] {
+			ss = append(ss, p.status())
+		}
+	}
+	return ss
+}
+
+// Close stops all processes
+func (e *Engine) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.closed = true
+	var ps []*process
+	for _, v := range e.svcs {
+		ps = append(ps, v...)
+	}
+	stopAll(ps)
+	e.svcs = map[string][]*process{}
+	return nil
+}
+
+func (e *Engine) env(app string, svc v1.Service) []string {
+	vs := map[string]string{}
+	for _, kv := range os.Environ() {
+		if i := strings.Index(kv, "="); i > 0 {
+			vs[kv[:i]] = kv[i+1:]
+		}
+	}
+	for k, v := range e.cfg.Env {
+		vs[k] = v
+	}
+	vs[context.EnvKeyNodeName] = e.cfg.NodeName
+	vs[context.EnvKeyAppName] = app
+	vs[context.EnvKeyServiceName] = svc.Name
+	certs := map[string]string{
+		EnvKeyCertCA:   e.cfg.Certificate.CA,
+		EnvKeyCertKey:  e.cfg.Certificate.Key,
+		EnvKeyCertFile: e.cfg.Certificate.Cert,
+	}
+	for k, v := range certs {
+		if v == "" {
+			continue
+		}
+		if abs, err := filepath.Abs(v); err == nil {
+			v = abs
+		}
+		vs[k] = v
+	}
+	for _, v := range svc.Env {
+		vs[v.Name] = v.Value
+	}
+	env := make([]string, 0, len(vs))
+	for k, v := range vs {
+		env = append(env, k+"="+v)
+	}
+	sort.Strings(env)
+	return env
+}
+
+// stopAll stops the processes in parallel
+func stopAll(ps []*process) {
+	var wg sync.WaitGroup
+	for _, p := range ps {
+		wg.Add(1)
+		go func(p *process) {
+			defer wg.Done()
+			p.stop()
+		}(p)
+	}
+	wg.Wait()
+}
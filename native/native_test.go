@@ -0,0 +1,183 @@
+package native
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/baetyl/baetyl-go/log"
+	v1 "github.com/baetyl/baetyl-go/spec/v1"
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestEngine(t *testing.T) (*Engine, string) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell is required")
+	}
+	dir, err := ioutil.TempDir("", "native")
+	assert.NoError(t, err)
+	var cfg Config
+	assert.NoError(t, utils.SetDefaults(&cfg))
+	cfg.Dir = dir
+	cfg.NodeName = "node"
+	cfg.Env = map[string]string{"A": "a"}
+	cfg.Certificate.CA = "/etc/baetyl/ca.pem"
+	cfg.StopTimeout = time.Second
+	cfg.MinBackoff = 10 * time.Millisecond
+	cfg.MaxBackoff = 50 * time.Millisecond
+	e, err := NewEngine(cfg)
+	assert.NoError(t, err)
+	return e, dir
+}
+
+func waitStatus(t *testing.T, e *Engine, app, name string, fn func(Status) bool) Status {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		ss, err := e.Status(app, name)
+		assert.NoError(t, err)
+		if fn(ss[0]) {
+			return ss[0]
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("unexpected status: %+v", ss[0])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestEngine(t *testing.T) {
+	e, dir := newTestEngine(t)
+	defer os.RemoveAll(dir)
+	defer e.Close()
+
+	svc := v1.Service{
+		Name:    "echo",
+		Command: []string{"sh", "-c"},
+		Args:    []string{`echo "$BAETYL_NODE_NAME $BAETYL_APP_NAME $BAETYL_SERVICE_NAME $BAETYL_CERT_CA $A $B" > env.txt; exec sleep 60`},
+		Env:     []v1.Environment{{Name: "B", Value: "b"}},
+		Replica: 2,
+	}
+	assert.NoError(t, e.Start("app", svc))
+	s := waitStatus(t, e, "app", "echo", func(s Status) bool {
+		return s.State == StateRunning && utils.FileExists(filepath.Join(dir, "app", "echo", "env.txt"))
+	})
+	assert.NotZero(t, s.PID)
+	data, err := ioutil.ReadFile(filepath.Join(dir, "app", "echo", "env.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "node app echo /etc/baetyl/ca.pem a b", strings.TrimSpace(string(data)))
+	ss, err := e.Status("app", "echo")
+	assert.NoError(t, err)
+	assert.Len(t, ss, 2)
+	assert.Len(t, e.List(), 2)
+
+	// restarted manually
+	assert.NoError(t, e.Restart("app", "echo"))
+	s = waitStatus(t, e, "app", "echo", func(s Status) bool {
+		return s.State == StateRunning && s.Restarts == 1
+	})
+	assert.NotZero(t, s.PID)
+
+	assert.NoError(t, e.Stop("app", "echo"))
+	assert.Equal(t, ErrServiceNotFound, e.Stop("app", "echo"))
+	assert.Equal(t, ErrServiceNotFound, e.Restart("app", "echo"))
+	_, err = e.Status("app", "echo")
+	assert.Equal(t, ErrServiceNotFound, err)
+	assert.Empty(t, e.List())
+
+	assert.Error(t, e.Start("app", v1.Service{Name: "none"}))
+	assert.NoError(t, e.Close())
+	assert.Equal(t, ErrEngineClosed, e.Start("app", svc))
+}
+
+func TestEngineRestartPolicy(t *testing.T) {
+	e, dir := newTestEngine(t)
+	defer os.RemoveAll(dir)
+	defer e.Close()
+
+	// restarted on failure until retries exhausted
+	svc := v1.Service{
+		Name:    "fail",
+		Command: []string{"sh", "-c", "echo started; exit 3"},
+		Restart: &v1.RestartPolicy{Policy: "on-failure", Retries: 2},
+	}
+	assert.NoError(t, e.Start("app", svc))
+	s := waitStatus(t, e, "app", "fail", func(s Status) bool {
+		return s.State == StateExited
+	})
+	assert.Equal(t, 2, s.Restarts)
+	assert.Equal(t, 3, s.ExitCode)
+
+	// not restarted on success
+	svc = v1.Service{
+		Name:    "ok",
+		Command: []string{"sh", "-c", "exit 0"},
+		Restart: &v1.RestartPolicy{Policy: "on-failure"},
+	}
+	assert.NoError(t, e.Start("app", svc))
+	s = waitStatus(t, e, "app", "ok", func(s Status) bool {
+		return s.State == StateExited
+	})
+	assert.Equal(t, 0, s.Restarts)
+	assert.Equal(t, 0, s.ExitCode)
+
+	// restarted always
+	svc = v1.Service{
+		Name:    "always",
+		Command: []string{"sh", "-c", "exit 0"},
+	}
+	assert.NoError(t, e.Start("app", svc))
+	waitStatus(t, e, "app", "always", func(s Status) bool {
+		return s.Restarts >= 3
+	})
+
+	// not started
+	svc = v1.Service{
+		Name:    "missing",
+		Image:   "./missing",
+		Restart: &v1.RestartPolicy{Policy: "never"},
+	}
+	assert.NoError(t, e.Start("app", svc))
+	s = waitStatus(t, e, "app", "missing", func(s Status) bool {
+		return s.State == StateExited
+	})
+	assert.Equal(t, -1, s.ExitCode)
+	assert.NotEmpty(t, s.Error)
+
+	// invalid limits
+	svc.Resources = &v1.Resources{Limits: map[string]string{"memory": "1x"}}
+	assert.Error(t, e.Start("app", svc))
+}
+
+func TestEngineStopTimeout(t *testing.T) {
+	e, dir := newTestEngine(t)
+	defer os.RemoveAll(dir)
+	defer e.Close()
+
+	svc := v1.Service{
+		Name:      "stubborn",
+		Command:   []string{"sh", "-c", "trap '' TERM; echo ready > ready.txt; while true; do sleep 0.1; done"},
+		Resources: &v1.Resources{Limits: map[string]string{"memory": "1Gi"}},
+	}
+	assert.NoError(t, e.Start("app", svc))
+	waitStatus(t, e, "app", "stubborn", func(s Status) bool {
+		return s.State == StateRunning && utils.FileExists(filepath.Join(dir, "app", "stubborn", "ready.txt"))
+	})
+	start := time.Now()
+	assert.NoError(t, e.Stop("app", "stubborn"))
+	assert.True(t, time.Since(start) >= time.Second)
+}
+
+func TestLogWriter(t *testing.T) {
+	w := &logWriter{log: log.With()}
+	n, err := w.Write([]byte("a\r\nb\nc"))
+	assert.NoError(t, err)
+	assert.Equal(t, 6, n)
+	assert.Equal(t, "c", string(w.buf))
+	w.flush()
+	assert.Empty(t, w.buf)
+}
@@ -0,0 +1,251 @@
+package native
+
+import (
+	"bytes"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/baetyl/baetyl-go/log"
+	v1 "github.com/baetyl/baetyl-go/spec/v1"
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/jpillora/backoff"
+)
+
+// process supervises one instance of service, restarts the child process by the restart policy with backoff
+type process struct {
+	cfg     Config
+	svc     v1.Service
+	program string
+	args    []string
+	dir     string
+	env     []string
+	limits  map[string]string
+	st      Status
+	kick    chan struct{}
+	mu      sync.RWMutex
+	log     *log.Logger
+	tomb    utils.Tomb
+}
+
+func newProcess(cfg Config, app string, svc v1.Service, instance int, program string, args []string, dir string, env []string, limits map[string]string) *process {
+	p := &process{
+		cfg:     cfg,
+		svc:     svc,
+		program: program,
+		args:    args,
+		dir:     dir,
+		env:     env,
+		limits:  limits,
+		st:      Status{App: app, Service: svc.Name, Instance: instance},
+		kick:    make(chan struct{}, 1),
+		log:     log.With(log.Any("app", app), log.Any("service", svc.Name), log.Any("instance", instance)),
+	}
+	p.tomb.Go(p.supervising)
+	return p
+}
+
+func (p *process) supervising() error {
+	bf := backoff.Backoff{
+		Min:    p.cfg.MinBackoff,
+		Max:    p.cfg.MaxBackoff,
+		Factor: 1.6,
+	}
+	policy, retries := "always", 0
+	if r := p.svc.Restart; r != nil {
+		if r.Policy != "" {
+			policy = r.Policy
+		}
+		retries = r.Retries
+		if r.Backoff > 0 {
+			bf.Max = r.Backoff
+		}
+	}
+	for {
+		start := time.Now()
+		code, err := p.run()
+		if !p.tomb.Alive() {
+			return nil
+		}
+		restarted := false
+		select {
+		case <-p.kick:
+			restarted = true
+		default:
+		}
+		if !restarted {
+			if policy == "never" || (policy == "on-failure" && err == nil) || (retries > 0 && p.restarts() >= retries) {
+				p.update(func(s *Status) {
+					s.State, s.PID = StateExited, 0
+				})
+				p.log.Info("process is exited and not restarted", log.Any("code", code))
+				// waits for restarting manually
+				select {
+				case <-p.kick:
+				case <-p.tomb.Dying():
+					return nil
+				}
+			} else {
+				// resets the backoff if the process has run long enough
+				if time.Since(start) > bf.Max {
+					bf.Reset()
+				}
+				d := bf.Duration()
+				p.update(func(s *Status) {
+					s.State, s.PID = StateRestarting, 0
+				})
+				p.log.Warn("process is exited, restarts later", log.Any("code", code), log.Any("backoff", d))
+				select {
+				case <-time.After(d):
+				case <-p.kick:
+				case <-p.tomb.Dying():
+					return nil
+				}
+			}
+		}
+		p.update(func(s *Status) {
+			s.Restarts++
+		})
+	}
+}
+
+// run runs the child process until it is exited, killed for restarting, or the supervisor is dying
+func (p *process) run() (int, error) {
+	stdout := &logWriter{log: p.log.With(log.Any("stream", "stdout"))}
+	stderr := &logWriter{log: p.log.With(log.Any("stream", "stderr")), warn: true}
+	defer stdout.flush()
+	defer stderr.flush()
+
+	cmd := exec.Command(p.program, p.args...)
+	cmd.Dir = p.dir
+	cmd.Env = p.env
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err := cmd.Start()
+	if err != nil {
+		p.log.Error("failed to start process", log.Error(err))
+		p.update(func(s *Status) {
+			s.ExitCode, s.Error = -1, err.Error()
+		})
+		return -1, err
+	}
+	if err = setLimits(cmd.Process.Pid, p.limits); err != nil {
+		p.log.Warn("failed to set resource limits", log.Error(err))
+	}
+	p.update(func(s *Status) {
+		s.State, s.PID, s.StartTime, s.Error = StateRunning, cmd.Process.Pid, time.Now(), ""
+	})
+	p.log.Info("process is started", log.Any("pid", cmd.Process.Pid))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	select {
+	case err = <-done:
+	case <-p.kick:
+		// pushes back to let the supervisor restart immediately
+		p.restart()
+		err = p.terminate(cmd, done)
+	case <-p.tomb.Dying():
+		err = p.terminate(cmd, done)
+	}
+	code := cmd.ProcessState.ExitCode()
+	p.update(func(s *Status) {
+		s.ExitCode, s.Error = code, ""
+		if err != nil {
+			s.Error = err.Error()
+		}
+	})
+	return code, err
+}
+
+// terminate sends SIGTERM to the child process, and kills it if it is not exited after the stop timeout
+func (p *process) terminate(cmd *exec.Cmd, done <-chan error) error {
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		cmd.Process.Kill()
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(p.cfg.StopTimeout):
+		p.log.Warn("process is killed after stop timeout", log.Any("timeout", p.cfg.StopTimeout))
+		cmd.Process.Kill()
+		return <-done
+	}
+}
+
+func (p *process) restart() {
+	select {
+	case p.kick <- struct{}{}:
+	default:
+	}
+}
+
+func (p *process) stop() {
+	p.tomb.Kill(nil)
+	p.tomb.Wait()
+	p.update(func(s *Status) {
+		s.State, s.PID = StateStopped, 0
+	})
+}
+
+func (p *process) restarts() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.st.Restarts
+}
+
+func (p *process) status() Status {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.st
+}
+
+func (p *process) update(fn func(*Status)) {
+	p.mu.Lock()
+	fn(&p.st)
+	p.mu.Unlock()
+}
+
+// logWriter writes the output of child process into log line by line
+type logWriter struct {
+	log  *log.Logger
+	warn bool
+	buf  []byte
+	mu   sync.Mutex
+}
+
+func (w *logWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, b...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.print(w.buf[:i])
+		w.buf = w.buf[i+1:]
+	}
+	return len(b), nil
+}
+
+func (w *logWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.buf) > 0 {
+		w.print(w.buf)
+		w.buf = nil
+	}
+}
+
+func (w *logWriter) print(line []byte) {
+	line = bytes.TrimRight(line, "\r")
+	if w.warn {
+		w.log.Warn(string(line))
+	} else {
+		w.log.Info(string(line))
+	}
+}
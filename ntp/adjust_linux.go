@@ -0,0 +1,23 @@
+package ntp
+
+import (
+	"reflect"
+	"syscall"
+	"time"
+)
+
+// the mode of adjtimex to adjust the time gradually as adjtime does
+const adjOffsetSingleshot = 0x8001
+
+// adjustTime steps the system time by settimeofday, or slews it by adjtimex, which requires the root privilege
+func adjustTime(offset time.Duration, step bool) error {
+	if step {
+		tv := syscall.NsecToTimeval(time.Now().Add(offset).UnixNano())
+		return syscall.Settimeofday(&tv)
+	}
+	tx := &syscall.Timex{Modes: adjOffsetSingleshot}
+	// the type of offset (in microseconds) differs between architectures
+	reflect.ValueOf(&tx.Offset).Elem().SetInt(int64(offset / time.Microsecond))
+	_, err := syscall.Adjtimex(tx)
+	return err
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package ntp
+
+import "time"
+
+// adjustTime is not supported
+func adjustTime(offset time.Duration, step bool) error {
+	return ErrNotSupported
+}
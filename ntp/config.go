@@ -0,0 +1,19 @@
+package ntp
+
+import "time"
+
+// all modes to adjust the system time
+const (
+	AdjustNone = "none" // the system time is not adjusted, the offset is exposed for timestamp correction
+	AdjustStep = "step" // the system time is set at once
+	AdjustSlew = "slew" // the system time is adjusted gradually, stepped if the offset exceeds the threshold
+)
+
+// Config the config of time synchronization
+type Config struct {
+	Servers       []string      `yaml:"servers" json:"servers" default:"[\"pool.ntp.org\"]"` // the port is 123 if missing
+	Interval      time.Duration `yaml:"interval" json:"interval" default:"1h"`
+	Timeout       time.Duration `yaml:"timeout" json:"timeout" default:"5s"`
+	Adjust        string        `yaml:"adjust" json:"adjust" default:"none" validate:"regexp=^(none|step|slew)$"`
+	StepThreshold time.Duration `yaml:"stepThreshold" json:"stepThreshold" default:"128ms"`
+}
@@ -0,0 +1,105 @@
+// Package ntp synchronizes the time with ntp servers, it reports the offset and the drift of local clock,
+// and optionally adjusts the system time (or exposes the offset for timestamp correction),
+// since bad clocks break tls and the ordering of telemetry on many devices.
+package ntp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// all errors
+var (
+	ErrUnsynchronized  = errors.New("server is unsynchronized")
+	ErrResponseInvalid = errors.New("response is invalid")
+	ErrNotSupported    = errors.New("adjusting system time is not supported")
+)
+
+const (
+	packetSize = 48
+	// the seconds from the ntp epoch (1900) to the unix epoch (1970)
+	epochOffset = 2208988800
+	version     = 4
+	modeClient  = 3
+	modeServer  = 4
+)
+
+// Response the response of ntp server
+type Response struct {
+	Server  string
+	Stratum uint8
+	Offset  time.Duration // the offset of server clock relative to local clock
+	Delay   time.Duration // the round trip delay
+	Time    time.Time     // the server time when the response is received
+}
+
+// Query queries the time of the ntp server (SNTP, RFC 4330)
+func Query(server string, timeout time.Duration) (*Response, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "123")
+	}
+	conn, err := net.DialTimeout("udp", server, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	req := make([]byte, packetSize)
+	req[0] = version<<3 | modeClient
+	t1 := time.Now()
+	// the transmit timestamp is echoed by server as the originate timestamp
+	putTime(req[40:], t1)
+	if _, err = conn.Write(req); err != nil {
+		return nil, err
+	}
+	res := make([]byte, packetSize)
+	n, err := conn.Read(res)
+	if err != nil {
+		return nil, err
+	}
+	t4 := time.Now()
+	if n < packetSize || res[0]&0x7 != modeServer || binary.BigEndian.Uint64(res[24:]) != binary.BigEndian.Uint64(req[40:]) {
+		return nil, ErrResponseInvalid
+	}
+	if res[0]>>6 == 3 {
+		return nil, ErrUnsynchronized
+	}
+	if res[1] == 0 {
+		return nil, fmt.Errorf("server is unavailable (kiss code %s)", string(res[12:16]))
+	}
+	t2 := getTime(res[32:])
+	t3 := getTime(res[40:])
+	offset := (t2.Sub(t1) + t3.Sub(t4)) / 2
+	delay := t4.Sub(t1) - t3.Sub(t2)
+	if delay < 0 {
+		delay = 0
+	}
+	return &Response{
+		Server:  server,
+		Stratum: res[1],
+		Offset:  offset,
+		Delay:   delay,
+		Time:    t4.Add(offset),
+	}, nil
+}
+
+func putTime(b []byte, t time.Time) {
+	sec := uint64(t.Unix() + epochOffset)
+	frac := uint64(t.Nanosecond()) << 32 / 1e9
+	binary.BigEndian.PutUint64(b, sec<<32|frac)
+}
+
+func getTime(b []byte) time.Time {
+	v := binary.BigEndian.Uint64(b)
+	sec := int64(v>>32) - epochOffset
+	if v>>63 == 0 {
+		// the era 1 since 2036
+		sec += 1 << 32
+	}
+	nsec := int64((v & 0xffffffff) * 1e9 >> 32)
+	return time.Unix(sec, nsec)
+}
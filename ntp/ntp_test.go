@@ -0,0 +1,202 @@
+package ntp
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockServer the fake ntp server whose clock is skewed, the response can be modified for tests
+type mockServer struct {
+	conn   net.PacketConn
+	skew   time.Duration
+	modify func([]byte)
+	mu     sync.Mutex
+}
+
+func newMockServer(t *testing.T, skew time.Duration) *mockServer {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	s := &mockServer{conn: conn, skew: skew}
+	go s.serve()
+	return s
+}
+
+func (s *mockServer) serve() {
+	buf := make([]byte, 1024)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if n < packetSize {
+			continue
+		}
+		res := make([]byte, packetSize)
+		res[0] = version<<3 | modeServer
+		res[1] = 2
+		copy(res[24:32], buf[40:48])
+		putTime(res[32:], time.Now().Add(s.skew))
+		putTime(res[40:], time.Now().Add(s.skew))
+		s.mu.Lock()
+		if s.modify != nil {
+			s.modify(res)
+		}
+		s.mu.Unlock()
+		s.conn.WriteTo(res, addr)
+	}
+}
+
+func (s *mockServer) setModify(f func([]byte)) {
+	s.mu.Lock()
+	s.modify = f
+	s.mu.Unlock()
+}
+
+func (s *mockServer) addr() string {
+	return s.conn.LocalAddr().String()
+}
+
+func (s *mockServer) close() {
+	s.conn.Close()
+}
+
+func TestTime(t *testing.T) {
+	now := time.Unix(1600000000, 123456789)
+	b := make([]byte, 8)
+	putTime(b, now)
+	assert.WithinDuration(t, now, getTime(b), time.Microsecond)
+
+	// era 1
+	now = time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC)
+	putTime(b, now)
+	assert.WithinDuration(t, now, getTime(b), time.Microsecond)
+}
+
+func TestQuery(t *testing.T) {
+	s := newMockServer(t, 10*time.Second)
+	defer s.close()
+
+	res, err := Query(s.addr(), time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, s.addr(), res.Server)
+	assert.Equal(t, uint8(2), res.Stratum)
+	assert.InDelta(t, float64(10*time.Second), float64(res.Offset), float64(100*time.Millisecond))
+	assert.True(t, res.Delay >= 0)
+	assert.WithinDuration(t, time.Now().Add(10*time.Second), res.Time, 100*time.Millisecond)
+
+	s.setModify(func(b []byte) { b[0] = version<<3 | modeClient })
+	_, err = Query(s.addr(), time.Second)
+	assert.Equal(t, ErrResponseInvalid, err)
+
+	s.setModify(func(b []byte) { b[0] |= 3 << 6 })
+	_, err = Query(s.addr(), time.Second)
+	assert.Equal(t, ErrUnsynchronized, err)
+
+	s.setModify(func(b []byte) {
+		b[1] = 0
+		copy(b[12:16], "DENY")
+	})
+	_, err = Query(s.addr(), time.Second)
+	assert.EqualError(t, err, "server is unavailable (kiss code DENY)")
+
+	s.setModify(func(b []byte) { b[24] ^= 0xff })
+	_, err = Query(s.addr(), time.Second)
+	assert.Equal(t, ErrResponseInvalid, err)
+}
+
+func TestSyncer(t *testing.T) {
+	s1 := newMockServer(t, 10*time.Second)
+	defer s1.close()
+	s2 := newMockServer(t, 10*time.Second)
+	s2.close()
+
+	var cfg Config
+	assert.NoError(t, utils.SetDefaults(&cfg))
+	assert.Equal(t, []string{"pool.ntp.org"}, cfg.Servers)
+	assert.Equal(t, AdjustNone, cfg.Adjust)
+	cfg.Servers = []string{s2.addr(), s1.addr()}
+	cfg.Timeout = 200 * time.Millisecond
+
+	syncer := NewSyncer(cfg)
+	defer syncer.Close()
+
+	assert.Eventually(t, func() bool {
+		return !syncer.Stats().Synced.IsZero()
+	}, 5*time.Second, 10*time.Millisecond)
+	stats := syncer.Stats()
+	assert.Equal(t, s1.addr(), stats.Server)
+	assert.InDelta(t, float64(10*time.Second), float64(stats.Offset), float64(100*time.Millisecond))
+	assert.Equal(t, stats.Offset, syncer.Offset())
+	assert.WithinDuration(t, time.Now().Add(10*time.Second), syncer.Now(), 100*time.Millisecond)
+
+	// all servers fail
+	cfg.Servers = []string{s2.addr()}
+	_, err := newSyncer(cfg).Sync()
+	assert.Error(t, err)
+	cfg.Servers = nil
+	_, err = newSyncer(cfg).Sync()
+	assert.EqualError(t, err, "no server configured")
+}
+
+func TestSyncerAdjust(t *testing.T) {
+	var cfg Config
+	assert.NoError(t, utils.SetDefaults(&cfg))
+
+	type adjusted struct {
+		offset time.Duration
+		step   bool
+	}
+	var calls []adjusted
+	newMock := func(adjust string) *Syncer {
+		cfg.Adjust = adjust
+		s := newSyncer(cfg)
+		s.adjust = func(offset time.Duration, step bool) error {
+			calls = append(calls, adjusted{offset, step})
+			return nil
+		}
+		return s
+	}
+
+	now := time.Now()
+	s := newMock(AdjustNone)
+	stats, err := s.update(&Response{Offset: time.Millisecond}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Millisecond, stats.Offset)
+	assert.Equal(t, float64(0), stats.Drift)
+	// 1ms more in 100s, which is 10ppm
+	stats, err = s.update(&Response{Offset: 2 * time.Millisecond}, now.Add(100*time.Second))
+	assert.NoError(t, err)
+	assert.InDelta(t, 10, stats.Drift, 0.001)
+	assert.Equal(t, 2*time.Millisecond, s.Offset())
+	assert.Empty(t, calls)
+
+	s = newMock(AdjustSlew)
+	_, err = s.update(&Response{Offset: time.Millisecond}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), s.Offset())
+	_, err = s.update(&Response{Offset: -time.Second}, now.Add(100*time.Second))
+	assert.NoError(t, err)
+	// the residual after adjusting is zero, so the drift is measured by the new offset
+	assert.InDelta(t, -10000, s.Stats().Drift, 0.001)
+
+	s = newMock(AdjustStep)
+	_, err = s.update(&Response{Offset: time.Millisecond}, now)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []adjusted{
+		{time.Millisecond, false},
+		{-time.Second, true},
+		{time.Millisecond, true},
+	}, calls)
+
+	s = newMock(AdjustStep)
+	s.adjust = func(time.Duration, bool) error { return ErrNotSupported }
+	_, err = s.update(&Response{Offset: time.Millisecond}, now)
+	assert.Equal(t, ErrNotSupported, err)
+	assert.Equal(t, time.Millisecond, s.Offset())
+}
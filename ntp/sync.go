@@ -0,0 +1,159 @@
+package ntp
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/baetyl/baetyl-go/log"
+	"github.com/baetyl/baetyl-go/utils"
+)
+
+// Stats the stats of time synchronization
+type Stats struct {
+	Server  string        `json:"server,omitempty"`
+	Stratum uint8         `json:"stratum,omitempty"`
+	Offset  time.Duration `json:"offset"` // the offset measured at the last synchronization
+	Delay   time.Duration `json:"delay"`
+	Drift   float64       `json:"drift"` // the drift of local clock in ppm, positive if the local clock is slow
+	Synced  time.Time     `json:"synced,omitempty"`
+}
+
+// Syncer synchronizes the time with the ntp servers periodically, the response of least delay is chosen
+type Syncer struct {
+	cfg    Config
+	stats  Stats
+	offset time.Duration // the offset not adjusted, which corrects the timestamps
+	query  func(string, time.Duration) (*Response, error)
+	adjust func(offset time.Duration, step bool) error
+	mu     sync.RWMutex
+	log    *log.Logger
+	tomb   utils.Tomb
+}
+
+// NewSyncer creates a new syncer and starts to synchronize in background
+func NewSyncer(cfg Config) *Syncer {
+	s := newSyncer(cfg)
+	s.tomb.Go(s.syncing)
+	return s
+}
+
+func newSyncer(cfg Config) *Syncer {
+	return &Syncer{
+		cfg:    cfg,
+		query:  Query,
+		adjust: adjustTime,
+		log:    log.With(log.Any("ntp", "syncer")),
+	}
+}
+
+// Sync synchronizes the time immediately
+func (s *Syncer) Sync() (*Stats, error) {
+	var best *Response
+	var err error
+	for _, server := range s.cfg.Servers {
+		res, qerr := s.query(server, s.cfg.Timeout)
+		if qerr != nil {
+			s.log.Debug("failed to query server", log.Any("server", server), log.Error(qerr))
+			err = qerr
+			continue
+		}
+		if best == nil || res.Delay < best.Delay {
+			best = res
+		}
+	}
+	if best == nil {
+		if err == nil {
+			err = errors.New("no server configured")
+		}
+		return nil, err
+	}
+	return s.update(best, time.Now())
+}
+
+// update updates the stats by the response, and adjusts the system time if configured
+func (s *Syncer) update(res *Response, now time.Time) (*Stats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	drift := s.stats.Drift
+	if !s.stats.Synced.IsZero() {
+		// the offset accumulated since the last synchronization
+		if elapsed := now.Sub(s.stats.Synced); elapsed > 0 {
+			drift = float64(res.Offset-s.offset) / float64(elapsed) * 1e6
+		}
+	}
+	s.stats = Stats{
+		Server:  res.Server,
+		Stratum: res.Stratum,
+		Offset:  res.Offset,
+		Delay:   res.Delay,
+		Drift:   drift,
+		Synced:  now,
+	}
+	s.offset = res.Offset
+	stats := s.stats
+	if s.cfg.Adjust == AdjustNone || s.cfg.Adjust == "" {
+		return &stats, nil
+	}
+	step := s.cfg.Adjust == AdjustStep || abs(res.Offset) > s.cfg.StepThreshold
+	if err := s.adjust(res.Offset, step); err != nil {
+		return &stats, err
+	}
+	s.log.Info("system time is adjusted", log.Any("offset", res.Offset), log.Any("step", step))
+	s.offset = 0
+	return &stats, nil
+}
+
+// Stats returns the stats of the last synchronization
+func (s *Syncer) Stats() Stats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.stats
+}
+
+// Offset returns the offset which is not adjusted in system time
+func (s *Syncer) Offset() time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.offset
+}
+
+// Now returns the local time corrected by the offset
+func (s *Syncer) Now() time.Time {
+	return time.Now().Add(s.Offset())
+}
+
+// Close stops synchronizing
+func (s *Syncer) Close() error {
+	s.tomb.Kill(nil)
+	return s.tomb.Wait()
+}
+
+func (s *Syncer) syncing() error {
+	s.log.Info("syncer starts")
+	defer s.log.Info("syncer has stopped")
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+		case <-s.tomb.Dying():
+			return nil
+		}
+		stats, err := s.Sync()
+		if err != nil {
+			s.log.Warn("failed to synchronize time", log.Error(err))
+		} else {
+			s.log.Debug("time is synchronized", log.Any("offset", stats.Offset), log.Any("drift", stats.Drift))
+		}
+		timer.Reset(s.cfg.Interval)
+	}
+}
+
+func abs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,20 @@
+package object
+
+import (
+	"time"
+
+	"github.com/baetyl/baetyl-go/utils"
+)
+
+// S3Config the config of s3 compatible storage, such as AWS S3, MinIO and BOS
+type S3Config struct {
+	Endpoint    string            `yaml:"endpoint" json:"endpoint"` // such as https://s3.bj.bcebos.com or http://minio:9000, empty for AWS
+	Region      string            `yaml:"region" json:"region" default:"us-east-1"`
+	Ak          string            `yaml:"ak" json:"ak"`
+	Sk          string            `yaml:"sk" json:"sk"`
+	PathStyle   bool              `yaml:"pathStyle" json:"pathStyle"` // uses path style (endpoint/bucket/key) instead of virtual host, required by MinIO
+	Certificate utils.Certificate `yaml:",inline" json:",inline"`
+	Timeout     time.Duration     `yaml:"timeout" json:"timeout" default:"10m"`
+	PartSize    utils.Size        `yaml:"partSize" json:"partSize" default:"8388608" validate:"min=5242880"`
+	Concurrency int               `yaml:"concurrency" json:"concurrency" default:"4" validate:"min=1"`
+}
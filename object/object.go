@@ -0,0 +1,39 @@
+// Package object provides the client of object storage, used to upload captured media and pull model artifacts.
+package object
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrObjectNotFound the object is not found
+var ErrObjectNotFound = errors.New("object not found")
+
+// Info the information of object
+type Info struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	ETag         string    `json:"etag"`
+	ContentType  string    `json:"contentType,omitempty"`
+	LastModified time.Time `json:"lastModified"`
+}
+
+// Client the common interface of object storage
+type Client interface {
+	// Upload uploads the object from reader, the large one is uploaded in parts
+	Upload(ctx context.Context, bucket, key string, r io.Reader) error
+	// UploadFile uploads the object from file, the large one is uploaded in parts and resumed if interrupted
+	UploadFile(ctx context.Context, bucket, key, file string) error
+	// Download returns the content of object, the caller must close it
+	Download(ctx context.Context, bucket, key string) (io.ReadCloser, error)
+	// DownloadFile downloads the object into file, resumed if interrupted
+	DownloadFile(ctx context.Context, bucket, key, file string) error
+	// Presign returns the url to access the object without credentials, the method is GET or PUT
+	Presign(method, bucket, key string, expires time.Duration) (string, error)
+	// Stat returns the information of object, returns ErrObjectNotFound if not exists
+	Stat(ctx context.Context, bucket, key string) (*Info, error)
+	// Delete deletes the object
+	Delete(ctx context.Context, bucket, key string) error
+}
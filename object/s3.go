@@ -0,0 +1,351 @@
+package object
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	gohttp "net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/baetyl/baetyl-go/log"
+	"github.com/baetyl/baetyl-go/utils"
+)
+
+// S3Client the client of s3 compatible storage
+type S3Client struct {
+	cfg S3Config
+	cli *s3.S3
+	log *log.Logger
+}
+
+// NewS3Client creates a new s3 client
+func NewS3Client(cfg S3Config) (*S3Client, error) {
+	transport := &gohttp.Transport{Proxy: gohttp.ProxyFromEnvironment}
+	if cfg.Certificate.CA != "" || cfg.Certificate.Key != "" || cfg.Certificate.Cert != "" {
+		tc, err := utils.NewTLSConfigClient(cfg.Certificate)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tc
+	}
+	ac := &aws.Config{
+		Region:           aws.String(cfg.Region),
+		S3ForcePathStyle: aws.Bool(cfg.PathStyle),
+		HTTPClient:       &gohttp.Client{Timeout: cfg.Timeout, Transport: transport},
+	}
+	if cfg.Endpoint != "" {
+		ac.Endpoint = aws.String(cfg.Endpoint)
+	}
+	if cfg.Ak != "" || cfg.Sk != "" {
+		ac.Credentials = credentials.NewStaticCredentials(cfg.Ak, cfg.Sk, "")
+	}
+	sess, err := session.NewSession(ac)
+	if err != nil {
+		return nil, err
+	}
+	return &S3Client{
+		cfg: cfg,
+		cli: s3.New(sess),
+		log: log.With(log.Any("object", "s3")),
+	}, nil
+}
+
+// Upload uploads the object from reader, the large one is uploaded in parts
+func (c *S3Client) Upload(ctx context.Context, bucket, key string, r io.Reader) error {
+	uploader := s3manager.NewUploaderWithClient(c.cli, func(u *s3manager.Uploader) {
+		u.PartSize = int64(c.cfg.PartSize)
+		u.Concurrency = c.cfg.Concurrency
+	})
+	_, err := uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   r,
+	})
+	return convertError(err)
+}
+
+// UploadFile uploads the object from file, the file larger than part size is uploaded in parts,
+// and the parts uploaded before (whose md5 matches) are skipped if the multipart upload of the key is found
+func (c *S3Client) UploadFile(ctx context.Context, bucket, key, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size, partSize := fi.Size(), int64(c.cfg.PartSize)
+	if size <= partSize {
+		_, err = c.cli.PutObjectWithContext(ctx, &s3.PutObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+			Body:   f,
+		})
+		return convertError(err)
+	}
+
+	uploadID, uploaded, err := c.resumeUpload(ctx, bucket, key)
+	if err != nil {
+		return err
+	}
+	if uploadID == "" {
+		out, err := c.cli.CreateMultipartUploadWithContext(ctx, &s3.CreateMultipartUploadInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			return convertError(err)
+		}
+		uploadID = aws.StringValue(out.UploadId)
+	} else {
+		c.log.Info("resume multipart upload", log.Any("key", key), log.Any("uploadID", uploadID), log.Any("parts", len(uploaded)))
+	}
+
+	count := (size + partSize - 1) / partSize
+	parts := make([]*s3.CompletedPart, count)
+	errs := make(chan error, count)
+	nums := make(chan int64)
+	var wg sync.WaitGroup
+	for i := 0; i < c.cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range nums {
+				etag, err := c.uploadPart(ctx, bucket, key, uploadID, f, n, size, uploaded[n])
+				if err != nil {
+					errs <- err
+					continue
+				}
+				parts[n-1] = &s3.CompletedPart{ETag: aws.String(etag), PartNumber: aws.Int64(n)}
+			}
+		}()
+	}
+	for n := int64(1); n <= count; n++ {
+		select {
+		case nums <- n:
+		case <-ctx.Done():
+		}
+	}
+	close(nums)
+	wg.Wait()
+	close(errs)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	for err := range errs {
+		// the multipart upload is kept to resume
+		return err
+	}
+	_, err = c.cli.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(bucket),
+		Key:             aws.String(key),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: parts},
+	})
+	return convertError(err)
+}
+
+// uploadPart uploads the part of file unless the part uploaded before has the same md5
+func (c *S3Client) uploadPart(ctx context.Context, bucket, key, uploadID string, f *os.File, n, size int64, uploaded *s3.Part) (string, error) {
+	partSize := int64(c.cfg.PartSize)
+	offset := (n - 1) * partSize
+	length := partSize
+	if offset+length > size {
+		length = size - offset
+	}
+	if uploaded != nil && aws.Int64Value(uploaded.Size) == length {
+		h := md5.New()
+		_, err := io.Copy(h, io.NewSectionReader(f, offset, length))
+		if err != nil {
+			return "", err
+		}
+		etag := aws.StringValue(uploaded.ETag)
+		if strings.Trim(etag, `"`) == hex.EncodeToString(h.Sum(nil)) {
+			return etag, nil
+		}
+	}
+	out, err := c.cli.UploadPartWithContext(ctx, &s3.UploadPartInput{
+		Bucket:     aws.String(bucket),
+		Key:        aws.String(key),
+		UploadId:   aws.String(uploadID),
+		PartNumber: aws.Int64(n),
+		Body:       io.NewSectionReader(f, offset, length),
+	})
+	if err != nil {
+		return "", convertError(err)
+	}
+	return aws.StringValue(out.ETag), nil
+}
+
+// resumeUpload returns the latest multipart upload of the key and its parts uploaded if any
+func (c *S3Client) resumeUpload(ctx context.Context, bucket, key string) (string, map[int64]*s3.Part, error) {
+	out, err := c.cli.ListMultipartUploadsWithContext(ctx, &s3.ListMultipartUploadsInput{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(key),
+	})
+	if err != nil {
+		return "", nil, convertError(err)
+	}
+	var uploads []*s3.MultipartUpload
+	for _, u := range out.Uploads {
+		if aws.StringValue(u.Key) == key {
+			uploads = append(uploads, u)
+		}
+	}
+	if len(uploads) == 0 {
+		return "", nil, nil
+	}
+	sort.Slice(uploads, func(i, j int) bool {
+		return aws.TimeValue(uploads[i].Initiated).After(aws.TimeValue(uploads[j].Initiated))
+	})
+	uploadID := aws.StringValue(uploads[0].UploadId)
+	parts := map[int64]*s3.Part{}
+	err = c.cli.ListPartsPagesWithContext(ctx, &s3.ListPartsInput{
+		Bucket:   aws.String(bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	}, func(page *s3.ListPartsOutput, _ bool) bool {
+		for _, p := range page.Parts {
+			parts[aws.Int64Value(p.PartNumber)] = p
+		}
+		return true
+	})
+	if err != nil {
+		return "", nil, convertError(err)
+	}
+	return uploadID, parts, nil
+}
+
+// Download returns the content of object, the caller must close it
+func (c *S3Client) Download(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	out, err := c.cli.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, convertError(err)
+	}
+	return out.Body, nil
+}
+
+// DownloadFile downloads the object into a temporary file named with the etag of object,
+// which is resumed if interrupted, and renamed to the file after completed
+func (c *S3Client) DownloadFile(ctx context.Context, bucket, key, file string) error {
+	info, err := c.Stat(ctx, bucket, key)
+	if err != nil {
+		return err
+	}
+	tmp := fmt.Sprintf("%s.%s.part", file, strings.Trim(info.ETag, `"`))
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if offset > info.Size {
+		err = f.Truncate(0)
+		if err != nil {
+			return err
+		}
+		offset, err = f.Seek(0, io.SeekStart)
+		if err != nil {
+			return err
+		}
+	}
+	if offset < info.Size {
+		in := &s3.GetObjectInput{
+			Bucket:  aws.String(bucket),
+			Key:     aws.String(key),
+			IfMatch: aws.String(info.ETag),
+		}
+		if offset > 0 {
+			in.Range = aws.String(fmt.Sprintf("bytes=%d-", offset))
+			c.log.Info("resume download", log.Any("key", key), log.Any("offset", offset))
+		}
+		out, err := c.cli.GetObjectWithContext(ctx, in)
+		if err != nil {
+			return convertError(err)
+		}
+		defer out.Body.Close()
+		_, err = io.Copy(f, out.Body)
+		if err != nil {
+			return err
+		}
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// Presign returns the url to access the object without credentials, the method is GET or PUT
+func (c *S3Client) Presign(method, bucket, key string, expires time.Duration) (string, error) {
+	switch strings.ToUpper(method) {
+	case gohttp.MethodGet:
+		req, _ := c.cli.GetObjectRequest(&s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+		return req.Presign(expires)
+	case gohttp.MethodPut:
+		req, _ := c.cli.PutObjectRequest(&s3.PutObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+		return req.Presign(expires)
+	default:
+		return "", fmt.Errorf("method (%s) not supported", method)
+	}
+}
+
+// Stat returns the information of object, returns ErrObjectNotFound if not exists
+func (c *S3Client) Stat(ctx context.Context, bucket, key string) (*Info, error) {
+	out, err := c.cli.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, convertError(err)
+	}
+	return &Info{
+		Key:          key,
+		Size:         aws.Int64Value(out.ContentLength),
+		ETag:         aws.StringValue(out.ETag),
+		ContentType:  aws.StringValue(out.ContentType),
+		LastModified: aws.TimeValue(out.LastModified),
+	}, nil
+}
+
+// Delete deletes the object
+func (c *S3Client) Delete(ctx context.Context, bucket, key string) error {
+	_, err := c.cli.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	return convertError(err)
+}
+
+func convertError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if e, ok := err.(awserr.RequestFailure); ok && e.StatusCode() == gohttp.StatusNotFound {
+		switch e.Code() {
+		case s3.ErrCodeNoSuchKey, "NotFound":
+			return ErrObjectNotFound
+		}
+	}
+	return err
+}
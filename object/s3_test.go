@@ -0,0 +1,267 @@
+package object
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	gohttp "net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+var _ Client = &S3Client{}
+
+type fakeUpload struct {
+	key   string
+	parts map[int][]byte
+}
+
+// fakeS3 the minimal s3 server in path style for test
+type fakeS3 struct {
+	objects map[string][]byte
+	uploads map[string]*fakeUpload
+	puts    []string
+	ranges  []string
+	mu      sync.Mutex
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{objects: map[string][]byte{}, uploads: map[string]*fakeUpload{}}
+}
+
+func etag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func (s *fakeS3) ServeHTTP(w gohttp.ResponseWriter, r *gohttp.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := r.URL.Query()
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	body, _ := ioutil.ReadAll(r.Body)
+	writeXML := func(v interface{}) {
+		data, _ := xml.Marshal(v)
+		w.Write(data)
+	}
+	switch {
+	case r.Method == gohttp.MethodGet && !strings.Contains(path, "/"):
+		type upload struct {
+			Key       string
+			UploadId  string
+			Initiated string
+		}
+		var res struct {
+			XMLName xml.Name `xml:"ListMultipartUploadsResult"`
+			Upload  []upload
+		}
+		for id, u := range s.uploads {
+			if strings.HasPrefix(u.key, q.Get("prefix")) {
+				res.Upload = append(res.Upload, upload{Key: u.key, UploadId: id, Initiated: time.Now().UTC().Format(time.RFC3339)})
+			}
+		}
+		writeXML(&res)
+	case r.Method == gohttp.MethodPost && strings.HasPrefix(r.URL.RawQuery, "uploads"):
+		id := strconv.Itoa(len(s.uploads) + 1)
+		s.uploads[id] = &fakeUpload{key: path[strings.Index(path, "/")+1:], parts: map[int][]byte{}}
+		writeXML(&struct {
+			XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+			Key      string
+			UploadId string
+		}{Key: path, UploadId: id})
+	case r.Method == gohttp.MethodPost && q.Get("uploadId") != "":
+		u := s.uploads[q.Get("uploadId")]
+		var req struct {
+			Part []struct{ PartNumber int }
+		}
+		xml.Unmarshal(body, &req)
+		var data []byte
+		for _, p := range req.Part {
+			data = append(data, u.parts[p.PartNumber]...)
+		}
+		s.objects[path] = data
+		delete(s.uploads, q.Get("uploadId"))
+		writeXML(&struct {
+			XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+			Key     string
+			ETag    string
+		}{Key: path, ETag: etag(data)})
+	case r.Method == gohttp.MethodGet && q.Get("uploadId") != "":
+		type part struct {
+			PartNumber int
+			ETag       string
+			Size       int
+		}
+		var res struct {
+			XMLName xml.Name `xml:"ListPartsResult"`
+			Part    []part
+		}
+		u := s.uploads[q.Get("uploadId")]
+		for n, data := range u.parts {
+			res.Part = append(res.Part, part{PartNumber: n, ETag: etag(data), Size: len(data)})
+		}
+		sort.Slice(res.Part, func(i, j int) bool { return res.Part[i].PartNumber < res.Part[j].PartNumber })
+		writeXML(&res)
+	case r.Method == gohttp.MethodPut && q.Get("uploadId") != "":
+		n, _ := strconv.Atoi(q.Get("partNumber"))
+		s.uploads[q.Get("uploadId")].parts[n] = body
+		s.puts = append(s.puts, q.Get("partNumber"))
+		w.Header().Set("ETag", etag(body))
+	case r.Method == gohttp.MethodPut:
+		s.objects[path] = body
+		s.puts = append(s.puts, path)
+		w.Header().Set("ETag", etag(body))
+	case r.Method == gohttp.MethodDelete:
+		delete(s.objects, path)
+		w.WriteHeader(gohttp.StatusNoContent)
+	default:
+		data, ok := s.objects[path]
+		if !ok {
+			w.WriteHeader(gohttp.StatusNotFound)
+			if r.Method == gohttp.MethodGet {
+				writeXML(&struct {
+					XMLName xml.Name `xml:"Error"`
+					Code    string
+				}{Code: "NoSuchKey"})
+			}
+			return
+		}
+		if rg := r.Header.Get("Range"); rg != "" {
+			s.ranges = append(s.ranges, rg)
+		}
+		w.Header().Set("ETag", etag(data))
+		gohttp.ServeContent(w, r, path, time.Unix(1590000000, 0), bytes.NewReader(data))
+	}
+}
+
+func newClient(t *testing.T, url string, partSize utils.Size) *S3Client {
+	var cfg S3Config
+	assert.NoError(t, utils.SetDefaults(&cfg))
+	cfg.Endpoint = url
+	cfg.Ak = "ak"
+	cfg.Sk = "sk"
+	cfg.PathStyle = true
+	cfg.PartSize = partSize
+	cfg.Concurrency = 2
+	cli, err := NewS3Client(cfg)
+	assert.NoError(t, err)
+	return cli
+}
+
+func TestS3Client(t *testing.T) {
+	fs := newFakeS3()
+	ts := httptest.NewServer(fs)
+	defer ts.Close()
+	cli := newClient(t, ts.URL, utils.Size(s3manager.MinUploadPartSize))
+	ctx := context.Background()
+
+	_, err := cli.Stat(ctx, "b", "k")
+	assert.Equal(t, ErrObjectNotFound, err)
+	_, err = cli.Download(ctx, "b", "k")
+	assert.Equal(t, ErrObjectNotFound, err)
+
+	assert.NoError(t, cli.Upload(ctx, "b", "k", bytes.NewBufferString("hello")))
+	info, err := cli.Stat(ctx, "b", "k")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), info.Size)
+	assert.Equal(t, etag([]byte("hello")), info.ETag)
+	rc, err := cli.Download(ctx, "b", "k")
+	assert.NoError(t, err)
+	data, err := ioutil.ReadAll(rc)
+	rc.Close()
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+
+	// uploaded in parts
+	large := bytes.Repeat([]byte("0123456789"), int(s3manager.MinUploadPartSize/10+1))
+	assert.NoError(t, cli.Upload(ctx, "b", "large", bytes.NewReader(large)))
+	assert.Equal(t, large, fs.objects["b/large"])
+
+	assert.NoError(t, cli.Delete(ctx, "b", "k"))
+	_, err = cli.Stat(ctx, "b", "k")
+	assert.Equal(t, ErrObjectNotFound, err)
+
+	u, err := cli.Presign("get", "b", "k", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(u, ts.URL+"/b/k?"))
+	assert.Contains(t, u, "X-Amz-Expires=60")
+	_, err = cli.Presign("DELETE", "b", "k", time.Minute)
+	assert.EqualError(t, err, "method (DELETE) not supported")
+}
+
+func TestS3ClientResume(t *testing.T) {
+	fs := newFakeS3()
+	ts := httptest.NewServer(fs)
+	defer ts.Close()
+	cli := newClient(t, ts.URL, 1024)
+	ctx := context.Background()
+
+	dir, err := ioutil.TempDir("", t.Name())
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	content := bytes.Repeat([]byte("0123456789"), 300)
+	file := filepath.Join(dir, "src")
+	assert.NoError(t, ioutil.WriteFile(file, content, 0644))
+
+	// the first part is uploaded, the second one is broken
+	out, err := cli.cli.CreateMultipartUpload(&s3.CreateMultipartUploadInput{Bucket: aws.String("b"), Key: aws.String("f")})
+	assert.NoError(t, err)
+	for n, data := range [][]byte{content[:1024], content[:1024]} {
+		_, err = cli.cli.UploadPart(&s3.UploadPartInput{
+			Bucket:     aws.String("b"),
+			Key:        aws.String("f"),
+			UploadId:   out.UploadId,
+			PartNumber: aws.Int64(int64(n + 1)),
+			Body:       bytes.NewReader(data),
+		})
+		assert.NoError(t, err)
+	}
+	fs.puts = nil
+
+	assert.NoError(t, cli.UploadFile(ctx, "b", "f", file))
+	assert.Equal(t, content, fs.objects["b/f"])
+	sort.Strings(fs.puts)
+	assert.Equal(t, []string{"2", "3"}, fs.puts)
+	assert.Len(t, fs.uploads, 0)
+
+	// the small file is put directly
+	fs.puts = nil
+	assert.NoError(t, ioutil.WriteFile(file, content[:10], 0644))
+	assert.NoError(t, cli.UploadFile(ctx, "b", "s", file))
+	assert.Equal(t, []string{"b/s"}, fs.puts)
+
+	// the download is resumed from the temporary file
+	dst := filepath.Join(dir, "dst")
+	tmp := fmt.Sprintf("%s.%s.part", dst, strings.Trim(etag(content), `"`))
+	assert.NoError(t, ioutil.WriteFile(tmp, content[:100], 0644))
+	assert.NoError(t, cli.DownloadFile(ctx, "b", "f", dst))
+	data, err := ioutil.ReadFile(dst)
+	assert.NoError(t, err)
+	assert.Equal(t, content, data)
+	assert.Equal(t, []string{"bytes=100-"}, fs.ranges)
+	assert.False(t, utils.FileExists(tmp))
+
+	fs.ranges = nil
+	assert.NoError(t, cli.DownloadFile(ctx, "b", "s", dst))
+	data, err = ioutil.ReadFile(dst)
+	assert.NoError(t, err)
+	assert.Equal(t, content[:10], data)
+	assert.Empty(t, fs.ranges)
+}
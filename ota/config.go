@@ -0,0 +1,16 @@
+package ota
+
+import (
+	"time"
+
+	"github.com/baetyl/baetyl-go/http"
+)
+
+// Config the config of ota engine
+type Config struct {
+	Dir            string            `yaml:"dir" json:"dir" default:"var/lib/baetyl/ota"` // the directory to keep the downloaded packages
+	KeyPrefix      string            `yaml:"keyPrefix" json:"keyPrefix" default:"ota/"`   // the prefix of keys in kv store
+	ConfirmTimeout time.Duration     `yaml:"confirmTimeout" json:"confirmTimeout" default:"5m"`
+	ReportInterval time.Duration     `yaml:"reportInterval" json:"reportInterval" default:"5s"`
+	Client         http.ClientConfig `yaml:"client" json:"client"`
+}
@@ -0,0 +1,370 @@
+package ota
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	gohttp "net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/baetyl/baetyl-go/http"
+	"github.com/baetyl/baetyl-go/kv"
+	"github.com/baetyl/baetyl-go/log"
+	"github.com/baetyl/baetyl-go/utils"
+)
+
+const keyState = "state"
+
+// Engine the ota engine which drives the update task through all phases in background,
+// the state is persisted in kv store, so that the update is resumed after restarted
+type Engine struct {
+	cfg      Config
+	cli      *http.Client
+	store    kv.Driver
+	applier  Applier
+	reporter Reporter
+	state    State
+	decision Phase // the decision made during confirming
+	trigger  chan struct{}
+	ctx      context.Context
+	cancel   context.CancelFunc
+	mu       sync.RWMutex
+	log      *log.Logger
+	tomb     utils.Tomb
+}
+
+// NewEngine creates a new ota engine and resumes the update in progress if any, the reporter is optional
+func NewEngine(cfg Config, store kv.Driver, applier Applier, reporter Reporter) (*Engine, error) {
+	err := os.MkdirAll(cfg.Dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	cli, err := http.NewClient(cfg.Client)
+	if err != nil {
+		return nil, err
+	}
+	e := &Engine{
+		cfg:      cfg,
+		cli:      cli,
+		store:    store,
+		applier:  applier,
+		reporter: reporter,
+		trigger:  make(chan struct{}, 1),
+		log:      log.With(log.Any("ota", "engine")),
+	}
+	e.ctx, e.cancel = context.WithCancel(context.Background())
+	data, err := store.Get(cfg.KeyPrefix + keyState)
+	if err == nil {
+		err = json.Unmarshal(data, &e.state)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load state: %s", err.Error())
+		}
+	} else if err != kv.ErrNotFound {
+		return nil, err
+	}
+	// the process is restarted during applying, waits for confirmation
+	if e.state.Phase == PhaseApplying {
+		e.log.Info("update is interrupted during applying, waits for confirmation", log.Any("task", e.state.Task.ID))
+		e.state.Deadline = time.Now().Add(cfg.ConfirmTimeout)
+		err = e.transit(PhaseConfirming, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	e.tomb.Go(e.processing)
+	e.kick()
+	return e, nil
+}
+
+// Start starts a new update task, returns ErrBusy if another update is in progress
+func (e *Engine) Start(task *Task) error {
+	err := task.Validate()
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	if e.state.Phase.Busy() {
+		e.mu.Unlock()
+		return ErrBusy
+	}
+	e.state = State{Task: task, Total: task.Size}
+	e.decision = PhaseIdle
+	e.mu.Unlock()
+	os.Remove(e.file(task))
+
+	err = e.transit(PhaseDownloading, nil)
+	if err != nil {
+		return err
+	}
+	e.log.Info("update is started", log.Any("task", task.ID), log.Any("version", task.Version))
+	e.kick()
+	return nil
+}
+
+// Confirm confirms the update applied, which is called after the new version works well
+func (e *Engine) Confirm() error {
+	return e.decide(PhaseSucceeded)
+}
+
+// Rollback rolls back the update applied instead of waiting for the confirmation timeout
+func (e *Engine) Rollback() error {
+	return e.decide(PhaseRollingBack)
+}
+
+// State returns the current state
+func (e *Engine) State() State {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.state
+}
+
+// Close stops the engine, the update in progress is resumed after the engine is created again
+func (e *Engine) Close() error {
+	e.tomb.Kill(nil)
+	e.cancel()
+	err := e.tomb.Wait()
+	e.cli.Close()
+	return err
+}
+
+func (e *Engine) decide(next Phase) error {
+	e.mu.Lock()
+	if e.state.Phase != PhaseConfirming {
+		e.mu.Unlock()
+		return ErrNotConfirming
+	}
+	e.decision = next
+	e.mu.Unlock()
+	e.kick()
+	return nil
+}
+
+func (e *Engine) kick() {
+	select {
+	case e.trigger <- struct{}{}:
+	default:
+	}
+}
+
+func (e *Engine) processing() error {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		select {
+		case <-e.trigger:
+		case <-timer.C:
+		case <-e.tomb.Dying():
+			return nil
+		}
+		e.process()
+
+		s := e.State()
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		if s.Phase == PhaseConfirming {
+			timer.Reset(time.Until(s.Deadline))
+		} else {
+			timer.Reset(time.Hour)
+		}
+	}
+}
+
+// process advances the update until it is finished or waits for confirmation
+func (e *Engine) process() {
+	for {
+		select {
+		case <-e.tomb.Dying():
+			return
+		default:
+		}
+		s := e.State()
+		var next Phase
+		var err error
+		switch s.Phase {
+		case PhaseDownloading:
+			next, err = PhaseVerifying, e.download(s.Task)
+		case PhaseVerifying:
+			next, err = PhaseApplying, e.verify(s.Task)
+		case PhaseApplying:
+			next, err = PhaseConfirming, e.applier.Apply(s.Task, e.file(s.Task))
+			e.mu.Lock()
+			e.state.Deadline = time.Now().Add(e.cfg.ConfirmTimeout)
+			e.mu.Unlock()
+		case PhaseConfirming:
+			e.mu.Lock()
+			next, e.decision = e.decision, PhaseIdle
+			e.mu.Unlock()
+			if next == PhaseIdle {
+				if time.Now().Before(s.Deadline) {
+					return
+				}
+				e.log.Warn("update is not confirmed in time", log.Any("task", s.Task.ID))
+				next = PhaseRollingBack
+			}
+		case PhaseRollingBack:
+			next, err = PhaseRolledBack, e.applier.Rollback(s.Task)
+		default:
+			return
+		}
+		if err != nil {
+			if e.ctx.Err() != nil {
+				// the engine is closed, the phase is resumed later
+				return
+			}
+			e.log.Error("failed to update", log.Any("task", s.Task.ID), log.Any("phase", s.Phase), log.Error(err))
+			next = PhaseFailed
+			if s.Phase == PhaseApplying {
+				next = PhaseRollingBack
+			}
+		}
+		if !next.Busy() {
+			os.Remove(e.file(s.Task))
+		}
+		if terr := e.transit(next, err); terr != nil {
+			e.log.Error("failed to persist state", log.Error(terr))
+			return
+		}
+	}
+}
+
+// transit sets the phase (and the error if any), then persists and reports the state
+func (e *Engine) transit(phase Phase, err error) error {
+	e.mu.Lock()
+	e.state.Phase = phase
+	if err != nil {
+		e.state.Error = err.Error()
+	} else if phase != PhaseRolledBack && phase != PhaseFailed {
+		e.state.Error = ""
+	}
+	e.state.UpdatedAt = time.Now()
+	s := e.state
+	e.mu.Unlock()
+
+	data, err := json.Marshal(&s)
+	if err != nil {
+		return err
+	}
+	err = e.store.Set(e.cfg.KeyPrefix+keyState, data)
+	if err != nil {
+		return err
+	}
+	e.log.Info("update phase changed", log.Any("task", s.Task.ID), log.Any("phase", phase))
+	e.report(&s)
+	return nil
+}
+
+func (e *Engine) report(s *State) {
+	if e.reporter == nil {
+		return
+	}
+	if err := e.reporter.Report(s); err != nil {
+		e.log.Warn("failed to report state", log.Error(err))
+	}
+}
+
+func (e *Engine) file(task *Task) string {
+	return filepath.Join(e.cfg.Dir, task.ID+".pkg")
+}
+
+// download downloads the package, resumes from the partial file if the server supports range requests
+func (e *Engine) download(task *Task) error {
+	f, err := os.OpenFile(e.file(task), os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if task.Size > 0 && offset == task.Size {
+		return nil
+	}
+	var header map[string]string
+	if offset > 0 {
+		header = map[string]string{"Range": fmt.Sprintf("bytes=%d-", offset)}
+	}
+	res, err := e.cli.SendContext(e.ctx, gohttp.MethodGet, task.URL, nil, header)
+	if err != nil {
+		if serr, ok := err.(*http.StatusError); ok && serr.Code == gohttp.StatusRequestedRangeNotSatisfiable {
+			// the partial file is broken or already completed, verifies it
+			return nil
+		}
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != gohttp.StatusPartialContent {
+		offset = 0
+		err = f.Truncate(0)
+		if err != nil {
+			return err
+		}
+		_, err = f.Seek(0, io.SeekStart)
+		if err != nil {
+			return err
+		}
+	}
+	if res.ContentLength > 0 {
+		e.mu.Lock()
+		e.state.Total = offset + res.ContentLength
+		e.mu.Unlock()
+	}
+	e.log.Info("downloading package", log.Any("task", task.ID), log.Any("url", task.URL), log.Any("offset", offset))
+
+	w := &progressWriter{e: e, n: offset}
+	_, err = io.Copy(f, io.TeeReader(res.Body, w))
+	if err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// verify verifies the checksum of package
+func (e *Engine) verify(task *Task) error {
+	h, expected, err := parseChecksum(task.Checksum)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(e.file(task))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return err
+	}
+	if actual := h.Sum(nil); string(actual) != string(expected) {
+		return fmt.Errorf("%s: expected %x, actual %x", ErrChecksumMismatch.Error(), expected, actual)
+	}
+	return nil
+}
+
+// progressWriter counts the bytes downloaded and reports the progress periodically
+type progressWriter struct {
+	e    *Engine
+	n    int64
+	last time.Time
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	w.e.mu.Lock()
+	w.e.state.Downloaded = w.n
+	s := w.e.state
+	w.e.mu.Unlock()
+	if now := time.Now(); now.Sub(w.last) >= w.e.cfg.ReportInterval {
+		w.last = now
+		s.UpdatedAt = now
+		w.e.report(&s)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,127 @@
+// Package ota implements the over-the-air update, the package of a task goes through
+// download -> verify -> apply -> confirm, and is rolled back if failed to apply or not confirmed in time.
+package ota
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+	"time"
+)
+
+// Phase the phase of update
+type Phase string
+
+// all phases
+const (
+	PhaseIdle        Phase = ""
+	PhaseDownloading Phase = "downloading"
+	PhaseVerifying   Phase = "verifying"
+	PhaseApplying    Phase = "applying"
+	PhaseConfirming  Phase = "confirming"
+	PhaseRollingBack Phase = "rollingback"
+	PhaseSucceeded   Phase = "succeeded"
+	PhaseRolledBack  Phase = "rolledback"
+	PhaseFailed      Phase = "failed"
+)
+
+// all errors
+var (
+	ErrBusy             = errors.New("another update is in progress")
+	ErrNotConfirming    = errors.New("no update is waiting for confirmation")
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+)
+
+// Busy returns true if the update is in progress
+func (p Phase) Busy() bool {
+	switch p {
+	case PhaseDownloading, PhaseVerifying, PhaseApplying, PhaseConfirming, PhaseRollingBack:
+		return true
+	default:
+		return false
+	}
+}
+
+// Task the update task
+type Task struct {
+	ID       string            `json:"id"`
+	Version  string            `json:"version,omitempty"`
+	URL      string            `json:"url"`
+	Checksum string            `json:"checksum"` // such as sha256:<hex> or md5:<hex>, sha256 if no algorithm prefix
+	Size     int64             `json:"size,omitempty"`
+	Meta     map[string]string `json:"meta,omitempty"`
+}
+
+// State the state of update, which is persisted and reported
+type State struct {
+	Task       *Task     `json:"task,omitempty"`
+	Phase      Phase     `json:"phase"`
+	Downloaded int64     `json:"downloaded"`
+	Total      int64     `json:"total,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Deadline   time.Time `json:"deadline,omitempty"` // the deadline to confirm
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// Applier applies the package, the process may be restarted during applying,
+// then the update waits for confirmation after restarted
+type Applier interface {
+	Apply(task *Task, file string) error
+	Rollback(task *Task) error
+}
+
+// Reporter reports the state of update
+type Reporter interface {
+	Report(*State) error
+}
+
+// ReporterFunc the function to report
+type ReporterFunc func(*State) error
+
+// Report calls the function
+func (f ReporterFunc) Report(s *State) error {
+	return f(s)
+}
+
+// Validate validates the task
+func (t *Task) Validate() error {
+	if t.ID == "" {
+		return errors.New("task id is required")
+	}
+	if strings.ContainsAny(t.ID, `/\`) || t.ID == "." || t.ID == ".." {
+		return fmt.Errorf("task id (%s) is invalid", t.ID)
+	}
+	if t.URL == "" {
+		return errors.New("task url is required")
+	}
+	_, _, err := parseChecksum(t.Checksum)
+	return err
+}
+
+func parseChecksum(s string) (hash.Hash, []byte, error) {
+	algo, sum := "sha256", s
+	if i := strings.Index(s, ":"); i >= 0 {
+		algo, sum = strings.ToLower(s[:i]), s[i+1:]
+	}
+	expected, err := hex.DecodeString(sum)
+	if err != nil || len(expected) == 0 {
+		return nil, nil, fmt.Errorf("checksum (%s) is invalid", s)
+	}
+	var h hash.Hash
+	switch algo {
+	case "sha256":
+		h = sha256.New()
+	case "md5":
+		h = md5.New()
+	default:
+		return nil, nil, fmt.Errorf("checksum algorithm (%s) not supported", algo)
+	}
+	if len(expected) != h.Size() {
+		return nil, nil, fmt.Errorf("checksum (%s) is invalid", s)
+	}
+	return h, expected, nil
+}
@@ -0,0 +1,221 @@
+package ota
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	gohttp "net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/baetyl/baetyl-go/kv"
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+var content = bytes.Repeat([]byte("baetyl"), 1000)
+
+type mockApplier struct {
+	applyErr error
+	applied  []string
+	rolled   []string
+	mu       sync.Mutex
+}
+
+func (a *mockApplier) Apply(task *Task, file string) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(content, data) {
+		return errors.New("package is broken")
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.applied = append(a.applied, task.ID)
+	return a.applyErr
+}
+
+func (a *mockApplier) Rollback(task *Task) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.rolled = append(a.rolled, task.ID)
+	return nil
+}
+
+func newServer() *httptest.Server {
+	return httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		gohttp.ServeContent(w, r, "pkg", time.Time{}, bytes.NewReader(content))
+	}))
+}
+
+func newEngine(t *testing.T, dir string, store kv.Driver, a Applier, r Reporter) *Engine {
+	var cfg Config
+	assert.NoError(t, utils.SetDefaults(&cfg))
+	cfg.Dir = filepath.Join(dir, "ota")
+	e, err := NewEngine(cfg, store, a, r)
+	assert.NoError(t, err)
+	return e
+}
+
+func newStore(t *testing.T, dir string) kv.Driver {
+	var cfg kv.Config
+	assert.NoError(t, utils.SetDefaults(&cfg))
+	cfg.Path = filepath.Join(dir, "kv.db")
+	store, err := kv.New(cfg)
+	assert.NoError(t, err)
+	return store
+}
+
+func waitPhase(t *testing.T, e *Engine, phase Phase) State {
+	for i := 0; i < 500; i++ {
+		if s := e.State(); s.Phase == phase {
+			return s
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("phase (%s) not reached, current: %+v", phase, e.State())
+	return State{}
+}
+
+func checksum() string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func TestEngine(t *testing.T) {
+	dir, err := ioutil.TempDir("", t.Name())
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	ts := newServer()
+	defer ts.Close()
+	store := newStore(t, dir)
+	defer store.Close()
+
+	var phases []Phase
+	var mu sync.Mutex
+	r := ReporterFunc(func(s *State) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(phases) == 0 || phases[len(phases)-1] != s.Phase {
+			phases = append(phases, s.Phase)
+		}
+		return nil
+	})
+	a := &mockApplier{}
+	e := newEngine(t, dir, store, a, r)
+	defer e.Close()
+
+	assert.Equal(t, ErrNotConfirming, e.Confirm())
+	task := &Task{ID: "t1", Version: "v2", URL: ts.URL, Checksum: checksum()}
+	assert.NoError(t, e.Start(task))
+	assert.Equal(t, ErrBusy, e.Start(&Task{ID: "t2", URL: ts.URL, Checksum: checksum()}))
+
+	s := waitPhase(t, e, PhaseConfirming)
+	assert.Equal(t, int64(len(content)), s.Downloaded)
+	assert.Equal(t, int64(len(content)), s.Total)
+	assert.NoError(t, e.Confirm())
+	s = waitPhase(t, e, PhaseSucceeded)
+	assert.Empty(t, s.Error)
+	assert.Equal(t, []string{"t1"}, a.applied)
+	assert.Empty(t, a.rolled)
+	assert.False(t, utils.FileExists(e.file(task)))
+	mu.Lock()
+	assert.Equal(t, []Phase{PhaseDownloading, PhaseVerifying, PhaseApplying, PhaseConfirming, PhaseSucceeded}, phases)
+	mu.Unlock()
+
+	// checksum mismatch
+	task = &Task{ID: "t2", URL: ts.URL, Checksum: "md5:00000000000000000000000000000000"}
+	assert.NoError(t, e.Start(task))
+	s = waitPhase(t, e, PhaseFailed)
+	assert.Contains(t, s.Error, ErrChecksumMismatch.Error())
+	assert.Equal(t, []string{"t1"}, a.applied)
+
+	// failed to apply
+	a.applyErr = errors.New("failed")
+	task = &Task{ID: "t3", URL: ts.URL, Checksum: checksum()}
+	assert.NoError(t, e.Start(task))
+	s = waitPhase(t, e, PhaseRolledBack)
+	assert.Equal(t, "failed", s.Error)
+	assert.Equal(t, []string{"t3"}, a.rolled)
+
+	// manual rollback
+	a.applyErr = nil
+	task = &Task{ID: "t4", URL: ts.URL, Checksum: checksum()}
+	assert.NoError(t, e.Start(task))
+	waitPhase(t, e, PhaseConfirming)
+	assert.NoError(t, e.Rollback())
+	waitPhase(t, e, PhaseRolledBack)
+	assert.Equal(t, []string{"t3", "t4"}, a.rolled)
+}
+
+func TestEngineResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", t.Name())
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	ts := newServer()
+	defer ts.Close()
+	store := newStore(t, dir)
+	defer store.Close()
+
+	// the partial package is resumed
+	task := &Task{ID: "t1", URL: ts.URL, Checksum: checksum()}
+	a := &mockApplier{}
+	e := newEngine(t, dir, store, a, nil)
+	e.Close()
+	assert.NoError(t, ioutil.WriteFile(e.file(task), content[:100], 0644))
+	e.state = State{Task: task}
+	assert.NoError(t, e.transit(PhaseDownloading, nil))
+
+	e = newEngine(t, dir, store, a, nil)
+	s := waitPhase(t, e, PhaseConfirming)
+	assert.Equal(t, int64(len(content)), s.Downloaded)
+	assert.Equal(t, []string{"t1"}, a.applied)
+	e.Close()
+
+	// the process is restarted during applying, and not confirmed in time
+	e.state.Phase = PhaseApplying
+	assert.NoError(t, e.transit(PhaseApplying, nil))
+	var cfg Config
+	assert.NoError(t, utils.SetDefaults(&cfg))
+	cfg.Dir = filepath.Join(dir, "ota")
+	cfg.ConfirmTimeout = 100 * time.Millisecond
+	e, err = NewEngine(cfg, store, a, nil)
+	assert.NoError(t, err)
+	defer e.Close()
+	assert.Equal(t, PhaseConfirming, e.State().Phase)
+	waitPhase(t, e, PhaseRolledBack)
+	assert.Equal(t, []string{"t1"}, a.applied)
+	assert.Equal(t, []string{"t1"}, a.rolled)
+}
+
+func TestTaskValidate(t *testing.T) {
+	sum := checksum()
+	tests := []struct {
+		task Task
+		err  string
+	}{
+		{task: Task{ID: "t", URL: "u", Checksum: sum}},
+		{task: Task{ID: "t", URL: "u", Checksum: sum[7:]}},
+		{task: Task{URL: "u", Checksum: sum}, err: "task id is required"},
+		{task: Task{ID: "../t", URL: "u", Checksum: sum}, err: "task id (../t) is invalid"},
+		{task: Task{ID: "t", Checksum: sum}, err: "task url is required"},
+		{task: Task{ID: "t", URL: "u"}, err: "checksum () is invalid"},
+		{task: Task{ID: "t", URL: "u", Checksum: "md5:" + sum[7:]}, err: "checksum (md5:" + sum[7:] + ") is invalid"},
+		{task: Task{ID: "t", URL: "u", Checksum: "sha1:00"}, err: "checksum algorithm (sha1) not supported"},
+	}
+	for _, tt := range tests {
+		err := tt.task.Validate()
+		if tt.err == "" {
+			assert.NoError(t, err)
+		} else {
+			assert.EqualError(t, err, tt.err)
+		}
+	}
+}
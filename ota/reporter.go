@@ -0,0 +1,33 @@
+package ota
+
+import (
+	"encoding/json"
+
+	"github.com/baetyl/baetyl-go/link"
+	"github.com/baetyl/baetyl-go/mqtt"
+	v1 "github.com/baetyl/baetyl-go/spec/v1"
+)
+
+// NewMQTTReporter creates a reporter which publishes the state as an event message to the topic (qos 0)
+func NewMQTTReporter(cli *mqtt.Client, topic string) Reporter {
+	return ReporterFunc(func(s *State) error {
+		data, err := json.Marshal(v1.NewMessage(v1.MessageEvent, s))
+		if err != nil {
+			return err
+		}
+		return cli.Publish(0, topic, data, 0, false, false)
+	})
+}
+
+// NewLinkReporter creates a reporter which sends the state as an event message to the topic
+func NewLinkReporter(cli *link.Client, topic string) Reporter {
+	return ReporterFunc(func(s *State) error {
+		m := v1.NewMessage(v1.MessageEvent, s)
+		m.Metadata = map[string]string{v1.MessageMetaTopic: topic}
+		msg, err := m.ToLink()
+		if err != nil {
+			return err
+		}
+		return cli.Send(msg)
+	})
+}
@@ -0,0 +1,65 @@
+package patch
+
+import "encoding/json"
+
+// MergePatch applies the json merge patch (RFC 7386) to a copy of the document and returns it,
+// the members whose values are null in patch are removed
+func MergePatch(doc, patch interface{}) interface{} {
+	pm, ok := toMap(patch)
+	if !ok {
+		return DeepCopy(patch)
+	}
+	dm, ok := toMap(doc)
+	out := map[string]interface{}{}
+	if ok && dm != nil {
+		out = DeepCopy(dm).(map[string]interface{})
+	}
+	for _, k := range sortedKeys(pm) {
+		v := pm[k]
+		if v == nil {
+			delete(out, k)
+			continue
+		}
+		out[k] = MergePatch(out[k], v)
+	}
+	return out
+}
+
+// MergePatchJSON applies the json merge patch to the json document
+func MergePatchJSON(doc, patch []byte) ([]byte, error) {
+	var d, p interface{}
+	if err := json.Unmarshal(doc, &d); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, err
+	}
+	return json.Marshal(MergePatch(d, p))
+}
+
+// CreateMergePatch creates the json merge patch which transforms the document from into the document to,
+// the members removed are null in patch. Since null can not be set by merge patch, the members whose values
+// are null in the document to are removed by the patch.
+func CreateMergePatch(from, to interface{}) interface{} {
+	fm, fok := toMap(from)
+	tm, tok := toMap(to)
+	if !fok || !tok {
+		return DeepCopy(to)
+	}
+	out := map[string]interface{}{}
+	for _, k := range sortedKeys(fm, tm) {
+		fv, fok := fm[k]
+		tv, tok := tm[k]
+		switch {
+		case !tok || tv == nil:
+			if fok {
+				out[k] = nil
+			}
+		case !fok:
+			out[k] = DeepCopy(tv)
+		case !Equal(fv, tv):
+			out[k] = CreateMergePatch(fv, tv)
+		}
+	}
+	return out
+}
@@ -0,0 +1,48 @@
+package patch
+
+// Merge3 merges the changes of ours and theirs since the common base, such as merging the desired properties
+// (theirs) set by cloud and the reported properties (ours) changed by device since last synchronized (base).
+// The objects are merged recursively, the member changed by only one side is taken from that side,
+// and the member changed by both sides differently is a conflict, which is resolved by theirs.
+// The merged document and the json pointers of conflicts in order are returned.
+func Merge3(base, ours, theirs interface{}) (interface{}, []string) {
+	var conflicts []string
+	merged, _ := merge3("", base, ours, theirs, true, true, true, &conflicts)
+	return merged, conflicts
+}
+
+// merge3 returns the merged value and whether it exists
+func merge3(path string, base, ours, theirs interface{}, bok, ook, tok bool, conflicts *[]string) (interface{}, bool) {
+	switch {
+	case same(ours, theirs, ook, tok):
+		return DeepCopy(ours), ook
+	case same(base, ours, bok, ook):
+		return DeepCopy(theirs), tok
+	case same(base, theirs, bok, tok):
+		return DeepCopy(ours), ook
+	}
+	om, omok := toMap(ours)
+	tm, tmok := toMap(theirs)
+	if ook && tok && omok && tmok {
+		bm, _ := toMap(base)
+		out := map[string]interface{}{}
+		for _, k := range sortedKeys(bm, om, tm) {
+			bv, bok := bm[k]
+			ov, ook := om[k]
+			tv, tok := tm[k]
+			if v, ok := merge3(path+"/"+escape(k), bv, ov, tv, bok, ook, tok, conflicts); ok {
+				out[k] = v
+			}
+		}
+		return out, true
+	}
+	*conflicts = append(*conflicts, path)
+	return DeepCopy(theirs), tok
+}
+
+func same(a, b interface{}, aok, bok bool) bool {
+	if !aok || !bok {
+		return aok == bok
+	}
+	return Equal(a, b)
+}
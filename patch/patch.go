@@ -0,0 +1,316 @@
+// Package patch implements the json patch (RFC 6902), the json merge patch (RFC 7386), and the three-way merge
+// of documents, such as the reported and desired properties of twins. The documents are the values unmarshaled
+// from json or yaml, and the results are deterministic since the keys of objects are processed in order.
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// all operations
+const (
+	OpAdd     = "add"
+	OpRemove  = "remove"
+	OpReplace = "replace"
+	OpMove    = "move"
+	OpCopy    = "copy"
+	OpTest    = "test"
+)
+
+// Operation the operation of json patch
+type Operation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	From  string      `json:"from,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// MarshalJSON keeps the value of add, replace and test even if it is null
+func (o Operation) MarshalJSON() ([]byte, error) {
+	switch o.Op {
+	case OpAdd, OpReplace, OpTest:
+		return json.Marshal(struct {
+			Op    string      `json:"op"`
+			Path  string      `json:"path"`
+			Value interface{} `json:"value"`
+		}{o.Op, o.Path, o.Value})
+	default:
+		type plain Operation
+		p := plain(o)
+		p.Value = nil
+		return json.Marshal(p)
+	}
+}
+
+// Patch the json patch, which is a list of operations
+type Patch []Operation
+
+// DecodePatch decodes the json patch
+func DecodePatch(data []byte) (Patch, error) {
+	var p Patch
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Apply applies the operations in order to a copy of the document and returns it,
+// the document is not changed if any operation failed
+func (p Patch) Apply(doc interface{}) (interface{}, error) {
+	doc = DeepCopy(doc)
+	var err error
+	for i, o := range p {
+		doc, err = o.apply(doc)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s) failed: %s", i, o.Op, o.Path, err.Error())
+		}
+	}
+	return doc, nil
+}
+
+// ApplyJSON applies the operations to the json document
+func (p Patch) ApplyJSON(data []byte) ([]byte, error) {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	doc, err := p.Apply(doc)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+func (o *Operation) apply(doc interface{}) (interface{}, error) {
+	switch o.Op {
+	case OpAdd:
+		return add(doc, o.Path, DeepCopy(o.Value))
+	case OpRemove:
+		doc, _, err := remove(doc, o.Path)
+		return doc, err
+	case OpReplace:
+		doc, _, err := remove(doc, o.Path)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, o.Path, DeepCopy(o.Value))
+	case OpMove:
+		if o.From != o.Path && strings.HasPrefix(o.Path, o.From+"/") {
+			return nil, fmt.Errorf("path (%s) is a child of from (%s)", o.Path, o.From)
+		}
+		doc, v, err := remove(doc, o.From)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, o.Path, v)
+	case OpCopy:
+		v, err := Get(doc, o.From)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, o.Path, DeepCopy(v))
+	case OpTest:
+		v, err := Get(doc, o.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !Equal(v, o.Value) {
+			return nil, errors.New("value is not equal")
+		}
+		return doc, nil
+	default:
+		return nil, fmt.Errorf("operation (%s) not supported", o.Op)
+	}
+}
+
+// Get returns the value of the json pointer (RFC 6901) in the document
+func Get(doc interface{}, pointer string) (interface{}, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	v := doc
+	for _, t := range tokens {
+		v, err = child(v, t)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// add adds the value at the pointer, the root is replaced if the pointer is empty
+func add(doc interface{}, pointer string, value interface{}) (interface{}, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	return update(doc, tokens, func(parent interface{}, last string) (interface{}, error) {
+		if m, ok := toMap(parent); ok {
+			m[last] = value
+			return m, nil
+		}
+		s, ok := parent.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("parent of (%s) is not a container", pointer)
+		}
+		i := len(s)
+		if last != "-" {
+			i, err = index(last, len(s)+1)
+			if err != nil {
+				return nil, err
+			}
+		}
+		s = append(s, nil)
+		copy(s[i+1:], s[i:])
+		s[i] = value
+		return s, nil
+	})
+}
+
+// remove removes the value at the pointer and returns it
+func remove(doc interface{}, pointer string) (interface{}, interface{}, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, doc, nil
+	}
+	var removed interface{}
+	doc, err = update(doc, tokens, func(parent interface{}, last string) (interface{}, error) {
+		if m, ok := toMap(parent); ok {
+			v, ok := m[last]
+			if !ok {
+				return nil, fmt.Errorf("path (%s) not found", pointer)
+			}
+			removed = v
+			delete(m, last)
+			return m, nil
+		}
+		s, ok := parent.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("parent of (%s) is not a container", pointer)
+		}
+		i, err := index(last, len(s))
+		if err != nil {
+			return nil, err
+		}
+		removed = s[i]
+		return append(s[:i], s[i+1:]...), nil
+	})
+	return doc, removed, err
+}
+
+// update walks to the parent of the last token, and sets the parent returned by the function,
+// since the arrays may be reallocated
+func update(doc interface{}, tokens []string, f func(parent interface{}, last string) (interface{}, error)) (interface{}, error) {
+	if len(tokens) == 1 {
+		return f(doc, tokens[0])
+	}
+	c, err := child(doc, tokens[0])
+	if err != nil {
+		return nil, err
+	}
+	c, err = update(c, tokens[1:], f)
+	if err != nil {
+		return nil, err
+	}
+	if m, ok := toMap(doc); ok {
+		m[tokens[0]] = c
+		return m, nil
+	}
+	s := doc.([]interface{})
+	i, _ := index(tokens[0], len(s))
+	s[i] = c
+	return s, nil
+}
+
+func child(v interface{}, token string) (interface{}, error) {
+	if m, ok := toMap(v); ok {
+		c, ok := m[token]
+		if !ok {
+			return nil, fmt.Errorf("key (%s) not found", token)
+		}
+		return c, nil
+	}
+	if s, ok := v.([]interface{}); ok {
+		i, err := index(token, len(s))
+		if err != nil {
+			return nil, err
+		}
+		return s[i], nil
+	}
+	return nil, fmt.Errorf("value of (%s) is not a container", token)
+}
+
+// index parses the array index which must be less than the size
+func index(token string, size int) (int, error) {
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("index (%s) is invalid", token)
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("index (%s) is invalid", token)
+	}
+	if i >= size {
+		return 0, fmt.Errorf("index (%d) out of range", i)
+	}
+	return i, nil
+}
+
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("pointer (%s) must start with /", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(t, "~1", "/", -1), "~0", "~", -1)
+	}
+	return tokens, nil
+}
+
+func escape(token string) string {
+	return strings.Replace(strings.Replace(token, "~", "~0", -1), "/", "~1", -1)
+}
+
+// Create creates the json patch which transforms the document from into the document to,
+// the objects are compared recursively, and the arrays different are replaced as a whole
+func Create(from, to interface{}) Patch {
+	return create("", from, to, nil)
+}
+
+func create(path string, from, to interface{}, p Patch) Patch {
+	if Equal(from, to) {
+		return p
+	}
+	fm, fok := toMap(from)
+	tm, tok := toMap(to)
+	if !fok || !tok {
+		return append(p, Operation{Op: OpReplace, Path: path, Value: DeepCopy(to)})
+	}
+	for _, k := range sortedKeys(fm, tm) {
+		fv, fok := fm[k]
+		tv, tok := tm[k]
+		kp := path + "/" + escape(k)
+		switch {
+		case !tok:
+			p = append(p, Operation{Op: OpRemove, Path: kp})
+		case !fok:
+			p = append(p, Operation{Op: OpAdd, Path: kp, Value: DeepCopy(tv)})
+		default:
+			p = create(kp, fv, tv, p)
+		}
+	}
+	return p
+}
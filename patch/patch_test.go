@@ -0,0 +1,159 @@
+package patch
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func decode(t *testing.T, s string) interface{} {
+	var v interface{}
+	assert.NoError(t, json.Unmarshal([]byte(s), &v))
+	return v
+}
+
+func TestApply(t *testing.T) {
+	cases := []struct {
+		doc, patch, expected, err string
+	}{
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`, ""},
+		{`{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`, ""},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc"]}]`, `{"foo":["bar",["abc"]]}`, ""},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`, ""},
+		{`{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`, ""},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`, ""},
+		{`{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`, ""},
+		{`{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`, ""},
+		{`{"foo":{"bar":1}}`, `[{"op":"copy","from":"/foo","path":"/baz"}]`, `{"foo":{"bar":1},"baz":{"bar":1}}`, ""},
+		{`{"baz":"qux","foo":["a",2,"c"]}`, `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`, `{"baz":"qux","foo":["a",2,"c"]}`, ""},
+		{`{"/":1,"~":2}`, `[{"op":"replace","path":"/~1","value":3},{"op":"remove","path":"/~0"}]`, `{"/":3}`, ""},
+		{`{"foo":1}`, `[{"op":"replace","path":"","value":[1]}]`, `[1]`, ""},
+		{`{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, "", "operation 0 (test /baz) failed: value is not equal"},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`, "", "operation 0 (add /baz/bat) failed: key (baz) not found"},
+		{`{"foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, "", "operation 0 (remove /baz) failed: path (/baz) not found"},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/2","value":1}]`, "", "operation 0 (add /foo/2) failed: index (2) out of range"},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/01","value":1}]`, "", "operation 0 (add /foo/01) failed: index (01) is invalid"},
+		{`{"foo":{"bar":1}}`, `[{"op":"move","from":"/foo","path":"/foo/bar"}]`, "", "operation 0 (move /foo/bar) failed: path (/foo/bar) is a child of from (/foo)"},
+		{`{}`, `[{"op":"bad","path":"/a"}]`, "", "operation 0 (bad /a) failed: operation (bad) not supported"},
+		{`{}`, `[{"op":"add","path":"a","value":1}]`, "", "operation 0 (add a) failed: pointer (a) must start with /"},
+	}
+	for _, c := range cases {
+		p, err := DecodePatch([]byte(c.patch))
+		assert.NoError(t, err)
+		doc := decode(t, c.doc)
+		out, err := p.Apply(doc)
+		if c.err != "" {
+			assert.EqualError(t, err, c.err, c.patch)
+			continue
+		}
+		assert.NoError(t, err, c.patch)
+		assert.Equal(t, decode(t, c.expected), out, c.patch)
+		// the document is not changed
+		assert.Equal(t, decode(t, c.doc), doc, c.patch)
+	}
+
+	p := Patch{{Op: OpAdd, Path: "/a", Value: 1}}
+	out, err := p.ApplyJSON([]byte(`{"b":2}`))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"a":1,"b":2}`, string(out))
+	_, err = DecodePatch([]byte(`{`))
+	assert.Error(t, err)
+}
+
+func TestCreate(t *testing.T) {
+	from := decode(t, `{"a":1,"b":{"c":"x","d":[1,2]},"e/f":true,"g":null}`)
+	to := decode(t, `{"a":1.0,"b":{"c":"y","d":[1,3]},"h":{"i":null}}`)
+	p := Create(from, to)
+	data, err := json.Marshal(p)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"op":"replace","path":"/b/c","value":"y"},
+		{"op":"replace","path":"/b/d","value":[1,3]},
+		{"op":"remove","path":"/e~1f"},
+		{"op":"remove","path":"/g"},
+		{"op":"add","path":"/h","value":{"i":null}}
+	]`, string(data))
+	out, err := p.Apply(from)
+	assert.NoError(t, err)
+	assert.True(t, Equal(to, out))
+	assert.Empty(t, Create(to, to))
+
+	data, err = json.Marshal(Operation{Op: OpAdd, Path: "/a"})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"op":"add","path":"/a","value":null}`, string(data))
+	data, err = json.Marshal(Operation{Op: OpMove, From: "/a", Path: "/b", Value: 1})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"op":"move","from":"/a","path":"/b"}`, string(data))
+}
+
+func TestMergePatch(t *testing.T) {
+	// the examples of RFC 7386
+	cases := []struct {
+		doc, patch, expected string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, c := range cases {
+		out, err := MergePatchJSON([]byte(c.doc), []byte(c.patch))
+		assert.NoError(t, err)
+		assert.JSONEq(t, c.expected, string(out), c.patch)
+	}
+	_, err := MergePatchJSON([]byte(`{`), []byte(`{}`))
+	assert.Error(t, err)
+
+	from := decode(t, `{"a":1,"b":{"c":"x","d":2},"e":[1],"f":"g"}`)
+	to := decode(t, `{"a":1,"b":{"c":"y","d":2},"e":[2],"f":{"h":1}}`)
+	p := CreateMergePatch(from, to)
+	assert.Equal(t, decode(t, `{"b":{"c":"y"},"e":[2],"f":{"h":1}}`), p)
+	assert.Equal(t, to, MergePatch(from, p))
+	assert.Equal(t, decode(t, `{"a":null}`), CreateMergePatch(from, decode(t, `{"b":{"c":"x","d":2},"e":[1],"f":"g"}`)))
+}
+
+func TestMerge3(t *testing.T) {
+	base := decode(t, `{"a":1,"b":{"c":1,"d":1},"e":1,"f":1,"g":1}`)
+	ours := decode(t, `{"a":2,"b":{"c":2,"d":1},"e":1,"f":2,"h":1}`)
+	theirs := decode(t, `{"a":1,"b":{"c":1,"d":2},"f":3,"g":1,"i":1}`)
+	merged, conflicts := Merge3(base, ours, theirs)
+	assert.Equal(t, decode(t, `{"a":2,"b":{"c":2,"d":2},"f":3,"h":1,"i":1}`), merged)
+	assert.Equal(t, []string{"/f"}, conflicts)
+
+	// both changed the same way, or changed to object
+	merged, conflicts = Merge3(decode(t, `{"a":1}`), decode(t, `{"a":{"x":1}}`), decode(t, `{"a":{"y":1}}`))
+	assert.Equal(t, decode(t, `{"a":{"x":1,"y":1}}`), merged)
+	assert.Empty(t, conflicts)
+	merged, conflicts = Merge3(nil, decode(t, `{"a":1}`), decode(t, `{"a":1.0}`))
+	assert.Equal(t, decode(t, `{"a":1}`), merged)
+	assert.Empty(t, conflicts)
+
+	// removed by theirs while changed by ours
+	merged, conflicts = Merge3(decode(t, `{"a":1}`), decode(t, `{"a":2}`), decode(t, `{}`))
+	assert.Equal(t, decode(t, `{}`), merged)
+	assert.Equal(t, []string{"/a"}, conflicts)
+}
+
+func TestEqual(t *testing.T) {
+	assert.True(t, Equal(1, 1.0))
+	assert.True(t, Equal(map[string]interface{}{"a": []interface{}{int64(1)}}, decode(t, `{"a":[1]}`)))
+	assert.False(t, Equal(map[string]interface{}{"a": 1}, decode(t, `{"a":1,"b":2}`)))
+	assert.False(t, Equal([]interface{}{1}, []interface{}{1, 2}))
+	assert.False(t, Equal("1", 1))
+	type named map[string]interface{}
+	assert.True(t, Equal(named{"a": 1}, map[string]interface{}{"a": 1}))
+	assert.Equal(t, map[string]interface{}{"a": 1}, DeepCopy(named{"a": 1}))
+}
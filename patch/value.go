@@ -0,0 +1,136 @@
+package patch
+
+import (
+	"reflect"
+	"sort"
+)
+
+// Equal checks whether the values unmarshaled from json or yaml are equal recursively,
+// the numbers are compared by value regardless of type
+func Equal(a, b interface{}) bool {
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		return ok && fa == fb
+	}
+	am, aok := toMap(a)
+	bm, bok := toMap(b)
+	if aok || bok {
+		if !aok || !bok || len(am) != len(bm) {
+			return false
+		}
+		for k, av := range am {
+			bv, ok := bm[k]
+			if !ok || !Equal(av, bv) {
+				return false
+			}
+		}
+		return true
+	}
+	as, aok := a.([]interface{})
+	bs, bok := b.([]interface{})
+	if aok && bok {
+		if len(as) != len(bs) {
+			return false
+		}
+		for i := range as {
+			if !Equal(as[i], bs[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// DeepCopy copies the value unmarshaled from json or yaml recursively
+func DeepCopy(in interface{}) interface{} {
+	switch v := in.(type) {
+	case map[string]interface{}:
+		if v == nil {
+			return v
+		}
+		out := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			out[k] = DeepCopy(e)
+		}
+		return out
+	case []interface{}:
+		if v == nil {
+			return v
+		}
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			out[i] = DeepCopy(e)
+		}
+		return out
+	default:
+		if m, ok := toMap(in); ok {
+			return DeepCopy(m)
+		}
+		return v
+	}
+}
+
+// toMap returns the map of value if it is an object, including the named map types such as dm.Props
+func toMap(v interface{}) (map[string]interface{}, bool) {
+	if m, ok := v.(map[string]interface{}); ok {
+		return m, true
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+		return nil, false
+	}
+	if !rv.Type().ConvertibleTo(mapType) {
+		return nil, false
+	}
+	return rv.Convert(mapType).Interface().(map[string]interface{}), true
+}
+
+var mapType = reflect.TypeOf(map[string]interface{}{})
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
+
+// sortedKeys returns the keys of maps in order, so that the results are deterministic
+func sortedKeys(ms ...map[string]interface{}) []string {
+	seen := map[string]struct{}{}
+	var keys []string
+	for _, m := range ms {
+		for k := range m {
+			if _, ok := seen[k]; !ok {
+				seen[k] = struct{}{}
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,24 @@
+package pubsub
+
+import "time"
+
+// all slow consumer policies
+const (
+	// PolicyBlock the publisher is blocked until the message is received or the timeout elapses, the message is dropped after timeout
+	PolicyBlock = "block"
+	// PolicyDropNewest the new message is dropped if the buffer of subscriber is full
+	PolicyDropNewest = "drop-newest"
+	// PolicyDropOldest the oldest message in the buffer of subscriber is dropped to receive the new message
+	PolicyDropOldest = "drop-oldest"
+	// PolicyDisconnect the subscriber is closed if its buffer is full
+	PolicyDisconnect = "disconnect"
+)
+
+// Config the config of pubsub
+type Config struct {
+	BufferSize int           `yaml:"bufferSize" json:"bufferSize" default:"100" validate:"min=0"`
+	Timeout    time.Duration `yaml:"timeout" json:"timeout" default:"1s"`
+	Policy     string        `yaml:"policy" json:"policy" default:"block" validate:"regexp=^(block|drop-newest|drop-oldest|disconnect)$"`
+	Durable    []string      `yaml:"durable" json:"durable"`                   // the durable topics, only available if the pubsub is backed by kv store
+	Retention  time.Duration `yaml:"retention" json:"retention" default:"24h"` // the retention of messages of durable topics
+}
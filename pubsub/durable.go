@@ -0,0 +1,194 @@
+package pubsub
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/baetyl/baetyl-go/kv"
+	"github.com/baetyl/baetyl-go/log"
+)
+
+// all key prefixes of durable topics in kv store
+const (
+	prefixMessage = "pubsub/msg/"
+	prefixSeq     = "pubsub/seq/"
+	prefixOffset  = "pubsub/offset/"
+)
+
+// ErrMessageNotBytes the message published to durable topic is not bytes
+var ErrMessageNotBytes = errors.New("message of durable topic must be bytes")
+
+// Message the message delivered to durable subscribers, which should be acknowledged after processed
+type Message struct {
+	Topic   string
+	Seq     uint64
+	Payload []byte
+}
+
+// NewDurablePubsub creates a new pubsub whose durable topics are backed by the kv store,
+// the messages of durable topics are kept in store until the retention elapses
+func NewDurablePubsub(cfg Config, store kv.Driver) (*Pubsub, error) {
+	p, err := NewPubsub(cfg)
+	if err != nil {
+		return nil, err
+	}
+	p.store = store
+	p.durable = map[string]*sync.Mutex{}
+	for _, t := range cfg.Durable {
+		p.durable[t] = &sync.Mutex{}
+	}
+	return p, nil
+}
+
+// IsDurable checks whether the topic is durable
+func (p *Pubsub) IsDurable(topic string) bool {
+	_, ok := p.durable[topic]
+	return ok
+}
+
+// SubscribeDurable subscribes the durable topic by name, the subscriber receives *Message,
+// and the messages not acknowledged are redelivered after the subscriber with the same name subscribes again
+func (p *Pubsub) SubscribeDurable(topic, name string) (*Subscriber, error) {
+	if !p.IsDurable(topic) {
+		return nil, fmt.Errorf("topic (%s) is not durable", topic)
+	}
+	offset, err := p.loadUint(offsetKey(topic, name))
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, ErrClosed
+	}
+	s := &Subscriber{
+		topic:  topic,
+		name:   name,
+		ps:     p,
+		ch:     make(chan interface{}, p.cfg.BufferSize),
+		done:   make(chan struct{}),
+		notify: make(chan struct{}, 1),
+		acked:  offset,
+	}
+	subs, ok := p.durableSubs[topic]
+	if !ok {
+		subs = map[*Subscriber]struct{}{}
+		p.durableSubs[topic] = subs
+	}
+	subs[s] = struct{}{}
+	go s.pumping(offset)
+	return s, nil
+}
+
+// Ack acknowledges the message of durable topic and all messages before it
+func (s *Subscriber) Ack(seq uint64) error {
+	if s.notify == nil {
+		return nil
+	}
+	s.ackMu.Lock()
+	defer s.ackMu.Unlock()
+	if seq <= s.acked {
+		return nil
+	}
+	err := s.ps.store.Set(offsetKey(s.topic, s.name), []byte(strconv.FormatUint(seq, 10)))
+	if err != nil {
+		return err
+	}
+	s.acked = seq
+	return nil
+}
+
+func (p *Pubsub) publishDurable(topic string, msg interface{}) error {
+	payload, ok := msg.([]byte)
+	if !ok {
+		return ErrMessageNotBytes
+	}
+	mu := p.durable[topic]
+	mu.Lock()
+	defer mu.Unlock()
+	seq, err := p.loadUint(prefixSeq + topic)
+	if err != nil {
+		return err
+	}
+	seq++
+	err = p.store.SetWithTTL(messageKey(topic, seq), payload, p.cfg.Retention)
+	if err != nil {
+		return err
+	}
+	err = p.store.Set(prefixSeq+topic, []byte(strconv.FormatUint(seq, 10)))
+	if err != nil {
+		return err
+	}
+	p.mu.RLock()
+	for s := range p.durableSubs[topic] {
+		select {
+		case s.notify <- struct{}{}:
+		default:
+		}
+	}
+	p.mu.RUnlock()
+	return nil
+}
+
+func (p *Pubsub) loadUint(key string) (uint64, error) {
+	v, err := p.store.Get(key)
+	if err == kv.ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(string(v), 10, 64)
+}
+
+// pumping delivers the messages stored after the sequence
+func (s *Subscriber) pumping(seq uint64) {
+	prefix := prefixMessage + s.topic + "/"
+	for {
+		kvs, err := s.ps.store.List(prefix)
+		if err != nil {
+			s.ps.log.Error("failed to list messages of durable topic", log.Any("topic", s.topic), log.Error(err))
+		}
+		for _, item := range kvs {
+			n, err := strconv.ParseUint(strings.TrimPrefix(item.Key, prefix), 10, 64)
+			if err != nil || n <= seq {
+				continue
+			}
+			if !s.send(&Message{Topic: s.topic, Seq: n, Payload: item.Value}) {
+				return
+			}
+			seq = n
+		}
+		select {
+		case <-s.notify:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// send blocks until the message is received, returns false if the subscriber is closed
+func (s *Subscriber) send(msg *Message) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	select {
+	case s.ch <- msg:
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+func messageKey(topic string, seq uint64) string {
+	return fmt.Sprintf("%s%s/%020d", prefixMessage, topic, seq)
+}
+
+func offsetKey(topic, name string) string {
+	return prefixOffset + topic + "/" + name
+}
@@ -0,0 +1,91 @@
+package pubsub
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/baetyl/baetyl-go/kv"
+	"github.com/creasty/defaults"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDurablePubsub(t *testing.T) {
+	dir, err := ioutil.TempDir("", t.Name())
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var kc kv.Config
+	assert.NoError(t, defaults.Set(&kc))
+	kc.Path = filepath.Join(dir, "kv.db")
+	store, err := kv.New(kc)
+	assert.NoError(t, err)
+	defer store.Close()
+
+	var cfg Config
+	assert.NoError(t, defaults.Set(&cfg))
+	cfg.Durable = []string{"events"}
+	p, err := NewDurablePubsub(cfg, store)
+	assert.NoError(t, err)
+	assert.True(t, p.IsDurable("events"))
+	assert.False(t, p.IsDurable("other"))
+
+	_, err = p.SubscribeDurable("other", "s1")
+	assert.EqualError(t, err, "topic (other) is not durable")
+	assert.Equal(t, ErrMessageNotBytes, p.Publish("events", 1))
+
+	// published before subscribed
+	assert.NoError(t, p.Publish("events", []byte("1")))
+	normal, err := p.Subscribe("events")
+	assert.NoError(t, err)
+	s, err := p.SubscribeDurable("events", "s1")
+	assert.NoError(t, err)
+	assert.NoError(t, p.Publish("events", []byte("2")))
+	assert.NoError(t, p.Publish("events", []byte("3")))
+
+	assertMessage(t, s, &Message{Topic: "events", Seq: 1, Payload: []byte("1")})
+	assertMessage(t, s, &Message{Topic: "events", Seq: 2, Payload: []byte("2")})
+	assert.NoError(t, s.Ack(2))
+	assertMessage(t, s, &Message{Topic: "events", Seq: 3, Payload: []byte("3")})
+	assert.Equal(t, []byte("2"), <-normal.Channel())
+	assert.Equal(t, []byte("3"), <-normal.Channel())
+
+	// the subscriber restarts, the message not acknowledged is redelivered
+	s.Close()
+	_, ok := <-s.Channel()
+	assert.False(t, ok)
+	assert.NoError(t, p.Publish("events", []byte("4")))
+	p.Close()
+
+	p, err = NewDurablePubsub(cfg, store)
+	assert.NoError(t, err)
+	defer p.Close()
+	s, err = p.SubscribeDurable("events", "s1")
+	assert.NoError(t, err)
+	assertMessage(t, s, &Message{Topic: "events", Seq: 3, Payload: []byte("3")})
+	assertMessage(t, s, &Message{Topic: "events", Seq: 4, Payload: []byte("4")})
+	assert.NoError(t, s.Ack(4))
+	assert.NoError(t, s.Ack(3))
+	assert.NoError(t, p.Publish("events", []byte("5")))
+	assertMessage(t, s, &Message{Topic: "events", Seq: 5, Payload: []byte("5")})
+
+	s2, err := p.SubscribeDurable("events", "s2")
+	assert.NoError(t, err)
+	assertMessage(t, s2, &Message{Topic: "events", Seq: 1, Payload: []byte("1")})
+	s2.Close()
+
+	v, err := store.Get(offsetKey("events", "s1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "4", string(v))
+}
+
+func assertMessage(t *testing.T, s *Subscriber, expected *Message) {
+	select {
+	case msg := <-s.Channel():
+		assert.Equal(t, expected, msg)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout, expect message (%d)", expected.Seq)
+	}
+}
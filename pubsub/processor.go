@@ -0,0 +1,68 @@
+package pubsub
+
+import (
+	"github.com/baetyl/baetyl-go/link"
+	"github.com/baetyl/baetyl-go/log"
+	"github.com/baetyl/baetyl-go/mqtt"
+)
+
+// TopicError the topic which the errors of clients are published to by the processor
+const TopicError = "$error"
+
+// Processor bridges the mqtt or link client into the pubsub, it is the observer of both clients, the publish packets
+// and the messages received are published to the topics of theirs, so that the goroutines of module subscribe the
+// topics instead of fanning out by themselves. The publish packet of mqtt is *mqtt.Publish (*mqtt.Publish5 if mqtt 5.0),
+// the message of link is *link.Message, and the errors are published to TopicError.
+type Processor struct {
+	ps  *Pubsub
+	log *log.Logger
+}
+
+// NewProcessor creates a new processor of the pubsub
+func NewProcessor(ps *Pubsub) *Processor {
+	return &Processor{
+		ps:  ps,
+		log: log.With(log.Any("pubsub", "processor")),
+	}
+}
+
+// OnPublish publishes the publish packet of mqtt to its topic, the error (such as ErrTimeout of slow subscriber) is returned to the client
+func (p *Processor) OnPublish(pkt *mqtt.Publish) error {
+	return p.ps.Publish(pkt.Message.Topic, pkt)
+}
+
+// OnPublish5 publishes the publish packet of mqtt 5.0 to its topic, the properties are kept
+func (p *Processor) OnPublish5(pkt *mqtt.Publish5) error {
+	return p.ps.Publish(pkt.Message.Topic, pkt)
+}
+
+// OnPuback ignores the puback packet
+func (p *Processor) OnPuback(*mqtt.Puback) error {
+	return nil
+}
+
+// OnError publishes the error of mqtt client
+func (p *Processor) OnError(err error) {
+	p.publishError(err)
+}
+
+// OnMsg publishes the message of link to its topic
+func (p *Processor) OnMsg(msg *link.Message) error {
+	return p.ps.Publish(msg.Context.Topic, msg)
+}
+
+// OnAck ignores the ack of link
+func (p *Processor) OnAck(*link.Message) error {
+	return nil
+}
+
+// OnErr publishes the error of link client
+func (p *Processor) OnErr(err error) {
+	p.publishError(err)
+}
+
+func (p *Processor) publishError(err error) {
+	if e := p.ps.Publish(TopicError, err); e != nil {
+		p.log.Warn("failed to publish error", log.Any("error", err.Error()), log.Error(e))
+	}
+}
@@ -0,0 +1,52 @@
+package pubsub
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/baetyl/baetyl-go/link"
+	"github.com/baetyl/baetyl-go/mqtt"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	_ mqtt.Observer5 = &Processor{}
+	_ link.Observer  = &Processor{}
+)
+
+func TestProcessor(t *testing.T) {
+	p := newPubsub(t, 1, PolicyBlock)
+	defer p.Close()
+	pr := NewProcessor(p)
+
+	s1, err := p.Subscribe("a")
+	assert.NoError(t, err)
+	s2, err := p.Subscribe("b")
+	assert.NoError(t, err)
+	se, err := p.Subscribe(TopicError)
+	assert.NoError(t, err)
+
+	pkt := mqtt.NewPublish()
+	pkt.Message.Topic = "a"
+	assert.NoError(t, pr.OnPublish(pkt))
+	assert.Equal(t, pkt, <-s1.Channel())
+	pkt5 := &mqtt.Publish5{Publish: pkt}
+	assert.NoError(t, pr.OnPublish5(pkt5))
+	assert.Equal(t, pkt5, <-s1.Channel())
+	assert.NoError(t, pr.OnPuback(mqtt.NewPuback()))
+
+	msg := &link.Message{}
+	msg.Context.Topic = "b"
+	assert.NoError(t, pr.OnMsg(msg))
+	assert.Equal(t, msg, <-s2.Channel())
+	assert.NoError(t, pr.OnAck(msg))
+
+	pr.OnError(errors.New("mqtt"))
+	assert.EqualError(t, (<-se.Channel()).(error), "mqtt")
+	pr.OnErr(errors.New("link"))
+	assert.EqualError(t, (<-se.Channel()).(error), "link")
+
+	// the slow subscriber blocks the client
+	assert.NoError(t, pr.OnMsg(msg))
+	assert.Equal(t, ErrTimeout, pr.OnMsg(msg))
+}
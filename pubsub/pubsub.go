@@ -0,0 +1,232 @@
+package pubsub
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/baetyl/baetyl-go/kv"
+	"github.com/baetyl/baetyl-go/log"
+)
+
+// all errors of pubsub
+var (
+	ErrClosed  = errors.New("pubsub is closed")
+	ErrTimeout = errors.New("timed out to deliver message to slow subscriber")
+)
+
+// Pubsub the in-process broker to publish messages to the subscribers of topics
+type Pubsub struct {
+	cfg         Config
+	subs        map[string]map[*Subscriber]struct{}
+	store       kv.Driver
+	durable     map[string]*sync.Mutex
+	durableSubs map[string]map[*Subscriber]struct{}
+	closed      bool
+	mu          sync.RWMutex
+	log         *log.Logger
+}
+
+// NewPubsub creates a new pubsub, all topics are not durable
+func NewPubsub(cfg Config) (*Pubsub, error) {
+	switch cfg.Policy {
+	case "", PolicyBlock, PolicyDropNewest, PolicyDropOldest, PolicyDisconnect:
+	default:
+		return nil, fmt.Errorf("policy (%s) not supported", cfg.Policy)
+	}
+	return &Pubsub{
+		cfg:         cfg,
+		subs:        map[string]map[*Subscriber]struct{}{},
+		durableSubs: map[string]map[*Subscriber]struct{}{},
+		log:         log.With(log.Any("pubsub", "broker")),
+	}, nil
+}
+
+// Subscribe subscribes the topic, the subscriber receives the messages published after subscribed
+func (p *Pubsub) Subscribe(topic string) (*Subscriber, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, ErrClosed
+	}
+	s := &Subscriber{
+		topic: topic,
+		ps:    p,
+		ch:    make(chan interface{}, p.cfg.BufferSize),
+		done:  make(chan struct{}),
+	}
+	subs, ok := p.subs[topic]
+	if !ok {
+		subs = map[*Subscriber]struct{}{}
+		p.subs[topic] = subs
+	}
+	subs[s] = struct{}{}
+	return s, nil
+}
+
+// Publish publishes the message to all subscribers of the topic,
+// returns ErrTimeout if any subscriber failed to receive the message in time with the block policy.
+// The message of durable topic must be bytes, and is stored before delivered.
+func (p *Pubsub) Publish(topic string, msg interface{}) error {
+	p.mu.RLock()
+	closed := p.closed
+	p.mu.RUnlock()
+	if closed {
+		return ErrClosed
+	}
+	if p.IsDurable(topic) {
+		err := p.publishDurable(topic, msg)
+		if err != nil {
+			return err
+		}
+	}
+	p.mu.RLock()
+	if p.closed {
+		p.mu.RUnlock()
+		return ErrClosed
+	}
+	subs := make([]*Subscriber, 0, len(p.subs[topic]))
+	for s := range p.subs[topic] {
+		subs = append(subs, s)
+	}
+	p.mu.RUnlock()
+
+	var err error
+	for _, s := range subs {
+		if s.deliver(msg) {
+			continue
+		}
+		p.log.Warn("message is dropped by slow subscriber", log.Any("topic", topic), log.Any("policy", p.cfg.Policy))
+		if p.cfg.Policy == PolicyBlock || p.cfg.Policy == "" {
+			err = ErrTimeout
+		}
+	}
+	return err
+}
+
+// Close closes pubsub and all subscribers
+func (p *Pubsub) Close() {
+	p.mu.Lock()
+	p.closed = true
+	all := []map[string]map[*Subscriber]struct{}{p.subs, p.durableSubs}
+	p.subs = map[string]map[*Subscriber]struct{}{}
+	p.durableSubs = map[string]map[*Subscriber]struct{}{}
+	p.mu.Unlock()
+
+	for _, subs := range all {
+		for _, ss := range subs {
+			for s := range ss {
+				s.close()
+			}
+		}
+	}
+}
+
+func (p *Pubsub) unsubscribe(s *Subscriber) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	all := p.subs
+	if s.notify != nil {
+		all = p.durableSubs
+	}
+	subs, ok := all[s.topic]
+	if !ok {
+		return
+	}
+	delete(subs, s)
+	if len(subs) == 0 {
+		delete(all, s.topic)
+	}
+}
+
+// Subscriber the subscriber of a topic
+type Subscriber struct {
+	topic  string
+	ps     *Pubsub
+	ch     chan interface{}
+	done   chan struct{}
+	once   sync.Once
+	closed bool
+	mu     sync.Mutex
+	// only for durable subscriber
+	name   string
+	notify chan struct{}
+	acked  uint64
+	ackMu  sync.Mutex
+}
+
+// Topic returns the topic subscribed
+func (s *Subscriber) Topic() string {
+	return s.topic
+}
+
+// Channel returns the channel to receive messages, the channel is closed after the subscriber is closed
+func (s *Subscriber) Channel() <-chan interface{} {
+	return s.ch
+}
+
+// Close unsubscribes the topic and closes the channel
+func (s *Subscriber) Close() {
+	s.ps.unsubscribe(s)
+	s.close()
+}
+
+func (s *Subscriber) close() {
+	s.once.Do(func() {
+		// closes done first to unblock the delivering
+		close(s.done)
+		s.mu.Lock()
+		s.closed = true
+		close(s.ch)
+		s.mu.Unlock()
+	})
+}
+
+// deliver delivers the message according to the policy, returns false if the message is dropped
+func (s *Subscriber) deliver(msg interface{}) bool {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return true
+	}
+	select {
+	case s.ch <- msg:
+		s.mu.Unlock()
+		return true
+	default:
+	}
+	switch s.ps.cfg.Policy {
+	case PolicyDropNewest:
+		s.mu.Unlock()
+		return false
+	case PolicyDropOldest:
+		defer s.mu.Unlock()
+		select {
+		case <-s.ch:
+		default:
+		}
+		select {
+		case s.ch <- msg:
+		default:
+		}
+		return false
+	case PolicyDisconnect:
+		s.mu.Unlock()
+		s.ps.log.Warn("slow subscriber is disconnected", log.Any("topic", s.topic))
+		s.Close()
+		return false
+	default:
+		defer s.mu.Unlock()
+		timer := time.NewTimer(s.ps.cfg.Timeout)
+		defer timer.Stop()
+		select {
+		case s.ch <- msg:
+			return true
+		case <-s.done:
+			return true
+		case <-timer.C:
+			return false
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package pubsub
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/creasty/defaults"
+	"github.com/stretchr/testify/assert"
+)
+
+func newPubsub(t *testing.T, size int, policy string) *Pubsub {
+	var cfg Config
+	assert.NoError(t, defaults.Set(&cfg))
+	cfg.BufferSize = size
+	cfg.Timeout = 50 * time.Millisecond
+	cfg.Policy = policy
+	p, err := NewPubsub(cfg)
+	assert.NoError(t, err)
+	return p
+}
+
+func TestPubsub(t *testing.T) {
+	p := newPubsub(t, 10, PolicyBlock)
+	s1, err := p.Subscribe("a")
+	assert.NoError(t, err)
+	assert.Equal(t, "a", s1.Topic())
+	s2, err := p.Subscribe("a")
+	assert.NoError(t, err)
+	s3, err := p.Subscribe("b")
+	assert.NoError(t, err)
+
+	assert.NoError(t, p.Publish("a", 1))
+	assert.NoError(t, p.Publish("b", "2"))
+	assert.NoError(t, p.Publish("c", 3))
+	assert.Equal(t, 1, <-s1.Channel())
+	assert.Equal(t, 1, <-s2.Channel())
+	assert.Equal(t, "2", <-s3.Channel())
+
+	s1.Close()
+	s1.Close()
+	_, ok := <-s1.Channel()
+	assert.False(t, ok)
+	assert.NoError(t, p.Publish("a", 4))
+	assert.Equal(t, 4, <-s2.Channel())
+	assert.Len(t, p.subs["a"], 1)
+
+	p.Close()
+	_, ok = <-s2.Channel()
+	assert.False(t, ok)
+	_, ok = <-s3.Channel()
+	assert.False(t, ok)
+	assert.Equal(t, ErrClosed, p.Publish("a", 5))
+	_, err = p.Subscribe("a")
+	assert.Equal(t, ErrClosed, err)
+	s2.Close()
+
+	_, err = NewPubsub(Config{Policy: "wait"})
+	assert.EqualError(t, err, "policy (wait) not supported")
+}
+
+func TestPubsubPolicy(t *testing.T) {
+	p := newPubsub(t, 1, PolicyBlock)
+	s, err := p.Subscribe("a")
+	assert.NoError(t, err)
+	assert.NoError(t, p.Publish("a", 1))
+	assert.Equal(t, ErrTimeout, p.Publish("a", 2))
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, p.Publish("a", 3))
+	}()
+	assert.Equal(t, 1, <-s.Channel())
+	assert.Equal(t, 3, <-s.Channel())
+	wg.Wait()
+	p.Close()
+
+	p = newPubsub(t, 2, PolicyDropNewest)
+	s, err = p.Subscribe("a")
+	assert.NoError(t, err)
+	for i := 1; i <= 3; i++ {
+		assert.NoError(t, p.Publish("a", i))
+	}
+	assert.Equal(t, 1, <-s.Channel())
+	assert.Equal(t, 2, <-s.Channel())
+	assert.Len(t, s.Channel(), 0)
+	p.Close()
+
+	p = newPubsub(t, 2, PolicyDropOldest)
+	s, err = p.Subscribe("a")
+	assert.NoError(t, err)
+	for i := 1; i <= 3; i++ {
+		assert.NoError(t, p.Publish("a", i))
+	}
+	assert.Equal(t, 2, <-s.Channel())
+	assert.Equal(t, 3, <-s.Channel())
+	p.Close()
+
+	p = newPubsub(t, 1, PolicyDisconnect)
+	s, err = p.Subscribe("a")
+	assert.NoError(t, err)
+	s2, err := p.Subscribe("a")
+	assert.NoError(t, err)
+	assert.NoError(t, p.Publish("a", 1))
+	<-s2.Channel()
+	assert.NoError(t, p.Publish("a", 2))
+	assert.Equal(t, 1, <-s.Channel())
+	_, ok := <-s.Channel()
+	assert.False(t, ok)
+	assert.Equal(t, 2, <-s2.Channel())
+	p.Close()
+}
+
+func TestPubsubCloseBlocked(t *testing.T) {
+	p := newPubsub(t, 0, PolicyBlock)
+	p.cfg.Timeout = time.Hour
+	_, err := p.Subscribe("a")
+	assert.NoError(t, err)
+	done := make(chan error)
+	go func() {
+		done <- p.Publish("a", 1)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	p.Close()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("publish is still blocked")
+	}
+}
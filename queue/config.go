@@ -0,0 +1,18 @@
+package queue
+
+import "github.com/baetyl/baetyl-go/utils"
+
+// all overflow policies
+const (
+	OverflowDropOldest = "drop"   // the oldest segment is dropped to make room
+	OverflowReject     = "reject" // the message pushed is rejected with ErrQueueFull
+)
+
+// Config the config of disk queue
+type Config struct {
+	Dir         string     `yaml:"dir" json:"dir" default:"var/lib/baetyl/queue"`
+	SegmentSize utils.Size `yaml:"segmentSize" json:"segmentSize" default:"16777216"`
+	MaxSize     utils.Size `yaml:"maxSize" json:"maxSize" default:"1073741824"`
+	Overflow    string     `yaml:"overflow" json:"overflow" default:"drop" validate:"regexp=^(drop|reject)$"`
+	Sync        bool       `yaml:"sync" json:"sync"` // fsyncs the segment after every push
+}
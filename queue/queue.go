@@ -0,0 +1,454 @@
+// Package queue implements a persistent FIFO on disk, the messages are appended to segment files,
+// and removed after acknowledged, which can be the offline buffer of mqtt and link clients.
+package queue
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/baetyl/baetyl-go/log"
+)
+
+// all errors
+var (
+	ErrQueueClosed = errors.New("queue is closed")
+	ErrQueueFull   = errors.New("queue is full")
+)
+
+const (
+	segmentExt = ".seg"
+	commitFile = "commit"
+	headerSize = 8 // length (4 bytes) + crc32 (4 bytes)
+)
+
+// Message the message in queue
+type Message struct {
+	Offset uint64
+	Data   []byte
+}
+
+type segment struct {
+	base  uint64 // the offset of the first message
+	count uint64
+	size  int64
+	path  string
+}
+
+// Queue the persistent FIFO, the messages popped are delivered again after reopened unless acknowledged
+type Queue struct {
+	cfg    Config
+	segs   []*segment
+	w      *os.File
+	next   uint64 // the offset of next message pushed
+	commit uint64 // the offsets before commit are all acknowledged
+	acked  map[uint64]struct{}
+	read   uint64 // the offset of next message popped
+	r      *reader
+	size   int64
+	notify chan struct{}
+	closed bool
+	mu     sync.Mutex
+	log    *log.Logger
+}
+
+// New opens (or creates) the queue in the directory, the broken tail of segments is truncated
+func New(cfg Config) (*Queue, error) {
+	err := os.MkdirAll(cfg.Dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	q := &Queue{
+		cfg:    cfg,
+		acked:  map[uint64]struct{}{},
+		notify: make(chan struct{}),
+		log:    log.With(log.Any("queue", cfg.Dir)),
+	}
+	err = q.load()
+	if err != nil {
+		q.close()
+		return nil, err
+	}
+	return q, nil
+}
+
+func (q *Queue) load() error {
+	files, err := filepath.Glob(filepath.Join(q.cfg.Dir, "*"+segmentExt))
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		base, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(f), segmentExt), 10, 64)
+		if err != nil {
+			q.log.Warn("ignore the file not segment", log.Any("file", f))
+			continue
+		}
+		q.segs = append(q.segs, &segment{base: base, path: f})
+	}
+	sort.Slice(q.segs, func(i, j int) bool { return q.segs[i].base < q.segs[j].base })
+	for _, s := range q.segs {
+		err = q.recover(s)
+		if err != nil {
+			return err
+		}
+		q.size += s.size
+	}
+	if n := len(q.segs); n > 0 {
+		q.next = q.segs[n-1].base + q.segs[n-1].count
+		q.commit = q.segs[0].base
+	}
+	data, err := ioutil.ReadFile(filepath.Join(q.cfg.Dir, commitFile))
+	if err == nil && len(data) == 8 {
+		if c := binary.BigEndian.Uint64(data); c > q.commit && c <= q.next {
+			q.commit = c
+		}
+	} else if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	q.read = q.commit
+	q.purge()
+	if len(q.segs) == 0 {
+		return q.roll()
+	}
+	last := q.segs[len(q.segs)-1]
+	q.w, err = os.OpenFile(last.path, os.O_WRONLY|os.O_APPEND, 0644)
+	return err
+}
+
+// recover counts the messages of segment and truncates the broken tail
+func (q *Queue) recover(s *segment) error {
+	f, err := os.OpenFile(s.path, os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	for {
+		data, err := readRecord(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			q.log.Warn("truncate the broken segment", log.Any("segment", s.path), log.Any("size", s.size), log.Error(err))
+			return f.Truncate(s.size)
+		}
+		s.count++
+		s.size += int64(headerSize + len(data))
+	}
+}
+
+// Push appends the message, returns its offset
+func (q *Queue) Push(data []byte) (uint64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return 0, ErrQueueClosed
+	}
+	n := int64(headerSize + len(data))
+	for q.size+n > int64(q.cfg.MaxSize) {
+		if q.cfg.Overflow == OverflowReject || len(q.segs) < 2 {
+			return 0, ErrQueueFull
+		}
+		q.drop()
+	}
+	last := q.segs[len(q.segs)-1]
+	if last.size > 0 && last.size+n > int64(q.cfg.SegmentSize) {
+		err := q.roll()
+		if err != nil {
+			return 0, err
+		}
+		last = q.segs[len(q.segs)-1]
+	}
+	buf := make([]byte, n)
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(data)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(data))
+	copy(buf[headerSize:], data)
+	_, err := q.w.Write(buf)
+	if err != nil {
+		return 0, err
+	}
+	if q.cfg.Sync {
+		err = q.w.Sync()
+		if err != nil {
+			return 0, err
+		}
+	}
+	last.count++
+	last.size += n
+	q.size += n
+	offset := q.next
+	q.next++
+	close(q.notify)
+	q.notify = make(chan struct{})
+	return offset, nil
+}
+
+// Pop returns the next message not acknowledged, blocks until a message is pushed or the context is done
+func (q *Queue) Pop(ctx context.Context) (*Message, error) {
+	for {
+		q.mu.Lock()
+		if q.closed {
+			q.mu.Unlock()
+			return nil, ErrQueueClosed
+		}
+		if q.read < q.next {
+			msg, err := q.pop()
+			if err == nil {
+				// skips the message acknowledged out of order, which is popped again after rewound
+				if _, ok := q.acked[msg.Offset]; ok {
+					q.mu.Unlock()
+					continue
+				}
+			}
+			q.mu.Unlock()
+			return msg, err
+		}
+		notify := q.notify
+		q.mu.Unlock()
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (q *Queue) pop() (*Message, error) {
+	if q.r == nil || q.r.next != q.read {
+		err := q.seek(q.read)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for q.r.next >= q.r.seg.base+q.r.seg.count {
+		// the segment is read out, moves to the next one
+		err := q.seek(q.r.seg.base + q.r.seg.count)
+		if err != nil {
+			return nil, err
+		}
+	}
+	data, err := readRecord(q.r.buf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read message (%d): %s", q.read, err.Error())
+	}
+	msg := &Message{Offset: q.r.next, Data: data}
+	q.r.next++
+	q.read = q.r.next
+	return msg, nil
+}
+
+// seek moves the reader to the offset
+func (q *Queue) seek(offset uint64) error {
+	q.closeReader()
+	var seg *segment
+	for _, s := range q.segs {
+		if offset < s.base+s.count {
+			seg = s
+			break
+		}
+	}
+	if seg == nil {
+		return fmt.Errorf("message (%d) not found", offset)
+	}
+	f, err := os.Open(seg.path)
+	if err != nil {
+		return err
+	}
+	r := &reader{f: f, buf: bufio.NewReader(f), seg: seg, next: seg.base}
+	if offset < seg.base {
+		// the messages before are dropped
+		offset = seg.base
+	}
+	for r.next < offset {
+		_, err = readRecord(r.buf)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		r.next++
+	}
+	q.r = r
+	q.read = offset
+	return nil
+}
+
+// Ack acknowledges the message, the messages are removed after all messages before are acknowledged
+func (q *Queue) Ack(offset uint64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return ErrQueueClosed
+	}
+	if offset < q.commit || offset >= q.next {
+		return nil
+	}
+	q.acked[offset] = struct{}{}
+	commit := q.commit
+	for {
+		if _, ok := q.acked[commit]; !ok {
+			break
+		}
+		delete(q.acked, commit)
+		commit++
+	}
+	if commit == q.commit {
+		return nil
+	}
+	q.commit = commit
+	q.purge()
+	return q.saveCommit()
+}
+
+// Rewind delivers the messages not acknowledged again, such as after the client reconnected
+func (q *Queue) Rewind() {
+	q.mu.Lock()
+	q.read = q.commit
+	q.mu.Unlock()
+}
+
+// Len returns the number of messages not acknowledged
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return int(q.next-q.commit) - len(q.acked)
+}
+
+// Size returns the total size of segments
+func (q *Queue) Size() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.size
+}
+
+// Close closes the queue
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return nil
+	}
+	q.closed = true
+	close(q.notify)
+	return q.close()
+}
+
+func (q *Queue) close() error {
+	q.closeReader()
+	if q.w != nil {
+		return q.w.Close()
+	}
+	return nil
+}
+
+func (q *Queue) closeReader() {
+	if q.r != nil {
+		q.r.f.Close()
+		q.r = nil
+	}
+}
+
+// roll creates a new segment to write
+func (q *Queue) roll() error {
+	if q.w != nil {
+		q.w.Close()
+	}
+	s := &segment{base: q.next, path: filepath.Join(q.cfg.Dir, fmt.Sprintf("%020d%s", q.next, segmentExt))}
+	w, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	q.w = w
+	q.segs = append(q.segs, s)
+	return nil
+}
+
+// drop removes the oldest segment even if not acknowledged
+func (q *Queue) drop() {
+	s := q.segs[0]
+	q.log.Warn("queue is full, drop the oldest segment", log.Any("segment", s.path), log.Any("messages", s.count))
+	q.remove()
+	end := s.base + s.count
+	for o := range q.acked {
+		if o < end {
+			delete(q.acked, o)
+		}
+	}
+	if q.commit < end {
+		q.commit = end
+		if err := q.saveCommit(); err != nil {
+			q.log.Error("failed to save commit", log.Error(err))
+		}
+	}
+	if q.read < end {
+		q.read = end
+	}
+}
+
+// purge removes the segments acknowledged except the one writing
+func (q *Queue) purge() {
+	for len(q.segs) > 1 && q.segs[0].base+q.segs[0].count <= q.commit {
+		q.remove()
+	}
+}
+
+func (q *Queue) remove() {
+	s := q.segs[0]
+	if q.r != nil && q.r.seg == s {
+		q.closeReader()
+	}
+	if err := os.Remove(s.path); err != nil {
+		q.log.Warn("failed to remove segment", log.Any("segment", s.path), log.Error(err))
+	}
+	q.size -= s.size
+	q.segs = q.segs[1:]
+}
+
+// saveCommit saves the commit offset atomically
+func (q *Queue) saveCommit() error {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, q.commit)
+	tmp := filepath.Join(q.cfg.Dir, commitFile+".tmp")
+	err := ioutil.WriteFile(tmp, b, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(q.cfg.Dir, commitFile))
+}
+
+type reader struct {
+	f    *os.File
+	buf  *bufio.Reader
+	seg  *segment
+	next uint64 // the offset of next message to read
+}
+
+func readRecord(r io.Reader) ([]byte, error) {
+	var h [headerSize]byte
+	_, err := io.ReadFull(r, h[:])
+	if err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, errors.New("header is truncated")
+		}
+		return nil, err
+	}
+	data := make([]byte, binary.BigEndian.Uint32(h[0:4]))
+	_, err = io.ReadFull(r, data)
+	if err != nil {
+		return nil, errors.New("data is truncated")
+	}
+	if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(h[4:8]) {
+		return nil, errors.New("checksum mismatch")
+	}
+	return data, nil
+}
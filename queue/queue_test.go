@@ -0,0 +1,194 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func newConfig(t *testing.T) Config {
+	dir, err := ioutil.TempDir("", "queue")
+	assert.NoError(t, err)
+	var cfg Config
+	assert.NoError(t, utils.SetDefaults(&cfg))
+	cfg.Dir = dir
+	return cfg
+}
+
+func pop(t *testing.T, q *Queue) *Message {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	msg, err := q.Pop(ctx)
+	assert.NoError(t, err)
+	return msg
+}
+
+func segments(t *testing.T, dir string) []string {
+	files, err := filepath.Glob(filepath.Join(dir, "*.seg"))
+	assert.NoError(t, err)
+	for i, f := range files {
+		files[i] = filepath.Base(f)
+	}
+	return files
+}
+
+func TestQueue(t *testing.T) {
+	cfg := newConfig(t)
+	defer os.RemoveAll(cfg.Dir)
+	cfg.SegmentSize = 30 // 2 messages per segment
+
+	q, err := New(cfg)
+	assert.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		o, err := q.Push([]byte(fmt.Sprintf("msg-%d", i)))
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(i), o)
+	}
+	assert.Equal(t, 5, q.Len())
+	assert.Equal(t, int64(5*13), q.Size())
+	assert.Equal(t, []string{"00000000000000000000.seg", "00000000000000000002.seg", "00000000000000000004.seg"}, segments(t, cfg.Dir))
+
+	for i := 0; i < 3; i++ {
+		msg := pop(t, q)
+		assert.Equal(t, uint64(i), msg.Offset)
+		assert.Equal(t, fmt.Sprintf("msg-%d", i), string(msg.Data))
+	}
+	// acknowledged out of order
+	assert.NoError(t, q.Ack(1))
+	assert.Equal(t, 4, q.Len())
+	assert.Len(t, segments(t, cfg.Dir), 3)
+	assert.NoError(t, q.Ack(0))
+	assert.Equal(t, 3, q.Len())
+	assert.Equal(t, []string{"00000000000000000002.seg", "00000000000000000004.seg"}, segments(t, cfg.Dir))
+
+	// redelivered after rewind
+	q.Rewind()
+	assert.Equal(t, uint64(2), pop(t, q).Offset)
+	assert.Equal(t, uint64(3), pop(t, q).Offset)
+	assert.NoError(t, q.Ack(2))
+
+	// pop blocks until pushed
+	assert.Equal(t, uint64(4), pop(t, q).Offset)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	_, err = q.Pop(ctx)
+	cancel()
+	assert.Equal(t, context.DeadlineExceeded, err)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		q.Push([]byte("msg-5"))
+	}()
+	msg := pop(t, q)
+	assert.Equal(t, uint64(5), msg.Offset)
+	assert.Equal(t, "msg-5", string(msg.Data))
+	assert.NoError(t, q.Close())
+	_, err = q.Push(nil)
+	assert.Equal(t, ErrQueueClosed, err)
+	_, err = q.Pop(context.Background())
+	assert.Equal(t, ErrQueueClosed, err)
+
+	// the messages not acknowledged are delivered again after reopened
+	q, err = New(cfg)
+	assert.NoError(t, err)
+	defer q.Close()
+	assert.Equal(t, 3, q.Len())
+	for i := 3; i < 6; i++ {
+		msg := pop(t, q)
+		assert.Equal(t, uint64(i), msg.Offset)
+		assert.Equal(t, fmt.Sprintf("msg-%d", i), string(msg.Data))
+		assert.NoError(t, q.Ack(msg.Offset))
+	}
+	o, err := q.Push([]byte("msg-6"))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(6), o)
+	assert.Equal(t, 1, q.Len())
+}
+
+func TestQueueRewindAcked(t *testing.T) {
+	cfg := newConfig(t)
+	defer os.RemoveAll(cfg.Dir)
+
+	q, err := New(cfg)
+	assert.NoError(t, err)
+	defer q.Close()
+	for i := 0; i < 3; i++ {
+		_, err = q.Push([]byte(fmt.Sprintf("msg-%d", i)))
+		assert.NoError(t, err)
+	}
+	for i := 0; i < 3; i++ {
+		pop(t, q)
+	}
+	// the message acknowledged out of order is not delivered again
+	assert.NoError(t, q.Ack(1))
+	q.Rewind()
+	assert.Equal(t, uint64(0), pop(t, q).Offset)
+	assert.Equal(t, uint64(2), pop(t, q).Offset)
+	assert.Equal(t, 2, q.Len())
+}
+
+func TestQueueRecover(t *testing.T) {
+	cfg := newConfig(t)
+	defer os.RemoveAll(cfg.Dir)
+
+	q, err := New(cfg)
+	assert.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err = q.Push([]byte(fmt.Sprintf("msg-%d", i)))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, q.Close())
+
+	// the last message is broken
+	seg := filepath.Join(cfg.Dir, "00000000000000000000.seg")
+	fi, err := os.Stat(seg)
+	assert.NoError(t, err)
+	assert.NoError(t, os.Truncate(seg, fi.Size()-2))
+
+	q, err = New(cfg)
+	assert.NoError(t, err)
+	defer q.Close()
+	assert.Equal(t, 2, q.Len())
+	assert.Equal(t, "msg-0", string(pop(t, q).Data))
+	assert.Equal(t, "msg-1", string(pop(t, q).Data))
+	o, err := q.Push([]byte("msg-2"))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), o)
+	assert.Equal(t, "msg-2", string(pop(t, q).Data))
+}
+
+func TestQueueOverflow(t *testing.T) {
+	cfg := newConfig(t)
+	defer os.RemoveAll(cfg.Dir)
+	cfg.SegmentSize = 30
+	cfg.MaxSize = 60
+
+	q, err := New(cfg)
+	assert.NoError(t, err)
+	for i := 0; i < 6; i++ {
+		_, err = q.Push([]byte(fmt.Sprintf("msg-%d", i)))
+		assert.NoError(t, err)
+	}
+	// the oldest segment is dropped
+	assert.Equal(t, 4, q.Len())
+	assert.Equal(t, uint64(2), pop(t, q).Offset)
+	assert.NoError(t, q.Close())
+
+	cfg.Overflow = OverflowReject
+	q, err = New(cfg)
+	assert.NoError(t, err)
+	defer q.Close()
+	assert.Equal(t, 4, q.Len())
+	_, err = q.Push([]byte("msg-6"))
+	assert.Equal(t, ErrQueueFull, err)
+	assert.NoError(t, q.Ack(2))
+	assert.NoError(t, q.Ack(3))
+	_, err = q.Push([]byte("msg-6"))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(4), pop(t, q).Offset)
+}
@@ -0,0 +1,17 @@
+package remote
+
+import (
+	"time"
+
+	"github.com/baetyl/baetyl-go/http"
+)
+
+// Config the config of remote configuration fetcher
+type Config struct {
+	Path      string            `yaml:"path" json:"path" default:"/v1/configs"`         // the path to fetch configuration objects, the name is appended
+	Dir       string            `yaml:"dir" json:"dir" default:"var/lib/baetyl/remote"` // the directory to cache the configurations fetched
+	Interval  time.Duration     `yaml:"interval" json:"interval" default:"1m"`          // the interval to check changes while watching
+	PublicKey string            `yaml:"publicKey" json:"publicKey"`                     // the pem file of rsa or ecdsa public key to verify the signature
+	Secret    string            `yaml:"secret" json:"secret"`                           // the secret to verify the hmac-sha256 signature
+	Client    http.ClientConfig `yaml:"client" json:"client"`
+}
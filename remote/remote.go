@@ -0,0 +1,323 @@
+// Package remote fetches the configuration objects from cloud over http, the responses are cached by ETag and
+// Last-Modified, verified by signature, and kept in local directory as fallback if the cloud is unreachable.
+package remote
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	gohttp "net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/baetyl/baetyl-go/http"
+	"github.com/baetyl/baetyl-go/log"
+	v1 "github.com/baetyl/baetyl-go/spec/v1"
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/jpillora/backoff"
+)
+
+// all header keys
+const (
+	HeaderETag            = "ETag"
+	HeaderIfNoneMatch     = "If-None-Match"
+	HeaderLastModified    = "Last-Modified"
+	HeaderIfModifiedSince = "If-Modified-Since"
+	HeaderSignature       = "X-Baetyl-Signature" // the base64 encoded signature of response body
+)
+
+// all errors
+var (
+	ErrSignatureMissing = errors.New("signature is missing")
+	ErrSignatureInvalid = errors.New("signature is invalid")
+	ErrNotCached        = errors.New("configuration is not cached")
+)
+
+// Handler handles the configuration changed, such as reloading the service
+type Handler func(cfg *v1.Configuration) error
+
+// entry the configuration cached with its validators
+type entry struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	Signature    string `json:"signature,omitempty"`
+	Body         []byte `json:"body"`
+}
+
+// Fetcher fetches the configurations from cloud
+type Fetcher struct {
+	cfg   Config
+	cli   *http.Client
+	pub   crypto.PublicKey
+	cache map[string]*entry
+	mu    sync.Mutex
+	tomb  utils.Tomb
+	log   *log.Logger
+}
+
+// NewFetcher creates a new fetcher
+func NewFetcher(cfg Config) (*Fetcher, error) {
+	f := &Fetcher{
+		cfg:   cfg,
+		cache: map[string]*entry{},
+		log:   log.With(log.Any("remote", "fetcher")),
+	}
+	if cfg.PublicKey != "" {
+		data, err := ioutil.ReadFile(cfg.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		f.pub, err = parsePublicKey(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err := os.MkdirAll(cfg.Dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	f.cli, err = http.NewClient(cfg.Client)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Fetch fetches the configuration of the name, the cached one is returned if not modified,
+// or if the cloud is unreachable (the error is logged)
+func (f *Fetcher) Fetch(ctx context.Context, name string) (*v1.Configuration, error) {
+	cfg, _, err := f.fetch(ctx, name)
+	return cfg, err
+}
+
+// Watch checks the configuration of the name periodically and calls the handler
+// once the configuration is fetched at first and every time it is changed
+func (f *Fetcher) Watch(name string, h Handler) {
+	f.tomb.Go(func() error {
+		f.watching(name, h)
+		return nil
+	})
+}
+
+// Close stops watching
+func (f *Fetcher) Close() error {
+	f.tomb.Kill(nil)
+	err := f.tomb.Wait()
+	f.cli.Close()
+	return err
+}
+
+func (f *Fetcher) watching(name string, h Handler) {
+	bf := backoff.Backoff{
+		Min:    time.Second,
+		Max:    f.cfg.Interval,
+		Factor: 1.6,
+	}
+	first := true
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+		case <-f.tomb.Dying():
+			return
+		}
+		next := f.cfg.Interval
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			select {
+			case <-f.tomb.Dying():
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		cfg, changed, err := f.fetch(ctx, name)
+		cancel()
+		if err == nil && (changed || first) {
+			err = h(cfg)
+			if err == nil {
+				first = false
+			}
+		}
+		if err != nil {
+			next = bf.Duration()
+			f.log.Error("failed to fetch configuration, retry later", log.Any("name", name), log.Any("after", next), log.Error(err))
+		} else {
+			bf.Reset()
+		}
+		timer.Reset(next)
+	}
+}
+
+// fetch returns the configuration and whether it is changed since fetched last time
+func (f *Fetcher) fetch(ctx context.Context, name string) (*v1.Configuration, bool, error) {
+	old, err := f.load(name)
+	if err != nil && err != ErrNotCached {
+		f.log.Warn("failed to load cached configuration", log.Any("name", name), log.Error(err))
+	}
+	header := map[string]string{}
+	if old != nil {
+		if old.ETag != "" {
+			header[HeaderIfNoneMatch] = old.ETag
+		}
+		if old.LastModified != "" {
+			header[HeaderIfModifiedSince] = old.LastModified
+		}
+	}
+	url := f.cfg.Path + "/" + name
+	res, err := f.cli.SendContext(ctx, gohttp.MethodGet, url, nil, header)
+	if err != nil {
+		if e, ok := err.(*http.StatusError); ok && e.Code == gohttp.StatusNotModified && old != nil {
+			cfg, err := decode(old.Body)
+			return cfg, false, err
+		}
+		if old == nil {
+			return nil, false, err
+		}
+		f.log.Warn("failed to fetch configuration, use the cached one", log.Any("name", name), log.Error(err))
+		cfg, err := decode(old.Body)
+		return cfg, false, err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, false, err
+	}
+	e := &entry{
+		ETag:         res.Header.Get(HeaderETag),
+		LastModified: res.Header.Get(HeaderLastModified),
+		Signature:    res.Header.Get(HeaderSignature),
+		Body:         body,
+	}
+	if err = f.verify(e); err != nil {
+		return nil, false, err
+	}
+	cfg, err := decode(body)
+	if err != nil {
+		return nil, false, err
+	}
+	if err = f.save(name, e); err != nil {
+		f.log.Warn("failed to cache configuration", log.Any("name", name), log.Error(err))
+	}
+	return cfg, old == nil || !bytes.Equal(old.Body, body), nil
+}
+
+// load loads the entry from memory or the local directory, the entry from directory is verified again
+func (f *Fetcher) load(name string) (*entry, error) {
+	f.mu.Lock()
+	e, ok := f.cache[name]
+	f.mu.Unlock()
+	if ok {
+		return e, nil
+	}
+	data, err := ioutil.ReadFile(f.file(name))
+	if os.IsNotExist(err) {
+		return nil, ErrNotCached
+	}
+	if err != nil {
+		return nil, err
+	}
+	e = &entry{}
+	if err = json.Unmarshal(data, e); err != nil {
+		return nil, err
+	}
+	if err = f.verify(e); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	f.cache[name] = e
+	f.mu.Unlock()
+	return e, nil
+}
+
+func (f *Fetcher) save(name string, e *entry) error {
+	f.mu.Lock()
+	f.cache[name] = e
+	f.mu.Unlock()
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	tmp := f.file(name) + ".tmp"
+	if err = ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.file(name))
+}
+
+func (f *Fetcher) file(name string) string {
+	return filepath.Join(f.cfg.Dir, name+".json")
+}
+
+// verify verifies the signature of body if the public key or the secret is configured
+func (f *Fetcher) verify(e *entry) error {
+	if f.pub == nil && f.cfg.Secret == "" {
+		return nil
+	}
+	if e.Signature == "" {
+		return ErrSignatureMissing
+	}
+	sig, err := base64.StdEncoding.DecodeString(e.Signature)
+	if err != nil {
+		return ErrSignatureInvalid
+	}
+	sum := sha256.Sum256(e.Body)
+	var ok bool
+	switch pub := f.pub.(type) {
+	case *rsa.PublicKey:
+		ok = rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], sig) == nil
+	case *ecdsa.PublicKey:
+		var es struct{ R, S *big.Int }
+		if _, err = asn1.Unmarshal(sig, &es); err == nil {
+			ok = ecdsa.Verify(pub, sum[:], es.R, es.S)
+		}
+	default:
+		mac := hmac.New(sha256.New, []byte(f.cfg.Secret))
+		mac.Write(e.Body)
+		ok = hmac.Equal(mac.Sum(nil), sig)
+	}
+	if !ok {
+		return ErrSignatureInvalid
+	}
+	return nil
+}
+
+func decode(body []byte) (*v1.Configuration, error) {
+	var cfg v1.Configuration
+	if err := json.Unmarshal(body, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func parsePublicKey(data []byte) (crypto.PublicKey, error) {
+	b, _ := pem.Decode(data)
+	if b == nil {
+		return nil, errors.New("failed to decode pem of public key")
+	}
+	pub, err := x509.ParsePKIXPublicKey(b.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	switch pub.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+		return pub, nil
+	default:
+		return nil, fmt.Errorf("public key type (%T) not supported", pub)
+	}
+}
@@ -0,0 +1,207 @@
+package remote
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	gohttp "net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "github.com/baetyl/baetyl-go/spec/v1"
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeCloud struct {
+	body   string
+	etag   string
+	sign   func([]byte) string
+	down   bool
+	hits   int
+	cached int
+	mu     sync.Mutex
+}
+
+func (c *fakeCloud) ServeHTTP(w gohttp.ResponseWriter, r *gohttp.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hits++
+	if c.down || r.URL.Path != "/v1/configs/c1" {
+		w.WriteHeader(gohttp.StatusNotFound)
+		return
+	}
+	if r.Header.Get(HeaderIfNoneMatch) == c.etag {
+		c.cached++
+		w.WriteHeader(gohttp.StatusNotModified)
+		return
+	}
+	w.Header().Set(HeaderETag, c.etag)
+	w.Header().Set(HeaderLastModified, "Mon, 02 Jan 2006 15:04:05 GMT")
+	if c.sign != nil {
+		w.Header().Set(HeaderSignature, c.sign([]byte(c.body)))
+	}
+	w.Write([]byte(c.body))
+}
+
+func (c *fakeCloud) set(body, etag string) {
+	c.mu.Lock()
+	c.body, c.etag = body, etag
+	c.mu.Unlock()
+}
+
+func newTestFetcher(t *testing.T, address, dir string, edit func(*Config)) *Fetcher {
+	var cfg Config
+	assert.NoError(t, utils.SetDefaults(&cfg))
+	cfg.Dir = dir
+	cfg.Interval = 50 * time.Millisecond
+	cfg.Client.Address = address
+	cfg.Client.MaxRetries = 0
+	if edit != nil {
+		edit(&cfg)
+	}
+	f, err := NewFetcher(cfg)
+	assert.NoError(t, err)
+	return f
+}
+
+func TestFetch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remote")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c := &fakeCloud{body: `{"name":"c1","version":"1","data":{"a":"b"}}`, etag: `"1"`}
+	ts := httptest.NewServer(c)
+	defer ts.Close()
+
+	f := newTestFetcher(t, ts.URL, dir, nil)
+	defer f.Close()
+	cfg, err := f.Fetch(context.Background(), "c1")
+	assert.NoError(t, err)
+	assert.Equal(t, &v1.Configuration{Name: "c1", Version: "1", Data: map[string]string{"a": "b"}}, cfg)
+	assert.FileExists(t, filepath.Join(dir, "c1.json"))
+
+	cfg, err = f.Fetch(context.Background(), "c1")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", cfg.Version)
+	assert.Equal(t, 1, c.cached)
+
+	_, err = f.Fetch(context.Background(), "c2")
+	assert.EqualError(t, err, "[404]")
+
+	// falls back to the configuration cached in directory
+	c.down = true
+	f2 := newTestFetcher(t, ts.URL, dir, nil)
+	defer f2.Close()
+	cfg, err = f2.Fetch(context.Background(), "c1")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", cfg.Version)
+}
+
+func TestWatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remote")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c := &fakeCloud{body: `{"name":"c1","version":"1"}`, etag: `"1"`}
+	ts := httptest.NewServer(c)
+	defer ts.Close()
+
+	f := newTestFetcher(t, ts.URL, dir, nil)
+	versions := make(chan string, 10)
+	f.Watch("c1", func(cfg *v1.Configuration) error {
+		versions <- cfg.Version
+		return nil
+	})
+	assert.Equal(t, "1", <-versions)
+	c.set(`{"name":"c1","version":"2"}`, `"2"`)
+	assert.Equal(t, "2", <-versions)
+	assert.NoError(t, f.Close())
+	select {
+	case v := <-versions:
+		t.Fatalf("unexpected version %s", v)
+	default:
+	}
+}
+
+func TestSignature(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remote")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c := &fakeCloud{body: `{"name":"c1","version":"1"}`, etag: `"1"`}
+	ts := httptest.NewServer(c)
+	defer ts.Close()
+
+	// hmac
+	c.sign = func(data []byte) string {
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write(data)
+		return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	}
+	f := newTestFetcher(t, ts.URL, filepath.Join(dir, "hmac"), func(cfg *Config) { cfg.Secret = "secret" })
+	_, err = f.Fetch(context.Background(), "c1")
+	assert.NoError(t, err)
+	f.Close()
+	f = newTestFetcher(t, ts.URL, filepath.Join(dir, "bad"), func(cfg *Config) { cfg.Secret = "other" })
+	_, err = f.Fetch(context.Background(), "c1")
+	assert.Equal(t, ErrSignatureInvalid, err)
+	f.Close()
+
+	// rsa
+	rk, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	c.sign = func(data []byte) string {
+		sum := sha256.Sum256(data)
+		sig, err := rsa.SignPKCS1v15(rand.Reader, rk, crypto.SHA256, sum[:])
+		assert.NoError(t, err)
+		return base64.StdEncoding.EncodeToString(sig)
+	}
+	pub := writePublicKey(t, dir, "rsa.pem", &rk.PublicKey)
+	f = newTestFetcher(t, ts.URL, filepath.Join(dir, "rsa"), func(cfg *Config) { cfg.PublicKey = pub })
+	_, err = f.Fetch(context.Background(), "c1")
+	assert.NoError(t, err)
+	f.Close()
+
+	// ecdsa
+	ek, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	c.sign = func(data []byte) string {
+		sum := sha256.Sum256(data)
+		sig, err := ecdsa.SignASN1(rand.Reader, ek, sum[:])
+		assert.NoError(t, err)
+		return base64.StdEncoding.EncodeToString(sig)
+	}
+	pub = writePublicKey(t, dir, "ecdsa.pem", &ek.PublicKey)
+	f = newTestFetcher(t, ts.URL, filepath.Join(dir, "ecdsa"), func(cfg *Config) { cfg.PublicKey = pub })
+	_, err = f.Fetch(context.Background(), "c1")
+	assert.NoError(t, err)
+	f.Close()
+
+	c.sign = nil
+	f = newTestFetcher(t, ts.URL, filepath.Join(dir, "missing"), func(cfg *Config) { cfg.PublicKey = pub })
+	_, err = f.Fetch(context.Background(), "c1")
+	assert.Equal(t, ErrSignatureMissing, err)
+	f.Close()
+}
+
+func writePublicKey(t *testing.T, dir, name string, pub interface{}) string {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	assert.NoError(t, err)
+	fn := filepath.Join(dir, name)
+	assert.NoError(t, ioutil.WriteFile(fn, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600))
+	return fn
+}
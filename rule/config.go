@@ -0,0 +1,45 @@
+package rule
+
+// all kinds of endpoints
+const (
+	KindMQTT = "mqtt"
+	KindLink = "link"
+	KindHTTP = "http"
+)
+
+// Config the config of rule engine
+type Config struct {
+	Rules []RuleConfig `yaml:"rules" json:"rules"`
+}
+
+// RuleConfig the config of rule, which routes the messages of source to target
+type RuleConfig struct {
+	Name      string          `yaml:"name" json:"name" validate:"nonzero"`
+	Source    SourceConfig    `yaml:"source" json:"source"`
+	Target    TargetConfig    `yaml:"target" json:"target"`
+	Transform TransformConfig `yaml:"transform" json:"transform"`
+}
+
+// SourceConfig the config of source, the topic is a filter which may contain wildcards
+type SourceConfig struct {
+	Kind  string `yaml:"kind" json:"kind" default:"mqtt" validate:"regexp=^(mqtt|link)$"`
+	Topic string `yaml:"topic" json:"topic" validate:"nonzero"`
+	QOS   uint32 `yaml:"qos" json:"qos" validate:"min=0, max=1"`
+}
+
+// TargetConfig the config of target, the topic is a template rendered with the source message,
+// such as "cloud/{{.Topic}}", the source topic is used if empty. The topic is the url (or path) of http target.
+// The message is sent with the lower qos of the source message and the target.
+type TargetConfig struct {
+	Kind    string            `yaml:"kind" json:"kind" default:"mqtt" validate:"regexp=^(mqtt|link|http)$"`
+	Topic   string            `yaml:"topic" json:"topic"`
+	QOS     uint32            `yaml:"qos" json:"qos" validate:"min=0, max=1"`
+	Method  string            `yaml:"method" json:"method" default:"POST"` // the method of http target
+	Headers map[string]string `yaml:"headers" json:"headers"`              // the headers of http target
+}
+
+// TransformConfig the config of transform, the payload is routed as is if the script is empty
+type TransformConfig struct {
+	Kind   string `yaml:"kind" json:"kind" default:"template"`
+	Script string `yaml:"script" json:"script"`
+}
@@ -0,0 +1,176 @@
+// Package rule routes the messages between mqtt topics, link and http endpoints by rules, with optional transforms.
+package rule
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/baetyl/baetyl-go/link"
+	"github.com/baetyl/baetyl-go/log"
+	"github.com/baetyl/baetyl-go/mqtt"
+)
+
+type rule struct {
+	index     int
+	cfg       RuleConfig
+	topic     *template.Template
+	transform Transform
+	sink      Sink
+}
+
+// Engine the rule engine, the messages of sources are routed by Route (or OnPublish of mqtt),
+// and every matched rule sends the message to its target
+type Engine struct {
+	rules   []*rule
+	sources map[string]*mqtt.Trie
+	log     *log.Logger
+}
+
+// NewEngine creates a new rule engine
+func NewEngine(cfg Config, eps Endpoints) (*Engine, error) {
+	e := &Engine{
+		sources: map[string]*mqtt.Trie{},
+		log:     log.With(log.Any("rule", "engine")),
+	}
+	names := map[string]struct{}{}
+	for i, rc := range cfg.Rules {
+		if _, ok := names[rc.Name]; ok {
+			return nil, fmt.Errorf("rule (%s) is duplicated", rc.Name)
+		}
+		names[rc.Name] = struct{}{}
+		r, err := newRule(rc, eps)
+		if err != nil {
+			return nil, fmt.Errorf("rule (%s) is invalid: %s", rc.Name, err.Error())
+		}
+		r.index = i
+		t, ok := e.sources[rc.Source.Kind]
+		if !ok {
+			t = mqtt.NewTrie()
+			e.sources[rc.Source.Kind] = t
+		}
+		t.Add(rc.Source.Topic, r)
+		e.rules = append(e.rules, r)
+	}
+	return e, nil
+}
+
+func newRule(cfg RuleConfig, eps Endpoints) (*rule, error) {
+	if !mqtt.CheckTopic(cfg.Source.Topic, true) {
+		return nil, fmt.Errorf("source topic (%s) is invalid", cfg.Source.Topic)
+	}
+	r := &rule{cfg: cfg}
+	if cfg.Target.Topic != "" {
+		if cfg.Target.Kind == KindMQTT && !strings.Contains(cfg.Target.Topic, "{{") && !mqtt.CheckTopic(cfg.Target.Topic, false) {
+			return nil, fmt.Errorf("target topic (%s) is invalid", cfg.Target.Topic)
+		}
+		t, err := parseTemplate("topic", cfg.Target.Topic)
+		if err != nil {
+			return nil, err
+		}
+		r.topic = t
+	}
+	if cfg.Source.Kind == cfg.Target.Kind && (cfg.Target.Topic == "" || cfg.Target.Topic == cfg.Source.Topic) {
+		return nil, fmt.Errorf("target is the same as source, which loops")
+	}
+	t, err := newTransform(cfg.Transform)
+	if err != nil {
+		return nil, err
+	}
+	r.transform = t
+	switch cfg.Target.Kind {
+	case "", KindMQTT:
+		if eps.MQTT == nil {
+			return nil, fmt.Errorf("endpoint (%s) is required", KindMQTT)
+		}
+		r.sink = NewMQTTSink(eps.MQTT)
+	case KindLink:
+		if eps.Link == nil {
+			return nil, fmt.Errorf("endpoint (%s) is required", KindLink)
+		}
+		r.sink = NewLinkSink(eps.Link)
+	case KindHTTP:
+		if eps.HTTP == nil {
+			return nil, fmt.Errorf("endpoint (%s) is required", KindHTTP)
+		}
+		if cfg.Target.Topic == "" {
+			return nil, fmt.Errorf("target topic (url) is required by http")
+		}
+		r.sink = NewHTTPSink(eps.HTTP, cfg.Target.Method, cfg.Target.Headers)
+	default:
+		return nil, fmt.Errorf("target kind (%s) not supported", cfg.Target.Kind)
+	}
+	return r, nil
+}
+
+// Subscriptions returns the subscriptions of mqtt sources, which should be subscribed by the mqtt client
+func (e *Engine) Subscriptions() []mqtt.Subscription {
+	var subs []mqtt.Subscription
+	for _, r := range e.rules {
+		if r.cfg.Source.Kind == KindMQTT {
+			subs = append(subs, mqtt.Subscription{Topic: r.cfg.Source.Topic, QOS: mqtt.QOS(r.cfg.Source.QOS)})
+		}
+	}
+	return subs
+}
+
+// OnPublish routes the message of mqtt source, the error is returned if any rule fails,
+// so that the message of qos 1 is not acknowledged and will be redelivered
+func (e *Engine) OnPublish(pkt *mqtt.Publish) error {
+	msg := &link.Message{Content: pkt.Message.Payload}
+	msg.Context.Topic = pkt.Message.Topic
+	msg.Context.QOS = uint32(pkt.Message.QOS)
+	if pkt.Message.Retain {
+		msg.Context.Type = link.MsgRtn
+	}
+	return e.Route(context.Background(), KindMQTT, msg)
+}
+
+// Route routes the message of the source kind to the targets of all matched rules in order,
+// returns the first error after all rules are applied
+func (e *Engine) Route(ctx context.Context, kind string, msg *link.Message) error {
+	t, ok := e.sources[kind]
+	if !ok {
+		return nil
+	}
+	matched := t.Match(msg.Context.Topic)
+	sort.Slice(matched, func(i, j int) bool { return matched[i].(*rule).index < matched[j].(*rule).index })
+	var first error
+	for _, v := range matched {
+		r := v.(*rule)
+		if err := r.route(ctx, msg); err != nil {
+			e.log.Warn("failed to route message", log.Any("rule", r.cfg.Name), log.Any("topic", msg.Context.Topic), log.Error(err))
+			if first == nil {
+				first = fmt.Errorf("rule (%s): %w", r.cfg.Name, err)
+			}
+		}
+	}
+	return first
+}
+
+func (r *rule) route(ctx context.Context, msg *link.Message) error {
+	out := *msg
+	if r.transform != nil {
+		res, err := r.transform(&out)
+		if err != nil || res == nil {
+			return err
+		}
+		out = *res
+	}
+	if r.topic != nil {
+		topic, err := execTemplate(r.topic, newTemplateData(msg))
+		if err != nil {
+			return err
+		}
+		out.Context.Topic = string(topic)
+		if r.cfg.Target.Kind == KindMQTT && !mqtt.CheckTopic(out.Context.Topic, false) {
+			return fmt.Errorf("target topic (%s) is invalid", out.Context.Topic)
+		}
+	}
+	if out.Context.QOS > r.cfg.Target.QOS {
+		out.Context.QOS = r.cfg.Target.QOS
+	}
+	return r.sink.Send(ctx, &out)
+}
@@ -0,0 +1,204 @@
+package rule
+
+import (
+	"context"
+	"io/ioutil"
+	gohttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/baetyl/baetyl-go/http"
+	"github.com/baetyl/baetyl-go/link"
+	"github.com/baetyl/baetyl-go/mqtt"
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+type published struct {
+	qos     mqtt.QOS
+	topic   string
+	payload string
+	retain  bool
+}
+
+type mockPublisher struct {
+	pubs []published
+	mu   sync.Mutex
+}
+
+func (p *mockPublisher) Publish(qos mqtt.QOS, topic string, payload []byte, pid mqtt.ID, retain bool, dup bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pubs = append(p.pubs, published{qos: qos, topic: topic, payload: string(payload), retain: retain})
+	return nil
+}
+
+func newPublish(topic string, qos mqtt.QOS, payload string) *mqtt.Publish {
+	pkt := mqtt.NewPublish()
+	pkt.Message.Topic = topic
+	pkt.Message.QOS = qos
+	pkt.Message.Payload = []byte(payload)
+	return pkt
+}
+
+func TestEngine(t *testing.T) {
+	type request struct {
+		method, path, contentType, token, body string
+	}
+	reqs := make(chan request, 10)
+	svr := httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		reqs <- request{r.Method, r.URL.Path, r.Header.Get("Content-Type"), r.Header.Get("Authorization"), string(body)}
+		if strings.HasSuffix(r.URL.Path, "/fail") {
+			w.WriteHeader(gohttp.StatusBadRequest)
+		}
+	}))
+	defer svr.Close()
+
+	in := `
+rules:
+- name: forward
+  source:
+    topic: sensor/+/data
+    qos: 1
+  target:
+    topic: cloud/{{level .Topic 1}}/data
+    qos: 1
+- name: downgrade
+  source:
+    topic: sensor/#
+    qos: 1
+  target:
+    topic: all/{{.Topic}}
+  transform:
+    script: '{{with .Value}}{{if gt .temp 30.0}}{"device":"{{level $.Topic 1}}","temp":{{.temp}}}{{end}}{{end}}'
+- name: upload
+  source:
+    kind: link
+    topic: upload/#
+  target:
+    kind: http
+    topic: /ingest/{{level .Topic 1}}
+    headers:
+      Authorization: Bearer token
+- name: call
+  source:
+    topic: sensor/+/event
+  target:
+    kind: link
+    topic: events
+`
+	var cfg Config
+	assert.NoError(t, utils.UnmarshalYAML([]byte(in), &cfg))
+	assert.Equal(t, KindMQTT, cfg.Rules[0].Source.Kind)
+	assert.Equal(t, "POST", cfg.Rules[2].Target.Method)
+	assert.Equal(t, "template", cfg.Rules[1].Transform.Kind)
+
+	var cc http.ClientConfig
+	assert.NoError(t, utils.SetDefaults(&cc))
+	cc.Address = svr.URL
+	cc.MaxRetries = 0
+	cli, err := http.NewClient(cc)
+	assert.NoError(t, err)
+	defer cli.Close()
+	pub := &mockPublisher{}
+	calls := make(chan *link.Message, 10)
+	eps := Endpoints{
+		MQTT: pub,
+		Link: link.CallerFunc(func(_ context.Context, msg *link.Message) (*link.Message, error) {
+			calls <- msg
+			return nil, nil
+		}),
+		HTTP: cli,
+	}
+	e, err := NewEngine(cfg, eps)
+	assert.NoError(t, err)
+	assert.Equal(t, []mqtt.Subscription{
+		{Topic: "sensor/+/data", QOS: 1},
+		{Topic: "sensor/#", QOS: 1},
+		{Topic: "sensor/+/event", QOS: 0},
+	}, e.Subscriptions())
+
+	// routed by two rules, the qos is the lower one
+	assert.NoError(t, e.OnPublish(newPublish("sensor/d1/data", 1, `{"temp":35.5}`)))
+	// filtered by transform
+	assert.NoError(t, e.OnPublish(newPublish("sensor/d2/data", 0, `{"temp":20}`)))
+	assert.Equal(t, []published{
+		{qos: 1, topic: "cloud/d1/data", payload: `{"temp":35.5}`},
+		{qos: 0, topic: "all/sensor/d1/data", payload: `{"device":"d1","temp":35.5}`},
+		{qos: 0, topic: "cloud/d2/data", payload: `{"temp":20}`},
+	}, pub.pubs)
+
+	// mqtt to link
+	assert.NoError(t, e.OnPublish(newPublish("sensor/d1/event", 0, "alarm")))
+	msg := <-calls
+	assert.Equal(t, "events", msg.Context.Topic)
+	assert.Equal(t, "alarm", string(msg.Content))
+
+	// link to http
+	msg = &link.Message{Content: []byte(`{"a":1}`)}
+	msg.Context.Topic = "upload/d1"
+	msg.Context.ContentType = "application/json"
+	assert.NoError(t, e.Route(context.Background(), KindLink, msg))
+	assert.Equal(t, request{"POST", "/ingest/d1", "application/json", "Bearer token", `{"a":1}`}, <-reqs)
+	msg.Context.Topic = "upload/fail"
+	err = e.Route(context.Background(), KindLink, msg)
+	assert.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "rule (upload): "))
+	<-reqs
+
+	// not matched
+	assert.NoError(t, e.Route(context.Background(), KindLink, &link.Message{}))
+	assert.NoError(t, e.Route(context.Background(), "unknown", &link.Message{}))
+}
+
+func TestEngineInvalid(t *testing.T) {
+	pub := &mockPublisher{}
+	newConfig := func(rc RuleConfig) Config {
+		assert.NoError(t, utils.SetDefaults(&rc))
+		return Config{Rules: []RuleConfig{rc}}
+	}
+	_, err := NewEngine(newConfig(RuleConfig{Name: "a", Source: SourceConfig{Topic: "a/#/b"}, Target: TargetConfig{Topic: "b"}}), Endpoints{MQTT: pub})
+	assert.EqualError(t, err, "rule (a) is invalid: source topic (a/#/b) is invalid")
+	_, err = NewEngine(newConfig(RuleConfig{Name: "a", Source: SourceConfig{Topic: "a"}, Target: TargetConfig{Topic: "b/+"}}), Endpoints{MQTT: pub})
+	assert.EqualError(t, err, "rule (a) is invalid: target topic (b/+) is invalid")
+	_, err = NewEngine(newConfig(RuleConfig{Name: "a", Source: SourceConfig{Topic: "a"}}), Endpoints{MQTT: pub})
+	assert.EqualError(t, err, "rule (a) is invalid: target is the same as source, which loops")
+	_, err = NewEngine(newConfig(RuleConfig{Name: "a", Source: SourceConfig{Topic: "a"}, Target: TargetConfig{Topic: "{{"}}), Endpoints{MQTT: pub})
+	assert.Error(t, err)
+	_, err = NewEngine(newConfig(RuleConfig{Name: "a", Source: SourceConfig{Topic: "a"}, Target: TargetConfig{Topic: "b"}, Transform: TransformConfig{Kind: "js", Script: "x"}}), Endpoints{MQTT: pub})
+	assert.EqualError(t, err, "rule (a) is invalid: transform (js) not supported")
+	_, err = NewEngine(newConfig(RuleConfig{Name: "a", Source: SourceConfig{Topic: "a"}, Target: TargetConfig{Kind: KindLink}}), Endpoints{MQTT: pub})
+	assert.EqualError(t, err, "rule (a) is invalid: endpoint (link) is required")
+	_, err = NewEngine(newConfig(RuleConfig{Name: "a", Source: SourceConfig{Topic: "a"}, Target: TargetConfig{Kind: KindHTTP}}), Endpoints{HTTP: &http.Client{}})
+	assert.EqualError(t, err, "rule (a) is invalid: target topic (url) is required by http")
+
+	cfg := newConfig(RuleConfig{Name: "a", Source: SourceConfig{Topic: "a"}, Target: TargetConfig{Topic: "b"}})
+	cfg.Rules = append(cfg.Rules, cfg.Rules[0])
+	_, err = NewEngine(cfg, Endpoints{MQTT: pub})
+	assert.EqualError(t, err, "rule (a) is duplicated")
+
+	// the target topic rendered is invalid
+	e, err := NewEngine(newConfig(RuleConfig{Name: "a", Source: SourceConfig{Topic: "a/#"}, Target: TargetConfig{Topic: "b/{{.Payload}}"}}), Endpoints{MQTT: pub})
+	assert.NoError(t, err)
+	assert.EqualError(t, e.OnPublish(newPublish("a/1", 0, "#")), "rule (a): target topic (b/#) is invalid")
+}
+
+func TestRegisterTransform(t *testing.T) {
+	RegisterTransform("upper", func(script string) (Transform, error) {
+		return func(msg *link.Message) (*link.Message, error) {
+			out := *msg
+			out.Content = []byte(strings.ToUpper(string(msg.Content)))
+			return &out, nil
+		}, nil
+	})
+	pub := &mockPublisher{}
+	rc := RuleConfig{Name: "a", Source: SourceConfig{Topic: "a"}, Target: TargetConfig{Topic: "b"}, Transform: TransformConfig{Kind: "upper", Script: "-"}}
+	assert.NoError(t, utils.SetDefaults(&rc))
+	e, err := NewEngine(Config{Rules: []RuleConfig{rc}}, Endpoints{MQTT: pub})
+	assert.NoError(t, err)
+	assert.NoError(t, e.OnPublish(newPublish("a", 0, "hello")))
+	assert.Equal(t, []published{{topic: "b", payload: "HELLO"}}, pub.pubs)
+}
@@ -0,0 +1,65 @@
+package rule
+
+import (
+	"context"
+
+	"github.com/baetyl/baetyl-go/http"
+	"github.com/baetyl/baetyl-go/link"
+	"github.com/baetyl/baetyl-go/mqtt"
+)
+
+// Publisher publishes the mqtt message, such as mqtt.Client
+type Publisher interface {
+	Publish(qos mqtt.QOS, topic string, payload []byte, pid mqtt.ID, retain bool, dup bool) error
+}
+
+// Endpoints the endpoints which the messages are routed to, only the endpoints used by rules are required
+type Endpoints struct {
+	MQTT Publisher
+	Link link.Caller
+	HTTP *http.Client
+}
+
+// Sink sends the message routed to the target
+type Sink interface {
+	Send(context.Context, *link.Message) error
+}
+
+// SinkFunc the function to send
+type SinkFunc func(context.Context, *link.Message) error
+
+// Send calls the function
+func (f SinkFunc) Send(ctx context.Context, msg *link.Message) error {
+	return f(ctx, msg)
+}
+
+// NewMQTTSink creates a sink which publishes the message to its topic
+func NewMQTTSink(pub Publisher) Sink {
+	return SinkFunc(func(_ context.Context, msg *link.Message) error {
+		return pub.Publish(mqtt.QOS(msg.Context.QOS), msg.Context.Topic, msg.Content, 0, msg.Retain(), false)
+	})
+}
+
+// NewLinkSink creates a sink which calls the link server (usually by a link client) with the message
+func NewLinkSink(caller link.Caller) Sink {
+	return SinkFunc(func(ctx context.Context, msg *link.Message) error {
+		_, err := caller.CallContext(ctx, msg)
+		return err
+	})
+}
+
+// NewHTTPSink creates a sink which sends the payload to the url (or path) of message topic,
+// the content type of message is sent as well if present
+func NewHTTPSink(cli *http.Client, method string, headers map[string]string) Sink {
+	return SinkFunc(func(ctx context.Context, msg *link.Message) error {
+		header := make(map[string]string, len(headers)+1)
+		if msg.Context.ContentType != "" {
+			header["Content-Type"] = msg.Context.ContentType
+		}
+		for k, v := range headers {
+			header[k] = v
+		}
+		_, err := cli.CallContext(ctx, method, msg.Context.Topic, msg.Content, header)
+		return err
+	})
+}
@@ -0,0 +1,124 @@
+package rule
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/baetyl/baetyl-go/link"
+)
+
+// Transform transforms the message, the message is dropped if nil is returned
+type Transform func(*link.Message) (*link.Message, error)
+
+// TransformFactory creates the transform by the script, such as a javascript engine
+type TransformFactory func(script string) (Transform, error)
+
+var (
+	transforms = map[string]TransformFactory{}
+	mu         sync.RWMutex
+)
+
+func init() {
+	RegisterTransform("template", NewTemplateTransform)
+}
+
+// RegisterTransform registers the factory of transform by kind, the factory registered before is replaced
+func RegisterTransform(kind string, f TransformFactory) {
+	mu.Lock()
+	transforms[kind] = f
+	mu.Unlock()
+}
+
+func newTransform(cfg TransformConfig) (Transform, error) {
+	if cfg.Script == "" {
+		return nil, nil
+	}
+	mu.RLock()
+	f, ok := transforms[cfg.Kind]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("transform (%s) not supported", cfg.Kind)
+	}
+	return f(cfg.Script)
+}
+
+// TemplateData the data to render the templates of transform and target topic
+type TemplateData struct {
+	Topic     string
+	QOS       uint32
+	Headers   map[string]string
+	Payload   string
+	Value     interface{} // the payload decoded as json, nil if not json
+	Timestamp int64       // the timestamp in milliseconds
+}
+
+func newTemplateData(msg *link.Message) *TemplateData {
+	d := &TemplateData{
+		Topic:     msg.Context.Topic,
+		QOS:       msg.Context.QOS,
+		Headers:   msg.Context.Headers,
+		Payload:   string(msg.Content),
+		Timestamp: int64(msg.Context.TS),
+	}
+	if d.Timestamp == 0 {
+		d.Timestamp = time.Now().UnixNano() / int64(time.Millisecond)
+	}
+	if json.Unmarshal(msg.Content, &d.Value) != nil {
+		d.Value = nil
+	}
+	return d
+}
+
+var templateFuncs = template.FuncMap{
+	// json encodes the value in json
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	// level returns the level of topic by index, empty if out of range
+	"level": func(topic string, i int) string {
+		ls := strings.Split(topic, "/")
+		if i < 0 || i >= len(ls) {
+			return ""
+		}
+		return ls[i]
+	},
+}
+
+func parseTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+}
+
+func execTemplate(t *template.Template, d *TemplateData) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, d); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// NewTemplateTransform creates the transform which renders the script (text/template) with TemplateData as the new payload,
+// the message is dropped if the payload rendered is blank, so the script can filter the messages by conditions
+func NewTemplateTransform(script string) (Transform, error) {
+	t, err := parseTemplate("transform", script)
+	if err != nil {
+		return nil, err
+	}
+	return func(msg *link.Message) (*link.Message, error) {
+		data, err := execTemplate(t, newTemplateData(msg))
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(data)) == 0 {
+			return nil, nil
+		}
+		out := *msg
+		out.Content = data
+		return &out, nil
+	}, nil
+}
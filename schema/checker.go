@@ -0,0 +1,152 @@
+package schema
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/baetyl/baetyl-go/log"
+	"github.com/baetyl/baetyl-go/mqtt"
+)
+
+// Violation the error of payload which violates the schema, with the action of rule matched
+type Violation struct {
+	Topic string
+	Rule  RuleConfig
+	Err   error
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("payload of topic (%s) violates schema (%s): %s", v.Topic, v.Rule.Subject, v.Err.Error())
+}
+
+// Publisher publishes the mqtt message, such as mqtt.Client
+type Publisher interface {
+	Publish(qos mqtt.QOS, topic string, payload []byte, pid mqtt.ID, retain bool, dup bool) error
+}
+
+type rule struct {
+	index int
+	cfg   RuleConfig
+}
+
+// Checker checks the payloads by the schemas of topics
+type Checker struct {
+	reg  *Registry
+	trie *mqtt.Trie
+	log  *log.Logger
+}
+
+// NewChecker creates a new checker
+func NewChecker(cfg Config) (*Checker, error) {
+	trie := mqtt.NewTrie()
+	for i, r := range cfg.Rules {
+		if !mqtt.CheckTopic(r.Topic, true) {
+			return nil, fmt.Errorf("topic (%s) is invalid", r.Topic)
+		}
+		trie.Add(r.Topic, &rule{index: i, cfg: r})
+	}
+	reg, err := NewRegistry(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Checker{
+		reg:  reg,
+		trie: trie,
+		log:  log.With(log.Any("schema", "checker")),
+	}, nil
+}
+
+// Check validates the payload by the schema of the first rule matched, returns a *Violation if the payload is invalid,
+// returns nil if valid or no rule matched
+func (c *Checker) Check(ctx context.Context, topic string, payload []byte) error {
+	var r *rule
+	for _, v := range c.trie.Match(topic) {
+		if m := v.(*rule); r == nil || m.index < r.index {
+			r = m
+		}
+	}
+	if r == nil {
+		return nil
+	}
+	val, err := c.reg.Get(ctx, r.cfg.Subject, r.cfg.Version)
+	if err != nil {
+		return err
+	}
+	if err = val.Validate(payload); err != nil {
+		return &Violation{Topic: topic, Rule: r.cfg, Err: err}
+	}
+	return nil
+}
+
+// handle applies the action of violation, returns whether the message is forwarded as is
+func (c *Checker) handle(pub Publisher, qos mqtt.QOS, payload []byte, v *Violation) (bool, error) {
+	switch v.Rule.Action {
+	case ActionPass:
+		c.log.Warn("payload is invalid, passed", log.Any("topic", v.Topic), log.Error(v.Err))
+		return true, nil
+	case ActionQuarantine:
+		if pub == nil {
+			return false, v
+		}
+		c.log.Warn("payload is invalid, quarantined", log.Any("topic", v.Topic), log.Error(v.Err))
+		return false, pub.Publish(qos, v.Rule.Quarantine+v.Topic, payload, 0, false, false)
+	default:
+		return false, v
+	}
+}
+
+// Observer wraps the observer to check the inbound messages, the message rejected is dropped (and acknowledged),
+// and the message quarantined is published to the quarantine topic by the publisher
+func (c *Checker) Observer(obs mqtt.Observer, pub Publisher) mqtt.Observer {
+	onPublish := func(pkt *mqtt.Publish) error {
+		err := c.Check(context.Background(), pkt.Message.Topic, pkt.Message.Payload)
+		if err == nil {
+			return obs.OnPublish(pkt)
+		}
+		v, ok := err.(*Violation)
+		if !ok {
+			return err
+		}
+		forward, err := c.handle(pub, pkt.Message.QOS, pkt.Message.Payload, v)
+		if forward {
+			return obs.OnPublish(pkt)
+		}
+		if err == v {
+			c.log.Warn("payload is invalid, dropped", log.Any("topic", v.Topic), log.Error(v.Err))
+			return nil
+		}
+		return err
+	}
+	return mqtt.NewObserverWrapper(onPublish, obs.OnPuback, obs.OnError)
+}
+
+// Publisher wraps the publisher to check the outbound messages, returns the *Violation if the message is rejected,
+// and the message quarantined is published to the quarantine topic instead
+func (c *Checker) Publisher(pub Publisher) Publisher {
+	return publisherFunc(func(qos mqtt.QOS, topic string, payload []byte, pid mqtt.ID, retain bool, dup bool) error {
+		err := c.Check(context.Background(), topic, payload)
+		if err == nil {
+			return pub.Publish(qos, topic, payload, pid, retain, dup)
+		}
+		v, ok := err.(*Violation)
+		if !ok {
+			return err
+		}
+		forward, err := c.handle(pub, qos, payload, v)
+		if forward {
+			return pub.Publish(qos, topic, payload, pid, retain, dup)
+		}
+		return err
+	})
+}
+
+type publisherFunc func(qos mqtt.QOS, topic string, payload []byte, pid mqtt.ID, retain bool, dup bool) error
+
+func (f publisherFunc) Publish(qos mqtt.QOS, topic string, payload []byte, pid mqtt.ID, retain bool, dup bool) error {
+	return f(qos, topic, payload, pid, retain, dup)
+}
+
+// Close closes the checker
+func (c *Checker) Close() error {
+	return c.reg.Close()
+}
@@ -0,0 +1,32 @@
+package schema
+
+import (
+	"time"
+
+	"github.com/baetyl/baetyl-go/http"
+)
+
+// all actions on the payloads invalid
+const (
+	ActionReject     = "reject"     // the message is dropped
+	ActionQuarantine = "quarantine" // the message is published to the quarantine topic
+	ActionPass       = "pass"       // the message is passed with a warning
+)
+
+// Config the config of schema validation
+type Config struct {
+	Path     string            `yaml:"path" json:"path" default:"/v1/schemas"`         // the path to fetch schemas, the subject and the version are appended
+	Dir      string            `yaml:"dir" json:"dir" default:"var/lib/baetyl/schema"` // the directory to cache the schemas fetched
+	Interval time.Duration     `yaml:"interval" json:"interval" default:"5m"`          // the interval to refresh the latest version of schemas
+	Client   http.ClientConfig `yaml:"client" json:"client"`
+	Rules    []RuleConfig      `yaml:"rules" json:"rules"`
+}
+
+// RuleConfig the config of the schema of topic, the first rule matched is applied if the topic matches several rules
+type RuleConfig struct {
+	Topic      string `yaml:"topic" json:"topic" validate:"nonzero"` // the topic filter
+	Subject    string `yaml:"subject" json:"subject" validate:"nonzero"`
+	Version    string `yaml:"version" json:"version" default:"latest"`
+	Action     string `yaml:"action" json:"action" default:"reject" validate:"regexp=^(reject|quarantine|pass)$"`
+	Quarantine string `yaml:"quarantine" json:"quarantine" default:"quarantine/"` // the prefix of quarantine topic, followed by the original topic
+}
@@ -0,0 +1,439 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// jsonSchema the compiled json schema, the keywords supported are type, enum, const, properties, required,
+// additionalProperties, items, minItems, maxItems, minimum, maximum, exclusiveMinimum, exclusiveMaximum, multipleOf,
+// minLength, maxLength, pattern, allOf, anyOf, oneOf, not and the $ref to local definitions. Others are ignored.
+type jsonSchema struct {
+	boolean  *bool // the schema true or false
+	types    []string
+	enum     []interface{}
+	constant *interface{}
+
+	properties   map[string]*jsonSchema
+	required     []string
+	additional   *jsonSchema
+	items        *jsonSchema
+	minItems     *int
+	maxItems     *int
+	minimum      *float64
+	maximum      *float64
+	exclusiveMin *float64
+	exclusiveMax *float64
+	multipleOf   *float64
+	minLength    *int
+	maxLength    *int
+	pattern      *regexp.Regexp
+
+	allOf []*jsonSchema
+	anyOf []*jsonSchema
+	oneOf []*jsonSchema
+	not   *jsonSchema
+
+	ref  string
+	root *jsonSchema
+	defs map[string]*jsonSchema
+}
+
+// CompileJSON compiles the json schema
+func CompileJSON(data []byte) (Validator, error) {
+	var raw interface{}
+	if err := unmarshalJSON(data, &raw); err != nil {
+		return nil, fmt.Errorf("json schema is invalid: %s", err.Error())
+	}
+	root := &jsonSchema{}
+	if err := root.compile(raw, root); err != nil {
+		return nil, fmt.Errorf("json schema is invalid: %s", err.Error())
+	}
+	return root, nil
+}
+
+// Validate validates the json payload
+func (s *jsonSchema) Validate(payload []byte) error {
+	var v interface{}
+	if err := unmarshalJSON(payload, &v); err != nil {
+		return fmt.Errorf("payload is not json: %s", err.Error())
+	}
+	return s.validate("$", v)
+}
+
+func unmarshalJSON(data []byte, v interface{}) error {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(v); err != nil {
+		return err
+	}
+	if d.More() {
+		return fmt.Errorf("unexpected data after top-level value")
+	}
+	return nil
+}
+
+func (s *jsonSchema) compile(raw interface{}, root *jsonSchema) error {
+	s.root = root
+	if b, ok := raw.(bool); ok {
+		s.boolean = &b
+		return nil
+	}
+	m, ok := raw.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("schema must be an object or a boolean")
+	}
+	var err error
+	if s == root {
+		// the definitions are allocated before compiled, so that they can refer to each other
+		s.defs = map[string]*jsonSchema{}
+		for _, k := range []string{"definitions", "$defs"} {
+			defs, _ := m[k].(map[string]interface{})
+			for name := range defs {
+				s.defs["#/"+k+"/"+name] = &jsonSchema{}
+			}
+		}
+		for _, k := range []string{"definitions", "$defs"} {
+			defs, _ := m[k].(map[string]interface{})
+			for name, v := range defs {
+				if err = s.defs["#/"+k+"/"+name].compile(v, root); err != nil {
+					return fmt.Errorf("%s.%s: %s", k, name, err.Error())
+				}
+			}
+		}
+	}
+	if v, ok := m["$ref"]; ok {
+		ref, _ := v.(string)
+		if ref != "#" {
+			if _, ok := root.defs[ref]; !ok {
+				return fmt.Errorf("$ref (%v) not found, only local definitions are supported", v)
+			}
+		}
+		s.ref = ref
+		return nil
+	}
+	switch t := m["type"].(type) {
+	case nil:
+	case string:
+		s.types = []string{t}
+	case []interface{}:
+		for _, v := range t {
+			if ts, ok := v.(string); ok {
+				s.types = append(s.types, ts)
+			}
+		}
+	default:
+		return fmt.Errorf("type must be a string or an array")
+	}
+	if v, ok := m["enum"].([]interface{}); ok {
+		s.enum = v
+	}
+	if v, ok := m["const"]; ok {
+		s.constant = &v
+	}
+	if props, ok := m["properties"].(map[string]interface{}); ok {
+		s.properties = map[string]*jsonSchema{}
+		for name, v := range props {
+			p := &jsonSchema{}
+			if err = p.compile(v, root); err != nil {
+				return fmt.Errorf("properties.%s: %s", name, err.Error())
+			}
+			s.properties[name] = p
+		}
+	}
+	if req, ok := m["required"].([]interface{}); ok {
+		for _, v := range req {
+			if name, ok := v.(string); ok {
+				s.required = append(s.required, name)
+			}
+		}
+	}
+	if s.additional, err = compileSub(m, "additionalProperties", root); err != nil {
+		return err
+	}
+	if s.items, err = compileSub(m, "items", root); err != nil {
+		return err
+	}
+	if s.not, err = compileSub(m, "not", root); err != nil {
+		return err
+	}
+	for _, k := range []string{"allOf", "anyOf", "oneOf"} {
+		list, ok := m[k].([]interface{})
+		if !ok {
+			continue
+		}
+		var subs []*jsonSchema
+		for i, v := range list {
+			sub := &jsonSchema{}
+			if err = sub.compile(v, root); err != nil {
+				return fmt.Errorf("%s[%d]: %s", k, i, err.Error())
+			}
+			subs = append(subs, sub)
+		}
+		switch k {
+		case "allOf":
+			s.allOf = subs
+		case "anyOf":
+			s.anyOf = subs
+		default:
+			s.oneOf = subs
+		}
+	}
+	for k, p := range map[string]**int{"minItems": &s.minItems, "maxItems": &s.maxItems, "minLength": &s.minLength, "maxLength": &s.maxLength} {
+		if n, ok := number(m[k]); ok {
+			i := int(n)
+			*p = &i
+		}
+	}
+	for k, p := range map[string]**float64{"minimum": &s.minimum, "maximum": &s.maximum, "exclusiveMinimum": &s.exclusiveMin, "exclusiveMaximum": &s.exclusiveMax, "multipleOf": &s.multipleOf} {
+		if n, ok := number(m[k]); ok {
+			*p = &n
+		}
+	}
+	if v, ok := m["pattern"].(string); ok {
+		if s.pattern, err = regexp.Compile(v); err != nil {
+			return fmt.Errorf("pattern: %s", err.Error())
+		}
+	}
+	return nil
+}
+
+func compileSub(m map[string]interface{}, key string, root *jsonSchema) (*jsonSchema, error) {
+	v, ok := m[key]
+	if !ok {
+		return nil, nil
+	}
+	s := &jsonSchema{}
+	if err := s.compile(v, root); err != nil {
+		return nil, fmt.Errorf("%s: %s", key, err.Error())
+	}
+	return s, nil
+}
+
+func number(v interface{}) (float64, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0, false
+	}
+	f, err := n.Float64()
+	return f, err == nil
+}
+
+func (s *jsonSchema) validate(path string, v interface{}) error {
+	if s.boolean != nil {
+		if !*s.boolean {
+			return fmt.Errorf("%s: not allowed", path)
+		}
+		return nil
+	}
+	if s.ref != "" {
+		if s.ref == "#" {
+			return s.root.validate(path, v)
+		}
+		return s.root.defs[s.ref].validate(path, v)
+	}
+	if len(s.types) > 0 {
+		ok := false
+		for _, t := range s.types {
+			if isType(v, t) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return fmt.Errorf("%s: expected %s, got %s", path, strings.Join(s.types, " or "), typeOf(v))
+		}
+	}
+	if s.constant != nil && !equal(v, *s.constant) {
+		return fmt.Errorf("%s: must be %v", path, *s.constant)
+	}
+	if s.enum != nil {
+		ok := false
+		for _, e := range s.enum {
+			if equal(v, e) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return fmt.Errorf("%s: must be one of %v", path, s.enum)
+		}
+	}
+
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if err := s.validateObject(path, t); err != nil {
+			return err
+		}
+	case []interface{}:
+		if s.minItems != nil && len(t) < *s.minItems {
+			return fmt.Errorf("%s: must have at least %d items", path, *s.minItems)
+		}
+		if s.maxItems != nil && len(t) > *s.maxItems {
+			return fmt.Errorf("%s: must have at most %d items", path, *s.maxItems)
+		}
+		if s.items != nil {
+			for i, item := range t {
+				if err := s.items.validate(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+					return err
+				}
+			}
+		}
+	case json.Number:
+		if err := s.validateNumber(path, t); err != nil {
+			return err
+		}
+	case string:
+		n := utf8.RuneCountInString(t)
+		if s.minLength != nil && n < *s.minLength {
+			return fmt.Errorf("%s: length must be at least %d", path, *s.minLength)
+		}
+		if s.maxLength != nil && n > *s.maxLength {
+			return fmt.Errorf("%s: length must be at most %d", path, *s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(t) {
+			return fmt.Errorf("%s: must match pattern %s", path, s.pattern.String())
+		}
+	}
+
+	for _, sub := range s.allOf {
+		if err := sub.validate(path, v); err != nil {
+			return err
+		}
+	}
+	if len(s.anyOf) > 0 {
+		ok := false
+		for _, sub := range s.anyOf {
+			if sub.validate(path, v) == nil {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return fmt.Errorf("%s: must match any of the schemas", path)
+		}
+	}
+	if len(s.oneOf) > 0 {
+		n := 0
+		for _, sub := range s.oneOf {
+			if sub.validate(path, v) == nil {
+				n++
+			}
+		}
+		if n != 1 {
+			return fmt.Errorf("%s: must match exactly one of the schemas, matched %d", path, n)
+		}
+	}
+	if s.not != nil && s.not.validate(path, v) == nil {
+		return fmt.Errorf("%s: must not match the schema", path)
+	}
+	return nil
+}
+
+func (s *jsonSchema) validateObject(path string, m map[string]interface{}) error {
+	for _, name := range s.required {
+		if _, ok := m[name]; !ok {
+			return fmt.Errorf("%s: property (%s) is required", path, name)
+		}
+	}
+	// in order, so that the error is stable
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p, ok := s.properties[name]
+		if !ok {
+			p = s.additional
+		}
+		if p == nil {
+			continue
+		}
+		if err := p.validate(path+"."+name, m[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *jsonSchema) validateNumber(path string, n json.Number) error {
+	f, err := n.Float64()
+	if err != nil {
+		return fmt.Errorf("%s: %s", path, err.Error())
+	}
+	if s.minimum != nil && f < *s.minimum {
+		return fmt.Errorf("%s: must be >= %v", path, *s.minimum)
+	}
+	if s.maximum != nil && f > *s.maximum {
+		return fmt.Errorf("%s: must be <= %v", path, *s.maximum)
+	}
+	if s.exclusiveMin != nil && f <= *s.exclusiveMin {
+		return fmt.Errorf("%s: must be > %v", path, *s.exclusiveMin)
+	}
+	if s.exclusiveMax != nil && f >= *s.exclusiveMax {
+		return fmt.Errorf("%s: must be < %v", path, *s.exclusiveMax)
+	}
+	if s.multipleOf != nil && *s.multipleOf > 0 {
+		q := f / *s.multipleOf
+		if math.Abs(q-math.Round(q)) > 1e-9 {
+			return fmt.Errorf("%s: must be a multiple of %v", path, *s.multipleOf)
+		}
+	}
+	return nil
+}
+
+func isType(v interface{}, t string) bool {
+	switch t {
+	case "integer":
+		n, ok := v.(json.Number)
+		if !ok {
+			return false
+		}
+		if _, err := strconv.ParseInt(n.String(), 10, 64); err == nil {
+			return true
+		}
+		f, err := n.Float64()
+		return err == nil && f == math.Trunc(f)
+	default:
+		return typeOf(v) == t
+	}
+}
+
+func typeOf(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return reflect.TypeOf(v).String()
+	}
+}
+
+func equal(a, b interface{}) bool {
+	na, ok1 := a.(json.Number)
+	nb, ok2 := b.(json.Number)
+	if ok1 && ok2 {
+		fa, err1 := na.Float64()
+		fb, err2 := nb.Float64()
+		return err1 == nil && err2 == nil && fa == fb
+	}
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,171 @@
+package schema
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/protoc-gen-gogo/descriptor"
+)
+
+// the max depth of nested messages, in case of the malicious payload
+const maxProtoDepth = 64
+
+type protoMessage struct {
+	name   string
+	proto3 bool
+	fields map[int32]*descriptor.FieldDescriptorProto
+}
+
+// protoSchema the compiled protobuf schema, which checks the wire format of payload against the message descriptor,
+// including the field numbers, the wire types, the nested messages, the required fields and the utf-8 strings of proto3.
+// The fields unknown are rejected, since the new fields should come with a new version of schema.
+type protoSchema struct {
+	root *protoMessage
+	msgs map[string]*protoMessage
+}
+
+// CompileProtobuf compiles the protobuf schema of the message (full name) in the file descriptor set,
+// which is generated by protoc with --descriptor_set_out and --include_imports
+func CompileProtobuf(data []byte, message string) (Validator, error) {
+	var fds descriptor.FileDescriptorSet
+	if err := proto.Unmarshal(data, &fds); err != nil {
+		return nil, fmt.Errorf("protobuf schema is invalid: %s", err.Error())
+	}
+	s := &protoSchema{msgs: map[string]*protoMessage{}}
+	for _, f := range fds.File {
+		prefix := f.GetPackage()
+		if prefix != "" {
+			prefix += "."
+		}
+		for _, m := range f.MessageType {
+			s.add(prefix, m, f.GetSyntax() == "proto3")
+		}
+	}
+	s.root = s.msgs[strings.TrimPrefix(message, ".")]
+	if s.root == nil {
+		return nil, fmt.Errorf("protobuf schema is invalid: message (%s) not found", message)
+	}
+	for _, m := range s.msgs {
+		for _, f := range m.fields {
+			if f.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+				continue
+			}
+			if _, ok := s.msgs[strings.TrimPrefix(f.GetTypeName(), ".")]; !ok {
+				return nil, fmt.Errorf("protobuf schema is invalid: message (%s) not found", f.GetTypeName())
+			}
+		}
+	}
+	return s, nil
+}
+
+func (s *protoSchema) add(prefix string, m *descriptor.DescriptorProto, proto3 bool) {
+	pm := &protoMessage{
+		name:   prefix + m.GetName(),
+		proto3: proto3,
+		fields: map[int32]*descriptor.FieldDescriptorProto{},
+	}
+	for _, f := range m.Field {
+		pm.fields[f.GetNumber()] = f
+	}
+	s.msgs[pm.name] = pm
+	for _, n := range m.NestedType {
+		s.add(pm.name+".", n, proto3)
+	}
+}
+
+// Validate validates the protobuf payload
+func (s *protoSchema) Validate(payload []byte) error {
+	return s.validate("$", s.root, payload, 0)
+}
+
+func (s *protoSchema) validate(path string, m *protoMessage, data []byte, depth int) error {
+	if depth > maxProtoDepth {
+		return fmt.Errorf("%s: too deep", path)
+	}
+	seen := map[int32]bool{}
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return fmt.Errorf("%s: invalid field key", path)
+		}
+		data = data[n:]
+		num, wt := int32(key>>3), int(key&7)
+		f, ok := m.fields[num]
+		if !ok {
+			return fmt.Errorf("%s: field (%d) unknown in message (%s)", path, num, m.name)
+		}
+		fp := path + "." + f.GetName()
+		var value []byte
+		value, data, n = readValue(data, wt)
+		if n < 0 {
+			return fmt.Errorf("%s: invalid value of wire type %d", fp, wt)
+		}
+		seen[num] = true
+
+		expected := f.WireType()
+		if wt != expected {
+			// the repeated scalars may be packed
+			if wt != 2 || expected == 2 || f.GetLabel() != descriptor.FieldDescriptorProto_LABEL_REPEATED {
+				return fmt.Errorf("%s: wire type %d mismatches type %s", fp, wt, f.GetType())
+			}
+			for len(value) > 0 {
+				if _, value, n = readValue(value, expected); n < 0 {
+					return fmt.Errorf("%s: invalid packed value", fp)
+				}
+			}
+			continue
+		}
+		switch f.GetType() {
+		case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+			if err := s.validate(fp, s.msgs[strings.TrimPrefix(f.GetTypeName(), ".")], value, depth+1); err != nil {
+				return err
+			}
+		case descriptor.FieldDescriptorProto_TYPE_STRING:
+			if m.proto3 && !utf8.Valid(value) {
+				return fmt.Errorf("%s: string is not valid utf-8", fp)
+			}
+		}
+	}
+	for num, f := range m.fields {
+		if f.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REQUIRED && !seen[num] {
+			return fmt.Errorf("%s: field (%s) is required", path, f.GetName())
+		}
+	}
+	return nil
+}
+
+// readValue reads the value of the wire type, returns the value (only for length-delimited), the rest
+// and the size read, the size is negative if invalid
+func readValue(data []byte, wt int) ([]byte, []byte, int) {
+	switch wt {
+	case 0:
+		_, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, nil, -1
+		}
+		return nil, data[n:], n
+	case 1:
+		if len(data) < 8 {
+			return nil, nil, -1
+		}
+		return nil, data[8:], 8
+	case 2:
+		l, n := binary.Uvarint(data)
+		if n <= 0 || l > uint64(len(data)-n) {
+			return nil, nil, -1
+		}
+		end := n + int(l)
+		return data[n:end], data[end:], end
+	case 5:
+		if len(data) < 4 {
+			return nil, nil, -1
+		}
+		return nil, data[4:], 4
+	default:
+		// the groups are deprecated and not supported
+		return nil, nil, -1
+	}
+}
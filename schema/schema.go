@@ -0,0 +1,181 @@
+// Package schema fetches the json schemas and the protobuf descriptors from the registry, caches them in local directory,
+// and validates the inbound and outbound payloads by topic, so that the malformed data is rejected or quarantined at the edge.
+package schema
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	gohttp "net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/baetyl/baetyl-go/http"
+	"github.com/baetyl/baetyl-go/log"
+)
+
+// all schema types
+const (
+	TypeJSON     = "json"
+	TypeProtobuf = "protobuf"
+)
+
+// VersionLatest the latest version of schema, which is refreshed periodically
+const VersionLatest = "latest"
+
+// Validator validates the payload
+type Validator interface {
+	Validate(payload []byte) error
+}
+
+// Definition the schema definition returned by the registry
+type Definition struct {
+	Subject string          `json:"subject"`
+	Version int             `json:"version"`
+	Type    string          `json:"type"`              // json or protobuf
+	Schema  json.RawMessage `json:"schema"`            // the json schema, or the file descriptor set of protobuf in base64
+	Message string          `json:"message,omitempty"` // the full name of protobuf message
+}
+
+// Compile compiles the schema definition
+func Compile(d *Definition) (Validator, error) {
+	switch d.Type {
+	case TypeJSON:
+		return CompileJSON(d.Schema)
+	case TypeProtobuf:
+		var fds []byte
+		if err := json.Unmarshal(d.Schema, &fds); err != nil {
+			return nil, fmt.Errorf("protobuf schema is invalid: %s", err.Error())
+		}
+		return CompileProtobuf(fds, d.Message)
+	default:
+		return nil, fmt.Errorf("schema type (%s) not supported", d.Type)
+	}
+}
+
+type entry struct {
+	def     *Definition
+	val     Validator
+	fetched time.Time
+}
+
+// Registry the client of schema registry, the schemas fetched are cached in memory and in local directory,
+// the cached one is used if the registry is unreachable
+type Registry struct {
+	cfg   Config
+	cli   *http.Client
+	cache map[string]*entry
+	mu    sync.Mutex
+	log   *log.Logger
+}
+
+// NewRegistry creates a new client of schema registry
+func NewRegistry(cfg Config) (*Registry, error) {
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, err
+	}
+	cli, err := http.NewClient(cfg.Client)
+	if err != nil {
+		return nil, err
+	}
+	return &Registry{
+		cfg:   cfg,
+		cli:   cli,
+		cache: map[string]*entry{},
+		log:   log.With(log.Any("schema", "registry")),
+	}, nil
+}
+
+// Get returns the validator of the subject and the version, the fixed version is fetched only once,
+// and the latest version is refreshed after the interval
+func (r *Registry) Get(ctx context.Context, subject, version string) (Validator, error) {
+	if version == "" {
+		version = VersionLatest
+	}
+	key := subject + "@" + version
+	r.mu.Lock()
+	e, ok := r.cache[key]
+	r.mu.Unlock()
+	if ok && (version != VersionLatest || time.Since(e.fetched) < r.cfg.Interval) {
+		return e.val, nil
+	}
+
+	ne, err := r.fetch(ctx, subject, version)
+	if err != nil {
+		if ok {
+			r.log.Warn("failed to refresh schema, use the cached one", log.Any("subject", subject), log.Error(err))
+			r.touch(key, e)
+			return e.val, nil
+		}
+		var lerr error
+		ne, lerr = r.load(key)
+		if lerr != nil {
+			return nil, err
+		}
+		r.log.Warn("failed to fetch schema, use the one cached in directory", log.Any("subject", subject))
+	}
+	r.mu.Lock()
+	r.cache[key] = ne
+	r.mu.Unlock()
+	return ne.val, nil
+}
+
+// touch delays the next refresh of the entry after failed, so that the unreachable registry isn't called for every payload
+func (r *Registry) touch(key string, e *entry) {
+	r.mu.Lock()
+	r.cache[key] = &entry{def: e.def, val: e.val, fetched: time.Now()}
+	r.mu.Unlock()
+}
+
+func (r *Registry) fetch(ctx context.Context, subject, version string) (*entry, error) {
+	path := r.cfg.Path + "/" + url.PathEscape(subject) + "/versions/" + url.PathEscape(version)
+	body, err := r.cli.CallContext(ctx, gohttp.MethodGet, path, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	e, err := decode(body)
+	if err != nil {
+		return nil, err
+	}
+	file := r.file(subject + "@" + version)
+	if err = ioutil.WriteFile(file+".tmp", body, 0600); err == nil {
+		err = os.Rename(file+".tmp", file)
+	}
+	if err != nil {
+		r.log.Warn("failed to cache schema", log.Any("subject", subject), log.Error(err))
+	}
+	return e, nil
+}
+
+func (r *Registry) load(key string) (*entry, error) {
+	body, err := ioutil.ReadFile(r.file(key))
+	if err != nil {
+		return nil, err
+	}
+	return decode(body)
+}
+
+func (r *Registry) file(key string) string {
+	return filepath.Join(r.cfg.Dir, url.PathEscape(key)+".json")
+}
+
+func decode(body []byte) (*entry, error) {
+	var d Definition
+	if err := json.Unmarshal(body, &d); err != nil {
+		return nil, fmt.Errorf("schema definition is invalid: %s", err.Error())
+	}
+	v, err := Compile(&d)
+	if err != nil {
+		return nil, err
+	}
+	return &entry{def: &d, val: v, fetched: time.Now()}, nil
+}
+
+// Close closes the client
+func (r *Registry) Close() error {
+	return r.cli.Close()
+}
@@ -0,0 +1,301 @@
+package schema
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	gohttp "net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/baetyl/baetyl-go/mqtt"
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/protoc-gen-gogo/descriptor"
+	"github.com/stretchr/testify/assert"
+)
+
+const testJSONSchema = `{
+	"type": "object",
+	"required": ["id", "temp"],
+	"additionalProperties": false,
+	"properties": {
+		"id": {"type": "string", "pattern": "^d[0-9]+$"},
+		"temp": {"type": "number", "minimum": -40, "exclusiveMaximum": 125},
+		"status": {"enum": ["on", "off"]},
+		"seq": {"type": "integer", "minimum": 0},
+		"tags": {"type": "array", "maxItems": 2, "items": {"type": "string", "minLength": 1}},
+		"child": {"$ref": "#/definitions/child"}
+	},
+	"definitions": {
+		"child": {"type": ["object", "null"], "properties": {"child": {"$ref": "#/definitions/child"}}}
+	}
+}`
+
+func TestJSONSchema(t *testing.T) {
+	v, err := CompileJSON([]byte(testJSONSchema))
+	assert.NoError(t, err)
+
+	assert.NoError(t, v.Validate([]byte(`{"id":"d1","temp":20.5,"status":"on","seq":3,"tags":["a"],"child":{"child":null}}`)))
+	cases := map[string]string{
+		`{"id":"d1"`:                               "payload is not json: unexpected EOF",
+		`{"id":"d1","temp":1} {}`:                  "payload is not json: unexpected data after top-level value",
+		`[]`:                                       "$: expected object, got array",
+		`{"id":"d1"}`:                              "$: property (temp) is required",
+		`{"id":"x1","temp":1}`:                     "$.id: must match pattern ^d[0-9]+$",
+		`{"id":"d1","temp":125}`:                   "$.temp: must be < 125",
+		`{"id":"d1","temp":-41}`:                   "$.temp: must be >= -40",
+		`{"id":"d1","temp":"1"}`:                   "$.temp: expected number, got string",
+		`{"id":"d1","temp":1,"status":"x"}`:        "$.status: must be one of [on off]",
+		`{"id":"d1","temp":1,"seq":1.5}`:           "$.seq: expected integer, got number",
+		`{"id":"d1","temp":1,"tags":["a",""]}`:     "$.tags[1]: length must be at least 1",
+		`{"id":"d1","temp":1,"tags":[1,2,3]}`:      "$.tags: must have at most 2 items",
+		`{"id":"d1","temp":1,"x":1}`:               "$.x: not allowed",
+		`{"id":"d1","temp":1,"child":{"child":1}}`: "$.child.child: expected object or null, got number",
+	}
+	for payload, msg := range cases {
+		assert.EqualError(t, v.Validate([]byte(payload)), msg, payload)
+	}
+
+	v, err = CompileJSON([]byte(`{"oneOf":[{"type":"integer"},{"minimum":10}],"not":{"const":3}}`))
+	assert.NoError(t, err)
+	assert.NoError(t, v.Validate([]byte(`1`)))
+	assert.NoError(t, v.Validate([]byte(`10.5`)))
+	assert.EqualError(t, v.Validate([]byte(`11`)), "$: must match exactly one of the schemas, matched 2")
+	assert.EqualError(t, v.Validate([]byte(`3`)), "$: must not match the schema")
+
+	_, err = CompileJSON([]byte(`{"$ref":"http://example.com/schema"}`))
+	assert.EqualError(t, err, "json schema is invalid: $ref (http://example.com/schema) not found, only local definitions are supported")
+	_, err = CompileJSON([]byte(`{"properties":{"a":{"pattern":"("}}}`))
+	assert.EqualError(t, err, "json schema is invalid: properties.a: pattern: error parsing regexp: missing closing ): `(`")
+	_, err = CompileJSON([]byte(`[]`))
+	assert.EqualError(t, err, "json schema is invalid: schema must be an object or a boolean")
+}
+
+func newTestDescriptorSet(t *testing.T) []byte {
+	field := func(name string, num int32, typ descriptor.FieldDescriptorProto_Type, label descriptor.FieldDescriptorProto_Label, typeName string) *descriptor.FieldDescriptorProto {
+		f := &descriptor.FieldDescriptorProto{Name: proto.String(name), Number: proto.Int32(num), Type: &typ, Label: &label}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+	opt := descriptor.FieldDescriptorProto_LABEL_OPTIONAL
+	rep := descriptor.FieldDescriptorProto_LABEL_REPEATED
+	fds := &descriptor.FileDescriptorSet{File: []*descriptor.FileDescriptorProto{{
+		Name:    proto.String("reading.proto"),
+		Package: proto.String("test"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptor.DescriptorProto{{
+			Name: proto.String("Reading"),
+			Field: []*descriptor.FieldDescriptorProto{
+				field("id", 1, descriptor.FieldDescriptorProto_TYPE_STRING, opt, ""),
+				field("temp", 2, descriptor.FieldDescriptorProto_TYPE_DOUBLE, opt, ""),
+				field("values", 3, descriptor.FieldDescriptorProto_TYPE_INT32, rep, ""),
+				field("meta", 4, descriptor.FieldDescriptorProto_TYPE_MESSAGE, opt, ".test.Reading.Meta"),
+			},
+			NestedType: []*descriptor.DescriptorProto{{
+				Name:  proto.String("Meta"),
+				Field: []*descriptor.FieldDescriptorProto{field("seq", 1, descriptor.FieldDescriptorProto_TYPE_UINT64, opt, "")},
+			}},
+		}},
+	}}}
+	data, err := proto.Marshal(fds)
+	assert.NoError(t, err)
+	return data
+}
+
+func TestProtobufSchema(t *testing.T) {
+	fds := newTestDescriptorSet(t)
+	v, err := CompileProtobuf(fds, ".test.Reading")
+	assert.NoError(t, err)
+
+	b := proto.NewBuffer(nil)
+	b.EncodeVarint(1<<3 | 2)
+	b.EncodeStringBytes("d1")
+	b.EncodeVarint(2<<3 | 1)
+	b.EncodeFixed64(1)
+	b.EncodeVarint(3<<3 | 0) // not packed
+	b.EncodeVarint(7)
+	b.EncodeVarint(3<<3 | 2) // packed
+	b.EncodeRawBytes([]byte{1, 2, 3})
+	b.EncodeVarint(4<<3 | 2)
+	b.EncodeRawBytes([]byte{1 << 3, 9})
+	assert.NoError(t, v.Validate(b.Bytes()))
+	assert.NoError(t, v.Validate(nil))
+
+	cases := map[string][]byte{
+		"$: field (9) unknown in message (test.Reading)":           {9<<3 | 0, 1},
+		"$.temp: wire type 0 mismatches type TYPE_DOUBLE":          {2<<3 | 0, 1},
+		"$.id: invalid value of wire type 2":                       {1<<3 | 2, 5, 'a'},
+		"$.id: string is not valid utf-8":                          {1<<3 | 2, 1, 0xff},
+		"$.meta: field (2) unknown in message (test.Reading.Meta)": {4<<3 | 2, 2, 2<<3 | 0, 1},
+		"$.values: invalid packed value":                           {3<<3 | 2, 1, 0x80},
+		"$: invalid field key":                                     {0x80},
+	}
+	for msg, payload := range cases {
+		assert.EqualError(t, v.Validate(payload), msg)
+	}
+
+	_, err = CompileProtobuf(fds, "test.Unknown")
+	assert.EqualError(t, err, "protobuf schema is invalid: message (test.Unknown) not found")
+	_, err = CompileProtobuf([]byte{0xff}, "test.Reading")
+	assert.Error(t, err)
+}
+
+func newTestRegistry(t *testing.T, defs map[string]*Definition) (*httptest.Server, *int32) {
+	var calls int32
+	s := httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		atomic.AddInt32(&calls, 1)
+		d, ok := defs[r.URL.Path]
+		if !ok {
+			gohttp.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(d)
+	}))
+	return s, &calls
+}
+
+func newTestConfig(t *testing.T, address string) (Config, func()) {
+	dir, err := ioutil.TempDir("", t.Name())
+	assert.NoError(t, err)
+	var cfg Config
+	assert.NoError(t, utils.SetDefaults(&cfg))
+	cfg.Dir = dir
+	cfg.Client.Address = address
+	cfg.Client.MaxRetries = 0
+	return cfg, func() { os.RemoveAll(dir) }
+}
+
+func TestRegistry(t *testing.T) {
+	fds := newTestDescriptorSet(t)
+	schema, err := json.Marshal(fds)
+	assert.NoError(t, err)
+	s, calls := newTestRegistry(t, map[string]*Definition{
+		"/v1/schemas/reading/versions/latest": {Subject: "reading", Version: 2, Type: TypeJSON, Schema: json.RawMessage(testJSONSchema)},
+		"/v1/schemas/reading/versions/1":      {Subject: "reading", Version: 1, Type: TypeProtobuf, Schema: schema, Message: "test.Reading"},
+		"/v1/schemas/bad/versions/latest":     {Subject: "bad", Version: 1, Type: "avro"},
+	})
+	cfg, clean := newTestConfig(t, s.URL)
+	defer clean()
+	cfg.Interval = time.Hour
+
+	r, err := NewRegistry(cfg)
+	assert.NoError(t, err)
+	defer r.Close()
+
+	v, err := r.Get(context.Background(), "reading", "")
+	assert.NoError(t, err)
+	assert.NoError(t, v.Validate([]byte(`{"id":"d1","temp":1}`)))
+	v, err = r.Get(context.Background(), "reading", "1")
+	assert.NoError(t, err)
+	assert.NoError(t, v.Validate([]byte{1<<3 | 2, 2, 'd', '1'}))
+	_, err = r.Get(context.Background(), "bad", "latest")
+	assert.EqualError(t, err, "schema type (avro) not supported")
+	_, err = r.Get(context.Background(), "unknown", "latest")
+	assert.Error(t, err)
+
+	// the schemas are cached in memory
+	n := atomic.LoadInt32(calls)
+	_, err = r.Get(context.Background(), "reading", "latest")
+	assert.NoError(t, err)
+	_, err = r.Get(context.Background(), "reading", "1")
+	assert.NoError(t, err)
+	assert.Equal(t, n, atomic.LoadInt32(calls))
+
+	// the schemas cached in directory are used if the registry is unreachable
+	s.Close()
+	r2, err := NewRegistry(cfg)
+	assert.NoError(t, err)
+	defer r2.Close()
+	v, err = r2.Get(context.Background(), "reading", "latest")
+	assert.NoError(t, err)
+	assert.EqualError(t, v.Validate([]byte(`{}`)), "$: property (id) is required")
+	_, err = r2.Get(context.Background(), "unknown", "latest")
+	assert.Error(t, err)
+
+	// the cached one is used if failed to refresh
+	r.cfg.Interval = 0
+	v, err = r.Get(context.Background(), "reading", "latest")
+	assert.NoError(t, err)
+	assert.NotNil(t, v)
+}
+
+type mockPublisher struct {
+	topics []string
+	err    error
+}
+
+func (p *mockPublisher) Publish(_ mqtt.QOS, topic string, _ []byte, _ mqtt.ID, _ bool, _ bool) error {
+	p.topics = append(p.topics, topic)
+	return p.err
+}
+
+func TestChecker(t *testing.T) {
+	s, _ := newTestRegistry(t, map[string]*Definition{
+		"/v1/schemas/reading/versions/latest": {Subject: "reading", Version: 1, Type: TypeJSON, Schema: json.RawMessage(testJSONSchema)},
+	})
+	defer s.Close()
+	cfg, clean := newTestConfig(t, s.URL)
+	defer clean()
+	cfg.Rules = []RuleConfig{
+		{Topic: "sensor/+/reading", Subject: "reading", Action: ActionQuarantine, Quarantine: "quarantine/"},
+		{Topic: "sensor/#", Subject: "reading", Action: ActionReject},
+		{Topic: "debug/#", Subject: "reading", Action: ActionPass},
+		{Topic: "other/#", Subject: "unknown", Action: ActionReject},
+	}
+	c, err := NewChecker(cfg)
+	assert.NoError(t, err)
+	defer c.Close()
+
+	valid, invalid := []byte(`{"id":"d1","temp":1}`), []byte(`{"id":"d1"}`)
+	assert.NoError(t, c.Check(context.Background(), "sensor/d1/reading", valid))
+	assert.NoError(t, c.Check(context.Background(), "none", invalid))
+	err = c.Check(context.Background(), "sensor/d1/reading", invalid)
+	assert.EqualError(t, err, "payload of topic (sensor/d1/reading) violates schema (reading): $: property (temp) is required")
+	assert.Equal(t, ActionQuarantine, err.(*Violation).Rule.Action)
+
+	// outbound
+	pub := &mockPublisher{}
+	p := c.Publisher(pub)
+	assert.NoError(t, p.Publish(1, "sensor/d1/reading", valid, 0, false, false))
+	assert.NoError(t, p.Publish(1, "sensor/d1/reading", invalid, 0, false, false))
+	assert.IsType(t, &Violation{}, p.Publish(1, "sensor/d1", invalid, 0, false, false))
+	assert.NoError(t, p.Publish(1, "debug/d1", invalid, 0, false, false))
+	assert.Error(t, p.Publish(1, "other/d1", valid, 0, false, false))
+	assert.Equal(t, []string{"sensor/d1/reading", "quarantine/sensor/d1/reading", "debug/d1"}, pub.topics)
+
+	// inbound
+	pub = &mockPublisher{}
+	var received []string
+	obs := c.Observer(mqtt.NewObserverWrapper(func(pkt *mqtt.Publish) error {
+		received = append(received, pkt.Message.Topic)
+		return nil
+	}, nil, nil), pub)
+	newPublish := func(topic string, payload []byte) *mqtt.Publish {
+		pkt := mqtt.NewPublish()
+		pkt.Message.Topic = topic
+		pkt.Message.Payload = payload
+		return pkt
+	}
+	assert.NoError(t, obs.OnPublish(newPublish("sensor/d1/reading", valid)))
+	assert.NoError(t, obs.OnPublish(newPublish("sensor/d1/reading", invalid)))
+	assert.NoError(t, obs.OnPublish(newPublish("sensor/d1", invalid)))
+	assert.NoError(t, obs.OnPublish(newPublish("debug/d1", invalid)))
+	assert.Error(t, obs.OnPublish(newPublish("other/d1", valid)))
+	assert.Equal(t, []string{"sensor/d1/reading", "debug/d1"}, received)
+	assert.Equal(t, []string{"quarantine/sensor/d1/reading"}, pub.topics)
+
+	// the message is not acknowledged if failed to quarantine
+	pub.err = errors.New("publish error")
+	assert.EqualError(t, obs.OnPublish(newPublish("sensor/d1/reading", invalid)), "publish error")
+
+	cfg.Rules = []RuleConfig{{Topic: "a/#/b", Subject: "x"}}
+	_, err = NewChecker(cfg)
+	assert.EqualError(t, err, "topic (a/#/b) is invalid")
+}
@@ -29,10 +29,11 @@ const (
 
 // Config contains the basic fields required for creating a certificate
 type Config struct {
-	commonName   string
-	organization []string
-	altNames     altNames
-	usages       []x509.ExtKeyUsage
+	commonName         string
+	organization       []string
+	organizationalUnit []string
+	altNames           altNames
+	usages             []x509.ExtKeyUsage
 }
 
 type altNames struct {
@@ -120,7 +121,7 @@ type Credentials struct {
 }
 
 // DecodePEMKey takes a key PEM byte array and returns a PrivateKey that represents
-// Either an RSA or EC private key.
+// Either an RSA or EC private key, including the one in PKCS #8.
 func DecodePEMKey(key []byte) (*PrivateKey, error) {
 	block, _ := pem.Decode(key)
 	if block == nil {
@@ -139,6 +140,19 @@ func DecodePEMKey(key []byte) (*PrivateKey, error) {
 			return nil, err
 		}
 		return &PrivateKey{Type: rsaPrivateKeyBlockType, Key: k}, nil
+	case privateKeyBlockType:
+		k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		switch k.(type) {
+		case *ecdsa.PrivateKey:
+			return &PrivateKey{Type: ecPrivateKeyBlockType, Key: k}, nil
+		case *rsa.PrivateKey:
+			return &PrivateKey{Type: rsaPrivateKeyBlockType, Key: k}, nil
+		default:
+			return nil, fmt.Errorf("unsupported key type %T", k)
+		}
 	default:
 		return nil, fmt.Errorf("unsupported block type %s", block.Type)
 	}
@@ -251,6 +265,46 @@ func certPoolFromCertificates(certs []*x509.Certificate) *x509.CertPool {
 	return pool
 }
 
+// newCACertTemplate creates the template of CA certificate, which is valid until notAfter
+func newCACertTemplate(subject pkix.Name, notAfter time.Time) (*x509.Certificate, error) {
+	serial, err := genSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+	return &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               subject,
+		NotBefore:             time.Now().Add(-time.Minute).UTC(), // tolerate clock skew
+		NotAfter:              notAfter.UTC(),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, nil
+}
+
+// newLeafCertTemplate creates the template of certificate for both server and client authentication,
+// the subject and the SANs of the request are kept, which is valid until notAfter
+func newLeafCertTemplate(csr *x509.CertificateRequest, notAfter time.Time) (*x509.Certificate, error) {
+	serial, err := genSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+	return &x509.Certificate{
+		Subject: pkix.Name{
+			CommonName:         csr.Subject.CommonName,
+			Organization:       csr.Subject.Organization,
+			OrganizationalUnit: csr.Subject.OrganizationalUnit,
+		},
+		SerialNumber: serial,
+		DNSNames:     csr.DNSNames,
+		IPAddresses:  csr.IPAddresses,
+		NotBefore:    time.Now().Add(-time.Minute).UTC(), // tolerate clock skew
+		NotAfter:     notAfter.UTC(),
+		KeyUsage:     x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+	}, nil
+}
+
 func genSerialNumber() (*big.Int, error) {
 	serialNumLimit := new(big.Int).Lsh(big.NewInt(1), 128)
 	serialNum, err := rand.Int(rand.Reader, serialNumLimit)
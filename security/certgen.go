@@ -0,0 +1,229 @@
+package security
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/baetyl/baetyl-go/utils"
+)
+
+// all types of keys
+const (
+	KeyTypeEC  = "ec"
+	KeyTypeRSA = "rsa"
+)
+
+// CertOptions the options of the certificate or the certificate request, such as the subject and the SANs
+type CertOptions struct {
+	CommonName         string
+	Organization       []string
+	OrganizationalUnit []string
+	DNSNames           []string
+	IPAddresses        []net.IP
+	Duration           time.Duration // the validity of the certificate, one year by default
+}
+
+func (o CertOptions) config() Config {
+	return Config{
+		commonName:         o.CommonName,
+		organization:       o.Organization,
+		organizationalUnit: o.OrganizationalUnit,
+		altNames:           altNames{dnsNames: o.DNSNames, ips: o.IPAddresses},
+	}
+}
+
+func (o CertOptions) notAfter() time.Time {
+	if o.Duration <= 0 {
+		return time.Now().Add(duration365d)
+	}
+	return time.Now().Add(o.Duration)
+}
+
+// GenerateKey generates the private key of the type, the bits are the curve of EC (256, 384 or 521, 256 by default),
+// or the size of RSA (2048 by default)
+func GenerateKey(keyType string, bits int) (crypto.Signer, error) {
+	switch keyType {
+	case KeyTypeEC:
+		var curve elliptic.Curve
+		switch bits {
+		case 0, 256:
+			curve = elliptic.P256()
+		case 384:
+			curve = elliptic.P384()
+		case 521:
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("curve of EC key (%d) not supported", bits)
+		}
+		return ecdsa.GenerateKey(curve, rand.Reader)
+	case KeyTypeRSA:
+		return NewRSAPrivateKey(bits)
+	default:
+		return nil, fmt.Errorf("type of key (%s) not supported", keyType)
+	}
+}
+
+// EncodeKeyPEM encodes the EC or RSA private key in pem, which can be decoded by DecodePEMKey
+func EncodeKeyPEM(key crypto.Signer) ([]byte, error) {
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		der, err := x509.MarshalECPrivateKey(k)
+		if err != nil {
+			return nil, err
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: ecPrivateKeyBlockType, Bytes: der}), nil
+	case *rsa.PrivateKey:
+		return EncodePrivateKeyPEM(k), nil
+	default:
+		return nil, fmt.Errorf("type of key (%T) not supported", key)
+	}
+}
+
+// EncodeCertPEM encodes the certificate in pem, which can be decoded by DecodePEMCertificates
+func EncodeCertPEM(cert *x509.Certificate) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: certificateBlockType, Bytes: cert.Raw})
+}
+
+// CreateCACert creates the self-signed CA certificate by the key
+func CreateCACert(opts CertOptions, key crypto.Signer) (*x509.Certificate, error) {
+	subject := pkix.Name{CommonName: opts.CommonName, Organization: opts.Organization, OrganizationalUnit: opts.OrganizationalUnit}
+	tmpl, err := newCACertTemplate(subject, opts.notAfter())
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
+
+// CreateCSR creates the PEM-encoded certificate signing request by the key, with the subject and the SANs of the options
+func CreateCSR(opts CertOptions, key crypto.Signer) ([]byte, error) {
+	return GenerateCSR(opts.config(), key)
+}
+
+// SignCSR signs the PEM-encoded certificate signing request by the CA, the certificate is valid for the duration
+// (one year by default) and used for both server and client authentication, the subject and the SANs are kept
+func SignCSR(csrPem []byte, caCert *x509.Certificate, caKey crypto.Signer, duration time.Duration) (*x509.Certificate, error) {
+	csr, err := parseCheckedCSR(csrPem)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := newLeafCertTemplate(csr, CertOptions{Duration: duration}.notAfter())
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, caCert, csr.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
+
+// GenerateCAFiles generates the key of the type and the self-signed CA certificate, which are written to
+// the files of key and cert of the certificate config, and to the file of CA as well if set
+func GenerateCAFiles(opts CertOptions, keyType string, out utils.Certificate) error {
+	key, err := GenerateKey(keyType, 0)
+	if err != nil {
+		return err
+	}
+	cert, err := CreateCACert(opts, key)
+	if err != nil {
+		return err
+	}
+	return writeCertFiles(out, key, cert, cert)
+}
+
+// GenerateCertFiles generates the key of the type and the certificate signed by the CA, whose key and cert are
+// loaded from the files of the CA config, the key, the cert and the CA cert are written to the files of the
+// certificate config, which can be used by utils.NewTLSConfigServer and utils.NewTLSConfigClient
+func GenerateCertFiles(opts CertOptions, keyType string, ca utils.Certificate, out utils.Certificate) error {
+	pair, err := tls.LoadX509KeyPair(ca.Cert, ca.Key)
+	if err != nil {
+		return err
+	}
+	caCert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return err
+	}
+	caKey, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return fmt.Errorf("type of key (%T) not supported", pair.PrivateKey)
+	}
+	key, err := GenerateKey(keyType, 0)
+	if err != nil {
+		return err
+	}
+	csr, err := CreateCSR(opts, key)
+	if err != nil {
+		return err
+	}
+	cert, err := SignCSR(csr, caCert, caKey, opts.Duration)
+	if err != nil {
+		return err
+	}
+	return writeCertFiles(out, key, cert, caCert)
+}
+
+// GenerateCSRFiles generates the key of the type and the certificate signing request, which are written to the files,
+// such as to get the certificate signed by the cloud when the device is provisioned
+func GenerateCSRFiles(opts CertOptions, keyType, keyFile, csrFile string) error {
+	key, err := GenerateKey(keyType, 0)
+	if err != nil {
+		return err
+	}
+	csr, err := CreateCSR(opts, key)
+	if err != nil {
+		return err
+	}
+	data, err := EncodeKeyPEM(key)
+	if err != nil {
+		return err
+	}
+	if err = writePEMFile(keyFile, data, 0600); err != nil {
+		return err
+	}
+	return writePEMFile(csrFile, csr, 0644)
+}
+
+func writeCertFiles(out utils.Certificate, key crypto.Signer, cert, ca *x509.Certificate) error {
+	data, err := EncodeKeyPEM(key)
+	if err != nil {
+		return err
+	}
+	if err = writePEMFile(out.Key, data, 0600); err != nil {
+		return err
+	}
+	if err = writePEMFile(out.Cert, EncodeCertPEM(cert), 0644); err != nil {
+		return err
+	}
+	if out.CA == "" {
+		return nil
+	}
+	return writePEMFile(out.CA, EncodeCertPEM(ca), 0644)
+}
+
+func writePEMFile(path string, data []byte, perm os.FileMode) error {
+	if path == "" {
+		return errors.New("path of pem file is empty")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, perm)
+}
@@ -0,0 +1,126 @@
+package security
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateKey(t *testing.T) {
+	for _, c := range []struct {
+		typ  string
+		bits int
+	}{{KeyTypeEC, 0}, {KeyTypeEC, 384}, {KeyTypeRSA, 1024}} {
+		key, err := GenerateKey(c.typ, c.bits)
+		assert.NoError(t, err)
+		data, err := EncodeKeyPEM(key)
+		assert.NoError(t, err)
+		parsed, err := DecodePEMKey(data)
+		assert.NoError(t, err)
+		assert.Equal(t, key, parsed.Key)
+	}
+	_, err := GenerateKey(KeyTypeEC, 128)
+	assert.EqualError(t, err, "curve of EC key (128) not supported")
+	_, err = GenerateKey("dsa", 0)
+	assert.EqualError(t, err, "type of key (dsa) not supported")
+}
+
+func TestSignCSR(t *testing.T) {
+	caKey, err := GenerateKey(KeyTypeEC, 0)
+	assert.NoError(t, err)
+	ca, err := CreateCACert(CertOptions{CommonName: "ca", Organization: []string{"baetyl"}}, caKey)
+	assert.NoError(t, err)
+	assert.True(t, ca.IsCA)
+	parsed, err := DecodePEMCertificates(EncodeCertPEM(ca))
+	assert.NoError(t, err)
+	assert.Equal(t, ca.Raw, parsed[0].Raw)
+
+	key, err := GenerateKey(KeyTypeRSA, 1024)
+	assert.NoError(t, err)
+	opts := CertOptions{
+		CommonName:  "node1",
+		DNSNames:    []string{"localhost", "baetyl-broker"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	csr, err := CreateCSR(opts, key)
+	assert.NoError(t, err)
+	req, err := ParsePemCSR(csr)
+	assert.NoError(t, err)
+	assert.Equal(t, "node1", req.Subject.CommonName)
+	assert.Equal(t, opts.DNSNames, req.DNSNames)
+
+	cert, err := SignCSR(csr, ca, caKey, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, "node1", cert.Subject.CommonName)
+	assert.Equal(t, opts.DNSNames, cert.DNSNames)
+	assert.True(t, cert.IPAddresses[0].Equal(net.ParseIP("127.0.0.1")))
+	assert.True(t, cert.NotAfter.Before(time.Now().Add(time.Hour+time.Minute)))
+	assert.NoError(t, cert.CheckSignatureFrom(ca))
+
+	_, err = SignCSR([]byte("xxx"), ca, caKey, 0)
+	assert.EqualError(t, err, "certificate signing request is not properly encoded")
+	certs, err := DecodePEMCertificates(csr)
+	assert.NoError(t, err)
+	assert.Empty(t, certs)
+}
+
+func TestGenerateCertFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", t.Name())
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ca := utils.Certificate{Key: filepath.Join(dir, "ca", "ca.key"), Cert: filepath.Join(dir, "ca", "ca.pem")}
+	assert.NoError(t, GenerateCAFiles(CertOptions{CommonName: "ca"}, KeyTypeRSA, ca))
+	server := utils.Certificate{
+		CA:   ca.Cert,
+		Key:  filepath.Join(dir, "server.key"),
+		Cert: filepath.Join(dir, "server.pem"),
+	}
+	assert.NoError(t, GenerateCertFiles(CertOptions{CommonName: "server", DNSNames: []string{"localhost"}}, KeyTypeEC, ca, server))
+	client := utils.Certificate{
+		CA:   filepath.Join(dir, "client", "ca.pem"),
+		Key:  filepath.Join(dir, "client", "client.key"),
+		Cert: filepath.Join(dir, "client", "client.pem"),
+		Name: "localhost",
+	}
+	assert.NoError(t, GenerateCertFiles(CertOptions{CommonName: "client"}, KeyTypeEC, ca, client))
+	info, err := os.Stat(client.Key)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// the files generated are used by the tls configs
+	sc, err := utils.NewTLSConfigServer(server)
+	assert.NoError(t, err)
+	cc, err := utils.NewTLSConfigClient(client)
+	assert.NoError(t, err)
+	cc.ServerName = client.Name
+	s, c := net.Pipe()
+	errs := make(chan error, 1)
+	go func() {
+		conn := tls.Server(s, sc)
+		errs <- conn.Handshake()
+		conn.Close()
+	}()
+	conn := tls.Client(c, cc)
+	assert.NoError(t, conn.Handshake())
+	assert.NoError(t, <-errs)
+	conn.Close()
+
+	keyFile, csrFile := filepath.Join(dir, "device.key"), filepath.Join(dir, "device.csr")
+	assert.NoError(t, GenerateCSRFiles(CertOptions{CommonName: "device"}, KeyTypeEC, keyFile, csrFile))
+	csr, err := ioutil.ReadFile(csrFile)
+	assert.NoError(t, err)
+	req, err := ParsePemCSR(csr)
+	assert.NoError(t, err)
+	assert.Equal(t, "device", req.Subject.CommonName)
+
+	assert.Error(t, GenerateCertFiles(CertOptions{}, KeyTypeEC, utils.Certificate{}, server))
+	assert.EqualError(t, GenerateCAFiles(CertOptions{}, KeyTypeEC, utils.Certificate{}), "path of pem file is empty")
+}
@@ -0,0 +1,23 @@
+package security
+
+import "time"
+
+// PKIConfig the config of local pki
+type PKIConfig struct {
+	CommonName   string        `yaml:"commonName" json:"commonName" default:"baetyl-ca"`
+	Organization []string      `yaml:"organization" json:"organization" default:"[\"baetyl\"]"`
+	RootTTL      time.Duration `yaml:"rootTTL" json:"rootTTL" default:"87600h"`
+	CertTTL      time.Duration `yaml:"certTTL" json:"certTTL" default:"8760h"`
+	KeyPrefix    string        `yaml:"keyPrefix" json:"keyPrefix" default:"security/pki/"` // the prefix of keys in kv store
+}
+
+// SecretConfig the config of secret store, the secrets are encrypted by the key in key file, which is generated
+// if missing, or derived from the passphrase if set (the key file keeps the salt then)
+type SecretConfig struct {
+	Backend    string `yaml:"backend" json:"backend" default:"file" validate:"regexp=^(file|kv)$"`
+	Path       string `yaml:"path" json:"path" default:"var/lib/baetyl/secrets"`      // the dir of secrets for file backend
+	KeyPrefix  string `yaml:"keyPrefix" json:"keyPrefix" default:"security/secrets/"` // the prefix of keys for kv backend
+	KeyFile    string `yaml:"keyFile" json:"keyFile" default:"var/lib/baetyl/secrets.key"`
+	Passphrase string `yaml:"passphrase" json:"passphrase"`
+	Iterations int    `yaml:"iterations" json:"iterations" default:"100000" validate:"min=1"`
+}
@@ -0,0 +1,136 @@
+package security
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+)
+
+// all sizes of keys
+const (
+	KeySize  = 32 // the size of AES-256 key
+	SaltSize = 16
+	// the iterations of PBKDF2 to derive the key from passphrase
+	DefaultIterations = 100000
+)
+
+// all errors of crypto
+var (
+	ErrKeySize         = errors.New("key size is invalid")
+	ErrDecryptFailed   = errors.New("failed to decrypt, the data is broken or the key is wrong")
+	ErrCiphertextShort = errors.New("ciphertext is too short")
+)
+
+// NewKey generates a new random key of KeySize
+func NewKey() ([]byte, error) {
+	return RandomBytes(KeySize)
+}
+
+// RandomBytes generates the random bytes, such as salt
+func RandomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// DeriveKey derives the key of KeySize from the passphrase and the salt by PBKDF2-HMAC-SHA256 (RFC 8018)
+func DeriveKey(passphrase, salt []byte, iterations int) []byte {
+	prf := hmac.New(sha256.New, passphrase)
+	size := prf.Size()
+	key := make([]byte, 0, KeySize)
+	u := make([]byte, size)
+	t := make([]byte, size)
+	var idx [4]byte
+	for block := uint32(1); len(key) < KeySize; block++ {
+		binary.BigEndian.PutUint32(idx[:], block)
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(idx[:])
+		u = prf.Sum(u[:0])
+		copy(t, u)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:KeySize]
+}
+
+// ExpandKey derives a sub key of KeySize for the purpose (info) from the key by HKDF-SHA256 (RFC 5869),
+// so that one master key protects the data of different purposes with different keys
+func ExpandKey(key, salt []byte, info string) []byte {
+	if salt == nil {
+		salt = make([]byte, sha256.Size)
+	}
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(key)
+	prk := extract.Sum(nil)
+
+	expand := hmac.New(sha256.New, prk)
+	var out, prev []byte
+	for i := byte(1); len(out) < KeySize; i++ {
+		expand.Reset()
+		expand.Write(prev)
+		expand.Write([]byte(info))
+		expand.Write([]byte{i})
+		prev = expand.Sum(nil)
+		out = append(out, prev...)
+	}
+	return out[:KeySize]
+}
+
+// Encrypt encrypts the plaintext by AES-GCM with a random nonce, the key must be 16, 24 or 32 bytes,
+// the additional data (optional) is authenticated but not encrypted, such as the name of data,
+// the output is the nonce followed by the ciphertext and the tag
+func Encrypt(key, plaintext, additional []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := RandomBytes(aead.NonceSize())
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+// Decrypt decrypts the output of Encrypt by the same key and additional data
+func Decrypt(key, ciphertext, additional []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aead.NonceSize()+aead.Overhead() {
+		return nil, ErrCiphertextShort
+	}
+	n := aead.NonceSize()
+	plaintext, err := aead.Open(nil, ciphertext[:n], ciphertext[n:], additional)
+	if err != nil {
+		return nil, ErrDecryptFailed
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	switch len(key) {
+	case 16, 24, 32:
+	default:
+		return nil, ErrKeySize
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,162 @@
+package security
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/baetyl/baetyl-go/kv"
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeriveKey(t *testing.T) {
+	// RFC 7914, section 11
+	key := DeriveKey([]byte("passwd"), []byte("salt"), 1)
+	assert.Equal(t, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc", hex.EncodeToString(key))
+
+	// RFC 5869, test case 1
+	ikm, _ := hex.DecodeString("0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b")
+	salt, _ := hex.DecodeString("000102030405060708090a0b0c")
+	info, _ := hex.DecodeString("f0f1f2f3f4f5f6f7f8f9")
+	key = ExpandKey(ikm, salt, string(info))
+	assert.Equal(t, "3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf", hex.EncodeToString(key))
+	assert.NotEqual(t, ExpandKey(ikm, nil, "a"), ExpandKey(ikm, nil, "b"))
+}
+
+func TestEncrypt(t *testing.T) {
+	key, err := NewKey()
+	assert.NoError(t, err)
+	assert.Len(t, key, KeySize)
+
+	plaintext := []byte("the password of device")
+	c1, err := Encrypt(key, plaintext, []byte("name"))
+	assert.NoError(t, err)
+	c2, err := Encrypt(key, plaintext, []byte("name"))
+	assert.NoError(t, err)
+	assert.NotEqual(t, c1, c2)
+
+	res, err := Decrypt(key, c1, []byte("name"))
+	assert.NoError(t, err)
+	assert.Equal(t, plaintext, res)
+
+	_, err = Decrypt(key, c1, []byte("other"))
+	assert.Equal(t, ErrDecryptFailed, err)
+	other, _ := NewKey()
+	_, err = Decrypt(other, c1, []byte("name"))
+	assert.Equal(t, ErrDecryptFailed, err)
+	c1[len(c1)-1] ^= 0xff
+	_, err = Decrypt(key, c1, []byte("name"))
+	assert.Equal(t, ErrDecryptFailed, err)
+	_, err = Decrypt(key, c1[:10], nil)
+	assert.Equal(t, ErrCiphertextShort, err)
+
+	_, err = Encrypt(key[:10], plaintext, nil)
+	assert.Equal(t, ErrKeySize, err)
+	_, err = Decrypt(key[:10], c2, nil)
+	assert.Equal(t, ErrKeySize, err)
+
+	// AES-128
+	c, err := Encrypt(key[:16], nil, nil)
+	assert.NoError(t, err)
+	res, err = Decrypt(key[:16], c, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, res)
+}
+
+func testSecretStore(t *testing.T, s *SecretStore) {
+	_, err := s.Get("a")
+	assert.Equal(t, kv.ErrNotFound, err)
+	assert.Equal(t, kv.ErrKeyEmpty, s.Set("", nil))
+
+	assert.NoError(t, s.Set("mqtt/password", []byte("secret1")))
+	assert.NoError(t, s.Set("../token", []byte("secret2")))
+	v, err := s.Get("mqtt/password")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret1", string(v))
+	v, err = s.Get("../token")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret2", string(v))
+
+	names, err := s.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"../token", "mqtt/password"}, names)
+
+	// the value is encrypted at rest and bound to the name
+	raw, err := s.backend.Get(s.cfg.KeyPrefix + "mqtt/password")
+	assert.NoError(t, err)
+	assert.NotContains(t, string(raw), "secret1")
+	assert.NoError(t, s.backend.Set(s.cfg.KeyPrefix+"moved", raw))
+	_, err = s.Get("moved")
+	assert.Equal(t, ErrDecryptFailed, err)
+
+	assert.NoError(t, s.Delete("mqtt/password"))
+	assert.NoError(t, s.Delete("mqtt/password"))
+	_, err = s.Get("mqtt/password")
+	assert.Equal(t, kv.ErrNotFound, err)
+}
+
+func TestSecretStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", t.Name())
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var cfg SecretConfig
+	assert.NoError(t, utils.SetDefaults(&cfg))
+	assert.Equal(t, "file", cfg.Backend)
+	cfg.Path = filepath.Join(dir, "secrets")
+	cfg.KeyFile = filepath.Join(dir, "keys", "secrets.key")
+	s, err := NewSecretStore(cfg, nil)
+	assert.NoError(t, err)
+	testSecretStore(t, s)
+
+	info, err := os.Stat(cfg.KeyFile)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	files, err := ioutil.ReadDir(cfg.Path)
+	assert.NoError(t, err)
+	assert.Len(t, files, 2)
+	for _, f := range files {
+		assert.Equal(t, os.FileMode(0600), f.Mode().Perm())
+	}
+
+	// reopen with the same key file
+	assert.NoError(t, s.Set("a", []byte("b")))
+	s, err = NewSecretStore(cfg, nil)
+	assert.NoError(t, err)
+	v, err := s.Get("a")
+	assert.NoError(t, err)
+	assert.Equal(t, "b", string(v))
+
+	// the key file is invalid
+	assert.NoError(t, ioutil.WriteFile(cfg.KeyFile, []byte("short"), 0600))
+	_, err = NewSecretStore(cfg, nil)
+	assert.EqualError(t, err, "key file ("+cfg.KeyFile+") is invalid")
+
+	// kv backend with passphrase
+	store, closer := newTestStore(t)
+	defer closer()
+	cfg.Backend = "kv"
+	cfg.KeyFile = filepath.Join(dir, "secrets.salt")
+	cfg.Passphrase = "passphrase"
+	cfg.Iterations = 1000
+	s, err = NewSecretStore(cfg, store)
+	assert.NoError(t, err)
+	testSecretStore(t, s)
+	assert.NoError(t, s.Set("a", []byte("b")))
+
+	// the passphrase is wrong
+	cfg.Passphrase = "wrong"
+	s, err = NewSecretStore(cfg, store)
+	assert.NoError(t, err)
+	_, err = s.Get("a")
+	assert.Equal(t, ErrDecryptFailed, err)
+
+	_, err = NewSecretStore(cfg, nil)
+	assert.EqualError(t, err, "kv store is required by backend (kv)")
+	cfg.Backend = "tpm"
+	_, err = NewSecretStore(cfg, store)
+	assert.EqualError(t, err, "backend (tpm) not supported")
+}
@@ -31,8 +31,9 @@ func GenerateCSR(cfg Config, key interface{}) ([]byte, error) {
 func genCSRTemplate(cfg Config) (*x509.CertificateRequest, error) {
 	return &x509.CertificateRequest{
 		Subject: pkix.Name{
-			Organization: cfg.organization,
-			CommonName:   cfg.commonName,
+			Organization:       cfg.organization,
+			OrganizationalUnit: cfg.organizationalUnit,
+			CommonName:         cfg.commonName,
 		},
 		DNSNames:    cfg.altNames.dnsNames,
 		IPAddresses: cfg.altNames.ips,
@@ -53,6 +54,19 @@ func ParsePemCSR(csrPem []byte) (*x509.CertificateRequest, error) {
 	return csr, nil
 }
 
+// parseCheckedCSR parses the PEM-encoded certificate signing request and checks its signature
+func parseCheckedCSR(csrPem []byte) (*x509.CertificateRequest, error) {
+	csr, err := ParsePemCSR(csrPem)
+	if err != nil {
+		return nil, err
+	}
+	err = csr.CheckSignature()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check signature of certificate signing request: %s", err.Error())
+	}
+	return csr, nil
+}
+
 func sigType(privateKey interface{}) x509.SignatureAlgorithm {
 	// customize the signature for RSA keys, depending on the key size
 	if privateKey, ok := privateKey.(*rsa.PrivateKey); ok {
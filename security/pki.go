@@ -0,0 +1,251 @@
+package security
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/baetyl/baetyl-go/kv"
+	"github.com/baetyl/baetyl-go/log"
+)
+
+// ErrCertNotFound the certificate is not found
+var ErrCertNotFound = errors.New("certificate not found")
+
+// CertRequest the request to issue a certificate, the ttl of pki config is used if ttl is 0
+type CertRequest struct {
+	CommonName   string
+	Organization []string
+	DNSNames     []string
+	IPAddresses  []net.IP
+	TTL          time.Duration
+}
+
+// CertPem the pem-encoded certificate and its private key (only returned when issued),
+// the serial is the hex string of the serial number
+type CertPem struct {
+	Serial string
+	Crt    []byte
+	Key    []byte
+}
+
+// PKI the public key infrastructure which issues, signs and revokes certificates
+type PKI interface {
+	// RootCert returns the pem-encoded root certificate
+	RootCert() []byte
+	// IssueCert generates a new private key and issues a certificate for it
+	IssueCert(req CertRequest) (*CertPem, error)
+	// SignCSR signs the pem-encoded certificate sign request, the ttl of pki config is used if ttl is 0
+	SignCSR(csr []byte, ttl time.Duration) (*CertPem, error)
+	// GetCert returns the pem-encoded certificate by serial
+	GetCert(serial string) ([]byte, error)
+	// RevokeCert revokes the certificate by serial
+	RevokeCert(serial string) error
+	// IsRevoked checks whether the certificate is revoked
+	IsRevoked(serial string) (bool, error)
+}
+
+// LocalPKI the pki backed by a local ca, the ca and all certificates issued are stored in the kv store
+type LocalPKI struct {
+	cfg     PKIConfig
+	store   kv.Driver
+	rootCrt *x509.Certificate
+	rootKey crypto.Signer
+	rootPem []byte
+	log     *log.Logger
+}
+
+// NewLocalPKI creates a new local pki, the root ca is loaded from kv store or created if not found
+func NewLocalPKI(cfg PKIConfig, store kv.Driver) (*LocalPKI, error) {
+	p := &LocalPKI{
+		cfg:   cfg,
+		store: store,
+		log:   log.With(log.Any("security", "pki")),
+	}
+	err := p.loadRoot()
+	if err == kv.ErrNotFound {
+		err = p.createRoot()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// RootCert returns the pem-encoded root certificate
+func (p *LocalPKI) RootCert() []byte {
+	return p.rootPem
+}
+
+// IssueCert generates a new private key and issues a certificate for it
+func (p *LocalPKI) IssueCert(req CertRequest) (*CertPem, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	csr := &x509.CertificateRequest{
+		Subject: pkix.Name{
+			CommonName:   req.CommonName,
+			Organization: req.Organization,
+		},
+		DNSNames:    req.DNSNames,
+		IPAddresses: req.IPAddresses,
+	}
+	res, err := p.sign(csr, key.Public(), req.TTL)
+	if err != nil {
+		return nil, err
+	}
+	res.Key = pem.EncodeToMemory(&pem.Block{Type: ecPrivateKeyBlockType, Bytes: keyDER})
+	return res, nil
+}
+
+// SignCSR signs the pem-encoded certificate sign request, the ttl of pki config is used if ttl is 0
+func (p *LocalPKI) SignCSR(csrPem []byte, ttl time.Duration) (*CertPem, error) {
+	csr, err := parseCheckedCSR(csrPem)
+	if err != nil {
+		return nil, err
+	}
+	return p.sign(csr, csr.PublicKey, ttl)
+}
+
+// GetCert returns the pem-encoded certificate by serial
+func (p *LocalPKI) GetCert(serial string) ([]byte, error) {
+	crt, err := p.store.Get(p.cfg.KeyPrefix + "cert/" + serial)
+	if err == kv.ErrNotFound {
+		return nil, ErrCertNotFound
+	}
+	return crt, err
+}
+
+// RevokeCert revokes the certificate by serial, the certificate is kept and marked as revoked
+func (p *LocalPKI) RevokeCert(serial string) error {
+	_, err := p.GetCert(serial)
+	if err != nil {
+		return err
+	}
+	err = p.store.Set(p.cfg.KeyPrefix+"revoked/"+serial, []byte(time.Now().UTC().Format(time.RFC3339)))
+	if err != nil {
+		return err
+	}
+	p.log.Info("certificate is revoked", log.Any("serial", serial))
+	return nil
+}
+
+// IsRevoked checks whether the certificate is revoked
+func (p *LocalPKI) IsRevoked(serial string) (bool, error) {
+	_, err := p.store.Get(p.cfg.KeyPrefix + "revoked/" + serial)
+	if err == kv.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (p *LocalPKI) sign(csr *x509.CertificateRequest, pub crypto.PublicKey, ttl time.Duration) (*CertPem, error) {
+	if ttl <= 0 {
+		ttl = p.cfg.CertTTL
+	}
+	notAfter := time.Now().Add(ttl)
+	// the certificate should not outlive the root
+	if notAfter.After(p.rootCrt.NotAfter) {
+		notAfter = p.rootCrt.NotAfter
+	}
+	tmpl, err := newLeafCertTemplate(csr, notAfter)
+	if err != nil {
+		return nil, err
+	}
+	crtDER, err := x509.CreateCertificate(rand.Reader, tmpl, p.rootCrt, pub, p.rootKey)
+	if err != nil {
+		return nil, err
+	}
+	res := &CertPem{
+		Serial: tmpl.SerialNumber.Text(16),
+		Crt:    pem.EncodeToMemory(&pem.Block{Type: certificateBlockType, Bytes: crtDER}),
+	}
+	err = p.store.Set(p.cfg.KeyPrefix+"cert/"+res.Serial, res.Crt)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (p *LocalPKI) loadRoot() error {
+	crtPem, err := p.store.Get(p.cfg.KeyPrefix + "root/crt")
+	if err != nil {
+		return err
+	}
+	keyPem, err := p.store.Get(p.cfg.KeyPrefix + "root/key")
+	if err != nil {
+		return err
+	}
+	crts, err := DecodePEMCertificates(crtPem)
+	if err != nil {
+		return err
+	}
+	if len(crts) == 0 {
+		return errors.New("no root certificate found")
+	}
+	key, err := DecodePEMKey(keyPem)
+	if err != nil {
+		return err
+	}
+	if !matchCertificateAndKey(key, crts[0]) {
+		return errors.New("public and private key pair of root do not match")
+	}
+	p.rootCrt = crts[0]
+	p.rootKey = key.Key.(crypto.Signer)
+	p.rootPem = crtPem
+	return nil
+}
+
+func (p *LocalPKI) createRoot() error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	tmpl, err := newCACertTemplate(pkix.Name{CommonName: p.cfg.CommonName, Organization: p.cfg.Organization}, time.Now().Add(p.cfg.RootTTL))
+	if err != nil {
+		return err
+	}
+	crtDER, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		return err
+	}
+	crt, err := x509.ParseCertificate(crtDER)
+	if err != nil {
+		return err
+	}
+	crtPem := pem.EncodeToMemory(&pem.Block{Type: certificateBlockType, Bytes: crtDER})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: ecPrivateKeyBlockType, Bytes: keyDER})
+	// save the key first, the root is loaded only if both exist
+	err = p.store.Set(p.cfg.KeyPrefix+"root/key", keyPem)
+	if err != nil {
+		return err
+	}
+	err = p.store.Set(p.cfg.KeyPrefix+"root/crt", crtPem)
+	if err != nil {
+		return err
+	}
+	p.rootCrt = crt
+	p.rootKey = key
+	p.rootPem = crtPem
+	p.log.Info("root certificate is created", log.Any("serial", tmpl.SerialNumber.Text(16)))
+	return nil
+}
@@ -0,0 +1,113 @@
+package security
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/baetyl/baetyl-go/kv"
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestStore(t *testing.T) (kv.Driver, func()) {
+	dir, err := ioutil.TempDir("", t.Name())
+	assert.NoError(t, err)
+	var cfg kv.Config
+	assert.NoError(t, utils.SetDefaults(&cfg))
+	cfg.Path = filepath.Join(dir, "kv.db")
+	store, err := kv.New(cfg)
+	assert.NoError(t, err)
+	return store, func() {
+		store.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func verify(t *testing.T, root, crt []byte) *x509.Certificate {
+	pool, err := CertPoolFromPEM(root)
+	assert.NoError(t, err)
+	crts, err := DecodePEMCertificates(crt)
+	assert.NoError(t, err)
+	assert.Len(t, crts, 1)
+	_, err = crts[0].Verify(x509.VerifyOptions{Roots: pool, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}})
+	assert.NoError(t, err)
+	return crts[0]
+}
+
+func TestLocalPKI(t *testing.T) {
+	store, clean := newTestStore(t)
+	defer clean()
+
+	var cfg PKIConfig
+	assert.NoError(t, utils.SetDefaults(&cfg))
+	assert.Equal(t, []string{"baetyl"}, cfg.Organization)
+	p, err := NewLocalPKI(cfg, store)
+	assert.NoError(t, err)
+	var _ PKI = p
+	roots, err := DecodePEMCertificates(p.RootCert())
+	assert.NoError(t, err)
+	assert.True(t, roots[0].IsCA)
+	assert.Equal(t, "baetyl-ca", roots[0].Subject.CommonName)
+
+	// issue
+	res, err := p.IssueCert(CertRequest{
+		CommonName:  "svc",
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		TTL:         time.Hour,
+	})
+	assert.NoError(t, err)
+	crt := verify(t, p.RootCert(), res.Crt)
+	assert.Equal(t, "svc", crt.Subject.CommonName)
+	assert.Equal(t, []string{"localhost"}, crt.DNSNames)
+	assert.Equal(t, res.Serial, crt.SerialNumber.Text(16))
+	assert.WithinDuration(t, time.Now().Add(time.Hour), crt.NotAfter, time.Minute)
+	key, err := DecodePEMKey(res.Key)
+	assert.NoError(t, err)
+	assert.True(t, matchCertificateAndKey(key, crt))
+
+	// sign csr
+	dkey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	csr, err := GenerateCSR(Config{commonName: "device"}, dkey)
+	assert.NoError(t, err)
+	res2, err := p.SignCSR(csr, 0)
+	assert.NoError(t, err)
+	assert.Nil(t, res2.Key)
+	crt = verify(t, p.RootCert(), res2.Crt)
+	assert.Equal(t, "device", crt.Subject.CommonName)
+	assert.WithinDuration(t, time.Now().Add(cfg.CertTTL), crt.NotAfter, time.Minute)
+	_, err = p.SignCSR([]byte("csr"), 0)
+	assert.Error(t, err)
+
+	// get and revoke
+	data, err := p.GetCert(res.Serial)
+	assert.NoError(t, err)
+	assert.Equal(t, res.Crt, data)
+	_, err = p.GetCert("1")
+	assert.Equal(t, ErrCertNotFound, err)
+	revoked, err := p.IsRevoked(res.Serial)
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+	assert.NoError(t, p.RevokeCert(res.Serial))
+	revoked, err = p.IsRevoked(res.Serial)
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+	assert.Equal(t, ErrCertNotFound, p.RevokeCert("1"))
+
+	// the root is loaded from store
+	p2, err := NewLocalPKI(cfg, store)
+	assert.NoError(t, err)
+	assert.Equal(t, p.RootCert(), p2.RootCert())
+	res3, err := p2.IssueCert(CertRequest{CommonName: "svc2"})
+	assert.NoError(t, err)
+	verify(t, p.RootCert(), res3.Crt)
+}
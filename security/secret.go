@@ -0,0 +1,236 @@
+package security
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/baetyl/baetyl-go/kv"
+)
+
+// SecretBackend the backend which stores the secrets encrypted, such as kv.Driver
+type SecretBackend interface {
+	Get(key string) ([]byte, error)
+	Set(key string, value []byte) error
+	Del(key string) error
+	List(prefix string) ([]*kv.KV, error)
+}
+
+// SecretStore the store which encrypts the secrets at rest by AES-GCM, the name of secret is authenticated as well,
+// so that the encrypted value can't be moved to another name
+type SecretStore struct {
+	cfg     SecretConfig
+	key     []byte
+	backend SecretBackend
+}
+
+// NewSecretStore creates a new secret store, the kv store is required if the backend is kv
+func NewSecretStore(cfg SecretConfig, store kv.Driver) (*SecretStore, error) {
+	var backend SecretBackend
+	switch cfg.Backend {
+	case "", "file":
+		b, err := NewFileBackend(cfg.Path)
+		if err != nil {
+			return nil, err
+		}
+		backend = b
+	case "kv":
+		if store == nil {
+			return nil, fmt.Errorf("kv store is required by backend (%s)", cfg.Backend)
+		}
+		backend = store
+	default:
+		return nil, fmt.Errorf("backend (%s) not supported", cfg.Backend)
+	}
+	key, err := loadKey(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &SecretStore{
+		cfg:     cfg,
+		key:     ExpandKey(key, nil, "baetyl-secret"),
+		backend: backend,
+	}, nil
+}
+
+// loadKey loads the key from the key file, or derives it from the passphrase and the salt in key file,
+// the key file is generated if missing
+func loadKey(cfg SecretConfig) ([]byte, error) {
+	size := KeySize
+	if cfg.Passphrase != "" {
+		size = SaltSize
+	}
+	data, err := ioutil.ReadFile(cfg.KeyFile)
+	if os.IsNotExist(err) {
+		data, err = RandomBytes(size)
+		if err != nil {
+			return nil, err
+		}
+		if err = os.MkdirAll(filepath.Dir(cfg.KeyFile), 0700); err != nil {
+			return nil, err
+		}
+		err = writeFileAtomic(cfg.KeyFile, data, 0600)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != size {
+		return nil, fmt.Errorf("key file (%s) is invalid", cfg.KeyFile)
+	}
+	if cfg.Passphrase != "" {
+		return DeriveKey([]byte(cfg.Passphrase), data, cfg.Iterations), nil
+	}
+	return data, nil
+}
+
+// Get returns the secret decrypted, returns kv.ErrNotFound if not found
+func (s *SecretStore) Get(name string) ([]byte, error) {
+	data, err := s.backend.Get(s.cfg.KeyPrefix + name)
+	if err != nil {
+		return nil, err
+	}
+	return Decrypt(s.key, data, []byte(name))
+}
+
+// Set encrypts and sets the secret
+func (s *SecretStore) Set(name string, value []byte) error {
+	if name == "" {
+		return kv.ErrKeyEmpty
+	}
+	data, err := Encrypt(s.key, value, []byte(name))
+	if err != nil {
+		return err
+	}
+	return s.backend.Set(s.cfg.KeyPrefix+name, data)
+}
+
+// Delete deletes the secret, no error if not found
+func (s *SecretStore) Delete(name string) error {
+	return s.backend.Del(s.cfg.KeyPrefix + name)
+}
+
+// List returns the names of all secrets, sorted by name
+func (s *SecretStore) List() ([]string, error) {
+	kvs, err := s.backend.List(s.cfg.KeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(kvs))
+	for _, v := range kvs {
+		names = append(names, strings.TrimPrefix(v.Key, s.cfg.KeyPrefix))
+	}
+	return names, nil
+}
+
+// FileBackend the backend which stores every value in a file of the dir, only readable by owner,
+// the name of file is the key encoded by base64 (url-safe)
+type FileBackend struct {
+	dir string
+	mu  sync.RWMutex
+}
+
+// NewFileBackend creates a new file backend, the dir is created if missing
+func NewFileBackend(dir string) (*FileBackend, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileBackend{dir: dir}, nil
+}
+
+func (b *FileBackend) path(key string) string {
+	return filepath.Join(b.dir, base64.RawURLEncoding.EncodeToString([]byte(key)))
+}
+
+// Get returns the value of the key, returns kv.ErrNotFound if not found
+func (b *FileBackend) Get(key string) ([]byte, error) {
+	if key == "" {
+		return nil, kv.ErrKeyEmpty
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	data, err := ioutil.ReadFile(b.path(key))
+	if os.IsNotExist(err) {
+		return nil, kv.ErrNotFound
+	}
+	return data, err
+}
+
+// Set sets the value of the key, the file is replaced atomically
+func (b *FileBackend) Set(key string, value []byte) error {
+	if key == "" {
+		return kv.ErrKeyEmpty
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return writeFileAtomic(b.path(key), value, 0600)
+}
+
+// Del deletes the key, no error if not found
+func (b *FileBackend) Del(key string) error {
+	if key == "" {
+		return kv.ErrKeyEmpty
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	err := os.Remove(b.path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// List returns all pairs whose key has the prefix, sorted by key
+func (b *FileBackend) List(prefix string) ([]*kv.KV, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	infos, err := ioutil.ReadDir(b.dir)
+	if err != nil {
+		return nil, err
+	}
+	var kvs []*kv.KV
+	for _, info := range infos {
+		if info.IsDir() {
+			continue
+		}
+		// the temporary files are skipped since the dot is not in the alphabet
+		key, err := base64.RawURLEncoding.DecodeString(info.Name())
+		if err != nil || !strings.HasPrefix(string(key), prefix) {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(b.dir, info.Name()))
+		if err != nil {
+			return nil, err
+		}
+		kvs = append(kvs, &kv.KV{Key: string(key), Value: data})
+	}
+	sort.Slice(kvs, func(i, j int) bool { return kvs[i].Key < kvs[j].Key })
+	return kvs, nil
+}
+
+// writeFileAtomic writes the data into a temporary file and renames it, so that the file is never partially written
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), perm)
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,203 @@
+package v1
+
+import "time"
+
+// all application types
+const (
+	AppTypeContainer = "container"
+	AppTypeFunction  = "function"
+	AppTypeNative    = "native"
+)
+
+// all labels set on the objects (such as containers) created for applications
+const (
+	LabelAppName     = "baetyl-app-name"
+	LabelAppVersion  = "baetyl-app-version"
+	LabelServiceName = "baetyl-service-name"
+)
+
+// Application application info
+type Application struct {
+	Name              string            `json:"name,omitempty" yaml:"name,omitempty"`
+	Namespace         string            `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Type              string            `json:"type,omitempty" yaml:"type,omitempty" default:"container"`
+	Labels            map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Selector          string            `json:"selector,omitempty" yaml:"selector,omitempty"`
+	Version           string            `json:"version,omitempty" yaml:"version,omitempty"`
+	CreationTimestamp time.Time         `json:"createTime,omitempty" yaml:"createTime,omitempty"`
+	Description       string            `json:"description,omitempty" yaml:"description,omitempty"`
+	Services          []Service         `json:"services,omitempty" yaml:"services,omitempty"`
+	Volumes           []Volume          `json:"volumes,omitempty" yaml:"volumes,omitempty"`
+}
+
+// Service service config
+type Service struct {
+	Name         string            `json:"name,omitempty" yaml:"name,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Image        string            `json:"image,omitempty" yaml:"image,omitempty"`
+	Replica      int               `json:"replica,omitempty" yaml:"replica,omitempty" default:"1"`
+	Command      []string          `json:"command,omitempty" yaml:"command,omitempty"`
+	Args         []string          `json:"args,omitempty" yaml:"args,omitempty"`
+	Env          []Environment     `json:"env,omitempty" yaml:"env,omitempty"`
+	Ports        []ContainerPort   `json:"ports,omitempty" yaml:"ports,omitempty"`
+	VolumeMounts []VolumeMount     `json:"volumeMounts,omitempty" yaml:"volumeMounts,omitempty"`
+	Devices      []Device          `json:"devices,omitempty" yaml:"devices,omitempty"`
+	Resources    *Resources        `json:"resources,omitempty" yaml:"resources,omitempty"`
+	Restart      *RestartPolicy    `json:"restart,omitempty" yaml:"restart,omitempty"`
+	Runtime      string            `json:"runtime,omitempty" yaml:"runtime,omitempty"`
+	Functions    []Function        `json:"functions,omitempty" yaml:"functions,omitempty"`
+}
+
+// Environment environment variable
+type Environment struct {
+	Name  string `json:"name,omitempty" yaml:"name,omitempty"`
+	Value string `json:"value,omitempty" yaml:"value,omitempty"`
+}
+
+// ContainerPort port mapping of container
+type ContainerPort struct {
+	HostPort      int32  `json:"hostPort,omitempty" yaml:"hostPort,omitempty"`
+	ContainerPort int32  `json:"containerPort,omitempty" yaml:"containerPort,omitempty"`
+	HostIP        string `json:"hostIP,omitempty" yaml:"hostIP,omitempty"`
+	Protocol      string `json:"protocol,omitempty" yaml:"protocol,omitempty" default:"TCP"`
+}
+
+// VolumeMount the mount of volume in service
+type VolumeMount struct {
+	Name      string `json:"name,omitempty" yaml:"name,omitempty"`
+	MountPath string `json:"mountPath,omitempty" yaml:"mountPath,omitempty"`
+	ReadOnly  bool   `json:"readOnly,omitempty" yaml:"readOnly,omitempty"`
+}
+
+// Device the host device mapped into service
+type Device struct {
+	DevicePath  string `json:"devicePath,omitempty" yaml:"devicePath,omitempty"`
+	Permissions string `json:"permissions,omitempty" yaml:"permissions,omitempty" default:"mrw"`
+}
+
+// Resources the resource quantities of service, such as cpu: 500m, memory: 128Mi
+type Resources struct {
+	Limits   map[string]string `json:"limits,omitempty" yaml:"limits,omitempty"`
+	Requests map[string]string `json:"requests,omitempty" yaml:"requests,omitempty"`
+}
+
+// RestartPolicy the restart policy of service
+type RestartPolicy struct {
+	Policy  string        `json:"policy,omitempty" yaml:"policy,omitempty" default:"always"`
+	Retries int           `json:"retries,omitempty" yaml:"retries,omitempty"`
+	Backoff time.Duration `json:"backoff,omitempty" yaml:"backoff,omitempty"`
+}
+
+// Function function config of function service
+type Function struct {
+	Name    string `json:"name,omitempty" yaml:"name,omitempty"`
+	Handler string `json:"handler,omitempty" yaml:"handler,omitempty"`
+	CodeDir string `json:"codedir,omitempty" yaml:"codedir,omitempty"`
+}
+
+// Volume volume config
+type Volume struct {
+	Name         string `json:"name,omitempty" yaml:"name,omitempty"`
+	VolumeSource `json:",inline" yaml:",inline"`
+}
+
+// VolumeSource the source of volume, only one of them can be set
+type VolumeSource struct {
+	HostPath *HostPathVolumeSource `json:"hostPath,omitempty" yaml:"hostPath,omitempty"`
+	Config   *ObjectReference      `json:"config,omitempty" yaml:"config,omitempty"`
+	Secret   *ObjectReference      `json:"secret,omitempty" yaml:"secret,omitempty"`
+}
+
+// HostPathVolumeSource the volume from host path
+type HostPathVolumeSource struct {
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+}
+
+// ObjectReference the reference to configuration or secret
+type ObjectReference struct {
+	Name    string `json:"name,omitempty" yaml:"name,omitempty"`
+	Version string `json:"version,omitempty" yaml:"version,omitempty"`
+}
+
+// DeepCopy creates a deep copy of application
+func (in *Application) DeepCopy() *Application {
+	if in == nil {
+		return nil
+	}
+	out := new(Application)
+	*out = *in
+	out.Labels = copyStringMap(in.Labels)
+	if in.Services != nil {
+		out.Services = make([]Service, len(in.Services))
+		for i := range in.Services {
+			out.Services[i] = *in.Services[i].DeepCopy()
+		}
+	}
+	if in.Volumes != nil {
+		out.Volumes = make([]Volume, len(in.Volumes))
+		for i := range in.Volumes {
+			out.Volumes[i] = *in.Volumes[i].DeepCopy()
+		}
+	}
+	return out
+}
+
+// DeepCopy creates a deep copy of service
+func (in *Service) DeepCopy() *Service {
+	if in == nil {
+		return nil
+	}
+	out := new(Service)
+	*out = *in
+	out.Labels = copyStringMap(in.Labels)
+	out.Command = copyStrings(in.Command)
+	out.Args = copyStrings(in.Args)
+	if in.Env != nil {
+		out.Env = append([]Environment{}, in.Env...)
+	}
+	if in.Ports != nil {
+		out.Ports = append([]ContainerPort{}, in.Ports...)
+	}
+	if in.VolumeMounts != nil {
+		out.VolumeMounts = append([]VolumeMount{}, in.VolumeMounts...)
+	}
+	if in.Devices != nil {
+		out.Devices = append([]Device{}, in.Devices...)
+	}
+	if in.Functions != nil {
+		out.Functions = append([]Function{}, in.Functions...)
+	}
+	if in.Resources != nil {
+		out.Resources = &Resources{
+			Limits:   copyStringMap(in.Resources.Limits),
+			Requests: copyStringMap(in.Resources.Requests),
+		}
+	}
+	if in.Restart != nil {
+		r := *in.Restart
+		out.Restart = &r
+	}
+	return out
+}
+
+// DeepCopy creates a deep copy of volume
+func (in *Volume) DeepCopy() *Volume {
+	if in == nil {
+		return nil
+	}
+	out := new(Volume)
+	*out = *in
+	if in.HostPath != nil {
+		hp := *in.HostPath
+		out.HostPath = &hp
+	}
+	if in.Config != nil {
+		c := *in.Config
+		out.Config = &c
+	}
+	if in.Secret != nil {
+		s := *in.Secret
+		out.Secret = &s
+	}
+	return out
+}
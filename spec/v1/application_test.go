@@ -0,0 +1,87 @@
+package v1
+
+import (
+	"testing"
+
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+const testApp = `
+name: app1
+namespace: default
+labels:
+  a: b
+services:
+- name: svc1
+  image: hub.baidubce.com/baetyl/baetyl-broker:v2
+  ports:
+  - hostPort: 1883
+    containerPort: 1883
+  volumeMounts:
+  - name: conf
+    mountPath: /etc/baetyl
+    readOnly: true
+  resources:
+    limits:
+      cpu: 500m
+      memory: 128Mi
+volumes:
+- name: conf
+  config:
+    name: conf1
+    version: "12"
+`
+
+func TestApplication(t *testing.T) {
+	var app Application
+	err := utils.UnmarshalYAML([]byte(testApp), &app)
+	assert.NoError(t, err)
+	assert.Equal(t, AppTypeContainer, app.Type)
+	assert.Len(t, app.Services, 1)
+	assert.Equal(t, 1, app.Services[0].Replica)
+	assert.Equal(t, "TCP", app.Services[0].Ports[0].Protocol)
+	assert.Equal(t, "500m", app.Services[0].Resources.Limits["cpu"])
+	assert.Equal(t, &ObjectReference{Name: "conf1", Version: "12"}, app.Volumes[0].Config)
+	assert.Nil(t, app.Volumes[0].HostPath)
+
+	cp := app.DeepCopy()
+	assert.Equal(t, &app, cp)
+	cp.Labels["a"] = "c"
+	cp.Services[0].Resources.Limits["cpu"] = "1"
+	cp.Services[0].Ports[0].HostPort = 8883
+	cp.Volumes[0].Config.Version = "13"
+	assert.Equal(t, "b", app.Labels["a"])
+	assert.Equal(t, "500m", app.Services[0].Resources.Limits["cpu"])
+	assert.Equal(t, int32(1883), app.Services[0].Ports[0].HostPort)
+	assert.Equal(t, "12", app.Volumes[0].Config.Version)
+
+	var nilApp *Application
+	assert.Nil(t, nilApp.DeepCopy())
+}
+
+func TestConfigurationAndSecret(t *testing.T) {
+	cfg := &Configuration{Name: "c", Data: map[string]string{"service.yml": "a: b"}}
+	cp := cfg.DeepCopy()
+	cp.Data["service.yml"] = "a: c"
+	assert.Equal(t, "a: b", cfg.Data["service.yml"])
+
+	sec := &Secret{Name: "s", Data: map[string][]byte{"key": []byte("abc")}}
+	scp := sec.DeepCopy()
+	scp.Data["key"][0] = 'x'
+	assert.Equal(t, "abc", string(sec.Data["key"]))
+}
+
+func TestNode(t *testing.T) {
+	node := &Node{
+		Name:   "n",
+		Report: Report{"apps": []interface{}{map[string]interface{}{"name": "a", "version": "1"}}},
+		Desire: Desire{"apps": []interface{}{map[interface{}]interface{}{"name": "a", "version": "2"}}},
+	}
+	cp := node.DeepCopy()
+	assert.Equal(t, node, cp)
+	cp.Report["apps"].([]interface{})[0].(map[string]interface{})["version"] = "3"
+	cp.Desire["apps"].([]interface{})[0].(map[interface{}]interface{})["version"] = "3"
+	assert.Equal(t, "1", node.Report["apps"].([]interface{})[0].(map[string]interface{})["version"])
+	assert.Equal(t, "2", node.Desire["apps"].([]interface{})[0].(map[interface{}]interface{})["version"])
+}
@@ -0,0 +1,60 @@
+package v1
+
+import "time"
+
+// Configuration the configuration data mounted into services as files
+type Configuration struct {
+	Name              string            `json:"name,omitempty" yaml:"name,omitempty"`
+	Namespace         string            `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Labels            map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Data              map[string]string `json:"data,omitempty" yaml:"data,omitempty"`
+	Version           string            `json:"version,omitempty" yaml:"version,omitempty"`
+	CreationTimestamp time.Time         `json:"createTime,omitempty" yaml:"createTime,omitempty"`
+	UpdateTimestamp   time.Time         `json:"updateTime,omitempty" yaml:"updateTime,omitempty"`
+	Description       string            `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// Secret the sensitive data mounted into services as files
+type Secret struct {
+	Name              string            `json:"name,omitempty" yaml:"name,omitempty"`
+	Namespace         string            `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Labels            map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Data              map[string][]byte `json:"data,omitempty" yaml:"data,omitempty"`
+	Version           string            `json:"version,omitempty" yaml:"version,omitempty"`
+	CreationTimestamp time.Time         `json:"createTime,omitempty" yaml:"createTime,omitempty"`
+	UpdateTimestamp   time.Time         `json:"updateTime,omitempty" yaml:"updateTime,omitempty"`
+	Description       string            `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// DeepCopy creates a deep copy of configuration
+func (in *Configuration) DeepCopy() *Configuration {
+	if in == nil {
+		return nil
+	}
+	out := new(Configuration)
+	*out = *in
+	out.Labels = copyStringMap(in.Labels)
+	out.Data = copyStringMap(in.Data)
+	return out
+}
+
+// DeepCopy creates a deep copy of secret
+func (in *Secret) DeepCopy() *Secret {
+	if in == nil {
+		return nil
+	}
+	out := new(Secret)
+	*out = *in
+	out.Labels = copyStringMap(in.Labels)
+	if in.Data != nil {
+		out.Data = make(map[string][]byte, len(in.Data))
+		for k, v := range in.Data {
+			if v == nil {
+				out.Data[k] = nil
+				continue
+			}
+			out.Data[k] = append([]byte{}, v...)
+		}
+	}
+	return out
+}
@@ -0,0 +1,63 @@
+package v1
+
+func copyStrings(in []string) []string {
+	if in == nil {
+		return nil
+	}
+	return append([]string{}, in...)
+}
+
+func copyStringMap(in map[string]string) map[string]string {
+	if in == nil {
+		return nil
+	}
+	out := make(map[string]string, len(in))
+	for k, v := range in {
+		out[k] = v
+	}
+	return out
+}
+
+// copyValue copies the value unmarshaled from json or yaml recursively
+func copyValue(in interface{}) interface{} {
+	switch v := in.(type) {
+	case map[string]interface{}:
+		if v == nil {
+			return v
+		}
+		out := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			out[k] = copyValue(e)
+		}
+		return out
+	case map[interface{}]interface{}:
+		if v == nil {
+			return v
+		}
+		out := make(map[interface{}]interface{}, len(v))
+		for k, e := range v {
+			out[k] = copyValue(e)
+		}
+		return out
+	case []interface{}:
+		if v == nil {
+			return v
+		}
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			out[i] = copyValue(e)
+		}
+		return out
+	case []byte:
+		if v == nil {
+			return v
+		}
+		return append([]byte{}, v...)
+	case Report:
+		return v.DeepCopy()
+	case Desire:
+		return v.DeepCopy()
+	default:
+		return v
+	}
+}
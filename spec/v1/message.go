@@ -0,0 +1,173 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/baetyl/baetyl-go/codec"
+	"github.com/baetyl/baetyl-go/link"
+	"github.com/baetyl/baetyl-go/mqtt"
+)
+
+// MessageKind the kind of message
+type MessageKind string
+
+// all message kinds
+const (
+	MessageReport MessageKind = "report"
+	MessageDesire MessageKind = "desire"
+	MessageEvent  MessageKind = "event"
+	MessageCmd    MessageKind = "cmd"
+	MessageData   MessageKind = "data"
+)
+
+// all metadata keys of message
+const (
+	MessageMetaTopic   = "topic"
+	MessageMetaVersion = "version"
+)
+
+// ErrMessageKindMissing the kind of message is missing
+var ErrMessageKindMissing = errors.New("message kind is missing")
+
+// Message the envelope of the messages exchanged over all channels (link, mqtt, http) in json
+type Message struct {
+	Kind     MessageKind       `json:"kind"`
+	Metadata map[string]string `json:"meta,omitempty"`
+	Content  LazyValue         `json:"content,omitempty"`
+}
+
+// NewMessage creates a new message with the value as content
+func NewMessage(kind MessageKind, value interface{}) *Message {
+	return &Message{Kind: kind, Content: LazyValue{Value: value}}
+}
+
+// LazyValue the value which keeps the raw data and decodes it into a typed struct on demand
+type LazyValue struct {
+	Value interface{}
+	raw   []byte
+}
+
+// MarshalJSON marshals the value, or returns the raw data if the value is not set
+func (v LazyValue) MarshalJSON() ([]byte, error) {
+	if v.Value != nil {
+		return json.Marshal(v.Value)
+	}
+	if v.raw != nil {
+		return v.raw, nil
+	}
+	return []byte("null"), nil
+}
+
+// UnmarshalJSON keeps the raw data without decoding
+func (v *LazyValue) UnmarshalJSON(data []byte) error {
+	v.Value = nil
+	v.raw = append(v.raw[:0], data...)
+	return nil
+}
+
+// Unmarshal decodes the raw data (or the value set) into the out
+func (v *LazyValue) Unmarshal(out interface{}) error {
+	data := v.raw
+	if v.Value != nil {
+		var err error
+		data, err = json.Marshal(v.Value)
+		if err != nil {
+			return err
+		}
+	}
+	if data == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// Raw returns the raw data
+func (v *LazyValue) Raw() []byte {
+	return v.raw
+}
+
+// ParseMessage parses the message from json data
+func ParseMessage(data []byte) (*Message, error) {
+	msg := &Message{}
+	err := json.Unmarshal(data, msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse message: %s", err.Error())
+	}
+	if msg.Kind == "" {
+		return nil, ErrMessageKindMissing
+	}
+	return msg, nil
+}
+
+// ToLink converts the message to a link message, the topic is taken from metadata,
+// and the other metadata are copied into the headers as well for the routing without decoding the content
+func (m *Message) ToLink() (*link.Message, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	msg := &link.Message{Content: data}
+	msg.Context.Topic = m.Metadata[MessageMetaTopic]
+	msg.Context.ContentType = codec.ContentTypeJSON
+	for k, v := range m.Metadata {
+		if k != MessageMetaTopic {
+			msg.SetHeader(k, v)
+		}
+	}
+	return msg, nil
+}
+
+// FromLink converts the link message to a message, the topic is kept in metadata,
+// and the headers are merged into metadata unless the metadata in content has the same keys
+func FromLink(msg *link.Message) (*Message, error) {
+	m, err := ParseMessage(msg.Content)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range msg.Context.Headers {
+		if _, ok := m.Metadata[k]; ok {
+			continue
+		}
+		if m.Metadata == nil {
+			m.Metadata = map[string]string{}
+		}
+		m.Metadata[k] = v
+	}
+	if msg.Context.Topic != "" {
+		if m.Metadata == nil {
+			m.Metadata = map[string]string{}
+		}
+		m.Metadata[MessageMetaTopic] = msg.Context.Topic
+	}
+	return m, nil
+}
+
+// ToMQTT converts the message to a mqtt publish packet, the topic is taken from metadata
+func (m *Message) ToMQTT(qos uint32) (*mqtt.Publish, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	pkt := mqtt.NewPublish()
+	pkt.Message.Topic = m.Metadata[MessageMetaTopic]
+	pkt.Message.Payload = data
+	pkt.Message.QOS = mqtt.QOS(qos)
+	return pkt, nil
+}
+
+// FromMQTT converts the mqtt publish packet to a message, the topic is kept in metadata
+func FromMQTT(pkt *mqtt.Publish) (*Message, error) {
+	m, err := ParseMessage(pkt.Message.Payload)
+	if err != nil {
+		return nil, err
+	}
+	if pkt.Message.Topic != "" {
+		if m.Metadata == nil {
+			m.Metadata = map[string]string{}
+		}
+		m.Metadata[MessageMetaTopic] = pkt.Message.Topic
+	}
+	return m, nil
+}
@@ -0,0 +1,85 @@
+package v1
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/baetyl/baetyl-go/link"
+	"github.com/baetyl/baetyl-go/mqtt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessage(t *testing.T) {
+	m := NewMessage(MessageReport, AppInfo{Name: "app1", Version: "1"})
+	m.Metadata = map[string]string{MessageMetaTopic: "$baetyl/report"}
+	data, err := json.Marshal(m)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"kind":"report","meta":{"topic":"$baetyl/report"},"content":{"name":"app1","version":"1"}}`, string(data))
+
+	m2, err := ParseMessage(data)
+	assert.NoError(t, err)
+	assert.Equal(t, MessageReport, m2.Kind)
+	assert.Nil(t, m2.Content.Value)
+	assert.Equal(t, `{"name":"app1","version":"1"}`, string(m2.Content.Raw()))
+	var info AppInfo
+	assert.NoError(t, m2.Content.Unmarshal(&info))
+	assert.Equal(t, AppInfo{Name: "app1", Version: "1"}, info)
+	var report Report
+	assert.NoError(t, m.Content.Unmarshal(&report))
+	assert.Equal(t, Report{"name": "app1", "version": "1"}, report)
+	data2, err := json.Marshal(m2)
+	assert.NoError(t, err)
+	assert.Equal(t, data, data2)
+
+	_, err = ParseMessage([]byte(`{"content":1}`))
+	assert.Equal(t, ErrMessageKindMissing, err)
+	_, err = ParseMessage([]byte(`{`))
+	assert.EqualError(t, err, "failed to parse message: unexpected end of JSON input")
+}
+
+func TestMessageConvert(t *testing.T) {
+	m := NewMessage(MessageDesire, map[string]interface{}{"a": 1})
+	m.Metadata = map[string]string{MessageMetaTopic: "t1"}
+
+	lm, err := m.ToLink()
+	assert.NoError(t, err)
+	assert.Equal(t, "t1", lm.Context.Topic)
+	m2, err := FromLink(lm)
+	assert.NoError(t, err)
+	assert.Equal(t, "t1", m2.Metadata[MessageMetaTopic])
+	var d Desire
+	assert.NoError(t, m2.Content.Unmarshal(&d))
+	assert.Equal(t, Desire{"a": float64(1)}, d)
+
+	lm = &link.Message{Content: []byte(`{"kind":"cmd"}`)}
+	lm.Context.Topic = "t2"
+	m2, err = FromLink(lm)
+	assert.NoError(t, err)
+	assert.Equal(t, MessageCmd, m2.Kind)
+	assert.Equal(t, map[string]string{MessageMetaTopic: "t2"}, m2.Metadata)
+
+	// the metadata are carried by headers as well
+	m.Metadata[MessageMetaVersion] = "v1"
+	lm, err = m.ToLink()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{MessageMetaVersion: "v1"}, lm.Context.Headers)
+	assert.Equal(t, "application/json", lm.Context.ContentType)
+	lm.Content = []byte(`{"kind":"cmd","meta":{"version":"v2"}}`)
+	lm.SetHeader("trace", "x")
+	m2, err = FromLink(lm)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{MessageMetaTopic: "t1", MessageMetaVersion: "v2", "trace": "x"}, m2.Metadata)
+
+	pkt, err := m.ToMQTT(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "t1", pkt.Message.Topic)
+	assert.Equal(t, mqtt.QOS(1), pkt.Message.QOS)
+	m2, err = FromMQTT(pkt)
+	assert.NoError(t, err)
+	assert.Equal(t, MessageDesire, m2.Kind)
+	assert.Equal(t, `{"a":1}`, string(m2.Content.Raw()))
+
+	pkt.Message.Payload = []byte("x")
+	_, err = FromMQTT(pkt)
+	assert.Error(t, err)
+}
@@ -0,0 +1,58 @@
+package v1
+
+import "time"
+
+// Node the edge node
+type Node struct {
+	Name              string            `json:"name,omitempty" yaml:"name,omitempty"`
+	Namespace         string            `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Labels            map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Annotations       map[string]string `json:"annotations,omitempty" yaml:"annotations,omitempty"`
+	Version           string            `json:"version,omitempty" yaml:"version,omitempty"`
+	CreationTimestamp time.Time         `json:"createTime,omitempty" yaml:"createTime,omitempty"`
+	Description       string            `json:"description,omitempty" yaml:"description,omitempty"`
+	Report            Report            `json:"report,omitempty" yaml:"report,omitempty"`
+	Desire            Desire            `json:"desire,omitempty" yaml:"desire,omitempty"`
+}
+
+// Report the state reported by node
+type Report map[string]interface{}
+
+// Desire the state desired by cloud
+type Desire map[string]interface{}
+
+// AppInfo the name and version of application deployed on node
+type AppInfo struct {
+	Name    string `json:"name,omitempty" yaml:"name,omitempty"`
+	Version string `json:"version,omitempty" yaml:"version,omitempty"`
+}
+
+// DeepCopy creates a deep copy of node
+func (in *Node) DeepCopy() *Node {
+	if in == nil {
+		return nil
+	}
+	out := new(Node)
+	*out = *in
+	out.Labels = copyStringMap(in.Labels)
+	out.Annotations = copyStringMap(in.Annotations)
+	out.Report = in.Report.DeepCopy()
+	out.Desire = in.Desire.DeepCopy()
+	return out
+}
+
+// DeepCopy creates a deep copy of report
+func (in Report) DeepCopy() Report {
+	if in == nil {
+		return nil
+	}
+	return Report(copyValue(map[string]interface{}(in)).(map[string]interface{}))
+}
+
+// DeepCopy creates a deep copy of desire
+func (in Desire) DeepCopy() Desire {
+	if in == nil {
+		return nil
+	}
+	return Desire(copyValue(map[string]interface{}(in)).(map[string]interface{}))
+}
@@ -0,0 +1,266 @@
+package v1
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const maxNameLength = 63
+
+var (
+	regName     = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+	regDataKey  = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+	regQuantity = regexp.MustCompile(`^([0-9]+(\.[0-9]+)?)(m|k|M|G|T|P|E|Ki|Mi|Gi|Ti|Pi|Ei)?$`)
+)
+
+var quantitySuffixes = map[string]float64{
+	"":   1,
+	"m":  1e-3,
+	"k":  1e3,
+	"M":  1e6,
+	"G":  1e9,
+	"T":  1e12,
+	"P":  1e15,
+	"E":  1e18,
+	"Ki": 1 << 10,
+	"Mi": 1 << 20,
+	"Gi": 1 << 30,
+	"Ti": 1 << 40,
+	"Pi": 1 << 50,
+	"Ei": 1 << 60,
+}
+
+// FieldError the validation error of a field, the field is a path like services[0].ports[1].hostPort
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// FieldErrors all validation errors
+type FieldErrors []*FieldError
+
+func (es FieldErrors) Error() string {
+	msgs := make([]string, 0, len(es))
+	for _, e := range es {
+		msgs = append(msgs, e.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (es *FieldErrors) add(field, format string, args ...interface{}) {
+	*es = append(*es, &FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (es FieldErrors) err() error {
+	if len(es) == 0 {
+		return nil
+	}
+	return es
+}
+
+// ParseQuantity parses the resource quantity, such as 500m (cpu) and 128Mi (memory), into base units
+func ParseQuantity(s string) (float64, error) {
+	m := regQuantity.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("quantity (%s) is invalid", s)
+	}
+	v, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, err
+	}
+	return v * quantitySuffixes[m[3]], nil
+}
+
+// Validate validates the application
+func (a *Application) Validate() error {
+	var es FieldErrors
+	validateName(&es, "name", a.Name)
+	switch a.Type {
+	case "", AppTypeContainer, AppTypeFunction, AppTypeNative:
+	default:
+		es.add("type", "type (%s) is not supported", a.Type)
+	}
+	vols := map[string]struct{}{}
+	for i := range a.Volumes {
+		f := fmt.Sprintf("volumes[%d]", i)
+		a.Volumes[i].validate(&es, f)
+		if _, ok := vols[a.Volumes[i].Name]; ok {
+			es.add(f+".name", "name (%s) is duplicated", a.Volumes[i].Name)
+		}
+		vols[a.Volumes[i].Name] = struct{}{}
+	}
+	svcs := map[string]struct{}{}
+	for i := range a.Services {
+		f := fmt.Sprintf("services[%d]", i)
+		a.Services[i].validate(&es, f, vols)
+		if _, ok := svcs[a.Services[i].Name]; ok {
+			es.add(f+".name", "name (%s) is duplicated", a.Services[i].Name)
+		}
+		svcs[a.Services[i].Name] = struct{}{}
+	}
+	return es.err()
+}
+
+func (s *Service) validate(es *FieldErrors, f string, vols map[string]struct{}) {
+	validateName(es, f+".name", s.Name)
+	if s.Image == "" && len(s.Functions) == 0 {
+		es.add(f+".image", "image is required")
+	}
+	if s.Replica < 0 {
+		es.add(f+".replica", "replica (%d) must not be negative", s.Replica)
+	}
+	for i, e := range s.Env {
+		if e.Name == "" {
+			es.add(fmt.Sprintf("%s.env[%d].name", f, i), "name is required")
+		}
+	}
+	for i, p := range s.Ports {
+		pf := fmt.Sprintf("%s.ports[%d]", f, i)
+		if p.ContainerPort < 1 || p.ContainerPort > 65535 {
+			es.add(pf+".containerPort", "port (%d) must be in range [1, 65535]", p.ContainerPort)
+		}
+		if p.HostPort < 0 || p.HostPort > 65535 {
+			es.add(pf+".hostPort", "port (%d) must be in range [0, 65535]", p.HostPort)
+		}
+		switch strings.ToUpper(p.Protocol) {
+		case "", "TCP", "UDP":
+		default:
+			es.add(pf+".protocol", "protocol (%s) is not supported", p.Protocol)
+		}
+	}
+	for i, m := range s.VolumeMounts {
+		mf := fmt.Sprintf("%s.volumeMounts[%d]", f, i)
+		if _, ok := vols[m.Name]; !ok {
+			es.add(mf+".name", "volume (%s) not found", m.Name)
+		}
+		if !path.IsAbs(m.MountPath) {
+			es.add(mf+".mountPath", "path (%s) must be absolute", m.MountPath)
+		}
+	}
+	for i, d := range s.Devices {
+		if !path.IsAbs(d.DevicePath) {
+			es.add(fmt.Sprintf("%s.devices[%d].devicePath", f, i), "path (%s) must be absolute", d.DevicePath)
+		}
+	}
+	if s.Resources != nil {
+		validateQuantities(es, f+".resources.limits", s.Resources.Limits)
+		validateQuantities(es, f+".resources.requests", s.Resources.Requests)
+	}
+	if s.Restart != nil {
+		switch s.Restart.Policy {
+		case "", "always", "on-failure", "never":
+		default:
+			es.add(f+".restart.policy", "policy (%s) is not supported", s.Restart.Policy)
+		}
+		if s.Restart.Retries < 0 {
+			es.add(f+".restart.retries", "retries (%d) must not be negative", s.Restart.Retries)
+		}
+	}
+	for i, fn := range s.Functions {
+		if fn.Name == "" {
+			es.add(fmt.Sprintf("%s.functions[%d].name", f, i), "name is required")
+		}
+		if fn.Handler == "" {
+			es.add(fmt.Sprintf("%s.functions[%d].handler", f, i), "handler is required")
+		}
+	}
+}
+
+func (v *Volume) validate(es *FieldErrors, f string) {
+	validateName(es, f+".name", v.Name)
+	n := 0
+	if v.HostPath != nil {
+		n++
+		if !path.IsAbs(v.HostPath.Path) {
+			es.add(f+".hostPath.path", "path (%s) must be absolute", v.HostPath.Path)
+		}
+	}
+	if v.Config != nil {
+		n++
+		validateName(es, f+".config.name", v.Config.Name)
+	}
+	if v.Secret != nil {
+		n++
+		validateName(es, f+".secret.name", v.Secret.Name)
+	}
+	if n != 1 {
+		es.add(f, "one and only one source (hostPath, config or secret) must be set")
+	}
+}
+
+// Validate validates the configuration
+func (c *Configuration) Validate() error {
+	var es FieldErrors
+	validateName(&es, "name", c.Name)
+	for _, k := range sortedKeys(c.Data) {
+		validateDataKey(&es, "data", k)
+	}
+	return es.err()
+}
+
+// Validate validates the secret
+func (s *Secret) Validate() error {
+	var es FieldErrors
+	validateName(&es, "name", s.Name)
+	keys := make([]string, 0, len(s.Data))
+	for k := range s.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		validateDataKey(&es, "data", k)
+	}
+	return es.err()
+}
+
+// Validate validates the node
+func (n *Node) Validate() error {
+	var es FieldErrors
+	validateName(&es, "name", n.Name)
+	return es.err()
+}
+
+func validateName(es *FieldErrors, f, name string) {
+	if name == "" {
+		es.add(f, "name is required")
+		return
+	}
+	if len(name) > maxNameLength {
+		es.add(f, "name (%s) must be no more than %d characters", name, maxNameLength)
+		return
+	}
+	if !regName.MatchString(name) {
+		es.add(f, "name (%s) must consist of lower case alphanumeric characters or '-', and start and end with an alphanumeric character", name)
+	}
+}
+
+func validateDataKey(es *FieldErrors, f, key string) {
+	if !regDataKey.MatchString(key) || key == "." || key == ".." {
+		es.add(f+"."+key, "key (%s) must consist of alphanumeric characters, '-', '_' or '.'", key)
+	}
+}
+
+func validateQuantities(es *FieldErrors, f string, qs map[string]string) {
+	for _, k := range sortedKeys(qs) {
+		if _, err := ParseQuantity(qs[k]); err != nil {
+			es.add(f+"."+k, "%s", err.Error())
+		}
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
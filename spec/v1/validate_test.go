@@ -0,0 +1,86 @@
+package v1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseQuantity(t *testing.T) {
+	cases := map[string]float64{
+		"1":     1,
+		"0.5":   0.5,
+		"500m":  0.5,
+		"128Mi": 128 << 20,
+		"1G":    1e9,
+		"2Ki":   2048,
+	}
+	for s, v := range cases {
+		q, err := ParseQuantity(s)
+		assert.NoError(t, err, s)
+		assert.Equal(t, v, q, s)
+	}
+	_, err := ParseQuantity("1.2.3")
+	assert.EqualError(t, err, "quantity (1.2.3) is invalid")
+	_, err = ParseQuantity("12mb")
+	assert.Error(t, err)
+}
+
+func TestApplicationValidate(t *testing.T) {
+	app := &Application{
+		Name: "app1",
+		Services: []Service{{
+			Name:         "svc1",
+			Image:        "broker",
+			Ports:        []ContainerPort{{HostPort: 1883, ContainerPort: 1883}},
+			VolumeMounts: []VolumeMount{{Name: "conf", MountPath: "/etc/baetyl"}},
+			Resources:    &Resources{Limits: map[string]string{"cpu": "500m", "memory": "128Mi"}},
+		}},
+		Volumes: []Volume{{Name: "conf", VolumeSource: VolumeSource{Config: &ObjectReference{Name: "conf1"}}}},
+	}
+	assert.NoError(t, app.Validate())
+
+	app.Name = "App_1"
+	app.Type = "vm"
+	app.Services = append(app.Services, Service{
+		Name:         "svc1",
+		Ports:        []ContainerPort{{HostPort: 70000, ContainerPort: 0, Protocol: "sctp"}},
+		VolumeMounts: []VolumeMount{{Name: "data", MountPath: "data"}},
+		Resources:    &Resources{Limits: map[string]string{"memory": "lots", "cpu": "x"}},
+		Restart:      &RestartPolicy{Policy: "sometimes"},
+	})
+	app.Volumes = append(app.Volumes, Volume{Name: "conf"})
+	err := app.Validate()
+	assert.Error(t, err)
+	es, ok := err.(FieldErrors)
+	assert.True(t, ok)
+	var fields []string
+	for _, e := range es {
+		fields = append(fields, e.Field)
+	}
+	assert.Equal(t, []string{
+		"name",
+		"type",
+		"volumes[1]",
+		"volumes[1].name",
+		"services[1].image",
+		"services[1].ports[0].containerPort",
+		"services[1].ports[0].hostPort",
+		"services[1].ports[0].protocol",
+		"services[1].volumeMounts[0].name",
+		"services[1].volumeMounts[0].mountPath",
+		"services[1].resources.limits.cpu",
+		"services[1].resources.limits.memory",
+		"services[1].restart.policy",
+		"services[1].name",
+	}, fields)
+	assert.Contains(t, err.Error(), "services[1].volumeMounts[0].name: volume (data) not found")
+}
+
+func TestConfigurationValidate(t *testing.T) {
+	assert.NoError(t, (&Configuration{Name: "c1", Data: map[string]string{"service.yml": ""}}).Validate())
+	assert.EqualError(t, (&Configuration{Name: "c1", Data: map[string]string{"a/b": ""}}).Validate(), "data.a/b: key (a/b) must consist of alphanumeric characters, '-', '_' or '.'")
+	assert.EqualError(t, (&Secret{Data: map[string][]byte{"..": nil}}).Validate(), "name: name is required; data...: key (..) must consist of alphanumeric characters, '-', '_' or '.'")
+	assert.NoError(t, (&Node{Name: "node-1"}).Validate())
+	assert.Error(t, (&Node{Name: "-node"}).Validate())
+}
@@ -0,0 +1,12 @@
+package sync
+
+import "time"
+
+// Config the config of sync
+type Config struct {
+	ReportTopic string        `yaml:"reportTopic" json:"reportTopic" default:"$baetyl/node/report"`
+	DesireTopic string        `yaml:"desireTopic" json:"desireTopic" default:"$baetyl/node/desire"`
+	Interval    time.Duration `yaml:"interval" json:"interval" default:"20s"`
+	MaxInterval time.Duration `yaml:"maxInterval" json:"maxInterval" default:"2m"` // the max interval to retry after failed to sync
+	Timeout     time.Duration `yaml:"timeout" json:"timeout" default:"30s"`
+}
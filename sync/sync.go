@@ -0,0 +1,264 @@
+// Package sync synchronizes the state between edge and cloud, the node reports its state periodically
+// and the cloud responds (or pushes) the desired state, which is applied by the appliers registered.
+package sync
+
+import (
+	"context"
+	"reflect"
+	gosync "sync"
+	"time"
+
+	"github.com/baetyl/baetyl-go/dm"
+	"github.com/baetyl/baetyl-go/link"
+	"github.com/baetyl/baetyl-go/log"
+	v1 "github.com/baetyl/baetyl-go/spec/v1"
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/jpillora/backoff"
+)
+
+// Collector collects the current state of a key to report
+type Collector func() (interface{}, error)
+
+// Applier applies the desired state of a key
+type Applier interface {
+	Apply(desire interface{}) error
+}
+
+// ApplierFunc the function to apply
+type ApplierFunc func(interface{}) error
+
+// Apply calls the function
+func (f ApplierFunc) Apply(desire interface{}) error {
+	return f(desire)
+}
+
+// Sync reports the state collected and applies the desired state over link,
+// only the properties changed since the last report are reported (shadow diffing),
+// and the desired properties different from the reported are applied by the applier of its key
+type Sync struct {
+	cfg        Config
+	caller     link.Caller
+	collectors map[string]Collector
+	appliers   map[string]Applier
+	reported   v1.Report              // the shadow of state reported to cloud
+	desired    v1.Desire              // the latest desired state from cloud
+	applied    map[string]interface{} // the desired values applied successfully
+	trigger    chan struct{}
+	mu         gosync.RWMutex
+	log        *log.Logger
+	tomb       utils.Tomb
+}
+
+// NewSync creates a new sync and starts to synchronize in background, the caller is usually a link client
+func NewSync(cfg Config, caller link.Caller) *Sync {
+	s := &Sync{
+		cfg:        cfg,
+		caller:     caller,
+		collectors: map[string]Collector{},
+		appliers:   map[string]Applier{},
+		reported:   v1.Report{},
+		applied:    map[string]interface{}{},
+		trigger:    make(chan struct{}, 1),
+		log:        log.With(log.Any("sync", "node")),
+	}
+	s.tomb.Go(s.syncing)
+	return s
+}
+
+// RegisterCollector registers the collector of the key
+func (s *Sync) RegisterCollector(key string, c Collector) {
+	s.mu.Lock()
+	s.collectors[key] = c
+	s.mu.Unlock()
+	s.kick()
+}
+
+// RegisterApplier registers the applier of the key
+func (s *Sync) RegisterApplier(key string, a Applier) {
+	s.mu.Lock()
+	s.appliers[key] = a
+	s.mu.Unlock()
+	s.kick()
+}
+
+// Report reports the state immediately instead of waiting for the next interval
+func (s *Sync) Report() {
+	s.kick()
+}
+
+// Desired returns a copy of the latest desired state
+func (s *Sync) Desired() v1.Desire {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.desired.DeepCopy()
+}
+
+// OnMsg handles the desired state pushed by cloud, so that the sync can be the observer of link client
+func (s *Sync) OnMsg(msg *link.Message) error {
+	if msg.Context.Topic != s.cfg.DesireTopic {
+		return nil
+	}
+	m, err := v1.FromLink(msg)
+	if err != nil {
+		return err
+	}
+	err = s.onDesire(m)
+	if err != nil {
+		return err
+	}
+	s.kick()
+	return nil
+}
+
+// OnAck handles the ack of message
+func (s *Sync) OnAck(*link.Message) error {
+	return nil
+}
+
+// OnErr handles the error of link client
+func (s *Sync) OnErr(err error) {
+	s.log.Warn("link client error", log.Error(err))
+}
+
+// Close stops synchronizing
+func (s *Sync) Close() error {
+	s.tomb.Kill(nil)
+	return s.tomb.Wait()
+}
+
+func (s *Sync) kick() {
+	select {
+	case s.trigger <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Sync) syncing() error {
+	s.log.Info("sync starts")
+	defer s.log.Info("sync has stopped")
+
+	bf := backoff.Backoff{
+		Min:    time.Second,
+		Max:    s.cfg.MaxInterval,
+		Factor: 1.6,
+	}
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+		case <-s.trigger:
+		case <-s.tomb.Dying():
+			return nil
+		}
+		next := s.cfg.Interval
+		if err := s.report(); err != nil {
+			next = bf.Duration()
+			s.log.Error("failed to report, retry later", log.Any("after", next), log.Error(err))
+		} else {
+			bf.Reset()
+		}
+		s.apply()
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(next)
+	}
+}
+
+// report reports the properties changed since last report, and takes the desired state in response
+func (s *Sync) report() error {
+	s.mu.RLock()
+	collectors := make(map[string]Collector, len(s.collectors))
+	for k, c := range s.collectors {
+		collectors[k] = c
+	}
+	s.mu.RUnlock()
+
+	current := v1.Report{}
+	for k, c := range collectors {
+		v, err := c()
+		if err != nil {
+			s.log.Warn("failed to collect state", log.Any("key", k), log.Error(err))
+			continue
+		}
+		current[k] = v
+	}
+	delta := v1.Report(dm.Props(current).Diff(dm.Props(s.reported)))
+
+	m := v1.NewMessage(v1.MessageReport, delta)
+	m.Metadata = map[string]string{v1.MessageMetaTopic: s.cfg.ReportTopic}
+	req, err := m.ToLink()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.Timeout)
+	defer cancel()
+	res, err := s.caller.CallContext(ctx, req)
+	if err != nil {
+		return err
+	}
+	s.reported = v1.Report(dm.Props(s.reported).Merge(dm.Props(delta)))
+	if ent := s.log.Check(log.DebugLevel, "sync reported state"); ent != nil {
+		ent.Write(log.Any("delta", delta))
+	}
+	if res == nil || len(res.Content) == 0 {
+		return nil
+	}
+	rm, err := v1.FromLink(res)
+	if err != nil {
+		return err
+	}
+	return s.onDesire(rm)
+}
+
+func (s *Sync) onDesire(m *v1.Message) error {
+	if m.Kind != v1.MessageDesire {
+		return nil
+	}
+	var desire v1.Desire
+	err := m.Content.Unmarshal(&desire)
+	if err != nil {
+		return err
+	}
+	if desire == nil {
+		return nil
+	}
+	s.mu.Lock()
+	s.desired = desire
+	s.mu.Unlock()
+	return nil
+}
+
+// apply applies the desired properties different from the reported, the failed ones are retried in next round
+func (s *Sync) apply() {
+	s.mu.RLock()
+	desired := s.desired.DeepCopy()
+	appliers := make(map[string]Applier, len(s.appliers))
+	for k, a := range s.appliers {
+		appliers[k] = a
+	}
+	s.mu.RUnlock()
+
+	delta := dm.Props(desired).Diff(dm.Props(s.reported))
+	for k := range delta {
+		a, ok := appliers[k]
+		if !ok {
+			continue
+		}
+		if v, ok := s.applied[k]; ok && reflect.DeepEqual(v, desired[k]) {
+			// applied already, waits for the state reported to converge
+			continue
+		}
+		err := a.Apply(desired[k])
+		if err != nil {
+			s.log.Error("failed to apply desired state, retry later", log.Any("key", k), log.Error(err))
+			continue
+		}
+		s.applied[k] = desired[k]
+		s.log.Info("desired state applied", log.Any("key", k))
+	}
+}
@@ -0,0 +1,149 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	gosync "sync"
+	"testing"
+	"time"
+
+	"github.com/baetyl/baetyl-go/link"
+	v1 "github.com/baetyl/baetyl-go/spec/v1"
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockCloud struct {
+	reports chan v1.Report
+	desire  v1.Desire
+	err     error
+	mu      gosync.Mutex
+}
+
+func (c *mockCloud) CallContext(_ context.Context, msg *link.Message) (*link.Message, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return nil, c.err
+	}
+	m, err := v1.FromLink(msg)
+	if err != nil {
+		return nil, err
+	}
+	if m.Metadata[v1.MessageMetaTopic] != "$baetyl/node/report" || m.Kind != v1.MessageReport {
+		return nil, errors.New("unexpected message")
+	}
+	var r v1.Report
+	err = m.Content.Unmarshal(&r)
+	if err != nil {
+		return nil, err
+	}
+	c.reports <- r
+	return v1.NewMessage(v1.MessageDesire, c.desire).ToLink()
+}
+
+func newConfig() Config {
+	var cfg Config
+	utils.SetDefaults(&cfg)
+	cfg.Interval = 50 * time.Millisecond
+	return cfg
+}
+
+func assertReport(t *testing.T, c *mockCloud, expected v1.Report) {
+	select {
+	case r := <-c.reports:
+		assert.Equal(t, expected, r)
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing reported")
+	}
+}
+
+func TestSync(t *testing.T) {
+	c := &mockCloud{reports: make(chan v1.Report, 100), desire: v1.Desire{"apps": map[string]interface{}{"a": "v1"}}}
+	s := NewSync(newConfig(), c)
+	defer s.Close()
+	assertReport(t, c, v1.Report{})
+
+	var mu gosync.Mutex
+	apps := map[string]interface{}{"a": "v0"}
+	s.RegisterCollector("apps", func() (interface{}, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return map[string]interface{}{"a": apps["a"]}, nil
+	})
+	s.RegisterCollector("broken", func() (interface{}, error) {
+		return nil, errors.New("broken")
+	})
+	applied := make(chan interface{}, 100)
+	fails := 1
+	s.RegisterApplier("apps", ApplierFunc(func(desire interface{}) error {
+		if fails > 0 {
+			fails--
+			return errors.New("failed to apply")
+		}
+		applied <- desire
+		return nil
+	}))
+
+	// only the changed properties are reported
+	for {
+		r := <-c.reports
+		if len(r) > 0 {
+			assert.Equal(t, v1.Report{"apps": map[string]interface{}{"a": "v0"}}, r)
+			break
+		}
+	}
+	select {
+	case d := <-applied:
+		assert.Equal(t, map[string]interface{}{"a": "v1"}, d)
+		mu.Lock()
+		apps["a"] = "v1"
+		mu.Unlock()
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing applied")
+	}
+	for {
+		r := <-c.reports
+		if len(r) > 0 {
+			assert.Equal(t, v1.Report{"apps": map[string]interface{}{"a": "v1"}}, r)
+			break
+		}
+	}
+	assert.Equal(t, v1.Desire{"apps": map[string]interface{}{"a": "v1"}}, s.Desired())
+	assert.Len(t, applied, 0)
+
+	// the desire pushed by cloud
+	m := v1.NewMessage(v1.MessageDesire, v1.Desire{"apps": map[string]interface{}{"a": "v2"}})
+	m.Metadata = map[string]string{v1.MessageMetaTopic: "$baetyl/node/desire"}
+	msg, err := m.ToLink()
+	assert.NoError(t, err)
+	c.mu.Lock()
+	c.desire = v1.Desire{"apps": map[string]interface{}{"a": "v2"}}
+	c.mu.Unlock()
+	assert.NoError(t, s.OnMsg(msg))
+	select {
+	case d := <-applied:
+		assert.Equal(t, map[string]interface{}{"a": "v2"}, d)
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing applied")
+	}
+}
+
+func TestSyncRetry(t *testing.T) {
+	c := &mockCloud{reports: make(chan v1.Report, 100), err: errors.New("unavailable")}
+	cfg := newConfig()
+	s := NewSync(cfg, c)
+	defer s.Close()
+	s.RegisterCollector("node", func() (interface{}, error) {
+		return "ready", nil
+	})
+	time.Sleep(100 * time.Millisecond)
+	assert.Len(t, c.reports, 0)
+
+	// the report failed is sent again after recovered
+	c.mu.Lock()
+	c.err = nil
+	c.mu.Unlock()
+	s.Report()
+	assertReport(t, c, v1.Report{"node": "ready"})
+}
@@ -0,0 +1,24 @@
+package trace
+
+import (
+	"time"
+
+	"github.com/baetyl/baetyl-go/utils"
+)
+
+// all exporters
+const (
+	ExporterNone = "none"
+	ExporterOTLP = "otlp"
+)
+
+// Config the config of tracing, the spans are exported to the otlp collector (such as localhost:55680),
+// tls is enabled if the certificate is set
+type Config struct {
+	Exporter     string            `yaml:"exporter" json:"exporter" default:"none" validate:"regexp=^(none|otlp)$"`
+	Endpoint     string            `yaml:"endpoint" json:"endpoint"`
+	Certificate  utils.Certificate `yaml:",inline" json:",inline"`
+	Sampling     float64           `yaml:"sampling" json:"sampling" default:"1"` // the fraction of traces sampled, the child spans are always sampled if their parent is
+	BatchTimeout time.Duration     `yaml:"batchTimeout" json:"batchTimeout" default:"5s"`
+	Propagation  bool              `yaml:"propagation" json:"propagation"` // the trace context is propagated by the mqtt and link clients created by the context if enabled
+}
@@ -0,0 +1,115 @@
+package trace
+
+import (
+	"context"
+
+	"github.com/baetyl/baetyl-go/link"
+	"github.com/baetyl/baetyl-go/mqtt"
+	"go.opentelemetry.io/otel/api/global"
+	"go.opentelemetry.io/otel/api/propagation"
+	apitrace "go.opentelemetry.io/otel/api/trace"
+	"google.golang.org/grpc"
+)
+
+// InjectLink injects the trace context into the headers of the link message, such as traceparent of w3c,
+// used as the message injector of link client
+func InjectLink(ctx context.Context, msg *link.Message) {
+	propagation.InjectHTTP(ctx, global.Propagators(), linkSupplier{msg})
+}
+
+// ExtractLink extracts the trace context from the headers of the link message received,
+// the spans started by the context returned are the children of the sender's
+func ExtractLink(ctx context.Context, msg *link.Message) context.Context {
+	return propagation.ExtractHTTP(ctx, global.Propagators(), linkSupplier{msg})
+}
+
+// InjectMQTT injects the trace context into the user properties of mqtt 5.0,
+// used as the properties injector of mqtt client
+func InjectMQTT(ctx context.Context, props *mqtt.Properties) {
+	propagation.InjectHTTP(ctx, global.Propagators(), mqttSupplier{props})
+}
+
+// ExtractMQTT extracts the trace context from the user properties of the mqtt 5.0 publish received
+func ExtractMQTT(ctx context.Context, props *mqtt.Properties) context.Context {
+	return propagation.ExtractHTTP(ctx, global.Propagators(), mqttSupplier{props})
+}
+
+// LinkClientConfig returns a copy of the config of link client, which propagates the trace context
+// by the interceptors of calls and by the headers of messages sent and received
+func LinkClientConfig(cc link.ClientConfig) link.ClientConfig {
+	cc.DialOptions = append(append([]grpc.DialOption{}, cc.DialOptions...), DialOptions()...)
+	cc.MessageInjector = InjectLink
+	cc.ReceiveInterceptor = ReceiveLink
+	return cc
+}
+
+// MQTTClientConfig returns a copy of the config of mqtt client, which propagates the trace context
+// by the user properties of mqtt 5.0 publishes sent and received
+func MQTTClientConfig(cc mqtt.ClientConfig) mqtt.ClientConfig {
+	cc.PropertiesInjector = InjectMQTT
+	cc.ReceiveInterceptor = ReceiveMQTT
+	return cc
+}
+
+// ReceiveLink starts a consumer span around the handling of the link message if it carries the trace context,
+// the headers are replaced by the context of the span, so that the handler continues the trace by ExtractLink,
+// used as the receive interceptor of link client and service
+func ReceiveLink(msg *link.Message, handle func() error) error {
+	ctx := ExtractLink(context.Background(), msg)
+	if !apitrace.RemoteSpanContextFromContext(ctx).IsValid() {
+		return handle()
+	}
+	ctx, span := StartLinkSpan(ctx, msg, apitrace.SpanKindConsumer)
+	InjectLink(ctx, msg)
+	err := handle()
+	EndSpan(ctx, span, err)
+	return err
+}
+
+// ReceiveMQTT starts a consumer span around the handling of the mqtt 5.0 publish if it carries the trace context,
+// the user properties are replaced by the context of the span, so that the handler continues the trace by ExtractMQTT,
+// used as the receive interceptor of mqtt client
+func ReceiveMQTT(pkt *mqtt.Publish5, handle func() error) error {
+	ctx := ExtractMQTT(context.Background(), &pkt.Properties)
+	if !apitrace.RemoteSpanContextFromContext(ctx).IsValid() {
+		return handle()
+	}
+	ctx, span := StartMQTTSpan(ctx, pkt.Publish, apitrace.SpanKindConsumer)
+	InjectMQTT(ctx, &pkt.Properties)
+	err := handle()
+	EndSpan(ctx, span, err)
+	return err
+}
+
+// linkSupplier gets and sets the trace context in the headers of link message
+type linkSupplier struct {
+	msg *link.Message
+}
+
+func (s linkSupplier) Get(key string) string {
+	return s.msg.Header(key)
+}
+
+func (s linkSupplier) Set(key, value string) {
+	s.msg.SetHeader(key, value)
+}
+
+// mqttSupplier gets and sets the trace context in the user properties of mqtt 5.0, the property of the same key is replaced
+type mqttSupplier struct {
+	props *mqtt.Properties
+}
+
+func (s mqttSupplier) Get(key string) string {
+	v, _ := s.props.UserProperty(key)
+	return v
+}
+
+func (s mqttSupplier) Set(key, value string) {
+	for i, up := range s.props.UserProperties {
+		if up.Key == key {
+			s.props.UserProperties[i].Value = value
+			return
+		}
+	}
+	s.props.AddUserProperty(key, value)
+}
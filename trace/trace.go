@@ -0,0 +1,154 @@
+// Package trace provides the tracing based on opentelemetry, and the helpers to start spans from mqtt publishes and link messages,
+// the context is propagated across grpc (link) by the interceptors, and by the headers of link messages and the user properties of mqtt publishes
+package trace
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/baetyl/baetyl-go/link"
+	"github.com/baetyl/baetyl-go/mqtt"
+	"github.com/baetyl/baetyl-go/utils"
+	"go.opentelemetry.io/otel/api/global"
+	"go.opentelemetry.io/otel/api/kv"
+	"go.opentelemetry.io/otel/api/standard"
+	apitrace "go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/exporters/otlp"
+	"go.opentelemetry.io/otel/plugin/grpctrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// the name of tracer
+const tracerName = "github.com/baetyl/baetyl-go"
+
+// all attribute keys of messages
+const (
+	KeyMessagingSystem      = kv.Key("messaging.system")
+	KeyMessagingDestination = kv.Key("messaging.destination")
+	KeyMessagingMessageID   = kv.Key("messaging.message_id")
+	KeyMessagingQOS         = kv.Key("messaging.qos")
+)
+
+// Init sets the global trace provider of the service, returns the function to flush the spans and stop the exporter
+func Init(cfg Config, service string) (func(), error) {
+	tp, err := sdktrace.NewProvider(
+		sdktrace.WithConfig(sdktrace.Config{DefaultSampler: sdktrace.ProbabilitySampler(cfg.Sampling)}),
+		sdktrace.WithResource(resource.New(standard.ServiceNameKey.String(service))),
+	)
+	if err != nil {
+		return nil, err
+	}
+	stop := func() {}
+	switch cfg.Exporter {
+	case ExporterNone:
+	case ExporterOTLP:
+		opts := []otlp.ExporterOption{otlp.WithAddress(cfg.Endpoint)}
+		if cfg.Certificate.CA != "" || cfg.Certificate.Key != "" || cfg.Certificate.Cert != "" {
+			tlsCfg, err := utils.NewTLSConfigClient(cfg.Certificate)
+			if err != nil {
+				return nil, err
+			}
+			opts = append(opts, otlp.WithTLSCredentials(credentials.NewTLS(tlsCfg)))
+		} else {
+			opts = append(opts, otlp.WithInsecure())
+		}
+		exp, err := otlp.NewExporter(opts...)
+		if err != nil {
+			return nil, err
+		}
+		bsp, err := sdktrace.NewBatchSpanProcessor(exp, sdktrace.WithBatchTimeout(cfg.BatchTimeout))
+		if err != nil {
+			exp.Stop()
+			return nil, err
+		}
+		tp.RegisterSpanProcessor(bsp)
+		stop = func() {
+			// the processor is shut down and flushes the spans after unregistered
+			tp.UnregisterSpanProcessor(bsp)
+			exp.Stop()
+		}
+	default:
+		return nil, fmt.Errorf("exporter (%s) not supported", cfg.Exporter)
+	}
+	global.SetTraceProvider(tp)
+	return stop, nil
+}
+
+// Tracer returns the tracer from the global trace provider
+func Tracer() apitrace.Tracer {
+	return global.Tracer(tracerName)
+}
+
+// StartSpan starts a span with the attributes
+func StartSpan(ctx context.Context, name string, attrs ...kv.KeyValue) (context.Context, apitrace.Span) {
+	return Tracer().Start(ctx, name, apitrace.WithAttributes(attrs...))
+}
+
+// EndSpan ends the span, the error is recorded and the status of span is set if not nil
+func EndSpan(ctx context.Context, span apitrace.Span, err error) {
+	if err != nil {
+		span.RecordError(ctx, err)
+		code := codes.Unknown
+		if s, ok := status.FromError(err); ok {
+			code = s.Code()
+		}
+		span.SetStatus(code, err.Error())
+	}
+	span.End()
+}
+
+// StartMQTTSpan starts a span of the mqtt publish, the kind is producer to publish and consumer to receive
+func StartMQTTSpan(ctx context.Context, pkt *mqtt.Publish, kind apitrace.SpanKind) (context.Context, apitrace.Span) {
+	return Tracer().Start(ctx, spanName("mqtt", pkt.Message.Topic, kind),
+		apitrace.WithSpanKind(kind),
+		apitrace.WithAttributes(
+			KeyMessagingSystem.String("mqtt"),
+			KeyMessagingDestination.String(pkt.Message.Topic),
+			KeyMessagingMessageID.Int64(int64(pkt.ID)),
+			KeyMessagingQOS.Int64(int64(pkt.Message.QOS)),
+		),
+	)
+}
+
+// StartLinkSpan starts a span of the link message, the kind is producer to send and consumer to receive
+func StartLinkSpan(ctx context.Context, msg *link.Message, kind apitrace.SpanKind) (context.Context, apitrace.Span) {
+	return Tracer().Start(ctx, spanName("link", msg.Context.Topic, kind),
+		apitrace.WithSpanKind(kind),
+		apitrace.WithAttributes(
+			KeyMessagingSystem.String("link"),
+			KeyMessagingDestination.String(msg.Context.Topic),
+			KeyMessagingMessageID.Uint64(msg.Context.ID),
+			KeyMessagingQOS.Int64(int64(msg.Context.QOS)),
+		),
+	)
+}
+
+// LinkInterceptor returns the interceptor of link server, which extracts the context from grpc metadata and starts server spans,
+// and starts consumer spans of the messages received by link service
+func LinkInterceptor() link.Interceptor {
+	return link.Interceptor{
+		Unary:   grpctrace.UnaryServerInterceptor(Tracer()),
+		Stream:  grpctrace.StreamServerInterceptor(Tracer()),
+		Receive: ReceiveLink,
+	}
+}
+
+// DialOptions returns the options of grpc client (such as link.NewClientConn), which start client spans and inject the context into grpc metadata
+func DialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(grpctrace.UnaryClientInterceptor(Tracer())),
+		grpc.WithChainStreamInterceptor(grpctrace.StreamClientInterceptor(Tracer())),
+	}
+}
+
+func spanName(system, topic string, kind apitrace.SpanKind) string {
+	if kind == apitrace.SpanKindProducer {
+		return fmt.Sprintf("%s send %s", system, topic)
+	}
+	return fmt.Sprintf("%s receive %s", system, topic)
+}
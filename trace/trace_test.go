@@ -0,0 +1,264 @@
+package trace
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/baetyl/baetyl-go/link"
+	"github.com/baetyl/baetyl-go/mqtt"
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/stretchr/testify/assert"
+	apitrace "go.opentelemetry.io/otel/api/trace"
+)
+
+type mockLink struct {
+	link.UnimplementedLinkServer
+	scs chan apitrace.SpanContext
+}
+
+func (s *mockLink) Call(ctx context.Context, msg *link.Message) (*link.Message, error) {
+	s.scs <- apitrace.SpanFromContext(ctx).SpanContext()
+	return msg, nil
+}
+
+func (s *mockLink) Talk(stream link.Link_TalkServer) error {
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		s.scs <- apitrace.RemoteSpanContextFromContext(ExtractLink(context.Background(), msg))
+	}
+}
+
+func TestInit(t *testing.T) {
+	var cfg Config
+	assert.NoError(t, utils.SetDefaults(&cfg))
+	assert.Equal(t, ExporterNone, cfg.Exporter)
+	assert.Equal(t, 1.0, cfg.Sampling)
+
+	cfg.Exporter = "unknown"
+	_, err := Init(cfg, "test")
+	assert.EqualError(t, err, "exporter (unknown) not supported")
+
+	// the exporter connects in background
+	cfg.Exporter = ExporterOTLP
+	cfg.Endpoint = "127.0.0.1:1"
+	stop, err := Init(cfg, "test")
+	assert.NoError(t, err)
+	stop()
+}
+
+func TestSpans(t *testing.T) {
+	var cfg Config
+	assert.NoError(t, utils.SetDefaults(&cfg))
+	stop, err := Init(cfg, "test")
+	assert.NoError(t, err)
+	defer stop()
+
+	ctx, rootSpan := StartSpan(context.Background(), "root")
+	defer rootSpan.End()
+	root := rootSpan.SpanContext()
+	assert.True(t, root.IsValid())
+	assert.True(t, root.IsSampled())
+
+	pkt := mqtt.NewPublish()
+	pkt.Message.Topic = "a/b"
+	_, span := StartMQTTSpan(ctx, pkt, apitrace.SpanKindProducer)
+	assert.Equal(t, root.TraceID, span.SpanContext().TraceID)
+	assert.NotEqual(t, root.SpanID, span.SpanContext().SpanID)
+	EndSpan(ctx, span, errors.New("failed"))
+
+	msg := &link.Message{}
+	msg.Context.Topic = "c"
+	_, span = StartLinkSpan(ctx, msg, apitrace.SpanKindConsumer)
+	assert.Equal(t, root.TraceID, span.SpanContext().TraceID)
+	EndSpan(ctx, span, nil)
+
+	// the context is propagated across link
+	var lc link.ServerConfig
+	assert.NoError(t, utils.SetDefaults(&lc))
+	s, err := link.NewServer(lc, nil, LinkInterceptor())
+	assert.NoError(t, err)
+	ms := &mockLink{scs: make(chan apitrace.SpanContext, 1)}
+	link.RegisterLinkServer(s, ms)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go s.Serve(lis)
+	defer s.Stop()
+
+	var cc link.ClientConfig
+	assert.NoError(t, utils.SetDefaults(&cc))
+	cc.Address = lis.Addr().String()
+	conn, err := link.NewClientConn(cc, DialOptions()...)
+	assert.NoError(t, err)
+	defer conn.Close()
+	_, err = link.NewLinkClient(conn).Call(ctx, msg)
+	assert.NoError(t, err)
+	sc := <-ms.scs
+	assert.Equal(t, root.TraceID, sc.TraceID)
+	assert.NotEqual(t, root.SpanID, sc.SpanID)
+}
+
+func TestPropagation(t *testing.T) {
+	var cfg Config
+	assert.NoError(t, utils.SetDefaults(&cfg))
+	assert.False(t, cfg.Propagation)
+	stop, err := Init(cfg, "test")
+	assert.NoError(t, err)
+	defer stop()
+
+	ctx, rootSpan := StartSpan(context.Background(), "root")
+	defer rootSpan.End()
+	root := rootSpan.SpanContext()
+
+	// link headers
+	msg := &link.Message{}
+	InjectLink(ctx, msg)
+	assert.NotEmpty(t, msg.Header("traceparent"))
+	sc := apitrace.RemoteSpanContextFromContext(ExtractLink(context.Background(), msg))
+	assert.Equal(t, root.TraceID, sc.TraceID)
+	assert.Equal(t, root.SpanID, sc.SpanID)
+
+	// mqtt user properties, the property of the same key is replaced
+	var props mqtt.Properties
+	props.AddUserProperty("a", "b")
+	InjectMQTT(ctx, &props)
+	InjectMQTT(ctx, &props)
+	assert.Len(t, props.UserProperties, 2)
+	sc = apitrace.RemoteSpanContextFromContext(ExtractMQTT(context.Background(), &props))
+	assert.Equal(t, root.TraceID, sc.TraceID)
+	assert.Equal(t, root.SpanID, sc.SpanID)
+
+	// no trace context
+	sc = apitrace.RemoteSpanContextFromContext(ExtractMQTT(context.Background(), &mqtt.Properties{}))
+	assert.False(t, sc.IsValid())
+
+	// the messages sent by link client carry the trace context
+	var lc link.ServerConfig
+	assert.NoError(t, utils.SetDefaults(&lc))
+	s, err := link.NewServer(lc, nil, LinkInterceptor())
+	assert.NoError(t, err)
+	ms := &mockLink{scs: make(chan apitrace.SpanContext, 1)}
+	link.RegisterLinkServer(s, ms)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go s.Serve(lis)
+	defer s.Stop()
+
+	var cc link.ClientConfig
+	assert.NoError(t, utils.SetDefaults(&cc))
+	cc.Address = lis.Addr().String()
+	cli, err := link.NewClient(LinkClientConfig(cc), nil)
+	assert.NoError(t, err)
+	defer cli.Close()
+
+	msg = &link.Message{Content: []byte("hi")}
+	assert.NoError(t, cli.SendContext(ctx, msg))
+	sc = <-ms.scs
+	assert.Equal(t, root.TraceID, sc.TraceID)
+	assert.Equal(t, root.SpanID, sc.SpanID)
+	// the message of caller is not modified
+	assert.Empty(t, msg.Header("traceparent"))
+
+	mc := MQTTClientConfig(mqtt.ClientConfig{})
+	assert.NotNil(t, mc.PropertiesInjector)
+}
+
+type mockService struct {
+	scs chan apitrace.SpanContext
+}
+
+func (s *mockService) OnCall(ctx context.Context, msg *link.Message) (*link.Message, error) {
+	return msg, nil
+}
+
+func (s *mockService) OnMsg(ss *link.ServiceStream, msg *link.Message) error {
+	s.scs <- apitrace.RemoteSpanContextFromContext(ExtractLink(context.Background(), msg))
+	// forwards the message back, which continues the trace
+	return ss.Send(msg)
+}
+
+func (s *mockService) OnAck(ss *link.ServiceStream, msg *link.Message) error {
+	return nil
+}
+
+type mockObserver struct {
+	scs chan apitrace.SpanContext
+}
+
+func (o *mockObserver) OnMsg(msg *link.Message) error {
+	o.scs <- apitrace.RemoteSpanContextFromContext(ExtractLink(context.Background(), msg))
+	return nil
+}
+
+func (o *mockObserver) OnAck(msg *link.Message) error {
+	return nil
+}
+
+func (o *mockObserver) OnErr(err error) {}
+
+func TestReceive(t *testing.T) {
+	var cfg Config
+	assert.NoError(t, utils.SetDefaults(&cfg))
+	stop, err := Init(cfg, "test")
+	assert.NoError(t, err)
+	defer stop()
+
+	ctx, rootSpan := StartSpan(context.Background(), "root")
+	defer rootSpan.End()
+	root := rootSpan.SpanContext()
+
+	// the consumer span is the child of the sender's, and the handler continues the trace of the consumer span
+	pkt := mqtt.NewPublish5()
+	pkt.Message.Topic = "a/b"
+	InjectMQTT(ctx, &pkt.Properties)
+	err = ReceiveMQTT(pkt, func() error {
+		sc := apitrace.RemoteSpanContextFromContext(ExtractMQTT(context.Background(), &pkt.Properties))
+		assert.Equal(t, root.TraceID, sc.TraceID)
+		assert.NotEqual(t, root.SpanID, sc.SpanID)
+		return errors.New("failed")
+	})
+	assert.EqualError(t, err, "failed")
+
+	// no trace context
+	pkt = mqtt.NewPublish5()
+	called := false
+	assert.NoError(t, ReceiveMQTT(pkt, func() error {
+		called = true
+		return nil
+	}))
+	assert.True(t, called)
+	assert.Empty(t, pkt.Properties.UserProperties)
+
+	mc := MQTTClientConfig(mqtt.ClientConfig{})
+	assert.NotNil(t, mc.ReceiveInterceptor)
+
+	// the messages received by link service and client
+	var lc link.ServerConfig
+	assert.NoError(t, utils.SetDefaults(&lc))
+	lc.Address = "127.0.0.1:0"
+	ms := &mockService{scs: make(chan apitrace.SpanContext, 1)}
+	svc, err := link.NewService(lc, ms, nil, LinkInterceptor())
+	assert.NoError(t, err)
+	defer svc.Close()
+
+	var cc link.ClientConfig
+	assert.NoError(t, utils.SetDefaults(&cc))
+	cc.Address = svc.Addr().String()
+	obs := &mockObserver{scs: make(chan apitrace.SpanContext, 1)}
+	cli, err := link.NewClient(LinkClientConfig(cc), obs)
+	assert.NoError(t, err)
+	defer cli.Close()
+
+	assert.NoError(t, cli.SendContext(ctx, &link.Message{Content: []byte("hi")}))
+	sc := <-ms.scs
+	assert.Equal(t, root.TraceID, sc.TraceID)
+	assert.NotEqual(t, root.SpanID, sc.SpanID)
+	sc2 := <-obs.scs
+	assert.Equal(t, root.TraceID, sc2.TraceID)
+	assert.NotEqual(t, root.SpanID, sc2.SpanID)
+	assert.NotEqual(t, sc.SpanID, sc2.SpanID)
+}
@@ -0,0 +1,78 @@
+package transfer
+
+import (
+	"context"
+
+	"github.com/baetyl/baetyl-go/link"
+	"github.com/baetyl/baetyl-go/log"
+	"google.golang.org/grpc"
+)
+
+// Client transfers files with the link server which serves transfers, each transfer takes a new stream
+type Client struct {
+	cfg Config
+	cli link.LinkClient
+	sem chan struct{}
+	log *log.Logger
+}
+
+// NewClient creates a new client by the link client, such as link.NewLinkClient(conn)
+func NewClient(cfg Config, cli link.LinkClient) *Client {
+	return &Client{
+		cfg: cfg,
+		cli: cli,
+		sem: make(chan struct{}, cfg.MaxConcurrent),
+		log: log.With(log.Any("transfer", "client")),
+	}
+}
+
+// Push sends the local file at the path to the server as the name, the transfer is resumed if pushed partially before
+func (c *Client) Push(ctx context.Context, path, name string, progress Progress) error {
+	name, err := CleanName(name)
+	if err != nil {
+		return err
+	}
+	return c.do(ctx, func(s link.Link_TalkClient) error {
+		return send(s, path, name, int(c.cfg.ChunkSize), progress)
+	})
+}
+
+// Pull receives the file of the name from the server into the local path, the transfer is resumed if pulled partially before
+func (c *Client) Pull(ctx context.Context, name, path string, progress Progress) error {
+	name, err := CleanName(name)
+	if err != nil {
+		return err
+	}
+	return c.do(ctx, func(s link.Link_TalkClient) error {
+		get := newMessage(OpGet)
+		get.SetHeader(HeaderName, name)
+		if err := s.Send(get); err != nil {
+			return err
+		}
+		open, err := recv(s, OpOpen)
+		if err != nil {
+			return reply(s, err)
+		}
+		return receive(s, open, path, progress)
+	})
+}
+
+// do runs the transfer on a new stream, waits if the transfers running reach the max concurrency
+func (c *Client) do(ctx context.Context, f func(link.Link_TalkClient) error) error {
+	select {
+	case c.sem <- struct{}{}:
+		defer func() { <-c.sem }()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	s, err := c.cli.Talk(ctx, grpc.WaitForReady(true))
+	if err != nil {
+		return err
+	}
+	if err = f(s); err != nil {
+		return err
+	}
+	return s.CloseSend()
+}
@@ -0,0 +1,10 @@
+package transfer
+
+import "github.com/baetyl/baetyl-go/utils"
+
+// Config the config of file transfer
+type Config struct {
+	Dir           string     `yaml:"dir" json:"dir" default:"var/lib/baetyl/transfer"` // the dir where the files are received into and served from by server
+	ChunkSize     utils.Size `yaml:"chunkSize" json:"chunkSize" default:"65536"`       // should be less than the max message size of link
+	MaxConcurrent int        `yaml:"maxConcurrent" json:"maxConcurrent" default:"4" validate:"min=1"`
+}
@@ -0,0 +1,71 @@
+package transfer
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/baetyl/baetyl-go/link"
+	"github.com/baetyl/baetyl-go/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Server serves the transfers of clients as a link server, the files are received into and served from the dir
+type Server struct {
+	cfg      Config
+	progress Progress
+	sem      chan struct{}
+	log      *log.Logger
+}
+
+// NewServer creates a new server, the progress is optional
+func NewServer(cfg Config, progress Progress) *Server {
+	return &Server{
+		cfg:      cfg,
+		progress: progress,
+		sem:      make(chan struct{}, cfg.MaxConcurrent),
+		log:      log.With(log.Any("transfer", "server")),
+	}
+}
+
+// Talk serves a transfer on the stream, waits if the transfers running reach the max concurrency
+func (s *Server) Talk(stream link.Link_TalkServer) error {
+	select {
+	case s.sem <- struct{}{}:
+		defer func() { <-s.sem }()
+	case <-stream.Context().Done():
+		return stream.Context().Err()
+	}
+	err := s.Serve(stream)
+	if err != nil {
+		s.log.Warn("failed to transfer file", log.Error(err))
+	}
+	return err
+}
+
+// Serve serves a transfer on the stream, which starts with a request of push (open) or pull (get)
+func (s *Server) Serve(stream Stream) error {
+	msg, err := recv(stream, OpOpen, OpGet)
+	if err != nil {
+		return reply(stream, err)
+	}
+	name, err := CleanName(msg.Header(HeaderName))
+	if err != nil {
+		return reply(stream, err)
+	}
+	path := filepath.Join(s.cfg.Dir, name)
+	if msg.Header(HeaderOp) == OpGet {
+		return reply(stream, send(stream, path, name, int(s.cfg.ChunkSize), s.progress))
+	}
+	msg.SetHeader(HeaderName, name)
+	if err = receive(stream, msg, path, s.progress); err != nil {
+		return err
+	}
+	s.log.Info("file is received", log.Any("name", name))
+	return nil
+}
+
+// Call is not supported
+func (s *Server) Call(context.Context, *link.Message) (*link.Message, error) {
+	return nil, status.Error(codes.Unimplemented, "call is not supported by transfer server")
+}
@@ -0,0 +1,287 @@
+// Package transfer transfers files reliably over the talk stream of link, the file is split into chunks,
+// the transfer interrupted is resumed from the bytes received, and the file is verified by sha256 checksum,
+// so that pushing models or configs to devices and pulling logs back use the same mechanism.
+//
+// A transfer takes a stream. The sender opens it with the name, size and checksum of file,
+// the receiver replies the offset to resume from, then the sender sends the chunks from the offset
+// and commits, the receiver verifies the file and replies the final status. To pull a file,
+// the receiver requests it first and the peer becomes the sender.
+package transfer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/baetyl/baetyl-go/link"
+)
+
+// all headers of transfer messages
+const (
+	HeaderOp       = "X-Transfer-Op"
+	HeaderName     = "X-Transfer-Name"
+	HeaderSize     = "X-Transfer-Size"
+	HeaderOffset   = "X-Transfer-Offset"
+	HeaderChecksum = "X-Transfer-Checksum"
+)
+
+// all operations of transfer messages
+const (
+	OpOpen   = "open"   // the sender opens the transfer
+	OpGet    = "get"    // the receiver requests the file
+	OpChunk  = "chunk"  // the sender sends a chunk at the offset
+	OpCommit = "commit" // the sender has sent all chunks
+	OpStatus = "status" // the receiver replies the bytes received
+	OpError  = "error"  // the peer fails, the content is the error message
+)
+
+// the suffix of file being received
+const partSuffix = ".part"
+
+// all errors
+var (
+	ErrNameInvalid      = errors.New("file name is invalid")
+	ErrChecksumMismatch = errors.New("checksum mismatched")
+	ErrUnexpectedOp     = errors.New("operation is unexpected")
+)
+
+// Stream the stream to transfer messages, such as link.Link_TalkClient and link.Link_TalkServer
+type Stream interface {
+	Send(*link.Message) error
+	Recv() (*link.Message, error)
+}
+
+// Progress the callback of progress, which is called after every chunk is transferred
+type Progress func(name string, done, total int64)
+
+// Error the error replied by the peer
+type Error struct {
+	Message string
+}
+
+func (e *Error) Error() string {
+	return "peer failed: " + e.Message
+}
+
+func newMessage(op string) *link.Message {
+	msg := &link.Message{}
+	msg.SetHeader(HeaderOp, op)
+	return msg
+}
+
+func headerInt(msg *link.Message, key string) (int64, error) {
+	v, err := strconv.ParseInt(msg.Header(key), 10, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("header (%s) is invalid", key)
+	}
+	return v, nil
+}
+
+// recv receives the message of the operations, the error replied by peer is returned as *Error
+func recv(s Stream, ops ...string) (*link.Message, error) {
+	msg, err := s.Recv()
+	if err != nil {
+		return nil, err
+	}
+	op := msg.Header(HeaderOp)
+	if op == OpError {
+		return nil, &Error{Message: string(msg.Content)}
+	}
+	for _, o := range ops {
+		if op == o {
+			return msg, nil
+		}
+	}
+	return nil, ErrUnexpectedOp
+}
+
+// sendMsg sends the message, the error replied by peer is received if the stream is aborted by peer
+func sendMsg(s Stream, msg *link.Message) error {
+	err := s.Send(msg)
+	if err != io.EOF {
+		return err
+	}
+	if _, rerr := s.Recv(); rerr != nil && rerr != io.EOF {
+		return rerr
+	}
+	return err
+}
+
+// reply replies the error to peer unless it is replied by peer, and returns the error
+func reply(s Stream, err error) error {
+	if _, ok := err.(*Error); ok || err == nil {
+		return err
+	}
+	msg := newMessage(OpError)
+	msg.Content = []byte(err.Error())
+	s.Send(msg)
+	return err
+}
+
+// CleanName cleans the name of file, which must be a relative path inside the dir
+func CleanName(name string) (string, error) {
+	name = filepath.Clean(filepath.FromSlash(name))
+	if name == "." || filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+		return "", ErrNameInvalid
+	}
+	return name, nil
+}
+
+func checksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// send sends the file at the path as the name
+func send(s Stream, path, name string, chunkSize int, progress Progress) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+	sum, err := checksum(path)
+	if err != nil {
+		return err
+	}
+	open := newMessage(OpOpen)
+	open.SetHeader(HeaderName, name)
+	open.SetHeader(HeaderSize, strconv.FormatInt(size, 10))
+	open.SetHeader(HeaderChecksum, sum)
+	if err = s.Send(open); err != nil {
+		return err
+	}
+	msg, err := recv(s, OpStatus)
+	if err != nil {
+		return err
+	}
+	offset, err := headerInt(msg, HeaderOffset)
+	if err != nil || offset > size {
+		return reply(s, fmt.Errorf("header (%s) is invalid", HeaderOffset))
+	}
+	if _, err = f.Seek(offset, io.SeekStart); err != nil {
+		return reply(s, err)
+	}
+	buf := make([]byte, chunkSize)
+	for offset < size {
+		n, err := io.ReadFull(f, buf)
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return reply(s, err)
+		}
+		chunk := newMessage(OpChunk)
+		chunk.SetHeader(HeaderOffset, strconv.FormatInt(offset, 10))
+		chunk.Content = buf[:n]
+		if err = sendMsg(s, chunk); err != nil {
+			return err
+		}
+		offset += int64(n)
+		if progress != nil {
+			progress(name, offset, size)
+		}
+	}
+	if err = sendMsg(s, newMessage(OpCommit)); err != nil {
+		return err
+	}
+	_, err = recv(s, OpStatus)
+	return err
+}
+
+// receive receives the file opened by the message into the path, resumes from the part received before
+func receive(s Stream, open *link.Message, path string, progress Progress) error {
+	name := open.Header(HeaderName)
+	size, err := headerInt(open, HeaderSize)
+	if err != nil {
+		return reply(s, err)
+	}
+	sum := open.Header(HeaderChecksum)
+	if b, err := hex.DecodeString(sum); err != nil || len(b) != sha256.Size {
+		return reply(s, fmt.Errorf("header (%s) is invalid", HeaderChecksum))
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return reply(s, err)
+	}
+	// the part is bound to the checksum, so that the part of a different file is not resumed
+	part := path + "." + sum[:16] + partSuffix
+	f, err := os.OpenFile(part, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return reply(s, err)
+	}
+	defer f.Close()
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return reply(s, err)
+	}
+	if offset > size {
+		if err = f.Truncate(0); err != nil {
+			return reply(s, err)
+		}
+		offset = 0
+	}
+	if _, err = f.Seek(offset, io.SeekStart); err != nil {
+		return reply(s, err)
+	}
+	status := newMessage(OpStatus)
+	status.SetHeader(HeaderOffset, strconv.FormatInt(offset, 10))
+	if err = s.Send(status); err != nil {
+		return err
+	}
+	for {
+		msg, err := recv(s, OpChunk, OpCommit)
+		if err != nil {
+			return reply(s, err)
+		}
+		if msg.Header(HeaderOp) == OpCommit {
+			break
+		}
+		o, err := headerInt(msg, HeaderOffset)
+		if err != nil {
+			return reply(s, err)
+		}
+		if o != offset || offset+int64(len(msg.Content)) > size {
+			return reply(s, fmt.Errorf("chunk at offset (%d) is unexpected", o))
+		}
+		if _, err = f.Write(msg.Content); err != nil {
+			return reply(s, err)
+		}
+		offset += int64(len(msg.Content))
+		if progress != nil {
+			progress(name, offset, size)
+		}
+	}
+	if err = f.Close(); err != nil {
+		return reply(s, err)
+	}
+	actual, err := checksum(part)
+	if err == nil && (offset != size || actual != sum) {
+		// the part is broken, which can't be resumed
+		os.Remove(part)
+		err = ErrChecksumMismatch
+	}
+	if err == nil {
+		err = os.Rename(part, path)
+	}
+	if err != nil {
+		return reply(s, err)
+	}
+	status = newMessage(OpStatus)
+	status.SetHeader(HeaderOffset, strconv.FormatInt(size, 10))
+	return s.Send(status)
+}
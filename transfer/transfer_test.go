@@ -0,0 +1,256 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/baetyl/baetyl-go/link"
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+type progresses struct {
+	dones []int64
+	mu    sync.Mutex
+}
+
+func (p *progresses) progress(name string, done, total int64) {
+	p.mu.Lock()
+	p.dones = append(p.dones, done)
+	p.mu.Unlock()
+}
+
+func (p *progresses) reset() []int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	dones := p.dones
+	p.dones = nil
+	return dones
+}
+
+func newTestTransfer(t *testing.T) (*Client, string, string, *progresses, func()) {
+	dir, err := ioutil.TempDir("", t.Name())
+	assert.NoError(t, err)
+
+	var cfg Config
+	assert.NoError(t, utils.SetDefaults(&cfg))
+	cfg.Dir = filepath.Join(dir, "server")
+	cfg.ChunkSize = 1024
+	sp := &progresses{}
+	var sc link.ServerConfig
+	assert.NoError(t, utils.SetDefaults(&sc))
+	svr, err := link.NewServer(sc, nil)
+	assert.NoError(t, err)
+	link.RegisterLinkServer(svr, NewServer(cfg, sp.progress))
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go svr.Serve(lis)
+
+	var cc link.ClientConfig
+	assert.NoError(t, utils.SetDefaults(&cc))
+	cc.Address = lis.Addr().String()
+	conn, err := link.NewClientConn(cc)
+	assert.NoError(t, err)
+	cli := NewClient(cfg, link.NewLinkClient(conn))
+	return cli, dir, cfg.Dir, sp, func() {
+		conn.Close()
+		svr.Stop()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestPushPull(t *testing.T) {
+	cli, dir, svrDir, sp, closer := newTestTransfer(t)
+	defer closer()
+
+	data := make([]byte, 2500)
+	rand.Read(data)
+	src := filepath.Join(dir, "model.bin")
+	assert.NoError(t, ioutil.WriteFile(src, data, 0644))
+
+	cp := &progresses{}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	assert.NoError(t, cli.Push(ctx, src, "models/model.bin", cp.progress))
+	res, err := ioutil.ReadFile(filepath.Join(svrDir, "models", "model.bin"))
+	assert.NoError(t, err)
+	assert.Equal(t, data, res)
+	assert.Equal(t, []int64{1024, 2048, 2500}, cp.reset())
+	assert.Equal(t, []int64{1024, 2048, 2500}, sp.reset())
+
+	// pull back
+	dst := filepath.Join(dir, "pulled", "model.bin")
+	assert.NoError(t, cli.Pull(ctx, "models/model.bin", dst, cp.progress))
+	res, err = ioutil.ReadFile(dst)
+	assert.NoError(t, err)
+	assert.Equal(t, data, res)
+	assert.Equal(t, []int64{1024, 2048, 2500}, cp.reset())
+	parts, _ := filepath.Glob(filepath.Join(dir, "pulled", "*"+partSuffix))
+	assert.Empty(t, parts)
+
+	// push concurrently
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			assert.NoError(t, cli.Push(ctx, src, name, nil))
+		}(string(rune('a' + i)))
+	}
+	wg.Wait()
+	res, err = ioutil.ReadFile(filepath.Join(svrDir, "h"))
+	assert.NoError(t, err)
+	assert.Equal(t, data, res)
+
+	// empty file
+	empty := filepath.Join(dir, "empty")
+	assert.NoError(t, ioutil.WriteFile(empty, nil, 0644))
+	assert.NoError(t, cli.Push(ctx, empty, "empty", nil))
+	res, err = ioutil.ReadFile(filepath.Join(svrDir, "empty"))
+	assert.NoError(t, err)
+	assert.Empty(t, res)
+}
+
+func TestResume(t *testing.T) {
+	cli, dir, svrDir, sp, closer := newTestTransfer(t)
+	defer closer()
+
+	data := make([]byte, 2500)
+	rand.Read(data)
+	src := filepath.Join(dir, "log.txt")
+	assert.NoError(t, ioutil.WriteFile(src, data, 0644))
+	sum, err := checksum(src)
+	assert.NoError(t, err)
+	part := filepath.Join(svrDir, "log.txt."+sum[:16]+partSuffix)
+	assert.NoError(t, os.MkdirAll(svrDir, 0755))
+
+	// resumed from the part received
+	assert.NoError(t, ioutil.WriteFile(part, data[:1500], 0644))
+	ctx := context.Background()
+	cp := &progresses{}
+	assert.NoError(t, cli.Push(ctx, src, "log.txt", cp.progress))
+	assert.Equal(t, []int64{2500}, cp.reset())
+	assert.Equal(t, []int64{2500}, sp.reset())
+	res, err := ioutil.ReadFile(filepath.Join(svrDir, "log.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, data, res)
+	_, err = os.Stat(part)
+	assert.True(t, os.IsNotExist(err))
+
+	// all received
+	assert.NoError(t, ioutil.WriteFile(part, data, 0644))
+	assert.NoError(t, cli.Push(ctx, src, "log.txt", cp.progress))
+	assert.Empty(t, cp.reset())
+
+	// the part is broken
+	broken := append([]byte{}, data[:1000]...)
+	broken[0] ^= 0xff
+	assert.NoError(t, ioutil.WriteFile(part, broken, 0644))
+	err = cli.Push(ctx, src, "log.txt", nil)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), ErrChecksumMismatch.Error())
+	_, err = os.Stat(part)
+	assert.True(t, os.IsNotExist(err))
+	// retry
+	assert.NoError(t, cli.Push(ctx, src, "log.txt", nil))
+
+	// the part is longer than file
+	assert.NoError(t, ioutil.WriteFile(part, append(data, data...), 0644))
+	assert.NoError(t, cli.Push(ctx, src, "log.txt", nil))
+	res, err = ioutil.ReadFile(filepath.Join(svrDir, "log.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, data, res)
+}
+
+func TestTransferError(t *testing.T) {
+	cli, dir, _, _, closer := newTestTransfer(t)
+	defer closer()
+
+	ctx := context.Background()
+	err := cli.Pull(ctx, "missing", filepath.Join(dir, "missing"), nil)
+	assert.IsType(t, &Error{}, err)
+	assert.Contains(t, err.Error(), "peer failed: open")
+
+	err = cli.Pull(ctx, "../etc/passwd", filepath.Join(dir, "passwd"), nil)
+	assert.Equal(t, ErrNameInvalid, err)
+	err = cli.Push(ctx, filepath.Join(dir, "missing"), "missing", nil)
+	assert.True(t, os.IsNotExist(err))
+
+	for _, name := range []string{"", ".", "..", "../a", "/a"} {
+		_, err = CleanName(name)
+		assert.Equal(t, ErrNameInvalid, err, name)
+	}
+	name, err := CleanName("a/../b/./c")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join("b", "c"), name)
+
+	// the server rejects the invalid requests
+	s := NewServer(Config{Dir: dir, ChunkSize: 1024, MaxConcurrent: 1}, nil)
+	for _, msg := range []*link.Message{
+		func() *link.Message {
+			m := newMessage(OpOpen)
+			m.SetHeader(HeaderName, "../a")
+			return m
+		}(),
+		func() *link.Message {
+			m := newMessage(OpOpen)
+			m.SetHeader(HeaderName, "a")
+			m.SetHeader(HeaderSize, "10")
+			m.SetHeader(HeaderChecksum, "../../a")
+			return m
+		}(),
+		newMessage(OpChunk),
+	} {
+		ms := &mockStream{in: []*link.Message{msg}}
+		assert.Error(t, s.Serve(ms))
+		assert.Len(t, ms.out, 1)
+		assert.Equal(t, OpError, ms.out[0].Header(HeaderOp))
+	}
+
+	// the chunk out of order
+	data := []byte("hello")
+	src := filepath.Join(dir, "hello")
+	assert.NoError(t, ioutil.WriteFile(src, data, 0644))
+	sum, _ := checksum(src)
+	open := newMessage(OpOpen)
+	open.SetHeader(HeaderName, "world")
+	open.SetHeader(HeaderSize, "5")
+	open.SetHeader(HeaderChecksum, sum)
+	chunk := newMessage(OpChunk)
+	chunk.SetHeader(HeaderOffset, "1")
+	chunk.Content = data
+	ms := &mockStream{in: []*link.Message{open, chunk}}
+	assert.EqualError(t, s.Serve(ms), "chunk at offset (1) is unexpected")
+	assert.Len(t, ms.out, 2)
+	assert.Equal(t, OpStatus, ms.out[0].Header(HeaderOp))
+	assert.Equal(t, "0", ms.out[0].Header(HeaderOffset))
+	assert.Equal(t, OpError, ms.out[1].Header(HeaderOp))
+	assert.True(t, bytes.Contains(ms.out[1].Content, []byte("unexpected")))
+}
+
+type mockStream struct {
+	in  []*link.Message
+	out []*link.Message
+}
+
+func (s *mockStream) Send(msg *link.Message) error {
+	s.out = append(s.out, msg)
+	return nil
+}
+
+func (s *mockStream) Recv() (*link.Message, error) {
+	if len(s.in) == 0 {
+		return nil, os.ErrClosed
+	}
+	msg := s.in[0]
+	s.in = s.in[1:]
+	return msg, nil
+}
@@ -0,0 +1,161 @@
+package tunnel
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"sync/atomic"
+	"time"
+
+	"github.com/baetyl/baetyl-go/link"
+	"github.com/baetyl/baetyl-go/log"
+)
+
+// ErrUnauthorized the session is not authorized
+var ErrUnauthorized = errors.New("session is unauthorized")
+
+// Authorizer authorizes the request to open a session, returns error to deny it
+type Authorizer func(*Request) error
+
+// Agent serves the sessions opened by the client over the tunnel, usually on the device
+type Agent struct {
+	cfg   AgentConfig
+	auth  Authorizer
+	log   *log.Logger
+	audit *log.Logger
+}
+
+// NewAgent creates a new agent, all sessions are denied if the authorizer is nil
+func NewAgent(cfg AgentConfig, auth Authorizer) *Agent {
+	return &Agent{
+		cfg:   cfg,
+		auth:  auth,
+		log:   log.With(log.Any("tunnel", "agent")),
+		audit: log.With(log.Any("tunnel", "audit")),
+	}
+}
+
+// Serve serves the sessions over the conn until the conn is failed, all sessions are closed then
+func (a *Agent) Serve(conn Conn) error {
+	m := newMux(conn, a.serve)
+	<-m.done()
+	m.close()
+	return m.err
+}
+
+func (a *Agent) serve(m *mux, msg *link.Message) {
+	req := newRequest(msg)
+	fields := []log.Field{
+		log.Any("session", req.ID),
+		log.Any("kind", req.Kind),
+		log.Any("target", req.Target),
+		log.Any("user", req.User),
+	}
+	s, err := m.add(req)
+	if err != nil {
+		a.log.Warn("failed to open session", append(fields, log.Error(err))...)
+		return
+	}
+	if a.auth == nil {
+		err = ErrUnauthorized
+	} else {
+		err = a.auth(req)
+	}
+	if err != nil {
+		a.audit.Warn("session is denied", append(fields, log.Error(err))...)
+		s.closeWith(0, err)
+		return
+	}
+
+	start := time.Now()
+	var code int
+	switch req.Kind {
+	case KindShell:
+		code, err = a.shell(s, fields)
+	case KindForward:
+		err = a.forward(s, fields)
+	default:
+		err = ErrKindInvalid
+	}
+	s.closeWith(code, err)
+	fields = append(fields,
+		log.Any("exitCode", code),
+		log.Any("bytesIn", atomic.LoadInt64(&s.read)),
+		log.Any("bytesOut", atomic.LoadInt64(&s.written)),
+		log.Any("duration", time.Since(start)),
+	)
+	if err != nil {
+		a.audit.Info("session is closed", append(fields, log.Error(err))...)
+	} else {
+		a.audit.Info("session is closed", fields...)
+	}
+}
+
+func (a *Agent) ready(s *Session, fields []log.Field) error {
+	a.audit.Info("session is opened", fields...)
+	return s.mux.send(newMessage(s.ID, OpReady))
+}
+
+// forward forwards the session to the target address
+func (a *Agent) forward(s *Session, fields []log.Field) error {
+	conn, err := net.DialTimeout("tcp", s.Target, a.cfg.DialTimeout)
+	if err != nil {
+		return err
+	}
+	if err = a.ready(s, fields); err != nil {
+		conn.Close()
+		return err
+	}
+	pipe(s, conn)
+	return nil
+}
+
+// shell runs the shell, whose stdin and output are the session
+func (a *Agent) shell(s *Session, fields []log.Field) (int, error) {
+	if len(a.cfg.Shell) == 0 {
+		return 0, errors.New("shell is not configured")
+	}
+	cmd := exec.Command(a.cfg.Shell[0], a.cfg.Shell[1:]...)
+	var rw io.ReadWriteCloser
+	var err error
+	if s.TTY {
+		var pty *os.File
+		pty, err = startPTY(cmd, s.Rows, s.Cols)
+		if err == nil {
+			rw = pty
+			s.setOnResize(func(rows, cols uint16) {
+				setWinsize(pty, rows, cols)
+			})
+		}
+	} else {
+		rw, err = startPipe(cmd)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err = a.ready(s, append(fields, log.Any("tty", s.TTY))); err != nil {
+		cmd.Process.Kill()
+		rw.Close()
+		cmd.Wait()
+		return 0, err
+	}
+	pipe(s, rw)
+	err = cmd.Wait()
+	if eerr, ok := err.(*exec.ExitError); ok {
+		return eerr.ExitCode(), nil
+	}
+	return 0, err
+}
+
+// pipe copies the data between the session and the conn until the conn is closed (the target disconnects or
+// the shell exits), the conn is closed once the session is closed
+func pipe(s *Session, conn io.ReadWriteCloser) {
+	go func() {
+		io.Copy(conn, s)
+		conn.Close()
+	}()
+	io.Copy(s, conn)
+	conn.Close()
+}
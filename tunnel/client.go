@@ -0,0 +1,116 @@
+package tunnel
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync/atomic"
+
+	"github.com/baetyl/baetyl-go/log"
+)
+
+// Client opens the sessions over the tunnel, usually in cloud where the agent connects to
+type Client struct {
+	ids uint64 // the last id of sessions, accessed atomically
+	mux *mux
+	log *log.Logger
+}
+
+// NewClient creates a new client over the conn
+func NewClient(conn Conn) *Client {
+	return &Client{
+		mux: newMux(conn, nil),
+		log: log.With(log.Any("tunnel", "client")),
+	}
+}
+
+// Shell opens an interactive shell, the terminal is allocated if tty is true and the size is set if positive
+func (c *Client) Shell(ctx context.Context, user string, tty bool, rows, cols uint16) (*Session, error) {
+	return c.Open(ctx, &Request{Kind: KindShell, User: user, TTY: tty, Rows: rows, Cols: cols})
+}
+
+// Dial opens a session forwarded to the target address by the agent
+func (c *Client) Dial(ctx context.Context, user, target string) (*Session, error) {
+	return c.Open(ctx, &Request{Kind: KindForward, User: user, Target: target})
+}
+
+// Open opens the session of the request, waits until it is started by agent, the id of request is assigned
+func (c *Client) Open(ctx context.Context, req *Request) (*Session, error) {
+	r := *req
+	r.ID = atomic.AddUint64(&c.ids, 1)
+	s, err := c.mux.add(&r)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.mux.send(r.message()); err != nil {
+		c.mux.remove(r.ID)
+		return nil, err
+	}
+	select {
+	case msg := <-s.in:
+		if msg.Header(HeaderOp) == OpReady {
+			return s, nil
+		}
+		s.closeByPeer(msg)
+		if s.err == nil {
+			s.err = ErrSessionClosed
+		}
+		return nil, s.err
+	case <-s.done:
+		return nil, s.err
+	case <-ctx.Done():
+		s.Close()
+		return nil, ctx.Err()
+	}
+}
+
+// Forward accepts the local connections of the listener and forwards them to the target address by the agent,
+// blocks until the listener is closed
+func (c *Client) Forward(lis net.Listener, user, target string) error {
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			s, err := c.Dial(context.Background(), user, target)
+			if err != nil {
+				c.log.Warn("failed to forward connection", log.Any("target", target), log.Error(err))
+				return
+			}
+			defer s.Close()
+			go func() {
+				io.Copy(conn, s)
+				conn.Close()
+			}()
+			io.Copy(s, conn)
+		}()
+	}
+}
+
+// Close closes all sessions, the conn should be closed by its owner
+func (c *Client) Close() error {
+	c.mux.close()
+	return nil
+}
+
+// Done returns the channel which is closed after the conn is failed, such as the agent disconnects
+func (c *Client) Done() <-chan struct{} {
+	return c.mux.done()
+}
+
+// Err returns the error of tunnel, nil if the tunnel is alive
+func (c *Client) Err() error {
+	select {
+	case <-c.mux.done():
+		c.mux.mu.Lock()
+		defer c.mux.mu.Unlock()
+		return c.mux.err
+	default:
+		return nil
+	}
+}
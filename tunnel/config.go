@@ -0,0 +1,9 @@
+package tunnel
+
+import "time"
+
+// AgentConfig the config of tunnel agent
+type AgentConfig struct {
+	Shell       []string      `yaml:"shell" json:"shell" default:"[\"/bin/sh\"]"` // the command of shell, the first element is the executable
+	DialTimeout time.Duration `yaml:"dialTimeout" json:"dialTimeout" default:"10s"`
+}
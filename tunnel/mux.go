@@ -0,0 +1,205 @@
+// Package tunnel multiplexes the interactive shells and the tcp port-forwards over a single message channel,
+// such as the talk stream of link or a websocket connection, which is dialed out by the agent on device,
+// so that the devices behind NAT can be debugged remotely.
+//
+// The client (usually in cloud) opens the sessions, and the agent (on device) serves them after authorized
+// by the explicit authorizer, every session opened or closed is written into the audit log.
+package tunnel
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+
+	"github.com/baetyl/baetyl-go/link"
+	"github.com/baetyl/baetyl-go/utils"
+)
+
+// all headers of tunnel messages
+const (
+	HeaderOp       = "X-Tunnel-Op"
+	HeaderKind     = "X-Tunnel-Kind"
+	HeaderTarget   = "X-Tunnel-Target"
+	HeaderUser     = "X-Tunnel-User"
+	HeaderTTY      = "X-Tunnel-Tty"
+	HeaderRows     = "X-Tunnel-Rows"
+	HeaderCols     = "X-Tunnel-Cols"
+	HeaderExitCode = "X-Tunnel-Exit-Code"
+)
+
+// all operations of tunnel messages, the id of message is the id of session
+const (
+	OpOpen   = "open"   // the client opens a session
+	OpReady  = "ready"  // the agent has started the session
+	OpData   = "data"   // the data of session
+	OpResize = "resize" // the client resizes the terminal of shell
+	OpClose  = "close"  // the peer closes the session, the content is the error message if failed
+)
+
+// all kinds of sessions
+const (
+	KindShell   = "shell"
+	KindForward = "forward"
+)
+
+// the max size of data message
+const maxDataSize = 32 * 1024
+
+// all errors
+var (
+	ErrTunnelClosed  = errors.New("tunnel is closed")
+	ErrSessionClosed = errors.New("session is closed")
+	ErrKindInvalid   = errors.New("session kind is invalid")
+)
+
+// Conn the message channel, such as link.Link_TalkClient, link.Link_TalkServer and the websocket conn wrapped
+type Conn interface {
+	Send(*link.Message) error
+	Recv() (*link.Message, error)
+}
+
+// Request the request to open a session
+type Request struct {
+	ID     uint64
+	Kind   string // shell or forward
+	Target string // the address to forward to
+	User   string // the user who opens the session, for authorization and audit
+	TTY    bool   // the shell is allocated a pseudo terminal if supported
+	Rows   uint16
+	Cols   uint16
+}
+
+func (r *Request) message() *link.Message {
+	msg := newMessage(r.ID, OpOpen)
+	msg.SetHeader(HeaderKind, r.Kind)
+	msg.SetHeader(HeaderTarget, r.Target)
+	msg.SetHeader(HeaderUser, r.User)
+	msg.SetHeader(HeaderTTY, strconv.FormatBool(r.TTY))
+	msg.SetHeader(HeaderRows, strconv.Itoa(int(r.Rows)))
+	msg.SetHeader(HeaderCols, strconv.Itoa(int(r.Cols)))
+	return msg
+}
+
+func newRequest(msg *link.Message) *Request {
+	tty, _ := strconv.ParseBool(msg.Header(HeaderTTY))
+	rows, _ := strconv.ParseUint(msg.Header(HeaderRows), 10, 16)
+	cols, _ := strconv.ParseUint(msg.Header(HeaderCols), 10, 16)
+	return &Request{
+		ID:     msg.Context.ID,
+		Kind:   msg.Header(HeaderKind),
+		Target: msg.Header(HeaderTarget),
+		User:   msg.Header(HeaderUser),
+		TTY:    tty,
+		Rows:   uint16(rows),
+		Cols:   uint16(cols),
+	}
+}
+
+func newMessage(id uint64, op string) *link.Message {
+	msg := &link.Message{}
+	msg.Context.ID = id
+	msg.SetHeader(HeaderOp, op)
+	return msg
+}
+
+// mux dispatches the messages of conn to the sessions
+type mux struct {
+	conn     Conn
+	sessions map[uint64]*Session
+	onOpen   func(*mux, *link.Message) // the agent serves the sessions opened, nil for client
+	err      error
+	mu       sync.Mutex
+	wmu      sync.Mutex // the send of conn is not safe for concurrent use
+	tomb     utils.Tomb
+}
+
+func newMux(conn Conn, onOpen func(*mux, *link.Message)) *mux {
+	m := &mux{
+		conn:     conn,
+		sessions: map[uint64]*Session{},
+		onOpen:   onOpen,
+	}
+	m.tomb.Go(m.receiving)
+	return m
+}
+
+func (m *mux) send(msg *link.Message) error {
+	m.wmu.Lock()
+	defer m.wmu.Unlock()
+	return m.conn.Send(msg)
+}
+
+func (m *mux) add(req *Request) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	if _, ok := m.sessions[req.ID]; ok {
+		return nil, errors.New("session id is duplicated")
+	}
+	s := newSession(m, req)
+	m.sessions[req.ID] = s
+	return s, nil
+}
+
+func (m *mux) remove(id uint64) {
+	m.mu.Lock()
+	delete(m.sessions, id)
+	m.mu.Unlock()
+}
+
+func (m *mux) get(id uint64) *Session {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.sessions[id]
+}
+
+func (m *mux) receiving() error {
+	for {
+		msg, err := m.conn.Recv()
+		if err != nil {
+			if err == io.EOF {
+				err = ErrTunnelClosed
+			}
+			m.fail(err)
+			return nil
+		}
+		if msg.Header(HeaderOp) == OpOpen {
+			if m.onOpen != nil {
+				go m.onOpen(m, msg)
+			}
+			continue
+		}
+		s := m.get(msg.Context.ID)
+		if s == nil {
+			continue
+		}
+		s.deliver(msg)
+	}
+}
+
+// fail closes all sessions with the error
+func (m *mux) fail(err error) {
+	m.mu.Lock()
+	if m.err == nil {
+		m.err = err
+	}
+	ss := m.sessions
+	m.sessions = map[uint64]*Session{}
+	m.mu.Unlock()
+	for _, s := range ss {
+		s.finish(err)
+	}
+}
+
+// close closes all sessions, the receiving stops after the conn is closed by its owner
+func (m *mux) close() {
+	m.fail(ErrTunnelClosed)
+}
+
+// done returns the channel closed after the conn is failed
+func (m *mux) done() <-chan struct{} {
+	return m.tomb.Dead()
+}
@@ -0,0 +1,63 @@
+package tunnel
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"unsafe"
+)
+
+// startPTY starts the command with a pseudo terminal, returns the master of terminal
+func startPTY(cmd *exec.Cmd, rows, cols uint16) (*os.File, error) {
+	ptm, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	var n uint32
+	if err = ioctl(ptm, syscall.TIOCGPTN, uintptr(unsafe.Pointer(&n))); err != nil {
+		ptm.Close()
+		return nil, err
+	}
+	var unlock int32
+	if err = ioctl(ptm, syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); err != nil {
+		ptm.Close()
+		return nil, err
+	}
+	pts, err := os.OpenFile(fmt.Sprintf("/dev/pts/%d", n), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		ptm.Close()
+		return nil, err
+	}
+	defer pts.Close()
+	if rows > 0 && cols > 0 {
+		setWinsize(ptm, rows, cols)
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = pts, pts, pts
+	if cmd.Env == nil {
+		cmd.Env = append(os.Environ(), "TERM=xterm")
+	}
+	// the terminal becomes the controlling terminal of the new session
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+	if err = cmd.Start(); err != nil {
+		ptm.Close()
+		return nil, err
+	}
+	return ptm, nil
+}
+
+// setWinsize sets the size of terminal
+func setWinsize(f *os.File, rows, cols uint16) error {
+	ws := struct {
+		Row, Col, X, Y uint16
+	}{Row: rows, Col: cols}
+	return ioctl(f, syscall.TIOCSWINSZ, uintptr(unsafe.Pointer(&ws)))
+}
+
+func ioctl(f *os.File, req, arg uintptr) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), req, arg)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+// +build !linux
+
+package tunnel
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// startPTY is not supported
+func startPTY(cmd *exec.Cmd, rows, cols uint16) (*os.File, error) {
+	return nil, errors.New("pseudo terminal is not supported")
+}
+
+// setWinsize is not supported
+func setWinsize(f *os.File, rows, cols uint16) error {
+	return nil
+}
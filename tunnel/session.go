@@ -0,0 +1,176 @@
+package tunnel
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/baetyl/baetyl-go/link"
+)
+
+// Session the session multiplexed over the tunnel, which is read and written as a stream
+type Session struct {
+	read    int64 // the bytes read, accessed atomically
+	written int64 // the bytes written, accessed atomically
+	Request
+	mux      *mux
+	in       chan *link.Message
+	buf      []byte
+	eof      bool
+	exitCode int
+	err      error // the error of session, nil if closed normally
+	onResize func(rows, cols uint16)
+	done     chan struct{}
+	once     sync.Once
+	rmu      sync.Mutex // serializes the reads
+	mu       sync.Mutex
+}
+
+func newSession(m *mux, req *Request) *Session {
+	return &Session{
+		Request: *req,
+		mux:     m,
+		in:      make(chan *link.Message, 64),
+		done:    make(chan struct{}),
+	}
+}
+
+// deliver delivers the message received, blocks if the session is not read in time
+func (s *Session) deliver(msg *link.Message) {
+	if msg.Header(HeaderOp) == OpResize {
+		rows, _ := strconv.ParseUint(msg.Header(HeaderRows), 10, 16)
+		cols, _ := strconv.ParseUint(msg.Header(HeaderCols), 10, 16)
+		s.mu.Lock()
+		onResize := s.onResize
+		s.mu.Unlock()
+		if onResize != nil {
+			onResize(uint16(rows), uint16(cols))
+		}
+		return
+	}
+	select {
+	case s.in <- msg:
+	case <-s.done:
+	}
+}
+
+func (s *Session) setOnResize(f func(rows, cols uint16)) {
+	s.mu.Lock()
+	s.onResize = f
+	s.mu.Unlock()
+}
+
+// Read reads the data sent by peer, returns io.EOF after the session is closed by peer
+func (s *Session) Read(p []byte) (int, error) {
+	s.rmu.Lock()
+	defer s.rmu.Unlock()
+	for len(s.buf) == 0 {
+		if s.eof {
+			return 0, io.EOF
+		}
+		select {
+		case msg := <-s.in:
+			switch msg.Header(HeaderOp) {
+			case OpData:
+				s.buf = msg.Content
+			case OpClose:
+				s.eof = true
+				s.closeByPeer(msg)
+			}
+		case <-s.done:
+			return 0, io.EOF
+		}
+	}
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+	atomic.AddInt64(&s.read, int64(n))
+	return n, nil
+}
+
+// Write writes the data to peer
+func (s *Session) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		select {
+		case <-s.done:
+			return n, ErrSessionClosed
+		default:
+		}
+		size := len(p)
+		if size > maxDataSize {
+			size = maxDataSize
+		}
+		msg := newMessage(s.ID, OpData)
+		msg.Content = append([]byte(nil), p[:size]...)
+		if err := s.mux.send(msg); err != nil {
+			return n, err
+		}
+		n += size
+		p = p[size:]
+		atomic.AddInt64(&s.written, int64(size))
+	}
+	return n, nil
+}
+
+// Resize resizes the terminal of shell
+func (s *Session) Resize(rows, cols uint16) error {
+	msg := newMessage(s.ID, OpResize)
+	msg.SetHeader(HeaderRows, strconv.Itoa(int(rows)))
+	msg.SetHeader(HeaderCols, strconv.Itoa(int(cols)))
+	return s.mux.send(msg)
+}
+
+// Close closes the session and notifies peer
+func (s *Session) Close() error {
+	return s.closeWith(0, nil)
+}
+
+// Wait waits the session to be closed, returns the error of session
+func (s *Session) Wait() error {
+	<-s.done
+	return s.err
+}
+
+// ExitCode returns the exit code of shell after the session is closed
+func (s *Session) ExitCode() int {
+	<-s.done
+	return s.exitCode
+}
+
+// closeWith closes the session with the exit code and the error, which are sent to peer
+func (s *Session) closeWith(code int, err error) error {
+	var serr error
+	s.once.Do(func() {
+		msg := newMessage(s.ID, OpClose)
+		msg.SetHeader(HeaderExitCode, strconv.Itoa(code))
+		if err != nil {
+			msg.Content = []byte(err.Error())
+		}
+		serr = s.mux.send(msg)
+		s.mux.remove(s.ID)
+		s.exitCode, s.err = code, err
+		close(s.done)
+	})
+	return serr
+}
+
+func (s *Session) closeByPeer(msg *link.Message) {
+	s.once.Do(func() {
+		s.mux.remove(s.ID)
+		s.exitCode, _ = strconv.Atoi(msg.Header(HeaderExitCode))
+		if len(msg.Content) > 0 {
+			s.err = errors.New(string(msg.Content))
+		}
+		close(s.done)
+	})
+}
+
+// finish finishes the session without notifying peer, since the tunnel is failed
+func (s *Session) finish(err error) {
+	s.once.Do(func() {
+		s.err = err
+		close(s.done)
+	})
+}
@@ -0,0 +1,50 @@
+package tunnel
+
+import (
+	"io"
+	"os"
+	"os/exec"
+)
+
+// pipeRW the stdin and the combined stdout and stderr of command
+type pipeRW struct {
+	stdin  io.WriteCloser
+	stdout *os.File
+}
+
+func (p *pipeRW) Read(b []byte) (int, error) {
+	return p.stdout.Read(b)
+}
+
+func (p *pipeRW) Write(b []byte) (int, error) {
+	return p.stdin.Write(b)
+}
+
+func (p *pipeRW) Close() error {
+	p.stdin.Close()
+	return p.stdout.Close()
+}
+
+// startPipe starts the command whose stdin and output are pipes, the stderr is merged into stdout,
+// the output is read until the command exits
+func startPipe(cmd *exec.Cmd) (io.ReadWriteCloser, error) {
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		stdin.Close()
+		return nil, err
+	}
+	cmd.Stdout = w
+	cmd.Stderr = w
+	err = cmd.Start()
+	w.Close()
+	if err != nil {
+		stdin.Close()
+		r.Close()
+		return nil, err
+	}
+	return &pipeRW{stdin: stdin, stdout: r}, nil
+}
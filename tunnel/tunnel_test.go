@@ -0,0 +1,358 @@
+package tunnel
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/baetyl/baetyl-go/link"
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// chanConn the conn over channels, the pair is closed together
+type chanConn struct {
+	in     <-chan *link.Message
+	out    chan<- *link.Message
+	closed chan struct{}
+	once   *sync.Once
+}
+
+func newChanConns() (*chanConn, *chanConn) {
+	a, b := make(chan *link.Message, 16), make(chan *link.Message, 16)
+	closed := make(chan struct{})
+	once := &sync.Once{}
+	return &chanConn{in: a, out: b, closed: closed, once: once}, &chanConn{in: b, out: a, closed: closed, once: once}
+}
+
+func (c *chanConn) Send(msg *link.Message) error {
+	select {
+	case c.out <- msg:
+		return nil
+	case <-c.closed:
+		return io.EOF
+	}
+}
+
+func (c *chanConn) Recv() (*link.Message, error) {
+	select {
+	case msg := <-c.in:
+		return msg, nil
+	case <-c.closed:
+		return nil, io.EOF
+	}
+}
+
+func (c *chanConn) Close() {
+	c.once.Do(func() { close(c.closed) })
+}
+
+func allowAll(*Request) error {
+	return nil
+}
+
+func newTestAgent(t *testing.T, auth Authorizer) *Agent {
+	var cfg AgentConfig
+	assert.NoError(t, utils.SetDefaults(&cfg))
+	assert.Equal(t, []string{"/bin/sh"}, cfg.Shell)
+	return NewAgent(cfg, auth)
+}
+
+func newTestTunnel(t *testing.T, auth Authorizer) (*Client, func()) {
+	ca, cb := newChanConns()
+	agent := newTestAgent(t, auth)
+	served := make(chan error, 1)
+	go func() {
+		served <- agent.Serve(cb)
+	}()
+	cli := NewClient(ca)
+	return cli, func() {
+		cli.Close()
+		ca.Close()
+		assert.Equal(t, ErrTunnelClosed, <-served)
+	}
+}
+
+func newEchoServer(t *testing.T) net.Listener {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return lis
+}
+
+func TestShell(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("shell is missing")
+	}
+	cli, closer := newTestTunnel(t, allowAll)
+	defer closer()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	s, err := cli.Shell(ctx, "alice", false, 0, 0)
+	assert.NoError(t, err)
+	_, err = s.Write([]byte("echo hello; echo world >&2; exit 3\n"))
+	assert.NoError(t, err)
+	out, err := ioutil.ReadAll(s)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello\nworld\n", string(out))
+	assert.NoError(t, s.Wait())
+	assert.Equal(t, 3, s.ExitCode())
+
+	// the shell is killed after the session is closed
+	s, err = cli.Shell(ctx, "alice", false, 0, 0)
+	assert.NoError(t, err)
+	assert.NoError(t, s.Close())
+	assert.NoError(t, s.Wait())
+	_, err = s.Write([]byte("exit\n"))
+	assert.Equal(t, ErrSessionClosed, err)
+}
+
+func TestShellTTY(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("shell is missing")
+	}
+	cli, closer := newTestTunnel(t, allowAll)
+	defer closer()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	s, err := cli.Shell(ctx, "alice", true, 24, 80)
+	if err != nil {
+		t.Skipf("pseudo terminal is not available: %s", err.Error())
+	}
+	assert.NoError(t, s.Resize(40, 120))
+	_, err = s.Write([]byte("stty size; exit 5\n"))
+	assert.NoError(t, err)
+	out, _ := ioutil.ReadAll(s)
+	assert.Contains(t, string(out), "40 120")
+	assert.Equal(t, 5, s.ExitCode())
+}
+
+func TestForward(t *testing.T) {
+	echo := newEchoServer(t)
+	defer echo.Close()
+	cli, closer := newTestTunnel(t, func(r *Request) error {
+		if r.Kind == KindForward && r.Target != echo.Addr().String() {
+			return errors.New("target is not allowed")
+		}
+		return nil
+	})
+	defer closer()
+
+	ctx := context.Background()
+	s, err := cli.Dial(ctx, "alice", echo.Addr().String())
+	assert.NoError(t, err)
+	data := bytes.Repeat([]byte("ping"), maxDataSize)
+	go s.Write(data)
+	res := make([]byte, len(data))
+	_, err = io.ReadFull(s, res)
+	assert.NoError(t, err)
+	assert.Equal(t, data, res)
+	assert.NoError(t, s.Close())
+
+	_, err = cli.Dial(ctx, "alice", "127.0.0.1:1")
+	assert.EqualError(t, err, "target is not allowed")
+
+	// forward the local connections
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go cli.Forward(lis, "alice", echo.Addr().String())
+	defer lis.Close()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, err := net.Dial("tcp", lis.Addr().String())
+			assert.NoError(t, err)
+			defer conn.Close()
+			_, err = conn.Write([]byte("hello"))
+			assert.NoError(t, err)
+			res := make([]byte, 5)
+			_, err = io.ReadFull(conn, res)
+			assert.NoError(t, err)
+			assert.Equal(t, "hello", string(res))
+		}()
+	}
+	wg.Wait()
+
+	// the target disconnects
+	target, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer target.Close()
+	go func() {
+		conn, err := target.Accept()
+		if err == nil {
+			conn.Write([]byte("bye"))
+			conn.Close()
+		}
+	}()
+	cli2, closer2 := newTestTunnel(t, allowAll)
+	defer closer2()
+	s, err = cli2.Dial(ctx, "alice", target.Addr().String())
+	assert.NoError(t, err)
+	out, err := ioutil.ReadAll(s)
+	assert.NoError(t, err)
+	assert.Equal(t, "bye", string(out))
+	assert.NoError(t, s.Wait())
+}
+
+func TestDenied(t *testing.T) {
+	cli, closer := newTestTunnel(t, nil)
+	defer closer()
+
+	ctx := context.Background()
+	_, err := cli.Shell(ctx, "bob", false, 0, 0)
+	assert.EqualError(t, err, ErrUnauthorized.Error())
+
+	cli2, closer2 := newTestTunnel(t, allowAll)
+	defer closer2()
+	_, err = cli2.Open(ctx, &Request{Kind: "unknown"})
+	assert.EqualError(t, err, ErrKindInvalid.Error())
+	_, err = cli2.Dial(ctx, "alice", "127.0.0.1:1")
+	assert.Error(t, err)
+}
+
+func TestTunnelClosed(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("shell is missing")
+	}
+	ca, cb := newChanConns()
+	agent := newTestAgent(t, allowAll)
+	served := make(chan error, 1)
+	go func() {
+		served <- agent.Serve(cb)
+	}()
+	cli := NewClient(ca)
+	assert.NoError(t, cli.Err())
+
+	s, err := cli.Shell(context.Background(), "alice", false, 0, 0)
+	assert.NoError(t, err)
+	ca.Close()
+	<-cli.Done()
+	assert.Equal(t, ErrTunnelClosed, cli.Err())
+	assert.Equal(t, ErrTunnelClosed, s.Wait())
+	assert.Equal(t, ErrTunnelClosed, <-served)
+	_, err = cli.Shell(context.Background(), "alice", false, 0, 0)
+	assert.Equal(t, ErrTunnelClosed, err)
+
+	// the context is canceled
+	ca, cb = newChanConns()
+	defer ca.Close()
+	cli = NewClient(ca)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = cli.Shell(ctx, "alice", false, 0, 0)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestWebsocket(t *testing.T) {
+	echo := newEchoServer(t)
+	defer echo.Close()
+
+	agent := newTestAgent(t, allowAll)
+	upgrader := websocket.Upgrader{}
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		agent.Serve(NewWebsocketConn(ws))
+	}))
+	defer svr.Close()
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(svr.URL, "http"), nil)
+	assert.NoError(t, err)
+	defer ws.Close()
+	cli := NewClient(NewWebsocketConn(ws))
+	defer cli.Close()
+
+	s, err := cli.Dial(context.Background(), "alice", echo.Addr().String())
+	assert.NoError(t, err)
+	_, err = s.Write([]byte("hello"))
+	assert.NoError(t, err)
+	res := make([]byte, 5)
+	_, err = io.ReadFull(s, res)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(res))
+	assert.NoError(t, s.Close())
+}
+
+type talkServer struct {
+	clients chan *Client
+}
+
+func (s *talkServer) Talk(stream link.Link_TalkServer) error {
+	cli := NewClient(stream)
+	s.clients <- cli
+	<-cli.Done()
+	return nil
+}
+
+func (s *talkServer) Call(context.Context, *link.Message) (*link.Message, error) {
+	return nil, errors.New("not supported")
+}
+
+func TestLink(t *testing.T) {
+	echo := newEchoServer(t)
+	defer echo.Close()
+
+	// the agent on device connects to the link server in cloud
+	var sc link.ServerConfig
+	assert.NoError(t, utils.SetDefaults(&sc))
+	svr, err := link.NewServer(sc, nil)
+	assert.NoError(t, err)
+	ts := &talkServer{clients: make(chan *Client, 1)}
+	link.RegisterLinkServer(svr, ts)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go svr.Serve(lis)
+	defer svr.Stop()
+
+	var cc link.ClientConfig
+	assert.NoError(t, utils.SetDefaults(&cc))
+	cc.Address = lis.Addr().String()
+	conn, err := link.NewClientConn(cc)
+	assert.NoError(t, err)
+	defer conn.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := link.NewLinkClient(conn).Talk(ctx)
+	assert.NoError(t, err)
+	go newTestAgent(t, allowAll).Serve(stream)
+
+	cli := <-ts.clients
+	s, err := cli.Dial(context.Background(), "alice", echo.Addr().String())
+	assert.NoError(t, err)
+	_, err = s.Write([]byte("hello"))
+	assert.NoError(t, err)
+	res := make([]byte, 5)
+	_, err = io.ReadFull(s, res)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(res))
+	assert.NoError(t, s.Close())
+}
@@ -0,0 +1,41 @@
+package tunnel
+
+import (
+	"errors"
+
+	"github.com/baetyl/baetyl-go/link"
+	"github.com/gorilla/websocket"
+)
+
+// wsConn the websocket conn which transfers the link messages in protobuf as binary messages
+type wsConn struct {
+	ws *websocket.Conn
+}
+
+// NewWebsocketConn wraps the websocket conn as the conn of tunnel
+func NewWebsocketConn(ws *websocket.Conn) Conn {
+	return &wsConn{ws: ws}
+}
+
+func (c *wsConn) Send(msg *link.Message) error {
+	data, err := msg.Marshal()
+	if err != nil {
+		return err
+	}
+	return c.ws.WriteMessage(websocket.BinaryMessage, data)
+}
+
+func (c *wsConn) Recv() (*link.Message, error) {
+	t, data, err := c.ws.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	if t != websocket.BinaryMessage {
+		return nil, errors.New("message type is invalid")
+	}
+	msg := &link.Message{}
+	if err = msg.Unmarshal(data); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
@@ -0,0 +1,28 @@
+package upload
+
+import (
+	"time"
+
+	"github.com/baetyl/baetyl-go/queue"
+	"github.com/baetyl/baetyl-go/utils"
+)
+
+// all compressions of batch
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+)
+
+// Config the config of uploader, the batch is closed once it is full (by count or size) or the interval is elapsed
+type Config struct {
+	MaxCount    int           `yaml:"maxCount" json:"maxCount" default:"100" validate:"min=1"`
+	MaxSize     utils.Size    `yaml:"maxSize" json:"maxSize" default:"65536"` // the max size of batch before compressed
+	Interval    time.Duration `yaml:"interval" json:"interval" default:"5s"`
+	Compression string        `yaml:"compression" json:"compression" default:"gzip" validate:"regexp=^(none|gzip)$"`
+	MaxPending  int           `yaml:"maxPending" json:"maxPending" default:"100" validate:"min=1"` // the max batches kept in memory, the oldest one is dropped if exceeded
+	Timeout     time.Duration `yaml:"timeout" json:"timeout" default:"30s"`
+	MaxInterval time.Duration `yaml:"maxInterval" json:"maxInterval" default:"2m"` // the max interval to retry after failed to send
+	// the batches are spilled to the disk queue instead of memory if enabled, which survive restarts
+	SpillEnabled bool         `yaml:"spillEnabled" json:"spillEnabled"`
+	Spill        queue.Config `yaml:"spill" json:"spill"`
+}
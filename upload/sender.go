@@ -0,0 +1,59 @@
+package upload
+
+import (
+	"context"
+	gohttp "net/http"
+	"strconv"
+
+	"github.com/baetyl/baetyl-go/codec"
+	"github.com/baetyl/baetyl-go/http"
+	"github.com/baetyl/baetyl-go/link"
+)
+
+// all headers of batch sent
+const (
+	HeaderContentEncoding = "Content-Encoding"
+	HeaderBatchCount      = "X-Baetyl-Batch-Count"
+)
+
+// Sender sends the batch
+type Sender interface {
+	Send(context.Context, *Batch) error
+}
+
+// SenderFunc the function to send
+type SenderFunc func(context.Context, *Batch) error
+
+// Send calls the function
+func (f SenderFunc) Send(ctx context.Context, b *Batch) error {
+	return f(ctx, b)
+}
+
+// NewLinkSender creates a sender which calls the link server (usually by a link client) with the batch as content,
+// the compression is set as the content encoding of message and the count is set in headers
+func NewLinkSender(caller link.Caller, topic string) Sender {
+	return SenderFunc(func(ctx context.Context, b *Batch) error {
+		msg := &link.Message{Content: b.Data}
+		msg.Context.Topic = topic
+		msg.Context.ContentType = codec.ContentTypeJSON
+		msg.Context.ContentEncoding = b.Encoding
+		msg.SetHeader(HeaderBatchCount, strconv.Itoa(b.Count))
+		_, err := caller.CallContext(ctx, msg)
+		return err
+	})
+}
+
+// NewHTTPSender creates a sender which posts the batch to the path by the http client
+func NewHTTPSender(cli *http.Client, path string) Sender {
+	return SenderFunc(func(ctx context.Context, b *Batch) error {
+		header := map[string]string{
+			http.HeaderContentType: http.ContentTypeJSON,
+			HeaderBatchCount:       strconv.Itoa(b.Count),
+		}
+		if b.Encoding != "" {
+			header[HeaderContentEncoding] = b.Encoding
+		}
+		_, err := cli.CallContext(ctx, gohttp.MethodPost, path, b.Data, header)
+		return err
+	})
+}
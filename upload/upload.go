@@ -0,0 +1,339 @@
+// Package upload aggregates the outbound messages, such as the telemetry of high-frequency sensors,
+// into batches bounded by time and size, compresses and sends them via link or http with retry,
+// which dramatically reduces the per-message overhead. The batches pending can be spilled to disk.
+package upload
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/baetyl/baetyl-go/log"
+	"github.com/baetyl/baetyl-go/queue"
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/jpillora/backoff"
+)
+
+// all errors
+var (
+	ErrUploaderClosed = errors.New("uploader is closed")
+	ErrBatchInvalid   = errors.New("batch is invalid")
+)
+
+// Batch the batch of messages, the data is the json array of messages compressed by the encoding
+type Batch struct {
+	Count    int
+	Encoding string // empty if not compressed
+	Data     []byte
+}
+
+// Decode decompresses the data and decodes the messages
+func (b *Batch) Decode() ([]json.RawMessage, error) {
+	data := b.Data
+	if b.Encoding == CompressionGzip {
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if _, err = buf.ReadFrom(r); err != nil {
+			return nil, err
+		}
+		data = buf.Bytes()
+	}
+	var msgs []json.RawMessage
+	err := json.Unmarshal(data, &msgs)
+	return msgs, err
+}
+
+// Uploader aggregates the messages into batches and sends them by the sender in order
+type Uploader struct {
+	cfg     Config
+	snd     Sender
+	items   [][]byte
+	size    int
+	gen     uint64 // the generation of batch, increased once a batch is closed
+	started chan batchStart
+	pending chan *Batch
+	spill   *queue.Queue
+	closed  bool
+	mu      sync.Mutex
+	ctx     context.Context
+	cancel  context.CancelFunc
+	log     *log.Logger
+	tomb    utils.Tomb
+}
+
+// NewUploader creates a new uploader and starts to send in background
+func NewUploader(cfg Config, snd Sender) (*Uploader, error) {
+	u := &Uploader{
+		cfg:     cfg,
+		snd:     snd,
+		started: make(chan batchStart, 1),
+		log:     log.With(log.Any("upload", "uploader")),
+	}
+	if cfg.SpillEnabled {
+		q, err := queue.New(cfg.Spill)
+		if err != nil {
+			return nil, err
+		}
+		u.spill = q
+	} else {
+		u.pending = make(chan *Batch, cfg.MaxPending)
+	}
+	u.ctx, u.cancel = context.WithCancel(context.Background())
+	u.tomb.Go(u.batching, u.sending)
+	return u, nil
+}
+
+// Add adds the value encoded in json into the current batch
+func (u *Uploader) Add(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return u.AddRaw(data)
+}
+
+// AddRaw adds the json data into the current batch, the batch is closed if it is full
+func (u *Uploader) AddRaw(data []byte) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.closed {
+		return ErrUploaderClosed
+	}
+	// the size of json array, including the brackets and the commas
+	if len(u.items) > 0 && u.size+len(data)+1 > int(u.cfg.MaxSize) {
+		if err := u.flush(); err != nil {
+			return err
+		}
+	}
+	u.items = append(u.items, data)
+	u.size += len(data) + 1
+	if len(u.items) == 1 {
+		// replaces the start of batch closed before which is not taken yet
+		select {
+		case <-u.started:
+		default:
+		}
+		u.started <- batchStart{gen: u.gen, at: time.Now()}
+	}
+	if len(u.items) >= u.cfg.MaxCount || u.size+1 >= int(u.cfg.MaxSize) {
+		return u.flush()
+	}
+	return nil
+}
+
+// Flush closes the current batch immediately instead of waiting for the interval
+func (u *Uploader) Flush() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.closed {
+		return ErrUploaderClosed
+	}
+	return u.flush()
+}
+
+// Close closes the current batch and stops sending, the batches pending in memory are dropped
+// and the ones spilled to disk are sent after the uploader is created again
+func (u *Uploader) Close() error {
+	u.mu.Lock()
+	if u.closed {
+		u.mu.Unlock()
+		return nil
+	}
+	err := u.flush()
+	if err != nil {
+		u.log.Warn("failed to flush batch", log.Error(err))
+	}
+	u.closed = true
+	u.mu.Unlock()
+
+	u.tomb.Kill(nil)
+	u.cancel()
+	err = u.tomb.Wait()
+	if u.spill != nil {
+		if cerr := u.spill.Close(); cerr != nil {
+			u.log.Warn("failed to close spill queue", log.Error(cerr))
+		}
+	} else if n := len(u.pending); n > 0 {
+		u.log.Warn("batches pending are dropped", log.Any("count", n))
+	}
+	return err
+}
+
+// flush closes the current batch and puts it into pending, must be called with lock
+func (u *Uploader) flush() error {
+	if len(u.items) == 0 {
+		return nil
+	}
+	b, err := u.encode(u.items)
+	u.items = nil
+	u.size = 0
+	u.gen++
+	if err != nil {
+		return err
+	}
+	if u.spill != nil {
+		_, err = u.spill.Push(marshalBatch(b))
+		return err
+	}
+	for {
+		select {
+		case u.pending <- b:
+			return nil
+		default:
+		}
+		select {
+		case old := <-u.pending:
+			u.log.Warn("the oldest batch pending is dropped", log.Any("count", old.Count))
+		default:
+		}
+	}
+}
+
+func (u *Uploader) encode(items [][]byte) (*Batch, error) {
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	var zw *gzip.Writer
+	b := &Batch{Count: len(items)}
+	if u.cfg.Compression == CompressionGzip {
+		zw = gzip.NewWriter(&buf)
+		w = zw
+		b.Encoding = CompressionGzip
+	}
+	w.Write([]byte{'['})
+	for i, item := range items {
+		if i > 0 {
+			w.Write([]byte{','})
+		}
+		w.Write(item)
+	}
+	w.Write([]byte{']'})
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+	}
+	b.Data = buf.Bytes()
+	return b, nil
+}
+
+type batchStart struct {
+	gen uint64
+	at  time.Time
+}
+
+// batching closes the batch once the interval is elapsed since its first message added
+func (u *Uploader) batching() error {
+	for {
+		var bs batchStart
+		select {
+		case bs = <-u.started:
+		case <-u.tomb.Dying():
+			return nil
+		}
+		t := time.NewTimer(u.cfg.Interval - time.Since(bs.at))
+		select {
+		case <-t.C:
+		case <-u.tomb.Dying():
+			t.Stop()
+			return nil
+		}
+		u.mu.Lock()
+		if u.gen == bs.gen && !u.closed {
+			if err := u.flush(); err != nil {
+				u.log.Error("failed to flush batch", log.Error(err))
+			}
+		}
+		u.mu.Unlock()
+	}
+}
+
+// sending sends the batches pending in order, retries with backoff until succeeded
+func (u *Uploader) sending() error {
+	bf := backoff.Backoff{
+		Min:    time.Second,
+		Max:    u.cfg.MaxInterval,
+		Factor: 1.6,
+	}
+	for {
+		b, ack, err := u.next()
+		if err != nil {
+			if u.ctx.Err() != nil {
+				return nil
+			}
+			u.log.Error("failed to read batch", log.Error(err))
+			continue
+		}
+		for {
+			ctx, cancel := context.WithTimeout(u.ctx, u.cfg.Timeout)
+			err = u.snd.Send(ctx, b)
+			cancel()
+			if err == nil {
+				break
+			}
+			d := bf.Duration()
+			u.log.Warn("failed to send batch, retry later", log.Any("count", b.Count), log.Any("after", d), log.Error(err))
+			select {
+			case <-time.After(d):
+			case <-u.tomb.Dying():
+				return nil
+			}
+		}
+		bf.Reset()
+		if err = ack(); err != nil {
+			u.log.Warn("failed to ack batch", log.Error(err))
+		}
+	}
+}
+
+func (u *Uploader) next() (*Batch, func() error, error) {
+	if u.spill == nil {
+		select {
+		case b := <-u.pending:
+			return b, func() error { return nil }, nil
+		case <-u.ctx.Done():
+			return nil, nil, u.ctx.Err()
+		}
+	}
+	m, err := u.spill.Pop(u.ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	b, err := unmarshalBatch(m.Data)
+	if err != nil {
+		// the broken batch is skipped
+		u.spill.Ack(m.Offset)
+		return nil, nil, err
+	}
+	return b, func() error { return u.spill.Ack(m.Offset) }, nil
+}
+
+// the batch spilled is the count (4 bytes), the length of encoding (1 byte), the encoding and the data
+func marshalBatch(b *Batch) []byte {
+	buf := make([]byte, 5+len(b.Encoding)+len(b.Data))
+	binary.BigEndian.PutUint32(buf, uint32(b.Count))
+	buf[4] = byte(len(b.Encoding))
+	n := copy(buf[5:], b.Encoding)
+	copy(buf[5+n:], b.Data)
+	return buf
+}
+
+func unmarshalBatch(data []byte) (*Batch, error) {
+	if len(data) < 5 || len(data) < 5+int(data[4]) {
+		return nil, ErrBatchInvalid
+	}
+	n := 5 + int(data[4])
+	return &Batch{
+		Count:    int(binary.BigEndian.Uint32(data)),
+		Encoding: string(data[5:n]),
+		Data:     data[n:],
+	}, nil
+}
@@ -0,0 +1,174 @@
+package upload
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	gohttp "net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/baetyl/baetyl-go/http"
+	"github.com/baetyl/baetyl-go/link"
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSender struct {
+	batches chan *Batch
+	fails   int
+}
+
+func (s *fakeSender) Send(_ context.Context, b *Batch) error {
+	if s.fails > 0 {
+		s.fails--
+		return errors.New("failed")
+	}
+	s.batches <- b
+	return nil
+}
+
+func newTestConfig(t *testing.T) Config {
+	var cfg Config
+	assert.NoError(t, utils.SetDefaults(&cfg))
+	return cfg
+}
+
+func decode(t *testing.T, b *Batch) []string {
+	msgs, err := b.Decode()
+	assert.NoError(t, err)
+	var res []string
+	for _, m := range msgs {
+		res = append(res, string(m))
+	}
+	return res
+}
+
+func TestUploader(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.MaxCount = 3
+	cfg.MaxSize = 20
+	cfg.Interval = 100 * time.Millisecond
+	snd := &fakeSender{batches: make(chan *Batch, 10)}
+	u, err := NewUploader(cfg, snd)
+	assert.NoError(t, err)
+
+	// closed by count
+	assert.NoError(t, u.Add(1))
+	assert.NoError(t, u.Add(2))
+	assert.NoError(t, u.Add(3))
+	b := <-snd.batches
+	assert.Equal(t, 3, b.Count)
+	assert.Equal(t, CompressionGzip, b.Encoding)
+	assert.Equal(t, []string{"1", "2", "3"}, decode(t, b))
+
+	// closed by size
+	assert.NoError(t, u.Add("aaaaaaaa"))
+	assert.NoError(t, u.Add("bbbbbbbb"))
+	b = <-snd.batches
+	assert.Equal(t, []string{`"aaaaaaaa"`}, decode(t, b))
+
+	// closed by interval
+	start := time.Now()
+	b = <-snd.batches
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+	assert.Equal(t, []string{`"bbbbbbbb"`}, decode(t, b))
+
+	// closed by flush
+	assert.NoError(t, u.AddRaw([]byte(`{"a":1}`)))
+	assert.NoError(t, u.Flush())
+	b = <-snd.batches
+	assert.Equal(t, []string{`{"a":1}`}, decode(t, b))
+
+	assert.NoError(t, u.Close())
+	assert.NoError(t, u.Close())
+	assert.Equal(t, ErrUploaderClosed, u.Add(1))
+	assert.Equal(t, ErrUploaderClosed, u.Flush())
+}
+
+func TestUploaderRetryAndDrop(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.MaxCount = 1
+	cfg.MaxPending = 2
+	cfg.Compression = CompressionNone
+	snd := &fakeSender{batches: make(chan *Batch, 10), fails: 1}
+	u, err := NewUploader(cfg, snd)
+	assert.NoError(t, err)
+	defer u.Close()
+
+	// the first batch is taken and retried, the oldest pending one is dropped
+	assert.NoError(t, u.Add(1))
+	time.Sleep(100 * time.Millisecond)
+	for i := 2; i <= 4; i++ {
+		assert.NoError(t, u.Add(i))
+	}
+	for _, expected := range []string{"[1]", "[3]", "[4]"} {
+		b := <-snd.batches
+		assert.Equal(t, "", b.Encoding)
+		assert.Equal(t, expected, string(b.Data))
+	}
+}
+
+func TestUploaderSpill(t *testing.T) {
+	dir, err := ioutil.TempDir("", t.Name())
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cfg := newTestConfig(t)
+	cfg.MaxCount = 2
+	cfg.SpillEnabled = true
+	cfg.Spill.Dir = dir
+	failing := SenderFunc(func(context.Context, *Batch) error { return errors.New("offline") })
+	u, err := NewUploader(cfg, failing)
+	assert.NoError(t, err)
+	assert.NoError(t, u.Add(1))
+	assert.NoError(t, u.Add(2))
+	assert.NoError(t, u.Add(3))
+	assert.NoError(t, u.Close())
+
+	// the batches spilled are sent after created again
+	snd := &fakeSender{batches: make(chan *Batch, 10)}
+	u, err = NewUploader(cfg, snd)
+	assert.NoError(t, err)
+	defer u.Close()
+	assert.Equal(t, []string{"1", "2"}, decode(t, <-snd.batches))
+	assert.Equal(t, []string{"3"}, decode(t, <-snd.batches))
+
+	_, err = unmarshalBatch([]byte{0, 0, 0, 1, 9})
+	assert.Equal(t, ErrBatchInvalid, err)
+}
+
+func TestSenders(t *testing.T) {
+	b := &Batch{Count: 2, Encoding: CompressionGzip, Data: []byte("data")}
+
+	var msg *link.Message
+	snd := NewLinkSender(link.CallerFunc(func(_ context.Context, m *link.Message) (*link.Message, error) {
+		msg = m
+		return &link.Message{}, nil
+	}), "t")
+	assert.NoError(t, snd.Send(context.Background(), b))
+	assert.Equal(t, "t", msg.Context.Topic)
+	assert.Equal(t, "application/json", msg.Context.ContentType)
+	assert.Equal(t, CompressionGzip, msg.Context.ContentEncoding)
+	assert.Equal(t, "2", msg.Header(HeaderBatchCount))
+	assert.Equal(t, []byte("data"), msg.Content)
+
+	svr := httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		assert.Equal(t, "/v1/telemetry", r.URL.Path)
+		assert.Equal(t, CompressionGzip, r.Header.Get(HeaderContentEncoding))
+		assert.Equal(t, "2", r.Header.Get(HeaderBatchCount))
+		data, _ := ioutil.ReadAll(r.Body)
+		assert.Equal(t, "data", string(data))
+		json.NewEncoder(w).Encode(map[string]string{})
+	}))
+	defer svr.Close()
+	var cc http.ClientConfig
+	assert.NoError(t, utils.SetDefaults(&cc))
+	cc.Address = svr.URL
+	cli, err := http.NewClient(cc)
+	assert.NoError(t, err)
+	assert.NoError(t, NewHTTPSender(cli, "/v1/telemetry").Send(context.Background(), b))
+}
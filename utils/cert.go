@@ -2,28 +2,135 @@ package utils
 
 import (
 	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"time"
 
 	"github.com/docker/go-connections/tlsconfig"
 )
 
+// all modes of client authentication of server
+const (
+	ClientAuthNone             = "none"               // the client certificate is not requested
+	ClientAuthRequest          = "request"            // the client certificate is requested but not verified
+	ClientAuthRequire          = "require"            // the client certificate is required but not verified
+	ClientAuthVerifyIfGiven    = "verify-if-given"    // the client certificate is verified if given, the default mode
+	ClientAuthRequireAndVerify = "require-and-verify" // the client certificate is required and verified
+)
+
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"":                         tls.VerifyClientCertIfGiven,
+	ClientAuthNone:             tls.NoClientCert,
+	ClientAuthRequest:          tls.RequestClientCert,
+	ClientAuthRequire:          tls.RequireAnyClientCert,
+	ClientAuthVerifyIfGiven:    tls.VerifyClientCertIfGiven,
+	ClientAuthRequireAndVerify: tls.RequireAndVerifyClientCert,
+}
+
 // Certificate certificate config for server
 // Name : serverNameOverride, same to CommonName in server.pem
 // if Name == "" , link would not verifies the server's certificate chain and host name
-// AuthType : declares the policy the server will follow for TLS Client Authentication
+// ClientAuth : declares the policy the server will follow for TLS Client Authentication
 type Certificate struct {
 	CA                 string `yaml:"ca" json:"ca"`
 	Key                string `yaml:"key" json:"key"`
 	Cert               string `yaml:"cert" json:"cert"`
 	Name               string `yaml:"name" json:"name"`
 	InsecureSkipVerify bool   `yaml:"insecureSkipVerify" json:"insecureSkipVerify"` // for client, for test purpose
+	// the files are watched in the interval if set, and the clients reconnect by the certificate rotated, see CertWatcher
+	WatchInterval time.Duration `yaml:"watchInterval" json:"watchInterval"`
+	// for server, the mode of client authentication, one of none, request, require, verify-if-given and require-and-verify
+	ClientAuth string `yaml:"clientAuth" json:"clientAuth" validate:"regexp=^(none|request|require|verify-if-given|require-and-verify)?$"`
+	// the common names and the organizational units allowed of the peer certificate verified, all allowed if empty,
+	// the server checks the client certificate if given, and the client checks the server certificate
+	PeerCN []string `yaml:"peerCN" json:"peerCN"`
+	PeerOU []string `yaml:"peerOU" json:"peerOU"`
+}
+
+// PeerIdentity the identity in the peer certificate verified
+type PeerIdentity struct {
+	CN string   `json:"cn"`
+	OU []string `json:"ou"`
 }
 
 // NewTLSConfigServer loads tls config for server
 func NewTLSConfigServer(c Certificate) (*tls.Config, error) {
-	return tlsconfig.Server(tlsconfig.Options{CAFile: c.CA, KeyFile: c.Key, CertFile: c.Cert, ClientAuth: tls.VerifyClientCertIfGiven})
+	clientAuth, err := ClientAuthType(c.ClientAuth)
+	if err != nil {
+		return nil, err
+	}
+	tc, err := tlsconfig.Server(tlsconfig.Options{CAFile: c.CA, KeyFile: c.Key, CertFile: c.Cert, ClientAuth: clientAuth})
+	if err != nil {
+		return nil, err
+	}
+	tc.VerifyPeerCertificate = newPeerVerifier(c)
+	return tc, nil
 }
 
 // NewTLSConfigClient loads tls config for client
 func NewTLSConfigClient(c Certificate) (*tls.Config, error) {
-	return tlsconfig.Client(tlsconfig.Options{CAFile: c.CA, KeyFile: c.Key, CertFile: c.Cert, InsecureSkipVerify: c.InsecureSkipVerify})
+	tc, err := tlsconfig.Client(tlsconfig.Options{CAFile: c.CA, KeyFile: c.Key, CertFile: c.Cert, InsecureSkipVerify: c.InsecureSkipVerify})
+	if err != nil {
+		return nil, err
+	}
+	tc.VerifyPeerCertificate = newPeerVerifier(c)
+	return tc, nil
+}
+
+// ClientAuthType returns the tls client auth type of the mode, verify-if-given if empty
+func ClientAuthType(mode string) (tls.ClientAuthType, error) {
+	t, ok := clientAuthTypes[mode]
+	if !ok {
+		return 0, fmt.Errorf("mode of client auth (%s) not supported", mode)
+	}
+	return t, nil
+}
+
+// GetPeerIdentity returns the identity of the leaf certificate of peer verified in the tls handshake,
+// false if the peer certificate is not given or not verified
+func GetPeerIdentity(cs tls.ConnectionState) (*PeerIdentity, bool) {
+	if len(cs.VerifiedChains) == 0 || len(cs.VerifiedChains[0]) == 0 {
+		return nil, false
+	}
+	subject := cs.VerifiedChains[0][0].Subject
+	return &PeerIdentity{CN: subject.CommonName, OU: subject.OrganizationalUnit}, true
+}
+
+// newPeerVerifier returns the callback to check the common name and the organizational units of the peer certificate
+// verified, nil if not configured. The peer certificate not given is left to the client auth mode, and the one given
+// but not verified is rejected, such as the one of the client auth mode request
+func newPeerVerifier(c Certificate) func([][]byte, [][]*x509.Certificate) error {
+	if len(c.PeerCN) == 0 && len(c.PeerOU) == 0 {
+		return nil
+	}
+	return func(raw [][]byte, chains [][]*x509.Certificate) error {
+		if len(raw) == 0 {
+			return nil
+		}
+		if len(chains) == 0 || len(chains[0]) == 0 {
+			return fmt.Errorf("peer certificate is not verified")
+		}
+		subject := chains[0][0].Subject
+		if len(c.PeerCN) > 0 && !containsString(c.PeerCN, subject.CommonName) {
+			return fmt.Errorf("common name of peer certificate (%s) is not allowed", subject.CommonName)
+		}
+		if len(c.PeerOU) == 0 {
+			return nil
+		}
+		for _, ou := range subject.OrganizationalUnit {
+			if containsString(c.PeerOU, ou) {
+				return nil
+			}
+		}
+		return fmt.Errorf("organizational units of peer certificate (%v) are not allowed", subject.OrganizationalUnit)
+	}
+}
+
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
 }
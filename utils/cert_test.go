@@ -1,8 +1,13 @@
 package utils
 
 import (
-	"github.com/stretchr/testify/assert"
+	"crypto/tls"
+	"net"
+	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestNewTLSConfigServer(t *testing.T) {
@@ -38,3 +43,107 @@ func TestNewTLSConfigClient(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotEmpty(t, tls)
 }
+
+func TestClientAuthType(t *testing.T) {
+	ct, err := ClientAuthType("")
+	assert.NoError(t, err)
+	assert.Equal(t, tls.VerifyClientCertIfGiven, ct)
+	ct, err = ClientAuthType(ClientAuthRequireAndVerify)
+	assert.NoError(t, err)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, ct)
+	_, err = ClientAuthType("xxx")
+	assert.EqualError(t, err, "mode of client auth (xxx) not supported")
+	_, err = NewTLSConfigServer(Certificate{ClientAuth: "xxx"})
+	assert.EqualError(t, err, "mode of client auth (xxx) not supported")
+}
+
+func TestPeerVerification(t *testing.T) {
+	// the certificates are signed by the ca with organizational units
+	dir := "../example/var/lib/baetyl/testcert/peer"
+	ca := Certificate{Key: filepath.Join(dir, "ca.key"), Cert: filepath.Join(dir, "ca.pem")}
+	server := Certificate{CA: ca.Cert, Key: filepath.Join(dir, "server.key"), Cert: filepath.Join(dir, "server.pem")}
+	client := Certificate{CA: ca.Cert, Key: filepath.Join(dir, "client.key"), Cert: filepath.Join(dir, "client.pem")}
+
+	handshake := func(s, c Certificate) (*PeerIdentity, error, error) {
+		sc, err := NewTLSConfigServer(s)
+		assert.NoError(t, err)
+		cc, err := NewTLSConfigClient(c)
+		assert.NoError(t, err)
+		cc.ServerName = "localhost"
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		defer lis.Close()
+		type result struct {
+			id  *PeerIdentity
+			err error
+		}
+		res := make(chan result, 1)
+		go func() {
+			nc, err := lis.Accept()
+			if err != nil {
+				res <- result{nil, err}
+				return
+			}
+			defer nc.Close()
+			nc.SetDeadline(time.Now().Add(5 * time.Second))
+			conn := tls.Server(nc, sc)
+			err = conn.Handshake()
+			id, _ := GetPeerIdentity(conn.ConnectionState())
+			res <- result{id, err}
+		}()
+		nc, err := net.Dial("tcp", lis.Addr().String())
+		assert.NoError(t, err)
+		nc.SetDeadline(time.Now().Add(5 * time.Second))
+		conn := tls.Client(nc, cc)
+		cerr := conn.Handshake()
+		if cerr == nil {
+			// the client handshake completes before the server verifies the client certificate in tls 1.3
+			_, cerr = conn.Write([]byte("x"))
+		}
+		conn.Close()
+		r := <-res
+		return r.id, r.err, cerr
+	}
+
+	// the identity of client certificate verified
+	id, serr, cerr := handshake(server, client)
+	assert.NoError(t, serr)
+	assert.NoError(t, cerr)
+	assert.Equal(t, &PeerIdentity{CN: "node1", OU: []string{"device"}}, id)
+
+	// the client certificate is required
+	s := server
+	s.ClientAuth = ClientAuthRequireAndVerify
+	id, serr, _ = handshake(s, Certificate{CA: ca.Cert})
+	assert.Error(t, serr)
+	assert.Nil(t, id)
+
+	// the client certificate is not required, no identity
+	id, serr, cerr = handshake(server, Certificate{CA: ca.Cert})
+	assert.NoError(t, serr)
+	assert.NoError(t, cerr)
+	assert.Nil(t, id)
+
+	// the common names and organizational units allowed of client
+	s = server
+	s.PeerCN = []string{"node1", "node2"}
+	s.PeerOU = []string{"device"}
+	_, serr, _ = handshake(s, client)
+	assert.NoError(t, serr)
+	s.PeerCN = []string{"node2"}
+	_, serr, _ = handshake(s, client)
+	assert.EqualError(t, serr, "common name of peer certificate (node1) is not allowed")
+	s.PeerCN = nil
+	s.PeerOU = []string{"gateway"}
+	_, serr, _ = handshake(s, client)
+	assert.EqualError(t, serr, "organizational units of peer certificate ([device]) are not allowed")
+
+	// the common names allowed of server
+	c := client
+	c.PeerCN = []string{"server"}
+	_, _, cerr = handshake(server, c)
+	assert.NoError(t, cerr)
+	c.PeerCN = []string{"other"}
+	_, _, cerr = handshake(server, c)
+	assert.EqualError(t, cerr, "common name of peer certificate (server) is not allowed")
+}
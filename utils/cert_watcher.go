@@ -0,0 +1,186 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/baetyl/baetyl-go/log"
+	"github.com/docker/go-connections/tlsconfig"
+)
+
+// CertWatcher watches the files of the certificate, the key pair and the CA are reloaded once the files are changed,
+// the tls configs created by the watcher get the key pair reloaded by the callbacks in new handshakes, and the clients
+// are notified by the channel of Changed to reconnect, so that the CA reloaded is used as well
+type CertWatcher struct {
+	cfg     Certificate
+	auth    tls.ClientAuthType
+	verify  func([][]byte, [][]*x509.Certificate) error
+	data    []byte
+	pair    *tls.Certificate
+	pool    *x509.CertPool
+	changed chan struct{}
+	mu      sync.RWMutex
+	tomb    Tomb
+	log     *log.Logger
+}
+
+// NewCertWatcher loads the certificate and watches the files in the watch interval, one minute if not set
+func NewCertWatcher(c Certificate) (*CertWatcher, error) {
+	auth, err := ClientAuthType(c.ClientAuth)
+	if err != nil {
+		return nil, err
+	}
+	w := &CertWatcher{
+		cfg:     c,
+		auth:    auth,
+		verify:  newPeerVerifier(c),
+		changed: make(chan struct{}),
+		log:     log.With(log.Any("utils", "cert"), log.Any("cert", c.Cert)),
+	}
+	data, err := w.read()
+	if err != nil {
+		return nil, err
+	}
+	if err = w.load(data); err != nil {
+		return nil, err
+	}
+	w.tomb.Go(w.watching)
+	return w, nil
+}
+
+// GetCertificate returns the key pair loaded, used by the tls config of server
+func (w *CertWatcher) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.pair, nil
+}
+
+// GetClientCertificate returns the key pair loaded, used by the tls config of client
+func (w *CertWatcher) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.pair, nil
+}
+
+// Changed returns the channel closed once the certificate is reloaded next time,
+// the channel should be got again after it is closed
+func (w *CertWatcher) Changed() <-chan struct{} {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.changed
+}
+
+// TLSConfigClient creates the tls config of client by the CA loaded, the key pair is got by the callback
+func (w *CertWatcher) TLSConfigClient() *tls.Config {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	tc := tlsconfig.ClientDefault()
+	tc.RootCAs = w.pool
+	tc.InsecureSkipVerify = w.cfg.InsecureSkipVerify
+	tc.GetClientCertificate = w.GetClientCertificate
+	tc.VerifyPeerCertificate = w.verify
+	return tc
+}
+
+// TLSConfigServer creates the tls config of server, the key pair and the CA loaded are got in every handshake
+func (w *CertWatcher) TLSConfigServer() *tls.Config {
+	tc := tlsconfig.ServerDefault()
+	tc.ClientAuth = w.auth
+	tc.VerifyPeerCertificate = w.verify
+	tc.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		w.mu.RLock()
+		defer w.mu.RUnlock()
+		c := tlsconfig.ServerDefault()
+		c.ClientAuth = w.auth
+		c.VerifyPeerCertificate = w.verify
+		c.ClientCAs = w.pool
+		c.Certificates = []tls.Certificate{*w.pair}
+		return c, nil
+	}
+	return tc
+}
+
+// Close stops watching
+func (w *CertWatcher) Close() error {
+	w.tomb.Kill(nil)
+	return w.tomb.Wait()
+}
+
+func (w *CertWatcher) watching() error {
+	interval := w.cfg.WatchInterval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			data, err := w.read()
+			if err != nil {
+				w.log.Warn("failed to read certificate", log.Error(err))
+				continue
+			}
+			if bytes.Equal(data, w.data) {
+				continue
+			}
+			// the files may be written partially, such as the cert is renewed but the key is not, retried next time
+			if err = w.load(data); err != nil {
+				w.log.Warn("failed to reload certificate, retry later", log.Error(err))
+				continue
+			}
+			w.log.Info("certificate is reloaded")
+		case <-w.tomb.Dying():
+			return nil
+		}
+	}
+}
+
+// read reads the contents of the files of CA, cert and key
+func (w *CertWatcher) read() ([]byte, error) {
+	var data []byte
+	for _, f := range []string{w.cfg.CA, w.cfg.Cert, w.cfg.Key} {
+		if f == "" {
+			continue
+		}
+		d, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, d...)
+	}
+	return data, nil
+}
+
+func (w *CertWatcher) load(data []byte) error {
+	pair, err := tls.LoadX509KeyPair(w.cfg.Cert, w.cfg.Key)
+	if err != nil {
+		return err
+	}
+	var pool *x509.CertPool
+	if w.cfg.CA != "" {
+		ca, err := ioutil.ReadFile(w.cfg.CA)
+		if err != nil {
+			return err
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return fmt.Errorf("failed to append certificates of CA (%s)", w.cfg.CA)
+		}
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pair = &pair
+	w.pool = pool
+	if w.data != nil {
+		close(w.changed)
+		w.changed = make(chan struct{})
+	}
+	w.data = data
+	return nil
+}
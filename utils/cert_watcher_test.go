@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func copyTestCert(t *testing.T, dir, src, dst string) {
+	data, err := ioutil.ReadFile(filepath.Join("../example/var/lib/baetyl/testcert", src))
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, dst), data, 0644))
+}
+
+func TestCertWatcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", t.Name())
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c := Certificate{
+		CA:            filepath.Join(dir, "ca.pem"),
+		Cert:          filepath.Join(dir, "cert.pem"),
+		Key:           filepath.Join(dir, "cert.key"),
+		WatchInterval: 10 * time.Millisecond,
+	}
+	_, err = NewCertWatcher(c)
+	assert.Error(t, err)
+
+	copyTestCert(t, dir, "ca.pem", "ca.pem")
+	copyTestCert(t, dir, "client.pem", "cert.pem")
+	copyTestCert(t, dir, "client.key", "cert.key")
+	w, err := NewCertWatcher(c)
+	assert.NoError(t, err)
+	defer w.Close()
+
+	pair, err := w.GetClientCertificate(nil)
+	assert.NoError(t, err)
+	tc := w.TLSConfigClient()
+	assert.NotNil(t, tc.RootCAs)
+	assert.NotNil(t, tc.GetClientCertificate)
+	ts := w.TLSConfigServer()
+	sc, err := ts.GetConfigForClient(nil)
+	assert.NoError(t, err)
+	assert.Len(t, sc.Certificates, 1)
+	assert.Equal(t, pair.Certificate, sc.Certificates[0].Certificate)
+
+	// the key pair mismatched is not loaded
+	changed := w.Changed()
+	copyTestCert(t, dir, "server.pem", "cert.pem")
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case <-changed:
+		t.Fatal("certificate should not be reloaded")
+	default:
+	}
+	p, err := w.GetCertificate(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, pair.Certificate, p.Certificate)
+
+	copyTestCert(t, dir, "server.key", "cert.key")
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatal("timed out to wait certificate reloaded")
+	}
+	p, err = w.GetClientCertificate(nil)
+	assert.NoError(t, err)
+	assert.NotEqual(t, pair.Certificate, p.Certificate)
+	assert.True(t, changed != w.Changed())
+}
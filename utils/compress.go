@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+// ErrDecompressedTooLarge the decompressed data exceeds the limit
+var ErrDecompressedTooLarge = errors.New("decompressed data is too large")
+
+// Gzip compresses the data by gzip
+func Gzip(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Gunzip decompresses the data by gzip, returns ErrDecompressedTooLarge if the result exceeds the limit (if positive),
+// which protects against the decompression bomb
+func Gunzip(data []byte, limit int64) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	var lr io.Reader = r
+	if limit > 0 {
+		lr = io.LimitReader(r, limit+1)
+	}
+	res, err := ioutil.ReadAll(lr)
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && int64(len(res)) > limit {
+		return nil, ErrDecompressedTooLarge
+	}
+	return res, nil
+}
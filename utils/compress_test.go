@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGzip(t *testing.T) {
+	data := bytes.Repeat([]byte("baetyl"), 1000)
+	c, err := Gzip(data)
+	assert.NoError(t, err)
+	assert.True(t, len(c) < len(data))
+
+	res, err := Gunzip(c, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, data, res)
+	res, err = Gunzip(c, int64(len(data)))
+	assert.NoError(t, err)
+	assert.Equal(t, data, res)
+	_, err = Gunzip(c, int64(len(data)-1))
+	assert.Equal(t, ErrDecompressedTooLarge, err)
+	_, err = Gunzip(data, 0)
+	assert.Error(t, err)
+}
@@ -11,7 +11,6 @@ import (
 	"text/template"
 
 	"github.com/docker/go-units"
-	"gopkg.in/validator.v2"
 	"gopkg.in/yaml.v2"
 )
 
@@ -60,11 +59,7 @@ func UnmarshalYAML(in []byte, out interface{}) error {
 	if err != nil {
 		return err
 	}
-	err = validator.Validate(out)
-	if err != nil {
-		return err
-	}
-	return nil
+	return Validate(out)
 }
 
 // UnmarshalJSON unmarshals, defaults and validates
@@ -77,11 +72,7 @@ func UnmarshalJSON(in []byte, out interface{}) error {
 	if err != nil {
 		return err
 	}
-	err = validator.Validate(out)
-	if err != nil {
-		return err
-	}
-	return nil
+	return Validate(out)
 }
 
 // Size size
@@ -7,7 +7,8 @@ import (
 	"github.com/creasty/defaults"
 )
 
-// SetDefaults set default values
+// SetDefaults set default values by the default tags, the nested structs, including the ones
+// pointed and the ones in slices and maps, are set as well
 func SetDefaults(ptr interface{}) error {
 	err := defaults.Set(ptr)
 	if err != nil {
@@ -20,6 +21,19 @@ func SetDefaults(ptr interface{}) error {
 	for i := 0; i < t.NumField(); i++ {
 		tf := t.Field(i)
 		vf := v.Field(i)
+		if !vf.CanSet() {
+			continue
+		}
+		if tf.Type.Kind() == reflect.Struct {
+			if err := setDefaults(vf); err != nil {
+				return err
+			}
+		}
+		if tf.Type.Kind() == reflect.Ptr && tf.Type.Elem().Kind() == reflect.Struct && !vf.IsNil() {
+			if err := setDefaults(vf.Elem()); err != nil {
+				return err
+			}
+		}
 		if tf.Type.Kind() == reflect.Slice {
 			for j := 0; j < vf.Len(); j++ {
 				item := vf.Index(j)
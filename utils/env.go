@@ -0,0 +1,195 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v2"
+)
+
+// the placeholders of envs, such as ${BROKER_ADDRESS} and ${BROKER_ADDRESS:-tcp://127.0.0.1:1883}
+var envPlaceholder = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// ExpandEnv expands the placeholders ${NAME} by the envs, the default after :- is used if the env is unset or empty,
+// such as ${NAME:-value}, returns the error if the env is unset and no default is given. The comments of yaml are
+// kept as is, such as # address: ${UNSET_HOST}
+func ExpandEnv(data []byte) ([]byte, error) {
+	var missing []string
+	expand := func(m []byte) []byte {
+		sub := envPlaceholder.FindSubmatch(m)
+		name := string(sub[1])
+		if v, ok := os.LookupEnv(name); ok && (v != "" || sub[2] == nil) {
+			return []byte(v)
+		}
+		if sub[2] != nil {
+			return sub[3]
+		}
+		missing = append(missing, name)
+		return m
+	}
+	res := make([]byte, 0, len(data))
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		i := commentIndex(line)
+		res = append(res, envPlaceholder.ReplaceAllFunc(line[:i], expand)...)
+		res = append(res, line[i:]...)
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("envs (%s) not set", strings.Join(missing, ","))
+	}
+	return res, nil
+}
+
+// commentIndex returns the index where the comment of the yaml line starts, or the length of line if no comment,
+// the comment starts with # at the beginning or after a space, and not in the quoted scalar
+func commentIndex(line []byte) int {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == '"' && c == '\\', quote == '\'' && c == '\'' && i+1 < len(line) && line[i+1] == '\'':
+			// the escaped character in double quotes or the escaped single quote in single quotes
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return i
+		case (c == '"' || c == '\'') && quotable(line[:i]):
+			quote = c
+		}
+	}
+	return len(line)
+}
+
+// quotable checks whether a quoted scalar can start after the text, such as after the indicators or the spaces
+func quotable(text []byte) bool {
+	text = bytes.TrimRight(text, " \t")
+	return len(text) == 0 || bytes.IndexByte([]byte(":-[{,?"), text[len(text)-1]) >= 0
+}
+
+// LoadYAMLWithEnv loads config from the yaml file into out interface like LoadYAML, and then
+// expands the placeholders and overrides the fields by the envs of the prefix, see UnmarshalYAMLWithEnv
+func LoadYAMLWithEnv(path, prefix string, out interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	res, err := ParseEnv(data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config parse error: %s", err.Error())
+		res = data
+	}
+	return UnmarshalYAMLWithEnv(res, prefix, out)
+}
+
+// UnmarshalYAMLWithEnv expands the placeholders ${NAME} by the envs, unmarshals, overrides the fields
+// by the envs of the prefix, defaults and validates. The env overriding takes precedence over the value
+// in yaml (the placeholders expanded), which takes precedence over the default
+func UnmarshalYAMLWithEnv(in []byte, prefix string, out interface{}) error {
+	res, err := ExpandEnv(in)
+	if err != nil {
+		return err
+	}
+	err = yaml.Unmarshal(res, out)
+	if err != nil {
+		return err
+	}
+	err = OverrideEnv(prefix, out)
+	if err != nil {
+		return err
+	}
+	err = SetDefaults(out)
+	if err != nil {
+		return err
+	}
+	return Validate(out)
+}
+
+// OverrideEnv overrides the fields of the struct by the envs, the name of env is the prefix and the keys of yaml tags
+// in the path of field, which are joined by underscores in upper case, and the key in camel case is split by underscores
+// as well, such as BAETYL_MQTT_ADDRESS of mqtt.address and BAETYL_LOGGER_MAX_AGE of logger.maxAge of the prefix BAETYL.
+// The value of env is unmarshaled in yaml except the string, such as 30s of duration, true of bool and [a, b] of slice.
+func OverrideEnv(prefix string, out interface{}) error {
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%T is not a pointer of struct", out)
+	}
+	return overrideEnv(strings.ToUpper(prefix), v.Elem())
+}
+
+var yamlUnmarshaler = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+
+func overrideEnv(prefix string, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tf := t.Field(i)
+		vf := v.Field(i)
+		if tf.PkgPath != "" && !tf.Anonymous {
+			continue
+		}
+		tag := strings.Split(tf.Tag.Get("yaml"), ",")
+		if tag[0] == "-" {
+			continue
+		}
+		name := prefix
+		switch {
+		case len(tag) > 1 && tag[1] == "inline":
+			// the fields inlined are of the same prefix
+		case tag[0] != "":
+			name = joinEnv(prefix, envName(tag[0]))
+		default:
+			name = joinEnv(prefix, strings.ToUpper(tf.Name))
+		}
+		if vf.Kind() == reflect.Ptr && vf.Type().Elem().Kind() == reflect.Struct && !vf.IsNil() {
+			vf = vf.Elem()
+		}
+		if vf.Kind() == reflect.Struct && !vf.Addr().Type().Implements(yamlUnmarshaler) {
+			if err := overrideEnv(name, vf); err != nil {
+				return err
+			}
+			continue
+		}
+		val, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if vf.Kind() == reflect.String {
+			vf.SetString(val)
+			continue
+		}
+		if err := yaml.Unmarshal([]byte(val), vf.Addr().Interface()); err != nil {
+			return fmt.Errorf("env (%s) is invalid: %s", name, err.Error())
+		}
+	}
+	return nil
+}
+
+func joinEnv(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "_" + name
+}
+
+// envName converts the key in camel case to the name of env, such as MAX_AGE of maxAge
+func envName(key string) string {
+	var b strings.Builder
+	rs := []rune(key)
+	for i, r := range rs {
+		if unicode.IsUpper(r) && i > 0 && (unicode.IsLower(rs[i-1]) || unicode.IsDigit(rs[i-1])) {
+			b.WriteByte('_')
+		}
+		if r == '-' || r == '.' {
+			r = '_'
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
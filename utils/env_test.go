@@ -0,0 +1,150 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testEnvInline struct {
+	Region string `yaml:"region" json:"region"`
+}
+
+type testEnvServer struct {
+	Address string        `yaml:"address" json:"address" default:"tcp://127.0.0.1:1883"`
+	Timeout time.Duration `yaml:"timeout" json:"timeout" default:"30s"`
+	Ignored string        `yaml:"-" json:"-"`
+}
+
+type testEnvConfig struct {
+	testEnvInline `yaml:",inline" json:",inline"`
+	Server        testEnvServer     `yaml:"server" json:"server"`
+	Backup        *testEnvServer    `yaml:"backup" json:"backup"`
+	MaxAge        int               `yaml:"maxAge" json:"maxAge" default:"15" validate:"min=1"`
+	Debug         bool              `yaml:"debug" json:"debug"`
+	Topics        []string          `yaml:"topics" json:"topics"`
+	Levels        map[string]string `yaml:"levels" json:"levels"`
+	Size          Size              `yaml:"size" json:"size"`
+}
+
+func TestExpandEnv(t *testing.T) {
+	os.Setenv("TEST_EXPAND_A", "a")
+	os.Setenv("TEST_EXPAND_EMPTY", "")
+	defer os.Unsetenv("TEST_EXPAND_A")
+	defer os.Unsetenv("TEST_EXPAND_EMPTY")
+	os.Unsetenv("TEST_EXPAND_NONE")
+
+	res, err := ExpandEnv([]byte("${TEST_EXPAND_A} ${TEST_EXPAND_NONE:-b} ${TEST_EXPAND_EMPTY:-c} [${TEST_EXPAND_EMPTY}] ${TEST_EXPAND_A:-d} $TEST_EXPAND_A"))
+	assert.NoError(t, err)
+	assert.Equal(t, "a b c [] a $TEST_EXPAND_A", string(res))
+
+	_, err = ExpandEnv([]byte("${TEST_EXPAND_NONE} ${TEST_EXPAND_A} ${TEST_EXPAND_NONE2}"))
+	assert.EqualError(t, err, "envs (TEST_EXPAND_NONE,TEST_EXPAND_NONE2) not set")
+
+	// the comments are kept as is, except the # in quotes or not after a space
+	res, err = ExpandEnv([]byte(`# address: ${TEST_EXPAND_NONE}
+a: ${TEST_EXPAND_A} # ${TEST_EXPAND_NONE}
+  # b: ${TEST_EXPAND_NONE}
+c: "# ${TEST_EXPAND_A} \" #" # ${TEST_EXPAND_NONE}
+d: 'it''s # ${TEST_EXPAND_A}' # ${TEST_EXPAND_NONE}
+e: x#${TEST_EXPAND_A}
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, `# address: ${TEST_EXPAND_NONE}
+a: a # ${TEST_EXPAND_NONE}
+  # b: ${TEST_EXPAND_NONE}
+c: "# a \" #" # ${TEST_EXPAND_NONE}
+d: 'it''s # a' # ${TEST_EXPAND_NONE}
+e: x#a
+`, string(res))
+}
+
+func TestOverrideEnv(t *testing.T) {
+	assert.Equal(t, "MAX_AGE", envName("maxAge"))
+	assert.Equal(t, "CLIENTID", envName("clientid"))
+	assert.Equal(t, "TLS_CA", envName("tls-ca"))
+	assert.Equal(t, "HTTP2_PORT", envName("http2Port"))
+
+	dir, err := ioutil.TempDir("", t.Name())
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "service.yml")
+	err = ioutil.WriteFile(path, []byte(`
+region: ${TEST_ENV_REGION:-bj}
+server:
+  address: tcp://${TEST_ENV_HOST}:1883
+  timeout: 10s
+maxAge: 3
+topics: [a]
+`), 0644)
+	assert.NoError(t, err)
+
+	var cfg testEnvConfig
+	err = LoadYAMLWithEnv(path, "test", &cfg)
+	assert.EqualError(t, err, "envs (TEST_ENV_HOST) not set")
+
+	os.Setenv("TEST_ENV_HOST", "broker")
+	defer os.Unsetenv("TEST_ENV_HOST")
+	cfg = testEnvConfig{}
+	err = LoadYAMLWithEnv(path, "test", &cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, "bj", cfg.Region)
+	assert.Equal(t, "tcp://broker:1883", cfg.Server.Address)
+	assert.Equal(t, 10*time.Second, cfg.Server.Timeout)
+	assert.Equal(t, 3, cfg.MaxAge)
+	assert.Nil(t, cfg.Backup)
+
+	// the envs override the yaml, which overrides the defaults
+	envs := map[string]string{
+		"TEST_REGION":         "sh",
+		"TEST_SERVER_ADDRESS": "ssl://broker:8883",
+		"TEST_SERVER_TIMEOUT": "1m",
+		"TEST_SERVER_IGNORED": "x",
+		"TEST_BACKUP":         "{address: tcp://backup:1883}",
+		"TEST_DEBUG":          "true",
+		"TEST_TOPICS":         "[b, c]",
+		"TEST_LEVELS":         "{mqtt: debug}",
+		"TEST_SIZE":           "10k",
+	}
+	for k, v := range envs {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+	cfg = testEnvConfig{}
+	err = LoadYAMLWithEnv(path, "test", &cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, "sh", cfg.Region)
+	assert.Equal(t, "ssl://broker:8883", cfg.Server.Address)
+	assert.Equal(t, time.Minute, cfg.Server.Timeout)
+	assert.Empty(t, cfg.Server.Ignored)
+	assert.Equal(t, "tcp://backup:1883", cfg.Backup.Address)
+	assert.Equal(t, 30*time.Second, cfg.Backup.Timeout)
+	assert.Equal(t, 3, cfg.MaxAge)
+	assert.True(t, cfg.Debug)
+	assert.Equal(t, []string{"b", "c"}, cfg.Topics)
+	assert.Equal(t, map[string]string{"mqtt": "debug"}, cfg.Levels)
+	assert.Equal(t, Size(10240), cfg.Size)
+
+	// the env of the pointer set is applied to the field
+	os.Setenv("TEST_BACKUP_ADDRESS", "tcp://backup2:1883")
+	defer os.Unsetenv("TEST_BACKUP_ADDRESS")
+	cfg = testEnvConfig{Backup: &testEnvServer{}}
+	assert.NoError(t, OverrideEnv("test", &cfg))
+	assert.Equal(t, "tcp://backup2:1883", cfg.Backup.Address)
+
+	os.Setenv("TEST_MAX_AGE", "x")
+	cfg = testEnvConfig{}
+	err = UnmarshalYAMLWithEnv(nil, "test", &cfg)
+	assert.Contains(t, err.Error(), "env (TEST_MAX_AGE) is invalid")
+	os.Setenv("TEST_MAX_AGE", "-1")
+	defer os.Unsetenv("TEST_MAX_AGE")
+	cfg = testEnvConfig{}
+	err = UnmarshalYAMLWithEnv(nil, "test", &cfg)
+	assert.EqualError(t, err, "maxAge: less than min")
+
+	assert.Error(t, OverrideEnv("test", cfg))
+}
@@ -0,0 +1,191 @@
+package utils
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/baetyl/baetyl-go/log"
+	"github.com/jpillora/backoff"
+)
+
+// all restart policies of tasks
+const (
+	RestartAlways    = "always"
+	RestartOnFailure = "on-failure"
+	RestartNever     = "never"
+)
+
+// RestartPolicy the policy to restart the task exited, the task is restarted with exponential backoff,
+// the delay of restart is counted from the start of the previous run
+type RestartPolicy struct {
+	Policy     string        `yaml:"policy" json:"policy" default:"always" validate:"regexp=^(always|on-failure|never)?$"`
+	MaxRetries int           `yaml:"maxRetries" json:"maxRetries"` // the max restarts in a row, unlimited if zero
+	MinBackoff time.Duration `yaml:"minBackoff" json:"minBackoff" default:"1s"`
+	MaxBackoff time.Duration `yaml:"maxBackoff" json:"maxBackoff" default:"2m"`
+	Factor     float64       `yaml:"factor" json:"factor" default:"1.6"`
+}
+
+// Task the function of task supervised, which should return once the dying channel of the run is closed
+type Task func(r *TaskRun) error
+
+// TaskRun the run of task
+type TaskRun struct {
+	dying   <-chan struct{}
+	attempt int
+	reset   bool
+}
+
+// Dying returns the channel closed once the task is stopped
+func (r *TaskRun) Dying() <-chan struct{} {
+	return r.dying
+}
+
+// Attempt returns the number of restarts in a row before the run, zero for the first run
+func (r *TaskRun) Attempt() int {
+	return r.attempt
+}
+
+// Reset resets the backoff and the retries of restarts, such as the client has connected,
+// so that the task is restarted soon if it fails later
+func (r *TaskRun) Reset() {
+	r.reset = true
+}
+
+// Supervisor runs the named tasks and restarts them by their restart policies, the panics of tasks are recovered
+// and logged with the stacktrace, then handled as the failures. The tasks are stopped in the reverse order of
+// started when the supervisor is closed, so the task started later (which may depend on the earlier) stops first.
+type Supervisor struct {
+	tasks []*task
+	tomb  Tomb
+	mu    sync.Mutex
+	log   *log.Logger
+}
+
+type task struct {
+	name   string
+	policy RestartPolicy
+	fn     Task
+	tomb   Tomb
+}
+
+// NewSupervisor creates a new supervisor, the events of tasks are logged by the logger with the names of tasks
+func NewSupervisor(l *log.Logger) *Supervisor {
+	if l == nil {
+		l = log.With(log.Any("utils", "supervisor"))
+	}
+	return &Supervisor{log: l}
+}
+
+// Go starts the task with the name and the restart policy, returns ErrDying if the supervisor is closed
+func (s *Supervisor) Go(name string, policy RestartPolicy, fn Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.tomb.Alive() {
+		return ErrDying
+	}
+	t := &task{name: name, policy: policy, fn: fn}
+	s.tasks = append(s.tasks, t)
+	return t.tomb.Go(func() error {
+		return s.supervising(t)
+	})
+}
+
+// Dying returns the channel closed once the supervisor starts to close
+func (s *Supervisor) Dying() <-chan struct{} {
+	return s.tomb.Dying()
+}
+
+// Alive returns true if the supervisor is not closed
+func (s *Supervisor) Alive() bool {
+	return s.tomb.Alive()
+}
+
+// Close stops the tasks in the reverse order of started and waits for each of them,
+// returns the first error of the tasks which are exited and not restarted by their policies
+func (s *Supervisor) Close() error {
+	s.mu.Lock()
+	s.tomb.Kill(nil)
+	tasks := s.tasks
+	s.mu.Unlock()
+
+	var err error
+	for i := len(tasks) - 1; i >= 0; i-- {
+		t := tasks[i]
+		t.tomb.Kill(nil)
+		if e := t.tomb.Wait(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (s *Supervisor) supervising(t *task) error {
+	bf := backoff.Backoff{
+		Min:    t.policy.MinBackoff,
+		Max:    t.policy.MaxBackoff,
+		Factor: t.policy.Factor,
+	}
+	if bf.Min <= 0 {
+		bf.Min = time.Second
+	}
+	if bf.Max <= 0 {
+		bf.Max = 2 * time.Minute
+	}
+	if bf.Factor <= 0 {
+		bf.Factor = 1.6
+	}
+
+	retries := 0
+	for {
+		start := time.Now()
+		r := &TaskRun{dying: t.tomb.Dying(), attempt: retries}
+		err := s.run(t, r)
+		// the task is not restarted once the supervisor starts to close
+		if !t.tomb.Alive() || !s.tomb.Alive() {
+			return nil
+		}
+		if r.reset {
+			bf.Reset()
+			retries = 0
+		}
+		if t.policy.Policy == RestartNever || (t.policy.Policy == RestartOnFailure && err == nil) ||
+			(t.policy.MaxRetries > 0 && retries >= t.policy.MaxRetries) {
+			if err != nil {
+				s.log.Error("task is exited and not restarted", log.Any("task", t.name), log.Any("retries", retries), log.Error(err))
+			} else {
+				s.log.Info("task is exited", log.Any("task", t.name))
+			}
+			return err
+		}
+		retries++
+		d := time.Until(start.Add(bf.Duration()))
+		if err != nil {
+			s.log.Warn("task is exited, restarts later", log.Any("task", t.name), log.Any("attempt", retries), log.Any("backoff", d), log.Error(err))
+		} else {
+			s.log.Info("task is exited, restarts later", log.Any("task", t.name), log.Any("attempt", retries), log.Any("backoff", d))
+		}
+		if d <= 0 {
+			continue
+		}
+		select {
+		case <-time.After(d):
+		case <-t.tomb.Dying():
+			return nil
+		case <-s.tomb.Dying():
+			return nil
+		}
+	}
+}
+
+// run runs the task once, the panic is recovered and returned as the error
+func (s *Supervisor) run(t *task, r *TaskRun) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			s.log.Error("task panics", log.Any("task", t.name), log.Any("panic", p), log.Any("stack", string(debug.Stack())))
+			err = fmt.Errorf("task (%s) panics: %v", t.name, p)
+		}
+	}()
+	return t.fn(r)
+}
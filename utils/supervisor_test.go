@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSupervisorRestart(t *testing.T) {
+	s := NewSupervisor(nil)
+	policy := RestartPolicy{MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+
+	// always
+	always := make(chan int, 10)
+	assert.NoError(t, s.Go("always", policy, func(r *TaskRun) error {
+		always <- r.Attempt()
+		if r.Attempt() == 2 {
+			<-r.Dying()
+		}
+		return nil
+	}))
+	assert.Equal(t, 0, <-always)
+	assert.Equal(t, 1, <-always)
+	assert.Equal(t, 2, <-always)
+
+	// on-failure
+	policy.Policy = RestartOnFailure
+	failure := make(chan int, 10)
+	assert.NoError(t, s.Go("on-failure", policy, func(r *TaskRun) error {
+		failure <- r.Attempt()
+		if r.Attempt() < 2 {
+			return errors.New("failed")
+		}
+		return nil
+	}))
+	assert.Equal(t, 0, <-failure)
+	assert.Equal(t, 1, <-failure)
+	assert.Equal(t, 2, <-failure)
+
+	// never
+	policy.Policy = RestartNever
+	never := make(chan int, 10)
+	assert.NoError(t, s.Go("never", policy, func(r *TaskRun) error {
+		never <- r.Attempt()
+		return errors.New("failed")
+	}))
+	assert.Equal(t, 0, <-never)
+
+	// max retries, the panic is handled as the failure, and the retries are reset
+	policy.Policy = RestartAlways
+	policy.MaxRetries = 2
+	panics := make(chan int, 10)
+	runs := 0
+	assert.NoError(t, s.Go("panics", policy, func(r *TaskRun) error {
+		panics <- r.Attempt()
+		if runs++; runs == 2 {
+			r.Reset()
+		}
+		panic("oops")
+	}))
+	assert.Equal(t, 0, <-panics)
+	assert.Equal(t, 1, <-panics)
+	assert.Equal(t, 1, <-panics)
+	assert.Equal(t, 2, <-panics)
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, never, 0)
+	assert.Len(t, failure, 0)
+	assert.Len(t, panics, 0)
+
+	assert.EqualError(t, s.Close(), "task (panics) panics: oops")
+	assert.Len(t, always, 0)
+	assert.Equal(t, ErrDying, s.Go("closed", policy, func(r *TaskRun) error { return nil }))
+}
+
+func TestSupervisorClose(t *testing.T) {
+	s := NewSupervisor(nil)
+	var mu sync.Mutex
+	var stopped []string
+	for _, name := range []string{"a", "b", "c"} {
+		name := name
+		assert.NoError(t, s.Go(name, RestartPolicy{}, func(r *TaskRun) error {
+			<-r.Dying()
+			mu.Lock()
+			stopped = append(stopped, name)
+			mu.Unlock()
+			return nil
+		}))
+	}
+	// the task watching the supervisor is not restarted once it is closing
+	runs := make(chan struct{}, 10)
+	assert.NoError(t, s.Go("d", RestartPolicy{}, func(r *TaskRun) error {
+		runs <- struct{}{}
+		<-s.Dying()
+		return errors.New("closing")
+	}))
+	<-runs
+
+	assert.True(t, s.Alive())
+	assert.NoError(t, s.Close())
+	assert.False(t, s.Alive())
+	assert.Equal(t, []string{"c", "b", "a"}, stopped)
+	assert.Len(t, runs, 0)
+}
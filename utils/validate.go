@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/validator.v2"
+)
+
+// FieldError the error of the field invalid, the field is the path of the field in yaml names, such as logger.maxAge
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Err.Error()
+}
+
+// ValidationError the errors of all fields invalid, sorted by the paths of the fields
+type ValidationError []FieldError
+
+func (e ValidationError) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, fe := range e {
+		msgs = append(msgs, fe.Error())
+	}
+	return strings.Join(msgs, ", ")
+}
+
+// Validate validates the struct by the validate tags, such as min=1, max=5, nonzero and regexp=^(json|console)$,
+// the errors of all fields invalid (including the nested ones) are returned in ValidationError
+func Validate(v interface{}) error {
+	err := validator.Validate(v)
+	errs, ok := err.(validator.ErrorMap)
+	if !ok {
+		return err
+	}
+	var res ValidationError
+	for field, fes := range errs {
+		field = yamlPath(reflect.TypeOf(v), field)
+		for _, fe := range fes {
+			res = append(res, FieldError{Field: field, Err: fe})
+		}
+	}
+	if len(res) == 0 {
+		return nil
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Field < res[j].Field
+	})
+	return res
+}
+
+// yamlPath maps the path of the field in go names (such as Logger.MaxAge or Servers[0].Port) to the one in yaml names,
+// the fields inlined are omitted, and the rest of the path is kept as is once the field is not found, such as of interfaces
+func yamlPath(t reflect.Type, path string) string {
+	var names []string
+	for path != "" {
+		for t != nil && t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		end := strings.IndexAny(path, ".[")
+		if end < 0 {
+			end = len(path)
+		}
+		var f reflect.StructField
+		ok := t != nil && t.Kind() == reflect.Struct
+		if ok {
+			f, ok = t.FieldByName(path[:end])
+		}
+		if !ok {
+			names = append(names, path)
+			break
+		}
+		name, inline := yamlName(f)
+		t, path = f.Type, path[end:]
+		// the indexes of slices and the keys of maps, such as [0], [k](key) and [k](value)
+		for strings.HasPrefix(path, "[") {
+			for t.Kind() == reflect.Ptr {
+				t = t.Elem()
+			}
+			end = strings.Index(path, "]") + 1
+			if end == 0 {
+				end = len(path)
+			}
+			if t.Kind() == reflect.Map {
+				if i := strings.Index(path, "](key)"); i >= 0 {
+					end, t = i+len("](key)"), t.Key()
+				} else if i = strings.Index(path, "](value)"); i >= 0 {
+					end, t = i+len("](value)"), t.Elem()
+				}
+			} else if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+				t = t.Elem()
+			}
+			name, inline = name+path[:end], false
+			path = path[end:]
+		}
+		if !inline {
+			names = append(names, name)
+		}
+		path = strings.TrimPrefix(path, ".")
+	}
+	return strings.Join(names, ".")
+}
+
+// yamlName returns the yaml name of the field, and whether the field is inlined
+func yamlName(f reflect.StructField) (string, bool) {
+	parts := strings.Split(f.Tag.Get("yaml"), ",")
+	name := parts[0]
+	if name == "" || name == "-" {
+		name = strings.ToLower(f.Name)
+	}
+	for _, p := range parts[1:] {
+		if p == "inline" {
+			return name, true
+		}
+	}
+	return name, false
+}
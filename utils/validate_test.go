@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testValidateServer struct {
+	Address string `yaml:"address" json:"address" validate:"regexp=^(tcp|ssl)://.+$"`
+	Port    int    `yaml:"port" json:"port" default:"1883" validate:"min=1, max=65535"`
+}
+
+type testValidateLimit struct {
+	MaxConns int `yaml:"maxConns" json:"maxConns" validate:"min=0"`
+}
+
+type testValidateConfig struct {
+	Name              string                         `yaml:"name" json:"name" validate:"nonzero"`
+	Server            testValidateServer             `yaml:"server" json:"server"`
+	Backup            *testValidateServer            `yaml:"backup" json:"backup"`
+	Servers           []testValidateServer           `yaml:"servers" json:"servers"`
+	Routes            map[string]*testValidateServer `yaml:"routes" json:"routes"`
+	testValidateLimit `yaml:",inline" json:",inline"`
+}
+
+func TestValidate(t *testing.T) {
+	var cfg testValidateConfig
+	err := UnmarshalYAML([]byte(`
+name: n
+server:
+  address: tcp://127.0.0.1
+backup:
+  address: ssl://127.0.0.1
+servers:
+  - address: tcp://127.0.0.2
+`), &cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, 1883, cfg.Server.Port)
+	assert.Equal(t, 1883, cfg.Backup.Port)
+	assert.Equal(t, 1883, cfg.Servers[0].Port)
+
+	cfg = testValidateConfig{}
+	err = UnmarshalYAML([]byte(`
+server:
+  address: udp://127.0.0.1
+  port: 70000
+backup:
+  address: ssl://127.0.0.1
+  port: -1
+`), &cfg)
+	// the paths of fields are in yaml names
+	assert.EqualError(t, err, "backup.port: less than min, name: zero value, server.address: regular expression mismatch, server.port: greater than max")
+	errs, ok := err.(ValidationError)
+	assert.True(t, ok)
+	assert.Len(t, errs, 4)
+	assert.Equal(t, "backup.port", errs[0].Field)
+
+	cfg = testValidateConfig{}
+	err = UnmarshalYAML([]byte(`
+name: n
+server:
+  address: tcp://127.0.0.1
+servers:
+  - address: tcp://127.0.0.2
+  - address: udp://127.0.0.3
+routes:
+  r1:
+    address: udp://127.0.0.4
+    port: 1
+maxConns: -1
+`), &cfg)
+	assert.EqualError(t, err, "maxConns: less than min, routes[r1](value).address: regular expression mismatch, servers[1].address: regular expression mismatch")
+
+	assert.NoError(t, Validate(&testValidateConfig{Name: "n", Server: testValidateServer{Address: "tcp://a", Port: 1}}))
+	assert.Error(t, Validate("x"))
+}
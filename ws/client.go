@@ -0,0 +1,160 @@
+package ws
+
+import (
+	"context"
+	"encoding/base64"
+	gohttp "net/http"
+	"time"
+
+	"github.com/baetyl/baetyl-go/errors"
+	"github.com/baetyl/baetyl-go/http"
+	"github.com/baetyl/baetyl-go/log"
+	v1 "github.com/baetyl/baetyl-go/spec/v1"
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/gorilla/websocket"
+	"github.com/jpillora/backoff"
+)
+
+// ErrClientAlreadyClosed the client is closed
+var ErrClientAlreadyClosed = errors.Coded(errors.CodeUnavailable, "client is closed")
+
+// Client the reconnecting websocket client which exchanges messages in json
+type Client struct {
+	cfg    ClientConfig
+	obs    Observer
+	dialer *websocket.Dialer
+	header gohttp.Header
+	cache  chan *v1.Message
+	log    *log.Logger
+	tomb   utils.Tomb
+}
+
+// NewClient creates a new websocket client, which keeps connecting to the server in background
+func NewClient(cc ClientConfig, obs Observer) (*Client, error) {
+	dialer := &websocket.Dialer{
+		Proxy:            gohttp.ProxyFromEnvironment,
+		HandshakeTimeout: cc.Timeout,
+	}
+	if cc.Certificate.Key != "" || cc.Certificate.Cert != "" || cc.Certificate.CA != "" {
+		tlsCfg, err := utils.NewTLSConfigClient(cc.Certificate)
+		if err != nil {
+			return nil, err
+		}
+		if !cc.Certificate.InsecureSkipVerify && cc.Certificate.Name != "" {
+			tlsCfg.ServerName = cc.Certificate.Name
+		}
+		dialer.TLSClientConfig = tlsCfg
+	}
+	header := gohttp.Header{}
+	if cc.Username != "" || cc.Password != "" {
+		auth := base64.StdEncoding.EncodeToString([]byte(cc.Username + ":" + cc.Password))
+		header.Set(http.HeaderAuthorization, "Basic "+auth)
+	}
+	cli := &Client{
+		cfg:    cc,
+		obs:    obs,
+		dialer: dialer,
+		header: header,
+		cache:  make(chan *v1.Message, cc.MaxCacheMessages),
+		log:    log.With(log.Any("ws", "client")),
+	}
+	cli.tomb.Go(cli.connecting)
+	return cli, nil
+}
+
+// Send sends a message asynchronously
+func (c *Client) Send(msg *v1.Message) error {
+	if !c.tomb.Alive() {
+		return ErrClientAlreadyClosed
+	}
+	select {
+	case c.cache <- msg:
+	case <-c.tomb.Dying():
+		return ErrClientAlreadyClosed
+	}
+	return nil
+}
+
+// SendContext sends a message with context asynchronously
+func (c *Client) SendContext(ctx context.Context, msg *v1.Message) error {
+	if !c.tomb.Alive() {
+		return ErrClientAlreadyClosed
+	}
+	select {
+	case c.cache <- msg:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.tomb.Dying():
+		return ErrClientAlreadyClosed
+	}
+	return nil
+}
+
+// Close closes client
+func (c *Client) Close() error {
+	c.log.Info("client is closing")
+	defer c.log.Info("client has closed")
+
+	c.tomb.Kill(nil)
+	return c.tomb.Wait()
+}
+
+func (c *Client) connecting() error {
+	c.log.Info("client starts to keep connect")
+	defer c.log.Info("client has stopped connecting")
+
+	var err error
+	var curr *v1.Message
+	var next time.Time
+	var conn *conn
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	bf := backoff.Backoff{
+		Min:    time.Second,
+		Max:    c.cfg.Interval,
+		Factor: 1.6,
+	}
+
+	for {
+		if !next.IsZero() {
+			timer.Reset(next.Sub(time.Now()))
+			c.log.Info("next reconnect", log.Any("at", next), log.Any("attempt", bf.Attempt()))
+		}
+		if conn != nil {
+			conn.close()
+			conn = nil
+			c.log.Info("client has disconnected")
+		}
+		select {
+		case <-c.tomb.Dying():
+			return nil
+		case <-timer.C:
+		}
+
+		c.log.Info("client starts to connect")
+		next = time.Now().Add(bf.Duration())
+		conn, err = c.connect()
+		if err != nil {
+			c.onErr("failed to connect", err)
+			continue
+		}
+		c.log.Info("client has connected")
+		bf.Reset()
+		curr = conn.sending(curr)
+	}
+}
+
+func (c *Client) onMsg(msg *v1.Message) error {
+	if c.obs == nil {
+		return nil
+	}
+	return c.obs.OnMsg(msg)
+}
+
+func (c *Client) onErr(msg string, err error) {
+	if c.obs == nil || err == nil {
+		return
+	}
+	c.log.Error(msg, log.Error(err))
+	c.obs.OnErr(err)
+}
@@ -0,0 +1,145 @@
+package ws
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/baetyl/baetyl-go/log"
+	v1 "github.com/baetyl/baetyl-go/spec/v1"
+	"github.com/baetyl/baetyl-go/utils"
+	"github.com/gorilla/websocket"
+)
+
+type conn struct {
+	cli  *Client
+	ws   *websocket.Conn
+	tomb utils.Tomb
+	once sync.Once
+	mu   sync.Mutex
+}
+
+func (c *Client) connect() (*conn, error) {
+	ws, _, err := c.dialer.Dial(c.cfg.Address, c.header)
+	if err != nil {
+		return nil, err
+	}
+	ws.SetReadLimit(int64(c.cfg.MaxMessageSize))
+	s := &conn{
+		cli: c,
+		ws:  ws,
+	}
+	if c.cfg.KeepAlive > 0 {
+		ws.SetReadDeadline(time.Now().Add(c.cfg.KeepAlive * 2))
+		ws.SetPongHandler(func(string) error {
+			return ws.SetReadDeadline(time.Now().Add(c.cfg.KeepAlive * 2))
+		})
+	}
+	s.tomb.Go(s.receiving)
+	return s, nil
+}
+
+func (s *conn) send(msg *v1.Message) error {
+	s.mu.Lock()
+	err := s.ws.WriteJSON(msg)
+	s.mu.Unlock()
+	if err != nil {
+		s.die("failed to send message", err)
+		return err
+	}
+
+	if ent := s.cli.log.Check(log.DebugLevel, "client sent a message"); ent != nil {
+		ent.Write(log.Any("msg", fmt.Sprintf("%v", msg)))
+	}
+
+	return nil
+}
+
+func (s *conn) ping() error {
+	s.mu.Lock()
+	err := s.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(s.cli.cfg.Timeout))
+	s.mu.Unlock()
+	if err != nil {
+		s.die("failed to send ping", err)
+	}
+	return err
+}
+
+func (s *conn) sending(curr *v1.Message) *v1.Message {
+	s.cli.log.Info("client starts to send messages")
+	defer s.cli.log.Info("client has stopped sending messages")
+
+	var err error
+	if curr != nil {
+		err = s.send(curr)
+		if err != nil {
+			return curr
+		}
+	}
+	var tick <-chan time.Time
+	if s.cli.cfg.KeepAlive > 0 {
+		ticker := time.NewTicker(s.cli.cfg.KeepAlive)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case msg := <-s.cli.cache:
+			err = s.send(msg)
+			if err != nil {
+				return msg
+			}
+		case <-tick:
+			if s.ping() != nil {
+				return nil
+			}
+		case <-s.cli.tomb.Dying():
+			return nil
+		case <-s.tomb.Dying():
+			return nil
+		}
+	}
+}
+
+func (s *conn) receiving() error {
+	s.cli.log.Info("client starts to receive messages")
+	defer s.cli.log.Info("client has stopped receiving messages")
+
+	for {
+		_, data, err := s.ws.ReadMessage()
+		if err != nil {
+			s.die("failed to receive message", err)
+			return err
+		}
+		msg, err := v1.ParseMessage(data)
+		if err != nil {
+			s.cli.log.Warn("failed to parse message", log.Error(err))
+			continue
+		}
+
+		if ent := s.cli.log.Check(log.DebugLevel, "client received a message"); ent != nil {
+			ent.Write(log.Any("msg", fmt.Sprintf("%v", msg)))
+		}
+
+		if uerr := s.cli.onMsg(msg); uerr != nil {
+			s.cli.log.Warn("failed to handle message in user code", log.Error(uerr))
+		}
+	}
+}
+
+func (s *conn) die(msg string, err error) {
+	s.once.Do(func() {
+		s.tomb.Kill(err)
+		s.cli.onErr(msg, err)
+	})
+}
+
+// ! called in the same goroutine with sending
+func (s *conn) close() error {
+	s.die("", nil)
+	s.mu.Lock()
+	s.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	s.mu.Unlock()
+	s.ws.Close()
+	return s.tomb.Wait()
+}
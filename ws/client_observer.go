@@ -0,0 +1,45 @@
+package ws
+
+import v1 "github.com/baetyl/baetyl-go/spec/v1"
+
+// OnMsg handles next message
+type OnMsg func(*v1.Message) error
+
+// OnErr handles error
+type OnErr func(error)
+
+// Observer message observer interface
+type Observer interface {
+	OnMsg(*v1.Message) error
+	OnErr(error)
+}
+
+// ObserverWrapper websocket message handler wrapper
+type ObserverWrapper struct {
+	onMsg OnMsg
+	onErr OnErr
+}
+
+// NewObserverWrapper creates a new handler wrapper
+func NewObserverWrapper(onMsg OnMsg, onErr OnErr) *ObserverWrapper {
+	return &ObserverWrapper{
+		onMsg: onMsg,
+		onErr: onErr,
+	}
+}
+
+// OnMsg handles next message
+func (h *ObserverWrapper) OnMsg(msg *v1.Message) error {
+	if h.onMsg == nil {
+		return nil
+	}
+	return h.onMsg(msg)
+}
+
+// OnErr handles error
+func (h *ObserverWrapper) OnErr(err error) {
+	if h.onErr == nil {
+		return
+	}
+	h.onErr(err)
+}
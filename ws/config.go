@@ -0,0 +1,29 @@
+package ws
+
+import (
+	"time"
+
+	"github.com/baetyl/baetyl-go/http"
+	"github.com/baetyl/baetyl-go/utils"
+)
+
+// ServerConfig websocket server config, the websocket endpoint is served at the path of the http server
+type ServerConfig struct {
+	Server         http.ServerConfig `yaml:",inline" json:",inline"`
+	Path           string            `yaml:"path" json:"path" default:"/ws"`
+	MaxMessageSize utils.Size        `yaml:"maxMessageSize" json:"maxMessageSize" default:"4194304"`
+	KeepAlive      time.Duration     `yaml:"keepalive" json:"keepalive" default:"30s"`
+}
+
+// ClientConfig websocket client config, the address is an url like wss://host:port/ws
+type ClientConfig struct {
+	Address          string            `yaml:"address" json:"address"`
+	Username         string            `yaml:"username" json:"username"`
+	Password         string            `yaml:"password" json:"password"`
+	Certificate      utils.Certificate `yaml:",inline" json:",inline"`
+	Timeout          time.Duration     `yaml:"timeout" json:"timeout" default:"30s"`
+	Interval         time.Duration     `yaml:"interval" json:"interval" default:"2m"`
+	KeepAlive        time.Duration     `yaml:"keepalive" json:"keepalive" default:"30s"`
+	MaxMessageSize   utils.Size        `yaml:"maxMessageSize" json:"maxMessageSize" default:"4194304"`
+	MaxCacheMessages int               `yaml:"maxCacheMessages" json:"maxCacheMessages" default:"10"`
+}
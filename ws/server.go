@@ -0,0 +1,235 @@
+package ws
+
+import (
+	"fmt"
+	"net"
+	gohttp "net/http"
+	"sync"
+	"time"
+
+	"github.com/baetyl/baetyl-go/http"
+	"github.com/baetyl/baetyl-go/log"
+	v1 "github.com/baetyl/baetyl-go/spec/v1"
+	"github.com/gorilla/websocket"
+)
+
+// Handler handles the sessions accepted by server
+type Handler interface {
+	OnMsg(*Session, *v1.Message) error
+	OnClose(*Session, error)
+}
+
+// BasicAuthenticator authenticates by the username and password in Authorization (Basic) header,
+// which are set by the websocket client
+type BasicAuthenticator struct {
+	accounts map[string]string
+}
+
+// NewBasicAuthenticator creates a new basic authenticator, the map is from username to password
+func NewBasicAuthenticator(accounts map[string]string) *BasicAuthenticator {
+	return &BasicAuthenticator{accounts: accounts}
+}
+
+// Authenticate authenticates the username and password, the username is the identity
+func (a *BasicAuthenticator) Authenticate(r *gohttp.Request) (string, error) {
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return "", http.ErrUnauthenticated
+	}
+	if p, ok := a.accounts[username]; !ok || p != password {
+		return "", http.ErrUnauthenticated
+	}
+	return username, nil
+}
+
+// Server the websocket server which exchanges messages in json with clients
+type Server struct {
+	cfg      ServerConfig
+	svr      *http.Server
+	handler  Handler
+	upgrader websocket.Upgrader
+	sessions map[*Session]struct{}
+	closed   bool
+	mu       sync.Mutex
+	wg       sync.WaitGroup
+	log      *log.Logger
+}
+
+// NewServer creates a new websocket server and starts to serve,
+// the requests are rejected unless one of the authenticators passes if any
+func NewServer(cfg ServerConfig, handler Handler, auths ...http.Authenticator) (*Server, error) {
+	var mws []http.Middleware
+	if len(auths) > 0 {
+		mws = append(mws, http.Authenticate(auths...))
+	}
+	svr, err := http.NewServer(cfg.Server, mws...)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		cfg:      cfg,
+		svr:      svr,
+		handler:  handler,
+		upgrader: websocket.Upgrader{HandshakeTimeout: cfg.Server.ReadTimeout},
+		sessions: map[*Session]struct{}{},
+		log:      log.With(log.Any("ws", "server")),
+	}
+	svr.Handle(cfg.Path, s)
+	return s, nil
+}
+
+// Addr returns the listener address
+func (s *Server) Addr() net.Addr {
+	return s.svr.Addr()
+}
+
+// ServeHTTP upgrades the request to websocket and serves the session until it is closed
+func (s *Server) ServeHTTP(w gohttp.ResponseWriter, r *gohttp.Request) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		gohttp.Error(w, gohttp.StatusText(gohttp.StatusServiceUnavailable), gohttp.StatusServiceUnavailable)
+		return
+	}
+	s.wg.Add(1)
+	s.mu.Unlock()
+	defer s.wg.Done()
+
+	c, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		s.log.Warn("failed to upgrade connection", log.Any("remote", r.RemoteAddr), log.Error(err))
+		return
+	}
+	id, ok := http.IdentityFromContext(r.Context())
+	if !ok {
+		id = r.RemoteAddr
+	}
+	ss := &Session{
+		id:   id,
+		ws:   c,
+		svr:  s,
+		done: make(chan struct{}),
+	}
+	s.mu.Lock()
+	s.sessions[ss] = struct{}{}
+	s.mu.Unlock()
+	s.log.Info("server accepted a session", log.Any("id", id))
+
+	err = ss.serving()
+
+	s.mu.Lock()
+	delete(s.sessions, ss)
+	s.mu.Unlock()
+	ss.Close()
+	if s.handler != nil {
+		s.handler.OnClose(ss, err)
+	}
+	s.log.Info("server closed a session", log.Any("id", id))
+}
+
+// Close closes all sessions and the http server
+func (s *Server) Close() error {
+	s.log.Info("server is closing")
+	defer s.log.Info("server has closed")
+
+	s.mu.Lock()
+	s.closed = true
+	for ss := range s.sessions {
+		ss.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return s.svr.Close()
+}
+
+// Session the websocket session of a client
+type Session struct {
+	id   string
+	ws   *websocket.Conn
+	svr  *Server
+	once sync.Once
+	done chan struct{}
+	mu   sync.Mutex
+}
+
+// ID returns the identity of the client authenticated, or the remote address if no authenticator
+func (ss *Session) ID() string {
+	return ss.id
+}
+
+// Send sends a message to the client
+func (ss *Session) Send(msg *v1.Message) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if ss.svr.cfg.Server.WriteTimeout > 0 {
+		ss.ws.SetWriteDeadline(time.Now().Add(ss.svr.cfg.Server.WriteTimeout))
+	}
+	err := ss.ws.WriteJSON(msg)
+	if err != nil {
+		return err
+	}
+	if ent := ss.svr.log.Check(log.DebugLevel, "server sent a message"); ent != nil {
+		ent.Write(log.Any("id", ss.id), log.Any("msg", fmt.Sprintf("%v", msg)))
+	}
+	return nil
+}
+
+// Close closes the session
+func (ss *Session) Close() error {
+	ss.once.Do(func() {
+		close(ss.done)
+		ss.mu.Lock()
+		ss.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+		ss.mu.Unlock()
+		ss.ws.Close()
+	})
+	return nil
+}
+
+func (ss *Session) serving() error {
+	cfg := ss.svr.cfg
+	ss.ws.SetReadLimit(int64(cfg.MaxMessageSize))
+	// the deadlines set by http server are cleared after hijacked
+	ss.ws.UnderlyingConn().SetDeadline(time.Time{})
+	if cfg.KeepAlive > 0 {
+		ss.ws.SetReadDeadline(time.Now().Add(cfg.KeepAlive * 2))
+		ss.ws.SetPingHandler(func(data string) error {
+			ss.ws.SetReadDeadline(time.Now().Add(cfg.KeepAlive * 2))
+			ss.mu.Lock()
+			defer ss.mu.Unlock()
+			err := ss.ws.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+			if err == websocket.ErrCloseSent {
+				return nil
+			}
+			return err
+		})
+	}
+	for {
+		_, data, err := ss.ws.ReadMessage()
+		if err != nil {
+			select {
+			case <-ss.done:
+				return nil
+			default:
+			}
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				return nil
+			}
+			return err
+		}
+		msg, err := v1.ParseMessage(data)
+		if err != nil {
+			ss.svr.log.Warn("failed to parse message", log.Any("id", ss.id), log.Error(err))
+			continue
+		}
+		if ent := ss.svr.log.Check(log.DebugLevel, "server received a message"); ent != nil {
+			ent.Write(log.Any("id", ss.id), log.Any("msg", fmt.Sprintf("%v", msg)))
+		}
+		if ss.svr.handler == nil {
+			continue
+		}
+		if uerr := ss.svr.handler.OnMsg(ss, msg); uerr != nil {
+			ss.svr.log.Warn("failed to handle message in user code", log.Any("id", ss.id), log.Error(uerr))
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package ws
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/baetyl/baetyl-go/spec/v1"
+	"github.com/creasty/defaults"
+	"github.com/stretchr/testify/assert"
+)
+
+type echoHandler struct {
+	closed chan string
+}
+
+func (h *echoHandler) OnMsg(ss *Session, msg *v1.Message) error {
+	msg.Metadata = map[string]string{"id": ss.ID()}
+	return ss.Send(msg)
+}
+
+func (h *echoHandler) OnClose(ss *Session, err error) {
+	h.closed <- ss.ID()
+}
+
+func newServer(t *testing.T, h Handler) *Server {
+	var cfg ServerConfig
+	defaults.Set(&cfg)
+	cfg.Server.Address = "127.0.0.1:0"
+	svr, err := NewServer(cfg, h, NewBasicAuthenticator(map[string]string{"u": "p"}))
+	assert.NoError(t, err)
+	return svr
+}
+
+func newClientConfig(svr *Server) ClientConfig {
+	var cc ClientConfig
+	defaults.Set(&cc)
+	cc.Address = "ws://" + svr.Addr().String() + "/ws"
+	cc.Username = "u"
+	cc.Password = "p"
+	cc.Interval = time.Second
+	return cc
+}
+
+func TestWebSocket(t *testing.T) {
+	h := &echoHandler{closed: make(chan string, 10)}
+	svr := newServer(t, h)
+	defer svr.Close()
+
+	msgs := make(chan *v1.Message, 10)
+	errs := make(chan error, 10)
+	obs := NewObserverWrapper(func(msg *v1.Message) error {
+		msgs <- msg
+		return nil
+	}, func(err error) {
+		errs <- err
+	})
+	cli, err := NewClient(newClientConfig(svr), obs)
+	assert.NoError(t, err)
+
+	err = cli.Send(v1.NewMessage(v1.MessageReport, map[string]int{"a": 1}))
+	assert.NoError(t, err)
+	select {
+	case msg := <-msgs:
+		assert.Equal(t, v1.MessageReport, msg.Kind)
+		assert.Equal(t, "u", msg.Metadata["id"])
+		var content map[string]int
+		assert.NoError(t, msg.Content.Unmarshal(&content))
+		assert.Equal(t, map[string]int{"a": 1}, content)
+	case <-time.After(10 * time.Second):
+		t.Fatal("nothing received")
+	}
+
+	cli.Close()
+	select {
+	case id := <-h.closed:
+		assert.Equal(t, "u", id)
+	case <-time.After(10 * time.Second):
+		t.Fatal("session not closed")
+	}
+	assert.Equal(t, ErrClientAlreadyClosed, cli.Send(v1.NewMessage(v1.MessageReport, nil)))
+
+	cc := newClientConfig(svr)
+	cc.Password = "x"
+	cli, err = NewClient(cc, obs)
+	assert.NoError(t, err)
+	defer cli.Close()
+	select {
+	case err := <-errs:
+		assert.True(t, strings.Contains(err.Error(), "bad handshake"))
+	case <-time.After(10 * time.Second):
+		t.Fatal("no error")
+	}
+}
+
+func TestWebSocketReconnect(t *testing.T) {
+	h := &echoHandler{closed: make(chan string, 10)}
+	svr := newServer(t, h)
+	defer svr.Close()
+
+	msgs := make(chan *v1.Message, 10)
+	cli, err := NewClient(newClientConfig(svr), NewObserverWrapper(func(msg *v1.Message) error {
+		msgs <- msg
+		return nil
+	}, nil))
+	assert.NoError(t, err)
+	defer cli.Close()
+
+	err = cli.Send(v1.NewMessage(v1.MessageEvent, "1"))
+	assert.NoError(t, err)
+	select {
+	case msg := <-msgs:
+		assert.Equal(t, v1.MessageEvent, msg.Kind)
+	case <-time.After(10 * time.Second):
+		t.Fatal("nothing received")
+	}
+
+	// the server drops the session, the client reconnects
+	svr.mu.Lock()
+	for ss := range svr.sessions {
+		ss.Close()
+	}
+	svr.mu.Unlock()
+	<-h.closed
+
+	assert.NoError(t, cli.Send(v1.NewMessage(v1.MessageEvent, "2")))
+	assert.NoError(t, cli.Send(v1.NewMessage(v1.MessageEvent, "3")))
+	timeout := time.After(30 * time.Second)
+	for {
+		select {
+		case msg := <-msgs:
+			var s string
+			assert.NoError(t, msg.Content.Unmarshal(&s))
+			if s == "3" {
+				return
+			}
+		case <-timeout:
+			t.Fatal("nothing received after reconnected")
+		}
+	}
+}